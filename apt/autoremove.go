@@ -0,0 +1,204 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package apt // import "github.com/ebikt/go-debian/apt"
+
+import (
+	"regexp"
+	"sort"
+
+	"github.com/ebikt/go-debian/dependency"
+)
+
+// Autoremove {{{
+
+// AutoremoveOptions tunes which packages are kept by Autoremovable, like
+// the matching apt options do.
+type AutoremoveOptions struct {
+	// Keep packages recommended (APT::AutoRemove::RecommendsImportant) or
+	// suggested (APT::AutoRemove::SuggestsImportant) by a package that is
+	// kept. Both default to true in apt.
+	RecommendsImportant bool
+	SuggestsImportant   bool
+
+	// Regular expressions of package names that are never removed
+	// automatically (APT::NeverAutoRemove).
+	NeverAutoRemove []string
+}
+
+// DefaultAutoremoveOptions returns the options apt uses without any
+// configuration.
+func DefaultAutoremoveOptions() AutoremoveOptions {
+	return AutoremoveOptions{
+		RecommendsImportant: true,
+		SuggestsImportant:   true,
+	}
+}
+
+// NewAutoremoveOptions reads the AutoremoveOptions from the apt
+// configuration, falling back to apt's defaults.
+func NewAutoremoveOptions(config *Config) AutoremoveOptions {
+	return AutoremoveOptions{
+		RecommendsImportant: config.FindB("APT::AutoRemove::RecommendsImportant", true),
+		SuggestsImportant:   config.FindB("APT::AutoRemove::SuggestsImportant", true),
+		NeverAutoRemove:     config.FindList("APT::NeverAutoRemove"),
+	}
+}
+
+// Autoremovable computes the installed packages that were installed
+// automatically and that are no longer needed, the ones `apt autoremove`
+// would remove.
+//
+// Packages installed manually, Essential packages and packages matching
+// NeverAutoRemove are needed. So is every installed package satisfying
+// (directly or through Provides) any alternative of the Pre-Depends and
+// Depends of a needed package, and of its Recommends and Suggests,
+// depending on the options. Everything else marked auto in `states` is
+// returned, sorted by name and architecture.
+func Autoremovable(installed []Package, states *ExtendedStates, opts AutoremoveOptions) ([]Package, error) {
+	never := []*regexp.Regexp{}
+	for _, el := range opts.NeverAutoRemove {
+		re, err := regexp.Compile(el)
+		if err != nil {
+			return nil, err
+		}
+		never = append(never, re)
+	}
+
+	index := map[string][]int{}
+	for i, pkg := range installed {
+		index[pkg.Name] = append(index[pkg.Name], i)
+		for _, possi := range pkg.Provides.GetAllPossibilities() {
+			index[possi.Name] = append(index[possi.Name], i)
+		}
+	}
+
+	needed := make([]bool, len(installed))
+	queue := []int{}
+	keep := func(i int) {
+		if !needed[i] {
+			needed[i] = true
+			queue = append(queue, i)
+		}
+	}
+
+	for i, pkg := range installed {
+		if pkg.Essential || !states.IsAuto(pkg.Name, pkg.Architecture) {
+			keep(i)
+			continue
+		}
+		for _, re := range never {
+			if re.MatchString(pkg.Name) {
+				keep(i)
+				break
+			}
+		}
+	}
+
+	for len(queue) > 0 {
+		pkg := installed[queue[0]]
+		queue = queue[1:]
+
+		deps := []dependency.Dependency{pkg.PreDepends, pkg.Depends}
+		if opts.RecommendsImportant {
+			deps = append(deps, pkg.Recommends)
+		}
+		if opts.SuggestsImportant {
+			deps = append(deps, pkg.Suggests)
+		}
+		for _, dep := range deps {
+			for _, relation := range dep.Relations {
+				for _, possi := range relation.Possibilities {
+					if possi.Substvar {
+						continue
+					}
+					for _, i := range index[possi.Name] {
+						if satisfiedBy(pkg, possi, installed[i]) {
+							keep(i)
+						}
+					}
+				}
+			}
+		}
+	}
+
+	ret := []Package{}
+	for i, pkg := range installed {
+		if !needed[i] {
+			ret = append(ret, pkg)
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Key() < ret[j].Key()
+	})
+	return ret, nil
+}
+
+// satisfiedBy checks if the Possibility of a relation of package `from` is
+// satisfied by the installed package `to`, either directly or by what it
+// provides, honouring the Multi-Arch rules.
+func satisfiedBy(from Package, possi dependency.Possibility, to Package) bool {
+	if !archSatisfies(from, possi, to) {
+		return false
+	}
+	if to.Name == possi.Name {
+		if possi.Version == nil || possi.Version.SatisfiedBy(to.Version) {
+			return true
+		}
+	}
+	for _, provided := range to.Provides.GetAllPossibilities() {
+		if provided.Name == possi.Name && providedSatisfies(possi, provided.Version) {
+			return true
+		}
+	}
+	return false
+}
+
+// archSatisfies checks the architecture part of a relation: "foo:any" is
+// satisfied by a Multi-Arch: allowed package of any architecture, "foo:arch"
+// by that architecture only, and a plain "foo" by a package of the same
+// architecture, an Architecture: all one or a Multi-Arch: foreign one.
+// Without a package to relate to, "foo:arch" is satisfied by that
+// architecture only.
+func archSatisfies(from Package, possi dependency.Possibility, to Package) bool {
+	if from.Architecture == "" && possi.Arch != nil && !possi.Arch.IsWildcard() && to.Architecture != "" && to.Architecture != "all" {
+		/* "foo:arch" asked for on its own, as done by Resolver.Install */
+		arch, err := dependency.ParseArch(to.Architecture)
+		return err == nil && arch.Is(possi.Arch)
+	}
+	if to.MultiArch == "foreign" || to.Architecture == "all" || from.Architecture == "" || to.Architecture == "" {
+		return true
+	}
+	if possi.Arch == nil {
+		return from.Architecture == to.Architecture || from.Architecture == "all"
+	}
+	if possi.Arch.CPU == "any" && possi.Arch.OS == "any" {
+		return to.MultiArch == "allowed"
+	}
+	arch, err := dependency.ParseArch(to.Architecture)
+	if err != nil {
+		return false
+	}
+	return arch.Is(possi.Arch)
+}
+
+// }}}
+
+// vim: foldmethod=marker
@@ -0,0 +1,177 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package apt_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ebikt/go-debian/apt"
+)
+
+/*
+ *
+ */
+
+const autoremoveStatus = `Package: hello
+Status: install ok installed
+Architecture: amd64
+Version: 2.10-3
+Depends: libc6 (>= 2.34), libhello1 | libhello-alt1
+Recommends: hello-doc
+
+Package: libc6
+Status: install ok installed
+Architecture: amd64
+Version: 2.36-9
+Multi-Arch: same
+
+Package: libhello1
+Status: install ok installed
+Architecture: amd64
+Version: 1.0-1
+Depends: mail-transport-agent
+
+Package: libhello-alt1
+Status: install ok installed
+Architecture: amd64
+Version: 1.0-1
+
+Package: hello-doc
+Status: install ok installed
+Architecture: all
+Version: 2.10-3
+Suggests: pdf-viewer
+
+Package: postfix
+Status: install ok installed
+Architecture: amd64
+Version: 3.7.6-0
+Provides: mail-transport-agent
+
+Package: evince
+Status: install ok installed
+Architecture: amd64
+Version: 43.1-2
+Provides: pdf-viewer
+
+Package: libold1
+Status: install ok installed
+Architecture: amd64
+Version: 0.1-1
+
+Package: linux-image-6.1.0-10-amd64
+Status: install ok installed
+Architecture: amd64
+Version: 6.1.38-1
+
+Package: gone
+Status: deinstall ok config-files
+Architecture: amd64
+Version: 1.0-1
+`
+
+func autoremoveNames(t *testing.T, opts apt.AutoremoveOptions) string {
+	installed, err := apt.ReadInstalled(strings.NewReader(autoremoveStatus))
+	isok(t, err)
+	assert(t, len(installed) == 9)
+
+	states := apt.ExtendedStates{}
+	for _, pkg := range installed {
+		if pkg.Name != "hello" {
+			states.SetAuto(pkg.Name, pkg.Architecture, true)
+		}
+	}
+
+	removable, err := apt.Autoremovable(installed, &states, opts)
+	isok(t, err)
+	names := []string{}
+	for _, pkg := range removable {
+		names = append(names, pkg.Name)
+	}
+	return strings.Join(names, " ")
+}
+
+func TestAutoremovable(t *testing.T) {
+	opts := apt.DefaultAutoremoveOptions()
+	assert(t, autoremoveNames(t, opts) == "libold1 linux-image-6.1.0-10-amd64")
+
+	opts.NeverAutoRemove = []string{"^linux-image-.*"}
+	assert(t, autoremoveNames(t, opts) == "libold1")
+
+	opts.SuggestsImportant = false
+	assert(t, autoremoveNames(t, opts) == "evince libold1")
+
+	opts.RecommendsImportant = false
+	assert(t, autoremoveNames(t, opts) == "evince hello-doc libold1")
+
+	opts.NeverAutoRemove = []string{"("}
+	_, err := apt.Autoremovable(nil, &apt.ExtendedStates{}, opts)
+	notok(t, err)
+}
+
+func TestAutoremoveOptionsFromConfig(t *testing.T) {
+	opts := apt.NewAutoremoveOptions(parseConfig(t, `
+APT::AutoRemove::SuggestsImportant "false";
+APT::NeverAutoRemove { "^linux-image-.*"; "^firmware-"; };
+`))
+	assert(t, opts.RecommendsImportant)
+	assert(t, !opts.SuggestsImportant)
+	assert(t, len(opts.NeverAutoRemove) == 2)
+}
+
+func TestAutoremoveMultiArch(t *testing.T) {
+	installed, err := apt.ReadInstalled(strings.NewReader(`Package: tool
+Status: install ok installed
+Architecture: amd64
+Version: 1
+Depends: libfoo1, python3:any
+
+Package: libfoo1
+Status: install ok installed
+Architecture: amd64
+Version: 1
+
+Package: libfoo1
+Status: install ok installed
+Architecture: i386
+Version: 1
+
+Package: python3
+Status: install ok installed
+Architecture: i386
+Version: 3.11
+Multi-Arch: allowed
+`))
+	isok(t, err)
+
+	states := apt.ExtendedStates{}
+	states.SetAuto("libfoo1", "amd64", true)
+	states.SetAuto("libfoo1", "i386", true)
+	states.SetAuto("python3", "i386", true)
+
+	removable, err := apt.Autoremovable(installed, &states, apt.DefaultAutoremoveOptions())
+	isok(t, err)
+	assert(t, len(removable) == 1)
+	assert(t, removable[0].Key() == "libfoo1:i386")
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,127 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package apt // import "github.com/ebikt/go-debian/apt"
+
+import (
+	"sort"
+
+	"github.com/ebikt/go-debian/internal"
+)
+
+// BaseSet {{{
+
+// BaseSet computes the base set of packages of a suite, what debootstrap
+// installs: the Essential packages and the ones of the given Priorities,
+// along with everything they depend on.
+type BaseSet struct {
+	// Architecture of the system, packages of other architectures (but
+	// "all") are ignored.
+	Architecture string
+
+	// Priorities of the packages to start from, in addition to the
+	// Essential ones. debootstrap uses "required" for its minbase variant,
+	// and also "important" by default.
+	Priorities []string
+
+	// Names of extra packages to start from, and wildcards of the
+	// packages not to start from, like debootstrap --include and
+	// --exclude. Excluded packages are still installed when something
+	// else depends on them.
+	Include []string
+	Exclude []string
+
+	// If set, only the packages Filter returns true for are considered at
+	// all, for instance to drop the ones of a component.
+	Filter func(Package) bool
+
+	// Pins used to pick the version of a package, see
+	// UpgradeSelector.Priority.
+	Pins []Pin
+}
+
+// NewBaseSet creates a BaseSet of the Essential and required packages of
+// the given architecture, like the debootstrap minbase variant.
+func NewBaseSet(arch string) *BaseSet {
+	return &BaseSet{Architecture: arch, Priorities: []string{"required"}}
+}
+
+func (b BaseSet) available(packages []Package) []Package {
+	ret := []Package{}
+	for _, pkg := range packages {
+		if pkg.Architecture != b.Architecture && pkg.Architecture != "all" {
+			continue
+		}
+		if b.Filter != nil && !b.Filter(pkg) {
+			continue
+		}
+		ret = append(ret, pkg)
+	}
+	return ret
+}
+
+func (b BaseSet) excluded(name string) bool {
+	for _, pattern := range b.Exclude {
+		if internal.GlobMatch(pattern, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Seeds returns the names of the packages the base set starts from,
+// sorted.
+func (b BaseSet) Seeds(packages []Package) []string {
+	seen := map[string]bool{}
+	for _, pkg := range b.available(packages) {
+		seed := pkg.Essential
+		for _, priority := range b.Priorities {
+			seed = seed || pkg.Priority == priority
+		}
+		if seed && !b.excluded(pkg.Name) {
+			seen[pkg.Name] = true
+		}
+	}
+	for _, name := range b.Include {
+		seen[name] = true
+	}
+	ret := []string{}
+	for name := range seen {
+		ret = append(ret, name)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// Resolve returns the packages of the base set, sorted by name. Only the
+// dependencies are followed, not the Recommends. When a package can't be
+// installed, the error is an *Explanation of why.
+func (b BaseSet) Resolve(packages []Package) ([]Package, error) {
+	resolver := Resolver{Available: b.available(packages), Pins: b.Pins}
+	solution, err := resolver.Install(b.Seeds(packages)...)
+	if err != nil {
+		return nil, err
+	}
+	return solution.Install, nil
+}
+
+// }}}
+
+// vim: foldmethod=marker
@@ -0,0 +1,109 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package apt_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ebikt/go-debian/apt"
+)
+
+/*
+ *
+ */
+
+const basePackages = `Package: base-files
+Version: 12.4
+Architecture: amd64
+Essential: yes
+Priority: required
+Pre-Depends: awk
+
+Package: mawk
+Version: 1.3.4-2
+Architecture: amd64
+Priority: required
+Provides: awk
+
+Package: libc6
+Version: 2.36-9
+Architecture: amd64
+Priority: optional
+
+Package: libc6
+Version: 2.36-9
+Architecture: i386
+Priority: required
+
+Package: dash
+Version: 0.5.12-2
+Architecture: amd64
+Essential: yes
+Priority: required
+Depends: libc6 (>= 2.34)
+
+Package: apt
+Version: 2.6.1
+Architecture: amd64
+Priority: important
+Depends: libc6, debian-archive-keyring
+
+Package: debian-archive-keyring
+Version: 2023.3
+Architecture: all
+Priority: important
+
+Package: vim
+Version: 2:9.0.1378-2
+Architecture: amd64
+Priority: optional
+Depends: libc6
+`
+
+func TestBaseSet(t *testing.T) {
+	packages, err := apt.ReadPackages(strings.NewReader(basePackages))
+	isok(t, err)
+	assert(t, len(packages) == 8)
+	assert(t, packages[0].Essential)
+	assert(t, packages[0].Priority == "required")
+
+	base := apt.NewBaseSet("amd64")
+	assert(t, strings.Join(base.Seeds(packages), " ") == "base-files dash mawk")
+	set, err := base.Resolve(packages)
+	isok(t, err)
+	assert(t, solutionNames(&apt.Solution{Install: set}) == "base-files=12.4 dash=0.5.12-2 libc6=2.36-9 mawk=1.3.4-2")
+
+	base.Priorities = append(base.Priorities, "important")
+	base.Include = []string{"vim"}
+	base.Exclude = []string{"m*"}
+	assert(t, strings.Join(base.Seeds(packages), " ") == "apt base-files dash debian-archive-keyring vim")
+	set, err = base.Resolve(packages)
+	isok(t, err)
+	/* mawk is still pulled in by base-files */
+	assert(t, len(set) == 7)
+
+	base.Filter = func(pkg apt.Package) bool { return pkg.Name != "libc6" }
+	_, err = base.Resolve(packages)
+	notok(t, err)
+}
+
+// vim: foldmethod=marker
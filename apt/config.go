@@ -0,0 +1,578 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package apt // import "github.com/ebikt/go-debian/apt"
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Config {{{
+
+// A Node is one element of the APT configuration tree. Given the
+// configuration:
+//
+//   Acquire::http { Proxy "http://proxy:3142"; };
+//   APT::NeverAutoRemove { "^linux-image-.*"; };
+//
+// The Node for Acquire::http::Proxy has the Tag "Proxy" and the Value
+// "http://proxy:3142", and APT::NeverAutoRemove has a single child with
+// an empty Tag, which is how lists are stored.
+type Node struct {
+	Tag      string
+	Value    string
+	Children []*Node
+
+	parent *Node
+}
+
+// Config is an APT configuration tree, as described in apt.conf(5). Tags
+// are matched case insensitively, as apt does.
+type Config struct {
+	root Node
+}
+
+// NewConfig returns an empty Config.
+func NewConfig() *Config {
+	return &Config{}
+}
+
+// Full name of the Node, such as "Acquire::http::Proxy".
+func (n *Node) FullTag() string {
+	if n.parent == nil || n.parent.parent == nil {
+		return n.Tag
+	}
+	return n.parent.FullTag() + "::" + n.Tag
+}
+
+func (n *Node) child(tag string, create bool) *Node {
+	if tag != "" {
+		for _, child := range n.Children {
+			if strings.EqualFold(child.Tag, tag) {
+				return child
+			}
+		}
+	}
+	if !create {
+		return nil
+	}
+	child := &Node{Tag: tag, parent: n}
+	n.Children = append(n.Children, child)
+	return child
+}
+
+func splitTag(path string) []string {
+	if path == "" {
+		return []string{}
+	}
+	return strings.Split(path, "::")
+}
+
+// lookup walks down the tree along the given path. When `create` is set,
+// missing nodes are created, and an empty last element (as in "Foo::")
+// appends a new list entry.
+func (n *Node) lookup(path string, create bool) *Node {
+	node := n
+	for _, tag := range splitTag(path) {
+		node = node.child(tag, create)
+		if node == nil {
+			return nil
+		}
+	}
+	return node
+}
+
+// Tree returns the Node at the given path, or nil if there's none.
+func (c *Config) Tree(path string) *Node {
+	return c.root.lookup(path, false)
+}
+
+// Exists checks if there's a Node at the given path.
+func (c *Config) Exists(path string) bool {
+	return c.Tree(path) != nil
+}
+
+// Find returns the value at the given path, or `def` if it's not set.
+func (c *Config) Find(path, def string) string {
+	if node := c.Tree(path); node != nil && node.Value != "" {
+		return node.Value
+	}
+	return def
+}
+
+// FindB returns the boolean value at the given path, or `def` if it's not
+// set or is not a boolean apt understands.
+func (c *Config) FindB(path string, def bool) bool {
+	switch strings.ToLower(c.Find(path, "")) {
+	case "1", "yes", "true", "with", "on", "enable":
+		return true
+	case "0", "no", "false", "without", "off", "disable":
+		return false
+	}
+	return def
+}
+
+// FindI returns the integer value at the given path, or `def` if it's not
+// set or is not an integer.
+func (c *Config) FindI(path string, def int) int {
+	value, err := strconv.Atoi(c.Find(path, ""))
+	if err != nil {
+		return def
+	}
+	return value
+}
+
+// FindList returns the values of the children of the given path, which is
+// how apt stores lists such as APT::NeverAutoRemove.
+func (c *Config) FindList(path string) []string {
+	ret := []string{}
+	node := c.Tree(path)
+	if node == nil {
+		return ret
+	}
+	for _, child := range node.Children {
+		ret = append(ret, child.Value)
+	}
+	return ret
+}
+
+// Set the value at the given path, creating it as needed. A path ending in
+// "::" appends an entry to a list.
+func (c *Config) Set(path, value string) {
+	c.root.lookup(path, true).Value = value
+}
+
+// Clear removes the given path, and everything below it.
+func (c *Config) Clear(path string) {
+	node := c.Tree(path)
+	if node == nil || node.parent == nil {
+		return
+	}
+	siblings := node.parent.Children
+	for i, sibling := range siblings {
+		if sibling == node {
+			node.parent.Children = append(siblings[:i:i], siblings[i+1:]...)
+			return
+		}
+	}
+}
+
+// Dump writes the configuration out in the format of `apt-config dump`.
+func (c *Config) Dump(out io.Writer) error {
+	return dumpNode(out, &c.root)
+}
+
+func dumpNode(out io.Writer, node *Node) error {
+	for _, child := range node.Children {
+		if _, err := fmt.Fprintf(out, "%s %s;\n", child.FullTag(), strconv.Quote(child.Value)); err != nil {
+			return err
+		}
+		if err := dumpNode(out, child); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// }}}
+
+// Tokenizer {{{
+
+type tokenKind int
+
+const (
+	tokWord tokenKind = iota
+	tokString
+	tokOpen
+	tokClose
+	tokSemicolon
+	tokInclude
+	tokClear
+)
+
+type token struct {
+	Kind  tokenKind
+	Value string
+	Line  int
+}
+
+func tokenize(data string) ([]token, error) {
+	tokens := []token{}
+	line := 1
+	startOfLine := true
+
+	for i := 0; i < len(data); {
+		c := data[i]
+		switch {
+		case c == '\n':
+			line++
+			startOfLine = true
+			i++
+			continue
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+			continue
+		case c == '#' && startOfLine:
+			end := strings.IndexByte(data[i:], '\n')
+			if end == -1 {
+				end = len(data) - i
+			}
+			directive := data[i : i+end]
+			switch {
+			case strings.HasPrefix(directive, "#include"):
+				tokens = append(tokens, token{Kind: tokInclude, Line: line})
+				i += len("#include")
+			case strings.HasPrefix(directive, "#clear"):
+				tokens = append(tokens, token{Kind: tokClear, Line: line})
+				i += len("#clear")
+			default:
+				/* Plain comment */
+				i += end
+			}
+			startOfLine = false
+			continue
+		case strings.HasPrefix(data[i:], "//"):
+			end := strings.IndexByte(data[i:], '\n')
+			if end == -1 {
+				end = len(data) - i
+			}
+			i += end
+			continue
+		case strings.HasPrefix(data[i:], "/*"):
+			end := strings.Index(data[i+2:], "*/")
+			if end == -1 {
+				return nil, fmt.Errorf("line %d: Unterminated comment", line)
+			}
+			line += strings.Count(data[i:i+2+end], "\n")
+			i += end + 4
+			continue
+		}
+
+		startOfLine = false
+		switch c {
+		case '{':
+			tokens = append(tokens, token{Kind: tokOpen, Line: line})
+			i++
+		case '}':
+			tokens = append(tokens, token{Kind: tokClose, Line: line})
+			i++
+		case ';':
+			tokens = append(tokens, token{Kind: tokSemicolon, Line: line})
+			i++
+		case '"':
+			end := strings.IndexByte(data[i+1:], '"')
+			if end == -1 {
+				return nil, fmt.Errorf("line %d: Unterminated string", line)
+			}
+			value := data[i+1 : i+1+end]
+			tokens = append(tokens, token{Kind: tokString, Value: value, Line: line})
+			line += strings.Count(value, "\n")
+			i += end + 2
+		default:
+			start := i
+			for i < len(data) && !strings.ContainsRune(" \t\r\n{};\"", rune(data[i])) {
+				if strings.HasPrefix(data[i:], "//") || strings.HasPrefix(data[i:], "/*") {
+					break
+				}
+				i++
+			}
+			tokens = append(tokens, token{Kind: tokWord, Value: data[start:i], Line: line})
+		}
+	}
+	return tokens, nil
+}
+
+// }}}
+
+// Parser {{{
+
+// Parse reads configuration in the apt.conf(5) format from the reader, and
+// merges it into this Config. The filename is used to resolve relative
+// #include directives, and in error messages.
+func (c *Config) Parse(reader io.Reader, filename string) error {
+	return c.parse(reader, filename, 0)
+}
+
+// maxIncludeDepth guards against #include loops.
+const maxIncludeDepth = 16
+
+func (c *Config) parse(reader io.Reader, filename string, depth int) error {
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+	tokens, err := tokenize(string(data))
+	if err != nil {
+		return fmt.Errorf("%s: %s", filename, err)
+	}
+
+	p := parser{config: c, filename: filename, tokens: tokens, depth: depth}
+	if err := p.parseScope(&c.root, false); err != nil {
+		return fmt.Errorf("%s: %s", filename, err)
+	}
+	return nil
+}
+
+type parser struct {
+	config   *Config
+	filename string
+	tokens   []token
+	pos      int
+	depth    int
+}
+
+func (p *parser) peek() *token {
+	if p.pos >= len(p.tokens) {
+		return nil
+	}
+	return &p.tokens[p.pos]
+}
+
+func (p *parser) next() *token {
+	tok := p.peek()
+	if tok != nil {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *parser) line() int {
+	if tok := p.peek(); tok != nil {
+		return tok.Line
+	}
+	if len(p.tokens) > 0 {
+		return p.tokens[len(p.tokens)-1].Line
+	}
+	return 1
+}
+
+// parseScope parses statements into `scope`, until EOF (for the top level)
+// or the closing brace (for nested scopes).
+func (p *parser) parseScope(scope *Node, nested bool) error {
+	for {
+		tok := p.next()
+		if tok == nil {
+			if nested {
+				return fmt.Errorf("line %d: Missing '}'", p.line())
+			}
+			return nil
+		}
+
+		switch tok.Kind {
+		case tokSemicolon:
+			continue
+		case tokClose:
+			if !nested {
+				return fmt.Errorf("line %d: Unexpected '}'", tok.Line)
+			}
+			return nil
+		case tokClear, tokInclude:
+			if nested {
+				return fmt.Errorf("line %d: Directives can only be done at the top level", tok.Line)
+			}
+			if tok.Kind == tokClear {
+				if err := p.parseClear(); err != nil {
+					return err
+				}
+			} else if err := p.parseInclude(); err != nil {
+				return err
+			}
+		case tokString:
+			/* A list entry: `"value";` or `"value" { ... };` */
+			node := scope.child("", true)
+			node.Value = tok.Value
+			if err := p.parseStatementEnd(node); err != nil {
+				return err
+			}
+		case tokWord:
+			node := scope.lookup(tok.Value, true)
+			if next := p.peek(); next != nil && (next.Kind == tokString || next.Kind == tokWord) {
+				node.Value = p.next().Value
+			}
+			if err := p.parseStatementEnd(node); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("line %d: Syntax error", tok.Line)
+		}
+	}
+}
+
+// parseStatementEnd expects either a ';', or a nested scope.
+func (p *parser) parseStatementEnd(node *Node) error {
+	tok := p.next()
+	if tok == nil {
+		return fmt.Errorf("line %d: Missing ';'", p.line())
+	}
+	switch tok.Kind {
+	case tokSemicolon:
+		return nil
+	case tokOpen:
+		return p.parseScope(node, true)
+	case tokClose:
+		/* apt tolerates a missing ';' before the closing brace */
+		p.pos--
+		return nil
+	}
+	return fmt.Errorf("line %d: Expected ';' after '%s'", tok.Line, node.FullTag())
+}
+
+// parseClear clears the trees named by a #clear directive, which are full
+// tags, as directives are only allowed at the top level.
+func (p *parser) parseClear() error {
+	for {
+		tok := p.next()
+		if tok == nil || tok.Kind == tokSemicolon {
+			return nil
+		}
+		if tok.Kind != tokWord && tok.Kind != tokString {
+			return fmt.Errorf("line %d: Syntax error in #clear", tok.Line)
+		}
+		p.config.Clear(tok.Value)
+	}
+}
+
+func (p *parser) parseInclude() error {
+	tok := p.next()
+	if tok == nil || (tok.Kind != tokString && tok.Kind != tokWord) {
+		return fmt.Errorf("line %d: Syntax error in #include", p.line())
+	}
+	if next := p.peek(); next != nil && next.Kind == tokSemicolon {
+		p.next()
+	}
+	if p.depth >= maxIncludeDepth {
+		return fmt.Errorf("line %d: Too many nested #include", tok.Line)
+	}
+
+	target := tok.Value
+	if !filepath.IsAbs(target) && p.filename != "" {
+		target = filepath.Join(filepath.Dir(p.filename), target)
+	}
+	info, err := os.Stat(target)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return p.config.loadParts(target, p.depth+1)
+	}
+	return p.config.loadFile(target, p.depth+1)
+}
+
+// }}}
+
+// Loading files {{{
+
+// validPartName matches the files apt reads from a parts directory, such
+// as /etc/apt/apt.conf.d: alphanumerics, '-', '_' and '.', with either no
+// extension or the ".conf" extension.
+var validPartName = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+func isConfigPart(name string) bool {
+	if !validPartName.MatchString(name) {
+		return false
+	}
+	ext := filepath.Ext(name)
+	return ext == "" || ext == ".conf"
+}
+
+// LoadFile parses the given configuration file into this Config.
+func (c *Config) LoadFile(path string) error {
+	return c.loadFile(path, 0)
+}
+
+func (c *Config) loadFile(path string, depth int) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return c.parse(bufio.NewReader(f), path, depth)
+}
+
+// LoadParts parses every configuration file of the given directory, in
+// the order and with the file name rules apt uses for apt.conf.d.
+func (c *Config) LoadParts(dir string) error {
+	return c.loadParts(dir, 0)
+}
+
+func (c *Config) loadParts(dir string, depth int) error {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	names := []string{}
+	for _, entry := range entries {
+		if !entry.IsDir() && isConfigPart(entry.Name()) {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := c.loadFile(filepath.Join(dir, name), depth); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LoadSystemConfig reads the configuration of the host's apt, found below
+// the given root directory ("/" for the running system), in the order apt
+// does: the file named by the APT_CONFIG environment variable, if set,
+// then the parts in etc/apt/apt.conf.d, and finally etc/apt/apt.conf, so
+// that the later ones win. Those two are found through the Dir,
+// Dir::Etc, Dir::Etc::Parts and Dir::Etc::Main options, which the
+// APT_CONFIG file may set.
+func LoadSystemConfig(root string) (*Config, error) {
+	config := NewConfig()
+	if path := os.Getenv("APT_CONFIG"); path != "" {
+		if err := config.LoadFile(path); err != nil {
+			return nil, err
+		}
+	}
+	resolve := func(base, path string) string {
+		if filepath.IsAbs(path) {
+			return path
+		}
+		return filepath.Join(base, path)
+	}
+	etc := resolve(resolve("/", config.Find("Dir", "/")), config.Find("Dir::Etc", "etc/apt/"))
+	parts := filepath.Join(root, resolve(etc, config.Find("Dir::Etc::Parts", "apt.conf.d")))
+	if err := config.LoadParts(parts); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	main := filepath.Join(root, resolve(etc, config.Find("Dir::Etc::Main", "apt.conf")))
+	if err := config.LoadFile(main); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return config, nil
+}
+
+// }}}
+
+// vim: foldmethod=marker
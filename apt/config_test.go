@@ -0,0 +1,210 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package apt_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"testing"
+
+	"github.com/ebikt/go-debian/apt"
+)
+
+/*
+ *
+ */
+
+func isok(t *testing.T, err error) {
+	if err != nil {
+		log.Printf("Error! Error is not nil! %s\n", err)
+		debug.PrintStack()
+		t.FailNow()
+	}
+}
+
+func notok(t *testing.T, err error) {
+	if err == nil {
+		log.Printf("Error! Error is nil!\n")
+		debug.PrintStack()
+		t.FailNow()
+	}
+}
+
+func assert(t *testing.T, expr bool) {
+	if !expr {
+		log.Printf("Assertion failed!")
+		debug.PrintStack()
+		t.FailNow()
+	}
+}
+
+/*
+ *
+ */
+
+func parseConfig(t *testing.T, data string) *apt.Config {
+	config := apt.NewConfig()
+	isok(t, config.Parse(strings.NewReader(data), "test.conf"))
+	return config
+}
+
+func TestConfigValues(t *testing.T) {
+	config := parseConfig(t, `// Proxy settings
+Acquire::http::Proxy "http://proxy:3142";
+Acquire {
+  https {
+    Proxy "DIRECT"; /* no proxy
+                       for https */
+  };
+  Retries "3";
+};
+# a plain comment
+APT::Default-Release "bookworm";
+APT::Get::Assume-Yes "yes";
+`)
+
+	assert(t, config.Find("Acquire::http::Proxy", "") == "http://proxy:3142")
+	assert(t, config.Find("acquire::HTTPS::proxy", "") == "DIRECT")
+	assert(t, config.FindI("Acquire::Retries", 0) == 3)
+	assert(t, config.FindI("Acquire::Queue-Mode", 7) == 7)
+	assert(t, config.Find("APT::Default-Release", "") == "bookworm")
+	assert(t, config.FindB("APT::Get::Assume-Yes", false))
+	assert(t, !config.FindB("APT::Get::Fix-Broken", false))
+	assert(t, config.Exists("Acquire::https"))
+	assert(t, !config.Exists("Acquire::ftp"))
+	assert(t, config.Tree("Acquire::https::Proxy").FullTag() == "Acquire::https::Proxy")
+}
+
+func TestConfigSyntaxErrors(t *testing.T) {
+	for _, data := range []string{
+		"Acquire { Retries \"3\";",
+		"Acquire::Retries \"3\"",
+		"};",
+		"Acquire::Retries \"3;",
+		"/* Acquire::Retries \"3\";",
+	} {
+		config := apt.NewConfig()
+		notok(t, config.Parse(strings.NewReader(data), "test.conf"))
+	}
+}
+
+func TestConfigLists(t *testing.T) {
+	config := parseConfig(t, `
+APT::NeverAutoRemove { "^firmware-linux.*"; "^linux-firmware$"; };
+APT::NeverAutoRemove:: "^linux-image-.*";
+DPkg::Pre-Install-Pkgs {"/usr/sbin/dpkg-preconfigure --apt || true";};
+`)
+	list := config.FindList("APT::NeverAutoRemove")
+	assert(t, len(list) == 3)
+	assert(t, list[0] == "^firmware-linux.*")
+	assert(t, list[2] == "^linux-image-.*")
+	assert(t, len(config.FindList("DPkg::Pre-Install-Pkgs")) == 1)
+	assert(t, len(config.FindList("DPkg::Post-Invoke")) == 0)
+}
+
+func TestConfigClear(t *testing.T) {
+	config := parseConfig(t, `
+APT::NeverAutoRemove { "^firmware-linux.*"; };
+DPkg::Post-Invoke { "rm -f /var/cache/apt/archives/*.deb"; };
+#clear APT::NeverAutoRemove DPkg::Post-Invoke;
+APT::NeverAutoRemove { "^linux-image-.*"; };
+`)
+	list := config.FindList("APT::NeverAutoRemove")
+	assert(t, len(list) == 1)
+	assert(t, list[0] == "^linux-image-.*")
+	assert(t, !config.Exists("DPkg::Post-Invoke"))
+
+	/* Directives are only allowed at the top level, like apt does */
+	notok(t, apt.NewConfig().Parse(strings.NewReader("APT {\n#clear Default-Release;\n};"), ""))
+	notok(t, apt.NewConfig().Parse(strings.NewReader("APT {\n#include \"other.conf\";\n};"), ""))
+}
+
+func TestConfigDump(t *testing.T) {
+	config := parseConfig(t, `Acquire::http { Proxy "http://proxy:3142"; };
+APT::NeverAutoRemove { "^linux-image-.*"; };
+`)
+	buf := bytes.Buffer{}
+	isok(t, config.Dump(&buf))
+	assert(t, buf.String() == `Acquire "";
+Acquire::http "";
+Acquire::http::Proxy "http://proxy:3142";
+APT "";
+APT::NeverAutoRemove "";
+APT::NeverAutoRemove:: "^linux-image-.*";
+`)
+}
+
+func TestConfigIncludeAndParts(t *testing.T) {
+	root, err := ioutil.TempDir("", "go-debian-apt")
+	isok(t, err)
+	defer os.RemoveAll(root)
+
+	write := func(path, data string) {
+		path = filepath.Join(root, path)
+		isok(t, os.MkdirAll(filepath.Dir(path), 0755))
+		isok(t, ioutil.WriteFile(path, []byte(data), 0644))
+	}
+
+	write("etc/apt/apt.conf.d/10proxy", `Acquire::http::Proxy "http://a:3142";`)
+	write("etc/apt/apt.conf.d/20proxy.conf", `Acquire::http::Proxy "http://b:3142";`)
+	write("etc/apt/apt.conf.d/30proxy.dpkg-old", `Acquire::http::Proxy "http://c:3142";`)
+	write("etc/apt/apt.conf.d/40release", `#include "../extra/release.conf";`)
+	write("etc/apt/extra/release.conf", `APT::Default-Release "trixie";`)
+	write("etc/apt/apt.conf", `APT::Get::Assume-Yes "true";`)
+
+	os.Unsetenv("APT_CONFIG")
+	config, err := apt.LoadSystemConfig(root)
+	isok(t, err)
+	assert(t, config.Find("Acquire::http::Proxy", "") == "http://b:3142")
+	assert(t, config.Find("APT::Default-Release", "") == "trixie")
+	assert(t, config.FindB("APT::Get::Assume-Yes", false))
+
+	/* The APT_CONFIG file is read first, and can move the others */
+	write("env.conf", `Acquire::http::Proxy "http://env:3142"; APT::Install-Recommends "false";`)
+	os.Setenv("APT_CONFIG", filepath.Join(root, "env.conf"))
+	defer os.Unsetenv("APT_CONFIG")
+	config, err = apt.LoadSystemConfig(root)
+	isok(t, err)
+	assert(t, config.Find("Acquire::http::Proxy", "") == "http://b:3142")
+	assert(t, !config.FindB("APT::Install-Recommends", true))
+
+	write("env.conf", `Dir::Etc "etc/other"; Dir::Etc::Main "/etc/apt/apt.conf";`)
+	write("etc/other/apt.conf.d/10proxy", `Acquire::http::Proxy "http://other:3142";`)
+	config, err = apt.LoadSystemConfig(root)
+	isok(t, err)
+	assert(t, config.Find("Acquire::http::Proxy", "") == "http://other:3142")
+	assert(t, config.FindB("APT::Get::Assume-Yes", false))
+	os.Unsetenv("APT_CONFIG")
+
+	write("loop.conf", `#include "loop.conf";`)
+	notok(t, apt.NewConfig().LoadFile(filepath.Join(root, "loop.conf")))
+
+	empty, err := apt.LoadSystemConfig(filepath.Join(root, "nonexistent"))
+	isok(t, err)
+	assert(t, !empty.Exists("APT"))
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,169 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package apt // import "github.com/ebikt/go-debian/apt"
+
+import (
+	"sort"
+
+	"github.com/ebikt/go-debian/control"
+	"github.com/ebikt/go-debian/dependency"
+	"github.com/ebikt/go-debian/version"
+)
+
+// Cross building {{{
+
+// CrossSatisfier checks the Build-Depends of a source package for a cross
+// build, on the build architecture for the host architecture, against a
+// multi-arch set of packages, following the rules apt applies to
+// "apt-get build-dep -a":
+//
+//   foo          foo of the host architecture, or of the build one when
+//                it's Multi-Arch: foreign or Architecture: all
+//   foo:any      a Multi-Arch: allowed foo of the build architecture
+//   foo:native   foo of the build architecture
+//   foo:arm64    foo of that architecture
+//
+// Architecture restrictions ("foo [amd64]") are checked against the host
+// architecture. The "cross" build profile is not implied: sbuild and other
+// tools enabling it for cross builds have it in the Profiles.
+type CrossSatisfier struct {
+	Packages []Package
+
+	// Architecture of the build machine, such as "amd64".
+	BuildArchitecture string
+
+	// Architecture the packages are built for, such as "arm64".
+	HostArchitecture string
+
+	// Build profiles enabled, such as "cross" or "nocheck".
+	Profiles []string
+}
+
+// CrossSatisfaction is the outcome of CrossSatisfier.Check.
+type CrossSatisfaction struct {
+	Satisfaction
+
+	// The packages to install, with their architecture, for the satisfied
+	// relations: for each, the highest version satisfying its first
+	// satisfiable possibility, as apt would pick.
+	Install []Package
+}
+
+// crossArchSatisfies checks the architecture part of a possibility of a
+// Build-Depends for a cross build, see dependency.Possibility.SatisfiedByCross.
+func (s CrossSatisfier) crossArchSatisfies(possi dependency.Possibility, pkg Package, arches dependency.CrossArchitectures) bool {
+	arch, err := dependency.ParseArch(pkg.Architecture)
+	if err != nil {
+		return false
+	}
+	possi.Version = nil
+	ok, _ := possi.SatisfiedByCross(possi.Name, version.Version{}, *arch, pkg.MultiArch, arches)
+	return ok
+}
+
+// Check checks each relation of the Dependency, once reduced to the host
+// architecture and the Profiles.
+func (s CrossSatisfier) Check(dep dependency.Dependency) (*CrossSatisfaction, error) {
+	build, err := dependency.ParseArch(s.BuildArchitecture)
+	if err != nil {
+		return nil, err
+	}
+	host, err := dependency.ParseArch(s.HostArchitecture)
+	if err != nil {
+		return nil, err
+	}
+	arches := dependency.CrossArchitectures{Build: *build, Host: *host, Target: *host}
+	resolver := Resolver{Available: s.Packages}
+	from := Package{Architecture: s.HostArchitecture}
+
+	ret := CrossSatisfaction{
+		Satisfaction: Satisfaction{Relations: []RelationStatus{}},
+		Install:      []Package{},
+	}
+	install := map[string]Package{}
+	for _, relation := range dep.Reduce(*host, s.Profiles).Relations {
+		status := RelationStatus{
+			Relation:     relation,
+			SatisfiedBy:  []Package{},
+			Alternatives: []Alternative{},
+		}
+		var picked *Package
+		possibilities := []dependency.Possibility{}
+		for _, possi := range relation.Possibilities {
+			if possi.Arch != nil {
+				/* "foo:native" is the build architecture */
+				arch := possi.Arch.ResolveNative(*build)
+				possi.Arch = &arch
+			}
+			possibilities = append(possibilities, possi)
+
+			byName := possi
+			byName.Arch = nil
+			var best *Package
+			for i, pkg := range s.Packages {
+				if !satisfiedBy(Package{}, byName, pkg) || !s.crossArchSatisfies(possi, pkg, arches) {
+					continue
+				}
+				status.SatisfiedBy = append(status.SatisfiedBy, pkg)
+				if best == nil || version.Compare(pkg.Version, best.Version) > 0 {
+					best = &s.Packages[i]
+				}
+			}
+			if picked == nil {
+				picked = best
+			}
+		}
+		if picked != nil {
+			install[picked.Key()] = *picked
+		}
+		if len(status.SatisfiedBy) == 0 {
+			for _, possi := range possibilities {
+				status.Alternatives = append(status.Alternatives, resolver.explain(from, possi))
+			}
+		}
+		ret.Relations = append(ret.Relations, status)
+	}
+
+	for _, pkg := range install {
+		ret.Install = append(ret.Install, pkg)
+	}
+	sort.Slice(ret.Install, func(i, j int) bool {
+		return ret.Install[i].Key() < ret.Install[j].Key()
+	})
+	return &ret, nil
+}
+
+// CheckBuildDepends checks the Build-Depends and Build-Depends-Arch of the
+// source package for the cross build, and its Build-Depends-Indep too when
+// `indep` is set, see Satisfier.CheckBuildDepends.
+func (s CrossSatisfier) CheckBuildDepends(dsc control.DSC, indep bool) (*CrossSatisfaction, error) {
+	dep := dependency.Dependency{Relations: []dependency.Relation{}}
+	dep.Relations = append(dep.Relations, dsc.BuildDepends.Relations...)
+	dep.Relations = append(dep.Relations, dsc.BuildDependsArch.Relations...)
+	if indep {
+		dep.Relations = append(dep.Relations, dsc.BuildDependsIndep.Relations...)
+	}
+	return s.Check(dep)
+}
+
+// }}}
+
+// vim: foldmethod=marker
@@ -0,0 +1,104 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package apt_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ebikt/go-debian/apt"
+	"github.com/ebikt/go-debian/dependency"
+)
+
+/*
+ *
+ */
+
+func crossSatisfier(t *testing.T) apt.CrossSatisfier {
+	packages := []apt.Package{archPackage(t, "debhelper", "13.11", "all")}
+	for _, arch := range []string{"amd64", "arm64"} {
+		gcc := archPackage(t, "gcc", "12.2.0-14", arch)
+		gcc.MultiArch = "foreign"
+		libfoo := archPackage(t, "libfoo-dev", "1.5-1", arch)
+		libfoo.MultiArch = "same"
+		python := archPackage(t, "python3", "3.11.2-1", arch)
+		python.MultiArch = "allowed"
+		packages = append(packages, gcc, libfoo, python, archPackage(t, "pkg-config", "1.8.1-1", arch))
+	}
+	packages = append(packages,
+		archPackage(t, "libarm", "1.0", "arm64"),
+		archPackage(t, "libfoo-dev", "1.4-1", "arm64"),
+		archPackage(t, "libbar-dev", "2.0", "amd64"),
+	)
+	return apt.CrossSatisfier{
+		Packages:          packages,
+		BuildArchitecture: "amd64",
+		HostArchitecture:  "arm64",
+	}
+}
+
+func crossCheck(t *testing.T, s apt.CrossSatisfier, relations string) *apt.CrossSatisfaction {
+	dep, err := dependency.Parse(relations)
+	isok(t, err)
+	ret, err := s.Check(*dep)
+	isok(t, err)
+	return ret
+}
+
+func TestCrossSatisfier(t *testing.T) {
+	s := crossSatisfier(t)
+	relations := "debhelper, gcc, libfoo-dev (>= 1.0), python3:any, " +
+		"pkg-config:native, libamd [amd64], libarm [arm64], libbaz <cross>"
+	ret := crossCheck(t, s, relations)
+	assert(t, ret.Satisfied())
+	assert(t, len(ret.Relations) == 6)
+
+	keys := []string{}
+	for _, pkg := range ret.Install {
+		keys = append(keys, pkg.Key()+"="+pkg.Version.String())
+	}
+	assert(t, strings.Join(keys, " ") == "debhelper:all=13.11 gcc:amd64=12.2.0-14 "+
+		"libarm:arm64=1.0 libfoo-dev:arm64=1.5-1 pkg-config:amd64=1.8.1-1 python3:amd64=3.11.2-1")
+
+	/* With the cross profile, the restricted relation applies */
+	s.Profiles = []string{"cross"}
+	ret = crossCheck(t, s, relations)
+	assert(t, !ret.Satisfied())
+	assert(t, ret.Unsatisfied()[0].Relation.Possibilities[0].Name == "libbaz")
+}
+
+func TestCrossSatisfierUnsatisfied(t *testing.T) {
+	s := crossSatisfier(t)
+	ret := crossCheck(t, s, "libbar-dev, libarm:native, libbar-dev:amd64")
+	unsatisfied := ret.Unsatisfied()
+	assert(t, len(unsatisfied) == 2)
+	assert(t, unsatisfied[0].Alternatives[0].Reason == apt.ReasonArchitecture)
+	assert(t, unsatisfied[1].Alternatives[0].Reason == apt.ReasonArchitecture)
+	assert(t, len(ret.Install) == 1 && ret.Install[0].Key() == "libbar-dev:amd64")
+
+	s.HostArchitecture = ""
+	dep, err := dependency.Parse("gcc")
+	isok(t, err)
+	_, err = s.Check(*dep)
+	notok(t, err)
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,7 @@
+/*
+
+This module provides an API to read the configuration of APT on a host,
+so programs can honor it the way apt(8) would.
+
+*/
+package apt // import "github.com/ebikt/go-debian/apt"
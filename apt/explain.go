@@ -0,0 +1,194 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package apt // import "github.com/ebikt/go-debian/apt"
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ebikt/go-debian/dependency"
+)
+
+// Explanation {{{
+
+// Reason tells why an alternative of a relation can't be used.
+type Reason int
+
+const (
+	// No package of that name, nor providing it, is available.
+	ReasonMissing Reason = iota
+	// None of the available packages is of an architecture that
+	// satisfies the relation.
+	ReasonArchitecture
+	// None of the available versions satisfies the relation.
+	ReasonVersion
+	// The versions that would satisfy the relation are pinned below 0.
+	ReasonPinned
+	// The candidate can't be installed itself, see Alternative.Cause.
+	ReasonUninstallable
+	// Installing the candidate hits a Breaks or Conflicts.
+	ReasonConflict
+	// Installing the candidate breaks a relation that is satisfied now.
+	ReasonBroken
+)
+
+// An Alternative of a relation that couldn't be satisfied, and why.
+type Alternative struct {
+	Possibility dependency.Possibility
+	Reason      Reason
+
+	// The package that was considered, for ReasonPinned,
+	// ReasonUninstallable, ReasonConflict and ReasonBroken.
+	Candidate *Package
+
+	// The available versions, for ReasonArchitecture and ReasonVersion.
+	Available []Package
+
+	// The Pin responsible for ReasonPinned.
+	Pin *Pin
+
+	// Why the Candidate can't be installed, for ReasonUninstallable.
+	Cause *Explanation
+}
+
+// Explanation is the error a Resolver returns when it can't satisfy a
+// relation: which package needs what, and why each alternative is not
+// possible, down to the root cause. It renders as text like:
+//
+//   hello 2.10-3 Depends: libhello1 | libhello-alt1
+//     libhello1 1.0-1 can't be installed:
+//       libhello1 1.0-1 Depends: libfoo (>= 2)
+//         only libfoo 1.5-1 is available
+//     libhello-alt1 is not available
+//
+// A nil Package means the relation is the request itself.
+type Explanation struct {
+	Package      *Package
+	Field        string
+	Relation     dependency.Relation
+	Alternatives []Alternative
+}
+
+func (e *Explanation) Error() string {
+	return e.String()
+}
+
+// String renders the Explanation as indented text.
+func (e *Explanation) String() string {
+	out := []string{}
+	e.render(&out, "")
+	return strings.Join(out, "\n")
+}
+
+func (e *Explanation) render(out *[]string, indent string) {
+	head := e.Field + ": " + e.Relation.String()
+	if e.Package != nil {
+		head = e.Package.Name + " " + e.Package.Version.String() + " " + head
+	}
+	*out = append(*out, indent+head)
+	indent += "  "
+	for _, alt := range e.Alternatives {
+		name := alt.Possibility.Name
+		candidate := ""
+		if alt.Candidate != nil {
+			candidate = alt.Candidate.Name + " " + alt.Candidate.Version.String()
+		}
+
+		switch alt.Reason {
+		case ReasonMissing:
+			*out = append(*out, indent+name+" is not available")
+		case ReasonArchitecture:
+			archs := []string{}
+			for _, pkg := range alt.Available {
+				archs = append(archs, pkg.Key())
+			}
+			*out = append(*out, fmt.Sprintf(
+				"%s%s is not available for the needed architecture, only %s",
+				indent, name, strings.Join(archs, ", "),
+			))
+		case ReasonVersion:
+			versions := []string{}
+			for _, pkg := range alt.Available {
+				versions = append(versions, pkg.Name+" "+pkg.Version.String())
+			}
+			*out = append(*out, fmt.Sprintf(
+				"%sonly %s %s available", indent, strings.Join(versions, ", "),
+				map[bool]string{true: "is", false: "are"}[len(versions) == 1],
+			))
+		case ReasonPinned:
+			*out = append(*out, fmt.Sprintf("%s%s is pinned out by %s", indent, candidate, alt.Pin))
+		case ReasonUninstallable:
+			*out = append(*out, indent+candidate+" can't be installed:")
+			alt.Cause.render(out, indent+"  ")
+		case ReasonConflict:
+			*out = append(*out, indent+candidate+" would be installed along with it")
+		case ReasonBroken:
+			*out = append(*out, indent+"installing "+candidate+" would break it")
+		}
+	}
+}
+
+// explain finds out why none of the available packages can be used for
+// the Possibility of a relation of `from`.
+func (r Resolver) explain(from Package, possi dependency.Possibility) Alternative {
+	selector := UpgradeSelector{Pins: r.Pins}
+	alt := Alternative{Possibility: possi, Reason: ReasonMissing, Available: []Package{}}
+
+	named := []Package{}
+	for _, pkg := range r.Available {
+		byName := dependency.Possibility{Name: possi.Name}
+		if satisfiedBy(Package{}, byName, pkg) {
+			named = append(named, pkg)
+		}
+	}
+	if len(named) == 0 {
+		return alt
+	}
+
+	archOK := []Package{}
+	for _, pkg := range named {
+		if archSatisfies(from, possi, pkg) {
+			archOK = append(archOK, pkg)
+		}
+	}
+	if len(archOK) == 0 {
+		alt.Reason, alt.Available = ReasonArchitecture, named
+		return alt
+	}
+
+	for i, pkg := range archOK {
+		if !satisfiedBy(from, possi, pkg) {
+			continue
+		}
+		for j, pin := range r.Pins {
+			if pin.Matches(pkg) && selector.Priority(pkg) < 0 {
+				alt.Reason, alt.Candidate, alt.Pin = ReasonPinned, &archOK[i], &r.Pins[j]
+				return alt
+			}
+		}
+	}
+	alt.Reason, alt.Available = ReasonVersion, archOK
+	return alt
+}
+
+// }}}
+
+// vim: foldmethod=marker
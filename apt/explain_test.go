@@ -0,0 +1,118 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package apt_test
+
+import (
+	"testing"
+
+	"github.com/ebikt/go-debian/apt"
+)
+
+/*
+ *
+ */
+
+func TestExplainMissingAndVersion(t *testing.T) {
+	hello := mkPackage(t, "hello", "2.10-3", "libhello1 | libhello-alt1", debianStable)
+	resolver := apt.Resolver{Available: []apt.Package{
+		hello,
+		mkPackage(t, "libhello1", "1.0-1", "libfoo (>= 2)", debianStable),
+		mkPackage(t, "libfoo", "1.5-1", "", debianStable),
+	}}
+
+	_, err := resolver.Install("hello")
+	notok(t, err)
+	explanation, ok := err.(*apt.Explanation)
+	assert(t, ok)
+	assert(t, explanation.Package == nil)
+	assert(t, len(explanation.Alternatives) == 1)
+	assert(t, explanation.Alternatives[0].Reason == apt.ReasonUninstallable)
+
+	cause := explanation.Alternatives[0].Cause
+	assert(t, cause.Package.Name == "hello")
+	assert(t, len(cause.Alternatives) == 2)
+	assert(t, cause.Alternatives[0].Reason == apt.ReasonUninstallable)
+	assert(t, cause.Alternatives[0].Cause.Alternatives[0].Reason == apt.ReasonVersion)
+	assert(t, cause.Alternatives[1].Reason == apt.ReasonMissing)
+
+	assert(t, err.Error() == `Install: hello
+  hello 2.10-3 can't be installed:
+    hello 2.10-3 Depends: libhello1 | libhello-alt1
+      libhello1 1.0-1 can't be installed:
+        libhello1 1.0-1 Depends: libfoo (>= 2)
+          only libfoo 1.5-1 is available
+      libhello-alt1 is not available`)
+}
+
+func TestExplainPinned(t *testing.T) {
+	resolver := apt.Resolver{
+		Available: []apt.Package{
+			mkPackage(t, "hello", "2.10-3", "libc6 (>= 2.36)", debianStable),
+			mkPackage(t, "libc6", "2.36-9", "", debianStable),
+		},
+		Pins: []apt.Pin{{Package: "libc6", Origin: apt.OriginPattern{Archive: "stable"}, Priority: -1}},
+	}
+
+	_, err := resolver.Install("hello")
+	notok(t, err)
+	cause := err.(*apt.Explanation).Alternatives[0].Cause
+	alt := cause.Alternatives[0]
+	assert(t, alt.Reason == apt.ReasonPinned)
+	assert(t, alt.Pin.Priority == -1)
+	assert(t, alt.Candidate.Version.String() == "2.36-9")
+	assert(t, err.Error() == `Install: hello
+  hello 2.10-3 can't be installed:
+    hello 2.10-3 Depends: libc6 (>= 2.36)
+      libc6 2.36-9 is pinned out by Package: libc6, Pin: release a=stable, Pin-Priority: -1`)
+}
+
+func TestExplainConflict(t *testing.T) {
+	hello := mkPackage(t, "hello", "2.10-3", "", debianStable)
+	hello.Conflicts = parseDep(t, "hello-traditional")
+	resolver := apt.Resolver{
+		Installed: []apt.Package{mkPackage(t, "hello-traditional", "2.10-5", "")},
+		Available: []apt.Package{hello},
+	}
+
+	_, err := resolver.Install("hello")
+	notok(t, err)
+	explanation := err.(*apt.Explanation)
+	assert(t, explanation.Field == "Conflicts")
+	assert(t, explanation.Alternatives[0].Reason == apt.ReasonConflict)
+	assert(t, err.Error() == `hello 2.10-3 Conflicts: hello-traditional
+  hello-traditional 2.10-5 would be installed along with it`)
+}
+
+func TestExplainArchitecture(t *testing.T) {
+	tool := mkPackage(t, "tool", "1", "libfoo1:i386")
+	tool.Architecture = "amd64"
+	libfoo := mkPackage(t, "libfoo1", "1", "")
+	libfoo.Architecture = "amd64"
+	resolver := apt.Resolver{Available: []apt.Package{tool, libfoo}}
+
+	_, err := resolver.Install("tool")
+	notok(t, err)
+	alt := err.(*apt.Explanation).Alternatives[0].Cause.Alternatives[0]
+	assert(t, alt.Reason == apt.ReasonArchitecture)
+	assert(t, len(alt.Available) == 1)
+}
+
+// vim: foldmethod=marker
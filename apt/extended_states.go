@@ -0,0 +1,146 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package apt // import "github.com/ebikt/go-debian/apt"
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/ebikt/go-debian/control"
+	"github.com/ebikt/go-debian/internal"
+)
+
+// ExtendedStates {{{
+
+// ExtendedStates is apt's record of which packages were installed
+// automatically, to satisfy dependencies, as stored in
+// /var/lib/apt/extended_states:
+//
+//   Package: libfoo1
+//   Architecture: amd64
+//   Auto-Installed: 1
+type ExtendedStates struct {
+	Paragraphs []control.Paragraph
+}
+
+// ParseExtendedStates reads the extended_states format from the reader.
+func ParseExtendedStates(in io.Reader) (*ExtendedStates, error) {
+	reader, err := control.NewParagraphReader(in, nil)
+	if err != nil {
+		return nil, err
+	}
+	paragraphs, err := reader.All()
+	if err != nil {
+		return nil, err
+	}
+	return &ExtendedStates{Paragraphs: paragraphs}, nil
+}
+
+// LoadExtendedStates reads the extended_states file at the given path. A
+// missing file is the same as an empty one, as it is for apt.
+func LoadExtendedStates(path string) (*ExtendedStates, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return &ExtendedStates{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseExtendedStates(f)
+}
+
+func (e *ExtendedStates) find(name, arch string) int {
+	for i, paragraph := range e.Paragraphs {
+		if paragraph.Get("Package") == name && paragraph.Get("Architecture") == arch {
+			return i
+		}
+	}
+	return -1
+}
+
+// IsAuto checks if the package of the given architecture was installed
+// automatically.
+func (e *ExtendedStates) IsAuto(name, arch string) bool {
+	i := e.find(name, arch)
+	return i != -1 && strings.TrimSpace(e.Paragraphs[i].Get("Auto-Installed")) == "1"
+}
+
+// Auto returns all of the automatically installed packages, as
+// "package:architecture", sorted.
+func (e *ExtendedStates) Auto() []string {
+	ret := []string{}
+	for _, paragraph := range e.Paragraphs {
+		if strings.TrimSpace(paragraph.Get("Auto-Installed")) == "1" {
+			ret = append(ret, paragraph.Get("Package")+":"+paragraph.Get("Architecture"))
+		}
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// SetAuto marks the package of the given architecture as automatically or
+// manually installed. Like apt, manually installed packages are dropped
+// from the file, unless other fields are recorded for them.
+func (e *ExtendedStates) SetAuto(name, arch string, auto bool) {
+	i := e.find(name, arch)
+	switch {
+	case auto && i == -1:
+		paragraph := control.NewParagraph()
+		paragraph.Set("Package", name)
+		paragraph.Set("Architecture", arch)
+		paragraph.Set("Auto-Installed", "1")
+		e.Paragraphs = append(e.Paragraphs, paragraph)
+	case auto:
+		e.Paragraphs[i].Set("Auto-Installed", "1")
+	case i == -1:
+	case len(e.Paragraphs[i].Order) <= 3:
+		e.Paragraphs = append(e.Paragraphs[:i], e.Paragraphs[i+1:]...)
+	default:
+		e.Paragraphs[i].Set("Auto-Installed", "0")
+	}
+}
+
+// WriteTo writes the extended_states format out.
+func (e *ExtendedStates) WriteTo(out io.Writer) (int64, error) {
+	buf := bytes.Buffer{}
+	for _, paragraph := range e.Paragraphs {
+		if err := paragraph.WriteTo(&buf); err != nil {
+			return 0, err
+		}
+		buf.WriteString("\n")
+	}
+	return buf.WriteTo(out)
+}
+
+// Save atomically replaces the extended_states file at the given path.
+func (e *ExtendedStates) Save(path string) error {
+	return internal.WriteFileAtomic(path, 0644, func(out io.Writer) error {
+		_, err := e.WriteTo(out)
+		return err
+	})
+}
+
+// }}}
+
+// vim: foldmethod=marker
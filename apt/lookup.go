@@ -0,0 +1,231 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package apt // import "github.com/ebikt/go-debian/apt"
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ebikt/go-debian/dependency"
+	"github.com/ebikt/go-debian/version"
+)
+
+// Package names {{{
+
+// SplitArchQualifier splits an architecture qualified package name such
+// as "libc6:i386" into the package name and the architecture, which is
+// empty for plain names.
+func SplitArchQualifier(name string) (string, string) {
+	if i := strings.LastIndex(name, ":"); i != -1 {
+		return name[:i], name[i+1:]
+	}
+	return name, ""
+}
+
+// }}}
+
+// Universe {{{
+
+// A Universe is a set of packages, such as the available or the installed
+// ones, that can be looked up by name the way apt and dpkg address them:
+// "name:arch" for the packages of an architecture, "name:native" for the
+// ones of the native architecture, "name:any" for all of them, and a plain
+// name for the native or Architecture: all ones, falling back to the
+// foreign ones when there's a single foreign architecture to pick.
+// Virtual packages are the names packages have in their Provides, see
+// Providers.
+type Universe struct {
+	// The architecture of the host (APT::Architecture).
+	NativeArch string
+
+	packages   []Package
+	byName     map[string][]int
+	byProvides map[string][]int
+}
+
+// NewUniverse creates a Universe of the packages, on a host of the given
+// native architecture.
+func NewUniverse(nativeArch string, packages []Package) *Universe {
+	ret := Universe{
+		NativeArch: nativeArch,
+		packages:   packages,
+		byName:     map[string][]int{},
+		byProvides: map[string][]int{},
+	}
+	for i, pkg := range packages {
+		ret.byName[pkg.Name] = append(ret.byName[pkg.Name], i)
+		for _, possi := range pkg.Provides.GetAllPossibilities() {
+			ret.byProvides[possi.Name] = append(ret.byProvides[possi.Name], i)
+		}
+	}
+	return &ret
+}
+
+// Architectures returns the architectures the named package is in the
+// Universe for, sorted.
+func (u Universe) Architectures(name string) []string {
+	seen := map[string]bool{}
+	ret := []string{}
+	for _, i := range u.byName[name] {
+		if arch := u.packages[i].Architecture; !seen[arch] {
+			seen[arch] = true
+			ret = append(ret, arch)
+		}
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// Providers returns the packages providing the virtual package of the
+// possibly versioned relation, such as "mail-transport-agent" or
+// "foo (>= 1.2)", sorted by name, newest versions first. Versioned
+// relations are only satisfied by Provides of an "=" version satisfying
+// them, such as "Provides: foo (= 1.2)".
+func (u Universe) Providers(relation string) ([]Package, error) {
+	dep, err := dependency.Parse(relation)
+	if err != nil {
+		return nil, err
+	}
+	if len(dep.Relations) != 1 || len(dep.Relations[0].Possibilities) != 1 {
+		return nil, fmt.Errorf("Not a single package relation: '%s'", relation)
+	}
+	possi := dep.Relations[0].Possibilities[0]
+
+	ret := []Package{}
+	for _, i := range u.byProvides[possi.Name] {
+		pkg := u.packages[i]
+		for _, provided := range pkg.Provides.GetAllPossibilities() {
+			if provided.Name == possi.Name && providedSatisfies(possi, provided.Version) {
+				ret = append(ret, pkg)
+				break
+			}
+		}
+	}
+	sort.SliceStable(ret, func(i, j int) bool {
+		if ret[i].Name != ret[j].Name {
+			return ret[i].Name < ret[j].Name
+		}
+		return version.Compare(ret[i].Version, ret[j].Version) > 0
+	})
+	return ret, nil
+}
+
+// IsVirtual tells whether the name is only the one of a virtual package:
+// no package has that name, but some provide it.
+func (u Universe) IsVirtual(name string) bool {
+	return len(u.byName[name]) == 0 && len(u.byProvides[name]) > 0
+}
+
+// Lookup returns all the versions of the package addressed by the possibly
+// architecture qualified name, newest first. Plain names matching packages
+// of several foreign architectures, and none of the native one, are
+// ambiguous, and result in an error, as do names matching no package,
+// such as the ones of virtual packages.
+func (u Universe) Lookup(name string) ([]Package, error) {
+	pkgName, arch := SplitArchQualifier(name)
+	if arch == "native" {
+		arch = u.NativeArch
+	}
+
+	matching := func(match func(Package) bool) []Package {
+		ret := []Package{}
+		for _, i := range u.byName[pkgName] {
+			if match(u.packages[i]) {
+				ret = append(ret, u.packages[i])
+			}
+		}
+		sort.SliceStable(ret, func(i, j int) bool {
+			return version.Compare(ret[i].Version, ret[j].Version) > 0
+		})
+		return ret
+	}
+
+	var ret []Package
+	switch arch {
+	case "":
+		ret = matching(func(pkg Package) bool {
+			return pkg.Architecture == u.NativeArch || pkg.Architecture == "all" || pkg.Architecture == ""
+		})
+		if len(ret) > 0 {
+			break
+		}
+		arches := u.Architectures(pkgName)
+		if len(arches) > 1 {
+			qualified := []string{}
+			for _, arch := range arches {
+				qualified = append(qualified, pkgName+":"+arch)
+			}
+			return nil, fmt.Errorf("Ambiguous package name '%s', candidates are: %s",
+				name, strings.Join(qualified, ", "))
+		}
+		ret = matching(func(Package) bool { return true })
+	case "any":
+		ret = matching(func(Package) bool { return true })
+	default:
+		ret = matching(func(pkg Package) bool { return pkg.Architecture == arch })
+	}
+
+	if len(ret) == 0 && u.IsVirtual(pkgName) {
+		seen, providers := map[string]bool{}, []string{}
+		for _, i := range u.byProvides[pkgName] {
+			if key := u.packages[i].Key(); !seen[key] {
+				seen[key] = true
+				providers = append(providers, key)
+			}
+		}
+		sort.Strings(providers)
+		return nil, fmt.Errorf("Package '%s' is a virtual package provided by: %s",
+			name, strings.Join(providers, ", "))
+	}
+	if len(ret) == 0 {
+		return nil, fmt.Errorf("Unable to locate package '%s'", name)
+	}
+	return ret, nil
+}
+
+// possibility turns a package name, as given on the command line, into
+// the Possibility of the package it addresses, qualified with its
+// architecture. Names of no package are left for the Resolver to explain,
+// only ambiguous ones are an error.
+func (u Universe) possibility(name string) (dependency.Possibility, error) {
+	pkgName, arch := SplitArchQualifier(name)
+	pkgs, err := u.Lookup(name)
+	if err != nil {
+		if arch == "" && len(u.Architectures(pkgName)) > 1 {
+			return dependency.Possibility{}, err
+		}
+		pkgs = []Package{{Name: pkgName, Architecture: arch}}
+	}
+	ret := dependency.Possibility{Name: pkgName}
+	if arch := pkgs[0].Architecture; arch != "" && arch != "all" && arch != "any" {
+		parsed, err := dependency.ParseArch(arch)
+		if err != nil {
+			return ret, err
+		}
+		ret.Arch = parsed
+	}
+	return ret, nil
+}
+
+// }}}
+
+// vim: foldmethod=marker
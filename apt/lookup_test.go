@@ -0,0 +1,111 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package apt_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ebikt/go-debian/apt"
+)
+
+/*
+ *
+ */
+
+func archPackage(t *testing.T, name, ver, arch string) apt.Package {
+	pkg := mkPackage(t, name, ver, "", debianStable)
+	pkg.Architecture = arch
+	return pkg
+}
+
+func lookupKeys(t *testing.T, universe *apt.Universe, name string) string {
+	pkgs, err := universe.Lookup(name)
+	isok(t, err)
+	keys := []string{}
+	for _, pkg := range pkgs {
+		keys = append(keys, pkg.Key()+"="+pkg.Version.String())
+	}
+	return strings.Join(keys, " ")
+}
+
+func TestUniverseLookup(t *testing.T) {
+	name, arch := apt.SplitArchQualifier("libc6:i386")
+	assert(t, name == "libc6" && arch == "i386")
+	name, arch = apt.SplitArchQualifier("libc6")
+	assert(t, name == "libc6" && arch == "")
+
+	universe := apt.NewUniverse("amd64", []apt.Package{
+		archPackage(t, "libc6", "2.36-9", "amd64"),
+		archPackage(t, "libc6", "2.36-9+deb12u1", "amd64"),
+		archPackage(t, "libc6", "2.36-9", "i386"),
+		archPackage(t, "tzdata", "2024a-0", "all"),
+		archPackage(t, "wine32", "8.0-1", "i386"),
+		archPackage(t, "libfoo1", "1.0-1", "i386"),
+		archPackage(t, "libfoo1", "1.0-1", "armhf"),
+	})
+
+	assert(t, lookupKeys(t, universe, "libc6") == "libc6:amd64=2.36-9+deb12u1 libc6:amd64=2.36-9")
+	assert(t, lookupKeys(t, universe, "libc6:i386") == "libc6:i386=2.36-9")
+	assert(t, lookupKeys(t, universe, "libc6:native") == "libc6:amd64=2.36-9+deb12u1 libc6:amd64=2.36-9")
+	assert(t, lookupKeys(t, universe, "libc6:any") == "libc6:amd64=2.36-9+deb12u1 libc6:amd64=2.36-9 libc6:i386=2.36-9")
+	assert(t, lookupKeys(t, universe, "tzdata") == "tzdata:all=2024a-0")
+	assert(t, lookupKeys(t, universe, "wine32") == "wine32:i386=8.0-1")
+	assert(t, strings.Join(universe.Architectures("libfoo1"), " ") == "armhf i386")
+
+	_, err := universe.Lookup("libfoo1")
+	notok(t, err)
+	assert(t, strings.Contains(err.Error(), "libfoo1:armhf, libfoo1:i386"))
+	_, err = universe.Lookup("libc6:arm64")
+	notok(t, err)
+	_, err = universe.Lookup("missing")
+	notok(t, err)
+}
+
+func TestResolverArchQualified(t *testing.T) {
+	config := parseConfig(t, `APT::Architecture "amd64";`)
+	resolver := apt.NewResolver(config, nil, []apt.Package{
+		archPackage(t, "libc6", "2.36-9+deb12u1", "amd64"),
+		archPackage(t, "libc6", "2.36-9", "i386"),
+		archPackage(t, "libfoo1", "1.0-1", "i386"),
+		archPackage(t, "libfoo1", "1.0-1", "armhf"),
+	})
+	assert(t, resolver.Architecture == "amd64")
+
+	solution, err := resolver.Install("libc6:i386")
+	isok(t, err)
+	assert(t, len(solution.Install) == 1 && solution.Install[0].Key() == "libc6:i386")
+
+	solution, err = resolver.Install("libc6")
+	isok(t, err)
+	assert(t, len(solution.Install) == 1 && solution.Install[0].Key() == "libc6:amd64")
+
+	_, err = resolver.Install("libfoo1")
+	notok(t, err)
+	_, isExplanation := err.(*apt.Explanation)
+	assert(t, !isExplanation)
+
+	_, err = resolver.Install("libc6:arm64")
+	_, isExplanation = err.(*apt.Explanation)
+	assert(t, isExplanation)
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,177 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package apt // import "github.com/ebikt/go-debian/apt"
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ebikt/go-debian/dpkg"
+)
+
+// Marker {{{
+
+// Marker reads and changes the auto-installed markers and the hold state
+// of the packages installed on a system, like apt-mark(8).
+type Marker struct {
+	// Root of the system, "/" for the running one.
+	Root string
+}
+
+func (m Marker) admindir() string {
+	return filepath.Join(m.Root, "var/lib/dpkg")
+}
+
+func (m Marker) extendedStates() string {
+	return filepath.Join(m.Root, "var/lib/apt/extended_states")
+}
+
+// resolve maps "package" or "package:architecture" to the installed
+// packages, as "package:architecture".
+func (m Marker) resolve(installed []string, names []string) ([]string, error) {
+	ret := []string{}
+	for _, name := range names {
+		found := false
+		for _, pkg := range installed {
+			if pkg == name || strings.SplitN(pkg, ":", 2)[0] == name {
+				ret = append(ret, pkg)
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("Package %s is not installed", name)
+		}
+	}
+	return ret, nil
+}
+
+func (m Marker) showAuto(auto bool) ([]string, error) {
+	installed, err := dpkg.Installed(m.admindir())
+	if err != nil {
+		return nil, err
+	}
+	states, err := LoadExtendedStates(m.extendedStates())
+	if err != nil {
+		return nil, err
+	}
+	ret := []string{}
+	for _, pkg := range installed {
+		parts := strings.SplitN(pkg, ":", 2)
+		if states.IsAuto(parts[0], parts[1]) == auto {
+			ret = append(ret, pkg)
+		}
+	}
+	sort.Strings(ret)
+	return ret, nil
+}
+
+// ShowAuto returns the installed packages that were installed
+// automatically, as "package:architecture".
+func (m Marker) ShowAuto() ([]string, error) {
+	return m.showAuto(true)
+}
+
+// ShowManual returns the installed packages that were installed manually,
+// as "package:architecture".
+func (m Marker) ShowManual() ([]string, error) {
+	return m.showAuto(false)
+}
+
+// MarkAuto marks the given installed packages as automatically installed
+// (if `auto` is set) or manually installed. Packages are given as
+// "package" (for all of its installed architectures) or as
+// "package:architecture".
+func (m Marker) MarkAuto(auto bool, names ...string) error {
+	lock, err := dpkg.LockAdmin(m.admindir())
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	installed, err := dpkg.Installed(m.admindir())
+	if err != nil {
+		return err
+	}
+	pkgs, err := m.resolve(installed, names)
+	if err != nil {
+		return err
+	}
+
+	states, err := LoadExtendedStates(m.extendedStates())
+	if err != nil {
+		return err
+	}
+	for _, pkg := range pkgs {
+		parts := strings.SplitN(pkg, ":", 2)
+		states.SetAuto(parts[0], parts[1], auto)
+	}
+	return states.Save(m.extendedStates())
+}
+
+// ShowHold returns the packages on hold, as "package:architecture".
+func (m Marker) ShowHold() ([]string, error) {
+	selections, err := dpkg.Selections(m.admindir())
+	if err != nil {
+		return nil, err
+	}
+	ret := []string{}
+	for pkg, want := range selections {
+		if want == dpkg.WantHold {
+			ret = append(ret, pkg)
+		}
+	}
+	sort.Strings(ret)
+	return ret, nil
+}
+
+// Hold puts the given packages on hold (if `hold` is set), or releases
+// them, through the dpkg selections.
+func (m Marker) Hold(hold bool, names ...string) error {
+	want := dpkg.WantInstall
+	if hold {
+		want = dpkg.WantHold
+	}
+	selections := map[string]string{}
+	for _, name := range names {
+		selections[name] = want
+	}
+	return dpkg.SetSelections(m.admindir(), selections)
+}
+
+// Autoremovable returns the installed packages that were installed
+// automatically and are no longer needed, see the Autoremovable function.
+func (m Marker) Autoremovable(opts AutoremoveOptions) ([]Package, error) {
+	installed, err := LoadInstalled(filepath.Join(m.admindir(), "status"))
+	if err != nil {
+		return nil, err
+	}
+	states, err := LoadExtendedStates(m.extendedStates())
+	if err != nil {
+		return nil, err
+	}
+	return Autoremovable(installed, states, opts)
+}
+
+// }}}
+
+// vim: foldmethod=marker
@@ -0,0 +1,98 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package apt_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ebikt/go-debian/apt"
+)
+
+/*
+ *
+ */
+
+func TestMarker(t *testing.T) {
+	root, err := ioutil.TempDir("", "go-debian-apt")
+	isok(t, err)
+	defer os.RemoveAll(root)
+
+	write := func(path, data string) {
+		path = filepath.Join(root, path)
+		isok(t, os.MkdirAll(filepath.Dir(path), 0755))
+		isok(t, ioutil.WriteFile(path, []byte(data), 0644))
+	}
+	write("var/lib/dpkg/status", `Package: hello
+Status: install ok installed
+Architecture: amd64
+
+Package: libfoo1
+Status: install ok installed
+Architecture: amd64
+
+Package: libbar1
+Status: install ok installed
+Architecture: amd64
+`)
+	write("var/lib/apt/extended_states", `Package: libfoo1
+Architecture: amd64
+Auto-Installed: 1
+
+Package: libbar1
+Architecture: amd64
+Auto-Installed: 1
+`)
+
+	marker := apt.Marker{Root: root}
+
+	auto, err := marker.ShowAuto()
+	isok(t, err)
+	assert(t, strings.Join(auto, " ") == "libbar1:amd64 libfoo1:amd64")
+
+	isok(t, marker.MarkAuto(false, "libfoo1"))
+	isok(t, marker.MarkAuto(true, "hello:amd64"))
+	notok(t, marker.MarkAuto(true, "missing"))
+
+	manual, err := marker.ShowManual()
+	isok(t, err)
+	assert(t, strings.Join(manual, " ") == "libfoo1:amd64")
+
+	states, err := apt.LoadExtendedStates(filepath.Join(root, "var/lib/apt/extended_states"))
+	isok(t, err)
+	assert(t, len(states.Paragraphs) == 2)
+	assert(t, states.IsAuto("hello", "amd64"))
+	assert(t, !states.IsAuto("hello", "i386"))
+
+	isok(t, marker.Hold(true, "hello"))
+	held, err := marker.ShowHold()
+	isok(t, err)
+	assert(t, strings.Join(held, " ") == "hello:amd64")
+	isok(t, marker.Hold(false, "hello"))
+	held, err = marker.ShowHold()
+	isok(t, err)
+	assert(t, len(held) == 0)
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,383 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package apt // import "github.com/ebikt/go-debian/apt"
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ebikt/go-debian/dependency"
+)
+
+// Ordering {{{
+
+// The actions of the Steps of a Plan, as dpkg carries them out.
+const (
+	ActionRemove    = "remove"
+	ActionUnpack    = "unpack"
+	ActionConfigure = "configure"
+)
+
+// A Step is an action of a Plan on a package.
+type Step struct {
+	Action  string
+	Package Package
+}
+
+func (s Step) String() string {
+	if s.Action == ActionRemove {
+		return s.Action + " " + s.Package.Key()
+	}
+	return fmt.Sprintf("%s %s %s", s.Action, s.Package.Key(), s.Package.Version)
+}
+
+// A Plan is the order dpkg has to be run in to carry out a Solution.
+type Plan struct {
+	Steps []Step
+}
+
+func (p Plan) String() string {
+	lines := []string{}
+	for _, step := range p.Steps {
+		lines = append(lines, step.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// An OrderCycle is the error of an Orderer when the constraints loop: a
+// Pre-Depends, Conflicts or Breaks cycle, which, unlike Depends cycles,
+// can't be broken.
+type OrderCycle struct {
+	// The steps of the cycle, each one having to come before the next,
+	// and the last one before the first, for the reason of the same index,
+	// such as "Pre-Depends".
+	Steps   []Step
+	Reasons []string
+}
+
+func (c *OrderCycle) Error() string {
+	parts := []string{}
+	for i, step := range c.Steps {
+		parts = append(parts, fmt.Sprintf("%s (%s)", step, c.Reasons[i]))
+	}
+	return "Ordering cycle: " + strings.Join(parts, ", before ") + ", before " + c.Steps[0].String()
+}
+
+// Orderer orders the installation, upgrade and removal of packages, the
+// way apt schedules its dpkg runs:
+//
+//   - a package is unpacked before it's configured;
+//   - the Pre-Depends of a package are configured before it's unpacked;
+//   - the Depends of a package are configured before it is, unless they
+//     depend on each other, which dpkg copes with;
+//   - the packages a package Conflicts with or Breaks are removed, or
+//     upgraded to versions it doesn't, before it's unpacked;
+//   - packages are removed before the ones they depend on.
+//
+// Essential packages are taken care of first, as they must stay usable.
+type Orderer struct {
+	// Packages installed, such as the ones of Status.Installed.
+	Installed []Package
+
+	// Packages to install or upgrade, and installed packages to remove.
+	Install []Package
+	Remove  []Package
+
+	// Let Essential packages be removed.
+	RemoveEssential bool
+}
+
+type orderEdge struct {
+	from, to int
+	reason   string
+	hard     bool
+}
+
+// ordering is the state of an Orderer run: the steps, and the edges
+// between them.
+type ordering struct {
+	steps []Step
+	edges []orderEdge
+
+	unpack    map[string]int
+	configure map[string]int
+	remove    map[string]int
+}
+
+func (o *ordering) add(from, to int, reason string, hard bool) {
+	o.edges = append(o.edges, orderEdge{from, to, reason, hard})
+}
+
+func (o *ordering) step(action string, pkg Package) int {
+	o.steps = append(o.steps, Step{Action: action, Package: pkg})
+	return len(o.steps) - 1
+}
+
+// Order returns the Plan installing, upgrading and removing the packages,
+// or the *OrderCycle making that impossible. Packages conflicting with
+// ones being installed have to be removed or upgraded along.
+func (o Orderer) Order() (*Plan, error) {
+	ord := ordering{unpack: map[string]int{}, configure: map[string]int{}, remove: map[string]int{}}
+
+	installed := map[string]Package{}
+	for _, pkg := range o.Installed {
+		installed[pkg.Key()] = pkg
+	}
+	remove := append([]Package{}, o.Remove...)
+	sort.Slice(remove, func(i, j int) bool { return remove[i].Key() < remove[j].Key() })
+	for _, pkg := range remove {
+		if pkg.Essential && !o.RemoveEssential {
+			return nil, fmt.Errorf("%s is Essential, and can't be removed", pkg.Key())
+		}
+		ord.remove[pkg.Key()] = ord.step(ActionRemove, pkg)
+	}
+	install := append([]Package{}, o.Install...)
+	sort.Slice(install, func(i, j int) bool { return install[i].Key() < install[j].Key() })
+	for _, pkg := range install {
+		if _, ok := ord.remove[pkg.Key()]; ok {
+			return nil, fmt.Errorf("%s is both installed and removed", pkg.Key())
+		}
+		ord.unpack[pkg.Key()] = ord.step(ActionUnpack, pkg)
+		ord.configure[pkg.Key()] = ord.step(ActionConfigure, pkg)
+		ord.add(ord.unpack[pkg.Key()], ord.configure[pkg.Key()], "unpack", true)
+	}
+
+	/* Installed packages the plan leaves alone */
+	untouched := []Package{}
+	for _, pkg := range o.Installed {
+		_, removed := ord.remove[pkg.Key()]
+		_, changed := ord.unpack[pkg.Key()]
+		if !removed && !changed {
+			untouched = append(untouched, pkg)
+		}
+	}
+
+	for _, pkg := range install {
+		ord.orderDepends(pkg, install, untouched)
+		if err := ord.orderConflicts(pkg, installed, install); err != nil {
+			return nil, err
+		}
+	}
+	for _, pkg := range remove {
+		for _, other := range remove {
+			for _, field := range []struct {
+				name string
+				dep  dependency.Dependency
+				hard bool
+			}{{"Pre-Depends", other.PreDepends, true}, {"Depends", other.Depends, false}} {
+				for _, possi := range field.dep.GetAllPossibilities() {
+					if other.Key() != pkg.Key() && satisfiedBy(other, possi, pkg) {
+						ord.add(ord.remove[other.Key()], ord.remove[pkg.Key()], field.name, field.hard)
+					}
+				}
+			}
+		}
+	}
+	return ord.sort()
+}
+
+// orderDepends adds the edges of the Pre-Depends and Depends of a package
+// being installed on other ones being installed, for the relations the
+// packages left alone don't satisfy already.
+func (o *ordering) orderDepends(pkg Package, install, untouched []Package) {
+	for _, field := range []struct {
+		name string
+		dep  dependency.Dependency
+		hard bool
+	}{{"Pre-Depends", pkg.PreDepends, true}, {"Depends", pkg.Depends, false}} {
+		for _, relation := range field.dep.Relations {
+			satisfied := false
+			for _, possi := range relation.Possibilities {
+				for _, other := range untouched {
+					satisfied = satisfied || satisfiedBy(pkg, possi, other)
+				}
+			}
+			if satisfied {
+				continue
+			}
+		alternatives:
+			for _, possi := range relation.Possibilities {
+				for _, other := range install {
+					if other.Key() != pkg.Key() && satisfiedBy(pkg, possi, other) {
+						to := o.configure[pkg.Key()]
+						if field.hard {
+							to = o.unpack[pkg.Key()]
+						}
+						o.add(o.configure[other.Key()], to, field.name, field.hard)
+						break alternatives
+					}
+				}
+			}
+		}
+	}
+}
+
+// orderConflicts adds the edges of the Conflicts and Breaks between a
+// package being installed and the installed packages, both ways: the
+// installed ones have to be removed or upgraded first.
+func (o *ordering) orderConflicts(pkg Package, installed map[string]Package, install []Package) error {
+	hits := func(from Package, field string, to Package) bool {
+		dep := from.Conflicts
+		if field == "Breaks" {
+			dep = from.Breaks
+		}
+		for _, possi := range dep.GetAllPossibilities() {
+			if from.Name != to.Name && satisfiedBy(from, possi, to) {
+				return true
+			}
+		}
+		return false
+	}
+	keys := []string{}
+	for key := range installed {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, field := range []string{"Conflicts", "Breaks"} {
+		for _, other := range install {
+			if hits(pkg, field, other) || hits(other, field, pkg) {
+				return fmt.Errorf("%s and %s can't be installed together (%s)", pkg.Key(), other.Key(), field)
+			}
+		}
+		for _, key := range keys {
+			old := installed[key]
+			if key == pkg.Key() || !hits(pkg, field, old) && !hits(old, field, pkg) {
+				continue
+			}
+			if i, ok := o.remove[key]; ok {
+				o.add(i, o.unpack[pkg.Key()], field, true)
+			} else if i, ok := o.unpack[key]; ok {
+				o.add(i, o.unpack[pkg.Key()], field, true)
+			} else {
+				return fmt.Errorf("%s and the installed %s can't be installed together (%s)", pkg.Key(), key, field)
+			}
+		}
+	}
+	return nil
+}
+
+// sort orders the steps along the edges, removals then Essential packages
+// first when there's a choice, breaking the cycles of soft edges.
+func (o *ordering) sort() (*Plan, error) {
+	rank := func(i int) int {
+		switch {
+		case o.steps[i].Action == ActionRemove:
+			return 0
+		case o.steps[i].Package.Essential:
+			return 1
+		}
+		return 2
+	}
+	for {
+		indegree := make([]int, len(o.steps))
+		for _, edge := range o.edges {
+			indegree[edge.to]++
+		}
+		done := make([]bool, len(o.steps))
+		order := []int{}
+		for len(order) < len(o.steps) {
+			next := -1
+			for i := range o.steps {
+				if done[i] || indegree[i] > 0 {
+					continue
+				}
+				if next == -1 || rank(i) < rank(next) {
+					next = i
+				}
+			}
+			if next == -1 {
+				break
+			}
+			done[next] = true
+			order = append(order, next)
+			for _, edge := range o.edges {
+				if edge.from == next {
+					indegree[edge.to]--
+				}
+			}
+		}
+
+		if len(order) == len(o.steps) {
+			plan := Plan{Steps: []Step{}}
+			for _, i := range order {
+				plan.Steps = append(plan.Steps, o.steps[i])
+			}
+			return &plan, nil
+		}
+
+		cycle := o.cycle(done)
+		broken := false
+		for _, e := range cycle {
+			if !o.edges[e].hard {
+				o.edges = append(o.edges[:e], o.edges[e+1:]...)
+				broken = true
+				break
+			}
+		}
+		if !broken {
+			ret := OrderCycle{}
+			for _, e := range cycle {
+				ret.Steps = append(ret.Steps, o.steps[o.edges[e].from])
+				ret.Reasons = append(ret.Reasons, o.edges[e].reason)
+			}
+			return nil, &ret
+		}
+	}
+}
+
+// cycle returns the edges of a cycle among the steps not done, in order:
+// each of them has an edge from another one, so walking those edges
+// backwards ends up in a cycle.
+func (o *ordering) cycle(done []bool) []int {
+	start := 0
+	for done[start] {
+		start++
+	}
+	seen := map[int]int{}
+	path := []int{}
+	for at := start; ; {
+		if _, ok := seen[at]; ok {
+			break
+		}
+		seen[at] = len(path)
+		for e, edge := range o.edges {
+			if edge.to == at && !done[edge.from] {
+				path = append(path, e)
+				at = edge.from
+				break
+			}
+		}
+	}
+	/* The cycle is the walk from where it came back, reversed */
+	last := o.edges[path[len(path)-1]].from
+	path = path[seen[last]:]
+	ret := []int{}
+	for i := len(path) - 1; i >= 0; i-- {
+		ret = append(ret, path[i])
+	}
+	return ret
+}
+
+// }}}
+
+// vim: foldmethod=marker
@@ -0,0 +1,141 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package apt_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ebikt/go-debian/apt"
+	"github.com/ebikt/go-debian/dependency"
+)
+
+/*
+ *
+ */
+
+func orderPackage(t *testing.T, name, ver string, fields map[string]string) apt.Package {
+	pkg := archPackage(t, name, ver, "amd64")
+	for field, value := range fields {
+		dep, err := dependency.Parse(value)
+		isok(t, err)
+		switch field {
+		case "Depends":
+			pkg.Depends = *dep
+		case "Pre-Depends":
+			pkg.PreDepends = *dep
+		case "Conflicts":
+			pkg.Conflicts = *dep
+		case "Breaks":
+			pkg.Breaks = *dep
+		}
+	}
+	return pkg
+}
+
+func planString(plan *apt.Plan) string {
+	return strings.Replace(plan.String(), "\n", "; ", -1)
+}
+
+func TestOrderPreDepends(t *testing.T) {
+	libc := orderPackage(t, "libc6", "2.36-9", nil)
+	hello := orderPackage(t, "hello", "2.10-3", map[string]string{"Pre-Depends": "libc6 (>= 2.36)"})
+	plan, err := apt.Orderer{Install: []apt.Package{hello, libc}}.Order()
+	isok(t, err)
+	assert(t, planString(plan) == "unpack libc6:amd64 2.36-9; configure libc6:amd64 2.36-9; "+
+		"unpack hello:amd64 2.10-3; configure hello:amd64 2.10-3")
+
+	/* Satisfied by an installed package the plan leaves alone */
+	plan, err = apt.Orderer{
+		Installed: []apt.Package{orderPackage(t, "libc6", "2.36-8", nil)},
+		Install:   []apt.Package{hello, orderPackage(t, "zlib1g", "1.2.13", nil)},
+	}.Order()
+	isok(t, err)
+	assert(t, planString(plan) == "unpack hello:amd64 2.10-3; configure hello:amd64 2.10-3; "+
+		"unpack zlib1g:amd64 1.2.13; configure zlib1g:amd64 1.2.13")
+}
+
+func TestOrderDependsCycle(t *testing.T) {
+	a := orderPackage(t, "a", "1", map[string]string{"Depends": "b"})
+	b := orderPackage(t, "b", "1", map[string]string{"Depends": "a"})
+	plan, err := apt.Orderer{Install: []apt.Package{a, b}}.Order()
+	isok(t, err)
+	assert(t, len(plan.Steps) == 4)
+
+	a = orderPackage(t, "a", "1", map[string]string{"Pre-Depends": "b"})
+	b = orderPackage(t, "b", "1", map[string]string{"Pre-Depends": "a"})
+	_, err = apt.Orderer{Install: []apt.Package{a, b}}.Order()
+	cycle, ok := err.(*apt.OrderCycle)
+	assert(t, ok)
+	assert(t, len(cycle.Steps) == 4)
+	assert(t, cycle.Reasons[0] == "Pre-Depends" || cycle.Reasons[0] == "unpack")
+}
+
+func TestOrderConflicts(t *testing.T) {
+	old := orderPackage(t, "exim4", "4.96-15", nil)
+	postfix := orderPackage(t, "postfix", "3.7.6-0", map[string]string{"Conflicts": "exim4"})
+	plan, err := apt.Orderer{
+		Installed: []apt.Package{old},
+		Install:   []apt.Package{postfix},
+		Remove:    []apt.Package{old},
+	}.Order()
+	isok(t, err)
+	assert(t, planString(plan) == "remove exim4:amd64; unpack postfix:amd64 3.7.6-0; configure postfix:amd64 3.7.6-0")
+
+	/* Neither removed nor upgraded */
+	_, err = apt.Orderer{Installed: []apt.Package{old}, Install: []apt.Package{postfix}}.Order()
+	notok(t, err)
+
+	/* An old package Breaks the new one, until upgraded */
+	oldLib := orderPackage(t, "libfoo1", "1.0", map[string]string{"Breaks": "foo-tools (>= 2)"})
+	newLib := orderPackage(t, "libfoo1", "2.0", nil)
+	tools := orderPackage(t, "foo-tools", "2.0", nil)
+	plan, err = apt.Orderer{
+		Installed: []apt.Package{oldLib},
+		Install:   []apt.Package{tools, newLib},
+	}.Order()
+	isok(t, err)
+	assert(t, plan.Steps[0].String() == "unpack libfoo1:amd64 2.0")
+}
+
+func TestOrderRemove(t *testing.T) {
+	app := orderPackage(t, "app", "1", map[string]string{"Depends": "lib"})
+	lib := orderPackage(t, "lib", "1", nil)
+	plan, err := apt.Orderer{Installed: []apt.Package{app, lib}, Remove: []apt.Package{lib, app}}.Order()
+	isok(t, err)
+	assert(t, planString(plan) == "remove app:amd64; remove lib:amd64")
+
+	lib.Essential = true
+	_, err = apt.Orderer{Installed: []apt.Package{lib}, Remove: []apt.Package{lib}}.Order()
+	notok(t, err)
+	_, err = apt.Orderer{Installed: []apt.Package{lib}, Remove: []apt.Package{lib}, RemoveEssential: true}.Order()
+	isok(t, err)
+}
+
+func TestOrderEssentialFirst(t *testing.T) {
+	base := orderPackage(t, "zbase", "1", nil)
+	base.Essential = true
+	plan, err := apt.Orderer{Install: []apt.Package{orderPackage(t, "abc", "1", nil), base}}.Order()
+	isok(t, err)
+	assert(t, plan.Steps[0].Package.Name == "zbase")
+}
+
+// vim: foldmethod=marker
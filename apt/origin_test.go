@@ -0,0 +1,56 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package apt_test
+
+import (
+	"testing"
+
+	"github.com/ebikt/go-debian/apt"
+)
+
+/*
+ *
+ */
+
+func TestOriginPattern(t *testing.T) {
+	pattern, err := apt.ParseOriginPattern("o=Debian,a=stable-security")
+	isok(t, err)
+	assert(t, pattern.Match(debianSecurity))
+	assert(t, !pattern.Match(debianStable))
+
+	pattern, err = apt.ParseOriginPattern("origin=Debian,codename=bookworm*,label=Debian*")
+	isok(t, err)
+	assert(t, pattern.Match(debianSecurity))
+	assert(t, pattern.Match(debianStable))
+
+	pattern, err = apt.ParseOriginPattern(`o=Foo\,Inc,c=updates/*`)
+	isok(t, err)
+	assert(t, pattern.Origin == "Foo,Inc")
+	assert(t, pattern.Match(apt.Origin{Origin: "Foo,Inc", Component: "updates/main"}))
+	assert(t, pattern.String() == `o=Foo\,Inc,c=updates/*`)
+
+	_, err = apt.ParseOriginPattern("x=Debian")
+	notok(t, err)
+	_, err = apt.ParseOriginPattern("Debian")
+	notok(t, err)
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,114 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package apt // import "github.com/ebikt/go-debian/apt"
+
+import (
+	"io"
+	"os"
+
+	"github.com/ebikt/go-debian/control"
+	"github.com/ebikt/go-debian/dependency"
+	"github.com/ebikt/go-debian/version"
+)
+
+// Package {{{
+
+// Package is a single version of a binary package, either installed on the
+// host or available from an archive, along with the relations apt looks at
+// when deciding what to upgrade, install or remove.
+type Package struct {
+	Name         string `control:"Package"`
+	Version      version.Version
+	Architecture string
+	MultiArch    string `control:"Multi-Arch"`
+	Essential    bool
+	Priority     string
+
+	// The source package this version was built from, as "src" or
+	// "src (version)", empty when it has the same name and version.
+	Source string
+
+	// Phased-Update-Percentage of the version, empty when it's not being
+	// phased; see Phaser.
+	PhasedUpdatePercentage string `control:"Phased-Update-Percentage"`
+
+	// Where this version is available from. Installed versions that are no
+	// longer available from any archive have no Origins.
+	Origins []Origin `control:"-"`
+
+	Depends    dependency.Dependency
+	PreDepends dependency.Dependency `control:"Pre-Depends"`
+	Recommends dependency.Dependency
+	Suggests   dependency.Dependency
+	Breaks     dependency.Dependency
+	Conflicts  dependency.Dependency
+	Provides   dependency.Dependency
+}
+
+// Key returns the "package:architecture" name of the Package, as used by
+// dpkg and apt to tell the architectures of a package apart.
+func (p Package) Key() string {
+	return p.Name + ":" + p.Architecture
+}
+
+// }}}
+
+// Packages index {{{
+
+// ReadPackages reads a (decompressed) Packages index, as found in archives
+// and in /var/lib/apt/lists/. The Origins of the packages are left empty.
+func ReadPackages(in io.Reader) ([]Package, error) {
+	ret := []Package{}
+	if err := control.Unmarshal(&ret, in); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// }}}
+
+// Status database {{{
+
+// ReadInstalled reads a dpkg status database, returning the packages that
+// are (at least partially) installed. Packages that are not installed or
+// only have their configuration files left are skipped.
+func ReadInstalled(in io.Reader) ([]Package, error) {
+	status, err := ReadStatus(in)
+	if err != nil {
+		return nil, err
+	}
+	return status.Installed(), nil
+}
+
+// LoadInstalled reads the dpkg status database at the given path, see
+// ReadInstalled.
+func LoadInstalled(path string) ([]Package, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadInstalled(f)
+}
+
+// }}}
+
+// vim: foldmethod=marker
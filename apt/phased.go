@@ -0,0 +1,177 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package apt // import "github.com/ebikt/go-debian/apt"
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Phased updates {{{
+
+// Phaser decides whether this machine takes part in the phased rollout of
+// an update, as announced by the Phased-Update-Percentage field of Ubuntu
+// archives. The decision is the one apt makes: a number from 0 to 100 is
+// drawn from the source package name, source version and machine id, and
+// the update is included if it's not over the percentage. Every machine
+// thus sticks to its decision for a given version, and the set of machines
+// getting it only grows as the percentage is raised.
+type Phaser struct {
+	// Contents of /etc/machine-id, or the APT::Machine-ID option.
+	MachineID string
+
+	// Include all phased updates (APT::Get::Always-Include-Phased-Updates),
+	// or none of them (APT::Get::Never-Include-Phased-Updates).
+	AlwaysInclude bool
+	NeverInclude  bool
+}
+
+// NewPhaser creates a Phaser from the apt configuration, reading the
+// machine id from /etc/machine-id unless APT::Machine-ID is set.
+func NewPhaser(config *Config) (*Phaser, error) {
+	ret := Phaser{
+		MachineID: config.Find("APT::Machine-ID", ""),
+		AlwaysInclude: config.FindB("APT::Get::Always-Include-Phased-Updates",
+			config.FindB("Update-Manager::Always-Include-Phased-Updates", false)),
+		NeverInclude: config.FindB("APT::Get::Never-Include-Phased-Updates",
+			config.FindB("Update-Manager::Never-Include-Phased-Updates", false)),
+	}
+	if ret.MachineID == "" && !ret.AlwaysInclude && !ret.NeverInclude {
+		data, err := os.ReadFile("/etc/machine-id")
+		if err != nil {
+			return nil, err
+		}
+		ret.MachineID = strings.TrimSpace(string(data))
+	}
+	return &ret, nil
+}
+
+// PhasedPercentage returns the Phased-Update-Percentage of the Package,
+// which is 100 when it's not being phased.
+func (p Package) PhasedPercentage() (int, error) {
+	if p.PhasedUpdatePercentage == "" {
+		return 100, nil
+	}
+	ret, err := strconv.Atoi(strings.TrimSpace(p.PhasedUpdatePercentage))
+	if err != nil || ret < 0 || ret > 100 {
+		return 0, fmt.Errorf("Malformed Phased-Update-Percentage: '%s'", p.PhasedUpdatePercentage)
+	}
+	return ret, nil
+}
+
+// source returns the name and version of the source package of the
+// Package, from its Source field.
+func (p Package) source() (string, string) {
+	name, ver := strings.TrimSpace(p.Source), p.Version.String()
+	if name == "" {
+		return p.Name, ver
+	}
+	if i := strings.Index(name, "("); i != -1 {
+		ver = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(name[i+1:]), ")"))
+		name = strings.TrimSpace(name[:i])
+	}
+	return name, ver
+}
+
+// Included returns whether this machine should get the given version of a
+// package. Versions that are not being phased are always included, as are
+// the ones available from a security archive, like apt does.
+func (ph Phaser) Included(pkg Package) (bool, error) {
+	percentage, err := pkg.PhasedPercentage()
+	if err != nil {
+		return false, err
+	}
+	if percentage >= 100 || ph.AlwaysInclude {
+		return true, nil
+	}
+	if ph.NeverInclude {
+		return false, nil
+	}
+	for _, origin := range pkg.Origins {
+		if strings.HasSuffix(origin.Archive, "-security") {
+			return true, nil
+		}
+	}
+	name, ver := pkg.source()
+	return phaseDraw(name+"-"+ver+"-"+ph.MachineID) <= percentage, nil
+}
+
+// phaseDraw draws the number from 0 to 100 apt compares against the
+// percentage. apt seeds a std::minstd_rand with a std::seed_seq of the
+// bytes of the seed, and takes a std::uniform_int_distribution(0, 100) of
+// it; this is the same computation, as done by libstdc++.
+func phaseDraw(seed string) int {
+	/* std::seed_seq::generate, for the 4 words minstd_rand asks for */
+	const n, p, q = 4, 1, 2
+	mix := func(x uint32) uint32 { return x ^ (x >> 27) }
+	words := [n]uint32{0x8b8b8b8b, 0x8b8b8b8b, 0x8b8b8b8b, 0x8b8b8b8b}
+	s := len(seed)
+	m := s + 1
+	if m < n {
+		m = n
+	}
+	for k := 0; k < m; k++ {
+		r1 := 1664525 * mix(words[k%n]^words[(k+p)%n]^words[(k+n-1)%n])
+		r2 := r1 + uint32(k%n)
+		if k == 0 {
+			r2 = r1 + uint32(s)
+		} else if k <= s {
+			r2 += uint32(int32(int8(seed[k-1])))
+		}
+		words[(k+p)%n] += r1
+		words[(k+q)%n] += r2
+		words[k%n] = r2
+	}
+	for k := m; k < m+n; k++ {
+		r3 := 1566083941 * mix(words[k%n]+words[(k+p)%n]+words[(k+n-1)%n])
+		r4 := r3 - uint32(k%n)
+		words[(k+p)%n] ^= r3
+		words[(k+q)%n] ^= r4
+		words[k%n] = r4
+	}
+
+	/* std::minstd_rand, seeded with the last word */
+	const modulus = 2147483647
+	state := uint64(words[3]) % modulus
+	if state == 0 {
+		state = 1
+	}
+	next := func() uint64 {
+		state = state * 48271 % modulus
+		return state
+	}
+
+	/* std::uniform_int_distribution(0, 100), by rejection */
+	const rng = modulus - 2
+	const scaling = rng / 101
+	const past = 101 * scaling
+	for {
+		if r := next() - 1; r < past {
+			return int(r / scaling)
+		}
+	}
+}
+
+// }}}
+
+// vim: foldmethod=marker
@@ -0,0 +1,126 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package apt_test
+
+import (
+	"testing"
+
+	"github.com/ebikt/go-debian/apt"
+)
+
+/*
+ *
+ */
+
+const machineID = "0123456789abcdef0123456789abcdef"
+
+func TestPhaserIncluded(t *testing.T) {
+	phaser := apt.Phaser{MachineID: machineID}
+
+	/* apt draws 71 for "hello-2.10-3-0123456789abcdef0123456789abcdef" */
+	pkg := mkPackage(t, "hello", "2.10-3", "")
+	included, err := phaser.Included(pkg)
+	isok(t, err)
+	assert(t, included)
+
+	pkg.PhasedUpdatePercentage = "70"
+	included, err = phaser.Included(pkg)
+	isok(t, err)
+	assert(t, !included)
+
+	pkg.PhasedUpdatePercentage = "71"
+	included, err = phaser.Included(pkg)
+	isok(t, err)
+	assert(t, included)
+
+	/* binNMUs are phased along with their source version */
+	binNMU := mkPackage(t, "hello-doc", "2.10-3+b1", "")
+	binNMU.Source = "hello (2.10-3)"
+	binNMU.PhasedUpdatePercentage = "70"
+	included, err = phaser.Included(binNMU)
+	isok(t, err)
+	assert(t, !included)
+
+	/* apt draws 11 for "apt-2.4.5-deadbeefdeadbeefdeadbeefdeadbeef" */
+	other := apt.Phaser{MachineID: "deadbeefdeadbeefdeadbeefdeadbeef"}
+	aptPkg := mkPackage(t, "apt", "2.4.5", "")
+	aptPkg.PhasedUpdatePercentage = "10"
+	included, err = other.Included(aptPkg)
+	isok(t, err)
+	assert(t, !included)
+	aptPkg.PhasedUpdatePercentage = "11"
+	included, err = other.Included(aptPkg)
+	isok(t, err)
+	assert(t, included)
+
+	/* Security updates are never phased */
+	pkg.PhasedUpdatePercentage = "0"
+	pkg.Origins = []apt.Origin{debianSecurity}
+	included, err = phaser.Included(pkg)
+	isok(t, err)
+	assert(t, included)
+
+	pkg.Origins = []apt.Origin{debianStable}
+	included, err = apt.Phaser{AlwaysInclude: true}.Included(pkg)
+	isok(t, err)
+	assert(t, included)
+	pkg.PhasedUpdatePercentage = "99"
+	included, err = apt.Phaser{NeverInclude: true}.Included(pkg)
+	isok(t, err)
+	assert(t, !included)
+
+	pkg.PhasedUpdatePercentage = "101"
+	_, err = phaser.Included(pkg)
+	notok(t, err)
+}
+
+func TestPhaserFromConfig(t *testing.T) {
+	phaser, err := apt.NewPhaser(parseConfig(t, `
+APT::Machine-ID "`+machineID+`";
+Update-Manager::Always-Include-Phased-Updates "true";
+`))
+	isok(t, err)
+	assert(t, phaser.MachineID == machineID)
+	assert(t, phaser.AlwaysInclude)
+	assert(t, !phaser.NeverInclude)
+}
+
+func TestUpgradeSelectPhased(t *testing.T) {
+	stable, err := apt.ParseOriginPattern("o=Debian,a=stable")
+	isok(t, err)
+	selector := apt.UpgradeSelector{
+		Allowed: []apt.OriginPattern{*stable},
+		Phaser:  &apt.Phaser{MachineID: machineID},
+	}
+
+	update := mkPackage(t, "hello", "2.10-3", "", debianStable)
+	update.PhasedUpdatePercentage = "50"
+	plan, err := selector.Select(
+		[]apt.Package{mkPackage(t, "hello", "2.10-2", "")},
+		[]apt.Package{update},
+	)
+	isok(t, err)
+	assert(t, len(plan.Upgrades) == 0)
+	assert(t, len(plan.Kept) == 1)
+	assert(t, plan.Kept[0].Reason == "phased")
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,271 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package apt // import "github.com/ebikt/go-debian/apt"
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ebikt/go-debian/control"
+	"github.com/ebikt/go-debian/version"
+)
+
+// Preferences {{{
+
+// Preferences are the pins of apt_preferences(5), along with the
+// APT::Default-Release option, which decide the priority of each version
+// of a package, and so which one apt installs.
+type Preferences struct {
+	Pins []Pin
+
+	// Suite or codename whose versions get the priority 990, if set.
+	DefaultRelease string
+}
+
+// parsePin reads the Pin of a paragraph of a preferences file.
+func parsePin(para control.Paragraph) (*Pin, error) {
+	pin := Pin{
+		Package:     strings.TrimSpace(para.Get("Package")),
+		Explanation: strings.TrimSpace(para.Get("Explanation")),
+	}
+	if pin.Package == "" {
+		return nil, fmt.Errorf("Preferences entry has no Package field")
+	}
+
+	priority := strings.TrimSpace(para.Get("Pin-Priority"))
+	if priority == "" {
+		return nil, fmt.Errorf("Preferences entry for '%s' has no Pin-Priority", pin.Package)
+	}
+	value, err := strconv.Atoi(priority)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid Pin-Priority for '%s': '%s'", pin.Package, priority)
+	}
+	pin.Priority = value
+
+	parts := strings.SplitN(strings.TrimSpace(para.Get("Pin")), " ", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("Invalid Pin for '%s': '%s'", pin.Package, para.Get("Pin"))
+	}
+	switch what, value := parts[0], strings.TrimSpace(parts[1]); what {
+	case "release":
+		if !strings.Contains(value, "=") {
+			/* "Pin: release stable" is a short hand for "a=stable" */
+			value = "a=" + value
+		}
+		origin, err := ParseOriginPattern(value)
+		if err != nil {
+			return nil, err
+		}
+		pin.Kind, pin.Origin = PinRelease, *origin
+	case "version":
+		pin.Kind, pin.Version = PinVersion, value
+	case "origin":
+		pin.Kind, pin.Site = PinOrigin, strings.Trim(value, `"`)
+	default:
+		return nil, fmt.Errorf("Unknown Pin type for '%s': '%s'", pin.Package, what)
+	}
+	return &pin, nil
+}
+
+// ParsePreferences reads the pins of an apt_preferences(5) file, such as
+// /etc/apt/preferences or a file of /etc/apt/preferences.d, in order.
+func ParsePreferences(reader io.Reader) ([]Pin, error) {
+	paragraphs, err := control.NewParagraphReader(reader, nil)
+	if err != nil {
+		return nil, err
+	}
+	ret := []Pin{}
+	for {
+		para, err := paragraphs.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		pin, err := parsePin(*para)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, *pin)
+	}
+	return ret, nil
+}
+
+// LoadPreferences reads the pins of the preferences file at the given path.
+func LoadPreferences(path string) ([]Pin, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParsePreferences(f)
+}
+
+func isPreferencesPart(name string) bool {
+	if !validPartName.MatchString(name) {
+		return false
+	}
+	ext := filepath.Ext(name)
+	return ext == "" || ext == ".pref"
+}
+
+// LoadSystemPreferences reads the preferences of the host's apt, found
+// below the given root directory ("/" for the running system):
+// etc/apt/preferences, then the parts in etc/apt/preferences.d, with the
+// file name rules apt uses. The DefaultRelease comes from the
+// APT::Default-Release option of the config, if not nil.
+func LoadSystemPreferences(root string, config *Config) (*Preferences, error) {
+	ret := Preferences{Pins: []Pin{}}
+	if config != nil {
+		ret.DefaultRelease = config.Find("APT::Default-Release", "")
+	}
+
+	pins, err := LoadPreferences(filepath.Join(root, "etc/apt/preferences"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	ret.Pins = append(ret.Pins, pins...)
+
+	dir := filepath.Join(root, "etc/apt/preferences.d")
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	names := []string{}
+	for _, entry := range entries {
+		if !entry.IsDir() && isPreferencesPart(entry.Name()) {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		pins, err := LoadPreferences(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		ret.Pins = append(ret.Pins, pins...)
+	}
+	return &ret, nil
+}
+
+// }}}
+
+// Priorities {{{
+
+// DefaultPriority returns the priority apt gives to the versions of an
+// Origin no Pin matches: 990 for the DefaultRelease, 1 for a NotAutomatic
+// Release, or 100 if it has ButAutomaticUpgrades too, and 500 otherwise.
+func (p Preferences) DefaultPriority(origin Origin) int {
+	switch {
+	case p.DefaultRelease != "" && (origin.Archive == p.DefaultRelease || origin.Codename == p.DefaultRelease):
+		return 990
+	case origin.NotAutomatic && origin.ButAutomaticUpgrades:
+		return 100
+	case origin.NotAutomatic:
+		return 1
+	}
+	return 500
+}
+
+// Priority returns the pin priority of the given Package, as apt computes
+// it: the priority of the first specific Pin matching it, if any. Else,
+// each of its Origins gets the priority of the first generic Pin matching
+// it, or its DefaultPriority, and the highest one is used. A Package with
+// no Origins is only installed, and gets 100, like apt's
+// /var/lib/dpkg/status.
+func (p Preferences) Priority(pkg Package) int {
+	for _, pin := range p.Pins {
+		if !pin.Generic() && pin.Matches(pkg) {
+			return pin.Priority
+		}
+	}
+	if len(pkg.Origins) == 0 {
+		return 100
+	}
+	ret := 0
+	for i, origin := range pkg.Origins {
+		priority := p.originPriority(pkg, origin)
+		if i == 0 || priority > ret {
+			ret = priority
+		}
+	}
+	return ret
+}
+
+func (p Preferences) originPriority(pkg Package, origin Origin) int {
+	pkg.Origins = []Origin{origin}
+	for _, pin := range p.Pins {
+		if pin.Generic() && pin.Matches(pkg) {
+			return pin.Priority
+		}
+	}
+	return p.DefaultPriority(origin)
+}
+
+// Candidate returns the version of a package apt would install, among the
+// available versions and the installed one, if not nil: the one with the
+// highest priority, then the highest version. Versions with a negative
+// priority are never picked, and versions older than the installed one
+// only with a priority of 1000 or more. nil is returned if there's no
+// candidate.
+func (p Preferences) Candidate(installed *Package, available []Package) *Package {
+	versions := append([]Package{}, available...)
+	if installed != nil {
+		known := false
+		for _, pkg := range available {
+			if version.Compare(pkg.Version, installed.Version) == 0 {
+				known = true
+			}
+		}
+		if !known {
+			versions = append(versions, *installed)
+		}
+	}
+	sort.SliceStable(versions, func(i, j int) bool {
+		return version.Compare(versions[i].Version, versions[j].Version) > 0
+	})
+
+	var ret *Package
+	best := 0
+	for i, pkg := range versions {
+		priority := p.Priority(pkg)
+		if priority < 0 {
+			continue
+		}
+		if installed != nil && priority < 1000 && version.Compare(pkg.Version, installed.Version) < 0 {
+			continue
+		}
+		if ret == nil || priority > best {
+			ret, best = &versions[i], priority
+		}
+	}
+	return ret
+}
+
+// }}}
+
+// vim: foldmethod=marker
@@ -0,0 +1,169 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package apt_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ebikt/go-debian/apt"
+)
+
+/*
+ *
+ */
+
+const preferences = `# Keep the kernel from backports
+Explanation: the backported kernel supports the new hardware
+Package: linux-image-* /^firmware-/
+Pin: release n=bookworm-backports
+Pin-Priority: 900
+
+Package: src:systemd
+Pin: version 252.*
+Pin-Priority: 1001
+
+Package: *
+Pin: release a=testing
+Pin-Priority: -10
+
+Package: *
+Pin: origin "deb.example.org"
+Pin-Priority: 600
+`
+
+var (
+	debianBackports = apt.Origin{Origin: "Debian", Archive: "stable-backports", Codename: "bookworm-backports", Component: "main", NotAutomatic: true, ButAutomaticUpgrades: true}
+	debianTesting   = apt.Origin{Origin: "Debian", Archive: "testing", Codename: "trixie", Component: "main"}
+	debianExp       = apt.Origin{Origin: "Debian", Archive: "experimental", Codename: "rc-buggy", Component: "main", NotAutomatic: true}
+	exampleOrigin   = apt.Origin{Origin: "Example", Archive: "stable", Component: "main", Site: "deb.example.org"}
+)
+
+func TestParsePreferences(t *testing.T) {
+	pins, err := apt.ParsePreferences(strings.NewReader(preferences))
+	isok(t, err)
+	assert(t, len(pins) == 4)
+	assert(t, pins[0].Kind == apt.PinRelease)
+	assert(t, pins[0].Origin.Codename == "bookworm-backports")
+	assert(t, strings.HasPrefix(pins[0].Explanation, "the backported"))
+	assert(t, pins[1].Kind == apt.PinVersion)
+	assert(t, pins[1].Version == "252.*")
+	assert(t, pins[2].Generic())
+	assert(t, pins[2].Priority == -10)
+	assert(t, pins[3].Kind == apt.PinOrigin)
+	assert(t, pins[3].Site == "deb.example.org")
+	assert(t, pins[3].String() == `Package: *, Pin: origin "deb.example.org", Pin-Priority: 600`)
+
+	pins, err = apt.ParsePreferences(strings.NewReader("Package: foo\nPin: release stable\nPin-Priority: 10\n"))
+	isok(t, err)
+	assert(t, pins[0].Origin.Archive == "stable")
+
+	for _, data := range []string{
+		"Pin: release a=stable\nPin-Priority: 10\n",
+		"Package: foo\nPin: release a=stable\n",
+		"Package: foo\nPin: release a=stable\nPin-Priority: high\n",
+		"Package: foo\nPin: label foo\nPin-Priority: 10\n",
+		"Package: foo\nPin: release\nPin-Priority: 10\n",
+		"Package: foo\nPin: release x=foo\nPin-Priority: 10\n",
+	} {
+		_, err := apt.ParsePreferences(strings.NewReader(data))
+		notok(t, err)
+	}
+}
+
+func TestPreferencesPriority(t *testing.T) {
+	pins, err := apt.ParsePreferences(strings.NewReader(preferences))
+	isok(t, err)
+	prefs := apt.Preferences{Pins: pins, DefaultRelease: "bookworm"}
+
+	assert(t, prefs.Priority(mkPackage(t, "bash", "5.2.15-2", "", debianStable)) == 990)
+	assert(t, prefs.Priority(mkPackage(t, "bash", "5.2.15-2", "", debianSecurity)) == 500)
+	assert(t, prefs.Priority(mkPackage(t, "bash", "5.2.21-2", "", debianTesting)) == -10)
+	assert(t, prefs.Priority(mkPackage(t, "bash", "5.3-1", "", debianExp)) == 1)
+	assert(t, prefs.Priority(mkPackage(t, "bash", "5.2.15-2", "")) == 100)
+	assert(t, prefs.Priority(mkPackage(t, "bash", "5.2.15-2+bpo1", "", debianBackports)) == 100)
+	assert(t, prefs.Priority(mkPackage(t, "linux-image-amd64", "6.5.10-1~bpo12+1", "", debianBackports)) == 900)
+	assert(t, prefs.Priority(mkPackage(t, "firmware-iwlwifi", "20230625-1~bpo12+1", "", debianBackports)) == 900)
+	assert(t, prefs.Priority(mkPackage(t, "hello", "1.0", "", exampleOrigin)) == 600)
+
+	/* The highest priority of all the origins is used */
+	assert(t, prefs.Priority(mkPackage(t, "bash", "5.2.15-2", "", debianTesting, debianStable)) == 990)
+
+	udev := mkPackage(t, "udev", "252.19-1", "", debianTesting)
+	udev.Source = "systemd"
+	assert(t, prefs.Priority(udev) == 1001)
+}
+
+func TestPreferencesCandidate(t *testing.T) {
+	pins, err := apt.ParsePreferences(strings.NewReader(preferences))
+	isok(t, err)
+	prefs := apt.Preferences{Pins: pins}
+
+	installed := mkPackage(t, "bash", "5.2.15-2", "")
+	available := []apt.Package{
+		mkPackage(t, "bash", "5.2.15-3", "", debianStable),
+		mkPackage(t, "bash", "5.2.21-2", "", debianTesting),
+		mkPackage(t, "bash", "5.3-1", "", debianExp),
+	}
+	candidate := prefs.Candidate(&installed, available)
+	assert(t, candidate.Version.String() == "5.2.15-3")
+	assert(t, prefs.Candidate(&installed, available[1:]).Version.String() == "5.2.15-2")
+	assert(t, prefs.Candidate(nil, available[1:]).Version.String() == "5.3-1")
+	assert(t, prefs.Candidate(nil, available[1:2]) == nil)
+
+	/* Only a priority over 1000 downgrades */
+	newer := mkPackage(t, "systemd", "254.1-1", "")
+	older := mkPackage(t, "systemd", "252.19-1", "", debianStable)
+	assert(t, prefs.Candidate(&newer, []apt.Package{older}).Version.String() == "252.19-1")
+	older.Version.Version = "253"
+	assert(t, prefs.Candidate(&newer, []apt.Package{older}).Version.String() == "254.1-1")
+}
+
+func TestLoadSystemPreferences(t *testing.T) {
+	root := t.TempDir()
+	write := func(name, data string) {
+		path := filepath.Join(root, name)
+		isok(t, os.MkdirAll(filepath.Dir(path), 0755))
+		isok(t, os.WriteFile(path, []byte(data), 0644))
+	}
+	write("etc/apt/preferences", "Package: foo\nPin: release a=stable\nPin-Priority: 1\n")
+	write("etc/apt/preferences.d/b.pref", "Package: bar\nPin: release a=stable\nPin-Priority: 2\n")
+	write("etc/apt/preferences.d/a", "Package: baz\nPin: release a=stable\nPin-Priority: 3\n")
+	write("etc/apt/preferences.d/c.disabled", "garbage")
+
+	config := apt.NewConfig()
+	config.Set("APT::Default-Release", "bookworm")
+	prefs, err := apt.LoadSystemPreferences(root, config)
+	isok(t, err)
+	assert(t, prefs.DefaultRelease == "bookworm")
+	assert(t, len(prefs.Pins) == 3)
+	assert(t, prefs.Pins[0].Package == "foo")
+	assert(t, prefs.Pins[1].Package == "baz")
+	assert(t, prefs.Pins[2].Package == "bar")
+
+	prefs, err = apt.LoadSystemPreferences(t.TempDir(), nil)
+	isok(t, err)
+	assert(t, len(prefs.Pins) == 0)
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,346 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package apt // import "github.com/ebikt/go-debian/apt"
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/ebikt/go-debian/dependency"
+	"github.com/ebikt/go-debian/internal"
+	"github.com/ebikt/go-debian/version"
+)
+
+// Resolver {{{
+
+// Resolver computes what has to be installed to install a set of packages,
+// pulling in their dependencies from the available packages, the same way
+// `apt-get install` does.
+type Resolver struct {
+	Installed []Package
+	Available []Package
+
+	// Pins used to pick the candidate version of a package, see
+	// UpgradeSelector.Priority.
+	Pins []Pin
+
+	// Install the Recommends of the packages being installed
+	// (APT::Install-Recommends), except for the packages matching one of
+	// the SkipRecommends wildcards. Recommends that are not installed are
+	// reported as Suggested.
+	InstallRecommends bool
+	SkipRecommends    []string
+
+	// Install the Suggests of the packages being installed
+	// (APT::Install-Suggests), rather than only reporting them.
+	InstallSuggests bool
+
+	// The native architecture (APT::Architecture), which the packages
+	// given to Install by their plain name are looked up for, see
+	// Universe.
+	Architecture string
+}
+
+// NewResolver creates a Resolver with the knobs of the apt configuration,
+// defaulting to installing Recommends and not Suggests like apt does.
+func NewResolver(config *Config, installed, available []Package) *Resolver {
+	return &Resolver{
+		Installed:         installed,
+		Available:         available,
+		InstallRecommends: config.FindB("APT::Install-Recommends", true),
+		InstallSuggests:   config.FindB("APT::Install-Suggests", false),
+		Architecture:      config.Find("APT::Architecture", ""),
+	}
+}
+
+// A Suggestion is a weak relation of a package being installed that has
+// been left unsatisfied, which apt lists as "Suggested packages" or
+// "Recommended packages".
+type Suggestion struct {
+	Package  string
+	Field    string
+	Relation dependency.Relation
+}
+
+func (s Suggestion) String() string {
+	return fmt.Sprintf("%s %s: %s", s.Package, s.Field, s.Relation.String())
+}
+
+// Solution is the outcome of a Resolver run.
+type Solution struct {
+	// Packages to install or upgrade, sorted by name.
+	Install []Package
+
+	// Weak relations left unsatisfied, advisory only, sorted by the name
+	// of the package they belong to.
+	Suggested []Suggestion
+}
+
+// resolution is the state of a single Resolver run.
+type resolution struct {
+	resolver  Resolver
+	installed map[string]Package
+	changes   map[string]Package
+	suggested []Suggestion
+}
+
+// Install resolves the installation of the named packages, which may be
+// architecture qualified ("libc6:i386"), see Universe.Lookup. When that's
+// not possible, the error is an *Explanation of why.
+func (r Resolver) Install(names ...string) (*Solution, error) {
+	res := resolution{
+		resolver:  r,
+		installed: map[string]Package{},
+		changes:   map[string]Package{},
+		suggested: []Suggestion{},
+	}
+	for _, pkg := range r.Installed {
+		res.installed[pkg.Name] = pkg
+	}
+
+	universe := NewUniverse(r.Architecture, r.Available)
+	for _, name := range names {
+		possi, err := universe.possibility(name)
+		if err != nil {
+			return nil, err
+		}
+		relation := dependency.Relation{
+			Possibilities: []dependency.Possibility{possi},
+		}
+		if err := res.require(nil, "Install", relation); err != nil {
+			return nil, err
+		}
+	}
+
+	before := map[string]bool{}
+	for _, problem := range newWorld(res.installed, nil).problems() {
+		before[problem.String()] = true
+	}
+	world := res.world()
+	for _, problem := range world.problems() {
+		if !before[problem.String()] {
+			return nil, res.explainProblem(world, problem)
+		}
+	}
+
+	solution := Solution{Install: []Package{}, Suggested: []Suggestion{}}
+	for _, pkg := range res.changes {
+		solution.Install = append(solution.Install, pkg)
+	}
+	sort.Slice(solution.Install, func(i, j int) bool {
+		return solution.Install[i].Name < solution.Install[j].Name
+	})
+	for _, suggestion := range res.suggested {
+		if !world.satisfiesRelation(suggestion.Relation) {
+			solution.Suggested = append(solution.Suggested, suggestion)
+		}
+	}
+	sort.SliceStable(solution.Suggested, func(i, j int) bool {
+		return solution.Suggested[i].Package < solution.Suggested[j].Package
+	})
+	return &solution, nil
+}
+
+// candidate returns the best available package for the Possibility of a
+// relation of `from`: packages with that name come first, then the ones
+// providing it, and within those the highest priority then version wins.
+// Packages pinned below 0 are never candidates.
+func (r Resolver) candidate(from Package, possi dependency.Possibility) *Package {
+	selector := UpgradeSelector{Pins: r.Pins}
+	var best *Package
+	bestReal, bestPriority := false, 0
+	for i, pkg := range r.Available {
+		if !satisfiedBy(from, possi, pkg) {
+			continue
+		}
+		real := pkg.Name == possi.Name
+		priority := selector.Priority(pkg)
+		if priority < 0 {
+			continue
+		}
+		if best != nil {
+			if bestReal != real {
+				if bestReal {
+					continue
+				}
+			} else if priority < bestPriority {
+				continue
+			} else if priority == bestPriority && version.Compare(pkg.Version, best.Version) <= 0 {
+				continue
+			}
+		}
+		best, bestReal, bestPriority = &r.Available[i], real, priority
+	}
+	return best
+}
+
+func (r Resolver) skipRecommends(name string) bool {
+	for _, pattern := range r.SkipRecommends {
+		if internal.GlobMatch(pattern, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (res *resolution) world() world {
+	all := map[string]Package{}
+	for name, pkg := range res.installed {
+		all[name] = pkg
+	}
+	for name, pkg := range res.changes {
+		all[name] = pkg
+	}
+	return newWorld(all, nil)
+}
+
+func (res *resolution) install(pkg Package) *Explanation {
+	if _, ok := res.changes[pkg.Name]; ok {
+		return nil
+	}
+	if old, ok := res.installed[pkg.Name]; ok && version.Compare(old.Version, pkg.Version) == 0 {
+		return nil
+	}
+	res.changes[pkg.Name] = pkg
+
+	for _, field := range []struct {
+		name string
+		dep  dependency.Dependency
+		hard bool
+		weak bool
+	}{
+		{"Pre-Depends", pkg.PreDepends, true, false},
+		{"Depends", pkg.Depends, true, false},
+		{"Recommends", pkg.Recommends, false,
+			!res.resolver.InstallRecommends || res.resolver.skipRecommends(pkg.Name)},
+		{"Suggests", pkg.Suggests, false, !res.resolver.InstallSuggests},
+	} {
+		for _, relation := range field.dep.Relations {
+			if field.weak {
+				res.suggested = append(res.suggested, Suggestion{pkg.Name, field.name, relation})
+				continue
+			}
+			err := res.require(&pkg, field.name, relation)
+			if err == nil {
+				continue
+			}
+			if field.hard {
+				return err
+			}
+			res.suggested = append(res.suggested, Suggestion{pkg.Name, field.name, relation})
+		}
+	}
+	return nil
+}
+
+// require makes sure the relation of `pkg` is satisfied, installing the
+// candidate of the first alternative that can be installed if it isn't
+// yet. Nothing is changed if none can, and the Explanation of why is
+// returned.
+func (res *resolution) require(pkg *Package, field string, relation dependency.Relation) *Explanation {
+	if pkg != nil && res.world().satisfiesRelation(relation) {
+		return nil
+	}
+	from := Package{}
+	if pkg != nil {
+		from = *pkg
+	}
+
+	explanation := Explanation{Package: pkg, Field: field, Relation: relation}
+	for _, possi := range relation.Possibilities {
+		if possi.Substvar {
+			continue
+		}
+		candidate := res.resolver.candidate(from, possi)
+		if candidate == nil {
+			explanation.Alternatives = append(
+				explanation.Alternatives,
+				res.resolver.explain(from, possi),
+			)
+			continue
+		}
+
+		changes := map[string]Package{}
+		for name, change := range res.changes {
+			changes[name] = change
+		}
+		suggested := len(res.suggested)
+
+		err := res.install(*candidate)
+		if err == nil {
+			return nil
+		}
+		res.changes, res.suggested = changes, res.suggested[:suggested]
+		explanation.Alternatives = append(explanation.Alternatives, Alternative{
+			Possibility: possi,
+			Reason:      ReasonUninstallable,
+			Candidate:   candidate,
+			Cause:       err,
+		})
+	}
+	return &explanation
+}
+
+// explainProblem explains a Breaks, Conflicts or Depends that doesn't
+// hold any more with the changes.
+func (res *resolution) explainProblem(w world, p problem) *Explanation {
+	pkg := w.packages[p.Package]
+	explanation := Explanation{Package: &pkg, Field: p.Field, Relation: p.Relation}
+	for _, possi := range p.Relation.Possibilities {
+		if p.Field == "Breaks" || p.Field == "Conflicts" {
+			for _, name := range w.hitBy(p.Package, possi) {
+				hit := w.packages[name]
+				explanation.Alternatives = append(explanation.Alternatives, Alternative{
+					Possibility: possi,
+					Reason:      ReasonConflict,
+					Candidate:   &hit,
+				})
+			}
+			continue
+		}
+		for _, name := range culprits(problem{Relation: dependency.Relation{
+			Possibilities: []dependency.Possibility{possi},
+		}}, res.installed, res.changes) {
+			culprit := res.changes[name]
+			explanation.Alternatives = append(explanation.Alternatives, Alternative{
+				Possibility: possi,
+				Reason:      ReasonBroken,
+				Candidate:   &culprit,
+			})
+		}
+	}
+	return &explanation
+}
+
+// satisfiesRelation checks if any alternative of the relation is
+// satisfied.
+func (w world) satisfiesRelation(relation dependency.Relation) bool {
+	for _, possi := range relation.Possibilities {
+		if possi.Substvar || w.satisfies(possi) {
+			return true
+		}
+	}
+	return false
+}
+
+// }}}
+
+// vim: foldmethod=marker
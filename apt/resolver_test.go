@@ -0,0 +1,200 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package apt_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ebikt/go-debian/apt"
+	"github.com/ebikt/go-debian/dependency"
+)
+
+/*
+ *
+ */
+
+func parseDep(t *testing.T, in string) dependency.Dependency {
+	dep, err := dependency.Parse(in)
+	isok(t, err)
+	return *dep
+}
+
+func solutionNames(solution *apt.Solution) string {
+	names := []string{}
+	for _, pkg := range solution.Install {
+		names = append(names, pkg.Name+"="+pkg.Version.String())
+	}
+	return strings.Join(names, " ")
+}
+
+func resolverUniverse(t *testing.T) []apt.Package {
+	hello := mkPackage(t, "hello", "2.10-3", "libc6 (>= 2.34), libhello1 | libhello-alt1", debianStable)
+	hello.Recommends = parseDep(t, "hello-doc, hello-extras")
+	hello.Suggests = parseDep(t, "hello-el")
+
+	doc := mkPackage(t, "hello-doc", "2.10-3", "", debianStable)
+	doc.Suggests = parseDep(t, "pdf-viewer")
+
+	evince := mkPackage(t, "evince", "43.1-2", "", debianStable)
+	evince.Provides = parseDep(t, "pdf-viewer")
+
+	return []apt.Package{
+		hello, doc, evince,
+		mkPackage(t, "libc6", "2.36-9", "", debianStable),
+		mkPackage(t, "libc6", "2.36-9+deb12u1", "", debianSecurity),
+		mkPackage(t, "libhello1", "1.0-1", "libmissing", debianStable),
+		mkPackage(t, "libhello-alt1", "1.0-1", "", debianStable),
+		mkPackage(t, "hello-extras", "1.0-1", "", debianStable),
+		mkPackage(t, "hello-el", "1.0-1", "", debianStable),
+	}
+}
+
+func TestResolverRecommends(t *testing.T) {
+	installed := []apt.Package{mkPackage(t, "libc6", "2.31-13", "")}
+	resolver := apt.NewResolver(apt.NewConfig(), installed, resolverUniverse(t))
+	assert(t, resolver.InstallRecommends)
+	assert(t, !resolver.InstallSuggests)
+
+	solution, err := resolver.Install("hello")
+	isok(t, err)
+	assert(t, solutionNames(solution) == "hello=2.10-3 hello-doc=2.10-3 hello-extras=1.0-1 libc6=2.36-9+deb12u1 libhello-alt1=1.0-1")
+	assert(t, len(solution.Suggested) == 2)
+	assert(t, solution.Suggested[0].String() == "hello Suggests: hello-el")
+	assert(t, solution.Suggested[1].String() == "hello-doc Suggests: pdf-viewer")
+
+	resolver.SkipRecommends = []string{"hel*"}
+	solution, err = resolver.Install("hello")
+	isok(t, err)
+	assert(t, solutionNames(solution) == "hello=2.10-3 libc6=2.36-9+deb12u1 libhello-alt1=1.0-1")
+	assert(t, len(solution.Suggested) == 3)
+	assert(t, solution.Suggested[0].String() == "hello Recommends: hello-doc")
+
+	resolver.SkipRecommends = nil
+	resolver.InstallSuggests = true
+	solution, err = resolver.Install("hello")
+	isok(t, err)
+	assert(t, strings.Contains(solutionNames(solution), "evince=43.1-2 hello=2.10-3 hello-doc=2.10-3 hello-el=1.0-1"))
+	assert(t, len(solution.Suggested) == 0)
+}
+
+func TestResolverFromConfig(t *testing.T) {
+	resolver := apt.NewResolver(parseConfig(t, `
+APT::Install-Recommends "false";
+APT::Install-Suggests "true";
+`), nil, resolverUniverse(t))
+	assert(t, !resolver.InstallRecommends)
+	assert(t, resolver.InstallSuggests)
+}
+
+func virtualUniverse(t *testing.T) []apt.Package {
+	exim := mkPackage(t, "exim4-daemon-light", "4.96-15", "", debianStable)
+	exim.Provides = parseDep(t, "mail-transport-agent")
+	exim.Conflicts = parseDep(t, "mail-transport-agent")
+	postfix := mkPackage(t, "postfix", "3.7.6-0", "", debianStable)
+	postfix.Provides = parseDep(t, "mail-transport-agent")
+	postfix.Conflicts = parseDep(t, "mail-transport-agent")
+	mailx := mkPackage(t, "bsd-mailx", "8.1.2-0.20220412", "default-mta | mail-transport-agent", debianStable)
+
+	mysql := mkPackage(t, "mariadb-server", "1:10.11.4-1", "", debianStable)
+	mysql.Provides = parseDep(t, "virtual-mysql-server (= 10.11), default-mysql-server")
+	legacy := mkPackage(t, "legacy-mysql-server", "5.0-1", "", debianStable)
+	legacy.Provides = parseDep(t, "virtual-mysql-server")
+	app := mkPackage(t, "app", "1.0", "virtual-mysql-server (>= 10)", debianStable)
+	old := mkPackage(t, "old-client", "1.0", "", debianStable)
+	old.Breaks = parseDep(t, "virtual-mysql-server (<< 10.5)")
+	return []apt.Package{exim, postfix, mailx, mysql, legacy, app, old}
+}
+
+func TestResolverVirtualPackages(t *testing.T) {
+	available := virtualUniverse(t)
+
+	/* An installed provider satisfies the relation */
+	resolver := apt.Resolver{Installed: []apt.Package{available[1]}, Available: available}
+	solution, err := resolver.Install("bsd-mailx")
+	isok(t, err)
+	assert(t, solutionNames(solution) == "bsd-mailx=8.1.2-0.20220412")
+
+	/* Providers conflicting with the virtual package exclude each other */
+	_, err = resolver.Install("exim4-daemon-light")
+	notok(t, err)
+	explanation, ok := err.(*apt.Explanation)
+	assert(t, ok)
+	assert(t, strings.Contains(explanation.String(), "postfix"))
+	resolver.Installed = nil
+	solution, err = resolver.Install("exim4-daemon-light")
+	isok(t, err)
+	assert(t, solutionNames(solution) == "exim4-daemon-light=4.96-15")
+
+	/* Only versioned Provides satisfy versioned relations */
+	solution, err = resolver.Install("app")
+	isok(t, err)
+	assert(t, solutionNames(solution) == "app=1.0 mariadb-server=1:10.11.4-1")
+	resolver.Installed = []apt.Package{available[4]}
+	solution, err = resolver.Install("old-client")
+	isok(t, err)
+	resolver.Installed = []apt.Package{available[3]}
+	_, err = resolver.Install("old-client")
+	isok(t, err)
+	resolver.Installed = []apt.Package{available[3], available[6]}
+	_, err = resolver.Install("app")
+	isok(t, err)
+	ancient := mkPackage(t, "mysql-server-10.1", "10.1.48-0", "", debianStable)
+	ancient.Provides = parseDep(t, "virtual-mysql-server (= 10.1)")
+	resolver.Installed = []apt.Package{ancient}
+	_, err = resolver.Install("old-client")
+	notok(t, err)
+}
+
+func TestUniverseProviders(t *testing.T) {
+	universe := apt.NewUniverse("amd64", virtualUniverse(t))
+	providers, err := universe.Providers("mail-transport-agent")
+	isok(t, err)
+	assert(t, len(providers) == 2)
+	assert(t, providers[0].Name == "exim4-daemon-light")
+	providers, err = universe.Providers("virtual-mysql-server (>= 10)")
+	isok(t, err)
+	assert(t, len(providers) == 1 && providers[0].Name == "mariadb-server")
+	_, err = universe.Providers("a | b")
+	notok(t, err)
+
+	assert(t, universe.IsVirtual("default-mysql-server"))
+	assert(t, !universe.IsVirtual("postfix"))
+	assert(t, !universe.IsVirtual("missing"))
+	_, err = universe.Lookup("mail-transport-agent")
+	assert(t, err != nil && strings.Contains(err.Error(), "virtual package provided by"))
+}
+
+func TestResolverUnsatisfiable(t *testing.T) {
+	resolver := apt.NewResolver(apt.NewConfig(), nil, resolverUniverse(t))
+
+	_, err := resolver.Install("libhello1")
+	notok(t, err)
+
+	_, err = resolver.Install("missing")
+	notok(t, err)
+
+	resolver.Pins = []apt.Pin{{Package: "libc6", Priority: -1}}
+	_, err = resolver.Install("hello")
+	notok(t, err)
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,155 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package apt // import "github.com/ebikt/go-debian/apt"
+
+import (
+	"github.com/ebikt/go-debian/control"
+	"github.com/ebikt/go-debian/dependency"
+)
+
+// Satisfying relations {{{
+
+// Satisfier checks relations, such as the Build-Depends of a source
+// package, against a set of packages, the way dpkg-checkbuilddeps does
+// against the installed ones. Unlike a Resolver, it doesn't pull anything
+// in: each relation has to be satisfied by the packages as they are.
+type Satisfier struct {
+	Packages []Package
+
+	// Architecture the relations are checked for: the possibilities
+	// restricted to other architectures ("foo [!amd64]") are left out,
+	// and plain names are satisfied by packages of this architecture,
+	// Architecture: all ones or Multi-Arch: foreign ones.
+	Architecture string
+
+	// Build profiles enabled, such as "nocheck", which the possibilities
+	// restricted with "<...>" are checked against.
+	Profiles []string
+}
+
+// RelationStatus tells whether a single relation is satisfied, and how.
+type RelationStatus struct {
+	Relation dependency.Relation
+
+	// The packages satisfying the relation, for each of its possibilities
+	// in turn.
+	SatisfiedBy []Package
+
+	// Why each of the possibilities can't be satisfied, when none is.
+	Alternatives []Alternative
+}
+
+// Satisfied tells whether some package satisfies the relation.
+func (s RelationStatus) Satisfied() bool {
+	return len(s.SatisfiedBy) > 0
+}
+
+// Satisfaction is the outcome of Satisfier.Check, relation by relation.
+type Satisfaction struct {
+	Relations []RelationStatus
+}
+
+// Satisfied tells whether all of the relations are satisfied.
+func (s Satisfaction) Satisfied() bool {
+	return len(s.Unsatisfied()) == 0
+}
+
+// Unsatisfied returns the relations that are not satisfied.
+func (s Satisfaction) Unsatisfied() []RelationStatus {
+	ret := []RelationStatus{}
+	for _, relation := range s.Relations {
+		if !relation.Satisfied() {
+			ret = append(ret, relation)
+		}
+	}
+	return ret
+}
+
+// Explanations tells why each of the unsatisfied relations is, as found
+// in the given field, such as "Build-Depends".
+func (s Satisfaction) Explanations(field string) []*Explanation {
+	ret := []*Explanation{}
+	for _, relation := range s.Unsatisfied() {
+		ret = append(ret, &Explanation{
+			Field:        field,
+			Relation:     relation.Relation,
+			Alternatives: relation.Alternatives,
+		})
+	}
+	return ret
+}
+
+// Check checks each relation of the Dependency, once reduced to the
+// Architecture and Profiles of the Satisfier.
+func (s Satisfier) Check(dep dependency.Dependency) (*Satisfaction, error) {
+	native, err := dependency.ParseArch(s.Architecture)
+	if err != nil {
+		return nil, err
+	}
+	resolver := Resolver{Available: s.Packages}
+	from := Package{Architecture: s.Architecture}
+
+	ret := Satisfaction{Relations: []RelationStatus{}}
+	for _, relation := range dep.Reduce(*native, s.Profiles).Relations {
+		status := RelationStatus{
+			Relation:     relation,
+			SatisfiedBy:  []Package{},
+			Alternatives: []Alternative{},
+		}
+		for _, possi := range relation.Possibilities {
+			if possi.Arch != nil {
+				/* "foo:native" is the architecture being checked for */
+				arch := possi.Arch.ResolveNative(*native)
+				possi.Arch = &arch
+			}
+			for _, pkg := range s.Packages {
+				if satisfiedBy(from, possi, pkg) {
+					status.SatisfiedBy = append(status.SatisfiedBy, pkg)
+				}
+			}
+		}
+		if len(status.SatisfiedBy) == 0 {
+			for _, possi := range relation.Possibilities {
+				status.Alternatives = append(status.Alternatives, resolver.explain(from, possi))
+			}
+		}
+		ret.Relations = append(ret.Relations, status)
+	}
+	return &ret, nil
+}
+
+// CheckBuildDepends checks the Build-Depends and Build-Depends-Arch of the
+// source package, and its Build-Depends-Indep too when `indep` is set, as
+// needed to build its architecture dependent (and independent) packages.
+// The Build-Conflicts fields are not looked at.
+func (s Satisfier) CheckBuildDepends(dsc control.DSC, indep bool) (*Satisfaction, error) {
+	dep := dependency.Dependency{Relations: []dependency.Relation{}}
+	dep.Relations = append(dep.Relations, dsc.BuildDepends.Relations...)
+	dep.Relations = append(dep.Relations, dsc.BuildDependsArch.Relations...)
+	if indep {
+		dep.Relations = append(dep.Relations, dsc.BuildDependsIndep.Relations...)
+	}
+	return s.Check(dep)
+}
+
+// }}}
+
+// vim: foldmethod=marker
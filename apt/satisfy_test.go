@@ -0,0 +1,132 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package apt_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ebikt/go-debian/apt"
+	"github.com/ebikt/go-debian/control"
+	"github.com/ebikt/go-debian/dependency"
+)
+
+/*
+ *
+ */
+
+func satisfier(t *testing.T) apt.Satisfier {
+	tool := archPackage(t, "debhelper-compat", "13", "all")
+	gcc := archPackage(t, "gcc", "12.2.0-14", "amd64")
+	gcc.MultiArch = "foreign"
+	libfoo := archPackage(t, "libfoo-dev", "1.5-1", "amd64")
+	libfooArm := archPackage(t, "libfoo-dev", "1.5-1", "arm64")
+	python := archPackage(t, "python3", "3.11.2-1", "amd64")
+	python.MultiArch = "allowed"
+	rust := archPackage(t, "rustc", "1.63.0", "amd64")
+	provides, err := dependency.Parse("rust-compiler (= 1.63)")
+	isok(t, err)
+	rust.Provides = *provides
+
+	return apt.Satisfier{
+		Packages:     []apt.Package{tool, gcc, libfoo, libfooArm, python, rust},
+		Architecture: "amd64",
+	}
+}
+
+func check(t *testing.T, s apt.Satisfier, relations string) *apt.Satisfaction {
+	dep, err := dependency.Parse(relations)
+	isok(t, err)
+	ret, err := s.Check(*dep)
+	isok(t, err)
+	return ret
+}
+
+func TestSatisfierSatisfied(t *testing.T) {
+	s := satisfier(t)
+	ret := check(t, s, "debhelper-compat (= 13), gcc, libfoo-dev (>= 1.0), "+
+		"python3:any, rust-compiler (>= 1.60) | cargo, "+
+		"libbar-dev [arm64], libbaz-dev <pkg.foo.baz>, gcc:native")
+	assert(t, ret.Satisfied())
+	assert(t, len(ret.Relations) == 6)
+	assert(t, len(ret.Relations[2].SatisfiedBy) == 1)
+	assert(t, ret.Relations[2].SatisfiedBy[0].Architecture == "amd64")
+	assert(t, ret.Relations[4].SatisfiedBy[0].Name == "rustc")
+
+	/* With the nocheck profile, the test dependencies are not needed */
+	s.Profiles = []string{"nocheck"}
+	ret = check(t, s, "gcc, libbaz-dev <!nocheck>")
+	assert(t, ret.Satisfied())
+	assert(t, len(ret.Relations) == 1)
+}
+
+func TestSatisfierUnsatisfied(t *testing.T) {
+	s := satisfier(t)
+	ret := check(t, s, "gcc, missing-dev, libfoo-dev (>= 2.0), rustc:any, libbaz-dev <!nocheck>")
+	assert(t, !ret.Satisfied())
+	unsatisfied := ret.Unsatisfied()
+	assert(t, len(unsatisfied) == 4)
+	assert(t, unsatisfied[0].Alternatives[0].Reason == apt.ReasonMissing)
+	assert(t, unsatisfied[1].Alternatives[0].Reason == apt.ReasonVersion)
+	assert(t, len(unsatisfied[1].Alternatives[0].Available) == 1)
+	assert(t, unsatisfied[2].Alternatives[0].Reason == apt.ReasonArchitecture)
+	assert(t, unsatisfied[3].Alternatives[0].Reason == apt.ReasonMissing)
+
+	explanations := ret.Explanations("Build-Depends")
+	assert(t, len(explanations) == 4)
+	assert(t, strings.Contains(explanations[1].String(), "only libfoo-dev 1.5-1 is available"))
+}
+
+func TestSatisfierArchitecture(t *testing.T) {
+	s := satisfier(t)
+	s.Packages = s.Packages[3:4]
+	ret := check(t, s, "libfoo-dev")
+	assert(t, !ret.Satisfied())
+	assert(t, ret.Relations[0].Alternatives[0].Reason == apt.ReasonArchitecture)
+
+	ret = check(t, s, "libfoo-dev:arm64")
+	assert(t, ret.Satisfied())
+}
+
+func TestSatisfierBuildDepends(t *testing.T) {
+	s := satisfier(t)
+	dsc := control.DSC{}
+	for field, value := range map[*dependency.Dependency]string{
+		&dsc.BuildDepends:      "debhelper-compat (= 13)",
+		&dsc.BuildDependsArch:  "gcc",
+		&dsc.BuildDependsIndep: "sphinx",
+	} {
+		dep, err := dependency.Parse(value)
+		isok(t, err)
+		*field = *dep
+	}
+
+	ret, err := s.CheckBuildDepends(dsc, false)
+	isok(t, err)
+	assert(t, ret.Satisfied())
+
+	ret, err = s.CheckBuildDepends(dsc, true)
+	isok(t, err)
+	assert(t, !ret.Satisfied())
+	assert(t, ret.Unsatisfied()[0].Relation.Possibilities[0].Name == "sphinx")
+}
+
+// vim: foldmethod=marker
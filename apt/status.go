@@ -0,0 +1,192 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package apt // import "github.com/ebikt/go-debian/apt"
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/ebikt/go-debian/control"
+	"github.com/ebikt/go-debian/dependency"
+	"github.com/ebikt/go-debian/version"
+)
+
+// Package status {{{
+
+// States a package can be in, the last word of its Status field, see
+// dpkg(1).
+const (
+	StateNotInstalled    = "not-installed"
+	StateConfigFiles     = "config-files"
+	StateHalfInstalled   = "half-installed"
+	StateUnpacked        = "unpacked"
+	StateHalfConfigured  = "half-configured"
+	StateTriggersAwaited = "triggers-awaited"
+	StateTriggersPending = "triggers-pending"
+	StateInstalled       = "installed"
+)
+
+// PackageStatus is the Status field of a dpkg status database entry, such
+// as "install ok installed": what is wanted of the package ("install",
+// "hold", "deinstall", "purge" or "unknown"), whether it needs to be
+// reinstalled ("ok" or "reinstreq"), and its state.
+type PackageStatus struct {
+	Want  string
+	Flag  string
+	State string
+}
+
+func (s *PackageStatus) UnmarshalControl(data string) error {
+	fields := strings.Fields(data)
+	if len(fields) != 3 {
+		return fmt.Errorf("Invalid package status: '%s'", data)
+	}
+	s.Want, s.Flag, s.State = fields[0], fields[1], fields[2]
+	return nil
+}
+
+func (s PackageStatus) MarshalControl() (string, error) {
+	return s.String(), nil
+}
+
+func (s PackageStatus) String() string {
+	return s.Want + " " + s.Flag + " " + s.State
+}
+
+// Installed tells whether the package is (at least partially) installed,
+// rather than not installed or only its configuration files left.
+func (s PackageStatus) Installed() bool {
+	return s.State != "" && s.State != StateNotInstalled && s.State != StateConfigFiles
+}
+
+// Configured tells whether the package is configured, which is what
+// satisfies the dependencies of other packages.
+func (s PackageStatus) Configured() bool {
+	switch s.State {
+	case StateInstalled, StateTriggersPending, StateTriggersAwaited:
+		return true
+	}
+	return false
+}
+
+// }}}
+
+// Status database {{{
+
+// InstalledPackage is an entry of the dpkg status database.
+type InstalledPackage struct {
+	Package
+
+	Status PackageStatus `required:"true"`
+
+	// The version last configured, which differs from the Version while
+	// an upgrade is only unpacked.
+	ConfigVersion version.Version `control:"Config-Version"`
+}
+
+// Status is the dpkg status database, /var/lib/dpkg/status.
+type Status struct {
+	Packages []InstalledPackage
+}
+
+// ReadStatus reads a dpkg status database.
+func ReadStatus(in io.Reader) (*Status, error) {
+	ret := Status{Packages: []InstalledPackage{}}
+	if err := control.Unmarshal(&ret.Packages, in); err != nil {
+		return nil, err
+	}
+	return &ret, nil
+}
+
+// LoadStatus reads the dpkg status database at the given path, such as
+// /var/lib/dpkg/status.
+func LoadStatus(path string) (*Status, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadStatus(f)
+}
+
+// Lookup returns the entry of the package named "name:arch", or "name"
+// for a package dpkg knows of a single architecture of, nil if there is
+// no such entry.
+func (s Status) Lookup(name string) *InstalledPackage {
+	pkgName, arch := SplitArchQualifier(name)
+	var ret *InstalledPackage
+	for i, pkg := range s.Packages {
+		if pkg.Name != pkgName || (arch != "" && pkg.Architecture != arch) {
+			continue
+		}
+		if ret != nil {
+			/* A plain name of a package of several architectures */
+			return nil
+		}
+		ret = &s.Packages[i]
+	}
+	return ret
+}
+
+// Filter returns the entries in any of the given states, such as
+// StateUnpacked or StateHalfConfigured.
+func (s Status) Filter(states ...string) []InstalledPackage {
+	ret := []InstalledPackage{}
+	for _, pkg := range s.Packages {
+		for _, state := range states {
+			if pkg.Status.State == state {
+				ret = append(ret, pkg)
+				break
+			}
+		}
+	}
+	return ret
+}
+
+// Installed returns the packages that are (at least partially) installed.
+func (s Status) Installed() []Package {
+	ret := []Package{}
+	for _, pkg := range s.Packages {
+		if pkg.Status.Installed() {
+			ret = append(ret, pkg.Package)
+		}
+	}
+	return ret
+}
+
+// Satisfies checks the relations against the configured packages, as dpkg
+// does for the Depends of a package being configured on a host of the
+// given architecture.
+func (s Status) Satisfies(dep dependency.Dependency, arch string) (*Satisfaction, error) {
+	configured := []Package{}
+	for _, pkg := range s.Packages {
+		if pkg.Status.Configured() {
+			configured = append(configured, pkg.Package)
+		}
+	}
+	return Satisfier{Packages: configured, Architecture: arch}.Check(dep)
+}
+
+// }}}
+
+// vim: foldmethod=marker
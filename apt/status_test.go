@@ -0,0 +1,103 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package apt_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ebikt/go-debian/apt"
+	"github.com/ebikt/go-debian/dependency"
+)
+
+/*
+ *
+ */
+
+const dpkgStatus = `Package: libc6
+Status: install ok installed
+Architecture: amd64
+Multi-Arch: same
+Version: 2.36-9
+
+Package: libc6
+Status: install ok installed
+Architecture: i386
+Multi-Arch: same
+Version: 2.36-9
+
+Package: hello
+Status: install ok unpacked
+Architecture: amd64
+Version: 2.10-3
+Config-Version: 2.10-2
+Depends: libc6 (>= 2.34)
+
+Package: removed
+Status: deinstall ok config-files
+Architecture: all
+Version: 1.0-1
+
+Package: tool
+Status: hold ok installed
+Architecture: amd64
+Version: 1.0-1
+`
+
+func TestStatus(t *testing.T) {
+	status, err := apt.ReadStatus(strings.NewReader(dpkgStatus))
+	isok(t, err)
+	assert(t, len(status.Packages) == 5)
+
+	hello := status.Lookup("hello")
+	assert(t, hello != nil)
+	assert(t, hello.Status.Want == "install" && hello.Status.State == apt.StateUnpacked)
+	assert(t, hello.Status.Installed() && !hello.Status.Configured())
+	assert(t, hello.ConfigVersion.String() == "2.10-2")
+	assert(t, hello.Version.String() == "2.10-3")
+
+	assert(t, status.Lookup("libc6") == nil)
+	libc := status.Lookup("libc6:i386")
+	assert(t, libc != nil && libc.Architecture == "i386")
+	assert(t, status.Lookup("missing") == nil)
+
+	assert(t, len(status.Filter(apt.StateUnpacked, apt.StateConfigFiles)) == 2)
+	assert(t, status.Filter(apt.StateInstalled)[2].Status.String() == "hold ok installed")
+	assert(t, len(status.Installed()) == 4)
+
+	dep, err := dependency.Parse("libc6 (>= 2.34), tool | hello")
+	isok(t, err)
+	satisfaction, err := status.Satisfies(*dep, "amd64")
+	isok(t, err)
+	assert(t, satisfaction.Satisfied())
+
+	/* Unpacked packages don't satisfy dependencies yet */
+	dep, err = dependency.Parse("hello")
+	isok(t, err)
+	satisfaction, err = status.Satisfies(*dep, "amd64")
+	isok(t, err)
+	assert(t, !satisfaction.Satisfied())
+
+	_, err = apt.ReadStatus(strings.NewReader("Package: hello\nStatus: installed\n"))
+	notok(t, err)
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,531 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package apt // import "github.com/ebikt/go-debian/apt"
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ebikt/go-debian/dependency"
+	"github.com/ebikt/go-debian/internal"
+	"github.com/ebikt/go-debian/version"
+)
+
+// Pin {{{
+
+// PinKind tells what a Pin selects the versions of the packages by, as the
+// "Pin:" line of apt_preferences(5) does.
+type PinKind int
+
+const (
+	// "Pin: release ...", the versions available from an Origin matching
+	// the OriginPattern.
+	PinRelease PinKind = iota
+
+	// "Pin: version ...", the versions matching the Version wildcard.
+	PinVersion
+
+	// "Pin: origin ...", the versions downloaded from the Site, the host
+	// name of the mirror; an empty Site is for local repositories.
+	PinOrigin
+)
+
+// A Pin assigns a priority to the versions of the packages matching the
+// Package field ("" or "*" for all of them), the same way a Pin-Priority
+// does in apt_preferences(5). Which versions are pinned depends on the
+// Kind, by default the ones available from an Origin matching the
+// OriginPattern.
+type Pin struct {
+	// Space separated names of the packages, which may be wildcards, or
+	// regular expressions between slashes, such as "/^linux-/"; and
+	// "src:name" for the binaries of a source package.
+	Package  string
+	Origin   OriginPattern
+	Priority int
+
+	Kind    PinKind
+	Version string
+	Site    string
+
+	Explanation string
+}
+
+// Generic tells whether the Pin applies to all packages; the priorities
+// of those only count when no specific Pin matches a version.
+func (p Pin) Generic() bool {
+	return p.Package == "" || p.Package == "*"
+}
+
+func matchRegexpOrGlob(pattern, value string) bool {
+	if len(pattern) > 1 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+		re, err := regexp.Compile(pattern[1 : len(pattern)-1])
+		return err == nil && re.MatchString(value)
+	}
+	return internal.GlobMatch(pattern, value)
+}
+
+func (p Pin) matchesName(pkg Package) bool {
+	if p.Generic() {
+		return true
+	}
+	for _, name := range strings.Fields(p.Package) {
+		if strings.HasPrefix(name, "src:") {
+			if matchRegexpOrGlob(name[4:], sourceName(pkg)) {
+				return true
+			}
+		} else if matchRegexpOrGlob(name, pkg.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+// sourceName returns the name of the source package of a Package.
+func sourceName(pkg Package) string {
+	if fields := strings.Fields(pkg.Source); len(fields) > 0 {
+		return fields[0]
+	}
+	return pkg.Name
+}
+
+// Matches checks if this Pin applies to the given Package.
+func (p Pin) Matches(pkg Package) bool {
+	if !p.matchesName(pkg) {
+		return false
+	}
+	switch p.Kind {
+	case PinVersion:
+		return matchRegexpOrGlob(p.Version, pkg.Version.String())
+	case PinOrigin:
+		for _, origin := range pkg.Origins {
+			if origin.Site == p.Site {
+				return true
+			}
+		}
+		return false
+	}
+	return p.Origin.MatchAny(pkg.Origins)
+}
+
+// String renders the Pin the way apt_preferences(5) would.
+func (p Pin) String() string {
+	name := p.Package
+	if name == "" {
+		name = "*"
+	}
+	pin := "release " + p.Origin.String()
+	switch p.Kind {
+	case PinVersion:
+		pin = "version " + p.Version
+	case PinOrigin:
+		pin = fmt.Sprintf("origin \"%s\"", p.Site)
+	}
+	return fmt.Sprintf("Package: %s, Pin: %s, Pin-Priority: %d", name, pin, p.Priority)
+}
+
+// }}}
+
+// UpgradeSelector {{{
+
+// UpgradeSelector decides which installed packages should be upgraded, and
+// to which version, restricted to the versions available from allowed
+// Origins. This is the decision core of unattended-upgrades.
+type UpgradeSelector struct {
+	// Only versions available from an Origin matching one of those are
+	// considered.
+	Allowed []OriginPattern
+
+	// Regular expressions, anchored at the start, of package names that
+	// must never be upgraded.
+	Blacklist []string
+
+	// Pins applied to the available versions, see Preferences.Priority.
+	// Without any, apt's default priorities are used.
+	Pins []Pin
+
+	// Hold back the phased updates this machine doesn't take part in yet,
+	// if set.
+	Phaser *Phaser
+}
+
+// NewUpgradeSelector creates an UpgradeSelector from the options of the
+// unattended-upgrades apt configuration: Unattended-Upgrade::Origins-Pattern,
+// Unattended-Upgrade::Allowed-Origins and Unattended-Upgrade::Package-Blacklist.
+// Macros such as ${distro_codename} are replaced by their values from the
+// `macros` map, keyed by name without the "${}".
+func NewUpgradeSelector(config *Config, macros map[string]string) (*UpgradeSelector, error) {
+	expand := func(in string) string {
+		for name, value := range macros {
+			in = strings.Replace(in, "${"+name+"}", value, -1)
+		}
+		return in
+	}
+
+	ret := UpgradeSelector{}
+	for _, el := range config.FindList("Unattended-Upgrade::Origins-Pattern") {
+		pattern, err := ParseOriginPattern(expand(el))
+		if err != nil {
+			return nil, err
+		}
+		ret.Allowed = append(ret.Allowed, *pattern)
+	}
+	for _, el := range config.FindList("Unattended-Upgrade::Allowed-Origins") {
+		/* The old "origin:archive" format */
+		el = expand(el)
+		i := strings.LastIndex(el, ":")
+		if i == -1 {
+			return nil, fmt.Errorf("Malformed Allowed-Origins entry: '%s'", el)
+		}
+		ret.Allowed = append(ret.Allowed, OriginPattern{Origin: el[:i], Archive: el[i+1:]})
+	}
+	for _, el := range config.FindList("Unattended-Upgrade::Package-Blacklist") {
+		ret.Blacklist = append(ret.Blacklist, expand(el))
+	}
+	return &ret, nil
+}
+
+// An Upgrade of an installed package to a new version.
+type Upgrade struct {
+	Package string
+	From    version.Version
+	To      Package
+}
+
+// A Kept upgrade, which was possible but has been held back.
+type Kept struct {
+	Package   string
+	Candidate Package
+	Reason    string
+}
+
+// UpgradePlan is the outcome of an UpgradeSelector run.
+type UpgradePlan struct {
+	Upgrades []Upgrade
+	Kept     []Kept
+}
+
+// Priority returns the pin priority of the given Package.
+func (s UpgradeSelector) Priority(pkg Package) int {
+	return Preferences{Pins: s.Pins}.Priority(pkg)
+}
+
+func (s UpgradeSelector) allowed(pkg Package) bool {
+	for _, pattern := range s.Allowed {
+		if pattern.MatchAny(pkg.Origins) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s UpgradeSelector) blacklisted(name string) (bool, error) {
+	for _, expr := range s.Blacklist {
+		re, err := regexp.Compile("^(?:" + expr + ")")
+		if err != nil {
+			return false, err
+		}
+		if re.MatchString(name) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Select computes the upgrades of the `installed` packages to versions
+// from `available`. For every installed package, the allowed version with
+// the highest priority (then the highest version) that is newer than the
+// installed one is picked; versions pinned below 0 are never picked.
+// Blacklisted packages, and phased updates not meant for this machine yet,
+// are held back.
+// Upgrades are then held back until every Depends and Pre-Depends of the
+// resulting set of packages is satisfied, and no Breaks or Conflicts is
+// hit, ignoring problems that already exist on the host. Packages that are
+// not installed yet are never pulled in, so an upgrade that needs a new
+// package is held back too.
+func (s UpgradeSelector) Select(installed, available []Package) (*UpgradePlan, error) {
+	plan := UpgradePlan{Upgrades: []Upgrade{}, Kept: []Kept{}}
+
+	current := map[string]Package{}
+	names := []string{}
+	for _, pkg := range installed {
+		current[pkg.Name] = pkg
+		names = append(names, pkg.Name)
+	}
+	sort.Strings(names)
+
+	candidates := map[string]Package{}
+	for _, pkg := range available {
+		old, ok := current[pkg.Name]
+		if !ok || version.Compare(pkg.Version, old.Version) <= 0 {
+			continue
+		}
+		priority := s.Priority(pkg)
+		if priority < 0 || !s.allowed(pkg) {
+			continue
+		}
+		best, ok := candidates[pkg.Name]
+		if ok {
+			bestPriority := s.Priority(best)
+			if priority < bestPriority {
+				continue
+			}
+			if priority == bestPriority && version.Compare(pkg.Version, best.Version) <= 0 {
+				continue
+			}
+		}
+		candidates[pkg.Name] = pkg
+	}
+
+	upgrades := map[string]Package{}
+	for _, name := range names {
+		candidate, ok := candidates[name]
+		if !ok {
+			continue
+		}
+		blacklisted, err := s.blacklisted(name)
+		if err != nil {
+			return nil, err
+		}
+		if blacklisted {
+			plan.Kept = append(plan.Kept, Kept{Package: name, Candidate: candidate, Reason: "blacklisted"})
+			continue
+		}
+		if s.Phaser != nil {
+			included, err := s.Phaser.Included(candidate)
+			if err != nil {
+				return nil, err
+			}
+			if !included {
+				plan.Kept = append(plan.Kept, Kept{Package: name, Candidate: candidate, Reason: "phased"})
+				continue
+			}
+		}
+		upgrades[name] = candidate
+	}
+
+	baseline := map[string]bool{}
+	for _, problem := range newWorld(current, nil).problems() {
+		baseline[problem.String()] = true
+	}
+
+	for {
+		w := newWorld(current, upgrades)
+		held := map[string]string{}
+		for _, problem := range w.problems() {
+			if baseline[problem.String()] {
+				continue
+			}
+			if _, ok := upgrades[problem.Package]; ok {
+				held[problem.Package] = problem.String()
+				continue
+			}
+			/* An installed package broke, blame the upgrades it relates to */
+			for _, name := range culprits(problem, current, upgrades) {
+				held[name] = problem.String()
+			}
+		}
+		if len(held) == 0 {
+			break
+		}
+		for name, reason := range held {
+			plan.Kept = append(plan.Kept, Kept{Package: name, Candidate: upgrades[name], Reason: reason})
+			delete(upgrades, name)
+		}
+	}
+
+	for _, name := range names {
+		if pkg, ok := upgrades[name]; ok {
+			plan.Upgrades = append(plan.Upgrades, Upgrade{Package: name, From: current[name].Version, To: pkg})
+		}
+	}
+	sort.Slice(plan.Kept, func(i, j int) bool { return plan.Kept[i].Package < plan.Kept[j].Package })
+	return &plan, nil
+}
+
+// }}}
+
+// world {{{
+
+// world is the set of packages that would be installed on the host, used
+// to check relations.
+type world struct {
+	packages map[string]Package
+	provides map[string][]provider
+}
+
+type provider struct {
+	Package string
+	Version *dependency.VersionRelation
+}
+
+type problem struct {
+	Package  string
+	Field    string
+	Relation dependency.Relation
+}
+
+func (p problem) String() string {
+	return fmt.Sprintf("%s %s: %s", p.Package, p.Field, p.Relation.String())
+}
+
+func newWorld(installed, upgrades map[string]Package) world {
+	w := world{packages: map[string]Package{}, provides: map[string][]provider{}}
+	for name, pkg := range installed {
+		if upgrade, ok := upgrades[name]; ok {
+			pkg = upgrade
+		}
+		w.packages[name] = pkg
+		for _, possi := range pkg.Provides.GetAllPossibilities() {
+			w.provides[possi.Name] = append(w.provides[possi.Name], provider{
+				Package: name,
+				Version: possi.Version,
+			})
+		}
+	}
+	return w
+}
+
+// providedSatisfies checks if a package providing the name of the
+// Possibility satisfies it: any Provides does for an unversioned relation,
+// and only a Provides of an "=" version satisfying it for a versioned one.
+func providedSatisfies(possi dependency.Possibility, provided *dependency.VersionRelation) bool {
+	if possi.Version == nil {
+		return true
+	}
+	if provided == nil || provided.Operator != "=" {
+		return false
+	}
+	providedVersion, err := version.Parse(provided.Number)
+	return err == nil && possi.Version.SatisfiedBy(providedVersion)
+}
+
+func (w world) satisfies(possi dependency.Possibility) bool {
+	if pkg, ok := w.packages[possi.Name]; ok {
+		if possi.Version == nil || possi.Version.SatisfiedBy(pkg.Version) {
+			return true
+		}
+	}
+	for _, provider := range w.provides[possi.Name] {
+		if providedSatisfies(possi, provider.Version) {
+			return true
+		}
+	}
+	return false
+}
+
+// hitBy returns the packages a Breaks or Conflicts alternative of the
+// package `self` hits, sorted: the package of that name, and the ones
+// providing it, as Debian Policy 7.6.2 has it. A package never hits
+// itself, which lets a package conflict with a virtual package it
+// provides, so that only one provider is installed at a time.
+func (w world) hitBy(self string, possi dependency.Possibility) []string {
+	ret := []string{}
+	if pkg, ok := w.packages[possi.Name]; ok && possi.Name != self {
+		if possi.Version == nil || possi.Version.SatisfiedBy(pkg.Version) {
+			ret = append(ret, possi.Name)
+		}
+	}
+	for _, provider := range w.provides[possi.Name] {
+		if provider.Package != self && provider.Package != possi.Name && providedSatisfies(possi, provider.Version) {
+			ret = append(ret, provider.Package)
+		}
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+func (w world) hits(self string, possi dependency.Possibility) bool {
+	return len(w.hitBy(self, possi)) > 0
+}
+
+func (w world) problems() []problem {
+	names := []string{}
+	for name := range w.packages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	ret := []problem{}
+	for _, name := range names {
+		pkg := w.packages[name]
+		for _, field := range []struct {
+			name string
+			dep  dependency.Dependency
+		}{{"Pre-Depends", pkg.PreDepends}, {"Depends", pkg.Depends}} {
+		relations:
+			for _, relation := range field.dep.Relations {
+				for _, possi := range relation.Possibilities {
+					if possi.Substvar || w.satisfies(possi) {
+						continue relations
+					}
+				}
+				ret = append(ret, problem{Package: name, Field: field.name, Relation: relation})
+			}
+		}
+		for _, field := range []struct {
+			name string
+			dep  dependency.Dependency
+		}{{"Breaks", pkg.Breaks}, {"Conflicts", pkg.Conflicts}} {
+			for _, relation := range field.dep.Relations {
+				for _, possi := range relation.Possibilities {
+					if !possi.Substvar && w.hits(name, possi) {
+						ret = append(ret, problem{Package: name, Field: field.name, Relation: relation})
+						break
+					}
+				}
+			}
+		}
+	}
+	return ret
+}
+
+// culprits returns the upgraded packages a problem of an installed package
+// relates to, either by name or through what they provide before or after
+// the upgrade.
+func culprits(p problem, installed, upgrades map[string]Package) []string {
+	ret := []string{}
+	for _, possi := range p.Relation.Possibilities {
+		for name, pkg := range upgrades {
+			old := installed[name]
+			provides := append(
+				pkg.Provides.GetAllPossibilities(),
+				old.Provides.GetAllPossibilities()...,
+			)
+			if name == possi.Name {
+				ret = append(ret, name)
+				continue
+			}
+			for _, provided := range provides {
+				if provided.Name == possi.Name {
+					ret = append(ret, name)
+					break
+				}
+			}
+		}
+	}
+	return ret
+}
+
+// }}}
+
+// vim: foldmethod=marker
@@ -0,0 +1,171 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package apt_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ebikt/go-debian/apt"
+	"github.com/ebikt/go-debian/dependency"
+	"github.com/ebikt/go-debian/version"
+)
+
+/*
+ *
+ */
+
+var (
+	debianSecurity = apt.Origin{Origin: "Debian", Label: "Debian-Security", Archive: "stable-security", Codename: "bookworm-security", Component: "main"}
+	debianStable   = apt.Origin{Origin: "Debian", Label: "Debian", Archive: "stable", Codename: "bookworm", Component: "main"}
+)
+
+func mkPackage(t *testing.T, name, ver, depends string, origins ...apt.Origin) apt.Package {
+	v, err := version.Parse(ver)
+	isok(t, err)
+	pkg := apt.Package{Name: name, Version: v, Origins: origins}
+	if depends != "" {
+		dep, err := dependency.Parse(depends)
+		isok(t, err)
+		pkg.Depends = *dep
+	}
+	return pkg
+}
+
+func TestUpgradeSelectorFromConfig(t *testing.T) {
+	config := parseConfig(t, `
+Unattended-Upgrade::Origins-Pattern {
+	"origin=Debian,codename=${distro_codename},label=Debian-Security";
+};
+Unattended-Upgrade::Allowed-Origins { "${distro_id}:${distro_codename}-updates"; };
+Unattended-Upgrade::Package-Blacklist { "linux-"; };
+`)
+	selector, err := apt.NewUpgradeSelector(config, map[string]string{
+		"distro_id":       "Debian",
+		"distro_codename": "bookworm",
+	})
+	isok(t, err)
+	assert(t, len(selector.Allowed) == 2)
+	assert(t, selector.Allowed[0].Codename == "bookworm")
+	assert(t, selector.Allowed[1].Origin == "Debian")
+	assert(t, selector.Allowed[1].Archive == "bookworm-updates")
+	assert(t, len(selector.Blacklist) == 1)
+}
+
+func TestUpgradeSelect(t *testing.T) {
+	security, err := apt.ParseOriginPattern("o=Debian,a=stable-security")
+	isok(t, err)
+	selector := apt.UpgradeSelector{
+		Allowed:   []apt.OriginPattern{*security},
+		Blacklist: []string{"linux-image"},
+	}
+
+	installed := []apt.Package{
+		mkPackage(t, "openssl", "3.0.11-1~deb12u1", "libssl3 (= 3.0.11-1~deb12u1)"),
+		mkPackage(t, "libssl3", "3.0.11-1~deb12u1", ""),
+		mkPackage(t, "curl", "7.88.1-10+deb12u4", ""),
+		mkPackage(t, "linux-image-amd64", "6.1.66-1", ""),
+		mkPackage(t, "vim", "2:9.0.1378-2", ""),
+	}
+	available := []apt.Package{
+		/* Both halves of openssl come from security, fine */
+		mkPackage(t, "openssl", "3.0.11-1~deb12u2", "libssl3 (= 3.0.11-1~deb12u2)", debianSecurity),
+		mkPackage(t, "libssl3", "3.0.11-1~deb12u2", "", debianSecurity),
+		/* curl needs a package that isn't installed */
+		mkPackage(t, "curl", "7.88.1-10+deb12u5", "libcurl4 (>= 7.88.1-10+deb12u5)", debianSecurity),
+		mkPackage(t, "linux-image-amd64", "6.1.69-1", "", debianSecurity),
+		/* Not from an allowed origin */
+		mkPackage(t, "vim", "2:9.0.1378-3", "", debianStable),
+	}
+
+	plan, err := selector.Select(installed, available)
+	isok(t, err)
+
+	assert(t, len(plan.Upgrades) == 2)
+	assert(t, plan.Upgrades[0].Package == "libssl3")
+	assert(t, plan.Upgrades[1].Package == "openssl")
+	assert(t, plan.Upgrades[1].To.Version.String() == "3.0.11-1~deb12u2")
+
+	assert(t, len(plan.Kept) == 2)
+	assert(t, plan.Kept[0].Package == "curl")
+	assert(t, strings.Contains(plan.Kept[0].Reason, "libcurl4"))
+	assert(t, plan.Kept[1].Package == "linux-image-amd64")
+	assert(t, plan.Kept[1].Reason == "blacklisted")
+}
+
+func TestUpgradeSelectHoldsBackTogether(t *testing.T) {
+	selector := apt.UpgradeSelector{
+		Allowed: []apt.OriginPattern{{Archive: "stable-security"}},
+	}
+
+	/* libssl3 is only in stable, so openssl can't be upgraded either */
+	installed := []apt.Package{
+		mkPackage(t, "openssl", "3.0.11-1~deb12u1", "libssl3 (= 3.0.11-1~deb12u1)"),
+		mkPackage(t, "libssl3", "3.0.11-1~deb12u1", ""),
+	}
+	available := []apt.Package{
+		mkPackage(t, "openssl", "3.0.11-1~deb12u2", "libssl3 (= 3.0.11-1~deb12u2)", debianSecurity),
+		mkPackage(t, "libssl3", "3.0.11-1~deb12u2", "", debianStable),
+	}
+	plan, err := selector.Select(installed, available)
+	isok(t, err)
+	assert(t, len(plan.Upgrades) == 0)
+	assert(t, len(plan.Kept) == 1)
+
+	/* Upgrading libssl3 alone would break the installed openssl */
+	installed[0], installed[1] = installed[1], installed[0]
+	available = []apt.Package{
+		mkPackage(t, "libssl3", "3.0.11-1~deb12u2", "", debianSecurity),
+	}
+	plan, err = selector.Select(installed, available)
+	isok(t, err)
+	assert(t, len(plan.Upgrades) == 0)
+	assert(t, len(plan.Kept) == 1)
+	assert(t, plan.Kept[0].Package == "libssl3")
+}
+
+func TestUpgradeSelectPins(t *testing.T) {
+	selector := apt.UpgradeSelector{
+		Allowed: []apt.OriginPattern{{Origin: "Debian"}},
+		Pins: []apt.Pin{
+			{Package: "vim*", Origin: apt.OriginPattern{Archive: "stable"}, Priority: -1},
+			{Origin: apt.OriginPattern{Archive: "stable-security"}, Priority: 990},
+		},
+	}
+
+	installed := []apt.Package{
+		mkPackage(t, "bash", "5.2.15-2", ""),
+		mkPackage(t, "vim", "2:9.0.1378-2", ""),
+	}
+	available := []apt.Package{
+		mkPackage(t, "bash", "5.2.15-2+b2", "", debianSecurity),
+		mkPackage(t, "bash", "5.2.15-3", "", debianStable),
+		mkPackage(t, "vim", "2:9.0.1378-3", "", debianStable),
+	}
+	plan, err := selector.Select(installed, available)
+	isok(t, err)
+	assert(t, len(plan.Upgrades) == 1)
+	assert(t, plan.Upgrades[0].Package == "bash")
+	assert(t, plan.Upgrades[0].To.Version.String() == "5.2.15-2+b2")
+	assert(t, selector.Priority(installed[0]) == 100)
+}
+
+// vim: foldmethod=marker
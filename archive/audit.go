@@ -0,0 +1,463 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package archive // import "github.com/ebikt/go-debian/archive"
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ebikt/go-debian/control"
+	"github.com/ebikt/go-debian/deb"
+)
+
+// Auditing {{{
+
+// The kinds of problems Audit finds.
+const (
+	// A file listed by a Release file or an index isn't there.
+	AuditMissing = "missing"
+
+	// A file doesn't have the size or checksum it's listed with.
+	AuditMismatch = "mismatch"
+
+	// A pool file isn't listed by any index.
+	AuditOrphan = "orphan"
+
+	// The by-hash link of an index is missing, or has the wrong contents.
+	AuditByHash = "by-hash"
+
+	// A Release file or an index can't be read.
+	AuditError = "error"
+)
+
+// An AuditFinding is a problem Audit found with a file of a mirror.
+type AuditFinding struct {
+	// One of AuditMissing, AuditMismatch, AuditOrphan, AuditByHash and
+	// AuditError.
+	Kind string
+
+	// Slash separated path of the file relative to the repository root,
+	// such as "dists/stable/main/binary-amd64/Packages.xz".
+	Path string
+
+	// Suite the file belongs to, empty for orphans.
+	Suite string
+
+	// What's wrong with the file, if there's more to say than the Kind.
+	Err error
+}
+
+func (f AuditFinding) String() string {
+	if f.Err == nil {
+		return fmt.Sprintf("%s: %s", f.Kind, f.Path)
+	}
+	return fmt.Sprintf("%s: %s: %s", f.Kind, f.Path, f.Err)
+}
+
+// AuditOptions tell Audit what to check.
+type AuditOptions struct {
+	// Suites to check; all the ones under dists/ if empty. Orphans are
+	// only looked for when checking all the suites.
+	Suites []string
+
+	// Only check that the pool files are there, with the right size,
+	// without reading them to check their checksums.
+	SizeOnly bool
+}
+
+// An AuditFunc is called by Audit for each problem found, as soon as it's
+// found. Returning an error stops the audit, which returns it.
+type AuditFunc func(finding AuditFinding) error
+
+// auditor holds the state of an Audit.
+type auditor struct {
+	ctx     context.Context
+	repo    *Repository
+	opts    AuditOptions
+	report  AuditFunc
+	checked map[string]bool
+
+	/* Whether an index couldn't be read or checked, making orphans
+	 * unknown */
+	failed bool
+}
+
+func (a *auditor) found(kind, rel, suite string, err error) error {
+	if kind == AuditError {
+		a.failed = true
+	}
+	return a.report(AuditFinding{Kind: kind, Path: rel, Suite: suite, Err: err})
+}
+
+// Audit checks a mirror, or any repository laid out like one: for each
+// suite, the files listed by its InRelease or Release file, and their
+// by-hash links when it has Acquire-By-Hash, then the pool files listed
+// by its Packages and Sources indexes. Then, the pool files no index
+// lists are looked for, unless some index couldn't be read, or is missing
+// or doesn't match the Release file, as a mirror being synced is. Problems
+// are passed to the AuditFunc as they are found; the error returned is
+// the one which stopped the audit, if any.
+func (r *Repository) Audit(ctx context.Context, opts AuditOptions, report AuditFunc) error {
+	suites := opts.Suites
+	if len(suites) == 0 {
+		var err error
+		if suites, err = r.Suites(); err != nil {
+			return err
+		}
+	}
+	a := auditor{ctx: ctx, repo: r, opts: opts, report: report, checked: map[string]bool{}}
+	for _, suite := range suites {
+		if err := a.auditSuite(suite); err != nil {
+			return err
+		}
+	}
+	if len(opts.Suites) == 0 && !a.failed {
+		return a.auditOrphans()
+	}
+	return nil
+}
+
+// suiteRelease reads the InRelease file of the suite, or else its
+// Release file.
+func (a *auditor) suiteRelease(suite string) (*Release, error) {
+	release, err := ParseReleaseFile(a.repo.path("dists/" + suite + "/InRelease"))
+	if os.IsNotExist(err) {
+		release, err = ParseReleaseFile(a.repo.path("dists/" + suite + "/Release"))
+	}
+	return release, err
+}
+
+// byHashNames are the names of the by-hash directories of the checksum
+// algorithms.
+var byHashNames = map[string]string{
+	"md5":    "MD5Sum",
+	"sha1":   "SHA1",
+	"sha256": "SHA256",
+	"sha512": "SHA512",
+}
+
+func (a *auditor) auditSuite(suite string) error {
+	dir := "dists/" + suite
+	release, err := a.suiteRelease(suite)
+	if err != nil {
+		return a.found(AuditError, dir+"/Release", suite, err)
+	}
+
+	files := release.Files()
+	names := []string{}
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	indexes := map[string]string{}
+	listed := map[string]bool{}
+	for _, name := range names {
+		if index := indexName(name); index != "" {
+			listed[index] = true
+		}
+		if err := a.ctx.Err(); err != nil {
+			return err
+		}
+		rel := dir + "/" + name
+		hashes := files[name]
+		if _, err := os.Stat(a.repo.path(rel)); os.IsNotExist(err) {
+			/* Release files list the uncompressed indexes which only
+			 * exist compressed, for apt to check what it decompresses */
+			if !a.hasVariant(dir, name, files) {
+				if err := a.found(AuditMissing, rel, suite, nil); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if err := verifyFile(a.repo.path(rel), hashes); err != nil {
+			if err := a.found(AuditMismatch, rel, suite, err); err != nil {
+				return err
+			}
+			continue
+		}
+		if release.AcquireByHash {
+			if err := a.auditByHash(dir, name, suite, hashes); err != nil {
+				return err
+			}
+		}
+		if index := indexName(name); index != "" {
+			if current, ok := indexes[index]; !ok || path.Ext(name) == "" || current > name {
+				indexes[index] = name
+			}
+		}
+	}
+
+	for index := range listed {
+		if _, ok := indexes[index]; !ok {
+			/* None of its flavors is there as listed: what it lists,
+			 * which may well be in the pool, isn't known */
+			a.failed = true
+		}
+	}
+
+	sorted := []string{}
+	for _, name := range indexes {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+	for _, name := range sorted {
+		if err := a.auditIndex(dir+"/"+name, suite); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// indexName returns the path of the Packages or Sources index the file
+// is a flavor of, without its compression extension, or "".
+func indexName(name string) string {
+	base := path.Base(name)
+	ext := path.Ext(base)
+	if isCompressionExt(ext) {
+		base = strings.TrimSuffix(base, ext)
+	}
+	for _, index := range indexNames {
+		if base == index {
+			return path.Join(path.Dir(name), base)
+		}
+	}
+	return ""
+}
+
+// hasVariant returns whether another flavor of the file listed by the
+// Release file, compressed differently, is there.
+func (a *auditor) hasVariant(dir, name string, files map[string]control.FileHashes) bool {
+	base := strings.TrimSuffix(name, path.Ext(name))
+	if !isCompressionExt(path.Ext(name)) {
+		base = name
+	}
+	for _, ext := range []string{"", ".gz", ".xz", ".bz2", ".lzma", ".zst"} {
+		if _, listed := files[base+ext]; !listed || base+ext == name {
+			continue
+		}
+		if _, err := os.Stat(a.repo.path(dir + "/" + base + ext)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// verifyFile checks the file against its checksums.
+func verifyFile(file string, hashes control.FileHashes) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return hashes.VerifyReader(f)
+}
+
+// auditByHash checks the by-hash links of a file listed by the Release
+// file: apt fetches the one of the strongest checksum, which has to be
+// there, while the other ones only need to be right.
+func (a *auditor) auditByHash(dir, name, suite string, hashes control.FileHashes) error {
+	strongest := ""
+	for _, algorithm := range []string{"md5", "sha1", "sha256", "sha512"} {
+		for _, hash := range hashes {
+			if hash.Algorithm == algorithm {
+				strongest = algorithm
+			}
+		}
+	}
+	for _, hash := range hashes {
+		hash.ByHash = byHashNames[hash.Algorithm]
+		if hash.ByHash == "" {
+			continue
+		}
+		rel := dir + "/" + hash.ByHashPath(name)
+		err := verifyFile(a.repo.path(rel), control.FileHashes{hash})
+		if os.IsNotExist(err) {
+			if hash.Algorithm != strongest {
+				continue
+			}
+			err = nil
+		} else if err == nil {
+			continue
+		}
+		if err := a.found(AuditByHash, rel, suite, err); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// auditIndex checks the pool files listed by a Packages or Sources
+// index, skipping the ones already checked.
+func (a *auditor) auditIndex(rel, suite string) error {
+	f, err := os.Open(a.repo.path(rel))
+	if err != nil {
+		return a.found(AuditError, rel, suite, err)
+	}
+	defer f.Close()
+	reader, err := deb.DecompressorFor(path.Ext(rel))(f)
+	if err != nil {
+		return a.found(AuditError, rel, suite, err)
+	}
+	paragraphs, err := control.NewParagraphReader(reader, nil)
+	if err != nil {
+		return a.found(AuditError, rel, suite, err)
+	}
+
+	for {
+		if err := a.ctx.Err(); err != nil {
+			return err
+		}
+		para, err := paragraphs.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return a.found(AuditError, rel, suite, err)
+		}
+		files, err := indexEntryHashes(*para)
+		if err != nil {
+			if err := a.found(AuditError, rel, suite, err); err != nil {
+				return err
+			}
+			continue
+		}
+		for _, hashes := range files {
+			if err := a.auditPoolFile(hashes, suite); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// indexEntryHashes returns the checksums of the files of an entry of a
+// Packages or Sources index, the strongest ones available.
+func indexEntryHashes(para control.Paragraph) ([]control.FileHashes, error) {
+	if filename := para.Get("Filename"); filename != "" {
+		hashes, err := poolHashes(path.Clean(filename), para)
+		if err != nil {
+			return nil, err
+		}
+		return []control.FileHashes{hashes}, nil
+	}
+	directory := para.Get("Directory")
+	if directory == "" {
+		return nil, nil
+	}
+	for _, field := range []struct{ key, algorithm string }{
+		{"Checksums-Sha512", "sha512"},
+		{"Checksums-Sha256", "sha256"},
+		{"Checksums-Sha1", "sha1"},
+		{"Files", "md5"},
+	} {
+		value := para.Get(field.key)
+		if value == "" {
+			continue
+		}
+		ret := []control.FileHashes{}
+		for _, line := range strings.Split(value, "\n") {
+			fields := strings.Fields(line)
+			if len(fields) == 0 {
+				continue
+			}
+			if len(fields) != 3 {
+				return nil, fmt.Errorf("Invalid %s line of %s: '%s'", field.key, para.Get("Package"), line)
+			}
+			size, err := strconv.ParseInt(fields[1], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid size in %s of %s: '%s'", field.key, para.Get("Package"), line)
+			}
+			ret = append(ret, control.FileHashes{{
+				Algorithm: field.algorithm,
+				Hash:      fields[0],
+				Size:      size,
+				Filename:  path.Join(directory, fields[2]),
+			}})
+		}
+		return ret, nil
+	}
+	return nil, fmt.Errorf("No checksum for the files of %s", para.Get("Package"))
+}
+
+func (a *auditor) auditPoolFile(hashes control.FileHashes, suite string) error {
+	rel := hashes[0].Filename
+	if a.checked[rel] {
+		return nil
+	}
+	a.checked[rel] = true
+
+	info, err := os.Stat(a.repo.path(rel))
+	if os.IsNotExist(err) {
+		return a.found(AuditMissing, rel, suite, nil)
+	} else if err != nil {
+		return a.found(AuditError, rel, suite, err)
+	}
+	if a.opts.SizeOnly {
+		if info.Size() != hashes[0].Size {
+			err = fmt.Errorf("Size mismatch for %s: got %d, want %d", rel, info.Size(), hashes[0].Size)
+		}
+	} else {
+		err = verifyFile(a.repo.path(rel), hashes)
+	}
+	if err != nil {
+		return a.found(AuditMismatch, rel, suite, err)
+	}
+	return nil
+}
+
+// auditOrphans reports the pool files no index listed.
+func (a *auditor) auditOrphans() error {
+	pool := a.repo.path("pool")
+	err := filepath.Walk(pool, func(p string, info os.FileInfo, err error) error {
+		if os.IsNotExist(err) && p == pool {
+			return filepath.SkipDir
+		} else if err != nil {
+			return err
+		}
+		if err := a.ctx.Err(); err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(a.repo.Root, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if a.checked[rel] {
+			return nil
+		}
+		return a.found(AuditOrphan, rel, "", nil)
+	})
+	return err
+}
+
+// }}}
+
+// vim: foldmethod=marker
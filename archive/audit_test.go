@@ -0,0 +1,146 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package archive_test
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/ebikt/go-debian/archive"
+	"github.com/ebikt/go-debian/testutil"
+)
+
+/*
+ *
+ */
+
+func auditMirror(t *testing.T) (string, *archive.Repository) {
+	root := t.TempDir()
+	repo, err := archive.NewRepository(root)
+	isok(t, err)
+	for _, d := range []testutil.Deb{
+		{Package: "hello", Version: "2.10-3", Architecture: "amd64"},
+		{Package: "hello-doc", Version: "2.10-3", Source: "hello"},
+	} {
+		data, err := d.Build()
+		isok(t, err)
+		writeFile(t, root, d.PoolPath("main"), string(data))
+	}
+	orig := "hello\n"
+	writeFile(t, root, "pool/main/h/hello/hello_2.10-3.dsc", fmt.Sprintf(`Format: 3.0 (quilt)
+Source: hello
+Binary: hello
+Architecture: any
+Version: 2.10-3
+Maintainer: Santiago Vila <sanvila@debian.org>
+Checksums-Sha256:
+ %x %d hello_2.10.orig.tar.xz
+Files:
+ %x %d hello_2.10.orig.tar.xz
+`, sha256.Sum256([]byte(orig)), len(orig), md5.Sum([]byte(orig)), len(orig)))
+	writeFile(t, root, "pool/main/h/hello/hello_2.10.orig.tar.xz", orig)
+
+	publisher := archive.Publisher{
+		Repository:   repo,
+		Suite:        "stable",
+		Compressions: []archive.Compression{{Extension: "gz"}},
+		ByHash:       true,
+	}
+	_, err = publisher.Publish()
+	isok(t, err)
+	return root, repo
+}
+
+func audit(t *testing.T, repo *archive.Repository, opts archive.AuditOptions) []string {
+	ret := []string{}
+	isok(t, repo.Audit(context.Background(), opts, func(finding archive.AuditFinding) error {
+		ret = append(ret, fmt.Sprintf("%s %s", finding.Kind, finding.Path))
+		return nil
+	}))
+	sort.Strings(ret)
+	return ret
+}
+
+func TestAuditClean(t *testing.T) {
+	_, repo := auditMirror(t)
+	findings := audit(t, repo, archive.AuditOptions{})
+	assert(t, len(findings) == 0)
+}
+
+func TestAudit(t *testing.T) {
+	root, repo := auditMirror(t)
+
+	/* A pool file changed, another one gone, and one nobody lists */
+	deb := testutil.Deb{Package: "hello", Version: "2.10-3", Architecture: "amd64"}
+	writeFile(t, root, deb.PoolPath("main"), "corrupted")
+	isok(t, os.Remove(filepath.Join(root, "pool/main/h/hello/hello_2.10.orig.tar.xz")))
+	writeFile(t, root, "pool/main/h/hello/hello_2.9-1.dsc", "Source: hello\n")
+
+	/* A by-hash link gone */
+	byHash, err := filepath.Glob(filepath.Join(root, "dists/stable/main/binary-amd64/by-hash/SHA256/*"))
+	isok(t, err)
+	assert(t, len(byHash) == 1)
+	isok(t, os.Remove(byHash[0]))
+
+	findings := audit(t, repo, archive.AuditOptions{})
+	assert(t, strings.Join(findings, "\n") == strings.Join([]string{
+		"by-hash dists/stable/main/binary-amd64/by-hash/SHA256/" + filepath.Base(byHash[0]),
+		"mismatch " + deb.PoolPath("main"),
+		"missing pool/main/h/hello/hello_2.10.orig.tar.xz",
+		"orphan pool/main/h/hello/hello_2.9-1.dsc",
+	}, "\n"))
+
+	/* What a changed index lists isn't known, so neither are orphans */
+	writeFile(t, root, "dists/stable/main/source/Sources.gz", "corrupted")
+	findings = audit(t, repo, archive.AuditOptions{})
+	assert(t, strings.Join(findings, "\n") == strings.Join([]string{
+		"by-hash dists/stable/main/binary-amd64/by-hash/SHA256/" + filepath.Base(byHash[0]),
+		"mismatch dists/stable/main/source/Sources.gz",
+		"mismatch " + deb.PoolPath("main"),
+	}, "\n"))
+
+	/* Only the size is checked, and no orphans looked for */
+	findings = audit(t, repo, archive.AuditOptions{Suites: []string{"stable"}, SizeOnly: true})
+	for _, finding := range findings {
+		assert(t, !strings.HasPrefix(finding, "orphan "))
+	}
+
+	/* Stopping early */
+	stop := fmt.Errorf("Enough")
+	err = repo.Audit(context.Background(), archive.AuditOptions{}, func(archive.AuditFinding) error {
+		return stop
+	})
+	assert(t, err == stop)
+
+	/* A suite without a Release file */
+	isok(t, os.MkdirAll(filepath.Join(root, "dists/unstable"), 0755))
+	findings = audit(t, repo, archive.AuditOptions{Suites: []string{"unstable"}})
+	assert(t, len(findings) == 1 && findings[0] == "error dists/unstable/Release")
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,293 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package archive // import "github.com/ebikt/go-debian/archive"
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"time"
+
+	"golang.org/x/crypto/openpgp"
+
+	"github.com/ebikt/go-debian/control"
+	"github.com/ebikt/go-debian/hashio"
+)
+
+// Repository client {{{
+
+// SourceEntry describes a suite of a remote repository, as a "deb" line of
+// sources.list does.
+type SourceEntry struct {
+	// URL of the root of the repository, such as
+	// "https://deb.debian.org/debian".
+	URI string
+
+	// Suite (or codename), such as "stable" or "bookworm".
+	Suite string
+
+	// Components and architectures to use; all the ones of the Release
+	// file if empty.
+	Components    []string
+	Architectures []string
+}
+
+// Client reads the indexes of a suite of a remote repository, the way apt
+// does: the InRelease file is fetched and its signature checked, then the
+// indexes are fetched, checked against the Release file, and decompressed.
+type Client struct {
+	Entry SourceEntry
+
+	// Where the files are fetched from; the one NewFetcher returns for
+	// the URI of the Entry if nil.
+	Fetcher Fetcher
+
+	// If set, the InRelease file (or else the Release file, along with
+	// Release.gpg) must be signed by a key of this keyring.
+	Keyring *openpgp.EntityList
+
+	// If set, checks the signatures instead of the Keyring, such as a
+	// control.GpgvVerifier.
+	Verifier control.Verifier
+
+	// Release file of the suite, as fetched by the last Update.
+	Release *Release
+
+	// If set, told about each file downloaded, by its path relative to
+	// the URI of the Entry.
+	Progress hashio.Progress
+}
+
+// NewClient creates a Client fetching the files of the entry from its
+// URI, see NewFetcher.
+func NewClient(entry SourceEntry, keyring *openpgp.EntityList) *Client {
+	return &Client{Entry: entry, Keyring: keyring}
+}
+
+// Components returns the components of the suite to use: the ones of the
+// Entry, or else all the ones of the Release file.
+func (c *Client) Components() []string {
+	if len(c.Entry.Components) > 0 || c.Release == nil {
+		return c.Entry.Components
+	}
+	return c.Release.Components
+}
+
+// Architectures returns the architectures of the suite to use: the ones of
+// the Entry, or else all the ones of the Release file.
+func (c *Client) Architectures() []string {
+	if len(c.Entry.Architectures) > 0 || c.Release == nil {
+		return c.Entry.Architectures
+	}
+	ret := []string{}
+	for _, arch := range c.Release.Architectures {
+		ret = append(ret, arch.String())
+	}
+	return ret
+}
+
+func (c *Client) suitePath(name string) string {
+	return "dists/" + c.Entry.Suite + "/" + name
+}
+
+// Update fetches the InRelease file of the suite, or else its Release
+// file, checks its signature when there's a Keyring, and that it hasn't
+// expired.
+func (c *Client) Update(ctx context.Context) (*Release, error) {
+	if c.Fetcher == nil {
+		fetcher, err := NewFetcher(ctx, c.Entry.URI, nil)
+		if err != nil {
+			return nil, err
+		}
+		c.Fetcher = fetcher
+	}
+	release, err := c.fetchRelease(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if release.Expired(time.Now()) {
+		return nil, fmt.Errorf("The Release file of %s expired on %s", c.Entry.Suite, release.ValidUntil)
+	}
+	c.Release = release
+	return release, nil
+}
+
+// fetch reads a whole file through the Fetcher, of the given size if
+// known, telling the Progress about it.
+func (c *Client) fetch(ctx context.Context, path string, size int64) ([]byte, error) {
+	if c.Progress == nil {
+		return fetchAll(ctx, c.Fetcher, path)
+	}
+	c.Progress.Start(path, size)
+	body, err := c.Fetcher.Fetch(ctx, path)
+	var data []byte
+	if err == nil {
+		data, err = ioutil.ReadAll(hashio.NewContextReader(ctx, hashio.NewProgressReader(body, c.Progress, path)))
+		body.Close()
+	}
+	c.Progress.Done(path, err)
+	return data, err
+}
+
+// verifier returns what checks the signatures, nil if nothing does. The
+// expired and revoked keys of the Keyring aren't allowed to sign, and a
+// *KeyError tells when none is left.
+func (c *Client) verifier() (control.Verifier, error) {
+	if c.Verifier != nil {
+		return c.Verifier, nil
+	}
+	if c.Keyring != nil {
+		keyring, err := UsableKeys(*c.Keyring, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		return control.KeyringVerifier{Keyring: keyring}, nil
+	}
+	return nil, nil
+}
+
+func (c *Client) fetchRelease(ctx context.Context) (*Release, error) {
+	verifier, err := c.verifier()
+	if err != nil {
+		return nil, err
+	}
+	data, err := c.fetch(ctx, c.suitePath("InRelease"), -1)
+	if err == nil {
+		var cleartext []byte
+		if verifier == nil {
+			cleartext, _, err = control.DecodeClearsigned(bytes.NewReader(data), nil)
+		} else {
+			cleartext, err = control.DecodeVerifiedContext(ctx, bytes.NewReader(data), verifier)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return ParseRelease(bytes.NewReader(cleartext))
+	}
+
+	data, err = c.fetch(ctx, c.suitePath("Release"), -1)
+	if err != nil {
+		return nil, err
+	}
+	if verifier != nil {
+		signature, err := c.fetch(ctx, c.suitePath("Release.gpg"), -1)
+		if err != nil {
+			return nil, err
+		}
+		if err := control.VerifyDetachedContext(ctx, verifier, data, signature); err != nil {
+			return nil, err
+		}
+	}
+	return ParseRelease(bytes.NewReader(data))
+}
+
+// Preference order of the variants of the indexes.
+var indexExtensions = []string{".xz", ".gz", "", ".zst", ".bz2"}
+
+// fetchVerified fetches the file `name` of the suite, which has the given
+// checksums, from its by-hash path when the suite has them, falling back
+// on its usual path like apt does.
+func (c *Client) fetchVerified(ctx context.Context, name string, hashes control.FileHashes) ([]byte, error) {
+	paths := []string{}
+	if c.Release.AcquireByHash {
+		/* The strongest checksums come last */
+		for j := len(hashes) - 1; j >= 0; j-- {
+			if hashes[j].ByHash != "" {
+				paths = append(paths, c.suitePath(hashes[j].ByHashPath(name)))
+				break
+			}
+		}
+	}
+	paths = append(paths, c.suitePath(name))
+
+	var err error
+	for _, candidate := range paths {
+		var data []byte
+		if data, err = c.fetch(ctx, candidate, hashes[0].Size); err != nil {
+			continue
+		}
+		if err = hashes.VerifyReader(bytes.NewReader(data)); err != nil {
+			continue
+		}
+		return data, nil
+	}
+	return nil, err
+}
+
+// FetchIndex fetches the index `name` of the suite, relative to its
+// directory, such as "main/binary-amd64/Packages", picking the best of its
+// compressed variants listed in the Release file. The index is checked
+// against the Release file, and returned decompressed. Update must have
+// been called first.
+func (c *Client) FetchIndex(ctx context.Context, name string) (io.Reader, error) {
+	if c.Release == nil {
+		return nil, fmt.Errorf("No Release file for %s, Update first", c.Entry.Suite)
+	}
+	files := c.Release.Files()
+	for _, ext := range indexExtensions {
+		hashes, ok := files[name+ext]
+		if !ok {
+			continue
+		}
+		data, err := c.fetchVerified(ctx, name+ext, hashes)
+		if err != nil {
+			return nil, err
+		}
+		/* Reading the index stops along with the context */
+		return hashio.NewDecompressingReader(hashio.NewContextReader(ctx, bytes.NewReader(data)))
+	}
+	return nil, fmt.Errorf("%s is not listed in the Release file of %s", name, c.Entry.Suite)
+}
+
+// Packages returns a reader of the Packages index of the component and
+// architecture.
+func (c *Client) Packages(ctx context.Context, component, arch string) (*control.BinaryIndexReader, error) {
+	index, err := c.FetchIndex(ctx, PackagesIndex(component, arch, false))
+	if err != nil {
+		return nil, err
+	}
+	return control.NewBinaryIndexReader(index)
+}
+
+// InstallerPackages returns a reader of the Packages index of the udebs of
+// the component and architecture, the debian-installer one.
+func (c *Client) InstallerPackages(ctx context.Context, component, arch string) (*control.BinaryIndexReader, error) {
+	index, err := c.FetchIndex(ctx, PackagesIndex(component, arch, true))
+	if err != nil {
+		return nil, err
+	}
+	return control.NewBinaryIndexReader(index)
+}
+
+// Sources returns a reader of the Sources index of the component.
+func (c *Client) Sources(ctx context.Context, component string) (*control.SourceIndexReader, error) {
+	index, err := c.FetchIndex(ctx, component+"/source/Sources")
+	if err != nil {
+		return nil, err
+	}
+	return control.NewSourceIndexReader(index)
+}
+
+// }}}
+
+// vim: foldmethod=marker
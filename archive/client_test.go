@@ -0,0 +1,235 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package archive_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/openpgp/packet"
+
+	"github.com/ebikt/go-debian/archive"
+	"github.com/ebikt/go-debian/control"
+	"github.com/ebikt/go-debian/testutil"
+)
+
+/*
+ *
+ */
+
+func gzipped(t *testing.T, data string) []byte {
+	out := bytes.Buffer{}
+	w := gzip.NewWriter(&out)
+	_, err := w.Write([]byte(data))
+	isok(t, err)
+	isok(t, w.Close())
+	return out.Bytes()
+}
+
+const clientSources = `Package: foo
+Version: 1.0-1
+Binary: foo
+Directory: pool/main/f/foo
+`
+
+func clientRepository(t *testing.T, suite testutil.Suite) (*testutil.Repository, *archive.Client) {
+	key, err := testutil.NewKey("Client", "client@example.com")
+	isok(t, err)
+	repo := testutil.NewRepository(key)
+	isok(t, repo.AddSuite(suite))
+	client := archive.NewClient(archive.SourceEntry{
+		URI:   repo.Start(),
+		Suite: suite.Suite,
+	}, testutil.Keyring(key))
+	return repo, client
+}
+
+func testSuite() testutil.Suite {
+	return testutil.Suite{
+		Suite:         "stable",
+		Architectures: []string{"amd64", "arm64"},
+		Components:    []string{"main"},
+		Indexes:       map[string][]byte{},
+	}
+}
+
+func TestClient(t *testing.T) {
+	suite := testSuite()
+	suite.AcquireByHash = true
+	suite.Indexes["main/binary-amd64/Packages.gz"] = gzipped(t, fooPackages)
+	suite.Indexes["main/source/Sources"] = []byte(clientSources)
+	repo, client := clientRepository(t, suite)
+	defer repo.Close()
+	/* Only the by-hash paths are left */
+	repo.RemoveFile("dists/stable/main/binary-amd64/Packages.gz")
+
+	ctx := context.Background()
+	_, err := client.FetchIndex(ctx, "main/binary-amd64/Packages")
+	notok(t, err)
+
+	release, err := client.Update(ctx)
+	isok(t, err)
+	assert(t, release.AcquireByHash)
+	assert(t, len(client.Components()) == 1)
+	assert(t, len(client.Architectures()) == 2)
+
+	packages, err := client.Packages(ctx, "main", "amd64")
+	isok(t, err)
+	entry, err := packages.Next()
+	isok(t, err)
+	assert(t, entry.Package == "foo")
+	assert(t, entry.Version.String() == "1.0-1")
+	_, err = packages.Next()
+	assert(t, err == io.EOF)
+
+	sources, err := client.Sources(ctx, "main")
+	isok(t, err)
+	source, err := sources.Next()
+	isok(t, err)
+	assert(t, source.Package == "foo")
+
+	_, err = client.Packages(ctx, "main", "arm64")
+	notok(t, err)
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = client.Update(cancelled)
+	assert(t, errors.Is(err, context.Canceled))
+	_, err = client.FetchIndex(cancelled, "main/source/Sources")
+	assert(t, errors.Is(err, context.Canceled))
+}
+
+// recordedProgress records the calls to a hashio.Progress.
+type recordedProgress struct {
+	events []string
+	bytes  map[string]int64
+}
+
+func (p *recordedProgress) Start(item string, size int64) {
+	p.events = append(p.events, fmt.Sprintf("start %s %d", item, size))
+}
+
+func (p *recordedProgress) Advance(item string, n int64) {
+	if p.bytes == nil {
+		p.bytes = map[string]int64{}
+	}
+	p.bytes[item] += n
+}
+
+func (p *recordedProgress) Done(item string, err error) {
+	p.events = append(p.events, fmt.Sprintf("done %s %v", item, err))
+}
+
+func TestClientProgress(t *testing.T) {
+	suite := testSuite()
+	packages := gzipped(t, fooPackages)
+	suite.Indexes["main/binary-amd64/Packages.gz"] = packages
+	repo, client := clientRepository(t, suite)
+	defer repo.Close()
+	progress := recordedProgress{}
+	client.Progress = &progress
+
+	ctx := context.Background()
+	_, err := client.Update(ctx)
+	isok(t, err)
+	_, err = client.FetchIndex(ctx, "main/binary-amd64/Packages")
+	isok(t, err)
+	index := "dists/stable/main/binary-amd64/Packages.gz"
+	assert(t, len(progress.events) == 4)
+	assert(t, progress.events[0] == "start dists/stable/InRelease -1")
+	assert(t, progress.events[2] == fmt.Sprintf("start %s %d", index, len(packages)))
+	assert(t, progress.events[3] == "done "+index+" <nil>")
+	assert(t, progress.bytes[index] == int64(len(packages)))
+}
+
+func TestClientTampered(t *testing.T) {
+	suite := testSuite()
+	suite.Indexes["main/binary-amd64/Packages"] = []byte(fooPackages)
+	repo, client := clientRepository(t, suite)
+	defer repo.Close()
+	repo.AddFile("dists/stable/main/binary-amd64/Packages", []byte(fooNewPackages))
+
+	ctx := context.Background()
+	_, err := client.Update(ctx)
+	isok(t, err)
+	_, err = client.Packages(ctx, "main", "amd64")
+	notok(t, err)
+}
+
+func TestClientExpired(t *testing.T) {
+	suite := testSuite()
+	suite.ValidUntil = time.Now().Add(-time.Hour)
+	repo, client := clientRepository(t, suite)
+	defer repo.Close()
+
+	_, err := client.Update(context.Background())
+	notok(t, err)
+	assert(t, client.Release == nil)
+}
+
+func TestClientWrongKey(t *testing.T) {
+	repo, client := clientRepository(t, testSuite())
+	defer repo.Close()
+	other, err := testutil.NewKey("Other", "other@example.com")
+	isok(t, err)
+	client.Keyring = testutil.Keyring(other)
+
+	_, err = client.Update(context.Background())
+	notok(t, err)
+}
+
+func TestClientRevokedKey(t *testing.T) {
+	repo, client := clientRepository(t, testSuite())
+	defer repo.Close()
+	key := (*client.Keyring)[0]
+	key.Revocations = append(key.Revocations, &packet.Signature{})
+
+	_, err := client.Update(context.Background())
+	keyErr := &archive.KeyError{}
+	assert(t, errors.As(err, &keyErr))
+	assert(t, len(keyErr.Keys) == 1 && keyErr.Keys[0].Revoked)
+}
+
+func TestClientDetachedSignature(t *testing.T) {
+	suite := testSuite()
+	suite.Indexes["main/binary-amd64/Packages"] = []byte(fooPackages)
+	repo, client := clientRepository(t, suite)
+	defer repo.Close()
+	repo.RemoveFile("dists/stable/InRelease")
+	client.Verifier = control.KeyringVerifier{Keyring: *client.Keyring}
+	client.Keyring = nil
+
+	ctx := context.Background()
+	_, err := client.Update(ctx)
+	isok(t, err)
+
+	repo.AddFile("dists/stable/Release", append(suite.Release(), "Label: Tampered\n"...))
+	_, err = client.Update(ctx)
+	notok(t, err)
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,259 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package archive // import "github.com/ebikt/go-debian/archive"
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/ebikt/go-debian/control"
+	"github.com/ebikt/go-debian/hashio"
+)
+
+// Compressed indexes {{{
+
+// A Compression is one of the variants an index is published as, such as
+// "xz" for Packages.xz. The Extension "" is the uncompressed index. Level
+// is the compression level, 0 being the default one, see
+// hashio.GetCompressorLevel.
+type Compression struct {
+	Extension string
+	Level     int
+}
+
+// compressedFile is one variant being written by a CompressedWriter, by
+// its own goroutine, which hashes it too.
+type compressedFile struct {
+	path       string
+	tmp        string
+	file       *os.File
+	compressor io.WriteCloser
+	writer     io.Writer
+	hashers    []*hashio.Hasher
+	data       chan []byte
+	err        error
+}
+
+func (f *compressedFile) run(data chan []byte, wg *sync.WaitGroup) {
+	defer wg.Done()
+	for data := range data {
+		if f.err != nil {
+			continue
+		}
+		_, f.err = f.writer.Write(data)
+	}
+	if f.compressor != nil {
+		if err := f.compressor.Close(); f.err == nil {
+			f.err = err
+		}
+	}
+	if err := f.file.Close(); f.err == nil {
+		f.err = err
+	}
+}
+
+// CompressedWriter writes an index to all of its compressed variants at
+// once, compressing them concurrently from a single pass over the data,
+// rather than compressing the uncompressed index once per variant. It's
+// meant to be the output of an IndexWriter.
+//
+// Each variant may be hashed as it's written too, for its checksums to be
+// listed by the Release file, and its by-hash links made, without reading
+// it back.
+type CompressedWriter struct {
+	files  []*compressedFile
+	wg     sync.WaitGroup
+	closed bool
+	err    error
+}
+
+// NewCompressedWriter creates the variants of the index at `path` ("path"
+// itself, "path.gz", "path.xz", ...) and starts compressing them.
+func NewCompressedWriter(path string, compressions ...Compression) (*CompressedWriter, error) {
+	return NewHashingCompressedWriter(path, nil, compressions...)
+}
+
+// NewHashingCompressedWriter is like NewCompressedWriter, but each variant
+// is hashed with the hashio algorithms too, such as "md5" and "sha256", by
+// the goroutine compressing it; see Checksums.
+func NewHashingCompressedWriter(path string, hashes []string, compressions ...Compression) (*CompressedWriter, error) {
+	ret := CompressedWriter{}
+	for _, compression := range compressions {
+		filePath := path
+		if compression.Extension != "" {
+			filePath += "." + compression.Extension
+		}
+		f := compressedFile{path: filePath, data: make(chan []byte, 16)}
+
+		var compressor hashio.Compressor
+		if compression.Extension != "" {
+			var err error
+			if compressor, err = hashio.GetCompressorLevel(compression.Extension, compression.Level); err != nil {
+				ret.abort()
+				return nil, err
+			}
+		}
+		file, err := ioutil.TempFile(filepath.Dir(filePath), "."+filepath.Base(filePath)+".")
+		if err != nil {
+			ret.abort()
+			return nil, err
+		}
+		f.tmp, f.file, f.writer = file.Name(), file, file
+		ret.files = append(ret.files, &f)
+		if err := file.Chmod(0644); err != nil {
+			file.Close()
+			ret.abort()
+			return nil, err
+		}
+		if len(hashes) > 0 {
+			if f.writer, f.hashers, err = hashio.NewHasherWriters(hashes, file); err != nil {
+				file.Close()
+				ret.abort()
+				return nil, err
+			}
+		}
+		if compressor != nil {
+			if f.compressor, err = compressor(f.writer); err != nil {
+				file.Close()
+				ret.abort()
+				return nil, err
+			}
+			f.writer = f.compressor
+		}
+
+		ret.wg.Add(1)
+		go f.run(f.data, &ret.wg)
+	}
+	return &ret, nil
+}
+
+// stop lets the goroutines finish writing the variants.
+func (w *CompressedWriter) stop() {
+	w.closed = true
+	for _, f := range w.files {
+		if f.data != nil {
+			close(f.data)
+			f.data = nil
+		}
+	}
+	w.wg.Wait()
+}
+
+// abort stops the goroutines and removes the variants written so far,
+// none of which is renamed into place.
+func (w *CompressedWriter) abort() {
+	w.stop()
+	for _, f := range w.files {
+		os.Remove(f.tmp)
+	}
+}
+
+// Write hands the data over to every variant. Errors of the compressors
+// are reported by Close.
+func (w *CompressedWriter) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, fmt.Errorf("Write to a closed CompressedWriter")
+	}
+	data := make([]byte, len(p))
+	copy(data, p)
+	for _, f := range w.files {
+		f.data <- data
+	}
+	return len(p), nil
+}
+
+// Close finishes writing all the variants, which are written to temporary
+// files, and renames them into place; if any of them hit an error, none
+// is, and the first error is returned.
+func (w *CompressedWriter) Close() error {
+	if w.closed {
+		return w.err
+	}
+	w.stop()
+	for _, f := range w.files {
+		if f.err != nil {
+			w.err = f.err
+			break
+		}
+	}
+	for _, f := range w.files {
+		if w.err == nil {
+			w.err = os.Rename(f.tmp, f.path)
+		}
+		if w.err != nil {
+			os.Remove(f.tmp)
+		}
+	}
+	return w.err
+}
+
+// Paths returns the paths of the variants, in the order of the
+// Compressions.
+func (w *CompressedWriter) Paths() []string {
+	ret := []string{}
+	for _, f := range w.files {
+		ret = append(ret, f.path)
+	}
+	return ret
+}
+
+// Checksums returns the checksums of the variants, in the order of the
+// Compressions, once the writer is closed. They're named after the paths
+// of the variants, and have the ByHash directory of their algorithm set.
+func (w *CompressedWriter) Checksums() []control.FileHashes {
+	ret := []control.FileHashes{}
+	for _, f := range w.files {
+		hashes := control.FileHashes{}
+		for _, hasher := range f.hashers {
+			hash := control.FileHashFromHasher(f.path, *hasher)
+			hash.ByHash = byHashNames[hash.Algorithm]
+			hashes = append(hashes, hash)
+		}
+		ret = append(ret, hashes)
+	}
+	return ret
+}
+
+// LinkByHash links each variant under its by-hash paths, such as
+// "by-hash/SHA256/<sha256>" next to it, for each of the algorithms it was
+// hashed with, once the writer is closed.
+func (w *CompressedWriter) LinkByHash() error {
+	for _, hashes := range w.Checksums() {
+		for _, hash := range hashes {
+			dest := filepath.Join(filepath.Dir(hash.Filename), "by-hash", hash.ByHash, hash.Hash)
+			if _, err := os.Stat(dest); err == nil {
+				continue
+			}
+			if err := linkFile(hash.Filename, dest); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// }}}
+
+// vim: foldmethod=marker
@@ -0,0 +1,139 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package archive_test
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ebikt/go-debian/archive"
+	"github.com/ebikt/go-debian/deb"
+)
+
+/*
+ *
+ */
+
+func TestCompressedWriter(t *testing.T) {
+	index := ""
+	for i := 0; i < 2000; i++ {
+		index += fmt.Sprintf("Package: pkg%d\nVersion: 1.0-%d\n\n", i, i)
+	}
+	index = strings.TrimSuffix(index, "\n")
+
+	path := filepath.Join(t.TempDir(), "Packages")
+	out, err := archive.NewCompressedWriter(path,
+		archive.Compression{Extension: ""},
+		archive.Compression{Extension: "gz", Level: 9},
+		archive.Compression{Extension: "xz"},
+		archive.Compression{Extension: "zst", Level: 19},
+	)
+	isok(t, err)
+	w := archive.NewIndexWriter(out, archive.SetField("Origin", "Example"))
+	isok(t, w.Copy(strings.NewReader(index)))
+	isok(t, out.Close())
+
+	paths := out.Paths()
+	assert(t, len(paths) == 4)
+	entries, err := os.ReadDir(filepath.Dir(path))
+	isok(t, err)
+	assert(t, len(entries) == 4)
+	assert(t, paths[3] == path+".zst")
+	want := ""
+	for _, el := range paths {
+		f, err := os.Open(el)
+		isok(t, err)
+		reader, err := deb.DecompressorFor(filepath.Ext(el))(f)
+		isok(t, err)
+		data, err := io.ReadAll(reader)
+		isok(t, err)
+		f.Close()
+		if want == "" {
+			want = string(data)
+			assert(t, strings.HasPrefix(want, "Package: pkg0\nVersion: 1.0-0\nOrigin: Example\n\n"))
+		}
+		assert(t, string(data) == want)
+	}
+	_, err = out.Write([]byte("Package: late\n"))
+	notok(t, err)
+
+	_, err = archive.NewCompressedWriter(path, archive.Compression{Extension: "bz2"})
+	notok(t, err)
+	_, err = archive.NewCompressedWriter(path, archive.Compression{Extension: "gz", Level: 12})
+	notok(t, err)
+	/* Nothing is left behind by a variant failing to start */
+	empty := filepath.Join(t.TempDir(), "Packages")
+	_, err = archive.NewCompressedWriter(empty, archive.Compression{Extension: ""}, archive.Compression{Extension: "gz", Level: 12})
+	notok(t, err)
+	entries, err = os.ReadDir(filepath.Dir(empty))
+	isok(t, err)
+	assert(t, len(entries) == 0)
+	out, err = archive.NewCompressedWriter(path, archive.Compression{Extension: "gz"})
+	isok(t, err)
+	isok(t, out.Close())
+}
+
+func TestHashingCompressedWriter(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "main/binary-amd64/Packages")
+	isok(t, os.MkdirAll(filepath.Dir(path), 0755))
+	out, err := archive.NewHashingCompressedWriter(path, []string{"md5", "sha1", "sha256"},
+		archive.Compression{Extension: ""},
+		archive.Compression{Extension: "xz"},
+	)
+	isok(t, err)
+	_, err = out.Write([]byte("Package: hello\nVersion: 2.10-3\n"))
+	isok(t, err)
+	isok(t, out.Close())
+
+	checksums := out.Checksums()
+	assert(t, len(checksums) == 2)
+	for i, hashes := range checksums {
+		assert(t, len(hashes) == 3)
+		assert(t, hashes[0].Filename == out.Paths()[i])
+		assert(t, hashes[1].ByHash == "SHA1")
+		assert(t, hashes[2].ByHash == "SHA256")
+		isok(t, hashes.Verify(""))
+	}
+	assert(t, checksums[0][2].Hash != checksums[1][2].Hash)
+	assert(t, checksums[0][0].Size == 31)
+
+	isok(t, out.LinkByHash())
+	isok(t, out.LinkByHash())
+	for _, hashes := range checksums {
+		for _, hash := range hashes {
+			data, err := os.ReadFile(filepath.Join(dir, "main/binary-amd64/by-hash", hash.ByHash, hash.Hash))
+			isok(t, err)
+			assert(t, int64(len(data)) == hash.Size)
+		}
+	}
+
+	unhashed, err := archive.NewCompressedWriter(filepath.Join(dir, "Sources"), archive.Compression{Extension: "gz"})
+	isok(t, err)
+	isok(t, unhashed.Close())
+	assert(t, len(unhashed.Checksums()[0]) == 0)
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,355 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package archive // import "github.com/ebikt/go-debian/archive"
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/ebikt/go-debian/hashio"
+)
+
+// Contents {{{
+
+// ContentsEntry is a line of a Contents-<arch> index: a file, and the
+// packages shipping it, qualified by their section, such as
+// "utils/hello" or "non-free/games/foo".
+type ContentsEntry struct {
+	Path      string
+	Locations []string
+}
+
+// Packages returns the names of the packages shipping the file, without
+// their section.
+func (e ContentsEntry) Packages() []string {
+	ret := []string{}
+	for _, location := range e.Locations {
+		ret = append(ret, location[strings.LastIndex(location, "/")+1:])
+	}
+	return ret
+}
+
+// How many lines at the start of a Contents index may be the free-form
+// header of old Contents files, which ends with a "FILE LOCATION" line.
+const contentsHeaderLines = 64
+
+// ContentsReader reads the entries of a Contents index one at a time, so
+// that even the largest ones never have to be held in memory. Compressed
+// indexes are decompressed on the fly.
+type ContentsReader struct {
+	scanner *bufio.Scanner
+	lineno  int
+
+	/* Lines read ahead at the start, looking for the end of a header */
+	pending []string
+	started bool
+}
+
+// NewContentsReader creates a ContentsReader reading the Contents index
+// from `in`, which may be compressed with gzip, xz, bzip2 or zstd.
+func NewContentsReader(in io.Reader) (*ContentsReader, error) {
+	reader, err := hashio.NewDecompressingReader(in)
+	if err != nil {
+		return nil, err
+	}
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	return &ContentsReader{scanner: scanner}, nil
+}
+
+func (r *ContentsReader) readLine() (string, error) {
+	for r.scanner.Scan() {
+		r.lineno++
+		if line := strings.TrimRight(r.scanner.Text(), " \t\r"); line != "" {
+			return line, nil
+		}
+	}
+	if err := r.scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", io.EOF
+}
+
+// start reads ahead the first lines, dropping them if they turn out to be
+// a header.
+func (r *ContentsReader) start() error {
+	r.started = true
+	for len(r.pending) < contentsHeaderLines {
+		line, err := r.readLine()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if fields := strings.Fields(line); len(fields) == 2 && fields[0] == "FILE" && fields[1] == "LOCATION" {
+			r.pending = nil
+			return nil
+		}
+		r.pending = append(r.pending, line)
+	}
+	return nil
+}
+
+func parseContentsLine(line string) (*ContentsEntry, error) {
+	i := strings.LastIndexAny(line, " \t")
+	if i == -1 {
+		return nil, fmt.Errorf("Malformed Contents line: '%s'", line)
+	}
+	return &ContentsEntry{
+		Path:      strings.TrimPrefix(strings.TrimRight(line[:i], " \t"), "/"),
+		Locations: strings.Split(line[i+1:], ","),
+	}, nil
+}
+
+// Next returns the next entry of the index, or io.EOF at its end.
+func (r *ContentsReader) Next() (*ContentsEntry, error) {
+	if !r.started {
+		if err := r.start(); err != nil {
+			return nil, err
+		}
+	}
+	var line string
+	if len(r.pending) > 0 {
+		line, r.pending = r.pending[0], r.pending[1:]
+	} else {
+		var err error
+		if line, err = r.readLine(); err != nil {
+			return nil, err
+		}
+	}
+	return parseContentsLine(line)
+}
+
+// ParseContents parses a whole Contents index, which may be compressed.
+// The free-form header of old Contents files, up to the "FILE LOCATION"
+// line, is skipped.
+func ParseContents(in io.Reader) ([]ContentsEntry, error) {
+	reader, err := NewContentsReader(in)
+	if err != nil {
+		return nil, err
+	}
+	ret := []ContentsEntry{}
+	for {
+		entry, err := reader.Next()
+		if err == io.EOF {
+			return ret, nil
+		} else if err != nil {
+			return nil, err
+		}
+		ret = append(ret, *entry)
+	}
+}
+
+// }}}
+
+// FileIndex {{{
+
+// FileIndex answers "which package ships this file" over a set of
+// Contents indexes, such as all those of a suite, like apt-file does.
+// Each Contents index is added under a name (its path, for instance), and
+// can be updated or removed on its own.
+type FileIndex struct {
+	contents map[string]*contentsTable
+}
+
+// contentsTable holds a Contents index, sorted by path, with all paths
+// joined in a single string (each one preceded by a '\n') so substring
+// searches are a single pass over it.
+type contentsTable struct {
+	checksum string
+	entries  []ContentsEntry
+	blob     string
+	offsets  []int
+
+	/* Entries shipped by each package, by name */
+	packages map[string][]int
+}
+
+// A FileMatch is a file shipped by a package, found in the Contents index
+// added under the name Contents.
+type FileMatch struct {
+	Path     string
+	Package  string
+	Contents string
+}
+
+// NewFileIndex creates an empty FileIndex.
+func NewFileIndex() *FileIndex {
+	return &FileIndex{contents: map[string]*contentsTable{}}
+}
+
+// Update adds the Contents index read from `in` under the given name,
+// replacing the one already there. Nothing is done if it didn't change,
+// in which case false is returned.
+func (i *FileIndex) Update(name string, in io.Reader) (bool, error) {
+	hash := sha256.New()
+	entries, err := ParseContents(io.TeeReader(in, hash))
+	if err != nil {
+		return false, err
+	}
+	checksum := hex.EncodeToString(hash.Sum(nil))
+	if old, ok := i.contents[name]; ok && old.checksum == checksum {
+		return false, nil
+	}
+
+	sort.SliceStable(entries, func(a, b int) bool {
+		return entries[a].Path < entries[b].Path
+	})
+	table := contentsTable{
+		checksum: checksum,
+		entries:  entries,
+		offsets:  make([]int, len(entries)),
+		packages: map[string][]int{},
+	}
+	blob := strings.Builder{}
+	for n, entry := range entries {
+		table.offsets[n] = blob.Len()
+		blob.WriteString("\n/" + entry.Path)
+		for _, pkg := range entry.Packages() {
+			table.packages[pkg] = append(table.packages[pkg], n)
+		}
+	}
+	table.blob = blob.String()
+	i.contents[name] = &table
+	return true, nil
+}
+
+// Remove drops the Contents index added under the given name.
+func (i *FileIndex) Remove(name string) {
+	delete(i.contents, name)
+}
+
+// Names returns the names of the Contents indexes, sorted.
+func (i *FileIndex) Names() []string {
+	ret := []string{}
+	for name := range i.contents {
+		ret = append(ret, name)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+func (i *FileIndex) collect(match func(name string, table *contentsTable) []int) []FileMatch {
+	ret := []FileMatch{}
+	for _, name := range i.Names() {
+		table := i.contents[name]
+		for _, n := range match(name, table) {
+			entry := table.entries[n]
+			for _, pkg := range entry.Packages() {
+				ret = append(ret, FileMatch{Path: "/" + entry.Path, Package: pkg, Contents: name})
+			}
+		}
+	}
+	return ret
+}
+
+// Owners returns the packages shipping the file at the given path.
+func (i *FileIndex) Owners(filename string) []FileMatch {
+	filename = strings.TrimPrefix(path.Clean("/"+filename), "/")
+	return i.collect(func(name string, table *contentsTable) []int {
+		n := sort.Search(len(table.entries), func(n int) bool {
+			return table.entries[n].Path >= filename
+		})
+		ret := []int{}
+		for ; n < len(table.entries) && table.entries[n].Path == filename; n++ {
+			ret = append(ret, n)
+		}
+		return ret
+	})
+}
+
+// Files returns the files shipped by the package, the reverse of Owners,
+// sorted by path within each Contents index.
+func (i *FileIndex) Files(pkg string) []FileMatch {
+	ret := []FileMatch{}
+	for _, match := range i.collect(func(name string, table *contentsTable) []int {
+		return table.packages[pkg]
+	}) {
+		/* Other packages shipping the same files are left out */
+		if match.Package == pkg {
+			ret = append(ret, match)
+		}
+	}
+	return ret
+}
+
+// Search returns the files whose path contains the given string, along
+// with the packages shipping them. Paths start with a '/', so
+// "/bin/hello" finds both /bin/hello and /usr/bin/hello.
+func (i *FileIndex) Search(substring string) []FileMatch {
+	if substring == "" || strings.Contains(substring, "\n") {
+		return []FileMatch{}
+	}
+	return i.collect(func(name string, table *contentsTable) []int {
+		ret := []int{}
+		for start := 0; start < len(table.blob); {
+			found := strings.Index(table.blob[start:], substring)
+			if found == -1 {
+				break
+			}
+			/* The entry is the last one starting before the match */
+			n := sort.SearchInts(table.offsets, start+found+1) - 1
+			ret = append(ret, n)
+			if n+1 < len(table.offsets) {
+				start = table.offsets[n+1]
+			} else {
+				break
+			}
+		}
+		return ret
+	})
+}
+
+// SearchGlob returns the files whose full path (starting with a '/')
+// matches the shell pattern, as path.Match does.
+func (i *FileIndex) SearchGlob(pattern string) ([]FileMatch, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+	/* Everything up to the first wildcard has to be in the path */
+	literal := pattern
+	if n := strings.IndexAny(literal, "*?[\\"); n != -1 {
+		literal = literal[:n]
+	}
+	return i.collect(func(name string, table *contentsTable) []int {
+		ret := []int{}
+		for n, entry := range table.entries {
+			full := "/" + entry.Path
+			if !strings.HasPrefix(full, literal) {
+				continue
+			}
+			if ok, _ := path.Match(pattern, full); ok {
+				ret = append(ret, n)
+			}
+		}
+		return ret
+	}), nil
+}
+
+// }}}
+
+// vim: foldmethod=marker
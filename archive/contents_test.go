@@ -0,0 +1,144 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package archive_test
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/ebikt/go-debian/archive"
+)
+
+/*
+ *
+ */
+
+const mainContents = `usr/bin/hello                                           devel/hello
+usr/share/doc/hello/copyright                           devel/hello
+usr/bin/hello-world                                     devel/hello-world,non-free/misc/hello-extra
+usr/share/man/man1/hello.1.gz                           devel/hello
+usr/lib/x86_64-linux-gnu/libfoo.so.1                    libs/libfoo1
+`
+
+func TestParseContents(t *testing.T) {
+	entries, err := archive.ParseContents(strings.NewReader(
+		"This file maps file names to packages.\n\nFILE   LOCATION\n" + mainContents))
+	isok(t, err)
+	assert(t, len(entries) == 5)
+	assert(t, entries[0].Path == "usr/bin/hello")
+	assert(t, entries[2].Locations[1] == "non-free/misc/hello-extra")
+	assert(t, strings.Join(entries[2].Packages(), " ") == "hello-world hello-extra")
+
+	_, err = archive.ParseContents(strings.NewReader("usr/bin/nowhere\n"))
+	notok(t, err)
+}
+
+func TestContentsReader(t *testing.T) {
+	reader, err := archive.NewContentsReader(bytes.NewReader(gzipped(t,
+		"Some words about the file\n\nFILE                    LOCATION\n"+mainContents)))
+	isok(t, err)
+	paths := []string{}
+	for {
+		entry, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		isok(t, err)
+		paths = append(paths, entry.Path)
+	}
+	assert(t, len(paths) == 5)
+	assert(t, paths[4] == "usr/lib/x86_64-linux-gnu/libfoo.so.1")
+
+	/* Without a header, nothing is skipped */
+	reader, err = archive.NewContentsReader(strings.NewReader(mainContents))
+	isok(t, err)
+	entry, err := reader.Next()
+	isok(t, err)
+	assert(t, entry.Path == "usr/bin/hello")
+}
+
+func TestFileIndexSearch(t *testing.T) {
+	index := archive.NewFileIndex()
+	changed, err := index.Update("main/Contents-amd64", strings.NewReader(mainContents))
+	isok(t, err)
+	assert(t, changed)
+	changed, err = index.Update("contrib/Contents-amd64", strings.NewReader("usr/bin/hello devel/hello-contrib\n"))
+	isok(t, err)
+	assert(t, changed)
+
+	owners := index.Owners("/usr/bin/hello")
+	assert(t, len(owners) == 2)
+	assert(t, owners[0].Package == "hello-contrib")
+	assert(t, owners[0].Contents == "contrib/Contents-amd64")
+	assert(t, owners[1].Package == "hello")
+	assert(t, owners[1].Path == "/usr/bin/hello")
+
+	matches := index.Search("bin/hello")
+	assert(t, len(matches) == 4)
+	assert(t, matches[1].Path == "/usr/bin/hello")
+	assert(t, matches[3].Package == "hello-extra")
+
+	assert(t, len(index.Search("/usr/lib/")) == 1)
+	assert(t, len(index.Search("libfoo.so.1")) == 1)
+	assert(t, len(index.Search("nothing")) == 0)
+
+	matches, err = index.SearchGlob("/usr/share/*/hello*")
+	isok(t, err)
+	assert(t, len(matches) == 0)
+	matches, err = index.SearchGlob("/usr/share/man/*/hello.*")
+	isok(t, err)
+	assert(t, len(matches) == 1)
+	_, err = index.SearchGlob("/usr/[")
+	notok(t, err)
+
+	files := index.Files("hello")
+	assert(t, len(files) == 3)
+	assert(t, files[0].Path == "/usr/bin/hello")
+	assert(t, files[2].Path == "/usr/share/man/man1/hello.1.gz")
+	files = index.Files("hello-extra")
+	assert(t, len(files) == 1)
+	assert(t, files[0].Path == "/usr/bin/hello-world")
+	assert(t, len(index.Files("nothing")) == 0)
+}
+
+func TestFileIndexUpdate(t *testing.T) {
+	index := archive.NewFileIndex()
+	_, err := index.Update("main/Contents-amd64", strings.NewReader(mainContents))
+	isok(t, err)
+
+	changed, err := index.Update("main/Contents-amd64", strings.NewReader(mainContents))
+	isok(t, err)
+	assert(t, !changed)
+
+	changed, err = index.Update("main/Contents-amd64", strings.NewReader("usr/bin/goodbye devel/goodbye\n"))
+	isok(t, err)
+	assert(t, changed)
+	assert(t, len(index.Owners("usr/bin/hello")) == 0)
+	assert(t, len(index.Owners("usr/bin/goodbye")) == 1)
+
+	index.Remove("main/Contents-amd64")
+	assert(t, len(index.Names()) == 0)
+	assert(t, len(index.Search("goodbye")) == 0)
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,8 @@
+/*
+
+This module provides an API to work with Debian archives (repositories) as
+they are laid out on disk: the `dists/` tree holding the indices of each
+suite, and the `pool/` tree holding the package files they reference.
+
+*/
+package archive // import "github.com/ebikt/go-debian/archive"
@@ -0,0 +1,262 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package archive // import "github.com/ebikt/go-debian/archive"
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/ebikt/go-debian/hashio"
+)
+
+// Fetching {{{
+
+// A Fetcher retrieves the files of a remote repository, given their slash
+// separated path relative to the root of the repository, such as
+// "dists/stable/InRelease".
+type Fetcher interface {
+	Fetch(ctx context.Context, path string) (io.ReadCloser, error)
+}
+
+// HTTPFetcher fetches the files of a repository served over HTTP(S).
+type HTTPFetcher struct {
+	// URL of the root of the repository, such as
+	// "https://deb.debian.org/debian".
+	BaseURL string
+
+	// HTTP client to use; http.DefaultClient if nil.
+	Client *http.Client
+
+	// Transport of the requests when there's no Client, such as one
+	// NewTransport returns, keeping a pool of connections to the servers;
+	// the one of http.DefaultClient if nil too.
+	Transport http.RoundTripper
+
+	// How requests which failed, and downloads interrupted midway, are
+	// retried; downloads are resumed where they stopped, with a Range
+	// request. Nothing is retried by default.
+	Retry RetryPolicy
+}
+
+func (f HTTPFetcher) client() *http.Client {
+	if f.Client != nil {
+		return f.Client
+	}
+	if f.Transport != nil {
+		return &http.Client{Transport: f.Transport}
+	}
+	return http.DefaultClient
+}
+
+// get issues a GET request for the url, asking for what follows the
+// offset when it isn't 0. The validator, the ETag or Last-Modified of the
+// previous response, makes sure the file didn't change in between.
+func (f HTTPFetcher) get(ctx context.Context, url string, offset int64, validator string) (*http.Response, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	expected := http.StatusOK
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		if validator != "" {
+			req.Header.Set("If-Range", validator)
+		}
+		expected = http.StatusPartialContent
+	}
+	resp, err := f.client().Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != expected {
+		io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+		return nil, &StatusError{Method: "GET", URL: url, StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+	return resp, nil
+}
+
+// Fetch issues a GET request for the file, which must succeed with a 200
+// status, retrying it as the Retry policy says. The body has to be closed
+// by the caller.
+func (f HTTPFetcher) Fetch(ctx context.Context, path string) (io.ReadCloser, error) {
+	url := strings.TrimSuffix(f.BaseURL, "/") + "/" + strings.TrimPrefix(path, "/")
+	body := resumingBody{ctx: ctx, fetcher: f, url: url}
+	if err := body.open(); err != nil {
+		return nil, err
+	}
+	if f.Retry.Retries == 0 {
+		return body.body, nil
+	}
+	return &body, nil
+}
+
+// FileFetcher fetches the files of a repository found on the filesystem,
+// as apt's file:/ method does.
+type FileFetcher struct {
+	// Directory of the root of the repository.
+	Root string
+}
+
+// Fetch opens the file, which can't be outside of the Root. Reading it
+// stops once the context is done.
+func (f FileFetcher) Fetch(ctx context.Context, name string) (io.ReadCloser, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	file, err := os.Open(filepath.Join(f.Root, filepath.FromSlash(path.Clean("/"+name))))
+	if err != nil {
+		return nil, err
+	}
+	return hashio.NewContextReader(ctx, file), nil
+}
+
+// MirrorListFetcher fetches the files of a repository from a list of
+// mirrors of it, trying them in turn until one has the file, as apt's
+// mirror method does. As everything fetched is checked against the
+// Release file, the mirrors need not be trusted.
+type MirrorListFetcher struct {
+	Mirrors []Fetcher
+}
+
+// Fetch fetches the file from the first mirror that has it, returning the
+// error of the last one if none does.
+func (f MirrorListFetcher) Fetch(ctx context.Context, path string) (io.ReadCloser, error) {
+	err := fmt.Errorf("No mirror to fetch %s from", path)
+	for _, mirror := range f.Mirrors {
+		var body io.ReadCloser
+		if body, err = mirror.Fetch(ctx, path); err == nil {
+			return body, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+	}
+	return nil, err
+}
+
+// ParseMirrorList parses a mirror list, as used by apt's mirror method:
+// one URL per line, along with metadata separated by tabs, which is
+// ignored, and comments starting with "#".
+func ParseMirrorList(reader io.Reader) ([]string, error) {
+	ret := []string{}
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		ret = append(ret, strings.Fields(line)[0])
+	}
+	return ret, scanner.Err()
+}
+
+// NewFetcher returns the Fetcher for the URI of a repository, as found in
+// sources.list:
+//
+//   http://deb.debian.org/debian           HTTPFetcher
+//   file:/srv/mirror/debian                FileFetcher
+//   mirror+file:/etc/apt/mirrors.txt       MirrorListFetcher
+//   mirror://mirrors.example.com/list.txt  MirrorListFetcher
+//
+// The mirror lists are fetched right away. HTTP redirects, such as the
+// ones of deb.debian.org and other redirectors to per-file mirrors, are
+// followed by the HTTP client.
+func NewFetcher(ctx context.Context, uri string, client *http.Client) (Fetcher, error) {
+	switch {
+	case strings.HasPrefix(uri, "http://"), strings.HasPrefix(uri, "https://"):
+		return HTTPFetcher{BaseURL: uri, Client: client}, nil
+	case strings.HasPrefix(uri, "file:"):
+		parsed, err := url.Parse(uri)
+		if err != nil {
+			return nil, err
+		}
+		return FileFetcher{Root: filepath.FromSlash(parsed.Path)}, nil
+	case strings.HasPrefix(uri, "mirror://"):
+		return newMirrorListFetcher(ctx, "http://"+strings.TrimPrefix(uri, "mirror://"), client)
+	case strings.HasPrefix(uri, "mirror+"):
+		return newMirrorListFetcher(ctx, strings.TrimPrefix(uri, "mirror+"), client)
+	}
+	return nil, fmt.Errorf("Unsupported repository URI '%s'", uri)
+}
+
+// newMirrorListFetcher fetches the mirror list found at the URI.
+func newMirrorListFetcher(ctx context.Context, uri string, client *http.Client) (Fetcher, error) {
+	i := strings.LastIndex(uri, "/")
+	if i < 0 {
+		return nil, fmt.Errorf("Invalid mirror list URI '%s'", uri)
+	}
+	dir, err := NewFetcher(ctx, uri[:i+1], client)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := dir.(MirrorListFetcher); ok {
+		return nil, fmt.Errorf("Invalid mirror list URI '%s'", uri)
+	}
+	data, err := fetchAll(ctx, dir, uri[i+1:])
+	if err != nil {
+		return nil, err
+	}
+	mirrors, err := ParseMirrorList(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	ret := MirrorListFetcher{Mirrors: []Fetcher{}}
+	for _, mirror := range mirrors {
+		if strings.HasPrefix(mirror, "mirror") {
+			return nil, fmt.Errorf("Nested mirror list '%s' in %s", mirror, uri)
+		}
+		fetcher, err := NewFetcher(ctx, mirror, client)
+		if err != nil {
+			return nil, err
+		}
+		ret.Mirrors = append(ret.Mirrors, fetcher)
+	}
+	if len(ret.Mirrors) == 0 {
+		return nil, fmt.Errorf("No mirror in %s", uri)
+	}
+	return ret, nil
+}
+
+// fetchAll reads a whole file through a Fetcher.
+func fetchAll(ctx context.Context, fetcher Fetcher, path string) ([]byte, error) {
+	body, err := fetcher.Fetch(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	/* Not every Fetcher stops reading when the context is done */
+	return ioutil.ReadAll(hashio.NewContextReader(ctx, body))
+}
+
+// }}}
+
+// vim: foldmethod=marker
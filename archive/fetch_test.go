@@ -0,0 +1,140 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package archive_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ebikt/go-debian/archive"
+)
+
+/*
+ *
+ */
+
+func TestParseMirrorList(t *testing.T) {
+	mirrors, err := archive.ParseMirrorList(strings.NewReader(`# Debian mirrors
+http://deb.debian.org/debian	priority:1	type:index
+http://ftp.example.org/debian
+
+file:/srv/mirror/debian
+`))
+	isok(t, err)
+	assert(t, len(mirrors) == 3)
+	assert(t, mirrors[0] == "http://deb.debian.org/debian")
+	assert(t, mirrors[2] == "file:/srv/mirror/debian")
+}
+
+func TestFileFetcher(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, root, "dists/stable/Release", "Suite: stable\n")
+
+	fetcher, err := archive.NewFetcher(context.Background(), "file://"+filepath.ToSlash(root), nil)
+	isok(t, err)
+	body, err := fetcher.Fetch(context.Background(), "dists/stable/Release")
+	isok(t, err)
+	data, err := ioutil.ReadAll(body)
+	body.Close()
+	isok(t, err)
+	assert(t, string(data) == "Suite: stable\n")
+
+	/* Nothing outside of the repository */
+	_, err = fetcher.Fetch(context.Background(), "../"+filepath.Base(root)+"/dists/stable/Release")
+	notok(t, err)
+
+	_, err = archive.NewFetcher(context.Background(), "ftp://ftp.debian.org/debian", nil)
+	notok(t, err)
+
+	/* Reading stops along with the context */
+	ctx, cancel := context.WithCancel(context.Background())
+	body, err = fetcher.Fetch(ctx, "dists/stable/Release")
+	isok(t, err)
+	cancel()
+	_, err = ioutil.ReadAll(body)
+	body.Close()
+	assert(t, err == context.Canceled)
+	_, err = fetcher.Fetch(ctx, "dists/stable/Release")
+	assert(t, err == context.Canceled)
+}
+
+func TestMirrorList(t *testing.T) {
+	suite := testSuite()
+	suite.Indexes["main/binary-amd64/Packages"] = []byte(fooPackages)
+	repo, client := clientRepository(t, suite)
+	defer repo.Close()
+	good := client.Entry.URI
+
+	broken := httptest.NewServer(http.NotFoundHandler())
+	defer broken.Close()
+	/* Redirects everything but the Release files to the mirror, the way
+	 * deb.debian.org does */
+	redirector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "Release") {
+			http.NotFound(w, r)
+			return
+		}
+		http.Redirect(w, r, good+r.URL.Path, http.StatusFound)
+	}))
+	defer redirector.Close()
+
+	list := filepath.Join(t.TempDir(), "mirrors.txt")
+	isok(t, os.WriteFile(list, []byte(broken.URL+"\n"+good+"\tpriority:2\n"), 0644))
+	client.Entry.URI = "mirror+file:" + filepath.ToSlash(list)
+	ctx := context.Background()
+	_, err := client.Update(ctx)
+	isok(t, err)
+	_, ok := client.Fetcher.(archive.MirrorListFetcher)
+	assert(t, ok)
+	packages, err := client.Packages(ctx, "main", "amd64")
+	isok(t, err)
+	entry, err := packages.Next()
+	isok(t, err)
+	assert(t, entry.Package == "foo")
+
+	/* The indexes are fetched through the redirector, and checked against
+	 * the Release file of the first mirror */
+	client.Fetcher = archive.MirrorListFetcher{Mirrors: []archive.Fetcher{
+		archive.HTTPFetcher{BaseURL: good},
+	}}
+	_, err = client.Update(ctx)
+	isok(t, err)
+	client.Fetcher = archive.HTTPFetcher{BaseURL: redirector.URL}
+	_, err = client.Packages(ctx, "main", "amd64")
+	isok(t, err)
+	repo.AddFile("dists/stable/main/binary-amd64/Packages", []byte(fooNewPackages))
+	_, err = client.Packages(ctx, "main", "amd64")
+	notok(t, err)
+
+	isok(t, os.WriteFile(list, []byte("# Nothing\n"), 0644))
+	_, err = archive.NewFetcher(ctx, "mirror+file:"+filepath.ToSlash(list), nil)
+	notok(t, err)
+	_, err = archive.NewFetcher(ctx, "mirror://"+strings.TrimPrefix(broken.URL, "http://")+"/mirrors.txt", nil)
+	notok(t, err)
+}
+
+// vim: foldmethod=marker
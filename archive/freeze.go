@@ -0,0 +1,375 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package archive // import "github.com/ebikt/go-debian/archive"
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ebikt/go-debian/control"
+	"github.com/ebikt/go-debian/deb"
+	"github.com/ebikt/go-debian/hashio"
+)
+
+// Frozen suites {{{
+//
+// Where a Snapshot copies the whole dists/ tree of a Repository, a
+// FrozenSuite only records what a suite was at some point: the SHA256
+// checksums of its Release file, of its indexes and of the pool files they
+// list. That's a few lines per package, which can be kept along with the
+// builds it was used by, and checked or served later on, the way
+// snapshot.debian.org serves the archive as it was:
+//
+//	frozen, err := repo.Freeze("stable")
+//	...
+//	err = frozen.Write(out)
+//	...
+//	client := &archive.Client{
+//		Entry:   archive.SourceEntry{Suite: "stable"},
+//		Fetcher: &archive.FrozenFetcher{Fetcher: mirror, Frozen: frozen},
+//	}
+
+// FrozenSuite is the frozen view of a suite of a repository, see Freeze.
+type FrozenSuite struct {
+	control.Paragraph
+
+	Suite string `required:"true"`
+
+	// When the suite was frozen, as RFC 1123.
+	Date string
+
+	// The InRelease or Release file of the suite, and the indexes it
+	// lists, relative to the suite directory, as in the Release file.
+	Release control.SHA256FileHash   `required:"true"`
+	Indexes []control.SHA256FileHash `delim:"\n" strip:"\n\r\t "`
+
+	// The Release.gpg file along with the Release file, if the suite has
+	// no InRelease file, relative to the suite directory too.
+	Signature []control.SHA256FileHash `delim:"\n" strip:"\n\r\t "`
+
+	// The pool files listed by the indexes, relative to the root of the
+	// repository.
+	Pool []control.SHA256FileHash `delim:"\n" strip:"\n\r\t "`
+}
+
+// ParseFrozenSuite reads a FrozenSuite written by Write.
+func ParseFrozenSuite(in io.Reader) (*FrozenSuite, error) {
+	ret := FrozenSuite{}
+	if err := control.Unmarshal(&ret, in); err != nil {
+		return nil, err
+	}
+	return &ret, nil
+}
+
+// Write writes the FrozenSuite as a control file.
+func (f *FrozenSuite) Write(out io.Writer) error {
+	return control.Marshal(out, f)
+}
+
+// Files returns the checksums of the files of the frozen view, by their
+// path relative to the root of the repository.
+func (f *FrozenSuite) Files() map[string]control.FileHash {
+	dir := "dists/" + f.Suite + "/"
+	ret := map[string]control.FileHash{}
+	ret[dir+f.Release.Filename] = f.Release.FileHash
+	for _, hash := range f.Signature {
+		ret[dir+hash.Filename] = hash.FileHash
+	}
+	for _, hash := range f.Indexes {
+		ret[dir+hash.Filename] = hash.FileHash
+	}
+	for _, hash := range f.Pool {
+		ret[hash.Filename] = hash.FileHash
+	}
+	return ret
+}
+
+// lookup returns the checksums of a file of the frozen view, by its path
+// relative to the root of the repository, which may be the by-hash path
+// of an index; files are the ones of Files.
+func (f *FrozenSuite) lookup(files map[string]control.FileHash, rel string) (control.FileHash, bool) {
+	rel = path.Clean(strings.TrimPrefix(rel, "/"))
+	if hash, ok := files[rel]; ok {
+		return hash, true
+	}
+	dir, hash := path.Dir(rel), path.Base(rel)
+	if path.Base(dir) != "SHA256" || path.Base(path.Dir(dir)) != "by-hash" {
+		return control.FileHash{}, false
+	}
+	indexDir := path.Dir(path.Dir(dir))
+	for _, index := range f.Indexes {
+		if index.Hash == hash && "dists/"+f.Suite+"/"+path.Dir(index.Filename) == indexDir {
+			return index.FileHash, true
+		}
+	}
+	return control.FileHash{}, false
+}
+
+// Freeze records the current state of the suite: the checksums of its
+// InRelease file, or else Release file and Release.gpg, of the indexes it
+// lists, and of the pool files of the Packages and Sources indexes. Pool
+// files only listed with weaker checksums than SHA256 are hashed to get
+// theirs.
+func (r *Repository) Freeze(suite string) (*FrozenSuite, error) {
+	dir := "dists/" + suite
+	ret := FrozenSuite{
+		Paragraph: control.NewParagraph(),
+		Suite:     suite,
+		Date:      time.Now().UTC().Format(time.RFC1123),
+		Indexes:   []control.SHA256FileHash{},
+		Pool:      []control.SHA256FileHash{},
+	}
+
+	var release *Release
+	for _, name := range []string{"InRelease", "Release"} {
+		data, err := os.ReadFile(r.path(dir + "/" + name))
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		if release, err = ParseRelease(bytes.NewReader(data)); err != nil {
+			return nil, fmt.Errorf("%s/%s: %s", dir, name, err)
+		}
+		_, sha256sum, err := hashFile(r.path(dir+"/"+name), name)
+		if err != nil {
+			return nil, err
+		}
+		ret.Release = control.SHA256FileHash{FileHash: *sha256sum}
+		if name == "Release" {
+			_, signature, err := hashFile(r.path(dir+"/Release.gpg"), "Release.gpg")
+			if err == nil {
+				ret.Signature = []control.SHA256FileHash{{FileHash: *signature}}
+			} else if !os.IsNotExist(err) {
+				return nil, err
+			}
+		}
+		break
+	}
+	if release == nil {
+		return nil, fmt.Errorf("No Release file for suite %s", suite)
+	}
+
+	pool := map[string]control.FileHash{}
+	read := map[string]bool{}
+	for _, hash := range release.SHA256 {
+		if _, err := os.Stat(r.path(dir + "/" + hash.Filename)); os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		ret.Indexes = append(ret.Indexes, hash)
+
+		/* All the variants of an index list the same files */
+		name := indexName(hash.Filename)
+		if name == "" || read[name] {
+			continue
+		}
+		read[name] = true
+		if err := r.freezeIndex(dir+"/"+hash.Filename, pool); err != nil {
+			return nil, err
+		}
+	}
+	if len(ret.Indexes) == 0 {
+		return nil, fmt.Errorf("The Release file of suite %s lists no SHA256 checksum of an index there", suite)
+	}
+
+	for _, hash := range pool {
+		ret.Pool = append(ret.Pool, control.SHA256FileHash{FileHash: hash})
+	}
+	sort.Slice(ret.Pool, func(i, j int) bool {
+		return ret.Pool[i].Filename < ret.Pool[j].Filename
+	})
+	return &ret, nil
+}
+
+// freezeIndex adds the pool files of a Packages or Sources index to the
+// ones of the frozen view.
+func (r *Repository) freezeIndex(rel string, pool map[string]control.FileHash) error {
+	f, err := os.Open(r.path(rel))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	reader, err := deb.DecompressorFor(path.Ext(rel))(f)
+	if err != nil {
+		return fmt.Errorf("%s: %s", rel, err)
+	}
+	paragraphs, err := control.NewParagraphReader(reader, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %s", rel, err)
+	}
+	for {
+		para, err := paragraphs.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("%s: %s", rel, err)
+		}
+		files, err := indexEntryHashes(*para)
+		if err != nil {
+			return fmt.Errorf("%s: %s", rel, err)
+		}
+		for _, hashes := range files {
+			name := hashes[0].Filename
+			if _, ok := pool[name]; ok {
+				continue
+			}
+			hash, err := r.poolSHA256(hashes)
+			if err != nil {
+				return err
+			}
+			pool[name] = hash
+		}
+	}
+}
+
+// poolSHA256 returns the SHA256 checksum of a pool file, the one listed
+// by its index, or else the one of the file itself, once checked against
+// the index.
+func (r *Repository) poolSHA256(hashes control.FileHashes) (control.FileHash, error) {
+	for _, hash := range hashes {
+		if hash.Algorithm == "sha256" {
+			return hash, nil
+		}
+	}
+	name := hashes[0].Filename
+	f, err := os.Open(r.path(name))
+	if err != nil {
+		return control.FileHash{}, err
+	}
+	defer f.Close()
+	reader, hasher, err := hashio.NewHasherReader("sha256", f)
+	if err != nil {
+		return control.FileHash{}, err
+	}
+	if err := hashes.VerifyReader(reader); err != nil {
+		return control.FileHash{}, err
+	}
+	return control.FileHashFromHasher(name, *hasher), nil
+}
+
+// VerifyFrozen checks that the repository still has the frozen view of
+// the suite: that its Release file, its indexes and the pool files are
+// the ones frozen. Missing and different files are passed to the
+// AuditFunc as they are found, files added since are not looked for. The
+// error returned is the one which stopped the check, if any.
+func (r *Repository) VerifyFrozen(ctx context.Context, frozen *FrozenSuite, report AuditFunc) error {
+	files := frozen.Files()
+	names := []string{}
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		err := verifyFile(r.path(name), control.FileHashes{files[name]})
+		kind := AuditMismatch
+		if os.IsNotExist(err) {
+			kind, err = AuditMissing, nil
+		} else if err == nil {
+			continue
+		}
+		if err := report(AuditFinding{Kind: kind, Path: name, Suite: frozen.Suite, Err: err}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// }}}
+
+// Serving frozen suites {{{
+
+// FrozenFetcher serves the frozen view of a suite out of another Fetcher,
+// such as the one of a mirror or of snapshot.debian.org: only the files
+// of the view can be fetched, and they have to be the ones frozen, or
+// reading them fails as they end. Indexes can be fetched by their SHA256
+// by-hash paths too.
+type FrozenFetcher struct {
+	Fetcher Fetcher
+	Frozen  *FrozenSuite
+
+	/* The Files of the Frozen suite, looked up on every fetch */
+	filesOnce sync.Once
+	files     map[string]control.FileHash
+}
+
+func (f *FrozenFetcher) Fetch(ctx context.Context, rel string) (io.ReadCloser, error) {
+	f.filesOnce.Do(func() { f.files = f.Frozen.Files() })
+	hash, ok := f.Frozen.lookup(f.files, rel)
+	if !ok {
+		return nil, fmt.Errorf("%s is not part of the frozen suite %s", rel, f.Frozen.Suite)
+	}
+	body, err := f.Fetcher.Fetch(ctx, rel)
+	if err != nil {
+		return nil, err
+	}
+	reader, hasher, err := hashio.NewHasherReader(hash.Algorithm, body)
+	if err != nil {
+		body.Close()
+		return nil, err
+	}
+	return &frozenBody{Reader: reader, body: body, hasher: hasher, hash: hash, name: rel}, nil
+}
+
+// frozenBody checks the file it reads against its frozen checksum, once
+// read to the end.
+type frozenBody struct {
+	io.Reader
+	body   io.ReadCloser
+	hasher *hashio.Hasher
+	hash   control.FileHash
+	name   string
+}
+
+func (b *frozenBody) Read(p []byte) (int, error) {
+	n, err := b.Reader.Read(p)
+	if b.hasher.Size() > b.hash.Size {
+		return n, fmt.Errorf("%s is larger than frozen: %d bytes, want %d", b.name, b.hasher.Size(), b.hash.Size)
+	}
+	if err == io.EOF {
+		got := control.FileHashFromHasher(b.name, *b.hasher)
+		if got.Size != b.hash.Size || got.Hash != b.hash.Hash {
+			return n, fmt.Errorf("%s is not the one frozen: got %s (%d bytes), want %s (%d bytes)",
+				b.name, got.Hash, got.Size, b.hash.Hash, b.hash.Size)
+		}
+	}
+	return n, err
+}
+
+func (b *frozenBody) Close() error {
+	return b.body.Close()
+}
+
+// }}}
+
+// vim: foldmethod=marker
@@ -0,0 +1,187 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package archive_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ebikt/go-debian/archive"
+	"github.com/ebikt/go-debian/testutil"
+)
+
+/*
+ *
+ */
+
+func frozenRepository(t *testing.T) (string, *archive.Repository) {
+	root := t.TempDir()
+	repo, err := archive.NewRepository(root)
+	isok(t, err)
+	for _, d := range []testutil.Deb{
+		{Package: "hello", Version: "2.10-3", Architecture: "amd64"},
+		{Package: "hello-doc", Version: "2.10-3", Source: "hello"},
+	} {
+		data, err := d.Build()
+		isok(t, err)
+		writeFile(t, root, d.PoolPath("main"), string(data))
+	}
+	_, err = (&archive.Publisher{Repository: repo, Suite: "stable", ByHash: true}).Publish()
+	isok(t, err)
+	return root, repo
+}
+
+func TestFreeze(t *testing.T) {
+	root, repo := frozenRepository(t)
+	frozen, err := repo.Freeze("stable")
+	isok(t, err)
+	assert(t, frozen.Release.Filename == "Release")
+	assert(t, len(frozen.Indexes) == 6)
+	assert(t, len(frozen.Pool) == 2)
+	assert(t, frozen.Pool[0].Filename == "pool/main/h/hello/hello-doc_2.10-3_all.deb")
+
+	out := bytes.Buffer{}
+	isok(t, frozen.Write(&out))
+	parsed, err := archive.ParseFrozenSuite(&out)
+	isok(t, err)
+	assert(t, parsed.Suite == "stable")
+	assert(t, parsed.Release.Hash == frozen.Release.Hash)
+	assert(t, len(parsed.Indexes) == 6)
+	assert(t, len(parsed.Pool) == 2)
+	assert(t, parsed.Pool[1].Size == frozen.Pool[1].Size)
+
+	verify := func() []string {
+		findings := []string{}
+		isok(t, repo.VerifyFrozen(context.Background(), parsed, func(finding archive.AuditFinding) error {
+			findings = append(findings, finding.Kind+" "+finding.Path)
+			return nil
+		}))
+		return findings
+	}
+	assert(t, len(verify()) == 0)
+
+	isok(t, os.Remove(filepath.Join(root, frozen.Pool[0].Filename)))
+	writeFile(t, root, frozen.Pool[1].Filename, "changed")
+	findings := verify()
+	assert(t, strings.Join(findings, "\n") ==
+		"missing pool/main/h/hello/hello-doc_2.10-3_all.deb\nmismatch pool/main/h/hello/hello_2.10-3_amd64.deb")
+
+	_, err = repo.Freeze("unstable")
+	notok(t, err)
+}
+
+func TestFrozenFetcher(t *testing.T) {
+	root, repo := frozenRepository(t)
+	frozen, err := repo.Freeze("stable")
+	isok(t, err)
+
+	mirror := archive.MemoryFetcher{}
+	isok(t, filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		isok(t, err)
+		data, err := os.ReadFile(p)
+		isok(t, err)
+		mirror[filepath.ToSlash(rel)] = data
+		return nil
+	}))
+	fetcher := &archive.FrozenFetcher{Fetcher: mirror, Frozen: frozen}
+
+	fetch := func(name string) error {
+		body, err := fetcher.Fetch(context.Background(), name)
+		if err != nil {
+			return err
+		}
+		defer body.Close()
+		_, err = io.ReadAll(body)
+		return err
+	}
+	isok(t, fetch("dists/stable/Release"))
+	isok(t, fetch("dists/stable/main/binary-amd64/Packages.gz"))
+	isok(t, fetch(frozen.Pool[0].Filename))
+	for _, index := range frozen.Indexes {
+		if index.Filename == "main/binary-amd64/Packages.xz" {
+			isok(t, fetch("dists/stable/main/binary-amd64/by-hash/SHA256/"+index.Hash))
+		}
+	}
+	notok(t, fetch("dists/stable/main/binary-i386/by-hash/SHA256/"+frozen.Indexes[0].Hash))
+	notok(t, fetch("dists/unstable/Release"))
+
+	/* The mirror moved on */
+	mirror[frozen.Pool[0].Filename] = []byte("changed")
+	notok(t, fetch(frozen.Pool[0].Filename))
+	mirror[frozen.Pool[0].Filename] = append(mirror[frozen.Pool[1].Filename], 0)
+	notok(t, fetch(frozen.Pool[0].Filename))
+}
+
+func TestFrozenFetcherSignature(t *testing.T) {
+	key, err := testutil.NewKey("Frozen", "frozen@example.com")
+	isok(t, err)
+	root := t.TempDir()
+	repo, err := archive.NewRepository(root)
+	isok(t, err)
+	d := testutil.Deb{Package: "hello", Version: "2.10-3", Architecture: "amd64"}
+	data, err := d.Build()
+	isok(t, err)
+	writeFile(t, root, d.PoolPath("main"), string(data))
+	_, err = (&archive.Publisher{Repository: repo, Suite: "stable", Signer: key}).Publish()
+	isok(t, err)
+	isok(t, os.Remove(filepath.Join(root, "dists/stable/InRelease")))
+
+	frozen, err := repo.Freeze("stable")
+	isok(t, err)
+	assert(t, frozen.Release.Filename == "Release")
+	assert(t, len(frozen.Signature) == 1)
+	assert(t, frozen.Signature[0].Filename == "Release.gpg")
+
+	out := bytes.Buffer{}
+	isok(t, frozen.Write(&out))
+	parsed, err := archive.ParseFrozenSuite(&out)
+	isok(t, err)
+	assert(t, len(parsed.Signature) == 1)
+	assert(t, parsed.Signature[0].Hash == frozen.Signature[0].Hash)
+
+	mirror := archive.MemoryFetcher{}
+	isok(t, filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		isok(t, err)
+		data, err := os.ReadFile(p)
+		isok(t, err)
+		mirror[filepath.ToSlash(rel)] = data
+		return nil
+	}))
+	client := archive.NewClient(archive.SourceEntry{Suite: "stable"}, testutil.Keyring(key))
+	client.Fetcher = &archive.FrozenFetcher{Fetcher: mirror, Frozen: parsed}
+	_, err = client.Update(context.Background())
+	isok(t, err)
+}
+
+// vim: foldmethod=marker
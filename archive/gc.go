@@ -0,0 +1,165 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package archive // import "github.com/ebikt/go-debian/archive"
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// Garbage collection {{{
+
+// PruneOptions tells Prune what to keep in the pool, and whether to only
+// report what would be removed.
+type PruneOptions struct {
+	// Only report what would be removed, without removing anything.
+	DryRun bool
+
+	// Keep the pool files referenced by the suites of these snapshots
+	// too, rather than relying on the copies in the snapshots, which
+	// Switch links back into the pool.
+	Retain []string
+}
+
+// PruneReport lists what Prune removed, or would remove.
+type PruneReport struct {
+	// Removed files, as slash separated paths relative to the repository
+	// root, in walk order.
+	Files []string
+
+	// Total size of the removed files, in bytes.
+	Size int64
+
+	// Disk space freed by removing them, in bytes: files hardlinked into
+	// snapshots don't free anything until the snapshots are deleted.
+	Reclaimed int64
+}
+
+// Prune removes files from pool/ that are not referenced by any of the
+// currently published suites, nor by the suites of the retained snapshots,
+// and reports them along with the space they took.
+func (r *Repository) Prune(opts PruneOptions) (*PruneReport, error) {
+	live := map[string]bool{}
+	suiteDirs := []string{}
+	suites, err := r.Suites()
+	if err != nil {
+		return nil, err
+	}
+	for _, suite := range suites {
+		suiteDirs = append(suiteDirs, r.path("dists/"+suite))
+	}
+	for _, name := range opts.Retain {
+		snapshot, err := r.GetSnapshot(name)
+		if err != nil {
+			return nil, err
+		}
+		suites, err := snapshot.Suites()
+		if err != nil {
+			return nil, err
+		}
+		for _, suite := range suites {
+			suiteDirs = append(suiteDirs, filepath.Join(snapshot.Path, "dists", suite))
+		}
+	}
+	for _, dir := range suiteDirs {
+		files, err := referencedFiles(dir)
+		if err != nil {
+			return nil, err
+		}
+		for file := range files {
+			live[file] = true
+		}
+	}
+
+	snapshots, err := r.Snapshots()
+	if err != nil {
+		return nil, err
+	}
+
+	report := PruneReport{Files: []string{}}
+	pool := r.path("pool")
+	err = filepath.Walk(pool, func(p string, info os.FileInfo, err error) error {
+		if os.IsNotExist(err) && p == pool {
+			return filepath.SkipDir
+		} else if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(r.Root, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if live[rel] {
+			return nil
+		}
+
+		report.Files = append(report.Files, rel)
+		report.Size += info.Size()
+		if !inSnapshots(snapshots, rel, info) {
+			report.Reclaimed += info.Size()
+		}
+		if opts.DryRun {
+			return nil
+		}
+		return os.Remove(p)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(pool); err == nil && !opts.DryRun {
+		if err := removeEmptyDirs(pool); err != nil {
+			return nil, err
+		}
+	}
+	return &report, nil
+}
+
+// inSnapshots checks if the pool file is hardlinked into a snapshot, where
+// it has the same path.
+func inSnapshots(snapshots []Snapshot, rel string, info os.FileInfo) bool {
+	for _, snapshot := range snapshots {
+		other, err := os.Stat(filepath.Join(snapshot.Path, filepath.FromSlash(rel)))
+		if err == nil && os.SameFile(info, other) {
+			return true
+		}
+	}
+	return false
+}
+
+// GarbageCollect removes files from pool/ that are not referenced by any
+// of the currently published suites, and returns their paths relative to
+// the repository root. Files still referenced by snapshots survive in the
+// snapshots themselves, and are restored by Switch. See Prune for more
+// control.
+func (r *Repository) GarbageCollect() ([]string, error) {
+	report, err := r.Prune(PruneOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return report.Files, nil
+}
+
+// }}}
+
+// vim: foldmethod=marker
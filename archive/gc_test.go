@@ -0,0 +1,72 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package archive_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ebikt/go-debian/archive"
+)
+
+/*
+ *
+ */
+
+func TestPrune(t *testing.T) {
+	repo := testRepository(t)
+	defer os.RemoveAll(repo.Root)
+
+	_, err := repo.Snapshot("one")
+	isok(t, err)
+
+	/* Publish 2.0, which leaves the 1.0 files unreferenced */
+	isok(t, os.RemoveAll(filepath.Join(repo.Root, "dists")))
+	writeFile(t, repo.Root, "dists/stable/main/binary-amd64/Packages", fooNewPackages)
+	writeFile(t, repo.Root, "pool/main/o/orphan/orphan_1.0_all.deb", "orphaned")
+
+	report, err := repo.Prune(archive.PruneOptions{DryRun: true})
+	isok(t, err)
+	assert(t, len(report.Files) == 4)
+	assert(t, report.Size > int64(len("pool/main/f/foo/foo_1.0-1_amd64.deb")+len("orphaned")))
+	/* Only the orphan isn't hardlinked into the snapshot */
+	assert(t, report.Reclaimed == int64(len("orphaned")))
+	assert(t, exists(repo.Root, "pool/main/f/foo/foo_1.0-1_amd64.deb"))
+	assert(t, exists(repo.Root, "pool/main/o/orphan/orphan_1.0_all.deb"))
+
+	report, err = repo.Prune(archive.PruneOptions{Retain: []string{"one"}})
+	isok(t, err)
+	assert(t, len(report.Files) == 1)
+	assert(t, report.Files[0] == "pool/main/o/orphan/orphan_1.0_all.deb")
+	assert(t, exists(repo.Root, "pool/main/f/foo/foo_1.0-1_amd64.deb"))
+	assert(t, !exists(repo.Root, "pool/main/o"))
+
+	_, err = repo.Prune(archive.PruneOptions{Retain: []string{"two"}})
+	notok(t, err)
+
+	removed, err := repo.GarbageCollect()
+	isok(t, err)
+	assert(t, len(removed) == 3)
+	assert(t, exists(repo.Root, "pool/main/f/foo/foo_2.0-1_amd64.deb"))
+}
+
+// vim: foldmethod=marker
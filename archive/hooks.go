@@ -0,0 +1,134 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package archive // import "github.com/ebikt/go-debian/archive"
+
+import (
+	"io"
+	"strings"
+
+	"github.com/ebikt/go-debian/control"
+)
+
+// Index hooks {{{
+
+// An IndexHook transforms a paragraph of a Packages or Sources index before
+// it's written out, in place. Returning false drops the paragraph from the
+// index.
+type IndexHook func(para *control.Paragraph) (bool, error)
+
+// SetField returns an IndexHook setting a field on every paragraph, such as
+// an Origin field.
+func SetField(key, value string) IndexHook {
+	return func(para *control.Paragraph) (bool, error) {
+		para.Set(key, value)
+		return true, nil
+	}
+}
+
+// RemoveFields returns an IndexHook removing the given fields from every
+// paragraph, such as Phased-Update-Percentage.
+func RemoveFields(keys ...string) IndexHook {
+	return func(para *control.Paragraph) (bool, error) {
+		for _, key := range keys {
+			para.Delete(key)
+		}
+		return true, nil
+	}
+}
+
+// PrefixFilename returns an IndexHook prepending a prefix to the Filename
+// field of Packages paragraphs, and to the Directory field of Sources ones,
+// for instance to serve the pool from a CDN.
+func PrefixFilename(prefix string) IndexHook {
+	return func(para *control.Paragraph) (bool, error) {
+		for _, key := range []string{"Filename", "Directory"} {
+			if value, ok := para.Get2(key); ok {
+				para.Set(key, strings.TrimSuffix(prefix, "/")+"/"+strings.TrimPrefix(value, "/"))
+			}
+		}
+		return true, nil
+	}
+}
+
+// IndexWriter writes the paragraphs of a Packages or Sources index, running
+// them through its Hooks first, in order.
+type IndexWriter struct {
+	Hooks []IndexHook
+
+	out     io.Writer
+	written bool
+}
+
+// NewIndexWriter creates an IndexWriter writing the index to `out`.
+func NewIndexWriter(out io.Writer, hooks ...IndexHook) *IndexWriter {
+	return &IndexWriter{Hooks: hooks, out: out}
+}
+
+// AddHook registers an IndexHook, run after the ones already there.
+func (w *IndexWriter) AddHook(hook IndexHook) {
+	w.Hooks = append(w.Hooks, hook)
+}
+
+// Write transforms a paragraph with the Hooks, and writes the outcome
+// unless a hook dropped it. The given paragraph isn't changed.
+func (w *IndexWriter) Write(para control.Paragraph) error {
+	copied := control.NewParagraph()
+	copied = para.Update(copied)
+	for _, hook := range w.Hooks {
+		keep, err := hook(&copied)
+		if err != nil {
+			return err
+		}
+		if !keep {
+			return nil
+		}
+	}
+	if w.written {
+		if _, err := io.WriteString(w.out, "\n"); err != nil {
+			return err
+		}
+	}
+	w.written = true
+	return copied.WriteTo(w.out)
+}
+
+// Copy writes every paragraph of the index read from `in`, see Write.
+func (w *IndexWriter) Copy(in io.Reader) error {
+	reader, err := control.NewParagraphReader(in, nil)
+	if err != nil {
+		return err
+	}
+	for {
+		para, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if err := w.Write(*para); err != nil {
+			return err
+		}
+	}
+}
+
+// }}}
+
+// vim: foldmethod=marker
@@ -0,0 +1,83 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package archive_test
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/ebikt/go-debian/archive"
+	"github.com/ebikt/go-debian/control"
+)
+
+/*
+ *
+ */
+
+func TestIndexWriterHooks(t *testing.T) {
+	out := bytes.Buffer{}
+	w := archive.NewIndexWriter(&out,
+		archive.SetField("Origin", "Example"),
+		archive.RemoveFields("Phased-Update-Percentage"),
+	)
+	w.AddHook(archive.PrefixFilename("https://cdn.example.com/debian/"))
+	w.AddHook(func(para *control.Paragraph) (bool, error) {
+		return para.Get("Package") != "secret", nil
+	})
+
+	isok(t, w.Copy(strings.NewReader(`Package: hello
+Version: 2.10-3
+Phased-Update-Percentage: 10
+Filename: pool/main/h/hello/hello_2.10-3_amd64.deb
+
+Package: secret
+Version: 1.0
+Filename: pool/main/s/secret/secret_1.0_all.deb
+
+Package: hello-doc
+Version: 2.10-3
+Filename: pool/main/h/hello/hello-doc_2.10-3_all.deb
+`)))
+	assert(t, out.String() == `Package: hello
+Version: 2.10-3
+Filename: https://cdn.example.com/debian/pool/main/h/hello/hello_2.10-3_amd64.deb
+Origin: Example
+
+Package: hello-doc
+Version: 2.10-3
+Filename: https://cdn.example.com/debian/pool/main/h/hello/hello-doc_2.10-3_all.deb
+Origin: Example
+`)
+
+	para := control.NewParagraph()
+	para.Set("Package", "hello")
+	isok(t, w.Write(para))
+	assert(t, !para.Has("Origin"))
+
+	w.AddHook(func(para *control.Paragraph) (bool, error) {
+		return false, fmt.Errorf("Broken hook")
+	})
+	notok(t, w.Write(para))
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,411 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package archive // import "github.com/ebikt/go-debian/archive"
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/openpgp"
+
+	"github.com/ebikt/go-debian/control"
+	"github.com/ebikt/go-debian/dependency"
+	"github.com/ebikt/go-debian/hashio"
+	"github.com/ebikt/go-debian/internal"
+)
+
+// Importing {{{
+
+// Importer copies the binary packages of a suite of a remote repository,
+// such as a third party one, into a local Repository: the .deb files land
+// in the local pool at the same paths, every version listed is kept, and
+// the local suite gets Packages indexes and a Release file of its own. This
+// is what vendoring a repository, or feeding an air-gapped one, takes.
+type Importer struct {
+	// Where the packages are imported from.
+	Source Fetcher
+
+	// Where the packages are imported to.
+	Repository *Repository
+
+	// If set, the InRelease file (or else the Release file, along with
+	// Release.gpg) must be signed by a key of this keyring.
+	Keyring *openpgp.EntityList
+
+	// If set, checks the signatures instead of the Keyring.
+	Verifier control.Verifier
+
+	// Components and architectures to import; all the ones of the remote
+	// Release file if empty.
+	Components    []string
+	Architectures []string
+
+	// Names of the packages to import, which may be wildcards such as
+	// "libfoo*"; every package if empty.
+	Packages []string
+
+	// Variants of the local Packages indexes to write; the uncompressed,
+	// gzip and xz ones if empty.
+	Compressions []Compression
+
+	// If set, the local Release file is signed by this key, as InRelease
+	// and Release.gpg.
+	Signer *openpgp.Entity
+}
+
+// ImportReport tells what an Import did.
+type ImportReport struct {
+	// Number of index entries imported.
+	Packages int
+
+	// Pool files downloaded, and the ones that were already there, as
+	// slash separated paths relative to the repository root.
+	Fetched []string
+	Reused  []string
+}
+
+// Import imports the remote suite as the local suite `into`, or under its
+// own name if `into` is empty. The indexes of the imported components and
+// architectures are replaced, and the Release file of the local suite is
+// written again.
+func (i *Importer) Import(ctx context.Context, suite, into string) (*ImportReport, error) {
+	if into == "" {
+		into = suite
+	}
+	client := &Client{
+		Entry: SourceEntry{
+			Suite:         suite,
+			Components:    i.Components,
+			Architectures: i.Architectures,
+		},
+		Fetcher:  i.Source,
+		Keyring:  i.Keyring,
+		Verifier: i.Verifier,
+	}
+	release, err := client.Update(ctx)
+	if err != nil {
+		return nil, err
+	}
+	components, architectures := client.Components(), client.Architectures()
+
+	report := ImportReport{Fetched: []string{}, Reused: []string{}}
+	for _, component := range components {
+		for _, arch := range architectures {
+			name := PackagesIndex(component, arch, false)
+			if err := i.importIndex(ctx, client, into, name, &report); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := i.writeRelease(into, release, components, architectures); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+func (i *Importer) wanted(name string) bool {
+	if len(i.Packages) == 0 {
+		return true
+	}
+	for _, pattern := range i.Packages {
+		if internal.GlobMatch(pattern, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (i *Importer) importIndex(ctx context.Context, client *Client, into, name string, report *ImportReport) error {
+	index, err := client.FetchIndex(ctx, name)
+	if err != nil {
+		return err
+	}
+	paragraphs, err := control.NewParagraphReader(index, nil)
+	if err != nil {
+		return err
+	}
+
+	dest := i.Repository.path("dists/" + into + "/" + name)
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	compressions := i.Compressions
+	if len(compressions) == 0 {
+		compressions = []Compression{{Extension: ""}, {Extension: "gz"}, {Extension: "xz"}}
+	}
+	out, err := NewCompressedWriter(dest, compressions...)
+	if err != nil {
+		return err
+	}
+	writer := NewIndexWriter(out)
+
+	for {
+		para, err := paragraphs.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			out.abort()
+			return err
+		}
+		if !i.wanted(para.Get("Package")) {
+			continue
+		}
+		if err := i.importPoolFile(ctx, *para, report); err != nil {
+			out.abort()
+			return err
+		}
+		if err := writer.Write(*para); err != nil {
+			out.abort()
+			return err
+		}
+		report.Packages++
+	}
+	return out.Close()
+}
+
+// poolHashes returns the checksums of the file of a Packages entry, the
+// strongest ones available.
+func poolHashes(filename string, para control.Paragraph) (control.FileHashes, error) {
+	size, err := strconv.ParseInt(para.Get("Size"), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid Size for %s: '%s'", filename, para.Get("Size"))
+	}
+	for _, field := range []struct{ key, algorithm string }{
+		{"SHA512", "sha512"},
+		{"SHA256", "sha256"},
+		{"SHA1", "sha1"},
+		{"MD5sum", "md5"},
+	} {
+		if hash := para.Get(field.key); hash != "" {
+			return control.FileHashes{{
+				Algorithm: field.algorithm,
+				Hash:      hash,
+				Size:      size,
+				Filename:  filename,
+			}}, nil
+		}
+	}
+	return nil, fmt.Errorf("No checksum for %s", filename)
+}
+
+// importPoolFile downloads the file of a Packages entry into the local
+// pool, unless it's already there.
+func (i *Importer) importPoolFile(ctx context.Context, para control.Paragraph, report *ImportReport) error {
+	filename := path.Clean(para.Get("Filename"))
+	if !strings.HasPrefix(filename, "pool/") {
+		return fmt.Errorf("Refusing to import '%s', which is not in the pool", para.Get("Filename"))
+	}
+	hashes, err := poolHashes(filename, para)
+	if err != nil {
+		return err
+	}
+
+	if err := hashes.Verify(i.Repository.Root); err == nil {
+		report.Reused = append(report.Reused, filename)
+		return nil
+	}
+
+	dest := i.Repository.path(filename)
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	err = internal.WriteFileAtomic(dest, 0644, func(out io.Writer) error {
+		body, err := i.Source.Fetch(ctx, filename)
+		if err != nil {
+			return err
+		}
+		defer body.Close()
+		return hashes.VerifyReader(io.TeeReader(body, out))
+	})
+	if err != nil {
+		return err
+	}
+	report.Fetched = append(report.Fetched, filename)
+	return nil
+}
+
+// writeRelease writes the Release file of the local suite, listing all of
+// its indexes, and signs it if there's a Signer.
+func (i *Importer) writeRelease(into string, remote *Release, components, architectures []string) error {
+	release := Release{
+		Origin:     remote.Origin,
+		Label:      remote.Label,
+		Suite:      into,
+		Date:       time.Now().UTC().Format(time.RFC1123),
+		Components: components,
+	}
+	if into == remote.Suite || into == remote.Codename {
+		release.Suite, release.Codename = remote.Suite, remote.Codename
+	}
+	for _, arch := range architectures {
+		parsed, err := dependency.ParseArch(arch)
+		if err != nil {
+			return err
+		}
+		release.Architectures = append(release.Architectures, *parsed)
+	}
+
+	return writeSuiteRelease(i.Repository.path("dists/"+into), release, i.Signer, nil)
+}
+
+// writeSuiteRelease lists the checksums of the indexes of the suite
+// directory in the Release, and writes it, along with InRelease and
+// Release.gpg if there's a signer. With Acquire-By-Hash set, the indexes
+// are linked under their by-hash paths too.
+func writeSuiteRelease(dir string, release Release, signer *openpgp.Entity, known map[string]control.FileHashes) error {
+	if err := hashIndexes(dir, &release, known); err != nil {
+		return err
+	}
+	if release.AcquireByHash {
+		if err := linkByHash(dir, release); err != nil {
+			return err
+		}
+	}
+
+	data := bytes.Buffer{}
+	if err := control.Marshal(&data, release); err != nil {
+		return err
+	}
+	files := map[string]func(io.Writer) error{
+		"Release": func(out io.Writer) error {
+			_, err := out.Write(data.Bytes())
+			return err
+		},
+	}
+	if signer != nil {
+		files["InRelease"] = func(out io.Writer) error {
+			return control.ClearSign(out, bytes.NewReader(data.Bytes()), signer, nil)
+		}
+		files["Release.gpg"] = func(out io.Writer) error {
+			return control.DetachSign(out, bytes.NewReader(data.Bytes()), signer, nil)
+		}
+	}
+	for name, write := range files {
+		if err := internal.WriteFileAtomic(filepath.Join(dir, name), 0644, write); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// linkByHash links each index of the suite directory under its by-hash
+// paths, "by-hash/MD5Sum/<md5>" and "by-hash/SHA256/<sha256>" next to it.
+func linkByHash(dir string, release Release) error {
+	hashes := []control.FileHash{}
+	for _, hash := range release.MD5Sum {
+		hash.ByHash = "MD5Sum"
+		hashes = append(hashes, hash.FileHash)
+	}
+	for _, hash := range release.SHA256 {
+		hash.ByHash = "SHA256"
+		hashes = append(hashes, hash.FileHash)
+	}
+	for _, hash := range hashes {
+		dest := filepath.Join(dir, filepath.FromSlash(hash.ByHashPath(hash.Filename)))
+		if _, err := os.Stat(dest); err == nil {
+			continue
+		}
+		if err := linkFile(filepath.Join(dir, filepath.FromSlash(hash.Filename)), dest); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// releaseHashes are the algorithms of the checksums listed by the Release
+// files written.
+var releaseHashes = []string{"md5", "sha256"}
+
+// hashIndexes lists the checksums of the indexes found in the suite
+// directory in the Release. The ones known already, by the path of the
+// index relative to the directory, aren't read again.
+func hashIndexes(dir string, release *Release, known map[string]control.FileHashes) error {
+	names := []string{}
+	err := filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if info.Name() == "by-hash" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		switch rel = filepath.ToSlash(rel); rel {
+		case "Release", "InRelease", "Release.gpg":
+		default:
+			names = append(names, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		hashes := map[string]control.FileHash{}
+		for _, hash := range known[name] {
+			hash.Filename = name
+			hashes[hash.Algorithm] = hash
+		}
+		if len(hashes) < len(releaseHashes) {
+			f, err := os.Open(filepath.Join(dir, filepath.FromSlash(name)))
+			if err != nil {
+				return err
+			}
+			writer, hashers, err := hashio.NewHasherWriters(releaseHashes, io.Discard)
+			if err != nil {
+				f.Close()
+				return err
+			}
+			_, err = io.Copy(writer, f)
+			f.Close()
+			if err != nil {
+				return err
+			}
+			for _, hasher := range hashers {
+				hashes[hasher.Name()] = control.FileHashFromHasher(name, *hasher)
+			}
+		}
+		release.MD5Sum = append(release.MD5Sum, control.MD5FileHash{FileHash: hashes["md5"]})
+		release.SHA256 = append(release.SHA256, control.SHA256FileHash{FileHash: hashes["sha256"]})
+	}
+	return nil
+}
+
+// }}}
+
+// vim: foldmethod=marker
@@ -0,0 +1,167 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package archive_test
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ebikt/go-debian/archive"
+	"github.com/ebikt/go-debian/control"
+	"github.com/ebikt/go-debian/testutil"
+)
+
+/*
+ *
+ */
+
+func importSource(t *testing.T) (*testutil.Repository, string) {
+	key, err := testutil.NewKey("Importer", "importer@example.com")
+	isok(t, err)
+	source := testutil.NewRepository(key)
+
+	packages, err := source.AddDebs("main",
+		testutil.Deb{Package: "foo", Version: "1.0-1", Architecture: "amd64"},
+		testutil.Deb{Package: "foo", Version: "2.0-1", Architecture: "amd64"},
+		testutil.Deb{Package: "bar", Version: "1.0-1", Architecture: "amd64"},
+	)
+	isok(t, err)
+	isok(t, source.AddSuite(testutil.Suite{
+		Origin:        "Vendor",
+		Label:         "Vendor",
+		Suite:         "stable",
+		Codename:      "vendor1",
+		Architectures: []string{"amd64"},
+		Components:    []string{"main"},
+		Indexes: map[string][]byte{
+			"main/binary-amd64/Packages": packages,
+		},
+	}))
+	return source, source.Start()
+}
+
+func TestImport(t *testing.T) {
+	source, url := importSource(t)
+	defer source.Close()
+
+	root, err := ioutil.TempDir("", "go-debian-import")
+	isok(t, err)
+	defer os.RemoveAll(root)
+	repo, err := archive.NewRepository(root)
+	isok(t, err)
+
+	signer, err := testutil.NewKey("Local", "local@example.com")
+	isok(t, err)
+	importer := archive.Importer{
+		Source:     archive.HTTPFetcher{BaseURL: url},
+		Repository: repo,
+		Keyring:    testutil.Keyring(source.Signer),
+		Packages:   []string{"fo*"},
+		Signer:     signer,
+	}
+	report, err := importer.Import(context.Background(), "stable", "vendor")
+	isok(t, err)
+	assert(t, report.Packages == 2)
+	assert(t, len(report.Fetched) == 2)
+	assert(t, len(report.Reused) == 0)
+	assert(t, exists(root, "pool/main/f/foo/foo_1.0-1_amd64.deb"))
+	assert(t, exists(root, "pool/main/f/foo/foo_2.0-1_amd64.deb"))
+	assert(t, !exists(root, "pool/main/b"))
+	assert(t, exists(root, "dists/vendor/main/binary-amd64/Packages.xz"))
+	assert(t, exists(root, "dists/vendor/InRelease"))
+
+	packages, err := control.OpenBinaryIndex(filepath.Join(root, "dists/vendor/main/binary-amd64/Packages.gz"))
+	isok(t, err)
+	defer packages.Close()
+	entry, err := packages.Next()
+	isok(t, err)
+	assert(t, entry.Package == "foo")
+
+	f, err := os.Open(filepath.Join(root, "dists/vendor/InRelease"))
+	isok(t, err)
+	defer f.Close()
+	cleartext, _, err := control.DecodeClearsigned(f, testutil.Keyring(signer))
+	isok(t, err)
+	release, err := archive.ParseRelease(bytes.NewReader(cleartext))
+	isok(t, err)
+	assert(t, release.Suite == "vendor")
+	assert(t, release.Codename == "")
+	assert(t, release.Origin == "Vendor")
+	packagesFile, err := os.Open(filepath.Join(root, "dists/vendor/main/binary-amd64/Packages"))
+	isok(t, err)
+	defer packagesFile.Close()
+	isok(t, release.Validate("main/binary-amd64/Packages", packagesFile))
+
+	/* Importing again finds the files already there */
+	report, err = importer.Import(context.Background(), "stable", "vendor")
+	isok(t, err)
+	assert(t, len(report.Fetched) == 0)
+	assert(t, len(report.Reused) == 2)
+}
+
+func TestImportBadSignature(t *testing.T) {
+	source, url := importSource(t)
+	defer source.Close()
+
+	root, err := ioutil.TempDir("", "go-debian-import")
+	isok(t, err)
+	defer os.RemoveAll(root)
+	repo, err := archive.NewRepository(root)
+	isok(t, err)
+
+	other, err := testutil.NewKey("Other", "other@example.com")
+	isok(t, err)
+	importer := archive.Importer{
+		Source:     archive.HTTPFetcher{BaseURL: url},
+		Repository: repo,
+		Keyring:    testutil.Keyring(other),
+	}
+	_, err = importer.Import(context.Background(), "stable", "")
+	notok(t, err)
+	assert(t, !exists(root, "dists"))
+}
+
+func TestImportBadChecksum(t *testing.T) {
+	source, url := importSource(t)
+	defer source.Close()
+	source.AddFile("pool/main/b/bar/bar_1.0-1_amd64.deb", []byte("tampered"))
+
+	root, err := ioutil.TempDir("", "go-debian-import")
+	isok(t, err)
+	defer os.RemoveAll(root)
+	repo, err := archive.NewRepository(root)
+	isok(t, err)
+
+	importer := archive.Importer{
+		Source:     archive.HTTPFetcher{BaseURL: url},
+		Repository: repo,
+		Packages:   []string{"bar"},
+	}
+	_, err = importer.Import(context.Background(), "stable", "")
+	notok(t, err)
+	assert(t, !exists(root, "pool/main/b/bar/bar_1.0-1_amd64.deb"))
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,267 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package archive // import "github.com/ebikt/go-debian/archive"
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/openpgp"
+)
+
+// Keyrings {{{
+
+// ReadKeyring reads a keyring, be it ASCII armored, like the .asc files of
+// /etc/apt/trusted.gpg.d, or binary, like its .gpg files.
+func ReadKeyring(reader io.Reader) (openpgp.EntityList, error) {
+	buffered := bufio.NewReader(reader)
+	for {
+		c, err := buffered.Peek(1)
+		if err != nil || !strings.ContainsRune(" \t\r\n", rune(c[0])) {
+			break
+		}
+		buffered.ReadByte()
+	}
+	if prefix, _ := buffered.Peek(14); string(prefix) == "-----BEGIN PGP" {
+		return openpgp.ReadArmoredKeyRing(buffered)
+	}
+	return openpgp.ReadKeyRing(buffered)
+}
+
+// LoadKeyring reads the keyring at the given path, see ReadKeyring.
+func LoadKeyring(path string) (openpgp.EntityList, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	keyring, err := ReadKeyring(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return keyring, nil
+}
+
+// LoadTrustedKeyrings reads the keys apt trusts for the sources with no
+// Signed-By, found below the given root directory ("/" for the running
+// system): etc/apt/trusted.gpg, then the .gpg and .asc files of
+// etc/apt/trusted.gpg.d, in order.
+func LoadTrustedKeyrings(root string) (openpgp.EntityList, error) {
+	ret, err := LoadKeyring(filepath.Join(root, "etc/apt/trusted.gpg"))
+	if os.IsNotExist(err) {
+		ret = openpgp.EntityList{}
+	} else if err != nil {
+		return nil, err
+	}
+	dir := filepath.Join(root, "etc/apt/trusted.gpg.d")
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	names := []string{}
+	for _, entry := range entries {
+		if ext := filepath.Ext(entry.Name()); !entry.IsDir() && (ext == ".gpg" || ext == ".asc") {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		keyring, err := LoadKeyring(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, keyring...)
+	}
+	return ret, nil
+}
+
+// isFingerprint tells whether a Signed-By value is the fingerprint of a
+// key, possibly ending with a "!" to only allow that very (sub)key.
+func isFingerprint(value string) bool {
+	value = strings.TrimSuffix(value, "!")
+	if len(value) != 40 {
+		return false
+	}
+	for _, c := range value {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", c) {
+			return false
+		}
+	}
+	return true
+}
+
+// hasFingerprint tells whether the primary key of the entity, or one of
+// its subkeys, has the given fingerprint, in hex.
+func hasFingerprint(entity *openpgp.Entity, fingerprint string) bool {
+	fingerprint = strings.ToUpper(strings.TrimSuffix(fingerprint, "!"))
+	if fmt.Sprintf("%X", entity.PrimaryKey.Fingerprint[:]) == fingerprint {
+		return true
+	}
+	for _, subkey := range entity.Subkeys {
+		if fmt.Sprintf("%X", subkey.PublicKey.Fingerprint[:]) == fingerprint {
+			return true
+		}
+	}
+	return false
+}
+
+// restrictKey returns a copy of the entity only allowed to sign with the
+// (sub)key of the given fingerprint, in hex: without its subkeys for its
+// primary key, or else without its other subkeys, and with its identities
+// no longer allowing its primary key to sign.
+func restrictKey(entity *openpgp.Entity, fingerprint string) *openpgp.Entity {
+	fingerprint = strings.ToUpper(strings.TrimSuffix(fingerprint, "!"))
+	ret := *entity
+	ret.Subkeys = []openpgp.Subkey{}
+	if fmt.Sprintf("%X", entity.PrimaryKey.Fingerprint[:]) == fingerprint {
+		return &ret
+	}
+	for _, subkey := range entity.Subkeys {
+		if fmt.Sprintf("%X", subkey.PublicKey.Fingerprint[:]) == fingerprint {
+			ret.Subkeys = append(ret.Subkeys, subkey)
+		}
+	}
+	ret.Identities = map[string]*openpgp.Identity{}
+	for name, identity := range entity.Identities {
+		restricted := *identity
+		if identity.SelfSignature != nil {
+			signature := *identity.SelfSignature
+			signature.FlagsValid = true
+			signature.FlagSign = false
+			restricted.SelfSignature = &signature
+		}
+		ret.Identities[name] = &restricted
+	}
+	return &ret
+}
+
+// Keyring returns the keys allowed to sign the Release files of the
+// source, like apt does, below the given root directory ("/" for the
+// running system). They are the keys of its Signed-By: either a key
+// embedded in it, or keyrings and fingerprints of keys of the trusted
+// keyrings, a fingerprint ending with a "!" only allowing that very
+// (sub)key to sign, and not the rest of its key; with no Signed-By, they
+// are all the keys of the trusted keyrings, see LoadTrustedKeyrings.
+func (s Source) Keyring(root string) (*openpgp.EntityList, error) {
+	signedBy := strings.TrimSpace(s.SignedBy)
+	if strings.HasPrefix(signedBy, "-----BEGIN PGP") {
+		keyring, err := ReadKeyring(strings.NewReader(signedBy))
+		if err != nil {
+			return nil, fmt.Errorf("Signed-By: %w", err)
+		}
+		return &keyring, nil
+	}
+
+	var trusted openpgp.EntityList
+	loadTrusted := func() error {
+		var err error
+		if trusted == nil {
+			trusted, err = LoadTrustedKeyrings(root)
+		}
+		return err
+	}
+	if signedBy == "" {
+		if err := loadTrusted(); err != nil {
+			return nil, err
+		}
+		return &trusted, nil
+	}
+
+	ret := openpgp.EntityList{}
+	for _, value := range strings.Fields(signedBy) {
+		if !isFingerprint(value) {
+			keyring, err := LoadKeyring(filepath.Join(root, value))
+			if err != nil {
+				return nil, err
+			}
+			ret = append(ret, keyring...)
+			continue
+		}
+		if err := loadTrusted(); err != nil {
+			return nil, err
+		}
+		found := false
+		for _, entity := range trusted {
+			if !hasFingerprint(entity, value) {
+				continue
+			}
+			if strings.HasSuffix(value, "!") {
+				entity = restrictKey(entity, value)
+			}
+			ret = append(ret, entity)
+			found = true
+		}
+		if !found {
+			return nil, fmt.Errorf("Signed-By: no trusted key has the fingerprint %s", value)
+		}
+	}
+	return &ret, nil
+}
+
+// }}}
+
+// Usable keys {{{
+
+// KeyError tells that none of the keys allowed to sign can be used, as
+// they're all expired or revoked.
+type KeyError struct {
+	Keys []KeyStatus
+}
+
+func (e *KeyError) Error() string {
+	if len(e.Keys) == 0 {
+		return "No key to check the signatures with"
+	}
+	reasons := []string{}
+	for _, key := range e.Keys {
+		reasons = append(reasons, key.String())
+	}
+	return fmt.Sprintf("No usable key to check the signatures with: %s", strings.Join(reasons, ", "))
+}
+
+// UsableKeys returns the keys of the keyring which can check signatures at
+// the time `now`, or a *KeyError telling why none can.
+func UsableKeys(keyring openpgp.EntityList, now time.Time) (openpgp.EntityList, error) {
+	ret := openpgp.EntityList{}
+	statuses := []KeyStatus{}
+	for _, entity := range keyring {
+		status := NewKeyStatus(entity, now, 0)
+		if status.Usable() {
+			ret = append(ret, entity)
+		}
+		statuses = append(statuses, status)
+	}
+	if len(ret) == 0 {
+		return nil, &KeyError{Keys: statuses}
+	}
+	return ret, nil
+}
+
+// }}}
+
+// vim: foldmethod=marker
@@ -0,0 +1,204 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package archive_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/packet"
+
+	"github.com/ebikt/go-debian/archive"
+	"github.com/ebikt/go-debian/testutil"
+)
+
+/*
+ *
+ */
+
+func binaryKeyring(t *testing.T, entities ...*openpgp.Entity) []byte {
+	out := bytes.Buffer{}
+	for _, entity := range entities {
+		isok(t, entity.Serialize(&out))
+	}
+	return out.Bytes()
+}
+
+func armoredKeyring(t *testing.T, entity *openpgp.Entity) []byte {
+	data, err := testutil.ArmoredPublicKey(entity)
+	isok(t, err)
+	return data
+}
+
+func TestReadKeyring(t *testing.T) {
+	first, err := testutil.NewKey("First", "first@example.com")
+	isok(t, err)
+	second, err := testutil.NewKey("Second", "second@example.com")
+	isok(t, err)
+
+	keyring, err := archive.ReadKeyring(bytes.NewReader(binaryKeyring(t, first, second)))
+	isok(t, err)
+	assert(t, len(keyring) == 2)
+	assert(t, keyring[1].PrimaryKey.KeyId == second.PrimaryKey.KeyId)
+
+	keyring, err = archive.ReadKeyring(bytes.NewReader(append([]byte("\n"), armoredKeyring(t, first)...)))
+	isok(t, err)
+	assert(t, len(keyring) == 1)
+	assert(t, keyring[0].PrimaryKey.KeyId == first.PrimaryKey.KeyId)
+
+	_, err = archive.ReadKeyring(strings.NewReader("not a keyring"))
+	notok(t, err)
+}
+
+func TestLoadTrustedKeyrings(t *testing.T) {
+	first, err := testutil.NewKey("First", "first@example.com")
+	isok(t, err)
+	second, err := testutil.NewKey("Second", "second@example.com")
+	isok(t, err)
+	third, err := testutil.NewKey("Third", "third@example.com")
+	isok(t, err)
+
+	root := t.TempDir()
+	keyring, err := archive.LoadTrustedKeyrings(root)
+	isok(t, err)
+	assert(t, len(keyring) == 0)
+
+	isok(t, testutil.WriteFiles(root, map[string][]byte{
+		"etc/apt/trusted.gpg":                    binaryKeyring(t, first),
+		"etc/apt/trusted.gpg.d/b.asc":            armoredKeyring(t, third),
+		"etc/apt/trusted.gpg.d/a.gpg":            binaryKeyring(t, second),
+		"etc/apt/trusted.gpg.d/ignored.gpg~":     []byte("not a keyring"),
+		"usr/share/keyrings/example-keyring.gpg": binaryKeyring(t, third),
+	}))
+	keyring, err = archive.LoadTrustedKeyrings(root)
+	isok(t, err)
+	assert(t, len(keyring) == 3)
+	assert(t, keyring[0].PrimaryKey.KeyId == first.PrimaryKey.KeyId)
+	assert(t, keyring[1].PrimaryKey.KeyId == second.PrimaryKey.KeyId)
+	assert(t, keyring[2].PrimaryKey.KeyId == third.PrimaryKey.KeyId)
+
+	isok(t, os.WriteFile(filepath.Join(root, "etc/apt/trusted.gpg.d/c.gpg"), []byte("broken"), 0644))
+	_, err = archive.LoadTrustedKeyrings(root)
+	notok(t, err)
+	assert(t, strings.Contains(err.Error(), "c.gpg"))
+}
+
+func TestSourceKeyring(t *testing.T) {
+	trusted, err := testutil.NewKey("Trusted", "trusted@example.com")
+	isok(t, err)
+	other, err := testutil.NewKey("Other", "other@example.com")
+	isok(t, err)
+	root := t.TempDir()
+	isok(t, testutil.WriteFiles(root, map[string][]byte{
+		"etc/apt/trusted.gpg.d/trusted.gpg":      binaryKeyring(t, trusted, other),
+		"usr/share/keyrings/example-keyring.asc": armoredKeyring(t, other),
+	}))
+
+	keyring, err := archive.Source{}.Keyring(root)
+	isok(t, err)
+	assert(t, len(*keyring) == 2)
+
+	keyring, err = archive.Source{SignedBy: "/usr/share/keyrings/example-keyring.asc"}.Keyring(root)
+	isok(t, err)
+	assert(t, len(*keyring) == 1)
+	assert(t, (*keyring)[0].PrimaryKey.KeyId == other.PrimaryKey.KeyId)
+
+	inline := strings.TrimSpace(string(armoredKeyring(t, trusted)))
+	inline = strings.Replace(strings.Replace(inline, "\n", "\n ", -1), "\n \n", "\n .\n", -1)
+	sources, err := archive.ParseSources(strings.NewReader("Types: deb\nURIs: https://example.com/debian\nSuites: stable\nComponents: main\nSigned-By:\n " + inline + "\n"))
+	isok(t, err)
+	keyring, err = sources[0].Keyring(root)
+	isok(t, err)
+	assert(t, len(*keyring) == 1)
+	assert(t, (*keyring)[0].PrimaryKey.KeyId == trusted.PrimaryKey.KeyId)
+
+	fingerprint := archive.NewKeyStatus(trusted, time.Now(), 0).Fingerprint
+	keyring, err = archive.Source{SignedBy: strings.ToLower(fingerprint)}.Keyring(root)
+	isok(t, err)
+	assert(t, len(*keyring) == 1)
+	assert(t, (*keyring)[0].PrimaryKey.KeyId == trusted.PrimaryKey.KeyId)
+
+	keyring, err = archive.Source{SignedBy: fingerprint + "! /usr/share/keyrings/example-keyring.asc"}.Keyring(root)
+	isok(t, err)
+	assert(t, len(*keyring) == 2)
+
+	/* "!" only allows that very (sub)key */
+	signature := bytes.Buffer{}
+	isok(t, openpgp.DetachSign(&signature, trusted, strings.NewReader("signed"), nil))
+	check := func(keyring *openpgp.EntityList) error {
+		_, err := openpgp.CheckDetachedSignature(*keyring, strings.NewReader("signed"), bytes.NewReader(signature.Bytes()))
+		return err
+	}
+	assert(t, len((*keyring)[0].Subkeys) == 0)
+	isok(t, check(keyring))
+	assert(t, len(trusted.Subkeys) == 1)
+	subkey := fmt.Sprintf("%X", trusted.Subkeys[0].PublicKey.Fingerprint[:])
+	keyring, err = archive.Source{SignedBy: subkey}.Keyring(root)
+	isok(t, err)
+	isok(t, check(keyring))
+	keyring, err = archive.Source{SignedBy: subkey + "!"}.Keyring(root)
+	isok(t, err)
+	assert(t, len(*keyring) == 1)
+	assert(t, len((*keyring)[0].Subkeys) == 1)
+	notok(t, check(keyring))
+	assert(t, len(trusted.Subkeys) == 1)
+	isok(t, check(testutil.Keyring(trusted)))
+
+	_, err = archive.Source{SignedBy: strings.Repeat("0", 40)}.Keyring(root)
+	notok(t, err)
+	_, err = archive.Source{SignedBy: "/usr/share/keyrings/missing.gpg"}.Keyring(root)
+	notok(t, err)
+}
+
+func TestUsableKeys(t *testing.T) {
+	now := time.Now()
+	expired := expiringKey(t, "Expired", time.Hour)
+	revoked, err := testutil.NewKey("Revoked", "revoked@example.com")
+	isok(t, err)
+	revoked.Revocations = append(revoked.Revocations, &packet.Signature{})
+	valid, err := testutil.NewKey("Valid", "valid@example.com")
+	isok(t, err)
+
+	keyring, err := archive.UsableKeys(*testutil.Keyring(expired, revoked, valid), now.Add(2*time.Hour))
+	isok(t, err)
+	assert(t, len(keyring) == 1 && keyring[0] == valid)
+
+	_, err = archive.UsableKeys(*testutil.Keyring(expired, revoked), now.Add(2*time.Hour))
+	keyErr := &archive.KeyError{}
+	assert(t, errors.As(err, &keyErr))
+	assert(t, len(keyErr.Keys) == 2)
+	assert(t, keyErr.Keys[0].Expired && keyErr.Keys[1].Revoked)
+	assert(t, strings.Contains(err.Error(), "expired on"))
+	assert(t, strings.Contains(err.Error(), "is revoked"))
+
+	_, err = archive.UsableKeys(openpgp.EntityList{}, now)
+	assert(t, errors.As(err, &keyErr))
+	assert(t, len(keyErr.Keys) == 0)
+}
+
+// vim: foldmethod=marker
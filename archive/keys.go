@@ -0,0 +1,277 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package archive // import "github.com/ebikt/go-debian/archive"
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/packet"
+
+	"github.com/ebikt/go-debian/control"
+)
+
+// Key expiry {{{
+
+// KeyStatus tells how soon a key of a keyring stops being usable to check
+// the signatures of Release files.
+type KeyStatus struct {
+	Entity *openpgp.Entity
+
+	// Fingerprint of the primary key, in upper case hex, and its primary
+	// user id.
+	Fingerprint string
+	Identity    string
+
+	// When the key stops being able to sign, which is when the last of its
+	// signing keys expires; zero if it never does.
+	Expires time.Time
+
+	Expired     bool
+	ExpiresSoon bool
+	Revoked     bool
+}
+
+func (s KeyStatus) String() string {
+	switch {
+	case s.Revoked:
+		return fmt.Sprintf("%s (%s) is revoked", s.Fingerprint, s.Identity)
+	case s.Expired:
+		return fmt.Sprintf("%s (%s) expired on %s", s.Fingerprint, s.Identity, s.Expires.Format("2006-01-02"))
+	case s.Expires.IsZero():
+		return fmt.Sprintf("%s (%s) never expires", s.Fingerprint, s.Identity)
+	default:
+		return fmt.Sprintf("%s (%s) expires on %s", s.Fingerprint, s.Identity, s.Expires.Format("2006-01-02"))
+	}
+}
+
+// Usable tells whether the key can still check signatures.
+func (s KeyStatus) Usable() bool {
+	return !s.Expired && !s.Revoked
+}
+
+// primaryIdentity returns the identity marked as primary, or else the first
+// one by name.
+func primaryIdentity(entity *openpgp.Entity) *openpgp.Identity {
+	names := []string{}
+	for name, identity := range entity.Identities {
+		if identity.SelfSignature != nil && identity.SelfSignature.IsPrimaryId != nil &&
+			*identity.SelfSignature.IsPrimaryId {
+			return identity
+		}
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	sort.Strings(names)
+	return entity.Identities[names[0]]
+}
+
+// keyExpiry returns when a key with the given self-signature expires, zero
+// if it never does. The lifetime counts from the creation of the key, not
+// from the one of the signature.
+func keyExpiry(key *packet.PublicKey, sig *packet.Signature) time.Time {
+	if sig == nil || sig.KeyLifetimeSecs == nil || *sig.KeyLifetimeSecs == 0 {
+		return time.Time{}
+	}
+	return key.CreationTime.Add(time.Duration(*sig.KeyLifetimeSecs) * time.Second)
+}
+
+// KeyExpiry returns when the entity stops being able to sign, zero if it
+// never does: that's when the last of its signing subkeys expires, or its
+// primary key if it has none, and never later than the primary key.
+func KeyExpiry(entity *openpgp.Entity) time.Time {
+	var sig *packet.Signature
+	if identity := primaryIdentity(entity); identity != nil {
+		sig = identity.SelfSignature
+	}
+	primary := keyExpiry(entity.PrimaryKey, sig)
+
+	var latest time.Time
+	signing := false
+	for _, subkey := range entity.Subkeys {
+		if subkey.Sig == nil || !subkey.Sig.FlagsValid || !subkey.Sig.FlagSign {
+			continue
+		}
+		expiry := keyExpiry(subkey.PublicKey, subkey.Sig)
+		if !signing || (!latest.IsZero() && (expiry.IsZero() || expiry.After(latest))) {
+			latest = expiry
+		}
+		signing = true
+	}
+	if !signing {
+		return primary
+	}
+	if primary.IsZero() || (!latest.IsZero() && latest.Before(primary)) {
+		return latest
+	}
+	return primary
+}
+
+// NewKeyStatus returns the status of the key at the time `now`; it expires
+// soon if it expires within `warning` of it.
+func NewKeyStatus(entity *openpgp.Entity, now time.Time, warning time.Duration) KeyStatus {
+	ret := KeyStatus{
+		Entity:      entity,
+		Fingerprint: fmt.Sprintf("%X", entity.PrimaryKey.Fingerprint[:]),
+		Expires:     KeyExpiry(entity),
+		Revoked:     len(entity.Revocations) > 0,
+	}
+	if identity := primaryIdentity(entity); identity != nil {
+		ret.Identity = identity.Name
+	}
+	if !ret.Expires.IsZero() {
+		ret.Expired = !now.Before(ret.Expires)
+		ret.ExpiresSoon = !ret.Expired && ret.Expires.Before(now.Add(warning))
+	}
+	return ret
+}
+
+// CheckKeyring returns the status of every key of the keyring at the time
+// `now`, the ones expiring first first.
+func CheckKeyring(keyring openpgp.EntityList, now time.Time, warning time.Duration) []KeyStatus {
+	ret := []KeyStatus{}
+	for _, entity := range keyring {
+		ret = append(ret, NewKeyStatus(entity, now, warning))
+	}
+	sort.SliceStable(ret, func(i, j int) bool {
+		a, b := ret[i].Expires, ret[j].Expires
+		if a.IsZero() || b.IsZero() {
+			return !a.IsZero() && b.IsZero()
+		}
+		return a.Before(b)
+	})
+	return ret
+}
+
+// }}}
+
+// Suite keys {{{
+
+// SuiteKeyStatus relates a suite to the key signing it.
+type SuiteKeyStatus struct {
+	Suite string
+
+	// Key signing the Release file of the suite.
+	Key KeyStatus
+
+	// Valid-Until of the Release file, zero if it has none.
+	ValidUntil time.Time
+
+	// Set when the key expires before the Release file does, so that apt
+	// refuses it while it's still meant to be valid.
+	ExpiresBeforeValidUntil bool
+
+	// Other keys of the keyring that are still usable past the warning
+	// period, which the suite can be signed with instead: when the Key
+	// expires soon and there are none, a new key must be rolled out
+	// before anything else.
+	Successors []KeyStatus
+}
+
+// Warnings returns what an operator should know about the suite, nothing
+// if all is well.
+func (s SuiteKeyStatus) Warnings() []string {
+	ret := []string{}
+	if !s.Key.Usable() || s.Key.ExpiresSoon {
+		ret = append(ret, fmt.Sprintf("%s: signing key %s", s.Suite, s.Key))
+		if len(s.Successors) == 0 {
+			ret = append(ret, fmt.Sprintf("%s: no other key of the keyring can take over", s.Suite))
+		}
+	}
+	if s.ExpiresBeforeValidUntil {
+		ret = append(ret, fmt.Sprintf("%s: signing key expires before the Release file (Valid-Until %s)",
+			s.Suite, s.ValidUntil.Format("2006-01-02")))
+	}
+	return ret
+}
+
+// CheckSuiteKey finds which key of the Keyring of the client signs the
+// suite, and how soon it expires relative to `now` and to the Release
+// file. Unlike Update, an expired key or Release file is not an error, as
+// telling about them is the point.
+func CheckSuiteKey(ctx context.Context, client *Client, now time.Time, warning time.Duration) (*SuiteKeyStatus, error) {
+	if client.Keyring == nil {
+		return nil, fmt.Errorf("Checking the key of %s needs a keyring", client.Entry.Suite)
+	}
+	if client.Fetcher == nil {
+		fetcher, err := NewFetcher(ctx, client.Entry.URI, nil)
+		if err != nil {
+			return nil, err
+		}
+		client.Fetcher = fetcher
+	}
+
+	var (
+		cleartext []byte
+		signer    *openpgp.Entity
+	)
+	data, err := fetchAll(ctx, client.Fetcher, client.suitePath("InRelease"))
+	if err == nil {
+		cleartext, signer, err = control.DecodeClearsigned(bytes.NewReader(data), client.Keyring)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		if cleartext, err = fetchAll(ctx, client.Fetcher, client.suitePath("Release")); err != nil {
+			return nil, err
+		}
+		signature, err := fetchAll(ctx, client.Fetcher, client.suitePath("Release.gpg"))
+		if err != nil {
+			return nil, err
+		}
+		signer, err = openpgp.CheckArmoredDetachedSignature(
+			*client.Keyring, bytes.NewReader(cleartext), bytes.NewReader(signature))
+		if err != nil {
+			return nil, err
+		}
+	}
+	release, err := ParseRelease(bytes.NewReader(cleartext))
+	if err != nil {
+		return nil, err
+	}
+
+	ret := SuiteKeyStatus{
+		Suite: client.Entry.Suite,
+		Key:   NewKeyStatus(signer, now, warning),
+	}
+	if ret.ValidUntil, err = release.ValidUntilTime(); err != nil {
+		return nil, err
+	}
+	if !ret.Key.Expires.IsZero() && !ret.ValidUntil.IsZero() {
+		ret.ExpiresBeforeValidUntil = ret.Key.Expires.Before(ret.ValidUntil)
+	}
+	for _, status := range CheckKeyring(*client.Keyring, now, warning) {
+		if status.Entity != signer && status.Usable() && !status.ExpiresSoon {
+			ret.Successors = append(ret.Successors, status)
+		}
+	}
+	return &ret, nil
+}
+
+// }}}
+
+// vim: foldmethod=marker
@@ -0,0 +1,111 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package archive_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/openpgp"
+
+	"github.com/ebikt/go-debian/archive"
+	"github.com/ebikt/go-debian/testutil"
+)
+
+/*
+ *
+ */
+
+// expiringKey generates a key expiring after the lifetime.
+func expiringKey(t *testing.T, name string, lifetime time.Duration) *openpgp.Entity {
+	key, err := testutil.NewKey(name, strings.ToLower(name)+"@example.com")
+	isok(t, err)
+	secs := uint32(lifetime / time.Second)
+	for _, identity := range key.Identities {
+		identity.SelfSignature.KeyLifetimeSecs = &secs
+		isok(t, identity.SelfSignature.SignUserId(identity.UserId.Id, key.PrimaryKey, key.PrivateKey, nil))
+	}
+	return key
+}
+
+func TestCheckKeyring(t *testing.T) {
+	now := time.Now()
+	soon := expiringKey(t, "Soon", 10*24*time.Hour)
+	later := expiringKey(t, "Later", 400*24*time.Hour)
+	never, err := testutil.NewKey("Never", "never@example.com")
+	isok(t, err)
+
+	statuses := archive.CheckKeyring(*testutil.Keyring(never, later, soon), now, 30*24*time.Hour)
+	assert(t, len(statuses) == 3)
+	assert(t, statuses[0].Entity == soon)
+	assert(t, statuses[0].ExpiresSoon && !statuses[0].Expired)
+	assert(t, statuses[0].Identity == "Soon (test key) <soon@example.com>")
+	assert(t, len(statuses[0].Fingerprint) == 40)
+	assert(t, statuses[1].Entity == later)
+	assert(t, !statuses[1].ExpiresSoon && statuses[1].Usable())
+	assert(t, statuses[2].Entity == never)
+	assert(t, statuses[2].Expires.IsZero())
+	assert(t, strings.HasSuffix(statuses[2].String(), "never expires"))
+
+	statuses = archive.CheckKeyring(*testutil.Keyring(soon), now.Add(11*24*time.Hour), 0)
+	assert(t, statuses[0].Expired && !statuses[0].Usable())
+	assert(t, strings.Contains(statuses[0].String(), "expired on"))
+}
+
+func TestCheckSuiteKey(t *testing.T) {
+	now := time.Now()
+	soon := expiringKey(t, "Soon", 10*24*time.Hour)
+	repo := testutil.NewRepository(soon)
+	defer repo.Close()
+	suite := testSuite()
+	suite.ValidUntil = now.Add(20 * 24 * time.Hour)
+	isok(t, repo.AddSuite(suite))
+
+	client := archive.NewClient(archive.SourceEntry{
+		URI:   repo.Start(),
+		Suite: "stable",
+	}, testutil.Keyring(soon))
+	status, err := archive.CheckSuiteKey(context.Background(), client, now, 30*24*time.Hour)
+	isok(t, err)
+	assert(t, status.Key.Entity == soon)
+	assert(t, status.Key.ExpiresSoon)
+	assert(t, status.ExpiresBeforeValidUntil)
+	assert(t, len(status.Successors) == 0)
+	assert(t, len(status.Warnings()) == 3)
+
+	/* With the next key already in the keyring, only the expiry is left */
+	next, err := testutil.NewKey("Next", "next@example.com")
+	isok(t, err)
+	client.Keyring = testutil.Keyring(soon, next)
+	status, err = archive.CheckSuiteKey(context.Background(), client, now, 30*24*time.Hour)
+	isok(t, err)
+	assert(t, len(status.Successors) == 1)
+	assert(t, status.Successors[0].Entity == next)
+	assert(t, len(status.Warnings()) == 2)
+
+	client.Keyring = testutil.Keyring(next)
+	_, err = archive.CheckSuiteKey(context.Background(), client, now, 0)
+	notok(t, err)
+}
+
+// vim: foldmethod=marker
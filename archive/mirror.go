@@ -0,0 +1,260 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package archive // import "github.com/ebikt/go-debian/archive"
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/openpgp"
+
+	"github.com/ebikt/go-debian/control"
+)
+
+// Mirror health checking {{{
+
+// MirrorStatus is the result of checking a single mirror.
+type MirrorStatus struct {
+	// Base URL of the mirror, such as http://deb.debian.org/debian
+	URL string
+
+	// Release file fetched from the mirror, nil if Err is set.
+	Release *Release
+	Date    time.Time
+
+	// Err is set if the Release file could not be fetched, verified or
+	// parsed.
+	Err error
+
+	// Stale is set if the mirror serves an older Release than the freshest
+	// mirror checked.
+	Stale bool
+
+	// Inconsistent is set if the mirror serves a Release with the same Date
+	// as the freshest mirror, but with different index checksums.
+	Inconsistent bool
+
+	fingerprint string
+}
+
+// Healthy reports whether the mirror can be used: it was reachable, and it
+// is neither stale nor inconsistent with the other mirrors.
+func (s MirrorStatus) Healthy() bool {
+	return s.Err == nil && !s.Stale && !s.Inconsistent
+}
+
+// MirrorChecker fetches the Release file of a suite from several mirrors of
+// the same archive, and compares them.
+type MirrorChecker struct {
+	// Suite (or codename) to check, such as "stable" or "bookworm".
+	Suite string
+
+	// HTTP client to use; http.DefaultClient if nil.
+	Client *http.Client
+
+	// If set, InRelease files must be signed by a key of this keyring. If
+	// nil, signatures are not checked, and plain Release files are
+	// accepted as well.
+	Keyring *openpgp.EntityList
+
+	// If set, mirrors serving a Release that doesn't match this pattern,
+	// such as "o=Debian,n=bookworm", are reported with an error.
+	Match *OriginPattern
+}
+
+// Check fetches the Release file from each of the mirrors concurrently,
+// and returns their status in the order they were given.
+func (c *MirrorChecker) Check(ctx context.Context, mirrors []string) []MirrorStatus {
+	ret := make([]MirrorStatus, len(mirrors))
+	wg := sync.WaitGroup{}
+	for i, mirror := range mirrors {
+		wg.Add(1)
+		go func(i int, mirror string) {
+			defer wg.Done()
+			ret[i] = c.checkOne(ctx, mirror)
+		}(i, mirror)
+	}
+	wg.Wait()
+	compareMirrors(ret)
+	return ret
+}
+
+func (c *MirrorChecker) checkOne(ctx context.Context, mirror string) MirrorStatus {
+	status := MirrorStatus{URL: mirror}
+	release, err := c.fetchRelease(ctx, mirror)
+	if err != nil {
+		status.Err = err
+		return status
+	}
+	date, err := release.DateTime()
+	if err != nil {
+		status.Err = err
+		return status
+	}
+	if c.Match != nil && !c.Match.MatchRelease(release) {
+		status.Err = fmt.Errorf("Release of %s doesn't match %s", mirror, c.Match)
+		return status
+	}
+	status.Release = release
+	status.Date = date
+	status.fingerprint = releaseFingerprint(release)
+	return status
+}
+
+func (c *MirrorChecker) suiteURL(mirror string) string {
+	return strings.TrimSuffix(mirror, "/") + "/dists/" + c.Suite + "/"
+}
+
+func (c *MirrorChecker) fetchRelease(ctx context.Context, mirror string) (*Release, error) {
+	data, err := c.fetch(ctx, c.suiteURL(mirror)+"InRelease")
+	if err == nil {
+		decoder, err := control.NewDecoder(bytes.NewReader(data), c.Keyring)
+		if err != nil {
+			return nil, err
+		}
+		release := Release{}
+		if err := decoder.Decode(&release); err != nil {
+			return nil, err
+		}
+		return &release, nil
+	}
+	if c.Keyring != nil {
+		/* Checking a detached Release.gpg isn't supported (yet) */
+		return nil, err
+	}
+	data, err = c.fetch(ctx, c.suiteURL(mirror)+"Release")
+	if err != nil {
+		return nil, err
+	}
+	return ParseRelease(bytes.NewReader(data))
+}
+
+func (c *MirrorChecker) fetch(ctx context.Context, url string) ([]byte, error) {
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(ioutil.Discard, resp.Body)
+		return nil, fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// releaseFingerprint condenses the list of index checksums of a Release
+// file, so they can be compared between mirrors.
+func releaseFingerprint(release *Release) string {
+	lines := []string{}
+	for _, hash := range release.Checksums() {
+		lines = append(lines, fmt.Sprintf("%s %s %d %s", hash.Algorithm, hash.Hash, hash.Size, hash.Filename))
+	}
+	sort.Strings(lines)
+	sum := sha256.Sum256([]byte(strings.Join(lines, "\n")))
+	return fmt.Sprintf("%x", sum)
+}
+
+// compareMirrors flags the stale and inconsistent mirrors. Among the
+// mirrors serving the newest Date, the checksums served by most of them
+// are taken to be the right ones.
+func compareMirrors(statuses []MirrorStatus) {
+	newest := time.Time{}
+	for _, status := range statuses {
+		if status.Err == nil && status.Date.After(newest) {
+			newest = status.Date
+		}
+	}
+
+	votes := map[string]int{}
+	for _, status := range statuses {
+		if status.Err == nil && status.Date.Equal(newest) {
+			votes[status.fingerprint]++
+		}
+	}
+	winner, best := "", 0
+	for i := range statuses {
+		/* Walk in order, so ties go to the first mirror given */
+		fingerprint := statuses[i].fingerprint
+		if statuses[i].Err == nil && votes[fingerprint] > best {
+			winner, best = fingerprint, votes[fingerprint]
+		}
+	}
+
+	for i := range statuses {
+		status := &statuses[i]
+		if status.Err != nil {
+			continue
+		}
+		if status.Date.Before(newest) {
+			status.Stale = true
+		} else if status.fingerprint != winner {
+			status.Inconsistent = true
+		}
+	}
+}
+
+// Freshest returns the first healthy mirror out of the given statuses, which
+// is the one subsequent fetches should go to.
+func Freshest(statuses []MirrorStatus) (*MirrorStatus, error) {
+	for i := range statuses {
+		if statuses[i].Healthy() {
+			return &statuses[i], nil
+		}
+	}
+	errs := []string{}
+	for _, status := range statuses {
+		if status.Err != nil {
+			errs = append(errs, status.Err.Error())
+		}
+	}
+	return nil, fmt.Errorf("No healthy mirror: %s", strings.Join(errs, "; "))
+}
+
+// SelectMirror checks the given mirrors, and returns the base URL of the
+// freshest healthy one.
+func (c *MirrorChecker) SelectMirror(ctx context.Context, mirrors []string) (string, error) {
+	status, err := Freshest(c.Check(ctx, mirrors))
+	if err != nil {
+		return "", err
+	}
+	return status.URL, nil
+}
+
+// }}}
+
+// vim: foldmethod=marker
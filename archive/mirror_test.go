@@ -0,0 +1,88 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package archive_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ebikt/go-debian/archive"
+)
+
+/*
+ *
+ */
+
+func mirrorServer(release string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if release == "" || r.URL.Path != "/debian/dists/stable/Release" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(release))
+	}))
+}
+
+func TestMirrorCheck(t *testing.T) {
+	older := strings.Replace(bookwormRelease, "Sat, 10 Feb 2024", "Sat, 03 Feb 2024", 1)
+	broken := strings.Replace(bookwormRelease, " 98581 contrib", " 98582 contrib", -1)
+
+	servers := []*httptest.Server{
+		mirrorServer(older),
+		mirrorServer(bookwormRelease),
+		mirrorServer(""),
+		mirrorServer(broken),
+		mirrorServer(bookwormRelease),
+	}
+	mirrors := []string{}
+	for _, server := range servers {
+		defer server.Close()
+		mirrors = append(mirrors, server.URL+"/debian/")
+	}
+
+	checker := archive.MirrorChecker{Suite: "stable"}
+	statuses := checker.Check(context.Background(), mirrors)
+	assert(t, len(statuses) == 5)
+
+	assert(t, statuses[0].Err == nil && statuses[0].Stale)
+	assert(t, statuses[1].Healthy())
+	assert(t, statuses[2].Err != nil)
+	assert(t, statuses[3].Err == nil && statuses[3].Inconsistent)
+	assert(t, statuses[4].Healthy())
+
+	freshest, err := archive.Freshest(statuses)
+	isok(t, err)
+	assert(t, freshest.URL == mirrors[1])
+	assert(t, freshest.Release.Codename == "bookworm")
+
+	mirror, err := checker.SelectMirror(context.Background(), mirrors[2:3])
+	notok(t, err)
+	assert(t, mirror == "")
+
+	checker.Match = &archive.OriginPattern{Origin: "Debian", Codename: "trixie"}
+	statuses = checker.Check(context.Background(), mirrors[1:2])
+	assert(t, statuses[0].Err != nil)
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,220 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package archive // import "github.com/ebikt/go-debian/archive"
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ebikt/go-debian/internal"
+)
+
+// Origin {{{
+
+// Origin describes where a version of a package comes from, as apt sees it
+// in the Release file of the archive, and the component and host the
+// package was found in.
+type Origin struct {
+	Origin    string
+	Label     string
+	Archive   string
+	Codename  string
+	Version   string
+	Component string
+	Site      string
+
+	// Set from the NotAutomatic and ButAutomaticUpgrades fields of the
+	// Release, which lower the default pin priority of its versions.
+	NotAutomatic         bool
+	ButAutomaticUpgrades bool
+}
+
+// NotAutomatic tells whether the Release says "NotAutomatic: yes", as
+// experimental or backports do, so that apt only installs its versions
+// when asked to.
+func (r *Release) NotAutomatic() bool {
+	return r.Paragraph.Get("NotAutomatic") == "yes"
+}
+
+// ButAutomaticUpgrades tells whether the Release says
+// "ButAutomaticUpgrades: yes", so that apt still upgrades the versions
+// installed from it.
+func (r *Release) ButAutomaticUpgrades() bool {
+	return r.Paragraph.Get("ButAutomaticUpgrades") == "yes"
+}
+
+// Origins returns the Origin of each component of the Release, as served
+// by the given site (host name of the mirror, may be empty).
+func (r *Release) Origins(site string) []Origin {
+	ret := []Origin{}
+	for _, component := range r.Components {
+		ret = append(ret, Origin{
+			Origin:    r.Origin,
+			Label:     r.Label,
+			Archive:   r.Suite,
+			Codename:  r.Codename,
+			Version:   r.Version,
+			Component: component,
+			Site:      site,
+
+			NotAutomatic:         r.NotAutomatic(),
+			ButAutomaticUpgrades: r.ButAutomaticUpgrades(),
+		})
+	}
+	return ret
+}
+
+// }}}
+
+// OriginPattern {{{
+
+// OriginPattern selects Origins by a set of fields, the way apt-style
+// release selectors are written, as in "o=Debian,n=bookworm,c=main". This
+// is the syntax of both the Unattended-Upgrade::Origins-Pattern option and
+// of the "Pin: release" lines of apt_preferences(5). Empty fields match
+// anything, and the other ones may contain the shell wildcards '*' and
+// '?'. All fields have to match.
+type OriginPattern struct {
+	Origin    string
+	Label     string
+	Archive   string
+	Codename  string
+	Version   string
+	Component string
+	Site      string
+}
+
+// ParseOriginPattern parses a comma separated list of key=value pairs into
+// an OriginPattern. Keys are either the short ones (o, l, a, n, v, c) or
+// the long ones (origin, label, archive, suite, codename, version,
+// component, site). Commas inside of values may be escaped with a
+// backslash.
+func ParseOriginPattern(in string) (*OriginPattern, error) {
+	ret := OriginPattern{}
+	for _, term := range splitEscaped(in, ',') {
+		if strings.TrimSpace(term) == "" {
+			continue
+		}
+		parts := strings.SplitN(term, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("Malformed origin pattern term: '%s'", term)
+		}
+		key, value := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+		switch strings.ToLower(key) {
+		case "o", "origin":
+			ret.Origin = value
+		case "l", "label":
+			ret.Label = value
+		case "a", "archive", "suite":
+			ret.Archive = value
+		case "n", "codename":
+			ret.Codename = value
+		case "v", "version":
+			ret.Version = value
+		case "c", "component":
+			ret.Component = value
+		case "site":
+			ret.Site = value
+		default:
+			return nil, fmt.Errorf("Unknown origin pattern key: '%s'", key)
+		}
+	}
+	return &ret, nil
+}
+
+func splitEscaped(in string, sep byte) []string {
+	ret := []string{}
+	current := []byte{}
+	for i := 0; i < len(in); i++ {
+		switch {
+		case in[i] == '\\' && i+1 < len(in) && in[i+1] == sep:
+			current = append(current, sep)
+			i++
+		case in[i] == sep:
+			ret = append(ret, string(current))
+			current = []byte{}
+		default:
+			current = append(current, in[i])
+		}
+	}
+	return append(ret, string(current))
+}
+
+// Match checks if the Origin is selected by this OriginPattern.
+func (p OriginPattern) Match(origin Origin) bool {
+	return internal.GlobMatch(p.Origin, origin.Origin) &&
+		internal.GlobMatch(p.Label, origin.Label) &&
+		internal.GlobMatch(p.Archive, origin.Archive) &&
+		internal.GlobMatch(p.Codename, origin.Codename) &&
+		internal.GlobMatch(p.Version, origin.Version) &&
+		internal.GlobMatch(p.Component, origin.Component) &&
+		internal.GlobMatch(p.Site, origin.Site)
+}
+
+// MatchAny checks if any of the Origins is selected by this OriginPattern.
+func (p OriginPattern) MatchAny(origins []Origin) bool {
+	for _, origin := range origins {
+		if p.Match(origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchRelease checks if any component of the Release is selected by this
+// OriginPattern, ignoring the Site. A pattern without a Component matches
+// a Release without any too.
+func (p OriginPattern) MatchRelease(release *Release) bool {
+	p.Site = ""
+	if p.Component == "" && len(release.Components) == 0 {
+		return p.Match(Origin{
+			Origin:   release.Origin,
+			Label:    release.Label,
+			Archive:  release.Suite,
+			Codename: release.Codename,
+			Version:  release.Version,
+		})
+	}
+	return p.MatchAny(release.Origins(""))
+}
+
+func (p OriginPattern) String() string {
+	terms := []string{}
+	for _, term := range []struct{ key, value string }{
+		{"o", p.Origin},
+		{"l", p.Label},
+		{"a", p.Archive},
+		{"n", p.Codename},
+		{"v", p.Version},
+		{"c", p.Component},
+		{"site", p.Site},
+	} {
+		if term.value != "" {
+			value := strings.Replace(term.value, ",", "\\,", -1)
+			terms = append(terms, term.key+"="+value)
+		}
+	}
+	return strings.Join(terms, ",")
+}
+
+// }}}
+
+// vim: foldmethod=marker
@@ -0,0 +1,83 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package archive_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ebikt/go-debian/archive"
+)
+
+/*
+ *
+ */
+
+func TestOriginPatternMatchRelease(t *testing.T) {
+	release, err := archive.ParseRelease(strings.NewReader(bookwormRelease))
+	isok(t, err)
+
+	origins := release.Origins("deb.debian.org")
+	assert(t, len(origins) == 4)
+	assert(t, origins[1].Component == "contrib")
+	assert(t, origins[1].Archive == "stable")
+	assert(t, origins[1].Version == "12.5")
+	assert(t, !origins[1].NotAutomatic)
+
+	release.Paragraph.Set("NotAutomatic", "yes")
+	release.Paragraph.Set("ButAutomaticUpgrades", "yes")
+	origins = release.Origins("deb.debian.org")
+	assert(t, origins[0].NotAutomatic && origins[0].ButAutomaticUpgrades)
+
+	for selector, match := range map[string]bool{
+		"o=Debian,n=bookworm,l=Debian,c=main":   true,
+		"o=Debian,a=stable,v=12.*":              true,
+		"origin=Debian,suite=stable":            true,
+		"c=non-free*":                           true,
+		"o=Debian,n=bookworm,l=Debian-Security": false,
+		"n=trixie":                              false,
+		"c=universe":                            false,
+		"v=11*":                                 false,
+	} {
+		pattern, err := archive.ParseOriginPattern(selector)
+		isok(t, err)
+		assert(t, pattern.MatchRelease(release) == match)
+		assert(t, pattern.String() != "")
+	}
+
+	pattern, err := archive.ParseOriginPattern(`o=Foo\,Inc,c=updates/*`)
+	isok(t, err)
+	assert(t, pattern.Origin == "Foo,Inc")
+	assert(t, pattern.Match(archive.Origin{Origin: "Foo,Inc", Component: "updates/main"}))
+	assert(t, pattern.String() == `o=Foo\,Inc,c=updates/*`)
+
+	_, err = archive.ParseOriginPattern("x=Debian")
+	notok(t, err)
+	_, err = archive.ParseOriginPattern("Debian")
+	notok(t, err)
+
+	componentless := *release
+	componentless.Components = nil
+	assert(t, archive.OriginPattern{Codename: "bookworm"}.MatchRelease(&componentless))
+	assert(t, !archive.OriginPattern{Component: "main"}.MatchRelease(&componentless))
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,317 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package archive // import "github.com/ebikt/go-debian/archive"
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/ebikt/go-debian/control"
+)
+
+// Overrides {{{
+
+// An Override is an entry of an archive override file, which sets the
+// Section and Priority of a package in the archive, whatever the package
+// itself says:
+//
+//   hello optional devel
+//   hello-udeb optional debian-installer
+//
+// Source override files have no Priority column.
+type Override struct {
+	Package  string
+	Priority string
+	Section  string
+
+	// Maintainer override, as optional last columns, such as
+	// "Jane Doe <jane@example.com>" or "old@example.com => new@example.com".
+	Maintainer string
+}
+
+// scanOverrides calls the function with the fields of each line of an
+// override file. Comments, starting with a '#', and empty lines are
+// skipped.
+func scanOverrides(in io.Reader, fn func(fields []string, line string) error) error {
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i != -1 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if err := fn(fields, scanner.Text()); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// ParseOverrides parses a binary package override file. Comments, starting
+// with a '#', and empty lines are ignored.
+func ParseOverrides(in io.Reader) ([]Override, error) {
+	ret := []Override{}
+	err := scanOverrides(in, func(fields []string, line string) error {
+		if len(fields) < 3 {
+			return fmt.Errorf("Malformed override line: '%s'", line)
+		}
+		ret = append(ret, Override{
+			Package:    fields[0],
+			Priority:   fields[1],
+			Section:    fields[2],
+			Maintainer: strings.Join(fields[3:], " "),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// ParseSourceOverrides parses a source package override file, whose lines
+// have a package and a section, then an optional maintainer override.
+func ParseSourceOverrides(in io.Reader) ([]Override, error) {
+	ret := []Override{}
+	err := scanOverrides(in, func(fields []string, line string) error {
+		if len(fields) < 2 {
+			return fmt.Errorf("Malformed source override line: '%s'", line)
+		}
+		ret = append(ret, Override{
+			Package:    fields[0],
+			Section:    fields[1],
+			Maintainer: strings.Join(fields[2:], " "),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// An ExtraOverride is an entry of an extra override file, which sets any
+// field of a package in the indexes, such as its Task:
+//
+//   hello Task standard
+//   hello-doc Tag role::documentation
+type ExtraOverride struct {
+	Package string
+	Field   string
+	Value   string
+}
+
+// ParseExtraOverrides parses an extra override file. The value is the rest
+// of the line after the field name, spaces included.
+func ParseExtraOverrides(in io.Reader) ([]ExtraOverride, error) {
+	ret := []ExtraOverride{}
+	err := scanOverrides(in, func(fields []string, line string) error {
+		if len(fields) < 3 {
+			return fmt.Errorf("Malformed extra override line: '%s'", line)
+		}
+		if i := strings.Index(line, "#"); i != -1 {
+			line = line[:i]
+		}
+		value := strings.TrimSpace(line)
+		for _, field := range fields[:2] {
+			value = strings.TrimSpace(strings.TrimPrefix(value, field))
+		}
+		ret = append(ret, ExtraOverride{
+			Package: fields[0],
+			Field:   fields[1],
+			Value:   value,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// }}}
+
+// Override checks {{{
+
+// An OverrideMismatch is a Section or Priority of a binary package that
+// disagrees with the override file, or with the Package-List of its source
+// package.
+type OverrideMismatch struct {
+	Package string
+
+	// "Section" or "Priority"
+	Field string
+
+	// Value of the Packages index.
+	Value string
+
+	// Value it disagrees with, and where it comes from: "override" or
+	// "Package-List".
+	Expected string
+	From     string
+}
+
+func (m OverrideMismatch) String() string {
+	if m.Expected == "" {
+		return fmt.Sprintf("%s: missing from the %s", m.Package, m.From)
+	}
+	return fmt.Sprintf("%s: %s says %s %s, Packages says %s",
+		m.Package, m.From, strings.ToLower(m.Field), m.Expected, m.Value)
+}
+
+// sectionName strips the component off a Section, such as "contrib/devel".
+func sectionName(section string) string {
+	return section[strings.LastIndex(section, "/")+1:]
+}
+
+// CheckOverrides compares the Section and Priority of the binary packages
+// of a Packages index with the overrides of their component, and with the
+// Package-List of their source packages if `sources` is set (binary
+// packages whose source is missing from it are only checked against the
+// overrides). Packages missing from the overrides are reported as a
+// mismatch of their Section with an empty Expected value.
+//
+// Sections are compared without their component, since overrides are per
+// component.
+func CheckOverrides(binaries []control.BinaryIndex, overrides []Override, sources *control.SourceMap) []OverrideMismatch {
+	byPackage := map[string]Override{}
+	for _, override := range overrides {
+		byPackage[override.Package] = override
+	}
+
+	ret := []OverrideMismatch{}
+	check := func(binary control.BinaryIndex, from, section, priority string) {
+		if sectionName(binary.Section) != sectionName(section) {
+			ret = append(ret, OverrideMismatch{binary.Package, "Section", binary.Section, section, from})
+		}
+		if binary.Priority != priority && priority != "" {
+			ret = append(ret, OverrideMismatch{binary.Package, "Priority", binary.Priority, priority, from})
+		}
+	}
+
+	for _, binary := range binaries {
+		if override, ok := byPackage[binary.Package]; ok {
+			check(binary, "override", override.Section, override.Priority)
+		} else {
+			ret = append(ret, OverrideMismatch{binary.Package, "Section", binary.Section, "", "override"})
+		}
+
+		if sources == nil {
+			continue
+		}
+		source, err := sources.SourceOf(&binary)
+		if err != nil {
+			continue
+		}
+		for _, entry := range source.PackageList {
+			if entry.Name == binary.Package {
+				check(binary, "Package-List", entry.Section, entry.Priority)
+			}
+		}
+	}
+
+	sort.SliceStable(ret, func(i, j int) bool {
+		return ret[i].Package < ret[j].Package
+	})
+	return ret
+}
+
+// }}}
+
+// Applying overrides {{{
+
+// ComponentOverrides are the override files of a component of a suite,
+// as dak keeps them: override.sid.main, override.sid.main.src and
+// override.sid.extra.main, and they rewrite the Packages and Sources
+// entries of the packages of the component when it's published.
+type ComponentOverrides struct {
+	Binary      []Override
+	Source      []Override
+	Extra       []ExtraOverride
+	SourceExtra []ExtraOverride
+}
+
+// overrideMaintainer returns the Maintainer set by an override: either a
+// plain new maintainer, or "old => new", which replaces only the old one
+// (several of them may be separated by " // ").
+func overrideMaintainer(current, override string) string {
+	if override == "" {
+		return current
+	}
+	i := strings.Index(override, "=>")
+	if i == -1 {
+		return override
+	}
+	for _, old := range strings.Split(override[:i], "//") {
+		if strings.TrimSpace(old) == current {
+			return strings.TrimSpace(override[i+2:])
+		}
+	}
+	return current
+}
+
+func applyOverrides(para *control.Paragraph, name string, overrides []Override, extras []ExtraOverride) bool {
+	found := false
+	for _, override := range overrides {
+		if override.Package != name {
+			continue
+		}
+		found = true
+		if override.Section != "" {
+			para.Set("Section", override.Section)
+		}
+		if override.Priority != "" {
+			para.Set("Priority", override.Priority)
+		}
+		if maintainer := overrideMaintainer(para.Get("Maintainer"), override.Maintainer); maintainer != para.Get("Maintainer") {
+			para.Set("Maintainer", maintainer)
+		}
+	}
+	for _, extra := range extras {
+		if extra.Package == name {
+			para.Set(extra.Field, extra.Value)
+		}
+	}
+	return found
+}
+
+// ApplyBinary rewrites the Packages entry of a binary package with its
+// overrides and extra overrides, and tells whether the override file has
+// the package at all.
+func (o *ComponentOverrides) ApplyBinary(para *control.Paragraph) bool {
+	return applyOverrides(para, para.Get("Package"), o.Binary, o.Extra)
+}
+
+// ApplySource rewrites the Sources entry of a source package, see
+// ApplyBinary.
+func (o *ComponentOverrides) ApplySource(para *control.Paragraph) bool {
+	return applyOverrides(para, para.Get("Package"), o.Source, o.SourceExtra)
+}
+
+// }}}
+
+// vim: foldmethod=marker
@@ -0,0 +1,158 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package archive_test
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+
+	"github.com/ebikt/go-debian/archive"
+	"github.com/ebikt/go-debian/control"
+)
+
+/*
+ *
+ */
+
+func TestCheckOverrides(t *testing.T) {
+	overrides, err := archive.ParseOverrides(strings.NewReader(`# main overrides
+hello optional devel
+hello-doc optional doc
+libfoo1 optional libs  Foo Maintainers <foo@example.com>
+`))
+	isok(t, err)
+	assert(t, len(overrides) == 3)
+	assert(t, overrides[2].Maintainer == "Foo Maintainers <foo@example.com>")
+
+	_, err = archive.ParseOverrides(strings.NewReader("hello optional\n"))
+	notok(t, err)
+
+	binaries, err := control.ParseBinaryIndex(bufio.NewReader(strings.NewReader(`Package: hello
+Version: 2.10-3
+Architecture: amd64
+Section: misc
+Priority: optional
+
+Package: hello-doc
+Source: hello
+Version: 2.10-3
+Architecture: all
+Section: contrib/doc
+Priority: extra
+
+Package: unknown
+Version: 1.0
+Architecture: all
+Section: misc
+Priority: optional
+`)))
+	isok(t, err)
+	sources, err := control.ParseSourceIndex(bufio.NewReader(strings.NewReader(`Package: hello
+Binary: hello, hello-doc
+Version: 2.10-3
+Package-List:
+ hello deb devel optional arch=any
+ hello-doc deb doc optional arch=all
+`)))
+	isok(t, err)
+
+	mismatches := archive.CheckOverrides(binaries, overrides, control.NewSourceMap(sources))
+	assert(t, len(mismatches) == 5)
+	assert(t, mismatches[0].String() == "hello: override says section devel, Packages says misc")
+	assert(t, mismatches[1].From == "Package-List")
+	assert(t, mismatches[2].String() == "hello-doc: override says priority optional, Packages says extra")
+	assert(t, mismatches[3].From == "Package-List")
+	assert(t, mismatches[3].Field == "Priority")
+	assert(t, mismatches[4].String() == "unknown: missing from the override")
+
+	assert(t, len(archive.CheckOverrides(binaries[:1], overrides, nil)) == 1)
+}
+
+func TestParseOverrideFiles(t *testing.T) {
+	sources, err := archive.ParseSourceOverrides(strings.NewReader("hello devel\nfoo libs old@example.com => new@example.com\n"))
+	isok(t, err)
+	assert(t, len(sources) == 2)
+	assert(t, sources[0].Section == "devel")
+	assert(t, sources[0].Priority == "")
+	assert(t, sources[1].Maintainer == "old@example.com => new@example.com")
+	_, err = archive.ParseSourceOverrides(strings.NewReader("hello\n"))
+	notok(t, err)
+
+	extras, err := archive.ParseExtraOverrides(strings.NewReader("# tasks\nhello Task standard, desktop  # comment\nhello-doc Tag role::documentation\n"))
+	isok(t, err)
+	assert(t, len(extras) == 2)
+	assert(t, extras[0].Field == "Task")
+	assert(t, extras[0].Value == "standard, desktop")
+	assert(t, extras[1].Value == "role::documentation")
+	_, err = archive.ParseExtraOverrides(strings.NewReader("hello Task\n"))
+	notok(t, err)
+}
+
+func TestApplyOverrides(t *testing.T) {
+	overrides := archive.ComponentOverrides{
+		Binary: []archive.Override{
+			{Package: "hello", Priority: "optional", Section: "devel"},
+			{Package: "hello-doc", Priority: "optional", Section: "doc",
+				Maintainer: "Old <old@example.com> // Older <older@example.com> => New <new@example.com>"},
+			{Package: "foo", Priority: "optional", Section: "libs", Maintainer: "Foo <foo@example.com>"},
+		},
+		Source: []archive.Override{{Package: "hello", Section: "devel"}},
+		Extra:  []archive.ExtraOverride{{Package: "hello", Field: "Task", Value: "standard"}},
+	}
+
+	para := control.NewParagraph()
+	para.Set("Package", "hello")
+	para.Set("Section", "misc")
+	para.Set("Priority", "extra")
+	para.Set("Maintainer", "Old <old@example.com>")
+	assert(t, overrides.ApplyBinary(&para))
+	assert(t, para.Get("Section") == "devel")
+	assert(t, para.Get("Priority") == "optional")
+	assert(t, para.Get("Maintainer") == "Old <old@example.com>")
+	assert(t, para.Get("Task") == "standard")
+
+	para.Set("Package", "hello-doc")
+	assert(t, overrides.ApplyBinary(&para))
+	assert(t, para.Get("Maintainer") == "New <new@example.com>")
+	para.Set("Maintainer", "Other <other@example.com>")
+	assert(t, overrides.ApplyBinary(&para))
+	assert(t, para.Get("Maintainer") == "Other <other@example.com>")
+
+	para.Set("Package", "foo")
+	assert(t, overrides.ApplyBinary(&para))
+	assert(t, para.Get("Maintainer") == "Foo <foo@example.com>")
+
+	para.Set("Package", "unknown")
+	para.Set("Section", "misc")
+	assert(t, !overrides.ApplyBinary(&para))
+	assert(t, para.Get("Section") == "misc")
+
+	source := control.NewParagraph()
+	source.Set("Package", "hello")
+	source.Set("Priority", "optional")
+	assert(t, overrides.ApplySource(&source))
+	assert(t, source.Get("Section") == "devel")
+	assert(t, source.Get("Priority") == "optional")
+	assert(t, !source.Has("Task"))
+}
+
+// vim: foldmethod=marker
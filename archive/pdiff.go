@@ -0,0 +1,337 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package archive // import "github.com/ebikt/go-debian/archive"
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"github.com/ebikt/go-debian/control"
+	"github.com/ebikt/go-debian/hashio"
+)
+
+// PDiffs {{{
+
+// PDiffIndex is the diff/Index file next to an index, such as
+// "main/binary-amd64/Packages.diff/Index", which lists the patches that
+// bring older versions of the index up to date. Only the SHA256 fields are
+// used.
+type PDiffIndex struct {
+	control.Paragraph
+
+	// "hash size" of the current version of the index.
+	SHA256Current string `control:"SHA256-Current"`
+
+	// The versions of the index each patch applies to, the patches
+	// themselves and their compressed files, by patch name.
+	SHA256History  []control.SHA256FileHash `control:"SHA256-History" delim:"\n" strip:"\n\r\t "`
+	SHA256Patches  []control.SHA256FileHash `control:"SHA256-Patches" delim:"\n" strip:"\n\r\t "`
+	SHA256Download []control.SHA256FileHash `control:"SHA256-Download" delim:"\n" strip:"\n\r\t "`
+
+	// "merged" when each patch brings its version of the index straight
+	// to the current one, as dak publishes them.
+	PatchPrecedence string `control:"X-Patch-Precedence"`
+}
+
+// ParsePDiffIndex parses a diff/Index file.
+func ParsePDiffIndex(reader io.Reader) (*PDiffIndex, error) {
+	ret := PDiffIndex{}
+	if err := control.Unmarshal(&ret, reader); err != nil {
+		return nil, err
+	}
+	if _, err := ret.Current(); err != nil {
+		return nil, err
+	}
+	return &ret, nil
+}
+
+// Current returns the checksum of the current version of the index.
+func (i PDiffIndex) Current() (control.FileHash, error) {
+	fields := strings.Fields(i.SHA256Current)
+	if len(fields) != 2 {
+		return control.FileHash{}, fmt.Errorf("Malformed SHA256-Current: '%s'", i.SHA256Current)
+	}
+	size, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return control.FileHash{}, fmt.Errorf("Malformed SHA256-Current: '%s'", i.SHA256Current)
+	}
+	return control.FileHash{Algorithm: "sha256", Hash: fields[0], Size: size}, nil
+}
+
+// Merged tells whether each patch brings its version of the index straight
+// to the current one, so that a single patch has to be applied.
+func (i PDiffIndex) Merged() bool {
+	return i.PatchPrecedence == "merged"
+}
+
+func findPatch(hashes []control.SHA256FileHash, name string) *control.FileHash {
+	for _, hash := range hashes {
+		if hash.Filename == name {
+			ret := hash.FileHash
+			return &ret
+		}
+	}
+	return nil
+}
+
+// PDiffStep is a patch to apply to the index, along with the checksums of
+// its compressed and uncompressed files, and of the index it leads to.
+type PDiffStep struct {
+	Name     string
+	Download control.FileHash
+	Patch    control.FileHash
+	Result   control.FileHash
+}
+
+// Plan returns the patches to apply, in order, to the version of the index
+// with the given SHA256 checksum; none if it's the current version. An
+// error is returned if that version is not in the History, in which case
+// the index has to be downloaded in full.
+func (i PDiffIndex) Plan(hash string) ([]PDiffStep, error) {
+	current, err := i.Current()
+	if err != nil {
+		return nil, err
+	}
+	if hash == current.Hash {
+		return []PDiffStep{}, nil
+	}
+	start := -1
+	for n, entry := range i.SHA256History {
+		if entry.Hash == hash {
+			start = n
+			break
+		}
+	}
+	if start == -1 {
+		return nil, fmt.Errorf("The index is too old, or unknown, to be patched")
+	}
+	history := i.SHA256History[start:]
+	if i.Merged() {
+		history = history[:1]
+	}
+
+	ret := []PDiffStep{}
+	for n, entry := range history {
+		step := PDiffStep{Name: entry.Filename, Result: current}
+		if n+1 < len(history) {
+			step.Result = history[n+1].FileHash
+			step.Result.Filename = ""
+		}
+		patch := findPatch(i.SHA256Patches, entry.Filename)
+		download := findPatch(i.SHA256Download, entry.Filename+".gz")
+		if patch == nil || download == nil {
+			return nil, fmt.Errorf("Patch %s is not listed in the diff/Index", entry.Filename)
+		}
+		step.Patch, step.Download = *patch, *download
+		ret = append(ret, step)
+	}
+	return ret, nil
+}
+
+// }}}
+
+// ed scripts {{{
+
+// parseEdAddress parses the "N" or "N,M" address of an ed command.
+func parseEdAddress(in string) (int, int, error) {
+	parts := strings.SplitN(in, ",", 2)
+	first, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0, fmt.Errorf("Bad ed address: '%s'", in)
+	}
+	last := first
+	if len(parts) == 2 {
+		if last, err = strconv.Atoi(parts[1]); err != nil {
+			return 0, 0, fmt.Errorf("Bad ed address: '%s'", in)
+		}
+	}
+	if first < 0 || last < first {
+		return 0, 0, fmt.Errorf("Bad ed address: '%s'", in)
+	}
+	return first, last, nil
+}
+
+// ApplyEdScript applies an ed script, as written by "diff --ed", to the
+// data, which is what pdiffs are. Only the "a", "c" and "d" commands are
+// understood, which is all diff writes.
+func ApplyEdScript(data []byte, script io.Reader) ([]byte, error) {
+	lines := strings.SplitAfter(string(data), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	scanner := bufio.NewScanner(script)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		command := scanner.Text()
+		if command == "" {
+			continue
+		}
+		op := command[len(command)-1]
+		first, last, err := parseEdAddress(command[:len(command)-1])
+		if err != nil {
+			return nil, err
+		}
+		if last > len(lines) || (op != 'a' && first == 0) {
+			return nil, fmt.Errorf("ed command '%s' is out of the %d lines", command, len(lines))
+		}
+
+		text := []string{}
+		if op == 'a' || op == 'c' {
+			terminated := false
+			for scanner.Scan() {
+				if scanner.Text() == "." {
+					terminated = true
+					break
+				}
+				text = append(text, scanner.Text()+"\n")
+			}
+			if !terminated {
+				return nil, fmt.Errorf("Unterminated text of ed command '%s'", command)
+			}
+		}
+
+		switch op {
+		case 'a':
+			/* "0a" inserts before the first line */
+			first = last + 1
+		case 'c', 'd':
+		default:
+			return nil, fmt.Errorf("Unknown ed command: '%s'", command)
+		}
+		rest := append(text, lines[last:]...)
+		lines = append(lines[:first-1], rest...)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return []byte(strings.Join(lines, "")), nil
+}
+
+// }}}
+
+// Updating indexes {{{
+
+// IndexUpdate tells how UpdateIndex brought an index up to date.
+type IndexUpdate struct {
+	// The new version of the index, decompressed.
+	Data []byte
+
+	// Patches applied, in order; none when the index was up to date, or
+	// was downloaded in full.
+	Patches []string
+
+	// Why the index was downloaded in full, nil if it wasn't.
+	Fallback error
+}
+
+// UpdateIndex brings the index `name` of the suite, such as
+// "main/binary-amd64/Packages", up to date given its current version, the
+// way apt does: the patches of its diff/Index are downloaded and applied,
+// checking each intermediate version, and the whole index is fetched with
+// FetchIndex when there are no pdiffs, or anything goes wrong with them.
+// Update must have been called first.
+func (c *Client) UpdateIndex(ctx context.Context, name string, current []byte) (*IndexUpdate, error) {
+	data, patches, err := c.patchIndex(ctx, name, current)
+	if err == nil {
+		return &IndexUpdate{Data: data, Patches: patches}, nil
+	}
+	index, fetchErr := c.FetchIndex(ctx, name)
+	if fetchErr != nil {
+		return nil, fetchErr
+	}
+	data, fetchErr = ioutil.ReadAll(index)
+	if fetchErr != nil {
+		return nil, fetchErr
+	}
+	return &IndexUpdate{Data: data, Patches: []string{}, Fallback: err}, nil
+}
+
+func (c *Client) patchIndex(ctx context.Context, name string, current []byte) ([]byte, []string, error) {
+	if c.Release == nil {
+		return nil, nil, fmt.Errorf("No Release file for %s, Update first", c.Entry.Suite)
+	}
+	hashes, ok := c.Release.Files()[name+".diff/Index"]
+	if !ok {
+		return nil, nil, fmt.Errorf("%s has no pdiffs", name)
+	}
+	data, err := c.fetchVerified(ctx, name+".diff/Index", hashes)
+	if err != nil {
+		return nil, nil, err
+	}
+	index, err := ParsePDiffIndex(bytes.NewReader(data))
+	if err != nil {
+		return nil, nil, err
+	}
+	steps, err := index.Plan(fmt.Sprintf("%x", sha256.Sum256(current)))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	applied := []string{}
+	for _, step := range steps {
+		compressed, err := c.fetch(ctx, c.suitePath(name+".diff/"+step.Download.Filename), step.Download.Size)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := (control.FileHashes{step.Download}).VerifyReader(bytes.NewReader(compressed)); err != nil {
+			return nil, nil, err
+		}
+		patch, err := hashio.NewDecompressingReader(bytes.NewReader(compressed))
+		if err != nil {
+			return nil, nil, err
+		}
+		script, err := ioutil.ReadAll(patch)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := (control.FileHashes{step.Patch}).VerifyReader(bytes.NewReader(script)); err != nil {
+			return nil, nil, err
+		}
+		if current, err = ApplyEdScript(current, bytes.NewReader(script)); err != nil {
+			return nil, nil, err
+		}
+		step.Result.Filename = name
+		if err := (control.FileHashes{step.Result}).VerifyReader(bytes.NewReader(current)); err != nil {
+			return nil, nil, err
+		}
+		applied = append(applied, step.Name)
+	}
+
+	/* The result has to be the index the Release file lists */
+	if hashes, ok := c.Release.Files()[name]; ok {
+		if err := hashes.VerifyReader(bytes.NewReader(current)); err != nil {
+			return nil, nil, err
+		}
+	}
+	return current, applied, nil
+}
+
+// }}}
+
+// vim: foldmethod=marker
@@ -0,0 +1,164 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package archive_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/ebikt/go-debian/archive"
+)
+
+/*
+ *
+ */
+
+const (
+	pdiffV1 = "Package: a\nVersion: 1\n\nPackage: b\nVersion: 1\n"
+	pdiffV2 = "Package: a\nVersion: 1\n\nPackage: b\nVersion: 2\n"
+	pdiffV3 = "Package: b\nVersion: 2\n\nPackage: c\nVersion: 1\n"
+
+	pdiffPatch1 = "5c\nVersion: 2\n.\n"
+	pdiffPatch2 = "5a\n\nPackage: c\nVersion: 1\n.\n1,3d\n"
+)
+
+func sha256Line(data []byte, name string) string {
+	return strings.TrimSpace(fmt.Sprintf("%x %d %s", sha256.Sum256(data), len(data), name))
+}
+
+func pdiffIndex(t *testing.T, patches map[string][]byte) string {
+	return fmt.Sprintf(`SHA256-Current: %s
+SHA256-History:
+ %s
+ %s
+SHA256-Patches:
+ %s
+ %s
+SHA256-Download:
+ %s
+ %s
+`,
+		sha256Line([]byte(pdiffV3), ""),
+		sha256Line([]byte(pdiffV1), "T-1"),
+		sha256Line([]byte(pdiffV2), "T-2"),
+		sha256Line([]byte(pdiffPatch1), "T-1"),
+		sha256Line([]byte(pdiffPatch2), "T-2"),
+		sha256Line(patches["T-1.gz"], "T-1.gz"),
+		sha256Line(patches["T-2.gz"], "T-2.gz"),
+	)
+}
+
+func TestApplyEdScript(t *testing.T) {
+	data, err := archive.ApplyEdScript([]byte(pdiffV1), strings.NewReader(pdiffPatch1))
+	isok(t, err)
+	assert(t, string(data) == pdiffV2)
+	data, err = archive.ApplyEdScript(data, strings.NewReader(pdiffPatch2))
+	isok(t, err)
+	assert(t, string(data) == pdiffV3)
+
+	data, err = archive.ApplyEdScript([]byte("b\n"), strings.NewReader("0a\na\n.\n1a\nc\n.\n"))
+	isok(t, err)
+	assert(t, string(data) == "a\nc\nb\n")
+
+	for _, script := range []string{"9d\n", "1x\n", "1c\nfoo\n", "a,bd\n", "0d\n", "3,2d\n"} {
+		_, err := archive.ApplyEdScript([]byte(pdiffV1), strings.NewReader(script))
+		notok(t, err)
+	}
+}
+
+func TestPDiffIndexPlan(t *testing.T) {
+	patches := map[string][]byte{"T-1.gz": gzipped(t, pdiffPatch1), "T-2.gz": gzipped(t, pdiffPatch2)}
+	index, err := archive.ParsePDiffIndex(strings.NewReader(pdiffIndex(t, patches)))
+	isok(t, err)
+	assert(t, !index.Merged())
+
+	hash := func(data string) string { return fmt.Sprintf("%x", sha256.Sum256([]byte(data))) }
+	steps, err := index.Plan(hash(pdiffV1))
+	isok(t, err)
+	assert(t, len(steps) == 2)
+	assert(t, steps[0].Name == "T-1")
+	assert(t, steps[0].Result.Hash == hash(pdiffV2))
+	assert(t, steps[1].Result.Hash == hash(pdiffV3))
+	assert(t, steps[1].Download.Filename == "T-2.gz")
+
+	steps, err = index.Plan(hash(pdiffV3))
+	isok(t, err)
+	assert(t, len(steps) == 0)
+	_, err = index.Plan(hash("something else"))
+	notok(t, err)
+
+	index.PatchPrecedence = "merged"
+	steps, err = index.Plan(hash(pdiffV1))
+	isok(t, err)
+	assert(t, len(steps) == 1)
+	assert(t, steps[0].Result.Hash == hash(pdiffV3))
+
+	_, err = archive.ParsePDiffIndex(strings.NewReader("SHA256-Current: 0123\n"))
+	notok(t, err)
+}
+
+func TestClientUpdateIndex(t *testing.T) {
+	patches := map[string][]byte{"T-1.gz": gzipped(t, pdiffPatch1), "T-2.gz": gzipped(t, pdiffPatch2)}
+	suite := testSuite()
+	suite.Indexes["main/binary-amd64/Packages"] = []byte(pdiffV3)
+	suite.Indexes["main/binary-amd64/Packages.diff/Index"] = []byte(pdiffIndex(t, patches))
+	repo, client := clientRepository(t, suite)
+	defer repo.Close()
+	for name, data := range patches {
+		repo.AddFile("dists/stable/main/binary-amd64/Packages.diff/"+name, data)
+	}
+
+	ctx := context.Background()
+	_, err := client.UpdateIndex(ctx, "main/binary-amd64/Packages", []byte(pdiffV1))
+	notok(t, err)
+	_, err = client.Update(ctx)
+	isok(t, err)
+
+	update, err := client.UpdateIndex(ctx, "main/binary-amd64/Packages", []byte(pdiffV1))
+	isok(t, err)
+	isok(t, update.Fallback)
+	assert(t, string(update.Data) == pdiffV3)
+	assert(t, strings.Join(update.Patches, " ") == "T-1 T-2")
+
+	update, err = client.UpdateIndex(ctx, "main/binary-amd64/Packages", []byte(pdiffV3))
+	isok(t, err)
+	isok(t, update.Fallback)
+	assert(t, len(update.Patches) == 0)
+
+	/* Unknown versions, and broken patches, are downloaded in full */
+	update, err = client.UpdateIndex(ctx, "main/binary-amd64/Packages", []byte("Package: z\n"))
+	isok(t, err)
+	notok(t, update.Fallback)
+	assert(t, string(update.Data) == pdiffV3)
+
+	repo.AddFile("dists/stable/main/binary-amd64/Packages.diff/T-2.gz", gzipped(t, "1d\n"))
+	update, err = client.UpdateIndex(ctx, "main/binary-amd64/Packages", []byte(pdiffV2))
+	isok(t, err)
+	notok(t, update.Fallback)
+	assert(t, len(update.Patches) == 0)
+	assert(t, bytes.Equal(update.Data, []byte(pdiffV3)))
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,141 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package archive // import "github.com/ebikt/go-debian/archive"
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/ebikt/go-debian/control"
+	"github.com/ebikt/go-debian/version"
+)
+
+// Pool layout {{{
+
+// PoolPrefix returns the directory of the pool grouping the source package
+// with the others starting alike: its first letter, or its first four for
+// the "lib" packages, such as "h" for hello and "libf" for libfoo.
+func PoolPrefix(source string) string {
+	if strings.HasPrefix(source, "lib") && len(source) > 3 {
+		return source[:4]
+	}
+	if source == "" {
+		return ""
+	}
+	return source[:1]
+}
+
+// PoolDirectory returns the directory of the pool holding the files of the
+// source package, such as "pool/main/libf/libfoo".
+func PoolDirectory(component, source string) string {
+	return "pool/" + component + "/" + PoolPrefix(source) + "/" + source
+}
+
+// SectionComponent returns the component of a Section, such as "contrib"
+// for "contrib/net"; sections without a component are in "main".
+func SectionComponent(section string) string {
+	if i := strings.Index(section, "/"); i >= 0 {
+		return section[:i]
+	}
+	return "main"
+}
+
+// DebFilename returns the name of the .deb of the binary package, such as
+// "libfoo1_1.2-1_amd64.deb", or of the .udeb of a udeb. The epoch of the
+// version is not part of it.
+func DebFilename(index control.BinaryIndex) string {
+	ver := index.Version
+	ver.Epoch = 0
+	ext := ".deb"
+	if index.IsUdeb() {
+		ext = ".udeb"
+	}
+	return index.Package + "_" + ver.String() + "_" + index.Architecture.String() + ext
+}
+
+// PackagesIndex returns the path of the Packages index of the component and
+// architecture, relative to the suite directory, such as
+// "main/binary-amd64/Packages", or of the udebs of the Debian Installer,
+// such as "main/debian-installer/binary-amd64/Packages".
+func PackagesIndex(component, arch string, udeb bool) string {
+	if udeb {
+		return component + "/debian-installer/binary-" + arch + "/Packages"
+	}
+	return component + "/binary-" + arch + "/Packages"
+}
+
+// BinaryPoolPath returns the path of the .deb of the binary package in the
+// pool, relative to the root of the repository, such as
+// "pool/main/libf/libfoo/libfoo1_1.2-1_amd64.deb". The component is the one
+// of its Section, and the directory the one of its source package.
+func BinaryPoolPath(index control.BinaryIndex) string {
+	component := SectionComponent(index.Section)
+	return PoolDirectory(component, index.SourcePackage()) + "/" + DebFilename(index)
+}
+
+// DscFilename returns the name of the .dsc of the source package, such as
+// "libfoo_1.2-1.dsc". The epoch of the version is not part of it.
+func DscFilename(source string, ver version.Version) string {
+	ver.Epoch = 0
+	return source + "_" + ver.String() + ".dsc"
+}
+
+// SourcePoolPath returns the path of the .dsc of the source package in the
+// pool of the component, such as "pool/main/libf/libfoo/libfoo_1.2-1.dsc".
+func SourcePoolPath(component, source string, ver version.Version) string {
+	return PoolDirectory(component, source) + "/" + DscFilename(source, ver)
+}
+
+// ParsePoolPath splits the path of a file of the pool, relative to the root
+// of the repository, into the component, the source package and the name
+// of the file, the other way around from PoolDirectory: for
+// "pool/main/libf/libfoo/libfoo1_1.2-1_amd64.deb", they are "main",
+// "libfoo" and "libfoo1_1.2-1_amd64.deb". The component may span several
+// directories, as "updates/main" does in the security archive; the
+// directory grouping the source package must be its PoolPrefix.
+func ParsePoolPath(p string) (component, source, filename string, err error) {
+	parts := strings.Split(path.Clean(strings.TrimPrefix(p, "/")), "/")
+	if len(parts) < 5 || parts[0] != "pool" {
+		return "", "", "", fmt.Errorf("Not a path of the pool: '%s'", p)
+	}
+	n := len(parts)
+	prefix, source, filename := parts[n-3], parts[n-2], parts[n-1]
+	if PoolPrefix(source) != prefix {
+		return "", "", "", fmt.Errorf("Source package %s is not under '%s' in '%s'", source, prefix, p)
+	}
+	return strings.Join(parts[1:n-3], "/"), source, filename, nil
+}
+
+// DownloadURL returns the URL of the .deb of the binary package in the
+// repository at baseURL, such as "https://deb.debian.org/debian": the one
+// of its Filename, or else of its BinaryPoolPath.
+func DownloadURL(baseURL string, index control.BinaryIndex) string {
+	filename := index.Filename
+	if filename == "" {
+		filename = BinaryPoolPath(index)
+	}
+	return strings.TrimSuffix(baseURL, "/") + "/" + strings.TrimPrefix(filename, "/")
+}
+
+// }}}
+
+// vim: foldmethod=marker
@@ -0,0 +1,112 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package archive_test
+
+import (
+	"testing"
+
+	"github.com/ebikt/go-debian/archive"
+	"github.com/ebikt/go-debian/control"
+	"github.com/ebikt/go-debian/dependency"
+	"github.com/ebikt/go-debian/version"
+)
+
+/*
+ *
+ */
+
+func TestPoolPrefix(t *testing.T) {
+	assert(t, archive.PoolPrefix("hello") == "h")
+	assert(t, archive.PoolPrefix("libfoo") == "libf")
+	assert(t, archive.PoolPrefix("lib") == "l")
+	assert(t, archive.PoolPrefix("0ad") == "0")
+	assert(t, archive.PoolDirectory("main", "libfoo") == "pool/main/libf/libfoo")
+	assert(t, archive.SectionComponent("net") == "main")
+	assert(t, archive.SectionComponent("non-free/libs") == "non-free")
+}
+
+func binaryIndex(t *testing.T, pkg, source, ver, arch, section string) control.BinaryIndex {
+	v, err := version.Parse(ver)
+	isok(t, err)
+	a, err := dependency.ParseArch(arch)
+	isok(t, err)
+	return control.BinaryIndex{
+		Package:      pkg,
+		Source:       source,
+		Version:      v,
+		Architecture: *a,
+		Section:      section,
+	}
+}
+
+func TestBinaryPoolPath(t *testing.T) {
+	index := binaryIndex(t, "libfoo1", "libfoo (1.2-1)", "1:1.2-1+b1", "amd64", "contrib/libs")
+	assert(t, archive.DebFilename(index) == "libfoo1_1.2-1+b1_amd64.deb")
+	assert(t, archive.BinaryPoolPath(index) == "pool/contrib/libf/libfoo/libfoo1_1.2-1+b1_amd64.deb")
+	assert(t, archive.DownloadURL("http://deb.debian.org/debian/", index) ==
+		"http://deb.debian.org/debian/pool/contrib/libf/libfoo/libfoo1_1.2-1+b1_amd64.deb")
+
+	index = binaryIndex(t, "hello-udeb", "hello", "2.10-3", "amd64", "debian-installer")
+	index.Paragraph = control.NewParagraph()
+	index.Paragraph.Set("Package-Type", "udeb")
+	assert(t, archive.BinaryPoolPath(index) == "pool/main/h/hello/hello-udeb_2.10-3_amd64.udeb")
+	assert(t, archive.PackagesIndex("main", "amd64", true) == "main/debian-installer/binary-amd64/Packages")
+	assert(t, archive.PackagesIndex("main", "amd64", false) == "main/binary-amd64/Packages")
+
+	index = binaryIndex(t, "hello", "", "2.10-3", "all", "devel")
+	assert(t, archive.BinaryPoolPath(index) == "pool/main/h/hello/hello_2.10-3_all.deb")
+	index.Filename = "pool/updates/main/h/hello/hello_2.10-3_all.deb"
+	assert(t, archive.DownloadURL("http://security.debian.org", index) ==
+		"http://security.debian.org/pool/updates/main/h/hello/hello_2.10-3_all.deb")
+}
+
+func TestParsePoolPath(t *testing.T) {
+	component, source, filename, err := archive.ParsePoolPath("pool/main/libf/libfoo/libfoo1_1.2-1_amd64.deb")
+	isok(t, err)
+	assert(t, component == "main")
+	assert(t, source == "libfoo")
+	assert(t, filename == "libfoo1_1.2-1_amd64.deb")
+
+	component, source, _, err = archive.ParsePoolPath("/pool/updates/main/h/hello/hello_2.10-3.dsc")
+	isok(t, err)
+	assert(t, component == "updates/main")
+	assert(t, source == "hello")
+
+	v, err := version.Parse("1:1.2-1")
+	isok(t, err)
+	p := archive.SourcePoolPath("non-free", "libfoo", v)
+	assert(t, p == "pool/non-free/libf/libfoo/libfoo_1.2-1.dsc")
+	component, source, filename, err = archive.ParsePoolPath(p)
+	isok(t, err)
+	assert(t, component == "non-free" && source == "libfoo" && filename == "libfoo_1.2-1.dsc")
+
+	for _, p := range []string{
+		"dists/main/h/hello/hello_2.10-3.dsc",
+		"pool/main/hello/hello_2.10-3.dsc",
+		"pool/main/l/libfoo/libfoo_1.2-1.dsc",
+		"pool/main/h/foo/foo_1.0.dsc",
+	} {
+		_, _, _, err = archive.ParsePoolPath(p)
+		notok(t, err)
+	}
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,515 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package archive // import "github.com/ebikt/go-debian/archive"
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/openpgp"
+
+	"github.com/ebikt/go-debian/control"
+	"github.com/ebikt/go-debian/deb"
+	"github.com/ebikt/go-debian/dependency"
+	"github.com/ebikt/go-debian/hashio"
+	"github.com/ebikt/go-debian/version"
+)
+
+// Publishing {{{
+
+// Publisher publishes a suite of a Repository out of the .deb and .dsc
+// files of its pool, like apt-ftparchive or reprepro do: the Packages and
+// Sources indexes of each component are generated from the packages
+// themselves, written in several compressed variants, and listed in the
+// Release file of the suite, which may be signed. The .udeb files of a
+// component are published in its debian-installer indexes, the way the
+// Debian Installer looks for them.
+type Publisher struct {
+	Repository *Repository
+
+	// Fields of the Release file.
+	Suite       string
+	Codename    string
+	Origin      string
+	Label       string
+	Description string
+
+	// Components to publish, the directories of the pool; all of them if
+	// empty.
+	Components []string
+
+	// Architectures to publish; all the ones of the .debs if empty, which
+	// requires some not to be of the "all" architecture. Packages of the
+	// "all" architecture are in the index of each of them.
+	Architectures []string
+
+	// Variants of the indexes to write; the uncompressed, gzip and xz
+	// ones if empty.
+	Compressions []Compression
+
+	// If set, the indexes are linked under their by-hash paths too, and
+	// the Release says Acquire-By-Hash.
+	ByHash bool
+
+	// If set, the Release gets a Valid-Until that far from now.
+	ValidFor time.Duration
+
+	// If set, the Release file is signed by this key, as InRelease and
+	// Release.gpg.
+	Signer *openpgp.Entity
+
+	// Overrides of each component, applied to the entries of its packages
+	// in the indexes. Components without any are published as the
+	// packages say.
+	Overrides map[string]*ComponentOverrides
+
+	// If set, told about each index written, by its path relative to the
+	// suite directory, as its uncompressed content is generated.
+	Progress hashio.Progress
+}
+
+// PublishReport tells what a Publish did.
+type PublishReport struct {
+	// Number of binary and source packages published, and of udebs.
+	Packages int
+	Sources  int
+	Udebs    int
+
+	// Indexes written, relative to the suite directory, without their
+	// compression extensions.
+	Indexes []string
+
+	// Packages missing from the overrides of their component, as
+	// "component/name" ("component/source/name" for source packages).
+	Unoverridden []string
+}
+
+// poolEntry is a package found in the pool: its index paragraph, and the
+// component and architecture it belongs to.
+type poolEntry struct {
+	para      control.Paragraph
+	component string
+	arch      string
+	name      string
+	version   version.Version
+	udeb      bool
+}
+
+// Publish scans the pool, and writes the indexes and the Release file of
+// the suite, replacing the ones already there. A suite switched to a
+// snapshot is published anew, leaving the snapshot alone.
+func (p *Publisher) Publish() (*PublishReport, error) {
+	if p.Suite == "" {
+		return nil, fmt.Errorf("No suite to publish")
+	}
+	binaries, sources, err := p.scanPool()
+	if err != nil {
+		return nil, err
+	}
+
+	components := p.Components
+	if len(components) == 0 {
+		components = poolComponents(binaries, sources)
+	}
+	architectures := p.Architectures
+	if len(architectures) == 0 {
+		architectures = poolArchitectures(binaries)
+		if len(architectures) == 0 && len(binaries) > 0 {
+			return nil, fmt.Errorf("The pool only has packages of the 'all' architecture, set the Architectures to publish them for")
+		}
+	}
+
+	report := PublishReport{Indexes: []string{}, Unoverridden: []string{}}
+	checksums := map[string]control.FileHashes{}
+	report.Unoverridden = append(report.Unoverridden, p.applyOverrides(binaries, "")...)
+	report.Unoverridden = append(report.Unoverridden, p.applyOverrides(sources, "source/")...)
+	sort.Strings(report.Unoverridden)
+
+	dir := p.Repository.path("dists/" + p.Suite)
+	if err := detachSuite(dir); err != nil {
+		return nil, err
+	}
+	for _, component := range components {
+		udebs := false
+		for _, entry := range binaries {
+			udebs = udebs || (entry.udeb && entry.component == component)
+		}
+		for _, arch := range architectures {
+			for _, udeb := range []bool{false, true} {
+				if udeb && !udebs {
+					continue
+				}
+				name := PackagesIndex(component, arch, udeb)
+				selected := []poolEntry{}
+				for _, entry := range binaries {
+					if entry.component == component && entry.udeb == udeb && (entry.arch == arch || entry.arch == "all") {
+						selected = append(selected, entry)
+					}
+				}
+				if err := p.writeIndex(dir, name, selected, checksums); err != nil {
+					return nil, err
+				}
+				report.Indexes = append(report.Indexes, name)
+				if udeb {
+					report.Udebs += len(selected)
+				} else {
+					report.Packages += len(selected)
+				}
+			}
+		}
+
+		name := component + "/source/Sources"
+		selected := []poolEntry{}
+		for _, entry := range sources {
+			if entry.component == component {
+				selected = append(selected, entry)
+			}
+		}
+		if err := p.writeIndex(dir, name, selected, checksums); err != nil {
+			return nil, err
+		}
+		report.Indexes = append(report.Indexes, name)
+		report.Sources += len(selected)
+	}
+
+	release := Release{
+		Origin:        p.Origin,
+		Label:         p.Label,
+		Suite:         p.Suite,
+		Codename:      p.Codename,
+		Description:   p.Description,
+		Components:    components,
+		AcquireByHash: p.ByHash,
+	}
+	now := time.Now().UTC()
+	release.Date = now.Format(time.RFC1123)
+	if p.ValidFor > 0 {
+		release.ValidUntil = now.Add(p.ValidFor).Format(time.RFC1123)
+	}
+	for _, arch := range architectures {
+		parsed, err := dependency.ParseArch(arch)
+		if err != nil {
+			return nil, err
+		}
+		release.Architectures = append(release.Architectures, *parsed)
+	}
+	if err := removeStaleIndexes(dir, checksums); err != nil {
+		return nil, err
+	}
+	if err := writeSuiteRelease(dir, release, p.Signer, checksums); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// detachSuite replaces the suite directory with an empty one if it's the
+// symlink into a snapshot Repository.Switch leaves, lest publishing writes
+// into the snapshot.
+func detachSuite(dir string) error {
+	info, err := os.Lstat(dir)
+	if err != nil || info.Mode()&os.ModeSymlink == 0 {
+		return nil
+	}
+	if err := os.Remove(dir); err != nil {
+		return err
+	}
+	return os.MkdirAll(dir, 0755)
+}
+
+// applyOverrides rewrites the entries with the overrides of their
+// component, returning the ones missing from them.
+func (p *Publisher) applyOverrides(entries []poolEntry, prefix string) []string {
+	missing := map[string]bool{}
+	for i := range entries {
+		entry := &entries[i]
+		overrides := p.Overrides[entry.component]
+		if overrides == nil {
+			continue
+		}
+		found := false
+		if entry.arch == "source" {
+			found = overrides.ApplySource(&entry.para)
+		} else {
+			found = overrides.ApplyBinary(&entry.para)
+		}
+		if !found {
+			missing[entry.component+"/"+prefix+entry.name] = true
+		}
+	}
+	return sortedKeys(missing)
+}
+
+func poolComponents(entries ...[]poolEntry) []string {
+	set := map[string]bool{}
+	for _, list := range entries {
+		for _, entry := range list {
+			set[entry.component] = true
+		}
+	}
+	return sortedKeys(set)
+}
+
+// poolArchitectures returns the architectures of the binary packages,
+// leaving out "all", which isn't one the indexes can be published for.
+func poolArchitectures(binaries []poolEntry) []string {
+	set := map[string]bool{}
+	for _, entry := range binaries {
+		if entry.arch != "all" {
+			set[entry.arch] = true
+		}
+	}
+	return sortedKeys(set)
+}
+
+// staleIndex matches the paths of the Packages and Sources indexes a
+// Publisher writes, relative to the suite directory.
+var staleIndex = regexp.MustCompile(`(^|/)(binary-[^/]+/Packages|source/Sources)(\.[^/.]+)?$`)
+
+// removeStaleIndexes removes the Packages and Sources indexes of the
+// suite directory which were not just written, as those of components or
+// architectures no longer published, lest the Release file lists them.
+func removeStaleIndexes(dir string, written map[string]control.FileHashes) error {
+	return filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if info.Name() == "by-hash" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if _, ok := written[rel]; ok || !staleIndex.MatchString(rel) {
+			return nil
+		}
+		return os.Remove(p)
+	})
+}
+
+// writeIndex writes the index of the entries, sorted by name and version,
+// and adds the checksums of its variants to the ones known, by their path
+// relative to the suite directory.
+func (p *Publisher) writeIndex(dir, name string, entries []poolEntry, checksums map[string]control.FileHashes) error {
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].name != entries[j].name {
+			return entries[i].name < entries[j].name
+		}
+		return version.Compare(entries[i].version, entries[j].version) < 0
+	})
+
+	dest := filepath.Join(dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	compressions := p.Compressions
+	if len(compressions) == 0 {
+		compressions = []Compression{{Extension: ""}, {Extension: "gz"}, {Extension: "xz"}}
+	}
+	out, err := NewHashingCompressedWriter(dest, releaseHashes, compressions...)
+	if err != nil {
+		return err
+	}
+	if p.Progress != nil {
+		p.Progress.Start(name, -1)
+	}
+	writer := NewIndexWriter(hashio.NewProgressWriter(out, p.Progress, name))
+	for _, entry := range entries {
+		if err = writer.Write(entry.para); err != nil {
+			out.abort()
+			break
+		}
+	}
+	if err == nil {
+		err = out.Close()
+	}
+	if p.Progress != nil {
+		p.Progress.Done(name, err)
+	}
+	if err != nil {
+		return err
+	}
+	for i, hashes := range out.Checksums() {
+		variant := name + strings.TrimPrefix(out.Paths()[i], dest)
+		checksums[variant] = hashes
+	}
+	return nil
+}
+
+// }}}
+
+// Pool scanning {{{
+
+// scanPool reads the .deb, .udeb and .dsc files of the pool.
+func (p *Publisher) scanPool() ([]poolEntry, []poolEntry, error) {
+	binaries, sources := []poolEntry{}, []poolEntry{}
+	root := p.Repository.path("pool")
+	err := filepath.Walk(root, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(p.Repository.Root, file)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		ext := path.Ext(rel)
+		if ext != ".deb" && ext != ".udeb" && ext != ".dsc" {
+			return nil
+		}
+		component, _, _, err := ParsePoolPath(rel)
+		if err != nil {
+			return err
+		}
+
+		switch ext {
+		case ".deb", ".udeb":
+			entry, err := binaryPoolEntry(file, rel)
+			if err != nil {
+				return fmt.Errorf("%s: %s", rel, err)
+			}
+			entry.component = component
+			binaries = append(binaries, *entry)
+		case ".dsc":
+			entry, err := sourcePoolEntry(file, rel)
+			if err != nil {
+				return fmt.Errorf("%s: %s", rel, err)
+			}
+			entry.component = component
+			sources = append(sources, *entry)
+		}
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return binaries, sources, nil
+	}
+	return binaries, sources, err
+}
+
+// binaryPoolEntry reads the control file of a .deb into the paragraph of
+// its Packages entry.
+func binaryPoolEntry(file, rel string) (*poolEntry, error) {
+	debFile, closer, err := deb.LoadFileHashed(file, "md5", "sha256")
+	if err != nil {
+		return nil, err
+	}
+	defer closer()
+	hashes, err := debFile.Checksums()
+	if err != nil {
+		return nil, err
+	}
+
+	para := control.NewParagraph()
+	for _, key := range debFile.Control.Paragraph.Order {
+		para.Set(key, debFile.Control.Paragraph.Get(key))
+	}
+	para.Set("Filename", rel)
+	para.Set("Size", fmt.Sprint(hashes[0].Size))
+	para.Set("MD5sum", hashes[0].Hash)
+	para.Set("SHA256", hashes[1].Hash)
+	return &poolEntry{
+		para:    para,
+		arch:    debFile.Control.Architecture.String(),
+		name:    debFile.Control.Package,
+		version: debFile.Control.Version,
+		udeb:    debFile.IsUdeb(),
+	}, nil
+}
+
+// hashFile returns the MD5 and SHA256 checksums of the file.
+func hashFile(file, name string) (*control.FileHash, *control.FileHash, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+	writer, hashers, err := hashio.NewHasherWriters([]string{"md5", "sha256"}, io.Discard)
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, err := io.Copy(writer, f); err != nil {
+		return nil, nil, err
+	}
+	md5sum := control.FileHashFromHasher(name, *hashers[0])
+	sha256sum := control.FileHashFromHasher(name, *hashers[1])
+	return &md5sum, &sha256sum, nil
+}
+
+// sourcePoolEntry reads a .dsc into the paragraph of its Sources entry,
+// which lists the .dsc itself among the files.
+func sourcePoolEntry(file, rel string) (*poolEntry, error) {
+	dsc, err := control.ParseDscFile(file)
+	if err != nil {
+		return nil, err
+	}
+	md5sum, sha256sum, err := hashFile(file, path.Base(rel))
+	if err != nil {
+		return nil, err
+	}
+
+	line := func(hash control.FileHash) string {
+		return fmt.Sprintf("\n%s %d %s", hash.Hash, hash.Size, hash.Filename)
+	}
+	files, checksums := line(*md5sum), line(*sha256sum)
+	for _, hash := range dsc.Files {
+		files += line(hash.FileHash)
+	}
+	for _, hash := range dsc.ChecksumsSha256 {
+		checksums += line(hash.FileHash)
+	}
+
+	para := control.NewParagraph()
+	para.Set("Package", dsc.Source)
+	for _, key := range dsc.Paragraph.Order {
+		switch strings.ToLower(key) {
+		case "source", "files", "checksums-sha1", "checksums-sha256":
+			continue
+		}
+		para.Set(key, dsc.Paragraph.Get(key))
+	}
+	para.Set("Directory", path.Dir(rel))
+	para.Set("Files", files)
+	para.Set("Checksums-Sha256", checksums)
+	return &poolEntry{
+		para:    para,
+		arch:    "source",
+		name:    dsc.Source,
+		version: dsc.Version,
+	}, nil
+}
+
+// }}}
+
+// vim: foldmethod=marker
@@ -0,0 +1,338 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package archive_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ebikt/go-debian/archive"
+	"github.com/ebikt/go-debian/control"
+	"github.com/ebikt/go-debian/testutil"
+)
+
+/*
+ *
+ */
+
+const helloDsc = `Format: 3.0 (quilt)
+Source: hello
+Binary: hello
+Architecture: any
+Version: 2.10-3
+Maintainer: Santiago Vila <sanvila@debian.org>
+Build-Depends: debhelper-compat (= 13)
+Checksums-Sha256:
+ cd9a2e08f3dcfbd14e5d67b3ed3fd8fcb94d4f5d6b2f30bb0b1b3aef6ab0e8a5 6 hello_2.10.orig.tar.gz
+Files:
+ dd9a2e08f3dcfbd14e5d67b3ed3fd8fc 6 hello_2.10.orig.tar.gz
+`
+
+func TestPublish(t *testing.T) {
+	root := t.TempDir()
+	repo, err := archive.NewRepository(root)
+	isok(t, err)
+
+	for _, d := range []testutil.Deb{
+		{Package: "hello", Version: "2.10-3", Architecture: "amd64"},
+		{Package: "hello", Version: "2.10-2", Architecture: "amd64"},
+		{Package: "hello", Version: "2.10-3", Architecture: "arm64"},
+		{Package: "hello-doc", Version: "2.10-3", Source: "hello"},
+	} {
+		data, err := d.Build()
+		isok(t, err)
+		writeFile(t, root, d.PoolPath("main"), string(data))
+	}
+	writeFile(t, root, "pool/main/h/hello/hello_2.10-3.dsc", helloDsc)
+	writeFile(t, root, "pool/main/h/hello/hello_2.10.orig.tar.gz", "hello\n")
+
+	signer, err := testutil.NewKey("Publisher", "publisher@example.com")
+	isok(t, err)
+	publisher := archive.Publisher{
+		Repository:   repo,
+		Suite:        "stable",
+		Origin:       "Local",
+		Compressions: []archive.Compression{{Extension: ""}, {Extension: "gz"}},
+		ByHash:       true,
+		Signer:       signer,
+	}
+	report, err := publisher.Publish()
+	isok(t, err)
+	assert(t, report.Packages == 5)
+	assert(t, report.Sources == 1)
+	assert(t, len(report.Indexes) == 3)
+	assert(t, exists(root, "dists/stable/main/binary-amd64/Packages.gz"))
+	assert(t, !exists(root, "dists/stable/main/binary-amd64/Packages.xz"))
+	assert(t, exists(root, "dists/stable/main/binary-arm64/Packages"))
+
+	packages, err := control.OpenBinaryIndex(filepath.Join(root, "dists/stable/main/binary-amd64/Packages.gz"))
+	isok(t, err)
+	defer packages.Close()
+	names := []string{}
+	for {
+		entry, err := packages.Next()
+		if err != nil {
+			break
+		}
+		names = append(names, entry.Package+" "+entry.Version.String())
+		assert(t, exists(root, entry.Filename))
+		assert(t, entry.SHA256 != "")
+	}
+	assert(t, strings.Join(names, ", ") == "hello 2.10-2, hello 2.10-3, hello-doc 2.10-3")
+
+	sources, err := control.OpenSourceIndex(filepath.Join(root, "dists/stable/main/source/Sources"))
+	isok(t, err)
+	defer sources.Close()
+	source, err := sources.Next()
+	isok(t, err)
+	assert(t, source.Package == "hello")
+	assert(t, source.Directory == "pool/main/h/hello")
+	files := strings.Join(source.Files, "\n")
+	assert(t, strings.Contains(files, " hello_2.10-3.dsc"))
+	assert(t, strings.Contains(files, " 6 hello_2.10.orig.tar.gz"))
+
+	f, err := os.Open(filepath.Join(root, "dists/stable/InRelease"))
+	isok(t, err)
+	defer f.Close()
+	cleartext, _, err := control.DecodeClearsigned(f, testutil.Keyring(signer))
+	isok(t, err)
+	release, err := archive.ParseRelease(bytes.NewReader(cleartext))
+	isok(t, err)
+	assert(t, release.Suite == "stable")
+	assert(t, release.AcquireByHash)
+	assert(t, len(release.Architectures) == 2)
+	assert(t, len(release.Components) == 1)
+	for _, hash := range release.SHA256 {
+		assert(t, exists(root, "dists/stable/"+filepath.Dir(hash.Filename)+"/by-hash/SHA256/"+hash.Hash))
+	}
+}
+
+func TestPublishOverrides(t *testing.T) {
+	root := t.TempDir()
+	repo, err := archive.NewRepository(root)
+	isok(t, err)
+	for _, d := range []testutil.Deb{
+		{Package: "hello", Version: "2.10-3", Architecture: "amd64"},
+		{Package: "hello-doc", Version: "2.10-3", Source: "hello"},
+	} {
+		data, err := d.Build()
+		isok(t, err)
+		writeFile(t, root, d.PoolPath("main"), string(data))
+	}
+	writeFile(t, root, "pool/main/h/hello/hello_2.10-3.dsc", helloDsc)
+
+	binary, err := archive.ParseOverrides(strings.NewReader("hello important devel\n"))
+	isok(t, err)
+	extra, err := archive.ParseExtraOverrides(strings.NewReader("hello Task standard\n"))
+	isok(t, err)
+	source, err := archive.ParseSourceOverrides(strings.NewReader("hello devel\n"))
+	isok(t, err)
+	report, err := (&archive.Publisher{
+		Repository:   repo,
+		Suite:        "unstable",
+		Compressions: []archive.Compression{{Extension: ""}},
+		Overrides: map[string]*archive.ComponentOverrides{
+			"main": {Binary: binary, Source: source, Extra: extra},
+		},
+	}).Publish()
+	isok(t, err)
+	assert(t, strings.Join(report.Unoverridden, " ") == "main/hello-doc")
+
+	packages, err := control.OpenBinaryIndex(filepath.Join(root, "dists/unstable/main/binary-amd64/Packages"))
+	isok(t, err)
+	defer packages.Close()
+	entry, err := packages.Next()
+	isok(t, err)
+	assert(t, entry.Package == "hello")
+	assert(t, entry.Section == "devel")
+	assert(t, entry.Priority == "important")
+	assert(t, entry.Get("Task") == "standard")
+
+	sources, err := control.OpenSourceIndex(filepath.Join(root, "dists/unstable/main/source/Sources"))
+	isok(t, err)
+	defer sources.Close()
+	sourceEntry, err := sources.Next()
+	isok(t, err)
+	assert(t, sourceEntry.Section == "devel")
+}
+
+func TestPublishUdebs(t *testing.T) {
+	root := t.TempDir()
+	repo, err := archive.NewRepository(root)
+	isok(t, err)
+	for _, d := range []testutil.Deb{
+		{Package: "hello", Version: "2.10-3", Architecture: "amd64"},
+		{Package: "hello-udeb", Version: "2.10-3", Architecture: "amd64", Source: "hello",
+			Fields: map[string]string{"Package-Type": "udeb", "Section": "debian-installer"}},
+	} {
+		data, err := d.Build()
+		isok(t, err)
+		writeFile(t, root, d.PoolPath("main"), string(data))
+	}
+	assert(t, exists(root, "pool/main/h/hello/hello-udeb_2.10-3_amd64.udeb"))
+
+	report, err := (&archive.Publisher{
+		Repository:   repo,
+		Suite:        "unstable",
+		Compressions: []archive.Compression{{Extension: ""}},
+	}).Publish()
+	isok(t, err)
+	assert(t, report.Packages == 1)
+	assert(t, report.Udebs == 1)
+	assert(t, strings.Join(report.Indexes, " ") ==
+		"main/binary-amd64/Packages main/debian-installer/binary-amd64/Packages main/source/Sources")
+
+	for name, expected := range map[string]string{
+		"main/binary-amd64/Packages":                  "hello",
+		"main/debian-installer/binary-amd64/Packages": "hello-udeb",
+	} {
+		packages, err := control.OpenBinaryIndex(filepath.Join(root, "dists/unstable", name))
+		isok(t, err)
+		defer packages.Close()
+		entry, err := packages.Next()
+		isok(t, err)
+		assert(t, entry.Package == expected)
+		assert(t, entry.IsUdeb() == (expected == "hello-udeb"))
+		_, err = packages.Next()
+		notok(t, err)
+	}
+}
+
+func TestPublishEmpty(t *testing.T) {
+	root := t.TempDir()
+	repo, err := archive.NewRepository(root)
+	isok(t, err)
+
+	_, err = (&archive.Publisher{Repository: repo}).Publish()
+	notok(t, err)
+
+	progress := recordedProgress{}
+	report, err := (&archive.Publisher{
+		Repository: repo,
+		Suite:      "unstable",
+		Components: []string{"main"},
+		Progress:   &progress,
+	}).Publish()
+	isok(t, err)
+	assert(t, report.Packages == 0)
+	assert(t, len(progress.events) == 2*len(report.Indexes))
+	assert(t, progress.events[0] == "start "+report.Indexes[0]+" -1")
+	assert(t, progress.events[1] == "done "+report.Indexes[0]+" <nil>")
+	assert(t, !exists(root, "dists/unstable/main/binary-all/Packages.xz"))
+	assert(t, exists(root, "dists/unstable/main/source/Sources.gz"))
+	assert(t, exists(root, "dists/unstable/Release"))
+	assert(t, !exists(root, "dists/unstable/InRelease"))
+
+	/* No architecture to publish the packages of "all" for */
+	d := testutil.Deb{Package: "hello-doc", Version: "2.10-3", Source: "hello"}
+	data, err := d.Build()
+	isok(t, err)
+	writeFile(t, root, d.PoolPath("main"), string(data))
+	_, err = (&archive.Publisher{Repository: repo, Suite: "unstable"}).Publish()
+	notok(t, err)
+	_, err = (&archive.Publisher{Repository: repo, Suite: "unstable", Architectures: []string{"amd64"}}).Publish()
+	isok(t, err)
+	assert(t, exists(root, "dists/unstable/main/binary-amd64/Packages.xz"))
+}
+
+func TestPublishStaleIndexes(t *testing.T) {
+	root := t.TempDir()
+	repo, err := archive.NewRepository(root)
+	isok(t, err)
+	for _, d := range []testutil.Deb{
+		{Package: "hello", Version: "2.10-3", Architecture: "amd64"},
+		{Package: "hello", Version: "2.10-3", Architecture: "arm64"},
+	} {
+		data, err := d.Build()
+		isok(t, err)
+		writeFile(t, root, d.PoolPath("main"), string(data))
+		writeFile(t, root, d.PoolPath("contrib"), string(data))
+	}
+	writeFile(t, root, "dists/stable/main/i18n/Translation-en", "")
+	publisher := archive.Publisher{Repository: repo, Suite: "stable", ByHash: true}
+	_, err = publisher.Publish()
+	isok(t, err)
+	assert(t, exists(root, "dists/stable/contrib/binary-arm64/Packages.xz"))
+
+	publisher.Components = []string{"main"}
+	publisher.Architectures = []string{"amd64"}
+	_, err = publisher.Publish()
+	isok(t, err)
+	assert(t, exists(root, "dists/stable/main/binary-amd64/Packages.xz"))
+	assert(t, !exists(root, "dists/stable/main/binary-arm64/Packages.xz"))
+	assert(t, !exists(root, "dists/stable/contrib/binary-amd64/Packages"))
+	assert(t, !exists(root, "dists/stable/contrib/source/Sources.gz"))
+	assert(t, exists(root, "dists/stable/main/i18n/Translation-en"))
+
+	data, err := os.ReadFile(filepath.Join(root, "dists/stable/Release"))
+	isok(t, err)
+	release, err := archive.ParseRelease(bytes.NewReader(data))
+	isok(t, err)
+	assert(t, len(release.SHA256) > 0)
+	for _, hash := range release.SHA256 {
+		assert(t, !strings.HasPrefix(hash.Filename, "contrib/"))
+		assert(t, !strings.Contains(hash.Filename, "binary-arm64"))
+	}
+}
+
+func TestPublishSwitched(t *testing.T) {
+	root := t.TempDir()
+	repo, err := archive.NewRepository(root)
+	isok(t, err)
+	d := testutil.Deb{Package: "hello", Version: "2.10-3", Architecture: "amd64"}
+	data, err := d.Build()
+	isok(t, err)
+	writeFile(t, root, d.PoolPath("main"), string(data))
+	publisher := archive.Publisher{Repository: repo, Suite: "stable", Compressions: []archive.Compression{{Extension: ""}}}
+	_, err = publisher.Publish()
+	isok(t, err)
+	_, err = repo.Snapshot("one")
+	isok(t, err)
+	isok(t, repo.Switch("stable", "one"))
+
+	frozen := filepath.Join(root, "snapshots/one/dists/stable/main/binary-amd64/Packages")
+	before, err := os.ReadFile(frozen)
+	isok(t, err)
+
+	d.Version = "2.10-4"
+	data, err = d.Build()
+	isok(t, err)
+	writeFile(t, root, d.PoolPath("main"), string(data))
+	report, err := publisher.Publish()
+	isok(t, err)
+	assert(t, report.Packages == 2)
+
+	after, err := os.ReadFile(frozen)
+	isok(t, err)
+	assert(t, string(after) == string(before))
+	info, err := os.Lstat(filepath.Join(root, "dists/stable"))
+	isok(t, err)
+	assert(t, info.IsDir())
+	live, err := os.ReadFile(filepath.Join(root, "dists/stable/main/binary-amd64/Packages"))
+	isok(t, err)
+	assert(t, strings.Contains(string(live), "Version: 2.10-4"))
+	assert(t, exists(root, "dists/stable/Release"))
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,192 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package archive // import "github.com/ebikt/go-debian/archive"
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ebikt/go-debian/control"
+	"github.com/ebikt/go-debian/dependency"
+)
+
+// Release {{{
+
+// The Release struct represents the Release (or InRelease) file at the top
+// of each dists/<suite>/ directory, which describes the suite and lists
+// the checksums of all of its index files.
+type Release struct {
+	control.Paragraph
+
+	Origin        string
+	Label         string
+	Suite         string
+	Version       string
+	Codename      string
+	Date          string
+	ValidUntil    string            `control:"Valid-Until"`
+	Architectures []dependency.Arch `control:"Architectures"`
+	Components    []string
+	Description   string
+
+	AcquireByHash bool `control:"Acquire-By-Hash"`
+
+	MD5Sum []control.MD5FileHash    `control:"MD5Sum" delim:"\n" strip:"\n\r\t "`
+	SHA1   []control.SHA1FileHash   `control:"SHA1" delim:"\n" strip:"\n\r\t "`
+	SHA256 []control.SHA256FileHash `control:"SHA256" delim:"\n" strip:"\n\r\t "`
+	SHA512 []control.SHA512FileHash `control:"SHA512" delim:"\n" strip:"\n\r\t "`
+}
+
+// Layouts seen in the wild for the Date and Valid-Until fields. The first
+// one is what dak and reprepro write.
+var releaseDateLayouts = []string{
+	time.RFC1123,
+	time.RFC1123Z,
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+}
+
+func parseReleaseDate(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	for _, layout := range releaseDateLayouts {
+		if when, err := time.Parse(layout, value); err == nil {
+			return when, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("Unknown date format: '%s'", value)
+}
+
+// Parse the Date field of the Release file.
+func (r *Release) DateTime() (time.Time, error) {
+	return parseReleaseDate(r.Date)
+}
+
+// Parse the Valid-Until field of the Release file. If there's no such
+// field, the zero time is returned.
+func (r *Release) ValidUntilTime() (time.Time, error) {
+	if r.ValidUntil == "" {
+		return time.Time{}, nil
+	}
+	return parseReleaseDate(r.ValidUntil)
+}
+
+// Checksums returns the strongest set of checksums present in the Release
+// file.
+func (r *Release) Checksums() []control.FileHash {
+	ret := []control.FileHash{}
+	switch {
+	case len(r.SHA512) > 0:
+		for _, hash := range r.SHA512 {
+			ret = append(ret, hash.FileHash)
+		}
+	case len(r.SHA256) > 0:
+		for _, hash := range r.SHA256 {
+			ret = append(ret, hash.FileHash)
+		}
+	case len(r.SHA1) > 0:
+		for _, hash := range r.SHA1 {
+			ret = append(ret, hash.FileHash)
+		}
+	default:
+		for _, hash := range r.MD5Sum {
+			ret = append(ret, hash.FileHash)
+		}
+	}
+	return ret
+}
+
+// Files returns the checksums of each of the files listed in the Release
+// file, with all the algorithms they are listed with, keyed by their path
+// relative to the directory of the Release file.
+func (r *Release) Files() map[string]control.FileHashes {
+	ret := map[string]control.FileHashes{}
+	add := func(hash control.FileHash) {
+		ret[hash.Filename] = append(ret[hash.Filename], hash)
+	}
+	for _, hash := range r.MD5Sum {
+		add(hash.FileHash)
+	}
+	for _, hash := range r.SHA1 {
+		add(hash.FileHash)
+	}
+	for _, hash := range r.SHA256 {
+		add(hash.FileHash)
+	}
+	for _, hash := range r.SHA512 {
+		add(hash.FileHash)
+	}
+	return ret
+}
+
+// Validate checks the index file `name`, relative to the directory of the
+// Release file (such as "main/binary-amd64/Packages.xz"), read from the
+// reader, against its size and checksums. Files the Release file doesn't
+// list are an error.
+func (r *Release) Validate(name string, reader io.Reader) error {
+	hashes, ok := r.Files()[name]
+	if !ok {
+		return fmt.Errorf("%s is not listed in the Release file", name)
+	}
+	return hashes.VerifyReader(reader)
+}
+
+// Expired tells whether the Release file is past its Valid-Until date at
+// the time `now`, after which apt refuses to use it. Release files without
+// a Valid-Until field never expire, and the ones with an unknown date
+// format always are.
+func (r *Release) Expired(now time.Time) bool {
+	validUntil, err := r.ValidUntilTime()
+	if err != nil {
+		return true
+	}
+	return !validUntil.IsZero() && now.After(validUntil)
+}
+
+// Given a path on the filesystem, Parse the file off the disk and return
+// a pointer to a brand new Release struct, unless error is set to a value
+// other than nil.
+func ParseReleaseFile(path string) (*Release, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseRelease(bufio.NewReader(f))
+}
+
+// Given a reader, parse a Release (or clearsigned InRelease) file. The
+// OpenPGP signature of an InRelease file is not checked; use a
+// control.Decoder with a keyring for that.
+func ParseRelease(reader io.Reader) (*Release, error) {
+	ret := Release{}
+	if err := control.Unmarshal(&ret, reader); err != nil {
+		return nil, err
+	}
+	return &ret, nil
+}
+
+// }}}
+
+// vim: foldmethod=marker
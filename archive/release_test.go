@@ -0,0 +1,125 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package archive_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ebikt/go-debian/archive"
+)
+
+/*
+ *
+ */
+
+const bookwormRelease = `Origin: Debian
+Label: Debian
+Suite: stable
+Version: 12.5
+Codename: bookworm
+Changelogs: https://metadata.ftp-master.debian.org/changelogs/@CHANGEPATH@_changelog
+Date: Sat, 10 Feb 2024 09:45:47 UTC
+Acquire-By-Hash: yes
+No-Support-for-Architecture-all: Packages
+Architectures: all amd64 arm64 armel armhf i386 mips64el mipsel ppc64el s390x
+Components: main contrib non-free-firmware non-free
+Description: Debian 12.5 Released 10 February 2024
+MD5Sum:
+ 0ed6d4c8891eb86358b94bb35d9e4da4  1484322 contrib/Contents-all
+ d0a0325a97c42fd5f66a8c3e29bcea64    98581 contrib/Contents-all.gz
+SHA256:
+ d6c9c82f4e61b4662f9ba16b9ebb379c57b4943f8b7813091d1f637325ddfb79  1484322 contrib/Contents-all
+ 3e9a121d599b56c08bc8f144e4830807c77c29d7114316d6984ba54695d3db7b    98581 contrib/Contents-all.gz
+`
+
+func TestReleaseParse(t *testing.T) {
+	release, err := archive.ParseRelease(strings.NewReader(bookwormRelease))
+	isok(t, err)
+
+	assert(t, release.Suite == "stable")
+	assert(t, release.Codename == "bookworm")
+	assert(t, release.AcquireByHash)
+	assert(t, len(release.Architectures) == 10)
+	assert(t, len(release.Components) == 4)
+	assert(t, release.Get("Changelogs") != "")
+
+	date, err := release.DateTime()
+	isok(t, err)
+	assert(t, date.Equal(time.Date(2024, 2, 10, 9, 45, 47, 0, time.UTC)))
+
+	validUntil, err := release.ValidUntilTime()
+	isok(t, err)
+	assert(t, validUntil.IsZero())
+
+	checksums := release.Checksums()
+	assert(t, len(checksums) == 2)
+	assert(t, checksums[0].Algorithm == "sha256")
+	assert(t, checksums[1].Filename == "contrib/Contents-all.gz")
+	assert(t, checksums[1].Size == 98581)
+
+	files := release.Files()
+	assert(t, len(files) == 2)
+	assert(t, len(files["contrib/Contents-all"]) == 2)
+	assert(t, !release.Expired(time.Now()))
+}
+
+func TestReleaseJSON(t *testing.T) {
+	release, err := archive.ParseRelease(strings.NewReader(bookwormRelease))
+	isok(t, err)
+	data, err := json.Marshal(release)
+	isok(t, err)
+	assert(t, strings.HasPrefix(string(data), `{"Origin":"Debian","Label":"Debian","Suite":"stable"`))
+
+	decoded := archive.Release{}
+	isok(t, json.Unmarshal(data, &decoded))
+	assert(t, decoded.Codename == "bookworm")
+	assert(t, len(decoded.Architectures) == 10)
+	assert(t, len(decoded.Checksums()) == 2)
+	assert(t, decoded.Get("Changelogs") == release.Get("Changelogs"))
+}
+
+func TestReleaseValidate(t *testing.T) {
+	release, err := archive.ParseRelease(strings.NewReader(`Suite: stable
+Date: Sat, 10 Feb 2024 09:45:47 UTC
+Valid-Until: Sat, 17 Feb 2024 09:45:47 UTC
+MD5Sum:
+ 20df5d4878775a9be46acf6b21710a16 31 main/binary-amd64/Packages
+SHA256:
+ ecb7b7ae8fbe85e7dbc779313b1b50a0f108055c1872d87c51ea3f6d00148cc8 31 main/binary-amd64/Packages
+`))
+	isok(t, err)
+
+	packages := "Package: hello\nVersion: 2.10-3\n"
+	isok(t, release.Validate("main/binary-amd64/Packages", strings.NewReader(packages)))
+	notok(t, release.Validate("main/binary-amd64/Packages", strings.NewReader(strings.Replace(packages, "3", "4", 1))))
+	notok(t, release.Validate("main/binary-amd64/Packages", strings.NewReader(packages+"\n")))
+	notok(t, release.Validate("main/binary-i386/Packages", strings.NewReader(packages)))
+
+	assert(t, !release.Expired(time.Date(2024, 2, 17, 9, 0, 0, 0, time.UTC)))
+	assert(t, release.Expired(time.Date(2024, 2, 17, 10, 0, 0, 0, time.UTC)))
+	release.ValidUntil = "next week"
+	assert(t, release.Expired(time.Date(2024, 2, 11, 0, 0, 0, 0, time.UTC)))
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,323 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package archive // import "github.com/ebikt/go-debian/archive"
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ebikt/go-debian/control"
+	"github.com/ebikt/go-debian/deb"
+)
+
+// Repository {{{
+
+// Repository is a published Debian repository rooted at a directory on
+// disk, such as the document root of a web server.
+type Repository struct {
+	Root string
+}
+
+// NewRepository returns a Repository rooted at the given directory, which
+// must exist.
+func NewRepository(root string) (*Repository, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := os.Stat(root); err != nil {
+		return nil, err
+	} else if !info.IsDir() {
+		return nil, fmt.Errorf("Repository root '%s' is not a directory", root)
+	}
+	return &Repository{Root: root}, nil
+}
+
+// Return the path of the given slash separated path relative to the
+// repository root.
+func (r *Repository) path(rel string) string {
+	return filepath.Join(r.Root, filepath.FromSlash(rel))
+}
+
+// Suites returns the names of the suites currently published under dists/.
+func (r *Repository) Suites() ([]string, error) {
+	return listDirs(r.path("dists"))
+}
+
+// PoolFiles returns the pool files referenced by the indices of the given
+// suite, as slash separated paths relative to the repository root.
+func (r *Repository) PoolFiles(suite string) ([]string, error) {
+	files, err := referencedFiles(r.path("dists/" + suite))
+	if err != nil {
+		return nil, err
+	}
+	return sortedKeys(files), nil
+}
+
+func sortedKeys(set map[string]bool) []string {
+	ret := []string{}
+	for key := range set {
+		ret = append(ret, key)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// }}}
+
+// Index walking {{{
+
+var indexNames = []string{"Packages", "Sources"}
+
+// referencedFiles walks a dists/<suite> directory, and returns the set of
+// files (relative to the repository root) that are referenced by the
+// Packages and Sources indices found in it.
+func referencedFiles(suiteDir string) (map[string]bool, error) {
+	suiteDir, err := filepath.EvalSymlinks(suiteDir)
+	if err != nil {
+		return nil, err
+	}
+
+	/* For each directory, pick one flavor of each index. They all have the
+	 * same contents, so there's no need to decompress every one of them. */
+	indices := map[string]string{}
+	err = filepath.Walk(suiteDir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if info.Name() == "by-hash" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		for _, name := range indexNames {
+			ext := strings.TrimPrefix(info.Name(), name)
+			if ext == info.Name() || (ext != "" && !isCompressionExt(ext)) {
+				continue
+			}
+			key := filepath.Join(filepath.Dir(p), name)
+			if _, ok := indices[key]; !ok || ext == "" {
+				indices[key] = p
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ret := map[string]bool{}
+	for _, index := range indices {
+		if err := readIndexFiles(index, ret); err != nil {
+			return nil, err
+		}
+	}
+	return ret, nil
+}
+
+func isCompressionExt(ext string) bool {
+	switch ext {
+	case ".gz", ".xz", ".bz2", ".lzma", ".zst":
+		return true
+	}
+	return false
+}
+
+// readIndexFiles adds the files referenced by the Packages or Sources
+// index at the given path to `into`.
+func readIndexFiles(index string, into map[string]bool) error {
+	f, err := os.Open(index)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	reader, err := deb.DecompressorFor(filepath.Ext(index))(f)
+	if err != nil {
+		return err
+	}
+	return indexFiles(reader, into)
+}
+
+// indexFiles adds the files referenced by the Packages or Sources index
+// read from `reader` to `into`.
+func indexFiles(reader io.Reader, into map[string]bool) error {
+	paragraphs, err := control.NewParagraphReader(reader, nil)
+	if err != nil {
+		return err
+	}
+	for {
+		para, err := paragraphs.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		/* Binary indices carry a Filename; source indices carry a
+		 * Directory, and the file names in the Files list. */
+		if filename := para.Get("Filename"); filename != "" {
+			into[path.Clean(filename)] = true
+			continue
+		}
+		directory := para.Get("Directory")
+		if directory == "" {
+			continue
+		}
+		for _, line := range strings.Split(para.Get("Files"), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) != 3 {
+				continue
+			}
+			into[path.Join(directory, fields[2])] = true
+		}
+	}
+}
+
+// }}}
+
+// Filesystem helpers {{{
+
+func listDirs(dir string) ([]string, error) {
+	f, err := os.Open(dir)
+	if os.IsNotExist(err) {
+		return []string{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	names, err := f.Readdirnames(-1)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := []string{}
+	for _, name := range names {
+		if strings.HasPrefix(name, ".") {
+			continue
+		}
+		/* Stat, not Lstat; published suites may well be symlinks */
+		if info, err := os.Stat(filepath.Join(dir, name)); err == nil && info.IsDir() {
+			ret = append(ret, name)
+		}
+	}
+	sort.Strings(ret)
+	return ret, nil
+}
+
+// copyTree copies the directory tree `src` to `dest`, following symlinks.
+func copyTree(src, dest string) error {
+	src, err := filepath.EvalSymlinks(src)
+	if err != nil {
+		return err
+	}
+	return filepath.Walk(src, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dest, rel)
+
+		if info.Mode()&os.ModeSymlink != 0 {
+			/* Walk doesn't follow links below the root; do it ourselves. */
+			if resolved, err := os.Stat(p); err == nil && resolved.IsDir() {
+				return copyTree(p, target)
+			}
+		}
+		if info.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		return copyFile(p, target)
+	})
+}
+
+func copyFile(src, dest string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// linkFile hardlinks `src` to `dest`, creating the parent directories of
+// `dest` as needed.
+func linkFile(src, dest string) error {
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	return os.Link(src, dest)
+}
+
+// removeEmptyDirs removes empty directories below (but not including) dir.
+func removeEmptyDirs(dir string) error {
+	entries, err := readDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		sub := filepath.Join(dir, entry.Name())
+		if err := removeEmptyDirs(sub); err != nil {
+			return err
+		}
+		if left, err := readDir(sub); err == nil && len(left) == 0 {
+			if err := os.Remove(sub); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func readDir(dir string) ([]os.FileInfo, error) {
+	f, err := os.Open(dir)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return f.Readdir(-1)
+}
+
+// }}}
+
+// vim: foldmethod=marker
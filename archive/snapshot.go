@@ -0,0 +1,360 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package archive // import "github.com/ebikt/go-debian/archive"
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Snapshots {{{
+//
+// Snapshots retain earlier generations of a Repository. Each snapshot
+// lives in snapshots/<name>/ and holds a full copy of the dists/ tree at
+// the time it was taken, plus a pool/ tree of hardlinks to every file
+// those indices reference, so the files stay around after they are
+// garbage collected from the live pool.
+//
+// A published suite can then be pointed at a snapshot by Switch, which
+// turns dists/<suite> into a symlink to snapshots/<name>/dists/<suite>:
+//
+//	repo, err := archive.NewRepository("/srv/apt")
+//	...
+//	if _, err := repo.Snapshot("2024-01-01"); err != nil {
+//		return err
+//	}
+//	/* ... publish something broken, and snapshot it too ... */
+//	if _, err := repo.Snapshot("2024-01-02"); err != nil {
+//		return err
+//	}
+//	if err := repo.Switch("stable", "2024-01-01"); err != nil {
+//		return err
+//	}
+
+// Snapshot is a named, frozen generation of the dists/ tree of a
+// Repository.
+type Snapshot struct {
+	Name string
+	Path string
+	Time time.Time
+}
+
+// Suites returns the names of the suites that were published when the
+// snapshot was taken.
+func (s *Snapshot) Suites() ([]string, error) {
+	return listDirs(filepath.Join(s.Path, "dists"))
+}
+
+func checkSnapshotName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.HasPrefix(name, ".") ||
+		strings.ContainsAny(name, "/\\") {
+		return fmt.Errorf("Invalid snapshot name: '%s'", name)
+	}
+	return nil
+}
+
+// Snapshot freezes the currently published dists/ tree under the given
+// name, hardlinking every referenced pool file into the snapshot.
+func (r *Repository) Snapshot(name string) (*Snapshot, error) {
+	if err := checkSnapshotName(name); err != nil {
+		return nil, err
+	}
+
+	dest := r.path("snapshots/" + name)
+	if _, err := os.Lstat(dest); err == nil {
+		return nil, fmt.Errorf("Snapshot '%s' already exists", name)
+	}
+
+	/* Build the snapshot off to the side, and move it into place once it's
+	 * complete, so a crash never leaves a half-baked snapshot behind. */
+	tmp := r.path("snapshots/." + name + ".tmp")
+	if err := os.RemoveAll(tmp); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Join(tmp, "dists"), 0755); err != nil {
+		return nil, err
+	}
+
+	suites, err := r.Suites()
+	if err != nil {
+		return nil, err
+	}
+	for _, suite := range suites {
+		if err := r.snapshotSuite(suite, tmp); err != nil {
+			os.RemoveAll(tmp)
+			return nil, err
+		}
+	}
+
+	if err := os.Rename(tmp, dest); err != nil {
+		os.RemoveAll(tmp)
+		return nil, err
+	}
+	return r.GetSnapshot(name)
+}
+
+func (r *Repository) snapshotSuite(suite, dest string) error {
+	distsDest := filepath.Join(dest, "dists", suite)
+	if err := copyTree(r.path("dists/"+suite), distsDest); err != nil {
+		return err
+	}
+	files, err := referencedFiles(distsDest)
+	if err != nil {
+		return err
+	}
+	for file := range files {
+		target := filepath.Join(dest, filepath.FromSlash(file))
+		if _, err := os.Lstat(target); err == nil {
+			/* Shared with a suite we've already done */
+			continue
+		}
+		if err := linkFile(r.path(file), target); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetSnapshot returns the snapshot with the given name.
+func (r *Repository) GetSnapshot(name string) (*Snapshot, error) {
+	if err := checkSnapshotName(name); err != nil {
+		return nil, err
+	}
+	p := r.path("snapshots/" + name)
+	info, err := os.Stat(p)
+	if err != nil {
+		return nil, err
+	}
+	return &Snapshot{Name: name, Path: p, Time: info.ModTime()}, nil
+}
+
+// Snapshots returns all the snapshots of this Repository, ordered by name.
+func (r *Repository) Snapshots() ([]Snapshot, error) {
+	names, err := listDirs(r.path("snapshots"))
+	if err != nil {
+		return nil, err
+	}
+	ret := []Snapshot{}
+	for _, name := range names {
+		snapshot, err := r.GetSnapshot(name)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, *snapshot)
+	}
+	return ret, nil
+}
+
+// Switch atomically points the published suite at its copy in the given
+// snapshot, by replacing the dists/<suite> symlink. Pool files that were
+// garbage collected since the snapshot was taken are linked back into the
+// pool first, so the suite is consistent as soon as it's switched.
+//
+// If dists/<suite> is a plain directory (as written by a publisher), it
+// is replaced by the symlink, provided a snapshot holds a copy of it: the
+// switch is refused otherwise, rather than losing the published suite.
+// That first switch is not atomic; every one after it is.
+func (r *Repository) Switch(suite, name string) error {
+	snapshot, err := r.GetSnapshot(name)
+	if err != nil {
+		return err
+	}
+	target := filepath.Join(snapshot.Path, "dists", suite)
+	if info, err := os.Stat(target); err != nil || !info.IsDir() {
+		return fmt.Errorf("Snapshot '%s' has no suite '%s'", name, suite)
+	}
+	link := r.path("dists/" + suite)
+	if info, err := os.Lstat(link); err == nil && info.IsDir() {
+		captured, err := r.captured(suite)
+		if err != nil {
+			return err
+		}
+		if !captured {
+			return fmt.Errorf("Suite '%s' isn't in any snapshot, take one before switching it", suite)
+		}
+	}
+
+	files, err := referencedFiles(target)
+	if err != nil {
+		return err
+	}
+	for file := range files {
+		if _, err := os.Lstat(r.path(file)); err == nil {
+			continue
+		}
+		if err := linkFile(filepath.Join(snapshot.Path, filepath.FromSlash(file)), r.path(file)); err != nil {
+			return err
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(link), 0755); err != nil {
+		return err
+	}
+	rel, err := filepath.Rel(filepath.Dir(link), target)
+	if err != nil {
+		return err
+	}
+
+	tmp := filepath.Join(filepath.Dir(link), "."+filepath.Base(link)+".tmp")
+	os.Remove(tmp)
+	if err := os.Symlink(rel, tmp); err != nil {
+		return err
+	}
+
+	if info, err := os.Lstat(link); err == nil && info.IsDir() {
+		old := filepath.Join(filepath.Dir(link), "."+filepath.Base(link)+".old")
+		if err := os.RemoveAll(old); err != nil {
+			return err
+		}
+		if err := os.Rename(link, old); err != nil {
+			return err
+		}
+		defer os.RemoveAll(old)
+	}
+	return os.Rename(tmp, link)
+}
+
+// captured tells whether a snapshot holds a copy of the published suite,
+// as it is now.
+func (r *Repository) captured(suite string) (bool, error) {
+	snapshots, err := r.Snapshots()
+	if err != nil {
+		return false, err
+	}
+	for _, snapshot := range snapshots {
+		same, err := sameTree(r.path("dists/"+suite), filepath.Join(snapshot.Path, "dists", suite))
+		if err != nil || same {
+			return same, err
+		}
+	}
+	return false, nil
+}
+
+// treeFiles returns the sizes of the files below dir, by their path
+// relative to it.
+func treeFiles(dir string) (map[string]int64, error) {
+	ret := map[string]int64{}
+	err := filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		ret[rel] = info.Size()
+		return nil
+	})
+	return ret, err
+}
+
+// sameTree tells whether the directories have the same files, with the
+// same contents; false if b doesn't exist.
+func sameTree(a, b string) (bool, error) {
+	if _, err := os.Stat(b); os.IsNotExist(err) {
+		return false, nil
+	}
+	aFiles, err := treeFiles(a)
+	if err != nil {
+		return false, err
+	}
+	bFiles, err := treeFiles(b)
+	if err != nil {
+		return false, err
+	}
+	if len(aFiles) != len(bFiles) {
+		return false, nil
+	}
+	for name, size := range aFiles {
+		if bSize, ok := bFiles[name]; !ok || bSize != size {
+			return false, nil
+		}
+	}
+	for name := range aFiles {
+		aData, err := ioutil.ReadFile(filepath.Join(a, name))
+		if err != nil {
+			return false, err
+		}
+		bData, err := ioutil.ReadFile(filepath.Join(b, name))
+		if err != nil {
+			return false, err
+		}
+		if !bytes.Equal(aData, bData) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// Current returns the name of the snapshot the given suite is switched
+// to, or "" if it isn't a symlink into a snapshot.
+func (r *Repository) Current(suite string) (string, error) {
+	dest, err := os.Readlink(r.path("dists/" + suite))
+	if err != nil {
+		if info, serr := os.Lstat(r.path("dists/" + suite)); serr == nil && info.IsDir() {
+			return "", nil
+		}
+		return "", err
+	}
+	if !filepath.IsAbs(dest) {
+		dest = filepath.Join(filepath.Dir(r.path("dists/"+suite)), dest)
+	}
+	rel, err := filepath.Rel(r.path("snapshots"), dest)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return "", nil
+	}
+	return strings.SplitN(filepath.ToSlash(rel), "/", 2)[0], nil
+}
+
+// DeleteSnapshot removes the snapshot with the given name. A snapshot can't
+// be removed while a suite is switched to it.
+func (r *Repository) DeleteSnapshot(name string) error {
+	snapshot, err := r.GetSnapshot(name)
+	if err != nil {
+		return err
+	}
+	suites, err := r.Suites()
+	if err != nil {
+		return err
+	}
+	for _, suite := range suites {
+		current, err := r.Current(suite)
+		if err != nil {
+			return err
+		}
+		if current == name {
+			return fmt.Errorf("Snapshot '%s' is in use by suite '%s'", name, suite)
+		}
+	}
+	return os.RemoveAll(snapshot.Path)
+}
+
+// }}}
+
+// vim: foldmethod=marker
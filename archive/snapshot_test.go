@@ -0,0 +1,213 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package archive_test
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"testing"
+
+	"github.com/ebikt/go-debian/archive"
+)
+
+/*
+ *
+ */
+
+func isok(t *testing.T, err error) {
+	if err != nil {
+		log.Printf("Error! Error is not nil! %s\n", err)
+		debug.PrintStack()
+		t.FailNow()
+	}
+}
+
+func notok(t *testing.T, err error) {
+	if err == nil {
+		log.Printf("Error! Error is nil!\n")
+		debug.PrintStack()
+		t.FailNow()
+	}
+}
+
+func assert(t *testing.T, expr bool) {
+	if !expr {
+		log.Printf("Assertion failed!")
+		debug.PrintStack()
+		t.FailNow()
+	}
+}
+
+func writeFile(t *testing.T, root, name, data string) {
+	p := filepath.Join(root, filepath.FromSlash(name))
+	isok(t, os.MkdirAll(filepath.Dir(p), 0755))
+	if filepath.Ext(p) == ".gz" {
+		f, err := os.Create(p)
+		isok(t, err)
+		w := gzip.NewWriter(f)
+		_, err = w.Write([]byte(data))
+		isok(t, err)
+		isok(t, w.Close())
+		isok(t, f.Close())
+		return
+	}
+	isok(t, ioutil.WriteFile(p, []byte(data), 0644))
+}
+
+func exists(root, name string) bool {
+	_, err := os.Stat(filepath.Join(root, filepath.FromSlash(name)))
+	return err == nil
+}
+
+/*
+ *
+ */
+
+const fooPackages = `Package: foo
+Version: 1.0-1
+Architecture: amd64
+Filename: pool/main/f/foo/foo_1.0-1_amd64.deb
+
+`
+
+const fooSources = `Package: foo
+Version: 1.0-1
+Directory: pool/main/f/foo
+Files:
+ d41d8cd98f00b204e9800998ecf8427e 0 foo_1.0-1.dsc
+ d41d8cd98f00b204e9800998ecf8427e 0 foo_1.0.orig.tar.gz
+`
+
+const fooNewPackages = `Package: foo
+Version: 2.0-1
+Architecture: amd64
+Filename: pool/main/f/foo/foo_2.0-1_amd64.deb
+`
+
+func testRepository(t *testing.T) *archive.Repository {
+	root, err := ioutil.TempDir("", "go-debian-archive")
+	isok(t, err)
+
+	for _, name := range []string{
+		"pool/main/f/foo/foo_1.0-1_amd64.deb",
+		"pool/main/f/foo/foo_1.0-1.dsc",
+		"pool/main/f/foo/foo_1.0.orig.tar.gz",
+		"pool/main/f/foo/foo_2.0-1_amd64.deb",
+	} {
+		writeFile(t, root, name, name)
+	}
+	writeFile(t, root, "dists/stable/main/binary-amd64/Packages", fooPackages)
+	writeFile(t, root, "dists/stable/main/binary-amd64/Packages.gz", fooPackages)
+	writeFile(t, root, "dists/stable/main/source/Sources.gz", fooSources)
+
+	repo, err := archive.NewRepository(root)
+	isok(t, err)
+	return repo
+}
+
+func TestPoolFiles(t *testing.T) {
+	repo := testRepository(t)
+	defer os.RemoveAll(repo.Root)
+
+	files, err := repo.PoolFiles("stable")
+	isok(t, err)
+	assert(t, len(files) == 3)
+	assert(t, files[0] == "pool/main/f/foo/foo_1.0-1.dsc")
+	assert(t, files[1] == "pool/main/f/foo/foo_1.0-1_amd64.deb")
+	assert(t, files[2] == "pool/main/f/foo/foo_1.0.orig.tar.gz")
+}
+
+func TestSnapshotRollback(t *testing.T) {
+	repo := testRepository(t)
+	defer os.RemoveAll(repo.Root)
+
+	snapshot, err := repo.Snapshot("one")
+	isok(t, err)
+	assert(t, snapshot.Name == "one")
+	assert(t, exists(snapshot.Path, "dists/stable/main/binary-amd64/Packages"))
+	assert(t, exists(snapshot.Path, "pool/main/f/foo/foo_1.0-1_amd64.deb"))
+	assert(t, !exists(snapshot.Path, "pool/main/f/foo/foo_2.0-1_amd64.deb"))
+
+	_, err = repo.Snapshot("one")
+	notok(t, err)
+	_, err = repo.Snapshot("../one")
+	notok(t, err)
+
+	/* Publish 2.0, and collect the old files */
+	isok(t, os.RemoveAll(filepath.Join(repo.Root, "dists")))
+	writeFile(t, repo.Root, "dists/stable/main/binary-amd64/Packages", fooNewPackages)
+
+	removed, err := repo.GarbageCollect()
+	isok(t, err)
+	assert(t, len(removed) == 3)
+	assert(t, !exists(repo.Root, "pool/main/f/foo/foo_1.0-1_amd64.deb"))
+	assert(t, exists(repo.Root, "pool/main/f/foo/foo_2.0-1_amd64.deb"))
+
+	/* 2.0 isn't in any snapshot, so it can't be switched away from */
+	notok(t, repo.Switch("stable", "one"))
+	assert(t, exists(repo.Root, "dists/stable/main/binary-amd64/Packages"))
+	current, err := repo.Current("stable")
+	isok(t, err)
+	assert(t, current == "")
+
+	_, err = repo.Snapshot("two")
+	isok(t, err)
+
+	snapshots, err := repo.Snapshots()
+	isok(t, err)
+	assert(t, len(snapshots) == 2)
+
+	/* Roll back to the first snapshot */
+	isok(t, repo.Switch("stable", "one"))
+	current, err = repo.Current("stable")
+	isok(t, err)
+	assert(t, current == "one")
+	assert(t, exists(repo.Root, "pool/main/f/foo/foo_1.0-1_amd64.deb"))
+
+	data, err := ioutil.ReadFile(filepath.Join(repo.Root, "dists/stable/main/binary-amd64/Packages"))
+	isok(t, err)
+	assert(t, string(data) == fooPackages)
+
+	notok(t, repo.DeleteSnapshot("one"))
+	notok(t, repo.Switch("unstable", "one"))
+
+	/* And forward again */
+	isok(t, repo.Switch("stable", "two"))
+	current, err = repo.Current("stable")
+	isok(t, err)
+	assert(t, current == "two")
+
+	removed, err = repo.GarbageCollect()
+	isok(t, err)
+	assert(t, len(removed) == 3)
+
+	isok(t, repo.DeleteSnapshot("one"))
+	snapshots, err = repo.Snapshots()
+	isok(t, err)
+	assert(t, len(snapshots) == 1)
+	assert(t, snapshots[0].Name == "two")
+}
+
+// vim: foldmethod=marker
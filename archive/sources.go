@@ -0,0 +1,524 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package archive // import "github.com/ebikt/go-debian/archive"
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ebikt/go-debian/control"
+)
+
+// Sources {{{
+
+// Source is an entry of apt's sources: either a line of a sources.list
+// file, or a paragraph of a deb822 style .sources file, which may have
+// several types, URIs and suites at once.
+type Source struct {
+	// "deb" and / or "deb-src".
+	Types []string
+
+	URIs []string
+
+	// Suites, or exact paths ending with "/" for flat repositories, which
+	// have no Components.
+	Suites     []string
+	Components []string
+
+	Architectures []string
+
+	// File names of the keyrings the Release files have to be signed
+	// with, or an inline ASCII armored keyring; or fingerprints.
+	SignedBy string
+
+	// False for a paragraph with "Enabled: no".
+	Enabled bool
+
+	// The other options, such as "Trusted" or "Languages", by their name
+	// in .sources files, see SourceOptionNames. Lists are space separated.
+	Options map[string]string
+}
+
+// SourceOptionNames maps the option names of sources.list lines to the
+// field names of .sources paragraphs. Options modified by "+=" and "-="
+// get the "-Add" and "-Remove" suffixes.
+var SourceOptionNames = map[string]string{
+	"arch":                        "Architectures",
+	"lang":                        "Languages",
+	"target":                      "Targets",
+	"pdiffs":                      "PDiffs",
+	"by-hash":                     "By-Hash",
+	"allow-insecure":              "Allow-Insecure",
+	"allow-weak":                  "Allow-Weak",
+	"allow-downgrade-to-insecure": "Allow-Downgrade-To-Insecure",
+	"trusted":                     "Trusted",
+	"signed-by":                   "Signed-By",
+	"check-valid-until":           "Check-Valid-Until",
+	"valid-until-min":             "Valid-Until-Min",
+	"valid-until-max":             "Valid-Until-Max",
+	"check-date":                  "Check-Date",
+	"date-max-future":             "Date-Max-Future",
+	"inrelease-path":              "InRelease-Path",
+	"snapshot":                    "Snapshot",
+}
+
+// Options whose values are lists, comma separated in sources.list lines.
+var sourceListOptions = map[string]bool{
+	"Architectures": true,
+	"Languages":     true,
+	"Targets":       true,
+}
+
+func isListOption(name string) bool {
+	name = strings.TrimSuffix(strings.TrimSuffix(name, "-Add"), "-Remove")
+	return sourceListOptions[name]
+}
+
+// oneLineOption returns the sources.list name of an option.
+func oneLineOption(name string) string {
+	suffix := ""
+	for _, modifier := range []struct{ field, op string }{{"-Add", "+"}, {"-Remove", "-"}} {
+		if strings.HasSuffix(name, modifier.field) {
+			name, suffix = strings.TrimSuffix(name, modifier.field), modifier.op
+		}
+	}
+	for key, field := range SourceOptionNames {
+		if field == name {
+			return key + suffix
+		}
+	}
+	return strings.ToLower(name) + suffix
+}
+
+// Entries returns a SourceEntry for each URI and suite of the "deb" type
+// of the Source, which a Client can read.
+func (s Source) Entries() []SourceEntry {
+	ret := []SourceEntry{}
+	if !s.Enabled || !s.HasType("deb") {
+		return ret
+	}
+	for _, uri := range s.URIs {
+		for _, suite := range s.Suites {
+			ret = append(ret, SourceEntry{
+				URI:           uri,
+				Suite:         suite,
+				Components:    s.Components,
+				Architectures: s.Architectures,
+			})
+		}
+	}
+	return ret
+}
+
+// HasType tells whether the Source is of the given type, such as "deb-src".
+func (s Source) HasType(kind string) bool {
+	for _, t := range s.Types {
+		if t == kind {
+			return true
+		}
+	}
+	return false
+}
+
+func (s Source) validate() error {
+	if len(s.Types) == 0 {
+		return fmt.Errorf("Source has no type")
+	}
+	for _, t := range s.Types {
+		if t != "deb" && t != "deb-src" {
+			return fmt.Errorf("Unknown source type: '%s'", t)
+		}
+	}
+	if len(s.URIs) == 0 {
+		return fmt.Errorf("Source has no URI")
+	}
+	if len(s.Suites) == 0 {
+		return fmt.Errorf("Source has no suite")
+	}
+	for _, suite := range s.Suites {
+		exact := strings.HasSuffix(suite, "/")
+		if exact && len(s.Components) > 0 {
+			return fmt.Errorf("Suite '%s' is an exact path, and can't have components", suite)
+		}
+		if !exact && len(s.Components) == 0 {
+			return fmt.Errorf("Suite '%s' has no components", suite)
+		}
+	}
+	return nil
+}
+
+// }}}
+
+// sources.list {{{
+
+// splitSourceLine splits a sources.list line into its words, and the
+// options between brackets following the type.
+func splitSourceLine(line string) ([]string, string, error) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return fields, "", nil
+	}
+	rest := strings.TrimSpace(strings.TrimSpace(line)[len(fields[0]):])
+	if !strings.HasPrefix(rest, "[") {
+		return fields, "", nil
+	}
+	end := strings.IndexByte(rest, ']')
+	if end < 0 {
+		return nil, "", fmt.Errorf("Unterminated options")
+	}
+	return append(fields[:1], strings.Fields(rest[end+1:])...), rest[1:end], nil
+}
+
+func parseSourceOptions(in string, source *Source) error {
+	for _, option := range strings.Fields(in) {
+		kv := strings.SplitN(option, "=", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("Malformed option: '%s'", option)
+		}
+		key, value, suffix := kv[0], kv[1], ""
+		switch {
+		case strings.HasSuffix(key, "+"):
+			key, suffix = strings.TrimSuffix(key, "+"), "-Add"
+		case strings.HasSuffix(key, "-"):
+			key, suffix = strings.TrimSuffix(key, "-"), "-Remove"
+		}
+		name, ok := SourceOptionNames[strings.ToLower(key)]
+		if !ok {
+			return fmt.Errorf("Unknown option: '%s'", key)
+		}
+		name += suffix
+		switch {
+		case name == "Architectures":
+			source.Architectures = strings.Split(value, ",")
+		case name == "Signed-By":
+			source.SignedBy = strings.Replace(value, ",", " ", -1)
+		case isListOption(name):
+			source.Options[name] = strings.Replace(value, ",", " ", -1)
+		default:
+			source.Options[name] = value
+		}
+	}
+	return nil
+}
+
+// ParseSourceLine parses a single sources.list line, such as
+// "deb [arch=amd64 signed-by=/usr/share/keyrings/foo.gpg] https://deb.example.org/ stable main".
+func ParseSourceLine(line string) (*Source, error) {
+	source := Source{Enabled: true, Options: map[string]string{}}
+	fields, options, err := splitSourceLine(line)
+	if err != nil {
+		return nil, err
+	}
+	if err := parseSourceOptions(options, &source); err != nil {
+		return nil, err
+	}
+	if len(fields) < 3 {
+		return nil, fmt.Errorf("Malformed source line: '%s'", line)
+	}
+	source.Types = []string{fields[0]}
+	source.URIs = []string{fields[1]}
+	source.Suites = []string{fields[2]}
+	source.Components = fields[3:]
+	if err := source.validate(); err != nil {
+		return nil, err
+	}
+	return &source, nil
+}
+
+// ParseSourcesList reads a sources.list file. Comments, including
+// commented out entries, are skipped.
+func ParseSourcesList(reader io.Reader) ([]Source, error) {
+	ret := []Source{}
+	scanner := bufio.NewScanner(reader)
+	lineno := 0
+	for scanner.Scan() {
+		lineno++
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		source, err := ParseSourceLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", lineno, err)
+		}
+		ret = append(ret, *source)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// Lines renders the Source as sources.list lines, one for each of its
+// types, URIs and suites. A disabled Source is commented out. An inline
+// keyring can't be written on a line.
+func (s Source) Lines() ([]string, error) {
+	if err := s.validate(); err != nil {
+		return nil, err
+	}
+	options := []string{}
+	if len(s.Architectures) > 0 {
+		options = append(options, "arch="+strings.Join(s.Architectures, ","))
+	}
+	if s.SignedBy != "" {
+		if strings.Contains(strings.TrimSpace(s.SignedBy), "\n") {
+			return nil, fmt.Errorf("An inline Signed-By keyring can't be written on a line")
+		}
+		options = append(options, "signed-by="+strings.Join(strings.Fields(s.SignedBy), ","))
+	}
+	names := []string{}
+	for name := range s.Options {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := s.Options[name]
+		if isListOption(name) {
+			value = strings.Join(strings.Fields(value), ",")
+		}
+		options = append(options, oneLineOption(name)+"="+value)
+	}
+
+	ret := []string{}
+	for _, kind := range s.Types {
+		for _, uri := range s.URIs {
+			for _, suite := range s.Suites {
+				words := []string{kind}
+				if len(options) > 0 {
+					words = append(words, "["+strings.Join(options, " ")+"]")
+				}
+				words = append(words, uri, suite)
+				line := strings.Join(append(words, s.Components...), " ")
+				if !s.Enabled {
+					line = "# " + line
+				}
+				ret = append(ret, line)
+			}
+		}
+	}
+	return ret, nil
+}
+
+// WriteSourcesList writes the Sources as a sources.list file.
+func WriteSourcesList(out io.Writer, sources []Source) error {
+	for _, source := range sources {
+		lines, err := source.Lines()
+		if err != nil {
+			return err
+		}
+		for _, line := range lines {
+			if _, err := fmt.Fprintln(out, line); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// }}}
+
+// .sources {{{
+
+// Fields of a .sources paragraph which are not Options.
+var sourceFields = map[string]bool{
+	"types":         true,
+	"uris":          true,
+	"suites":        true,
+	"components":    true,
+	"architectures": true,
+	"signed-by":     true,
+	"enabled":       true,
+}
+
+// ParseSourceParagraph reads a paragraph of a deb822 style .sources file.
+func ParseSourceParagraph(para control.Paragraph) (*Source, error) {
+	source := Source{
+		Types:         strings.Fields(para.Get("Types")),
+		URIs:          strings.Fields(para.Get("URIs")),
+		Suites:        strings.Fields(para.Get("Suites")),
+		Components:    strings.Fields(para.Get("Components")),
+		Architectures: strings.Fields(para.Get("Architectures")),
+		SignedBy:      strings.TrimSpace(para.Get("Signed-By")),
+		Enabled:       true,
+		Options:       map[string]string{},
+	}
+	if strings.Contains(para.Get("Signed-By"), "\n") {
+		/* An inline keyring keeps its lines */
+		source.SignedBy = strings.TrimLeft(para.Get("Signed-By"), "\n")
+	}
+	switch enabled := strings.ToLower(strings.TrimSpace(para.Get("Enabled"))); enabled {
+	case "", "yes":
+	case "no":
+		source.Enabled = false
+	default:
+		return nil, fmt.Errorf("Invalid Enabled value: '%s'", enabled)
+	}
+	for _, key := range para.Order {
+		if !sourceFields[strings.ToLower(key)] {
+			source.Options[key] = strings.TrimSpace(para.Get(key))
+		}
+	}
+	if err := source.validate(); err != nil {
+		return nil, err
+	}
+	return &source, nil
+}
+
+// ParseSources reads a deb822 style .sources file.
+func ParseSources(reader io.Reader) ([]Source, error) {
+	paragraphs, err := control.NewParagraphReader(reader, nil)
+	if err != nil {
+		return nil, err
+	}
+	ret := []Source{}
+	for {
+		para, err := paragraphs.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		source, err := ParseSourceParagraph(*para)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, *source)
+	}
+	return ret, nil
+}
+
+// Paragraph renders the Source as a paragraph of a .sources file.
+func (s Source) Paragraph() (*control.Paragraph, error) {
+	if err := s.validate(); err != nil {
+		return nil, err
+	}
+	para := control.NewParagraph()
+	if !s.Enabled {
+		para.Set("Enabled", "no")
+	}
+	para.Set("Types", strings.Join(s.Types, " "))
+	para.Set("URIs", strings.Join(s.URIs, " "))
+	para.Set("Suites", strings.Join(s.Suites, " "))
+	if len(s.Components) > 0 {
+		para.Set("Components", strings.Join(s.Components, " "))
+	}
+	if len(s.Architectures) > 0 {
+		para.Set("Architectures", strings.Join(s.Architectures, " "))
+	}
+	if s.SignedBy != "" {
+		value := s.SignedBy
+		if strings.Contains(strings.TrimSpace(value), "\n") {
+			value = "\n" + strings.TrimRight(value, "\n") + "\n"
+		}
+		para.Set("Signed-By", value)
+	}
+	names := []string{}
+	for name := range s.Options {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		para.Set(name, s.Options[name])
+	}
+	return &para, nil
+}
+
+// WriteSources writes the Sources as a deb822 style .sources file.
+func WriteSources(out io.Writer, sources []Source) error {
+	for i, source := range sources {
+		para, err := source.Paragraph()
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			if _, err := out.Write([]byte("\n")); err != nil {
+				return err
+			}
+		}
+		if err := para.WriteTo(out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// }}}
+
+// Loading {{{
+
+// LoadSourcesFile reads a sources.list file, or a .sources one, as told
+// by its extension.
+func LoadSourcesFile(path string) ([]Source, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if filepath.Ext(path) == ".sources" {
+		return ParseSources(f)
+	}
+	return ParseSourcesList(f)
+}
+
+// LoadSystemSources reads the sources of the host's apt, found below the
+// given root directory ("/" for the running system): etc/apt/sources.list,
+// then the .list and .sources files of etc/apt/sources.list.d, in order.
+func LoadSystemSources(root string) ([]Source, error) {
+	ret, err := LoadSourcesFile(filepath.Join(root, "etc/apt/sources.list"))
+	if os.IsNotExist(err) {
+		ret = []Source{}
+	} else if err != nil {
+		return nil, err
+	}
+	dir := filepath.Join(root, "etc/apt/sources.list.d")
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	names := []string{}
+	for _, entry := range entries {
+		if ext := filepath.Ext(entry.Name()); !entry.IsDir() && (ext == ".list" || ext == ".sources") {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		sources, err := LoadSourcesFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", name, err)
+		}
+		ret = append(ret, sources...)
+	}
+	return ret, nil
+}
+
+// }}}
+
+// vim: foldmethod=marker
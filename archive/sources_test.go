@@ -0,0 +1,168 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package archive_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/ebikt/go-debian/archive"
+)
+
+/*
+ *
+ */
+
+const sourcesList = `# The main archive
+deb http://deb.debian.org/debian bookworm main contrib
+deb-src http://deb.debian.org/debian bookworm main
+# deb http://deb.debian.org/debian bookworm-backports main
+
+deb [arch=amd64,arm64 signed-by=/usr/share/keyrings/example.gpg lang+=de] https://deb.example.org/ stable/ # flat
+`
+
+const sourcesFile = `Types: deb deb-src
+URIs: http://deb.debian.org/debian
+Suites: bookworm bookworm-updates
+Components: main contrib
+Signed-By: /usr/share/keyrings/debian-archive-keyring.gpg
+
+Enabled: no
+Types: deb
+URIs: https://deb.example.org/
+Suites: ./
+Architectures: amd64
+Trusted: yes
+Signed-By:
+ -----BEGIN PGP PUBLIC KEY BLOCK-----
+ .
+ mQINBF...
+ -----END PGP PUBLIC KEY BLOCK-----
+`
+
+func TestParseSourcesList(t *testing.T) {
+	sources, err := archive.ParseSourcesList(strings.NewReader(sourcesList))
+	isok(t, err)
+	assert(t, len(sources) == 3)
+	assert(t, sources[0].Types[0] == "deb")
+	assert(t, sources[0].Suites[0] == "bookworm")
+	assert(t, len(sources[0].Components) == 2)
+	assert(t, sources[1].HasType("deb-src"))
+
+	flat := sources[2]
+	assert(t, flat.URIs[0] == "https://deb.example.org/")
+	assert(t, flat.Suites[0] == "stable/")
+	assert(t, len(flat.Components) == 0)
+	assert(t, len(flat.Architectures) == 2)
+	assert(t, flat.SignedBy == "/usr/share/keyrings/example.gpg")
+	assert(t, flat.Options["Languages-Add"] == "de")
+
+	lines, err := flat.Lines()
+	isok(t, err)
+	assert(t, lines[0] == "deb [arch=amd64,arm64 signed-by=/usr/share/keyrings/example.gpg lang+=de] https://deb.example.org/ stable/")
+
+	entries := sources[0].Entries()
+	assert(t, len(entries) == 1)
+	assert(t, entries[0].URI == "http://deb.debian.org/debian")
+	assert(t, len(sources[1].Entries()) == 0)
+
+	for _, line := range []string{
+		"deb http://deb.debian.org/debian\n",
+		"deb http://deb.debian.org/debian bookworm\n",
+		"deb http://deb.debian.org/debian ./ main\n",
+		"rpm http://example.org/ stable main\n",
+		"deb [arch=amd64 http://deb.debian.org/debian bookworm main\n",
+		"deb [frobnicate=yes] http://deb.debian.org/debian bookworm main\n",
+	} {
+		_, err := archive.ParseSourcesList(strings.NewReader(line))
+		notok(t, err)
+	}
+}
+
+func TestParseSources(t *testing.T) {
+	sources, err := archive.ParseSources(strings.NewReader(sourcesFile))
+	isok(t, err)
+	assert(t, len(sources) == 2)
+	assert(t, len(sources[0].Types) == 2)
+	assert(t, len(sources[0].Entries()) == 2)
+	assert(t, sources[0].Entries()[1].Suite == "bookworm-updates")
+	assert(t, sources[0].Enabled)
+
+	flat := sources[1]
+	assert(t, !flat.Enabled)
+	assert(t, len(flat.Entries()) == 0)
+	assert(t, flat.Options["Trusted"] == "yes")
+	assert(t, strings.HasPrefix(flat.SignedBy, "-----BEGIN PGP PUBLIC KEY BLOCK-----\n\nmQINBF"))
+
+	lines, err := sources[0].Lines()
+	isok(t, err)
+	assert(t, len(lines) == 4)
+	assert(t, lines[3] == "deb-src [signed-by=/usr/share/keyrings/debian-archive-keyring.gpg] http://deb.debian.org/debian bookworm-updates main contrib")
+	_, err = flat.Lines()
+	notok(t, err)
+
+	/* Round trip through the .sources format */
+	out := bytes.Buffer{}
+	isok(t, archive.WriteSources(&out, sources))
+	again, err := archive.ParseSources(&out)
+	isok(t, err)
+	assert(t, len(again) == 2)
+	assert(t, again[1].SignedBy == flat.SignedBy)
+	assert(t, again[1].Options["Trusted"] == "yes")
+	assert(t, !again[1].Enabled)
+
+	/* And from sources.list */
+	list, err := archive.ParseSourcesList(strings.NewReader(sourcesList))
+	isok(t, err)
+	out.Reset()
+	isok(t, archive.WriteSources(&out, list))
+	assert(t, strings.Contains(out.String(), "Languages-Add: de\n"))
+	again, err = archive.ParseSources(&out)
+	isok(t, err)
+	out.Reset()
+	isok(t, archive.WriteSourcesList(&out, again))
+	assert(t, strings.Contains(out.String(), "deb-src http://deb.debian.org/debian bookworm main\n"))
+	assert(t, strings.Contains(out.String(), "lang+=de"))
+
+	_, err = archive.ParseSources(strings.NewReader("Types: deb\nURIs: http://example.org/\nSuites: stable\n"))
+	notok(t, err)
+	_, err = archive.ParseSources(strings.NewReader("Types: deb\nURIs: http://example.org/\nSuites: ./\nEnabled: maybe\n"))
+	notok(t, err)
+}
+
+func TestLoadSystemSources(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, root, "etc/apt/sources.list", sourcesList)
+	writeFile(t, root, "etc/apt/sources.list.d/debian.sources", sourcesFile)
+	writeFile(t, root, "etc/apt/sources.list.d/old.list.save", "garbage")
+
+	sources, err := archive.LoadSystemSources(root)
+	isok(t, err)
+	assert(t, len(sources) == 5)
+	assert(t, !sources[4].Enabled)
+
+	sources, err = archive.LoadSystemSources(t.TempDir())
+	isok(t, err)
+	assert(t, len(sources) == 0)
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,263 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package archive // import "github.com/ebikt/go-debian/archive"
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"time"
+)
+
+// Transport {{{
+
+// StatusError is the error of an HTTP request answered with an unexpected
+// status, such as "404 Not Found".
+type StatusError struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Status     string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s %s: %s", e.Method, e.URL, e.Status)
+}
+
+// Temporary returns whether the request may succeed if retried: the
+// server failed, timed out, or asked to slow down.
+func (e *StatusError) Temporary() bool {
+	return e.StatusCode >= 500 || e.StatusCode == http.StatusRequestTimeout ||
+		e.StatusCode == http.StatusTooManyRequests
+}
+
+// RetryPolicy tells how failed requests are retried.
+type RetryPolicy struct {
+	// Number of retries after the first attempt; none if 0.
+	Retries int
+
+	// Delay before the first retry, doubled for each of the next ones, up
+	// to MaxBackoff; 1 second and 1 minute if 0.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+// delay returns how long to wait before the retry, counting from 1.
+func (p RetryPolicy) delay(retry int) time.Duration {
+	backoff, max := p.Backoff, p.MaxBackoff
+	if backoff <= 0 {
+		backoff = time.Second
+	}
+	if max <= 0 {
+		max = time.Minute
+	}
+	for i := 1; i < retry && backoff < max; i++ {
+		backoff *= 2
+	}
+	if backoff > max {
+		return max
+	}
+	return backoff
+}
+
+// wait sleeps before the retry, unless the context is done first.
+func (p RetryPolicy) wait(ctx context.Context, retry int) error {
+	timer := time.NewTimer(p.delay(retry))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// retryable returns whether a request which failed with the error may
+// succeed if retried: the network errors and the temporary StatusErrors.
+func retryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	status := &StatusError{}
+	if errors.As(err, &status) {
+		return status.Temporary()
+	}
+	return true
+}
+
+// TransportOptions configure the transport NewTransport returns.
+type TransportOptions struct {
+	// URL of the proxy to use, such as "http://proxy.example.org:3128";
+	// the ones of the http_proxy, https_proxy and no_proxy environment
+	// variables if empty, or none if "DIRECT", as apt understands it.
+	Proxy string
+
+	// Idle connections kept open for later requests to the same server;
+	// 4 if 0.
+	MaxIdleConnsPerHost int
+
+	// Connections to the same server open at once; no limit if 0.
+	MaxConnsPerHost int
+
+	// How long connecting, and waiting for the headers of a response, may
+	// take; 30 seconds if 0.
+	Timeout time.Duration
+}
+
+// NewTransport returns an HTTP transport for HTTPFetchers, keeping a pool
+// of connections to the servers.
+func NewTransport(opts TransportOptions) (*http.Transport, error) {
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	idle := opts.MaxIdleConnsPerHost
+	if idle <= 0 {
+		idle = 4
+	}
+
+	proxy := http.ProxyFromEnvironment
+	switch opts.Proxy {
+	case "":
+	case "DIRECT":
+		proxy = nil
+	default:
+		proxyURL, err := url.Parse(opts.Proxy)
+		if err != nil {
+			return nil, err
+		}
+		if proxyURL.Scheme == "" || proxyURL.Host == "" {
+			return nil, fmt.Errorf("Invalid proxy URL '%s'", opts.Proxy)
+		}
+		proxy = http.ProxyURL(proxyURL)
+	}
+
+	return &http.Transport{
+		Proxy:                 proxy,
+		DialContext:           (&net.Dialer{Timeout: timeout, KeepAlive: 30 * time.Second}).DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   idle,
+		MaxConnsPerHost:       opts.MaxConnsPerHost,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   timeout,
+		ResponseHeaderTimeout: timeout,
+		ExpectContinueTimeout: time.Second,
+	}, nil
+}
+
+// resumingBody is the body of a file an HTTPFetcher fetches, which
+// resumes the download where it stopped when the connection fails.
+type resumingBody struct {
+	ctx     context.Context
+	fetcher HTTPFetcher
+	url     string
+
+	body      io.ReadCloser
+	offset    int64
+	validator string
+	retries   int
+}
+
+// open issues the request for what follows the offset, retrying it as
+// the policy of the fetcher says.
+func (b *resumingBody) open() error {
+	policy := b.fetcher.Retry
+	for {
+		resp, err := b.fetcher.get(b.ctx, b.url, b.offset, b.validator)
+		if err == nil {
+			b.body = resp.Body
+			if b.offset == 0 {
+				b.validator = resp.Header.Get("ETag")
+				if b.validator == "" {
+					b.validator = resp.Header.Get("Last-Modified")
+				}
+			}
+			return nil
+		}
+		if b.retries >= policy.Retries || !retryable(err) {
+			return err
+		}
+		b.retries++
+		if err := policy.wait(b.ctx, b.retries); err != nil {
+			return err
+		}
+	}
+}
+
+func (b *resumingBody) Read(p []byte) (int, error) {
+	for {
+		n, err := b.body.Read(p)
+		b.offset += int64(n)
+		if err == nil || err == io.EOF {
+			return n, err
+		}
+		if n > 0 {
+			/* The failure is seen again by the next Read */
+			return n, nil
+		}
+		if b.retries >= b.fetcher.Retry.Retries || b.ctx.Err() != nil || !retryable(err) {
+			return n, err
+		}
+		b.body.Close()
+		b.retries++
+		if err := b.fetcher.Retry.wait(b.ctx, b.retries); err != nil {
+			return 0, err
+		}
+		if err := b.open(); err != nil {
+			b.body = ioutil.NopCloser(bytes.NewReader(nil))
+			return 0, err
+		}
+	}
+}
+
+func (b *resumingBody) Close() error {
+	return b.body.Close()
+}
+
+// MemoryFetcher fetches the files of a repository held in memory, by
+// their path, such as "dists/stable/InRelease"; handy for tests.
+type MemoryFetcher map[string][]byte
+
+// Fetch returns a reader of the file, or an error satisfying
+// errors.Is(err, fs.ErrNotExist).
+func (f MemoryFetcher) Fetch(ctx context.Context, name string) (io.ReadCloser, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	data, ok := f[path.Clean("/" + name)[1:]]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return ioutil.NopCloser(bytes.NewReader(data)), nil
+}
+
+// }}}
+
+// vim: foldmethod=marker
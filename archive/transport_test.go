@@ -0,0 +1,186 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package archive_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/fs"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ebikt/go-debian/archive"
+)
+
+/*
+ *
+ */
+
+func TestHTTPFetcherRetry(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&requests, 1)
+		switch {
+		case r.URL.Path == "/missing":
+			http.NotFound(w, r)
+		case n <= 2:
+			http.Error(w, "Busy", http.StatusServiceUnavailable)
+		default:
+			w.Write([]byte("Suite: stable\n"))
+		}
+	}))
+	defer server.Close()
+
+	fetcher := archive.HTTPFetcher{
+		BaseURL: server.URL,
+		Retry:   archive.RetryPolicy{Retries: 2, Backoff: time.Millisecond},
+	}
+	body, err := fetcher.Fetch(context.Background(), "dists/stable/Release")
+	isok(t, err)
+	data, err := ioutil.ReadAll(body)
+	body.Close()
+	isok(t, err)
+	assert(t, string(data) == "Suite: stable\n")
+	assert(t, atomic.LoadInt32(&requests) == 3)
+
+	/* Not found isn't retried */
+	atomic.StoreInt32(&requests, 0)
+	_, err = fetcher.Fetch(context.Background(), "missing")
+	status := &archive.StatusError{}
+	assert(t, errors.As(err, &status))
+	assert(t, status.StatusCode == http.StatusNotFound && !status.Temporary())
+	assert(t, err.Error() == "GET "+server.URL+"/missing: 404 Not Found")
+	assert(t, atomic.LoadInt32(&requests) == 1)
+
+	/* Without retries, the first failure is returned */
+	atomic.StoreInt32(&requests, 0)
+	_, err = archive.HTTPFetcher{BaseURL: server.URL}.Fetch(context.Background(), "dists/stable/Release")
+	assert(t, errors.As(err, &status) && status.Temporary())
+
+	/* Waiting stops along with the context */
+	atomic.StoreInt32(&requests, 0)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	fetcher.Retry.Backoff = time.Hour
+	_, err = fetcher.Fetch(ctx, "dists/stable/Release")
+	assert(t, errors.Is(err, context.DeadlineExceeded))
+}
+
+func TestHTTPFetcherResume(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789abcdef"), 64*1024)
+	ranges := []string{}
+	var failed int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		w.Header().Set("ETag", `"hello"`)
+		if atomic.AddInt32(&failed, 1) == 1 {
+			/* Cut the connection midway */
+			w.Header().Set("Content-Length", "1048576")
+			w.Write(content[:100000])
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		}
+		http.ServeContent(w, r, "hello.deb", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	fetcher := archive.HTTPFetcher{
+		BaseURL: server.URL,
+		Retry:   archive.RetryPolicy{Retries: 1, Backoff: time.Millisecond},
+	}
+	body, err := fetcher.Fetch(context.Background(), "pool/main/h/hello/hello.deb")
+	isok(t, err)
+	data, err := ioutil.ReadAll(body)
+	body.Close()
+	isok(t, err)
+	assert(t, bytes.Equal(data, content))
+	assert(t, len(ranges) == 2)
+	assert(t, ranges[0] == "")
+	assert(t, strings.HasPrefix(ranges[1], "bytes=") && ranges[1] != "bytes=0-")
+
+	/* Without retries, the download fails */
+	atomic.StoreInt32(&failed, 0)
+	body, err = archive.HTTPFetcher{BaseURL: server.URL}.Fetch(context.Background(), "hello.deb")
+	isok(t, err)
+	_, err = ioutil.ReadAll(body)
+	body.Close()
+	notok(t, err)
+}
+
+func TestNewTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("Suite: stable\n"))
+	}))
+	defer server.Close()
+
+	transport, err := archive.NewTransport(archive.TransportOptions{Proxy: "DIRECT", MaxConnsPerHost: 2})
+	isok(t, err)
+	assert(t, transport.Proxy == nil)
+	assert(t, transport.MaxConnsPerHost == 2 && transport.MaxIdleConnsPerHost == 4)
+
+	fetcher := archive.HTTPFetcher{BaseURL: server.URL, Transport: transport}
+	for i := 0; i < 3; i++ {
+		body, err := fetcher.Fetch(context.Background(), "dists/stable/Release")
+		isok(t, err)
+		data, err := ioutil.ReadAll(body)
+		body.Close()
+		isok(t, err)
+		assert(t, string(data) == "Suite: stable\n")
+	}
+
+	transport, err = archive.NewTransport(archive.TransportOptions{Proxy: "http://proxy.example.org:3128"})
+	isok(t, err)
+	req, err := http.NewRequest("GET", "http://deb.debian.org/debian/", nil)
+	isok(t, err)
+	proxy, err := transport.Proxy(req)
+	isok(t, err)
+	assert(t, proxy.String() == "http://proxy.example.org:3128")
+
+	_, err = archive.NewTransport(archive.TransportOptions{Proxy: "proxy.example.org"})
+	notok(t, err)
+}
+
+func TestMemoryFetcher(t *testing.T) {
+	fetcher := archive.MemoryFetcher{"dists/stable/Release": []byte("Suite: stable\n")}
+	body, err := fetcher.Fetch(context.Background(), "/dists/stable/Release")
+	isok(t, err)
+	data, err := ioutil.ReadAll(body)
+	body.Close()
+	isok(t, err)
+	assert(t, string(data) == "Suite: stable\n")
+
+	_, err = fetcher.Fetch(context.Background(), "dists/stable/InRelease")
+	assert(t, errors.Is(err, fs.ErrNotExist))
+
+	/* Good enough for a Client */
+	client := archive.Client{Entry: archive.SourceEntry{Suite: "stable"}, Fetcher: fetcher}
+	release, err := client.Update(context.Background())
+	isok(t, err)
+	assert(t, release.Suite == "stable")
+}
+
+// vim: foldmethod=marker
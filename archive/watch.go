@@ -0,0 +1,256 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package archive // import "github.com/ebikt/go-debian/archive"
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ebikt/go-debian/version"
+)
+
+// Watching for updates {{{
+
+// PackageChange is a binary package which appeared, disappeared or changed
+// version between two polls of a suite.
+type PackageChange struct {
+	Package      string
+	Architecture string
+
+	// Versions before and after the change; the zero Version for a
+	// package which was added, or removed.
+	Old version.Version
+	New version.Version
+}
+
+func (c PackageChange) String() string {
+	switch {
+	case c.Old.Version == "":
+		return fmt.Sprintf("%s:%s %s (new)", c.Package, c.Architecture, c.New)
+	case c.New.Version == "":
+		return fmt.Sprintf("%s:%s %s (removed)", c.Package, c.Architecture, c.Old)
+	}
+	return fmt.Sprintf("%s:%s %s -> %s", c.Package, c.Architecture, c.Old, c.New)
+}
+
+// SuiteDelta is what changed in a suite since the last poll. Each package
+// and architecture is compared by its highest version in the suite.
+type SuiteDelta struct {
+	// The new Release file.
+	Release *Release
+
+	Added      []PackageChange
+	Removed    []PackageChange
+	Upgraded   []PackageChange
+	Downgraded []PackageChange
+}
+
+// Empty returns whether no package changed, which happens when only the
+// Release file was refreshed, or indexes the Watcher doesn't read changed.
+func (d SuiteDelta) Empty() bool {
+	return len(d.Added)+len(d.Removed)+len(d.Upgraded)+len(d.Downgraded) == 0
+}
+
+type packageArch struct {
+	name, arch string
+}
+
+// Watcher polls a suite of a remote repository, and reports what changed
+// in its Packages indexes whenever its Release file changes, so that
+// mirrors and notification bots don't need to write the polling logic.
+type Watcher struct {
+	Client *Client
+
+	// Time between two polls; 5 minutes if 0.
+	Interval time.Duration
+
+	// Called by Run with what changed, whenever the Release file changes.
+	// An error stops Run.
+	OnChange func(*SuiteDelta) error
+
+	// Called by Run with the errors of a poll, such as a network error,
+	// after which Run carries on polling. Run stops on the first error
+	// when nil.
+	OnError func(error)
+
+	// Date and checksums of the Release file of the last poll, and the
+	// packages found then.
+	state    string
+	packages map[packageArch]version.Version
+}
+
+// NewWatcher creates a Watcher of the suite of the Client, calling
+// onChange with what changes.
+func NewWatcher(client *Client, interval time.Duration, onChange func(*SuiteDelta) error) *Watcher {
+	return &Watcher{Client: client, Interval: interval, OnChange: onChange}
+}
+
+// releaseState sums up what identifies a version of the Release file.
+func releaseState(release *Release) string {
+	lines := []string{release.Date}
+	for _, hash := range release.Checksums() {
+		lines = append(lines, hash.Algorithm+" "+hash.Hash+" "+hash.Filename)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Poll fetches the Release file of the suite, and returns what changed
+// since the last Poll, or nil when the Release file didn't change. The
+// first Poll only takes note of the packages of the suite, and returns
+// nil.
+func (w *Watcher) Poll(ctx context.Context) (*SuiteDelta, error) {
+	release, err := w.Client.Update(ctx)
+	if err != nil {
+		return nil, err
+	}
+	state := releaseState(release)
+	if w.packages != nil && state == w.state {
+		return nil, nil
+	}
+
+	packages, err := w.readPackages(ctx, release)
+	if err != nil {
+		return nil, err
+	}
+	previous := w.packages
+	w.state, w.packages = state, packages
+	if previous == nil {
+		return nil, nil
+	}
+	return diffPackages(release, previous, packages), nil
+}
+
+// readPackages reads the highest version of each package of the Packages
+// indexes the Client uses. Indexes missing from the Release file are
+// skipped, as some architectures may lack some components.
+func (w *Watcher) readPackages(ctx context.Context, release *Release) (map[packageArch]version.Version, error) {
+	files := release.Files()
+	ret := map[packageArch]version.Version{}
+	for _, component := range w.Client.Components() {
+		for _, arch := range w.Client.Architectures() {
+			name := PackagesIndex(component, arch, false)
+			listed := false
+			for _, ext := range indexExtensions {
+				if _, listed = files[name+ext]; listed {
+					break
+				}
+			}
+			if !listed {
+				continue
+			}
+
+			reader, err := w.Client.Packages(ctx, component, arch)
+			if err != nil {
+				return nil, err
+			}
+			reader.SetFields("Package", "Version", "Architecture")
+			for {
+				entry, err := reader.Next()
+				if err == io.EOF {
+					break
+				} else if err != nil {
+					return nil, err
+				}
+				key := packageArch{entry.Package, entry.Architecture.String()}
+				if current, found := ret[key]; !found || version.Compare(entry.Version, current) > 0 {
+					ret[key] = entry.Version
+				}
+			}
+		}
+	}
+	return ret, nil
+}
+
+func diffPackages(release *Release, old, new map[packageArch]version.Version) *SuiteDelta {
+	ret := SuiteDelta{Release: release}
+	for key, newVersion := range new {
+		change := PackageChange{Package: key.name, Architecture: key.arch, New: newVersion}
+		oldVersion, found := old[key]
+		if !found {
+			ret.Added = append(ret.Added, change)
+			continue
+		}
+		change.Old = oldVersion
+		switch cmp := version.Compare(newVersion, oldVersion); {
+		case cmp > 0:
+			ret.Upgraded = append(ret.Upgraded, change)
+		case cmp < 0:
+			ret.Downgraded = append(ret.Downgraded, change)
+		}
+	}
+	for key, oldVersion := range old {
+		if _, found := new[key]; !found {
+			ret.Removed = append(ret.Removed, PackageChange{Package: key.name, Architecture: key.arch, Old: oldVersion})
+		}
+	}
+	for _, changes := range [][]PackageChange{ret.Added, ret.Removed, ret.Upgraded, ret.Downgraded} {
+		sort.Slice(changes, func(i, j int) bool {
+			if changes[i].Package != changes[j].Package {
+				return changes[i].Package < changes[j].Package
+			}
+			return changes[i].Architecture < changes[j].Architecture
+		})
+	}
+	return &ret
+}
+
+// Run polls the suite every Interval until the context is done, calling
+// OnChange with what changes. It returns the error of the context, or the
+// first error of OnChange, or of a poll when there's no OnError.
+func (w *Watcher) Run(ctx context.Context) error {
+	interval := w.Interval
+	if interval == 0 {
+		interval = 5 * time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		delta, err := w.Poll(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if w.OnError == nil {
+				return err
+			}
+			w.OnError(err)
+		} else if delta != nil && w.OnChange != nil {
+			if err := w.OnChange(delta); err != nil {
+				return err
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// }}}
+
+// vim: foldmethod=marker
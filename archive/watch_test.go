@@ -0,0 +1,83 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package archive_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ebikt/go-debian/archive"
+)
+
+/*
+ *
+ */
+
+func TestWatcher(t *testing.T) {
+	suite := testSuite()
+	suite.Indexes["main/binary-amd64/Packages"] = []byte(fooPackages + "Package: gone\nVersion: 1.0\nArchitecture: all\n")
+	repo, client := clientRepository(t, suite)
+	defer repo.Close()
+
+	ctx := context.Background()
+	watcher := archive.NewWatcher(client, time.Millisecond, nil)
+	delta, err := watcher.Poll(ctx)
+	isok(t, err)
+	assert(t, delta == nil)
+	delta, err = watcher.Poll(ctx)
+	isok(t, err)
+	assert(t, delta == nil)
+
+	suite.Indexes["main/binary-amd64/Packages"] = []byte(fooNewPackages + "\nPackage: bar\nVersion: 0.1\nArchitecture: amd64\n")
+	isok(t, repo.AddSuite(suite))
+	delta, err = watcher.Poll(ctx)
+	isok(t, err)
+	assert(t, delta != nil && !delta.Empty())
+	assert(t, len(delta.Upgraded) == 1)
+	assert(t, delta.Upgraded[0].String() == "foo:amd64 1.0-1 -> 2.0-1")
+	assert(t, len(delta.Added) == 1 && delta.Added[0].Package == "bar")
+	assert(t, len(delta.Removed) == 1 && delta.Removed[0].String() == "gone:all 1.0 (removed)")
+	assert(t, len(delta.Downgraded) == 0)
+
+	/* Run reports the changes, and stops on the error of OnChange */
+	suite.Indexes["main/binary-amd64/Packages"] = []byte(fooPackages)
+	isok(t, repo.AddSuite(suite))
+	stop := fmt.Errorf("stop")
+	watcher.OnChange = func(delta *archive.SuiteDelta) error {
+		assert(t, len(delta.Downgraded) == 1 && len(delta.Removed) == 1)
+		return stop
+	}
+	assert(t, watcher.Run(ctx) == stop)
+
+	/* Poll errors are handed to OnError, until the context is done */
+	repo.RemoveFile("dists/stable/InRelease")
+	repo.RemoveFile("dists/stable/Release")
+	errors := 0
+	watcher.OnError = func(error) { errors++ }
+	ctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	assert(t, watcher.Run(ctx) == context.DeadlineExceeded)
+	assert(t, errors > 0)
+}
+
+// vim: foldmethod=marker
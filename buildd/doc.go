@@ -0,0 +1,8 @@
+/*
+
+This module provides the data model of a build daemon queue, such as
+wanna-build's: the state of the builds of every source package on an
+architecture, and how they change as packages are built and uploaded.
+
+*/
+package buildd // import "github.com/ebikt/go-debian/buildd"
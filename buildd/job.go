@@ -0,0 +1,178 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package buildd // import "github.com/ebikt/go-debian/buildd"
+
+import (
+	"fmt"
+
+	"github.com/ebikt/go-debian/dependency"
+	"github.com/ebikt/go-debian/version"
+)
+
+// State {{{
+
+// State of the build of a source package on an architecture, named as
+// wanna-build names them.
+type State string
+
+const (
+	// The package has to be built.
+	StateNeedsBuild State = "Needs-Build"
+
+	// A builder took the package and is building it.
+	StateBuilding State = "Building"
+
+	// The package was built, and is waiting to be uploaded.
+	StateBuilt State = "Built"
+
+	// The package was uploaded, and is waiting to be installed in the
+	// archive.
+	StateUploaded State = "Uploaded"
+
+	// The binary packages are in the archive, up to date.
+	StateInstalled State = "Installed"
+
+	// The package can't be built until its DepWait relations are
+	// satisfied.
+	StateDepWait State = "Dep-Wait"
+
+	// The build failed.
+	StateBuildAttempted State = "Build-Attempted"
+
+	// The package isn't built on this architecture.
+	StateNotForUs State = "Not-For-Us"
+)
+
+// The changes of state allowed by Job.SetState.
+var transitions = map[State][]State{
+	StateNeedsBuild:     {StateBuilding, StateDepWait, StateNotForUs},
+	StateBuilding:       {StateBuilt, StateBuildAttempted, StateDepWait, StateNeedsBuild},
+	StateBuilt:          {StateUploaded, StateNeedsBuild},
+	StateUploaded:       {StateInstalled, StateNeedsBuild},
+	StateInstalled:      {StateNeedsBuild},
+	StateDepWait:        {StateNeedsBuild, StateNotForUs},
+	StateBuildAttempted: {StateNeedsBuild, StateDepWait, StateNotForUs},
+	StateNotForUs:       {StateNeedsBuild},
+}
+
+// }}}
+
+// Job {{{
+
+// Job is the build of a version of a source package on an architecture.
+type Job struct {
+	Package      string
+	Version      version.Version
+	Architecture dependency.Arch
+	State        State
+
+	// Number of the binNMU to build, 0 for a sourceful build.
+	BinNMU int
+
+	// Changelog entry of the binNMU.
+	BinNMUReason string
+
+	// Relations the package is waiting on, in the Dep-Wait state.
+	DepWait dependency.Dependency
+
+	// Builder that took the package, in the Building and later states.
+	Builder string
+}
+
+// SetState moves the Job to another State, if wanna-build would allow it.
+func (j *Job) SetState(state State) error {
+	for _, allowed := range transitions[j.State] {
+		if allowed == state {
+			j.State = state
+			if state != StateDepWait {
+				j.DepWait = dependency.Dependency{}
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("%s can't go from %s to %s", j.Package, j.State, state)
+}
+
+// Take assigns a package needing a build to a builder.
+func (j *Job) Take(builder string) error {
+	if err := j.SetState(StateBuilding); err != nil {
+		return err
+	}
+	j.Builder = builder
+	return nil
+}
+
+// GiveBack puts a package being built back in the queue.
+func (j *Job) GiveBack() error {
+	if j.State != StateBuilding {
+		return fmt.Errorf("%s is not being built", j.Package)
+	}
+	j.Builder = ""
+	return j.SetState(StateNeedsBuild)
+}
+
+// Wait moves the package to the Dep-Wait state, until `dep` is satisfied.
+func (j *Job) Wait(dep dependency.Dependency) error {
+	if err := j.SetState(StateDepWait); err != nil {
+		return err
+	}
+	j.DepWait = dep
+	return nil
+}
+
+// ScheduleBinNMU requests a binary-only rebuild of a package that's up to
+// date, with the next binNMU number.
+func (j *Job) ScheduleBinNMU(reason string) error {
+	if j.State != StateInstalled {
+		return fmt.Errorf("Can't binNMU %s in state %s", j.Package, j.State)
+	}
+	if err := j.SetState(StateNeedsBuild); err != nil {
+		return err
+	}
+	j.BinNMU++
+	j.BinNMUReason = reason
+	j.Builder = ""
+	return nil
+}
+
+// BuildVersion returns the version of the binary packages to build, with
+// the binNMU suffix, such as 1.0-1+b2.
+func (j *Job) BuildVersion() version.Version {
+	ret := j.Version
+	if j.BinNMU == 0 {
+		return ret
+	}
+	suffix := fmt.Sprintf("+b%d", j.BinNMU)
+	if ret.IsNative() {
+		ret.Version += suffix
+	} else {
+		ret.Revision += suffix
+	}
+	return ret
+}
+
+func (j *Job) String() string {
+	return fmt.Sprintf("%s_%s [%s] %s", j.Package, j.BuildVersion(), j.Architecture, j.State)
+}
+
+// }}}
+
+// vim: foldmethod=marker
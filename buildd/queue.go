@@ -0,0 +1,181 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package buildd // import "github.com/ebikt/go-debian/buildd"
+
+import (
+	"sort"
+
+	"github.com/ebikt/go-debian/control"
+	"github.com/ebikt/go-debian/dependency"
+	"github.com/ebikt/go-debian/version"
+)
+
+// Queue {{{
+
+// Queue holds the Jobs of an architecture, one per source package.
+type Queue struct {
+	Architecture dependency.Arch
+	Jobs         map[string]*Job
+}
+
+// A Transition is a change of State of a Job made by Queue.Update. New
+// jobs come From the empty State.
+type Transition struct {
+	Package string
+	Version version.Version
+	From    State
+	To      State
+}
+
+// NewQueue creates an empty Queue for the given architecture.
+func NewQueue(arch dependency.Arch) *Queue {
+	return &Queue{Architecture: arch, Jobs: map[string]*Job{}}
+}
+
+// buildsOn tells if a source package has anything to build on `arch`:
+// architecture independent packages are left to the arch:all builders.
+func buildsOn(source control.SourceIndex, arch dependency.Arch) bool {
+	for _, candidate := range source.Architecture {
+		if candidate.CPU != "all" && arch.Matches(candidate) {
+			return true
+		}
+	}
+	return false
+}
+
+// Update brings the Queue in line with the Sources and Packages indexes of
+// the architecture: new source versions need to be built, and jobs whose
+// binary packages reached the archive are Installed. Dep-Wait jobs go back
+// to Needs-Build once the binary packages satisfy what they wait on. Jobs
+// of source packages that left the archive are dropped.
+//
+// The changes made are returned, sorted by package.
+func (q *Queue) Update(sources []control.SourceIndex, binaries []control.BinaryIndex) []Transition {
+	latest := map[string]control.SourceIndex{}
+	for _, source := range sources {
+		if old, ok := latest[source.Package]; !ok || version.Compare(source.Version, old.Version) > 0 {
+			latest[source.Package] = source
+		}
+	}
+
+	/* The binNMU number of the binary packages of each source version */
+	built := map[string]int{}
+	onArch := []control.BinaryIndex{}
+	for _, binary := range binaries {
+		if binary.Architecture.CPU == "all" {
+			onArch = append(onArch, binary)
+		}
+		if binary.Architecture != q.Architecture {
+			continue
+		}
+		onArch = append(onArch, binary)
+		sourceVersion, err := binary.SourceVersion()
+		if err != nil {
+			continue
+		}
+		key := binary.SourcePackage() + " " + sourceVersion.String()
+		_, n := binary.Version.BinNMU()
+		if old, ok := built[key]; !ok || n > old {
+			built[key] = n
+		}
+	}
+
+	ret := []Transition{}
+	move := func(job *Job, state State) {
+		if job.State != state {
+			ret = append(ret, Transition{Package: job.Package, Version: job.Version, From: job.State, To: state})
+			job.State = state
+		}
+	}
+
+	for name := range q.Jobs {
+		if _, ok := latest[name]; !ok {
+			delete(q.Jobs, name)
+		}
+	}
+	for name, source := range latest {
+		job, ok := q.Jobs[name]
+		if !ok || version.Compare(job.Version, source.Version) < 0 {
+			job = &Job{Package: name, Version: source.Version, Architecture: q.Architecture}
+			q.Jobs[name] = job
+		}
+
+		binNMU, isBuilt := built[name+" "+source.Version.String()]
+		switch {
+		case !buildsOn(source, q.Architecture):
+			move(job, StateNotForUs)
+		case isBuilt && binNMU >= job.BinNMU:
+			job.BinNMU = binNMU
+			move(job, StateInstalled)
+		case job.State == "":
+			move(job, StateNeedsBuild)
+		case job.State == StateDepWait && satisfied(job.DepWait, onArch):
+			job.DepWait = dependency.Dependency{}
+			move(job, StateNeedsBuild)
+		}
+	}
+
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Package < ret[j].Package
+	})
+	return ret
+}
+
+// Pending returns the jobs in the given State, sorted by package.
+func (q *Queue) Pending(state State) []*Job {
+	ret := []*Job{}
+	for _, job := range q.Jobs {
+		if job.State == state {
+			ret = append(ret, job)
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Package < ret[j].Package
+	})
+	return ret
+}
+
+// satisfied checks if every Relation of `dep` is satisfied by one of the
+// binary packages, by name and version.
+func satisfied(dep dependency.Dependency, binaries []control.BinaryIndex) bool {
+	for _, relation := range dep.Relations {
+		found := false
+		for _, possi := range relation.Possibilities {
+			for _, binary := range binaries {
+				if binary.Package != possi.Name {
+					continue
+				}
+				if possi.Version == nil || possi.Version.SatisfiedBy(binary.Version) {
+					found = true
+					break
+				}
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// }}}
+
+// vim: foldmethod=marker
@@ -0,0 +1,187 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package buildd_test
+
+import (
+	"bufio"
+	"log"
+	"runtime/debug"
+	"strings"
+	"testing"
+
+	"github.com/ebikt/go-debian/buildd"
+	"github.com/ebikt/go-debian/control"
+	"github.com/ebikt/go-debian/dependency"
+)
+
+/*
+ *
+ */
+
+func isok(t *testing.T, err error) {
+	if err != nil {
+		log.Printf("Error! Error is not nil! %s\n", err)
+		debug.PrintStack()
+		t.FailNow()
+	}
+}
+
+func notok(t *testing.T, err error) {
+	if err == nil {
+		log.Printf("Error! Error is nil!\n")
+		debug.PrintStack()
+		t.FailNow()
+	}
+}
+
+func assert(t *testing.T, expr bool) {
+	if !expr {
+		log.Printf("Assertion failed!")
+		debug.PrintStack()
+		t.FailNow()
+	}
+}
+
+/*
+ *
+ */
+
+func parseSources(t *testing.T, in string) []control.SourceIndex {
+	sources, err := control.ParseSourceIndex(bufio.NewReader(strings.NewReader(in)))
+	isok(t, err)
+	return sources
+}
+
+func parseBinaries(t *testing.T, in string) []control.BinaryIndex {
+	binaries, err := control.ParseBinaryIndex(bufio.NewReader(strings.NewReader(in)))
+	isok(t, err)
+	return binaries
+}
+
+const queueSources = `Package: hello
+Binary: hello
+Version: 2.10-3
+Architecture: any
+
+Package: hello-docs
+Binary: hello-docs
+Version: 1.0-1
+Architecture: all
+
+Package: libfoo
+Binary: libfoo1, libfoo-dev
+Version: 1.2-1
+Architecture: linux-any
+
+Package: kfreebsd-utils
+Binary: kfreebsd-utils
+Version: 1.0
+Architecture: kfreebsd-any
+`
+
+func TestQueueUpdate(t *testing.T) {
+	amd64, err := dependency.ParseArch("amd64")
+	isok(t, err)
+	queue := buildd.NewQueue(*amd64)
+
+	sources := parseSources(t, queueSources)
+	transitions := queue.Update(sources, parseBinaries(t, `Package: hello
+Version: 2.10-2
+Architecture: amd64
+`))
+	assert(t, len(transitions) == 4)
+	assert(t, transitions[0].Package == "hello")
+	assert(t, transitions[0].From == "")
+	assert(t, transitions[0].To == buildd.StateNeedsBuild)
+	assert(t, queue.Jobs["hello-docs"].State == buildd.StateNotForUs)
+	assert(t, queue.Jobs["kfreebsd-utils"].State == buildd.StateNotForUs)
+	assert(t, len(queue.Pending(buildd.StateNeedsBuild)) == 2)
+
+	hello := queue.Jobs["hello"]
+	isok(t, hello.Take("buildd-1"))
+	notok(t, hello.Take("buildd-2"))
+	isok(t, hello.SetState(buildd.StateBuilt))
+	isok(t, hello.SetState(buildd.StateUploaded))
+
+	libfoo := queue.Jobs["libfoo"]
+	isok(t, libfoo.Take("buildd-2"))
+	dep, err := dependency.Parse("libbar-dev (>= 2)")
+	isok(t, err)
+	isok(t, libfoo.Wait(*dep))
+
+	/* Nothing changes until the archive does */
+	assert(t, len(queue.Update(sources, nil)) == 0)
+
+	binaries := parseBinaries(t, `Package: hello
+Version: 2.10-3
+Architecture: amd64
+
+Package: libbar-dev
+Source: libbar
+Version: 2.1-1
+Architecture: all
+`)
+	transitions = queue.Update(sources, binaries)
+	assert(t, len(transitions) == 2)
+	assert(t, transitions[0].To == buildd.StateInstalled)
+	assert(t, transitions[1].Package == "libfoo")
+	assert(t, transitions[1].From == buildd.StateDepWait)
+	assert(t, transitions[1].To == buildd.StateNeedsBuild)
+	assert(t, len(libfoo.DepWait.Relations) == 0)
+
+	isok(t, hello.ScheduleBinNMU("Rebuild against libbar 2"))
+	assert(t, hello.BuildVersion().String() == "2.10-3+b1")
+	assert(t, len(queue.Update(sources, binaries)) == 0)
+	assert(t, hello.State == buildd.StateNeedsBuild)
+
+	binaries = append(binaries, parseBinaries(t, `Package: hello
+Source: hello (2.10-3)
+Version: 2.10-3+b1
+Architecture: amd64
+`)...)
+	transitions = queue.Update(sources, binaries)
+	assert(t, len(transitions) == 1)
+	assert(t, hello.State == buildd.StateInstalled)
+
+	/* A new upload resets the job */
+	sources = parseSources(t, strings.Replace(queueSources, "2.10-3", "2.10-4", 1))
+	transitions = queue.Update(sources[:1], binaries)
+	assert(t, len(transitions) == 1)
+	assert(t, transitions[0].From == "")
+	assert(t, queue.Jobs["hello"].BinNMU == 0)
+	assert(t, len(queue.Jobs) == 1)
+}
+
+func TestJobStates(t *testing.T) {
+	job := buildd.Job{Package: "hello", State: buildd.StateNeedsBuild}
+	notok(t, job.SetState(buildd.StateInstalled))
+	notok(t, job.GiveBack())
+	notok(t, job.ScheduleBinNMU(""))
+	isok(t, job.Take("buildd-1"))
+	isok(t, job.GiveBack())
+	assert(t, job.Builder == "")
+	assert(t, job.State == buildd.StateNeedsBuild)
+	isok(t, job.Take("buildd-1"))
+	isok(t, job.SetState(buildd.StateBuildAttempted))
+	isok(t, job.SetState(buildd.StateNeedsBuild))
+}
+
+// vim: foldmethod=marker
@@ -24,7 +24,6 @@ import (
 	"bufio"
 	"fmt"
 	"io"
-	"os"
 	"strings"
 	"time"
 
@@ -32,19 +31,35 @@ import (
 )
 
 // A ChangelogEntry is the encapsulation for each entry for a given version
-// in a series of uploads.
+// in a series of uploads:
+//
+//   hello (2.10-1) unstable; urgency=low
+//
+//     * New upstream release.
+//
+//    -- Santiago Vila <sanvila@debian.org>  Sun, 22 Mar 2015 11:56:00 +0100
+//
+// The Target holds the distributions, separated by spaces, and Arguments
+// the options following them, keyed by their lowercased name.
 type ChangelogEntry struct {
-	Source    string
-	Version   version.Version
-	Target    string
-	Arguments map[string]string
-	Changelog string
-	ChangedBy string
-	When      time.Time
+	Source    string            `json:"source"`
+	Version   version.Version   `json:"version"`
+	Target    string            `json:"target"`
+	Arguments map[string]string `json:"arguments"`
+	Changelog string            `json:"changes"`
+	ChangedBy string            `json:"changed_by"`
+	When      time.Time         `json:"date"`
 }
 
 const whenLayout = time.RFC1123Z // "Mon, 02 Jan 2006 15:04:05 -0700"
 
+// Layouts of the dates found in the wild, tried after whenLayout.
+var whenLayouts = []string{
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon,  2 Jan 2006 15:04:05 -0700",
+	"2 Jan 2006 15:04:05 -0700",
+}
+
 type ChangelogEntries []ChangelogEntry
 
 func trim(line string) string {
@@ -60,61 +75,142 @@ func partition(line, delim string) (string, string) {
 
 }
 
-func ParseOne(reader *bufio.Reader) (*ChangelogEntry, error) {
-	changeLog := ChangelogEntry{}
+// Distributions returns the distributions the entry targets.
+func (c ChangelogEntry) Distributions() []string {
+	return strings.Fields(c.Target)
+}
 
-	var header string
-	for {
-		line, err := reader.ReadString('\n')
-		if err != nil {
-			return nil, err
-		}
-		if line == "\n" {
-			continue
+// Maintainer splits ChangedBy into the name and the email address of the
+// person who made the entry.
+func (c ChangelogEntry) Maintainer() (string, string) {
+	name, email := partition(c.ChangedBy, "<")
+	return trim(name), trim(strings.TrimSuffix(trim(email), ">"))
+}
+
+// parseWhen parses the date of the trailer line, which should be in the
+// RFC 2822 format, as given by `date -R`.
+func parseWhen(value string) (time.Time, error) {
+	value = trim(value)
+	if i := strings.Index(value, " ("); i != -1 {
+		/* A trailing comment, such as "+0000 (UTC)" */
+		value = value[:i]
+	}
+	when, err := time.Parse(whenLayout, value)
+	if err == nil {
+		return when, nil
+	}
+	for _, layout := range whenLayouts {
+		if when, err := time.Parse(layout, value); err == nil {
+			return when, nil
 		}
-		if !strings.HasPrefix(line, " ") {
-			/* Great. Let's work with this. */
-			header = line
-			break
-		} else {
-			return nil, fmt.Errorf("Unexpected line: %s", line)
+	}
+	return time.Time{}, err
+}
+
+// readLine reads a line, adding the newline missing at the end of the
+// last one.
+func readLine(reader *bufio.Reader) (string, error) {
+	line, err := reader.ReadString('\n')
+	if err == io.EOF && line != "" {
+		return line + "\n", nil
+	}
+	return line, err
+}
+
+// isTrailer checks if the line ends the changelog, such as the Emacs
+// local variables, after which nothing is parsed.
+func isTrailer(line string) bool {
+	line = strings.ToLower(line)
+	for _, prefix := range []string{"local variables:", ";; local variables:", "old changelog:"} {
+		if strings.HasPrefix(line, prefix) {
+			return true
 		}
 	}
+	return false
+}
 
-	/* OK, so, we have a header. Let's run with it
-	 * hello (2.10-1) unstable; urgency=low */
+// isComment checks if the line is one of the comments allowed between
+// entries, such as vim modelines.
+func isComment(line string) bool {
+	return strings.HasPrefix(line, "#") || strings.HasPrefix(line, "vim:") ||
+		strings.HasPrefix(line, "$")
+}
 
-	arguments, options := partition(header, ";")
+// ParseHeader parses the first line of an entry, leaving the Changelog,
+// ChangedBy and When of the returned entry empty.
+func ParseHeader(header string) (*ChangelogEntry, error) {
+	changeLog := ChangelogEntry{Arguments: map[string]string{}}
+
+	/* hello (2.10-1) unstable; urgency=low */
+	arguments, options := partition(trim(header), ";")
 	/* Arguments: hello (2.10-1) unstable
 	 * Options:   urgency=low, other=bar */
 
 	source, remainder := partition(arguments, "(")
 	versionString, suite := partition(remainder, ")")
+	if remainder == "" || !strings.Contains(remainder, ")") {
+		return nil, fmt.Errorf("Malformed changelog header: '%s'", trim(header))
+	}
 
 	var err error
-
 	changeLog.Source = trim(source)
 	changeLog.Version, err = version.Parse(trim(versionString))
 	if err != nil {
 		return nil, err
 	}
-	changeLog.Target = trim(suite)
-
-	changeLog.Arguments = map[string]string{}
+	changeLog.Target = strings.Join(strings.Fields(suite), " ")
 
 	for _, entry := range strings.Split(options, ",") {
+		if trim(entry) == "" {
+			continue
+		}
 		key, value := partition(trim(entry), "=")
-		changeLog.Arguments[trim(key)] = trim(value)
+		changeLog.Arguments[strings.ToLower(trim(key))] = trim(value)
+	}
+	return &changeLog, nil
+}
+
+// ParseOne parses the next entry. It only reads as far as the end of that
+// entry, which makes it cheap to get the current version of a package
+// from the top of its changelog. io.EOF is returned when there are no
+// more entries.
+func ParseOne(reader *bufio.Reader) (*ChangelogEntry, error) {
+	var header string
+	for {
+		line, err := readLine(reader)
+		if err != nil {
+			return nil, err
+		}
+		if trim(line) == "" || isComment(line) {
+			continue
+		}
+		if isTrailer(line) {
+			return nil, io.EOF
+		}
+		if !strings.HasPrefix(line, " ") {
+			/* Great. Let's work with this. */
+			header = line
+			break
+		} else {
+			return nil, fmt.Errorf("Unexpected line: %s", line)
+		}
+	}
+
+	changeLog, err := ParseHeader(header)
+	if err != nil {
+		return nil, err
 	}
 
 	var signoff string
 	/* OK, we've got the header. Let's zip down. */
 	for {
-		line, err := reader.ReadString('\n')
-		if err != nil {
+		line, err := readLine(reader)
+		if err == io.EOF {
+			return nil, fmt.Errorf("Missing trailer line of %s (%s)", changeLog.Source, changeLog.Version)
+		} else if err != nil {
 			return nil, err
 		}
-		if !strings.HasPrefix(line, " ") && trim(line) != "" {
+		if !strings.HasPrefix(line, " ") && !strings.HasPrefix(line, "\t") && trim(line) != "" {
 			return nil, fmt.Errorf("Error! Didn't get ending line!")
 		}
 
@@ -130,22 +226,12 @@ func ParseOne(reader *bufio.Reader) (*ChangelogEntry, error) {
 	_, signoff = partition(signoff, "--")  /* Get rid of the leading " -- " */
 	whom, when := partition(signoff, "  ") /* Split on the "  " */
 	changeLog.ChangedBy = trim(whom)
-	changeLog.When, err = time.Parse(whenLayout, trim(when))
+	changeLog.When, err = parseWhen(when)
 	if err != nil {
 		return nil, fmt.Errorf("Failed parsing When %q: %v", when, err)
 	}
 
-	return &changeLog, nil
-}
-
-func ParseFileOne(path string) (*ChangelogEntry, error) {
-	f, err := os.Open(path)
-	if err != nil {
-		return nil, err
-	}
-	defer f.Close()
-
-	return ParseOne(bufio.NewReader(f))
+	return changeLog, nil
 }
 
 func Parse(reader io.Reader) (ChangelogEntries, error) {
@@ -164,14 +250,23 @@ func Parse(reader io.Reader) (ChangelogEntries, error) {
 	return ret, nil
 }
 
-func ParseFile(path string) (ChangelogEntries, error) {
-	f, err := os.Open(path)
-	if err != nil {
-		return nil, err
+// CurrentVersion returns the source name and the version of the topmost
+// entry, reading nothing but its header line.
+func CurrentVersion(reader *bufio.Reader) (string, version.Version, error) {
+	for {
+		line, err := readLine(reader)
+		if err != nil {
+			return "", version.Version{}, err
+		}
+		if trim(line) == "" || isComment(line) {
+			continue
+		}
+		entry, err := ParseHeader(line)
+		if err != nil {
+			return "", version.Version{}, err
+		}
+		return entry.Source, entry.Version, nil
 	}
-	defer f.Close()
-
-	return Parse(bufio.NewReader(f))
 }
 
 // vim: foldmethod=marker
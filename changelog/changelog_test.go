@@ -22,6 +22,7 @@ package changelog_test
 
 import (
 	"bufio"
+	"encoding/json"
 	"io"
 	"log"
 	"strings"
@@ -99,4 +100,82 @@ func TestChangelogEntries(t *testing.T) {
 	assert(t, len(changeLogs) == 2)
 }
 
+func TestChangelogFields(t *testing.T) {
+	entries, err := changelog.Parse(strings.NewReader(`# vim: set ft=debchangelog:
+hello (2.10-2~bpo12+1) bookworm-backports  experimental; Urgency=medium, binary-only=yes
+
+  * Rebuild for bookworm-backports.
+
+ -- Jane Doe <jane@example.org>  Mon, 3 Apr 2023 09:01:02 +0000 (UTC)
+
+hello (2.10-1) unstable; urgency=low
+
+  * New upstream release.
+
+ -- Santiago Vila <sanvila@debian.org>  Sun, 22 Mar 2015 11:56:00 +0100
+
+Local variables:
+mode: debian-changelog
+End:
+`))
+	isok(t, err)
+	assert(t, len(entries) == 2)
+
+	entry := entries[0]
+	assert(t, entry.Source == "hello")
+	assert(t, entry.Version.String() == "2.10-2~bpo12+1")
+	assert(t, strings.Join(entry.Distributions(), ",") == "bookworm-backports,experimental")
+	assert(t, entry.Arguments["urgency"] == "medium")
+	assert(t, entry.Arguments["binary-only"] == "yes")
+	assert(t, entry.Changelog == "\n  * Rebuild for bookworm-backports.\n\n")
+	name, email := entry.Maintainer()
+	assert(t, name == "Jane Doe")
+	assert(t, email == "jane@example.org")
+	assert(t, entry.When.Day() == 3)
+	assert(t, entry.When.Hour() == 9)
+
+	assert(t, len(entries[1].Arguments) == 1)
+}
+
+func TestChangelogCurrentVersion(t *testing.T) {
+	source, ver, err := changelog.CurrentVersion(bufio.NewReader(strings.NewReader(changeLog)))
+	isok(t, err)
+	assert(t, source == "hello")
+	assert(t, ver.String() == "2.10-1")
+
+	_, _, err = changelog.CurrentVersion(bufio.NewReader(strings.NewReader("hello 2.10-1 unstable\n")))
+	notok(t, err)
+}
+
+func TestChangelogMalformed(t *testing.T) {
+	for _, data := range []string{
+		"hello (2.10-1 unstable; urgency=low\n\n  * Oops.\n\n -- A <a@b.c>  Sun, 22 Mar 2015 11:56:00 +0100\n",
+		"hello (2.10-1) unstable; urgency=low\n\n  * No trailer line.\n",
+		"hello (2.10-1) unstable; urgency=low\n\n  * Bad date.\n\n -- A <a@b.c>  yesterday\n",
+	} {
+		_, err := changelog.Parse(strings.NewReader(data))
+		notok(t, err)
+	}
+}
+
+func TestChangelogJSON(t *testing.T) {
+	entries, err := changelog.Parse(strings.NewReader(changeLog))
+	isok(t, err)
+	data, err := json.Marshal(entries[1])
+	isok(t, err)
+	assert(t, strings.HasPrefix(string(data),
+		`{"source":"hello","version":"2.9-2","target":"unstable","arguments":{"urgency":"low"},"changes":`))
+	assert(t, strings.HasSuffix(string(data),
+		`"changed_by":"Santiago Vila \u003csanvila@debian.org\u003e","date":"2014-11-06T12:03:40+01:00"}`))
+
+	decoded := changelog.ChangelogEntries{}
+	data, err = json.Marshal(entries)
+	isok(t, err)
+	isok(t, json.Unmarshal(data, &decoded))
+	assert(t, len(decoded) == 2)
+	assert(t, decoded[0].Version.String() == "2.10-1")
+	assert(t, decoded[1].When.Equal(entries[1].When))
+	assert(t, decoded[1].Changelog == entries[1].Changelog)
+}
+
 // vim: foldmethod=marker
@@ -0,0 +1,165 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package changelog // import "github.com/ebikt/go-debian/changelog"
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Document {{{
+
+// A Document is a changelog, kept the way it was written: writing it out
+// again gives back the very same bytes, whatever the layout of its entries
+// or the comments between them. Only the lines of the entries which are
+// added or updated are rendered again, so that scripted edits make for
+// minimal diffs, which ChangelogEntries.WriteTo doesn't.
+type Document struct {
+	entries []documentEntry
+
+	// What follows the last entry, such as the Emacs local variables.
+	trailer string
+}
+
+// A documentEntry is an entry, along with the text it was read from,
+// including the blank lines and comments before it.
+type documentEntry struct {
+	entry ChangelogEntry
+	raw   string
+}
+
+// ParseDocument reads a changelog as a Document.
+func ParseDocument(reader io.Reader) (*Document, error) {
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	source := bytes.NewReader(data)
+	stream := bufio.NewReader(source)
+
+	doc := Document{entries: []documentEntry{}}
+	offset := 0
+	for {
+		entry, err := ParseOne(stream)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		end := len(data) - source.Len() - stream.Buffered()
+		doc.entries = append(doc.entries, documentEntry{entry: *entry, raw: string(data[offset:end])})
+		offset = end
+	}
+	doc.trailer = string(data[offset:])
+	return &doc, nil
+}
+
+// Entries returns the entries of the changelog, the newest first.
+func (d *Document) Entries() ChangelogEntries {
+	ret := ChangelogEntries{}
+	for _, entry := range d.entries {
+		ret = append(ret, entry.entry)
+	}
+	return ret
+}
+
+// Add prepends an entry, as ChangelogEntries.Add does, leaving the rest of
+// the changelog alone.
+func (d *Document) Add(entry ChangelogEntry) error {
+	entries := d.Entries()
+	if err := entries.Add(entry); err != nil {
+		return err
+	}
+	raw := entries[0].String()
+	if len(d.entries) > 0 {
+		raw += "\n"
+	}
+	d.entries = append([]documentEntry{{entry: entries[0], raw: raw}}, d.entries...)
+	return nil
+}
+
+// Update replaces the i-th entry, the newest one being the 0th. Only the
+// lines which changed are written again: the header for the Source,
+// Version, Target or Arguments, the trailer line for ChangedBy or When,
+// and the changes in between for the Changelog. Finalizing an UNRELEASED
+// entry, as `dch --release` does, only touches its first and last lines.
+func (d *Document) Update(i int, entry ChangelogEntry) error {
+	if i < 0 || i >= len(d.entries) {
+		return fmt.Errorf("No changelog entry %d", i)
+	}
+	old := d.entries[i]
+	lines := strings.SplitAfter(old.raw, "\n")
+
+	/* The raw text of an entry is its leading blank lines and comments,
+	 * the header, the changes, and the trailer line, ParseOne reading
+	 * no further */
+	header := 0
+	for header < len(lines) && (trim(lines[header]) == "" || isComment(lines[header])) {
+		header++
+	}
+	trailer := len(lines) - 1
+	for trailer > header && !strings.HasPrefix(lines[trailer], " -- ") {
+		trailer--
+	}
+	if header >= trailer {
+		return fmt.Errorf("Malformed changelog entry %d", i)
+	}
+
+	if old.entry.header() != entry.header() {
+		lines[header] = entry.header() + "\n"
+	}
+	if old.entry.Changelog != entry.Changelog {
+		lines = append(lines[:header+1], append([]string{entry.Changelog}, lines[trailer:]...)...)
+		trailer = header + 2
+	}
+	if old.entry.ChangedBy != entry.ChangedBy || !old.entry.When.Equal(entry.When) {
+		lines[trailer] = fmt.Sprintf(" -- %s  %s\n", entry.ChangedBy, entry.When.Format(whenLayout))
+	}
+	d.entries[i] = documentEntry{entry: entry, raw: strings.Join(lines, "")}
+	return nil
+}
+
+// Bytes returns the changelog as it is to be written out.
+func (d *Document) Bytes() []byte {
+	out := bytes.Buffer{}
+	for _, entry := range d.entries {
+		out.WriteString(entry.raw)
+	}
+	out.WriteString(d.trailer)
+	return out.Bytes()
+}
+
+func (d *Document) String() string {
+	return string(d.Bytes())
+}
+
+// WriteTo writes the changelog out.
+func (d *Document) WriteTo(out io.Writer) (int64, error) {
+	n, err := out.Write(d.Bytes())
+	return int64(n), err
+}
+
+// }}}
+
+// vim: foldmethod=marker
@@ -0,0 +1,53 @@
+//go:build !pureparser
+// +build !pureparser
+
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package changelog // import "github.com/ebikt/go-debian/changelog"
+
+import (
+	"io"
+	"os"
+
+	"github.com/ebikt/go-debian/internal"
+)
+
+// ParseDocumentFile reads the changelog at the given path as a Document,
+// to be edited.
+func ParseDocumentFile(path string) (*Document, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseDocument(f)
+}
+
+// WriteFile writes the Document to the changelog at the given path,
+// replacing it atomically.
+func (d *Document) WriteFile(path string) error {
+	return internal.WriteFileAtomic(path, 0644, func(out io.Writer) error {
+		_, err := d.WriteTo(out)
+		return err
+	})
+}
+
+// vim: foldmethod=marker
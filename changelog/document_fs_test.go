@@ -0,0 +1,53 @@
+//go:build !pureparser
+// +build !pureparser
+
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package changelog_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ebikt/go-debian/changelog"
+)
+
+/*
+ *
+ */
+
+func TestDocumentWriteFile(t *testing.T) {
+	doc, err := changelog.ParseDocument(strings.NewReader(untidyChangelog))
+	isok(t, err)
+
+	path := filepath.Join(t.TempDir(), "changelog")
+	isok(t, doc.WriteFile(path))
+	reread, err := changelog.ParseDocumentFile(path)
+	isok(t, err)
+	assert(t, len(reread.Entries()) == 2)
+	data, err := ioutil.ReadFile(path)
+	isok(t, err)
+	assert(t, string(data) == untidyChangelog)
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,99 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package changelog_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ebikt/go-debian/changelog"
+	"github.com/ebikt/go-debian/version"
+)
+
+/*
+ *
+ */
+
+// The layout of the entries differs from what ChangelogEntry.String writes
+const untidyChangelog = `hello (2.10-2) UNRELEASED; urgency=medium
+
+  * Fix the build with GCC 14.
+  *   Bump Standards-Version.
+
+ -- Jane Doe <jane@example.org>  Tue, 5 Mar 2024 10:00:00 +0100
+
+
+# vim: comments
+hello (2.10-1) unstable; urgency=low
+  * New upstream release.
+ -- Santiago Vila <sanvila@debian.org>  Sun, 22 Mar 2015 11:56:00 +0100
+
+Local variables:
+mode: debian-changelog
+End:
+`
+
+func TestDocumentRoundTrip(t *testing.T) {
+	for _, data := range []string{untidyChangelog, changeLog, ""} {
+		doc, err := changelog.ParseDocument(strings.NewReader(data))
+		isok(t, err)
+		assert(t, doc.String() == data)
+	}
+
+	doc, err := changelog.ParseDocument(strings.NewReader(untidyChangelog))
+	isok(t, err)
+	entries := doc.Entries()
+	assert(t, len(entries) == 2)
+	assert(t, entries[1].Version.String() == "2.10-1")
+}
+
+func TestDocumentUpdate(t *testing.T) {
+	doc, err := changelog.ParseDocument(strings.NewReader(untidyChangelog))
+	isok(t, err)
+
+	/* Releasing touches the header and the trailer line */
+	entry := doc.Entries()[0]
+	entry.Target = "unstable"
+	entry.When = time.Date(2024, 3, 6, 9, 30, 0, 0, time.FixedZone("", 3600))
+	isok(t, doc.Update(0, entry))
+	expected := strings.Replace(untidyChangelog, "UNRELEASED", "unstable", 1)
+	expected = strings.Replace(expected, "Tue, 5 Mar 2024 10:00:00", "Wed, 06 Mar 2024 09:30:00", 1)
+	assert(t, doc.String() == expected)
+
+	/* As does a change to the changes */
+	entry = doc.Entries()[1]
+	entry.Changelog += "  * Bump Standards-Version.\n"
+	isok(t, doc.Update(1, entry))
+	expected = strings.Replace(expected, "release.\n", "release.\n  * Bump Standards-Version.\n", 1)
+	assert(t, doc.String() == expected)
+	notok(t, doc.Update(2, entry))
+
+	ver, err := version.Parse("2.10-3")
+	isok(t, err)
+	added := changelog.NewEntry("hello", ver, "unstable", "", "Jane Doe <jane@example.org>", "Rebuild.")
+	added.When = time.Date(2024, 4, 1, 12, 0, 0, 0, time.UTC)
+	isok(t, doc.Add(added))
+	assert(t, doc.String() == added.String()+"\n"+expected)
+	notok(t, doc.Add(added))
+}
+
+// vim: foldmethod=marker
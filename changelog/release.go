@@ -0,0 +1,177 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package changelog // import "github.com/ebikt/go-debian/changelog"
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/ebikt/go-debian/version"
+)
+
+// Releasing {{{
+
+// Bugs closed by an entry, found the way dpkg-parsechangelog does.
+var (
+	closesRegexp = regexp.MustCompile(`(?i)closes:\s*(?:bug)?#?\s?\d+(?:,\s*(?:bug)?#?\s?\d+)*`)
+	bugRegexp    = regexp.MustCompile(`\d+`)
+)
+
+// Closes returns the numbers of the bugs the entry closes, as listed by
+// "Closes: #123, #456" in its changes, in order.
+func (c ChangelogEntry) Closes() []string {
+	ret := []string{}
+	seen := map[string]bool{}
+	for _, closes := range closesRegexp.FindAllString(c.Changelog, -1) {
+		for _, bug := range bugRegexp.FindAllString(closes, -1) {
+			if !seen[bug] {
+				seen[bug] = true
+				ret = append(ret, bug)
+			}
+		}
+	}
+	return ret
+}
+
+// MaintainerFromEnv finds out who is making a changelog entry from the
+// environment, the way dch does: DEBFULLNAME and DEBEMAIL, or NAME and
+// EMAIL, where the email variables may also hold "Name <email>".
+func MaintainerFromEnv(getenv func(string) string) (string, error) {
+	for _, vars := range [][2]string{{"DEBFULLNAME", "DEBEMAIL"}, {"NAME", "EMAIL"}} {
+		name, email := trim(getenv(vars[0])), trim(getenv(vars[1]))
+		if email == "" {
+			continue
+		}
+		if strings.Contains(email, "<") {
+			embeddedName, embeddedEmail := partition(email, "<")
+			email = trim(strings.TrimSuffix(trim(embeddedEmail), ">"))
+			if name == "" {
+				name = trim(embeddedName)
+			}
+		}
+		if name == "" {
+			continue
+		}
+		return fmt.Sprintf("%s <%s>", name, email), nil
+	}
+	return "", fmt.Errorf("No maintainer in the environment, set DEBFULLNAME and DEBEMAIL")
+}
+
+// ReleaseOptions tell how to release the top changelog entry.
+type ReleaseOptions struct {
+	// Distribution the entry targets, replacing UNRELEASED.
+	Distribution string
+
+	// Who releases the entry; whoever wrote it if empty.
+	ChangedBy string
+
+	// Date of the release; now if zero.
+	When time.Time
+
+	// Versions of the source package already in the archive, which the
+	// released version must be newer than.
+	ArchiveVersions []version.Version
+
+	// If set, the released entry is checked against the Vendor.
+	Vendor *Vendor
+}
+
+// ReleaseInfo is what releasing an entry yields: the fields of the
+// .changes file that come from the changelog.
+type ReleaseInfo struct {
+	Source       string
+	Version      version.Version
+	Distribution string
+	Urgency      Urgency
+	ChangedBy    string
+	Date         time.Time
+	Closes       []string
+
+	// The Changes field: the header of the entry and its changes.
+	Changes string
+}
+
+// Release finalizes the top entry, which must target UNRELEASED, for the
+// given distribution, as `dch --release` does. Its version must be newer
+// than the one of the previous entry and than the ArchiveVersions. The
+// entries are only changed if all of that holds.
+func (c *ChangelogEntries) Release(opts ReleaseOptions) (*ReleaseInfo, error) {
+	if len(*c) == 0 {
+		return nil, fmt.Errorf("No changelog entry to release")
+	}
+	entry := (*c)[0]
+	if entry.Target != "UNRELEASED" {
+		return nil, fmt.Errorf("%s (%s) is already released to %s", entry.Source, entry.Version, entry.Target)
+	}
+	if opts.Distribution == "" || opts.Distribution == "UNRELEASED" {
+		return nil, fmt.Errorf("No distribution to release %s (%s) to", entry.Source, entry.Version)
+	}
+
+	previous := opts.ArchiveVersions
+	if len(*c) > 1 {
+		previous = append([]version.Version{(*c)[1].Version}, previous...)
+	}
+	for _, ver := range previous {
+		if version.Compare(entry.Version, ver) <= 0 {
+			return nil, fmt.Errorf(
+				"%s (%s) is not newer than %s",
+				entry.Source, entry.Version, ver,
+			)
+		}
+	}
+
+	entry.Target = opts.Distribution
+	if opts.ChangedBy != "" {
+		entry.ChangedBy = opts.ChangedBy
+	}
+	entry.When = opts.When
+	if entry.When.IsZero() {
+		entry.When = time.Now()
+	}
+	if opts.Vendor != nil {
+		if err := opts.Vendor.Validate(entry); err != nil {
+			return nil, err
+		}
+	}
+	urgency, err := entry.Urgency()
+	if err != nil {
+		return nil, err
+	}
+
+	(*c)[0] = entry
+	rendered := entry.String()
+	return &ReleaseInfo{
+		Source:       entry.Source,
+		Version:      entry.Version,
+		Distribution: entry.Target,
+		Urgency:      urgency,
+		ChangedBy:    entry.ChangedBy,
+		Date:         entry.When,
+		Closes:       entry.Closes(),
+		Changes:      rendered[:strings.LastIndex(rendered, "\n -- ")],
+	}, nil
+}
+
+// }}}
+
+// vim: foldmethod=marker
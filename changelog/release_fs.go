@@ -0,0 +1,56 @@
+//go:build !pureparser
+// +build !pureparser
+
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package changelog // import "github.com/ebikt/go-debian/changelog"
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// ReleaseTree releases the top entry of the debian/changelog of the source
+// tree at `dir`, see ChangelogEntries.Release, and writes the changelog
+// back. Unless given, who releases it is taken from the environment, see
+// MaintainerFromEnv, or else left as it is.
+func ReleaseTree(dir string, opts ReleaseOptions) (*ReleaseInfo, error) {
+	path := filepath.Join(dir, "debian", "changelog")
+	entries, err := ParseFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if opts.ChangedBy == "" {
+		if changedBy, err := MaintainerFromEnv(os.Getenv); err == nil {
+			opts.ChangedBy = changedBy
+		}
+	}
+	info, err := entries.Release(opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := entries.WriteFile(path); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// vim: foldmethod=marker
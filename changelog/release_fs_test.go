@@ -0,0 +1,57 @@
+//go:build !pureparser
+// +build !pureparser
+
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package changelog_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ebikt/go-debian/changelog"
+)
+
+/*
+ *
+ */
+
+func TestReleaseTree(t *testing.T) {
+	dir := t.TempDir()
+	isok(t, os.Mkdir(filepath.Join(dir, "debian"), 0755))
+	path := filepath.Join(dir, "debian", "changelog")
+	isok(t, os.WriteFile(path, []byte(unreleasedChangeLog), 0644))
+	t.Setenv("DEBFULLNAME", "John Roe")
+	t.Setenv("DEBEMAIL", "john@example.org")
+
+	info, err := changelog.ReleaseTree(dir, changelog.ReleaseOptions{Distribution: "unstable"})
+	isok(t, err)
+	assert(t, info.ChangedBy == "John Roe <john@example.org>")
+
+	entries, err := changelog.ParseFile(path)
+	isok(t, err)
+	assert(t, entries[0].Target == "unstable")
+	assert(t, entries[0].ChangedBy == "John Roe <john@example.org>")
+	assert(t, len(entries) == 2)
+}
+
+// vim: foldmethod=marker
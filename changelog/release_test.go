@@ -0,0 +1,128 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package changelog_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ebikt/go-debian/changelog"
+	"github.com/ebikt/go-debian/version"
+)
+
+/*
+ *
+ */
+
+const unreleasedChangeLog = `hello (2.10-2) UNRELEASED; urgency=medium
+
+  * Fix the build with GCC 14. Closes: #1001, #1002
+  * Bump Standards-Version (closes: bug#1003).
+
+ -- Jane Doe <jane@example.org>  Tue, 05 Mar 2024 10:00:00 +0100
+
+hello (2.10-1) unstable; urgency=low
+
+  * New upstream release.
+
+ -- Santiago Vila <sanvila@debian.org>  Sun, 22 Mar 2015 11:56:00 +0100
+`
+
+func mustVersion(t *testing.T, ver string) version.Version {
+	v, err := version.Parse(ver)
+	isok(t, err)
+	return v
+}
+
+func TestMaintainerFromEnv(t *testing.T) {
+	env := func(vars map[string]string) func(string) string {
+		return func(name string) string { return vars[name] }
+	}
+	who, err := changelog.MaintainerFromEnv(env(map[string]string{
+		"DEBFULLNAME": "Jane Doe", "DEBEMAIL": "jane@example.org",
+		"NAME": "Someone", "EMAIL": "someone@example.org",
+	}))
+	isok(t, err)
+	assert(t, who == "Jane Doe <jane@example.org>")
+
+	who, err = changelog.MaintainerFromEnv(env(map[string]string{
+		"DEBEMAIL": "Jane Doe <jane@example.org>",
+	}))
+	isok(t, err)
+	assert(t, who == "Jane Doe <jane@example.org>")
+
+	who, err = changelog.MaintainerFromEnv(env(map[string]string{
+		"DEBEMAIL": "jane@example.org", "NAME": "Jane", "EMAIL": "jane@example.com",
+	}))
+	isok(t, err)
+	assert(t, who == "Jane <jane@example.com>")
+
+	_, err = changelog.MaintainerFromEnv(env(map[string]string{}))
+	notok(t, err)
+}
+
+func TestRelease(t *testing.T) {
+	entries, err := changelog.Parse(strings.NewReader(unreleasedChangeLog))
+	isok(t, err)
+	assert(t, len(entries[0].Closes()) == 3)
+
+	when := time.Date(2024, 3, 6, 9, 0, 0, 0, time.UTC)
+	info, err := entries.Release(changelog.ReleaseOptions{
+		Distribution:    "unstable",
+		ChangedBy:       "John Roe <john@example.org>",
+		When:            when,
+		ArchiveVersions: []version.Version{mustVersion(t, "2.10-1")},
+		Vendor:          &changelog.Debian,
+	})
+	isok(t, err)
+	assert(t, info.Source == "hello")
+	assert(t, info.Version.String() == "2.10-2")
+	assert(t, info.Distribution == "unstable")
+	assert(t, info.Urgency == changelog.UrgencyMedium)
+	assert(t, info.ChangedBy == "John Roe <john@example.org>")
+	assert(t, info.Date.Equal(when))
+	assert(t, strings.Join(info.Closes, " ") == "1001 1002 1003")
+	assert(t, strings.HasPrefix(info.Changes, "hello (2.10-2) unstable; urgency=medium\n\n  * Fix"))
+	assert(t, !strings.Contains(info.Changes, " -- "))
+	assert(t, entries[0].Target == "unstable")
+	assert(t, strings.HasPrefix(entries[0].String(), "hello (2.10-2) unstable;"))
+
+	/* It's released now */
+	_, err = entries.Release(changelog.ReleaseOptions{Distribution: "unstable"})
+	notok(t, err)
+}
+
+func TestReleaseRefused(t *testing.T) {
+	for _, opts := range []changelog.ReleaseOptions{
+		{},
+		{Distribution: "unstable", ArchiveVersions: []version.Version{mustVersion(t, "2.10-2")}},
+		{Distribution: "bogus", Vendor: &changelog.Debian},
+	} {
+		entries, err := changelog.Parse(strings.NewReader(unreleasedChangeLog))
+		isok(t, err)
+		_, err = entries.Release(opts)
+		notok(t, err)
+		assert(t, entries[0].Target == "UNRELEASED")
+	}
+}
+
+// vim: foldmethod=marker
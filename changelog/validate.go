@@ -0,0 +1,195 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package changelog // import "github.com/ebikt/go-debian/changelog"
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Urgency {{{
+
+// Urgency of an upload, from the "urgency=" option of a changelog entry
+// header, which decides how fast it migrates to testing.
+type Urgency string
+
+const (
+	UrgencyLow       Urgency = "low"
+	UrgencyMedium    Urgency = "medium"
+	UrgencyHigh      Urgency = "high"
+	UrgencyEmergency Urgency = "emergency"
+	UrgencyCritical  Urgency = "critical"
+)
+
+// ParseUrgency parses the value of the "urgency=" option. The keyword is
+// case-insensitive, and may be followed by a comment, such as
+// "medium (HIGH for hurd-i386)".
+func ParseUrgency(value string) (Urgency, error) {
+	fields := strings.Fields(value)
+	if len(fields) == 0 {
+		return "", fmt.Errorf("Empty urgency")
+	}
+	urgency := Urgency(strings.ToLower(fields[0]))
+	if !urgency.Valid() {
+		return "", fmt.Errorf("Unknown urgency '%s'", fields[0])
+	}
+	return urgency, nil
+}
+
+// Valid checks if the Urgency is one of the defined ones.
+func (u Urgency) Valid() bool {
+	switch u {
+	case UrgencyLow, UrgencyMedium, UrgencyHigh, UrgencyEmergency, UrgencyCritical:
+		return true
+	}
+	return false
+}
+
+// Urgency returns the Urgency of the entry. Entries without one are
+// treated as UrgencyLow, as the archive does.
+func (c ChangelogEntry) Urgency() (Urgency, error) {
+	value, ok := c.Arguments["urgency"]
+	if !ok {
+		return UrgencyLow, nil
+	}
+	return ParseUrgency(value)
+}
+
+// }}}
+
+// Vendor {{{
+
+// A Vendor lists the distributions its changelog entries may target. A
+// distribution is valid if it's one of the Suites, optionally followed
+// by one of the Suffixes (e.g. "bookworm" + "-security"), or, when
+// AllowUnreleased is set, "UNRELEASED".
+type Vendor struct {
+	Name            string
+	Suites          []string
+	Suffixes        []string
+	AllowUnreleased bool
+}
+
+var (
+	// Debian is the Vendor for the Debian archive.
+	Debian = Vendor{
+		Name: "Debian",
+		Suites: []string{
+			"unstable", "sid", "experimental", "testing", "stable",
+			"oldstable", "oldoldstable", "forky", "trixie", "bookworm",
+			"bullseye", "buster", "stretch", "jessie",
+		},
+		Suffixes: []string{
+			"-security", "-updates", "-proposed-updates", "-backports",
+			"-backports-sloppy", "-lts", "-fasttrack",
+		},
+		AllowUnreleased: true,
+	}
+
+	// Ubuntu is the Vendor for the Ubuntu archive.
+	Ubuntu = Vendor{
+		Name: "Ubuntu",
+		Suites: []string{
+			"devel", "questing", "plucky", "oracular", "noble", "mantic",
+			"lunar", "kinetic", "jammy", "focal", "bionic", "xenial",
+		},
+		Suffixes: []string{
+			"-security", "-updates", "-proposed", "-backports",
+		},
+		AllowUnreleased: true,
+	}
+)
+
+// ValidDistribution checks if the entry may target the given
+// distribution.
+func (v Vendor) ValidDistribution(distribution string) bool {
+	if v.AllowUnreleased && distribution == "UNRELEASED" {
+		return true
+	}
+	for _, suite := range v.Suites {
+		if distribution == suite {
+			return true
+		}
+		if !strings.HasPrefix(distribution, suite) {
+			continue
+		}
+		for _, suffix := range v.Suffixes {
+			if distribution == suite+suffix {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Validate checks the Urgency and the distributions the entry targets.
+func (v Vendor) Validate(entry ChangelogEntry) error {
+	if _, err := entry.Urgency(); err != nil {
+		return fmt.Errorf("%s (%s): %s", entry.Source, entry.Version, err)
+	}
+	distributions := strings.Fields(entry.Target)
+	if len(distributions) == 0 {
+		return fmt.Errorf("%s (%s): No distribution", entry.Source, entry.Version)
+	}
+	for _, distribution := range distributions {
+		if !v.ValidDistribution(distribution) {
+			return fmt.Errorf(
+				"%s (%s): Unknown %s distribution '%s'",
+				entry.Source, entry.Version, v.Name, distribution,
+			)
+		}
+	}
+	return nil
+}
+
+// ParseOne parses a single entry, like the ParseOne function, and
+// validates it.
+func (v Vendor) ParseOne(reader *bufio.Reader) (*ChangelogEntry, error) {
+	entry, err := ParseOne(reader)
+	if err != nil {
+		return nil, err
+	}
+	if err := v.Validate(*entry); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// Parse parses all the entries, like the Parse function, and validates
+// them.
+func (v Vendor) Parse(reader io.Reader) (ChangelogEntries, error) {
+	entries, err := Parse(reader)
+	if err != nil {
+		return ChangelogEntries{}, err
+	}
+	for _, entry := range entries {
+		if err := v.Validate(entry); err != nil {
+			return ChangelogEntries{}, err
+		}
+	}
+	return entries, nil
+}
+
+// }}}
+
+// vim: foldmethod=marker
@@ -0,0 +1,88 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package changelog_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ebikt/go-debian/changelog"
+)
+
+/*
+ *
+ */
+
+func TestParseUrgency(t *testing.T) {
+	for value, urgency := range map[string]changelog.Urgency{
+		"low":                       changelog.UrgencyLow,
+		"MEDIUM":                    changelog.UrgencyMedium,
+		"high (for hurd-i386 only)": changelog.UrgencyHigh,
+		"emergency":                 changelog.UrgencyEmergency,
+		"critical":                  changelog.UrgencyCritical,
+	} {
+		parsed, err := changelog.ParseUrgency(value)
+		isok(t, err)
+		assert(t, parsed == urgency)
+	}
+
+	for _, value := range []string{"", "urgent", "lowish"} {
+		_, err := changelog.ParseUrgency(value)
+		notok(t, err)
+	}
+}
+
+func TestVendorDistributions(t *testing.T) {
+	for _, dist := range []string{"unstable", "UNRELEASED", "bookworm-security", "trixie-backports", "stable-proposed-updates"} {
+		assert(t, changelog.Debian.ValidDistribution(dist))
+	}
+	for _, dist := range []string{"unreleased", "bookworm-proposed", "jammy", "sid-foo"} {
+		assert(t, !changelog.Debian.ValidDistribution(dist))
+	}
+	assert(t, changelog.Ubuntu.ValidDistribution("jammy-proposed"))
+
+	vendor := changelog.Vendor{Name: "Acme", Suites: []string{"acme"}, Suffixes: []string{"-hotfix"}}
+	assert(t, vendor.ValidDistribution("acme-hotfix"))
+	assert(t, !vendor.ValidDistribution("UNRELEASED"))
+}
+
+func TestVendorParse(t *testing.T) {
+	entries, err := changelog.Debian.Parse(strings.NewReader(changeLog))
+	isok(t, err)
+	assert(t, len(entries) == 2)
+	urgency, err := entries[0].Urgency()
+	isok(t, err)
+	assert(t, urgency == changelog.UrgencyLow)
+
+	_, err = changelog.Ubuntu.Parse(strings.NewReader(changeLog))
+	notok(t, err)
+
+	_, err = changelog.Debian.Parse(strings.NewReader(strings.Replace(changeLog, "urgency=low", "urgency=soon", 1)))
+	notok(t, err)
+
+	_, err = changelog.Debian.Parse(strings.NewReader(strings.Replace(changeLog, ") unstable;", ") unstable bookworm-security;", 1)))
+	isok(t, err)
+
+	_, err = changelog.Debian.Parse(strings.NewReader(strings.Replace(changeLog, ") unstable;", ");", 1)))
+	notok(t, err)
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,150 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package changelog // import "github.com/ebikt/go-debian/changelog"
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ebikt/go-debian/version"
+)
+
+// Writing {{{
+
+// NewEntry creates a ChangelogEntry, with one "  * " bullet per change, as
+// `dch` does. The urgency defaults to medium, like `dch`, and the entry is
+// dated when it's added.
+func NewEntry(source string, ver version.Version, target, urgency, changedBy string, changes ...string) ChangelogEntry {
+	if urgency == "" {
+		urgency = string(UrgencyMedium)
+	}
+	body := "\n"
+	for _, change := range changes {
+		body += "  * " + change + "\n"
+	}
+	return ChangelogEntry{
+		Source:    source,
+		Version:   ver,
+		Target:    target,
+		Arguments: map[string]string{"urgency": urgency},
+		Changelog: body + "\n",
+		ChangedBy: changedBy,
+	}
+}
+
+// header renders the first line of the entry. The urgency comes first,
+// then the other options, sorted.
+func (c ChangelogEntry) header() string {
+	keys := []string{}
+	for key := range c.Arguments {
+		if key != "urgency" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	if _, ok := c.Arguments["urgency"]; ok {
+		keys = append([]string{"urgency"}, keys...)
+	}
+	options := []string{}
+	for _, key := range keys {
+		options = append(options, key+"="+c.Arguments[key])
+	}
+
+	ret := fmt.Sprintf("%s (%s) %s", c.Source, c.Version, strings.Join(c.Distributions(), " "))
+	if len(options) > 0 {
+		ret += "; " + strings.Join(options, ", ")
+	}
+	return ret
+}
+
+// String renders the entry the way `dch` writes it: the header, the
+// changes between blank lines, and the trailer line with the date in the
+// format of `date -R`.
+func (c ChangelogEntry) String() string {
+	lines := strings.Split(strings.TrimRight(c.Changelog, "\n\r\t "), "\n")
+	for len(lines) > 0 && trim(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	body := ""
+	for _, line := range lines {
+		body += strings.TrimRight(line, "\r\t ") + "\n"
+	}
+	return fmt.Sprintf(
+		"%s\n\n%s\n -- %s  %s\n",
+		c.header(), body, c.ChangedBy, c.When.Format(whenLayout),
+	)
+}
+
+// Add prepends an entry, which must be newer than the current top one. An
+// entry without a date is dated now.
+func (c *ChangelogEntries) Add(entry ChangelogEntry) error {
+	if len(*c) > 0 {
+		top := (*c)[0]
+		if version.Compare(entry.Version, top.Version) <= 0 {
+			return fmt.Errorf(
+				"Version %s is not newer than the current one, %s",
+				entry.Version, top.Version,
+			)
+		}
+	}
+	if entry.When.IsZero() {
+		entry.When = time.Now()
+	}
+	if entry.Arguments == nil {
+		entry.Arguments = map[string]string{}
+	}
+	*c = append(ChangelogEntries{entry}, *c...)
+	return nil
+}
+
+// WriteTo writes the entries out, separated by blank lines, as found in
+// debian/changelog.
+func (c ChangelogEntries) WriteTo(out io.Writer) (int64, error) {
+	written := int64(0)
+	for i, entry := range c {
+		data := entry.String()
+		if i > 0 {
+			data = "\n" + data
+		}
+		n, err := io.WriteString(out, data)
+		written += int64(n)
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+// Add validates the entry, as Validate does, before adding it to the
+// entries.
+func (v Vendor) Add(entries *ChangelogEntries, entry ChangelogEntry) error {
+	if err := v.Validate(entry); err != nil {
+		return err
+	}
+	return entries.Add(entry)
+}
+
+// }}}
+
+// vim: foldmethod=marker
@@ -0,0 +1,87 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package changelog_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ebikt/go-debian/changelog"
+	"github.com/ebikt/go-debian/version"
+)
+
+/*
+ *
+ */
+
+func TestChangelogRoundTrip(t *testing.T) {
+	entries, err := changelog.Parse(strings.NewReader(changeLog))
+	isok(t, err)
+	out := bytes.Buffer{}
+	n, err := entries.WriteTo(&out)
+	isok(t, err)
+	assert(t, n == int64(len(changeLog)))
+	assert(t, out.String() == changeLog)
+}
+
+func TestChangelogAdd(t *testing.T) {
+	entries, err := changelog.Parse(strings.NewReader(changeLog))
+	isok(t, err)
+
+	ver, err := version.Parse("2.10-2")
+	isok(t, err)
+	entry := changelog.NewEntry("hello", ver, "unstable", "", "Jane Doe <jane@example.org>",
+		"Fix the build with GCC 14.", "Bump Standards-Version.")
+	entry.When = time.Date(2024, 3, 5, 10, 0, 0, 0, time.FixedZone("", 3600))
+	isok(t, changelog.Debian.Add(&entries, entry))
+	assert(t, len(entries) == 3)
+
+	assert(t, entries[0].String() == `hello (2.10-2) unstable; urgency=medium
+
+  * Fix the build with GCC 14.
+  * Bump Standards-Version.
+
+ -- Jane Doe <jane@example.org>  Tue, 05 Mar 2024 10:00:00 +0100
+`)
+
+	out := bytes.Buffer{}
+	_, err = entries.WriteTo(&out)
+	isok(t, err)
+	assert(t, strings.HasSuffix(out.String(), "+0100\n\n"+changeLog))
+	reparsed, err := changelog.Parse(&out)
+	isok(t, err)
+	assert(t, len(reparsed) == 3)
+	assert(t, reparsed[0].Version.String() == "2.10-2")
+
+	/* Not newer */
+	notok(t, entries.Add(entry))
+	/* Not a Debian distribution */
+	ver, err = version.Parse("2.10-3")
+	isok(t, err)
+	entry = changelog.NewEntry("hello", ver, "acme", "low", "Jane Doe <jane@example.org>", "Oops.")
+	notok(t, changelog.Debian.Add(&entries, entry))
+	isok(t, entries.Add(entry))
+	assert(t, !entries[0].When.IsZero())
+}
+
+// vim: foldmethod=marker
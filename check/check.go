@@ -0,0 +1,211 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package check // import "github.com/ebikt/go-debian/check"
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/ebikt/go-debian/changelog"
+	"github.com/ebikt/go-debian/control"
+	"github.com/ebikt/go-debian/deb"
+)
+
+// Severity {{{
+
+// Severity of a Finding, from the most to the least severe.
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+	SeverityInfo    Severity = "info"
+)
+
+// AtLeast checks if the Severity is as severe as `other`, or more.
+func (s Severity) AtLeast(other Severity) bool {
+	return s.rank() >= other.rank()
+}
+
+/* The letter lintian prefixes its tags with */
+func (s Severity) code() string {
+	switch s {
+	case SeverityError:
+		return "E"
+	case SeverityWarning:
+		return "W"
+	}
+	return "I"
+}
+
+func (s Severity) rank() int {
+	switch s {
+	case SeverityError:
+		return 2
+	case SeverityWarning:
+		return 1
+	}
+	return 0
+}
+
+// }}}
+
+// Finding {{{
+
+// A Finding is an issue reported by a Check.
+type Finding struct {
+	// Name of the Check which reported it, filled in by Run.
+	Check    string   `json:"check"`
+	Severity Severity `json:"severity"`
+
+	// Package the issue is in, binary or source.
+	Package string `json:"package,omitempty"`
+
+	// Path of the file the issue is in, relative to the root of the
+	// data.tar, if any.
+	Path string `json:"path,omitempty"`
+
+	Message string `json:"message"`
+}
+
+// String renders the Finding as lintian does, such as
+// "W: hello: unstripped-binary usr/bin/hello: Not stripped".
+func (f Finding) String() string {
+	ret := fmt.Sprintf("%s: %s: %s", f.Severity.code(), f.Package, f.Check)
+	if f.Path != "" {
+		ret += " " + f.Path
+	}
+	return ret + ": " + f.Message
+}
+
+// }}}
+
+// Input {{{
+
+// Input is what the checks look at. Any of its fields may be unset, and
+// the checks skip what they need but isn't there.
+type Input struct {
+	Deb       *deb.Deb
+	Source    *control.SourceControl
+	DSC       *control.DSC
+	Changelog changelog.ChangelogEntries
+
+	data     *deb.DataFS
+	dataErr  error
+	dataOnce sync.Once
+}
+
+// Data returns the files of the data.tar of the Deb, which it reads the
+// first time it's called, or nil if there's no Deb.
+func (in *Input) Data() (*deb.DataFS, error) {
+	if in.Deb == nil {
+		return nil, nil
+	}
+	in.dataOnce.Do(func() {
+		in.data, in.dataErr = in.Deb.DataFS()
+	})
+	return in.data, in.dataErr
+}
+
+// }}}
+
+// Check {{{
+
+// A Check looks for one kind of issue in an Input.
+type Check interface {
+	// Name of the Check, a lintian-style tag, such as "missing-maintainer".
+	Name() string
+
+	// Run returns the issues found in the Input. An error means the Input
+	// couldn't be looked at, not that an issue was found.
+	Run(in *Input) ([]Finding, error)
+}
+
+type funcCheck struct {
+	name string
+	fn   func(*Input) ([]Finding, error)
+}
+
+func (c funcCheck) Name() string {
+	return c.name
+}
+
+func (c funcCheck) Run(in *Input) ([]Finding, error) {
+	return c.fn(in)
+}
+
+// Func returns a Check named `name` which runs `fn`.
+func Func(name string, fn func(*Input) ([]Finding, error)) Check {
+	return funcCheck{name: name, fn: fn}
+}
+
+// The checks Run runs by default, keyed on their names.
+var (
+	registryLock sync.RWMutex
+	registry     = map[string]Check{}
+)
+
+// Register registers a Check to be run by default, replacing the one
+// with the same name if any.
+func Register(check Check) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	registry[check.Name()] = check
+}
+
+// Registered returns the registered checks, sorted by name.
+func Registered() []Check {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+	ret := []Check{}
+	for _, check := range registry {
+		ret = append(ret, check)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Name() < ret[j].Name()
+	})
+	return ret
+}
+
+// Run runs the given checks over the Input, or the registered ones if
+// none is given, and returns all their findings, with their Check set.
+func Run(in *Input, checks ...Check) ([]Finding, error) {
+	if len(checks) == 0 {
+		checks = Registered()
+	}
+	ret := []Finding{}
+	for _, check := range checks {
+		findings, err := check.Run(in)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", check.Name(), err)
+		}
+		for _, finding := range findings {
+			finding.Check = check.Name()
+			ret = append(ret, finding)
+		}
+	}
+	return ret, nil
+}
+
+// }}}
+
+// vim: foldmethod=marker
@@ -0,0 +1,245 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package check_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"encoding/json"
+	"log"
+	"os"
+	"runtime/debug"
+	"strings"
+	"testing"
+
+	"github.com/ebikt/go-debian/changelog"
+	"github.com/ebikt/go-debian/check"
+	"github.com/ebikt/go-debian/control"
+	"github.com/ebikt/go-debian/deb"
+	"github.com/ebikt/go-debian/version"
+)
+
+/*
+ *
+ */
+
+func isok(t *testing.T, err error) {
+	if err != nil {
+		log.Printf("Error! Error is not nil! %s\n", err)
+		debug.PrintStack()
+		t.FailNow()
+	}
+}
+
+func notok(t *testing.T, err error) {
+	if err == nil {
+		log.Printf("Error! Error is nil!\n")
+		debug.PrintStack()
+		t.FailNow()
+	}
+}
+
+func assert(t *testing.T, expr bool) {
+	if !expr {
+		log.Printf("Assertion failed!")
+		debug.PrintStack()
+		t.FailNow()
+	}
+}
+
+/*
+ *
+ */
+
+func makeDeb(t *testing.T, controlFile string, files map[string][]byte) *deb.Deb {
+	out := bytes.Buffer{}
+	w := tar.NewWriter(&out)
+	for name, data := range files {
+		isok(t, w.WriteHeader(&tar.Header{Name: "./" + name, Mode: 0755, Size: int64(len(data))}))
+		_, err := w.Write(data)
+		isok(t, err)
+	}
+	isok(t, w.Close())
+	ret := deb.Deb{Data: tar.NewReader(&out)}
+	isok(t, control.Unmarshal(&ret.Control, strings.NewReader(controlFile)))
+	return &ret
+}
+
+/* An ELF object with the given sections, all empty */
+func elfObject(t *testing.T, sections ...string) []byte {
+	names := []byte("\x00.shstrtab\x00")
+	headers := []elf.Section64{{}, {Name: 1, Type: uint32(elf.SHT_STRTAB), Off: 64}}
+	for _, section := range sections {
+		kind := elf.SHT_PROGBITS
+		if section == ".symtab" {
+			kind = elf.SHT_SYMTAB
+		}
+		headers = append(headers, elf.Section64{Name: uint32(len(names)), Type: uint32(kind)})
+		names = append(append(names, section...), 0)
+	}
+	headers[1].Size = uint64(len(names))
+
+	header := elf.Header64{
+		Type:      uint16(elf.ET_EXEC),
+		Machine:   uint16(elf.EM_X86_64),
+		Version:   uint32(elf.EV_CURRENT),
+		Ehsize:    64,
+		Shoff:     uint64(64 + len(names)),
+		Shentsize: 64,
+		Shnum:     uint16(len(headers)),
+		Shstrndx:  1,
+	}
+	copy(header.Ident[:], elf.ELFMAG)
+	header.Ident[elf.EI_CLASS] = byte(elf.ELFCLASS64)
+	header.Ident[elf.EI_DATA] = byte(elf.ELFDATA2LSB)
+	header.Ident[elf.EI_VERSION] = byte(elf.EV_CURRENT)
+
+	out := bytes.Buffer{}
+	isok(t, binary.Write(&out, binary.LittleEndian, header))
+	out.Write(names)
+	isok(t, binary.Write(&out, binary.LittleEndian, headers))
+	return out.Bytes()
+}
+
+func TestMissingMaintainer(t *testing.T) {
+	in := check.Input{
+		Deb: makeDeb(t, "Package: hello\nVersion: 1.0-1\nArchitecture: amd64\n", nil),
+		DSC: &control.DSC{Source: "hello", Maintainer: "Jane Doe <jane@example.org>"},
+	}
+	findings, err := check.Run(&in, check.MissingMaintainer)
+	isok(t, err)
+	assert(t, len(findings) == 1)
+	assert(t, findings[0].Check == "missing-maintainer")
+	assert(t, findings[0].Severity == check.SeverityError)
+	assert(t, findings[0].Package == "hello")
+	assert(t, findings[0].String() == "E: hello: missing-maintainer: No Maintainer in the control file")
+}
+
+func TestBadSection(t *testing.T) {
+	assert(t, check.ValidSection("utils"))
+	assert(t, check.ValidSection("non-free/games"))
+	assert(t, check.ValidSection("golang"))
+	assert(t, check.ValidSection("contrib/rust"))
+	assert(t, !check.ValidSection("utilities"))
+	assert(t, !check.ValidSection("universe/utils"))
+
+	source := control.Control{}
+	source.Source.Source = "hello"
+	source.Source.Section = "contrib/utils"
+	source.Binaries = []control.BinaryParagraph{
+		{Package: "hello"},
+		{Package: "hello-data", Section: "data"},
+	}
+	findings, err := check.Run(&check.Input{Source: &source}, check.BadSection)
+	isok(t, err)
+	assert(t, len(findings) == 1)
+	assert(t, findings[0].Package == "hello-data")
+	assert(t, findings[0].Severity == check.SeverityWarning)
+}
+
+func TestBadPriority(t *testing.T) {
+	source := control.Control{}
+	source.Source.Source = "hello"
+	source.Source.Priority = "optional"
+	source.Binaries = []control.BinaryParagraph{
+		{Package: "hello", Priority: "extra"},
+		{Package: "hello-data", Priority: "low"},
+	}
+	findings, err := check.Run(&check.Input{Source: &source}, check.BadPriority)
+	isok(t, err)
+	assert(t, len(findings) == 2)
+	assert(t, findings[0].Package == "hello")
+	assert(t, findings[0].Severity == check.SeverityInfo)
+	assert(t, findings[1].Package == "hello-data")
+	assert(t, findings[1].Severity == check.SeverityWarning)
+}
+
+func TestUnstrippedBinary(t *testing.T) {
+	unstripped := elfObject(t, ".text", ".symtab")
+
+	in := check.Input{Deb: makeDeb(t,
+		"Package: hello\nVersion: 1.0-1\nArchitecture: amd64\nMaintainer: Jane Doe <jane@example.org>\n",
+		map[string][]byte{
+			"usr/bin/hello":                   unstripped,
+			"usr/bin/hi":                      elfObject(t, ".text"),
+			"usr/bin/hey":                     []byte("#!/bin/sh\n"),
+			"usr/lib/debug/.build-id/ab/cdef": unstripped,
+		},
+	)}
+	findings, err := check.Run(&in)
+	isok(t, err)
+	assert(t, len(findings) == 1)
+	assert(t, findings[0].Check == "unstripped-binary")
+	assert(t, findings[0].Path == "usr/bin/hello")
+}
+
+func TestChangelogVersionMismatch(t *testing.T) {
+	dscVersion, err := version.Parse("1.0-2")
+	isok(t, err)
+	changelogVersion, err := version.Parse("1.0-1")
+	isok(t, err)
+
+	in := check.Input{
+		DSC: &control.DSC{Source: "hello", Version: dscVersion},
+		Changelog: changelog.ChangelogEntries{
+			{Source: "hello", Version: changelogVersion},
+		},
+	}
+	findings, err := check.Run(&in, check.ChangelogVersionMismatch)
+	isok(t, err)
+	assert(t, len(findings) == 1)
+	assert(t, findings[0].Message == "Version is 1.0-2 in the .dsc, but 1.0-1 in the changelog")
+
+	in.Changelog[0].Version = dscVersion
+	findings, err = check.Run(&in, check.ChangelogVersionMismatch)
+	isok(t, err)
+	assert(t, len(findings) == 0)
+}
+
+func TestRegister(t *testing.T) {
+	custom := check.Func("custom-failure", func(in *check.Input) ([]check.Finding, error) {
+		return nil, os.ErrInvalid
+	})
+	check.Register(custom)
+	names := []string{}
+	for _, registered := range check.Registered() {
+		names = append(names, registered.Name())
+	}
+	assert(t, strings.Join(names, " ") ==
+		"bad-priority bad-section changelog-version-mismatch custom-failure missing-maintainer unstripped-binary")
+
+	_, err := check.Run(&check.Input{})
+	notok(t, err)
+
+	findings, err := check.Run(&check.Input{}, check.MissingMaintainer, check.BadSection)
+	isok(t, err)
+	assert(t, len(findings) == 0)
+
+	encoded, err := json.Marshal(check.Finding{Check: "bad-section", Severity: check.SeverityWarning, Message: "Oops"})
+	isok(t, err)
+	assert(t, string(encoded) == `{"check":"bad-section","severity":"warning","message":"Oops"}`)
+	assert(t, check.SeverityError.AtLeast(check.SeverityWarning))
+	assert(t, !check.SeverityInfo.AtLeast(check.SeverityWarning))
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,7 @@
+/*
+
+This module runs lintian-style checks over the parsed .deb files, source
+control files, .dsc files and changelogs, and reports what they find.
+
+*/
+package check // import "github.com/ebikt/go-debian/check"
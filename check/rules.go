@@ -0,0 +1,248 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package check // import "github.com/ebikt/go-debian/check"
+
+import (
+	"bytes"
+	"debug/elf"
+	"fmt"
+	"io/fs"
+
+	"github.com/ebikt/go-debian/control"
+	"github.com/ebikt/go-debian/version"
+)
+
+// Core checks {{{
+
+var (
+	// MissingMaintainer reports the .deb, source control file and .dsc
+	// without a Maintainer.
+	MissingMaintainer = Func("missing-maintainer", missingMaintainer)
+
+	// BadSection reports the sections which aren't one of the archive's
+	// Sections, optionally prefixed with one of its Components.
+	BadSection = Func("bad-section", badSection)
+
+	// UnstrippedBinary reports the ELF objects of the .deb with a symbol
+	// table, outside of /usr/lib/debug.
+	UnstrippedBinary = Func("unstripped-binary", unstrippedBinary)
+
+	// BadPriority reports the priorities which aren't one of Debian Policy,
+	// and the deprecated "extra" one.
+	BadPriority = Func("bad-priority", badPriority)
+
+	// ChangelogVersionMismatch reports the .dsc whose source or version
+	// aren't the ones of the latest changelog entry.
+	ChangelogVersionMismatch = Func("changelog-version-mismatch", changelogVersionMismatch)
+)
+
+func init() {
+	for _, check := range []Check{
+		MissingMaintainer, BadSection, BadPriority, UnstrippedBinary, ChangelogVersionMismatch,
+	} {
+		Register(check)
+	}
+}
+
+// }}}
+
+// missing-maintainer {{{
+
+func missingMaintainer(in *Input) ([]Finding, error) {
+	ret := []Finding{}
+	missing := func(pkg, what string) {
+		ret = append(ret, Finding{
+			Severity: SeverityError,
+			Package:  pkg,
+			Message:  fmt.Sprintf("No Maintainer in the %s", what),
+		})
+	}
+	if in.Deb != nil && in.Deb.Control.Maintainer == "" {
+		missing(in.Deb.Control.Package, "control file")
+	}
+	if in.Source != nil && in.Source.Source.Maintainer == "" {
+		missing(in.Source.Source.Source, "source control file")
+	}
+	if in.DSC != nil && in.DSC.Maintainer == "" {
+		missing(in.DSC.Source, ".dsc")
+	}
+	return ret, nil
+}
+
+// }}}
+
+// bad-section {{{
+
+// ValidSection checks if the section is one of the known sections of
+// control.KnownSections, optionally prefixed with one of the
+// control.KnownComponents, such as "contrib/games". More of them are
+// registered with control.RegisterSection and control.RegisterComponent.
+func ValidSection(section string) bool {
+	_, err := control.ParseSection(section)
+	return err == nil
+}
+
+func badSection(in *Input) ([]Finding, error) {
+	ret := []Finding{}
+	check := func(pkg, section string) {
+		if section == "" || ValidSection(section) {
+			return
+		}
+		ret = append(ret, Finding{
+			Severity: SeverityWarning,
+			Package:  pkg,
+			Message:  fmt.Sprintf("Unknown section '%s'", section),
+		})
+	}
+	if in.Deb != nil {
+		check(in.Deb.Control.Package, in.Deb.Control.Section)
+	}
+	if in.Source != nil {
+		check(in.Source.Source.Source, in.Source.Source.Section)
+		for _, binary := range in.Source.Binaries {
+			check(binary.Package, binary.Section)
+		}
+	}
+	return ret, nil
+}
+
+// }}}
+
+// bad-priority {{{
+
+func badPriority(in *Input) ([]Finding, error) {
+	ret := []Finding{}
+	check := func(pkg, value string) {
+		if value == "" {
+			return
+		}
+		priority, err := control.ParsePriority(value)
+		switch {
+		case err != nil:
+			ret = append(ret, Finding{
+				Severity: SeverityWarning,
+				Package:  pkg,
+				Message:  fmt.Sprintf("Unknown priority '%s'", value),
+			})
+		case priority.Deprecated():
+			ret = append(ret, Finding{
+				Severity: SeverityInfo,
+				Package:  pkg,
+				Message:  fmt.Sprintf("Deprecated priority '%s'", value),
+			})
+		}
+	}
+	if in.Deb != nil {
+		check(in.Deb.Control.Package, in.Deb.Control.Priority)
+	}
+	if in.Source != nil {
+		check(in.Source.Source.Source, in.Source.Source.Priority)
+		for _, binary := range in.Source.Binaries {
+			check(binary.Package, binary.Priority)
+		}
+	}
+	return ret, nil
+}
+
+// }}}
+
+// unstripped-binary {{{
+
+func unstrippedBinary(in *Input) ([]Finding, error) {
+	data, err := in.Data()
+	if data == nil || err != nil {
+		return nil, err
+	}
+	ret := []Finding{}
+	err = fs.WalkDir(data, ".", func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() && path == "usr/lib/debug" {
+			return fs.SkipDir
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		contents, err := data.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if !bytes.HasPrefix(contents, []byte(elf.ELFMAG)) {
+			return nil
+		}
+		object, err := elf.NewFile(bytes.NewReader(contents))
+		if err != nil {
+			/* Not for this check to tell */
+			return nil
+		}
+		if object.Section(".symtab") != nil {
+			ret = append(ret, Finding{
+				Severity: SeverityWarning,
+				Package:  in.Deb.Control.Package,
+				Path:     path,
+				Message:  "Not stripped",
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// }}}
+
+// changelog-version-mismatch {{{
+
+func changelogVersionMismatch(in *Input) ([]Finding, error) {
+	if in.DSC == nil || len(in.Changelog) == 0 {
+		return nil, nil
+	}
+	ret := []Finding{}
+	latest := in.Changelog[0]
+	if latest.Source != in.DSC.Source {
+		ret = append(ret, Finding{
+			Severity: SeverityError,
+			Package:  in.DSC.Source,
+			Message: fmt.Sprintf(
+				"Source is '%s' in the .dsc, but '%s' in the changelog",
+				in.DSC.Source, latest.Source,
+			),
+		})
+	}
+	if version.Compare(latest.Version, in.DSC.Version) != 0 {
+		ret = append(ret, Finding{
+			Severity: SeverityError,
+			Package:  in.DSC.Source,
+			Message: fmt.Sprintf(
+				"Version is %s in the .dsc, but %s in the changelog",
+				in.DSC.Version, latest.Version,
+			),
+		})
+	}
+	return ret, nil
+}
+
+// }}}
+
+// vim: foldmethod=marker
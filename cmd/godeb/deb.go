@@ -0,0 +1,263 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package main // import "github.com/ebikt/go-debian/cmd/godeb"
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ebikt/go-debian/control"
+	"github.com/ebikt/go-debian/deb"
+)
+
+// inspect {{{
+
+// A debFile is a file of the data member of a .deb.
+type debFile struct {
+	Path string `json:"path"`
+	Mode string `json:"mode"`
+	User string `json:"user"`
+	Size int64  `json:"size"`
+	Link string `json:"link,omitempty"`
+}
+
+// inspect shows the control file of a .deb, and lists its files, as
+// dpkg-deb --info and --contents do.
+func inspect(args []string, out io.Writer) error {
+	set, asJSON := flags("inspect")
+	args, err := parse(set, args, 1, 1)
+	if err != nil {
+		return err
+	}
+	debFile, closer, err := deb.LoadFile(args[0])
+	if err != nil {
+		return err
+	}
+	defer closer()
+	files, err := debFiles(debFile.Data)
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		return writeJSON(out, map[string]interface{}{
+			"control": debFile.Control.Paragraph,
+			"files":   files,
+		})
+	}
+	if err := debFile.Control.Paragraph.WriteTo(out); err != nil {
+		return err
+	}
+	fmt.Fprintln(out)
+	for _, file := range files {
+		link := ""
+		if file.Link != "" {
+			link = " -> " + file.Link
+		}
+		fmt.Fprintf(out, "%s %s %8d %s%s\n", file.Mode, file.User, file.Size, file.Path, link)
+	}
+	return nil
+}
+
+// debFiles lists the files of the data member.
+func debFiles(data *tar.Reader) ([]debFile, error) {
+	ret := []debFile{}
+	for {
+		header, err := data.Next()
+		if err == io.EOF {
+			return ret, nil
+		} else if err != nil {
+			return nil, err
+		}
+		ret = append(ret, debFile{
+			Path: header.Name,
+			Mode: header.FileInfo().Mode().String(),
+			User: fmt.Sprintf("%s/%s", header.Uname, header.Gname),
+			Size: header.Size,
+			Link: header.Linkname,
+		})
+	}
+}
+
+// }}}
+
+// extract {{{
+
+// extracted records the files written by an extraction.
+type extracted struct {
+	files []string
+}
+
+func (e *extracted) Start(item string, size int64) {}
+
+func (e *extracted) Advance(item string, n int64) {}
+
+func (e *extracted) Done(item string, err error) {
+	if err == nil {
+		e.files = append(e.files, item)
+	}
+}
+
+// extract writes the files of a .deb to a directory, as dpkg-deb
+// --extract does, listing the regular files written.
+func extract(args []string, out io.Writer) error {
+	set, asJSON := flags("extract")
+	args, err := parse(set, args, 2, 2)
+	if err != nil {
+		return err
+	}
+	debFile, closer, err := deb.LoadFile(args[0])
+	if err != nil {
+		return err
+	}
+	defer closer()
+	progress := extracted{files: []string{}}
+	if err := debFile.Extract(args[1], &deb.ExtractPolicy{Progress: &progress}); err != nil {
+		return err
+	}
+
+	if *asJSON {
+		return writeJSON(out, map[string]interface{}{
+			"directory": args[1],
+			"files":     progress.files,
+		})
+	}
+	for _, file := range progress.files {
+		fmt.Fprintln(out, file)
+	}
+	return nil
+}
+
+// }}}
+
+// build {{{
+
+// build builds a .deb out of a directory, as dpkg-deb --build does: the
+// control file and maintainer scripts are read from its DEBIAN directory,
+// and the timestamps follow SOURCE_DATE_EPOCH.
+func build(args []string, out io.Writer) error {
+	set, asJSON := flags("build")
+	compression := set.String("compression", "", `compression of the members: "gz", "xz", "zst" or "none"`)
+	args, err := parse(set, args, 2, 2)
+	if err != nil {
+		return err
+	}
+	root, filename := args[0], args[1]
+
+	builder, err := debianBuilder(filepath.Join(root, "DEBIAN"))
+	if err != nil {
+		return err
+	}
+	builder.Compression = *compression
+	if err := builder.UseSourceDateEpoch(os.Getenv); err != nil {
+		return err
+	}
+	if err := builder.AddTree(root); err != nil {
+		return err
+	}
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	err = builder.Build(file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(filename)
+		return err
+	}
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	para := builder.Control
+	if *asJSON {
+		return writeJSON(out, map[string]interface{}{
+			"filename":     filename,
+			"package":      para.Get("Package"),
+			"version":      para.Get("Version"),
+			"architecture": para.Get("Architecture"),
+			"size":         len(data),
+			"sha256":       fmt.Sprintf("%x", sha256.Sum256(data)),
+		})
+	}
+	fmt.Fprintf(out, "Built %s %s (%s) in %s\n", para.Get("Package"), para.Get("Version"), para.Get("Architecture"), filename)
+	return nil
+}
+
+// debianBuilder creates the Builder of the DEBIAN directory of a package:
+// its control file, maintainer scripts, conffiles, and other control
+// files.
+func debianBuilder(dir string) (*deb.Builder, error) {
+	in, err := os.Open(filepath.Join(dir, "control"))
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+	reader, err := control.NewParagraphReader(in, nil)
+	if err != nil {
+		return nil, err
+	}
+	para, err := reader.Next()
+	if err != nil {
+		return nil, fmt.Errorf("Can't read %s/control: %s", dir, err)
+	}
+
+	builder := deb.NewBuilder(*para)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || name == "control" || name == "md5sums" {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		switch name {
+		case "preinst", "postinst", "prerm", "postrm", "config":
+			builder.Scripts[name] = data
+		case "conffiles":
+			for _, line := range strings.Split(string(data), "\n") {
+				if line = strings.TrimSpace(line); line != "" {
+					builder.Conffiles = append(builder.Conffiles, line)
+				}
+			}
+		default:
+			builder.ControlFiles[name] = data
+		}
+	}
+	return builder, nil
+}
+
+// }}}
+
+// vim: foldmethod=marker
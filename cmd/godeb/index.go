@@ -0,0 +1,193 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package main // import "github.com/ebikt/go-debian/cmd/godeb"
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ebikt/go-debian/apt"
+	"github.com/ebikt/go-debian/archive"
+	"github.com/ebikt/go-debian/control"
+	"github.com/ebikt/go-debian/deb"
+	"github.com/ebikt/go-debian/hashio"
+)
+
+// resolve-depends {{{
+
+// readPackages reads a Packages index, compressed or not.
+func readPackages(path string) ([]apt.Package, error) {
+	in, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+	reader, err := hashio.NewDecompressingReader(in)
+	if err != nil {
+		return nil, err
+	}
+	return apt.ReadPackages(reader)
+}
+
+// resolveDepends resolves the installation of packages, as apt-get
+// install would, out of Packages indexes and the dpkg status database.
+func resolveDepends(args []string, out io.Writer) error {
+	set, asJSON := flags("resolve-depends")
+	indexes := stringList{}
+	set.Var(&indexes, "packages", "Packages index of the available packages, given once per index")
+	status := set.String("status", "", "dpkg status database of the installed packages")
+	arch := set.String("arch", "", "native architecture")
+	recommends := set.Bool("recommends", false, "install the Recommends too")
+	args, err := parse(set, args, 1, -1)
+	if err != nil {
+		return err
+	}
+
+	resolver := apt.Resolver{Architecture: *arch, InstallRecommends: *recommends}
+	for _, index := range indexes {
+		packages, err := readPackages(index)
+		if err != nil {
+			return fmt.Errorf("%s: %s", index, err)
+		}
+		resolver.Available = append(resolver.Available, packages...)
+	}
+	if *status != "" {
+		if resolver.Installed, err = apt.LoadInstalled(*status); err != nil {
+			return err
+		}
+	}
+	solution, err := resolver.Install(args...)
+	if err != nil {
+		return err
+	}
+
+	type installJSON struct {
+		Package      string `json:"package"`
+		Version      string `json:"version"`
+		Architecture string `json:"architecture"`
+	}
+	install := []installJSON{}
+	for _, pkg := range solution.Install {
+		install = append(install, installJSON{pkg.Name, pkg.Version.String(), pkg.Architecture})
+	}
+	suggested := []string{}
+	for _, suggestion := range solution.Suggested {
+		suggested = append(suggested, suggestion.String())
+	}
+	if *asJSON {
+		return writeJSON(out, map[string]interface{}{
+			"install":   install,
+			"suggested": suggested,
+		})
+	}
+	for _, pkg := range install {
+		fmt.Fprintf(out, "%s %s %s\n", pkg.Package, pkg.Version, pkg.Architecture)
+	}
+	for _, suggestion := range suggested {
+		fmt.Fprintf(out, "Suggested: %s\n", suggestion)
+	}
+	return nil
+}
+
+// }}}
+
+// gen-index {{{
+
+// genIndex writes the Packages index of the .debs and .udebs found under
+// a directory, as dpkg-scanpackages does, their Filename relative to its
+// parent, such as "pool/main/h/hello/hello_2.10-3_amd64.deb" when
+// scanning "pool".
+func genIndex(args []string, out io.Writer) error {
+	set, asJSON := flags("gen-index")
+	args, err := parse(set, args, 1, 1)
+	if err != nil {
+		return err
+	}
+	root := filepath.Clean(args[0])
+
+	files := []string{}
+	err = filepath.Walk(root, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() && (strings.HasSuffix(file, ".deb") || strings.HasSuffix(file, ".udeb")) {
+			files = append(files, file)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	sort.Strings(files)
+
+	paras := []control.Paragraph{}
+	for _, file := range files {
+		rel, err := filepath.Rel(filepath.Dir(root), file)
+		if err != nil {
+			return err
+		}
+		para, err := indexParagraph(file, filepath.ToSlash(rel))
+		if err != nil {
+			return fmt.Errorf("%s: %s", file, err)
+		}
+		paras = append(paras, *para)
+	}
+
+	if *asJSON {
+		return writeJSON(out, paras)
+	}
+	writer := archive.NewIndexWriter(out)
+	for _, para := range paras {
+		if err := writer.Write(para); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// indexParagraph reads the control file of a .deb into the paragraph of
+// its Packages entry.
+func indexParagraph(file, filename string) (*control.Paragraph, error) {
+	debFile, closer, err := deb.LoadFileHashed(file, "md5", "sha256")
+	if err != nil {
+		return nil, err
+	}
+	defer closer()
+	hashes, err := debFile.Checksums()
+	if err != nil {
+		return nil, err
+	}
+	para := control.NewParagraph()
+	para = debFile.Control.Paragraph.Update(para)
+	para.Set("Filename", filename)
+	para.Set("Size", fmt.Sprint(hashes[0].Size))
+	para.Set("MD5sum", hashes[0].Hash)
+	para.Set("SHA256", hashes[1].Hash)
+	return &para, nil
+}
+
+// }}}
+
+// vim: foldmethod=marker
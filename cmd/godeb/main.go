@@ -0,0 +1,159 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+// Command godeb exposes the go-debian packages on the command line, to
+// script them directly:
+//
+//   godeb inspect hello_2.10-3_amd64.deb
+//   godeb extract hello_2.10-3_amd64.deb hello/
+//   godeb build debian/hello hello_2.10-3_amd64.deb
+//   godeb verify-sig --keyring debian-archive-keyring.gpg InRelease
+//   godeb resolve-depends --packages Packages --status /var/lib/dpkg/status hello
+//   godeb gen-index pool > Packages
+//
+// Every command takes a --json flag, to write its outcome as JSON rather
+// than as text.
+package main // import "github.com/ebikt/go-debian/cmd/godeb"
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// Commands {{{
+
+// A command runs a godeb subcommand with its arguments, writing its
+// outcome to out.
+type command func(args []string, out io.Writer) error
+
+var commands = map[string]command{
+	"inspect":         inspect,
+	"extract":         extract,
+	"build":           build,
+	"verify-sig":      verifySig,
+	"resolve-depends": resolveDepends,
+	"gen-index":       genIndex,
+}
+
+// The arguments each command takes.
+var usages = map[string]string{
+	"inspect":         "[--json] FILE.deb",
+	"extract":         "[--json] FILE.deb DIRECTORY",
+	"build":           "[--json] [--compression xz] DIRECTORY FILE.deb",
+	"verify-sig":      "[--json] --keyring KEYRING FILE [SIGNATURE]",
+	"resolve-depends": "[--json] --packages Packages... [--status FILE] [--arch ARCH] PACKAGE...",
+	"gen-index":       "[--json] DIRECTORY",
+}
+
+func usage(out io.Writer) {
+	names := []string{}
+	for name := range usages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Fprintf(out, "Usage:\n")
+	for _, name := range names {
+		fmt.Fprintf(out, "  godeb %s %s\n", name, usages[name])
+	}
+}
+
+// run runs the godeb command line, without the program name.
+func run(args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf("No command given, see godeb help")
+	}
+	if args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		usage(out)
+		return nil
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		return fmt.Errorf("Unknown command '%s', see godeb help", args[0])
+	}
+	return cmd(args[1:], out)
+}
+
+// }}}
+
+// Flags {{{
+
+// flags creates the flag set of a command, with its --json flag.
+func flags(name string) (*flag.FlagSet, *bool) {
+	set := flag.NewFlagSet(name, flag.ContinueOnError)
+	set.Usage = func() {
+		fmt.Fprintf(set.Output(), "Usage: godeb %s %s\n", name, usages[name])
+		set.PrintDefaults()
+	}
+	return set, set.Bool("json", false, "write the outcome as JSON")
+}
+
+// parse parses the flags of a command, which takes between min and max
+// arguments, max being -1 for no limit.
+func parse(set *flag.FlagSet, args []string, min, max int) ([]string, error) {
+	if err := set.Parse(args); err != nil {
+		return nil, err
+	}
+	if set.NArg() < min || max >= 0 && set.NArg() > max {
+		set.Usage()
+		return nil, fmt.Errorf("Wrong number of arguments to %s", set.Name())
+	}
+	return set.Args(), nil
+}
+
+// stringList is a flag given several times.
+type stringList []string
+
+func (l *stringList) String() string {
+	return fmt.Sprint(*l)
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// }}}
+
+// Output {{{
+
+// writeJSON writes the value as indented JSON.
+func writeJSON(out io.Writer, value interface{}) error {
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
+
+// }}}
+
+func main() {
+	err := run(os.Args[1:], os.Stdout)
+	switch {
+	case err == flag.ErrHelp:
+	case err != nil:
+		fmt.Fprintf(os.Stderr, "godeb: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+// vim: foldmethod=marker
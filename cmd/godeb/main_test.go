@@ -0,0 +1,179 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/packet"
+
+	"github.com/ebikt/go-debian/control"
+	"github.com/ebikt/go-debian/testutil"
+)
+
+func isok(t *testing.T, err error) {
+	if err != nil {
+		log.Printf("Error! Error is not nil! %s\n", err)
+		debug.PrintStack()
+		t.FailNow()
+	}
+}
+
+func notok(t *testing.T, err error) {
+	if err == nil {
+		log.Printf("Error! Error is nil!\n")
+		debug.PrintStack()
+		t.FailNow()
+	}
+}
+
+func assert(t *testing.T, expr bool) {
+	if !expr {
+		log.Printf("Assertion failed!")
+		debug.PrintStack()
+		t.FailNow()
+	}
+}
+
+/*
+ *
+ */
+
+// godeb runs the command line, returning its output.
+func godeb(t *testing.T, args ...string) string {
+	out := bytes.Buffer{}
+	isok(t, run(args, &out))
+	return out.String()
+}
+
+// writeTree writes the files, by their path relative to dir.
+func writeTree(t *testing.T, dir string, files map[string]string) {
+	for name, data := range files {
+		isok(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755))
+		isok(t, os.WriteFile(filepath.Join(dir, name), []byte(data), 0644))
+	}
+}
+
+func TestCommands(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "hello")
+	writeTree(t, root, map[string]string{
+		"DEBIAN/control": `Package: hello
+Version: 2.10-3
+Architecture: amd64
+Maintainer: Test Maintainer <test@example.com>
+Depends: libhello1
+Description: hello test package
+`,
+		"usr/share/doc/hello/README": "hello\n",
+	})
+	writeTree(t, filepath.Join(dir, "libhello1"), map[string]string{
+		"DEBIAN/control": `Package: libhello1
+Version: 1.0-1
+Architecture: amd64
+Maintainer: Test Maintainer <test@example.com>
+Description: hello test library
+`,
+	})
+	pool := filepath.Join(dir, "pool")
+	isok(t, os.Mkdir(pool, 0755))
+	helloDeb := filepath.Join(pool, "hello_2.10-3_amd64.deb")
+
+	built := map[string]interface{}{}
+	isok(t, json.Unmarshal([]byte(godeb(t, "build", "--json", root, helloDeb)), &built))
+	assert(t, built["package"] == "hello")
+	assert(t, len(built["sha256"].(string)) == 64)
+	assert(t, strings.HasPrefix(godeb(t, "build", filepath.Join(dir, "libhello1"), filepath.Join(pool, "libhello1_1.0-1_amd64.deb")),
+		"Built libhello1 1.0-1 (amd64)"))
+
+	inspected := godeb(t, "inspect", helloDeb)
+	assert(t, strings.HasPrefix(inspected, "Package: hello\n"))
+	assert(t, strings.Contains(inspected, " ./usr/share/doc/hello/README\n"))
+
+	extracted := struct{ Files []string }{}
+	isok(t, json.Unmarshal([]byte(godeb(t, "extract", "--json", helloDeb, filepath.Join(dir, "out"))), &extracted))
+	assert(t, len(extracted.Files) == 1 && extracted.Files[0] == "./usr/share/doc/hello/README")
+	_, err := os.Stat(filepath.Join(dir, "out/usr/share/doc/hello/README"))
+	isok(t, err)
+
+	index := godeb(t, "gen-index", pool)
+	assert(t, strings.Contains(index, "Filename: pool/hello_2.10-3_amd64.deb\n"))
+	packages := filepath.Join(dir, "Packages")
+	isok(t, os.WriteFile(packages, []byte(index), 0644))
+
+	assert(t, godeb(t, "resolve-depends", "--packages", packages, "hello") == "hello 2.10-3 amd64\nlibhello1 1.0-1 amd64\n")
+	notok(t, run([]string{"resolve-depends", "--packages", packages, "missing"}, &bytes.Buffer{}))
+
+	/* Signatures, clearsigned or detached */
+	key, err := testutil.NewKey("Test Archive", "archive@example.org")
+	isok(t, err)
+	armoredKey, err := testutil.ArmoredPublicKey(key)
+	isok(t, err)
+	keyring := filepath.Join(dir, "keyring.asc")
+	isok(t, os.WriteFile(keyring, armoredKey, 0644))
+	inRelease, err := testutil.ClearSign(key, []byte("Suite: stable\n"))
+	isok(t, err)
+	signature, err := testutil.DetachSign(key, []byte("Suite: stable\n"))
+	isok(t, err)
+	writeTree(t, dir, map[string]string{
+		"InRelease":   string(inRelease),
+		"Release":     "Suite: stable\n",
+		"Release.gpg": string(signature),
+		"Tampered":    "Suite: unstable\n",
+	})
+	verified := godeb(t, "verify-sig", "--keyring", keyring, filepath.Join(dir, "InRelease"))
+	assert(t, strings.Contains(verified, "Test Archive (test key) <archive@example.org>"))
+	godeb(t, "verify-sig", "--keyring", keyring, filepath.Join(dir, "Release"), filepath.Join(dir, "Release.gpg"))
+	notok(t, run([]string{"verify-sig", "--keyring", keyring, filepath.Join(dir, "Tampered"), filepath.Join(dir, "Release.gpg")}, &bytes.Buffer{}))
+	notok(t, run([]string{"verify-sig", "--keyring", keyring, filepath.Join(dir, "Release")}, &bytes.Buffer{}))
+
+	/* A key which has expired since it signed is not trusted anymore */
+	past := &packet.Config{Time: func() time.Time { return time.Now().Add(-48 * time.Hour) }}
+	expired, err := openpgp.NewEntity("Expired Archive", "test key", "expired@example.org", past)
+	isok(t, err)
+	lifetime := uint32(24 * 60 * 60)
+	for _, identity := range expired.Identities {
+		identity.SelfSignature.KeyLifetimeSecs = &lifetime
+		isok(t, identity.SelfSignature.SignUserId(identity.UserId.Id, expired.PrimaryKey, expired.PrivateKey, past))
+	}
+	armoredKey, err = testutil.ArmoredPublicKey(expired)
+	isok(t, err)
+	isok(t, os.WriteFile(keyring, armoredKey, 0644))
+	signed := bytes.Buffer{}
+	isok(t, control.ClearSign(&signed, strings.NewReader("Suite: stable\n"), expired, past))
+	isok(t, os.WriteFile(filepath.Join(dir, "InRelease"), signed.Bytes(), 0644))
+	_, _, err = control.KeyringVerifier{Keyring: openpgp.EntityList{expired}}.CheckClearsigned(signed.Bytes())
+	isok(t, err)
+	notok(t, run([]string{"verify-sig", "--keyring", keyring, filepath.Join(dir, "InRelease")}, &bytes.Buffer{}))
+
+	notok(t, run([]string{"unknown"}, &bytes.Buffer{}))
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,102 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package main // import "github.com/ebikt/go-debian/cmd/godeb"
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"golang.org/x/crypto/openpgp"
+
+	"github.com/ebikt/go-debian/archive"
+	"github.com/ebikt/go-debian/control"
+)
+
+// verify-sig {{{
+
+// verifySig checks the signature of a clearsigned file, such as an
+// InRelease or a .dsc, or the detached signature of a file, such as a
+// Release file and its Release.gpg, and tells who made it. Expired and
+// revoked keys of the keyring are not trusted.
+func verifySig(args []string, out io.Writer) error {
+	set, asJSON := flags("verify-sig")
+	keyringPath := set.String("keyring", "", "keyring of the keys allowed to sign")
+	args, err := parse(set, args, 1, 2)
+	if err != nil {
+		return err
+	}
+	if *keyringPath == "" {
+		return fmt.Errorf("No --keyring given")
+	}
+	keyring, err := archive.LoadKeyring(*keyringPath)
+	if err != nil {
+		return err
+	}
+	usable, err := archive.UsableKeys(keyring, time.Now())
+	if err != nil {
+		return err
+	}
+	verifier := control.KeyringVerifier{Keyring: usable}
+	data, err := os.ReadFile(args[0])
+	if err != nil {
+		return err
+	}
+
+	var signer *openpgp.Entity
+	if len(args) == 1 {
+		_, signer, err = verifier.CheckClearsigned(data)
+	} else {
+		var signature []byte
+		if signature, err = os.ReadFile(args[1]); err != nil {
+			return err
+		}
+		signer, err = verifier.CheckDetached(data, signature)
+	}
+	if err != nil {
+		return err
+	}
+
+	fingerprint := fmt.Sprintf("%X", signer.PrimaryKey.Fingerprint)
+	identities := []string{}
+	for name := range signer.Identities {
+		identities = append(identities, name)
+	}
+	sort.Strings(identities)
+	if *asJSON {
+		return writeJSON(out, map[string]interface{}{
+			"valid":       true,
+			"fingerprint": fingerprint,
+			"identities":  identities,
+		})
+	}
+	fmt.Fprintf(out, "Good signature by %s\n", fingerprint)
+	for _, identity := range identities {
+		fmt.Fprintf(out, "  %s\n", identity)
+	}
+	return nil
+}
+
+// }}}
+
+// vim: foldmethod=marker
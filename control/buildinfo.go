@@ -0,0 +1,268 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control // import "github.com/ebikt/go-debian/control"
+
+import (
+	"bufio"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ebikt/go-debian/dependency"
+	"github.com/ebikt/go-debian/version"
+)
+
+// Buildinfo {{{
+
+// A Buildinfo is a .buildinfo file (Format 1.0), which records the
+// environment a package was built in, so that the build can be
+// reproduced, see deb-buildinfo(5).
+type Buildinfo struct {
+	Paragraph
+
+	Filename string
+
+	Format        string
+	Source        string
+	Binaries      []string          `control:"Binary" delim:" "`
+	Architectures []dependency.Arch `control:"Architecture"`
+	Version       version.Version
+
+	ChecksumsMd5    []MD5FileHash    `control:"Checksums-Md5" delim:"\n" strip:"\n\r\t "`
+	ChecksumsSha1   []SHA1FileHash   `control:"Checksums-Sha1" delim:"\n" strip:"\n\r\t "`
+	ChecksumsSha256 []SHA256FileHash `control:"Checksums-Sha256" delim:"\n" strip:"\n\r\t "`
+
+	BuildOrigin        string          `control:"Build-Origin"`
+	BuildArchitecture  dependency.Arch `control:"Build-Architecture"`
+	BuildDate          string          `control:"Build-Date"`
+	BuildKernelVersion string          `control:"Build-Kernel-Version"`
+	BuildPath          string          `control:"Build-Path"`
+	BuildTaintedBy     []string        `control:"Build-Tainted-By" delim:"\n" strip:"\n\r\t "`
+
+	// The packages installed during the build, each with its exact
+	// version, such as "libc6 (= 2.36-9)".
+	InstalledBuildDepends dependency.Dependency `control:"Installed-Build-Depends"`
+
+	// The environment variables set during the build, one
+	// NAME="value" per line; see Env.
+	Environment string
+}
+
+// ParseBuildinfo reads a .buildinfo file, which may be clearsigned; the
+// signature is not checked. The path is kept as the Filename.
+func ParseBuildinfo(reader *bufio.Reader, path string) (*Buildinfo, error) {
+	ret := Buildinfo{Filename: path}
+	if err := Unmarshal(&ret, reader); err != nil {
+		return nil, err
+	}
+	return &ret, nil
+}
+
+// SourceVersion returns the name of the source package, and its version,
+// which differs from the Version for binNMUs, where the Source field is
+// "name (version)".
+func (b *Buildinfo) SourceVersion() (string, version.Version, error) {
+	name, ver := b.Source, b.Version
+	if i := strings.Index(name, " ("); i != -1 && strings.HasSuffix(name, ")") {
+		parsed, err := version.Parse(name[i+2 : len(name)-1])
+		if err != nil {
+			return "", version.Version{}, err
+		}
+		name, ver = name[:i], parsed
+	}
+	return name, ver, nil
+}
+
+// BuildTime parses the Build-Date, which is in the RFC 2822 format.
+func (b *Buildinfo) BuildTime() (time.Time, error) {
+	return time.Parse(time.RFC1123Z, strings.TrimSpace(b.BuildDate))
+}
+
+// Env parses the Environment, into the value of each variable.
+func (b *Buildinfo) Env() (map[string]string, error) {
+	ret := map[string]string{}
+	for _, line := range strings.Split(b.Environment, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		i := strings.Index(line, "=")
+		if i <= 0 {
+			return nil, fmt.Errorf("Invalid Environment line: '%s'", line)
+		}
+		value := line[i+1:]
+		if len(value) < 2 || value[0] != '"' || value[len(value)-1] != '"' {
+			return nil, fmt.Errorf("Invalid value of %s: '%s'", line[:i], value)
+		}
+		/* dpkg-genbuildinfo escapes the quotes and backslashes */
+		ret[line[:i]] = strings.NewReplacer(`\\`, `\`, `\"`, `"`).Replace(value[1 : len(value)-1])
+	}
+	return ret, nil
+}
+
+// InstalledPackages returns the version each package listed in the
+// Installed-Build-Depends was at, keyed by name, qualified with the
+// architecture when it is.
+func (b *Buildinfo) InstalledPackages() (map[string]version.Version, error) {
+	ret := map[string]version.Version{}
+	for _, relation := range b.InstalledBuildDepends.Relations {
+		if len(relation.Possibilities) != 1 {
+			return nil, fmt.Errorf("Alternatives in Installed-Build-Depends: '%s'", relation)
+		}
+		possi := relation.Possibilities[0]
+		if possi.Version == nil || possi.Version.Operator != "=" {
+			return nil, fmt.Errorf("Installed-Build-Depends without an exact version: '%s'", possi)
+		}
+		ver, err := version.Parse(possi.Version.Number)
+		if err != nil {
+			return nil, err
+		}
+		name := possi.Name
+		if possi.Arch != nil {
+			name += ":" + possi.Arch.String()
+		}
+		ret[name] = ver
+	}
+	return ret, nil
+}
+
+// }}}
+
+// Buildinfo diffs {{{
+
+// A BuildinfoChange is a value that differs between two documents; Old or New is
+// empty when the value is only in one of them.
+type BuildinfoChange struct {
+	Name string
+	Old  string
+	New  string
+}
+
+func (c BuildinfoChange) String() string {
+	switch {
+	case c.Old == "":
+		return fmt.Sprintf("%s: added %s", c.Name, c.New)
+	case c.New == "":
+		return fmt.Sprintf("%s: removed %s", c.Name, c.Old)
+	}
+	return fmt.Sprintf("%s: %s -> %s", c.Name, c.Old, c.New)
+}
+
+// BuildinfoDiff tells how two .buildinfo files differ, which is what
+// tells why two builds aren't reproducible.
+type BuildinfoDiff struct {
+	// Fields of the build, such as the Build-Path.
+	Fields []BuildinfoChange
+
+	// Versions of the installed packages.
+	Packages []BuildinfoChange
+
+	// Values of the environment variables.
+	Environment []BuildinfoChange
+
+	// SHA-256 checksums of the files built, by file name.
+	Checksums []BuildinfoChange
+}
+
+// Empty tells whether the two .buildinfo files are alike.
+func (d BuildinfoDiff) Empty() bool {
+	return len(d.Fields)+len(d.Packages)+len(d.Environment)+len(d.Checksums) == 0
+}
+
+// diffMaps returns the changes from one map to the other, sorted by name.
+func diffMaps(old, new map[string]string) []BuildinfoChange {
+	names := []string{}
+	for name := range old {
+		names = append(names, name)
+	}
+	for name := range new {
+		if _, ok := old[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	ret := []BuildinfoChange{}
+	for _, name := range names {
+		if old[name] != new[name] {
+			ret = append(ret, BuildinfoChange{Name: name, Old: old[name], New: new[name]})
+		}
+	}
+	return ret
+}
+
+// buildinfoFields returns the fields of the Buildinfo that describe the
+// build, other than the ones compared separately.
+func (b *Buildinfo) buildinfoFields() map[string]string {
+	ret := map[string]string{}
+	for key, value := range map[string]string{
+		"Source":               b.Source,
+		"Version":              b.Version.String(),
+		"Binary":               strings.Join(b.Binaries, " "),
+		"Build-Origin":         b.BuildOrigin,
+		"Build-Architecture":   b.BuildArchitecture.String(),
+		"Build-Date":           b.BuildDate,
+		"Build-Kernel-Version": b.BuildKernelVersion,
+		"Build-Path":           b.BuildPath,
+		"Build-Tainted-By":     strings.Join(b.BuildTaintedBy, " "),
+	} {
+		if value != "" {
+			ret[key] = value
+		}
+	}
+	return ret
+}
+
+// Diff compares the .buildinfo with another one, of a build of the same
+// source package, such as one rebuilt to check it's reproducible.
+func (b *Buildinfo) Diff(other *Buildinfo) (*BuildinfoDiff, error) {
+	ret := BuildinfoDiff{Fields: diffMaps(b.buildinfoFields(), other.buildinfoFields())}
+
+	packages := [2]map[string]string{}
+	environment := [2]map[string]string{}
+	checksums := [2]map[string]string{}
+	for i, buildinfo := range []*Buildinfo{b, other} {
+		installed, err := buildinfo.InstalledPackages()
+		if err != nil {
+			return nil, err
+		}
+		packages[i] = map[string]string{}
+		for name, ver := range installed {
+			packages[i][name] = ver.String()
+		}
+		if environment[i], err = buildinfo.Env(); err != nil {
+			return nil, err
+		}
+		checksums[i] = map[string]string{}
+		for _, hash := range buildinfo.ChecksumsSha256 {
+			checksums[i][hash.Filename] = hash.Hash
+		}
+	}
+	ret.Packages = diffMaps(packages[0], packages[1])
+	ret.Environment = diffMaps(environment[0], environment[1])
+	ret.Checksums = diffMaps(checksums[0], checksums[1])
+	return &ret, nil
+}
+
+// }}}
+
+// vim: foldmethod=marker
@@ -0,0 +1,128 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control_test
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+
+	"github.com/ebikt/go-debian/control"
+)
+
+/*
+ *
+ */
+
+const helloBuildinfo = `Format: 1.0
+Source: hello (2.10-3)
+Binary: hello
+Architecture: amd64
+Version: 2.10-3+b1
+Checksums-Md5:
+ b1ab5d6ba5a9b6ed4a8e4e9d4cf3b2e4 53052 hello_2.10-3+b1_amd64.deb
+Checksums-Sha256:
+ 8b4f2e6a0b9f2d3b0e6a3f1b1f1e2b6c8d9a0b1c2d3e4f5a6b7c8d9e0f1a2b3c 53052 hello_2.10-3+b1_amd64.deb
+Build-Origin: Debian
+Build-Architecture: amd64
+Build-Date: Sat, 10 Jun 2023 12:00:00 +0000
+Build-Path: /build/reproducible-path/hello-2.10
+Build-Tainted-By:
+ merged-usr-via-aliased-dirs
+Installed-Build-Depends:
+ autoconf (= 2.71-3),
+ libc6:amd64 (= 2.36-9),
+ debhelper (= 13.11.4)
+Environment:
+ DEB_BUILD_OPTIONS="parallel=4"
+ LANG="C.UTF-8"
+ QUOTED="say \"hi\""
+`
+
+func parseBuildinfo(t *testing.T, data string) *control.Buildinfo {
+	buildinfo, err := control.ParseBuildinfo(bufio.NewReader(strings.NewReader(data)), "hello.buildinfo")
+	isok(t, err)
+	return buildinfo
+}
+
+func TestParseBuildinfo(t *testing.T) {
+	buildinfo := parseBuildinfo(t, helloBuildinfo)
+	assert(t, buildinfo.Format == "1.0")
+	assert(t, buildinfo.Version.String() == "2.10-3+b1")
+	source, ver, err := buildinfo.SourceVersion()
+	isok(t, err)
+	assert(t, source == "hello")
+	assert(t, ver.String() == "2.10-3")
+
+	assert(t, len(buildinfo.ChecksumsMd5) == 1)
+	assert(t, buildinfo.ChecksumsSha256[0].Size == 53052)
+	assert(t, buildinfo.BuildArchitecture.String() == "amd64")
+	assert(t, buildinfo.BuildPath == "/build/reproducible-path/hello-2.10")
+	assert(t, len(buildinfo.BuildTaintedBy) == 1)
+	when, err := buildinfo.BuildTime()
+	isok(t, err)
+	assert(t, when.Year() == 2023)
+
+	installed, err := buildinfo.InstalledPackages()
+	isok(t, err)
+	assert(t, len(installed) == 3)
+	assert(t, installed["libc6:amd64"].String() == "2.36-9")
+
+	env, err := buildinfo.Env()
+	isok(t, err)
+	assert(t, env["DEB_BUILD_OPTIONS"] == "parallel=4")
+	assert(t, env["QUOTED"] == `say "hi"`)
+
+	buildinfo.Environment = "LANG=C"
+	_, err = buildinfo.Env()
+	notok(t, err)
+	buildinfo.InstalledBuildDepends.Relations[0].Possibilities[0].Version.Operator = ">="
+	_, err = buildinfo.InstalledPackages()
+	notok(t, err)
+}
+
+func TestBuildinfoDiff(t *testing.T) {
+	one := parseBuildinfo(t, helloBuildinfo)
+	diff, err := one.Diff(parseBuildinfo(t, helloBuildinfo))
+	isok(t, err)
+	assert(t, diff.Empty())
+
+	other := strings.NewReplacer(
+		"Build-Path: /build/reproducible-path/hello-2.10", "Build-Path: /tmp/hello",
+		"autoconf (= 2.71-3),\n", "",
+		"debhelper (= 13.11.4)", "debhelper (= 13.11.5)",
+		`LANG="C.UTF-8"`, `LANG="en_US.UTF-8"`,
+		"8b4f2e6a", "00000000",
+	).Replace(helloBuildinfo)
+	diff, err = one.Diff(parseBuildinfo(t, other))
+	isok(t, err)
+	assert(t, !diff.Empty())
+	assert(t, len(diff.Fields) == 1)
+	assert(t, diff.Fields[0].String() == "Build-Path: /build/reproducible-path/hello-2.10 -> /tmp/hello")
+	assert(t, len(diff.Packages) == 2)
+	assert(t, diff.Packages[0].String() == "autoconf: removed 2.71-3")
+	assert(t, diff.Packages[1].New == "13.11.5")
+	assert(t, len(diff.Environment) == 1)
+	assert(t, diff.Environment[0].New == "en_US.UTF-8")
+	assert(t, len(diff.Checksums) == 1)
+}
+
+// vim: foldmethod=marker
@@ -23,19 +23,20 @@ package control // import "github.com/ebikt/go-debian/control"
 import (
 	"bufio"
 	"fmt"
-	"os"
 	"path"
-	"path/filepath"
 	"strconv"
 	"strings"
 
 	"github.com/ebikt/go-debian/dependency"
-	"github.com/ebikt/go-debian/internal"
 	"github.com/ebikt/go-debian/version"
 )
 
 // {{{ .changes Files list entries
 
+// An entry of the Files field of a .changes, which gives the section and
+// priority of each file on top of its MD5 sum. Despite its name,
+// Component holds the section, such as "devel" or "contrib/devel", see
+// SplitSection.
 type FileListChangesFileHash struct {
 	FileHash
 
@@ -46,7 +47,7 @@ type FileListChangesFileHash struct {
 func (c *FileListChangesFileHash) UnmarshalControl(data string) error {
 	var err error
 	c.Algorithm = "md5"
-	vals := strings.Split(data, " ")
+	vals := strings.Fields(data)
 	if len(vals) < 5 {
 		return fmt.Errorf("Error: Unknown File List Hash line: '%s'", data)
 	}
@@ -63,6 +64,20 @@ func (c *FileListChangesFileHash) UnmarshalControl(data string) error {
 	return nil
 }
 
+func (c FileListChangesFileHash) MarshalControl() (string, error) {
+	return fmt.Sprintf("%s %d %s %s %s", c.Hash, c.Size, c.Component, c.Priority, c.Filename), nil
+}
+
+// SplitSection returns the archive area and the section of the file, as
+// given in its section: "contrib/devel" is in the "devel" section of
+// contrib, while "devel" is in the "devel" section of main.
+func (c FileListChangesFileHash) SplitSection() (string, string) {
+	if i := strings.Index(c.Component, "/"); i != -1 {
+		return c.Component[:i], c.Component[i+1:]
+	}
+	return "main", c.Component
+}
+
 // }}}
 
 // The Changes struct is the default encapsulation of the Debian .changes
@@ -80,6 +95,7 @@ type Changes struct {
 	Filename string
 
 	Format          string
+	Date            string
 	Source          string
 	Binaries        []string          `control:"Binary" delim:" "`
 	Architectures   []dependency.Arch `control:"Architecture"`
@@ -89,6 +105,7 @@ type Changes struct {
 	Urgency         string
 	Maintainer      string
 	ChangedBy       string `control:"Changed-By"`
+	Description     string
 	Closes          []string
 	Changes         string
 	ChecksumsSha1   []SHA1FileHash            `control:"Checksums-Sha1" delim:"\n" strip:"\n\r\t "`
@@ -96,24 +113,6 @@ type Changes struct {
 	Files           []FileListChangesFileHash `control:"Files" delim:"\n" strip:"\n\r\t "`
 }
 
-// Given a path on the filesystem, Parse the file off the disk and return
-// a pointer to a brand new Changes struct, unless error is set to a value
-// other than nil.
-func ParseChangesFile(path string) (ret *Changes, err error) {
-	path, err = filepath.Abs(path)
-	if err != nil {
-		return nil, err
-	}
-
-	f, err := os.Open(path)
-	if err != nil {
-		return nil, err
-	}
-	defer f.Close()
-
-	return ParseChanges(bufio.NewReader(f), path)
-}
-
 // Given a bufio.Reader, consume the Reader, and return a Changes object
 // for use. The "path" argument is used to set Changes.Filename, which
 // is used by Changes.GetDSC, Changes.Remove, Changes.Move and Changes.Copy to
@@ -124,13 +123,22 @@ func ParseChanges(reader *bufio.Reader, path string) (*Changes, error) {
 	return ret, Unmarshal(ret, reader)
 }
 
+// ChangedByPerson returns the Changed-By field parsed, or else the
+// Maintainer one, as the upload is made by the maintainer then.
+func (changes *Changes) ChangedByPerson() (*Person, error) {
+	if changes.ChangedBy == "" {
+		return ParsePerson(changes.Maintainer)
+	}
+	return ParsePerson(changes.ChangedBy)
+}
+
 // Return a list of FileListChangesFileHash entries from the `changes.Files`
 // entry, with the exception that each `Filename` will be joined to the root
 // directory of the Changes file.
 func (changes *Changes) AbsFiles() []FileListChangesFileHash {
 	ret := []FileListChangesFileHash{}
 
-	baseDir := filepath.Dir(changes.Filename)
+	baseDir := path.Dir(changes.Filename)
 	for _, hash := range changes.Files {
 		hash.Filename = path.Join(baseDir, hash.Filename)
 		ret = append(ret, hash)
@@ -139,92 +147,30 @@ func (changes *Changes) AbsFiles() []FileListChangesFileHash {
 	return ret
 }
 
-// Return a DSC struct for the DSC listed in the .changes file. This requires
-// Changes.Filename to be correctly set, and for the .dsc file to exist
-// in the correct place next to the .changes.
-//
-// This function may also return an error if the given .changes does not
-// include the .dsc (binary-only upload)
-func (changes *Changes) GetDSC() (*DSC, error) {
-	for _, file := range changes.Files {
-		if strings.HasSuffix(file.Filename, ".dsc") {
-
-			// Right, now lets resolve the absolute path.
-			baseDir := filepath.Dir(changes.Filename)
-
-			dsc, err := ParseDscFile(baseDir + "/" + file.Filename)
-			if err != nil {
-				return nil, err
-			}
-			return dsc, nil
-		}
-	}
-	return nil, fmt.Errorf("No .dsc file in .changes")
-}
-
-// Copy the .changes file and all referenced files to the directory
-// listed by the dest argument. This function will error out if the dest
-// argument is not a directory, or if there is an IO operation in transfer.
-//
-// This function will always move .changes last, making it suitable to
-// be used to move something into an incoming directory with an inotify
-// hook. This will also mutate Changes.Filename to match the new location.
-func (changes *Changes) Copy(dest string) error {
-	if file, err := os.Stat(dest); err == nil && !file.IsDir() {
-		return fmt.Errorf("Attempting to move .changes to a non-directory")
+// Return all the entries of the Files, Checksums-Sha1 and Checksums-Sha256
+// fields, as FileHashes.
+func (changes *Changes) Hashes() FileHashes {
+	ret := FileHashes{}
+	for _, hash := range changes.Files {
+		ret = append(ret, hash.FileHash)
 	}
-
-	for _, file := range changes.AbsFiles() {
-		dirname := filepath.Base(file.Filename)
-		err := internal.Copy(file.Filename, dest+"/"+dirname)
-		if err != nil {
-			return err
-		}
+	for _, hash := range changes.ChecksumsSha1 {
+		ret = append(ret, hash.FileHash)
 	}
-
-	dirname := filepath.Base(changes.Filename)
-	err := internal.Copy(changes.Filename, dest+"/"+dirname)
-	changes.Filename = dest + "/" + dirname
-	return err
-}
-
-// Move the .changes file and all referenced files to the directory
-// listed by the dest argument. This function will error out if the dest
-// argument is not a directory, or if there is an IO operation in transfer.
-//
-// This function will always move .changes last, making it suitable to
-// be used to move something into an incoming directory with an inotify
-// hook. This will also mutate Changes.Filename to match the new location.
-func (changes *Changes) Move(dest string) error {
-	if file, err := os.Stat(dest); err == nil && !file.IsDir() {
-		return fmt.Errorf("Attempting to move .changes to a non-directory")
-	}
-
-	for _, file := range changes.AbsFiles() {
-		dirname := filepath.Base(file.Filename)
-		err := os.Rename(file.Filename, dest+"/"+dirname)
-		if err != nil {
-			return err
-		}
+	for _, hash := range changes.ChecksumsSha256 {
+		ret = append(ret, hash.FileHash)
 	}
-
-	dirname := filepath.Base(changes.Filename)
-	err := os.Rename(changes.Filename, dest+"/"+dirname)
-	changes.Filename = dest + "/" + dirname
-	return err
+	return ret
 }
 
-// Remove the .changes file and any associated files. This function will
-// always remove the .changes last, in the event there are filesystem i/o errors
-// on removing associated files.
-func (changes *Changes) Remove() error {
-	for _, file := range changes.AbsFiles() {
-		err := os.Remove(file.Filename)
-		if err != nil {
-			return err
+// HasSource returns whether the upload includes the source package.
+func (changes *Changes) HasSource() bool {
+	for _, arch := range changes.Architectures {
+		if arch.CPU == "source" {
+			return true
 		}
 	}
-	return os.Remove(changes.Filename)
+	return false
 }
 
 // vim: foldmethod=marker
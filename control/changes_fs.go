@@ -0,0 +1,137 @@
+//go:build !pureparser
+// +build !pureparser
+
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control // import "github.com/ebikt/go-debian/control"
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Given a path on the filesystem, Parse the file off the disk and return
+// a pointer to a brand new Changes struct, unless error is set to a value
+// other than nil.
+func ParseChangesFile(path string) (ret *Changes, err error) {
+	path, err = filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ParseChanges(bufio.NewReader(f), path)
+}
+
+// Return a DSC struct for the DSC listed in the .changes file. This requires
+// Changes.Filename to be correctly set, and for the .dsc file to exist
+// in the correct place next to the .changes.
+//
+// This function may also return an error if the given .changes does not
+// include the .dsc (binary-only upload)
+func (changes *Changes) GetDSC() (*DSC, error) {
+	for _, file := range changes.Files {
+		if strings.HasSuffix(file.Filename, ".dsc") {
+
+			// Right, now lets resolve the absolute path.
+			baseDir := filepath.Dir(changes.Filename)
+
+			dsc, err := ParseDscFile(baseDir + "/" + file.Filename)
+			if err != nil {
+				return nil, err
+			}
+			return dsc, nil
+		}
+	}
+	return nil, fmt.Errorf("No .dsc file in .changes")
+}
+
+// Validate checks the size and digests of every file referenced by the
+// .changes, as found in the directory `dir`, or next to the .changes if
+// `dir` is empty. See FileHashes.Verify.
+func (changes *Changes) Validate(dir string) error {
+	if dir == "" {
+		dir = filepath.Dir(changes.Filename)
+	}
+	return changes.Hashes().Verify(dir)
+}
+
+// Copy the .changes file and all referenced files to the directory
+// listed by the dest argument. This function will error out if the dest
+// argument is not a directory, or if there is an IO operation in transfer.
+//
+// The files are copied to temporary names first, and only renamed in place
+// once all of them are there, the .changes last, making it suitable to be
+// used to move something into an incoming directory with an inotify hook.
+// Nothing shows up in dest if the copy fails. This will also mutate
+// Changes.Filename to match the new location.
+func (changes *Changes) Copy(dest string) error {
+	return changes.relocate(dest, false)
+}
+
+// Move the .changes file and all referenced files to the directory
+// listed by the dest argument. This function will error out if the dest
+// argument is not a directory, or if there is an IO operation in transfer.
+//
+// This function will always move .changes last, making it suitable to
+// be used to move something into an incoming directory with an inotify
+// hook. Files already moved are moved back if one of them can't be, and
+// moves across filesystems are done as in Copy. This will also mutate
+// Changes.Filename to match the new location.
+func (changes *Changes) Move(dest string) error {
+	return changes.relocate(dest, true)
+}
+
+func (changes *Changes) relocate(dest string, move bool) error {
+	files := []string{}
+	for _, file := range changes.AbsFiles() {
+		files = append(files, file.Filename)
+	}
+	files = append(files, changes.Filename)
+	if err := relocate(files, dest, move); err != nil {
+		return err
+	}
+	changes.Filename = filepath.Join(dest, filepath.Base(changes.Filename))
+	return nil
+}
+
+// Remove the .changes file and any associated files. This function will
+// always remove the .changes last, in the event there are filesystem i/o errors
+// on removing associated files.
+func (changes *Changes) Remove() error {
+	for _, file := range changes.AbsFiles() {
+		err := os.Remove(file.Filename)
+		if err != nil {
+			return err
+		}
+	}
+	return os.Remove(changes.Filename)
+}
+
+// vim: foldmethod=marker
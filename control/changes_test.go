@@ -22,6 +22,7 @@ package control_test
 
 import (
 	"bufio"
+	"bytes"
 	"strings"
 	"testing"
 
@@ -133,6 +134,21 @@ Files:
 	assert(t, len(changes.ChecksumsSha1) == 2)
 	assert(t, len(changes.ChecksumsSha256) == 2)
 	assert(t, len(changes.Files) == 2)
+	assert(t, changes.Date == "Wed, 29 Apr 2015 21:29:13 -0400")
+	assert(t, changes.HasSource())
+
+	component, section := changes.Files[0].SplitSection()
+	assert(t, component == "main" && section == "devel")
+	changes.Files[1].Component = "contrib/devel"
+	component, section = changes.Files[1].SplitSection()
+	assert(t, component == "contrib" && section == "devel")
+
+	writer := bytes.Buffer{}
+	isok(t, control.Marshal(&writer, changes))
+	assert(t, strings.Contains(writer.String(), `Files:
+ a74c9e3e9fe05d480d24cd43b225ee0c 1131 devel extra dput-ng_1.9.dsc
+ 67e67e85a267c0c8110001b1a6cfc293 82504 contrib/devel extra dput-ng_1.9.tar.xz
+`))
 }
 
 // vim: foldmethod=marker
@@ -0,0 +1,133 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control // import "github.com/ebikt/go-debian/control"
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/clearsign"
+)
+
+// Clearsigned documents {{{
+
+var clearsignArmor = []byte("-----BEGIN PGP SIGNED MESSAGE-----")
+
+// IsClearsigned tells whether the data is an OpenPGP clearsigned message,
+// such as a signed .dsc, .changes or InRelease file.
+func IsClearsigned(data []byte) bool {
+	return bytes.HasPrefix(bytes.TrimLeft(data, " \t\r\n"), clearsignArmor)
+}
+
+// verifyClearsigned extracts the cleartext of a clearsigned message, and
+// checks its signature against the keyring, unless the keyring is nil.
+func verifyClearsigned(data []byte, keyring *openpgp.EntityList) ([]byte, *openpgp.Entity, error) {
+	block, _ := clearsign.Decode(data)
+	/* We're only interested in the first block. This may change in the
+	 * future, in which case, we should likely set reader back to
+	 * the remainder, and return that out to put through another
+	 * ParagraphReader, since it may have a different signer. */
+
+	if block == nil {
+		return nil, nil, fmt.Errorf("Invalid clearsigned input")
+	}
+
+	if keyring == nil {
+		/* As a special case, if the keyring is nil, we can go ahead
+		 * and assume this data isn't intended to be checked against the
+		 * keyring. So, we'll just pass on through. */
+		return block.Plaintext, nil, nil
+	}
+
+	/* Now, we have to go ahead and check that the signature is valid and
+	 * relates to an entity we have in our keyring */
+	signer, err := openpgp.CheckDetachedSignature(
+		keyring,
+		bytes.NewReader(block.Bytes),
+		block.ArmoredSignature.Body,
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	return block.Plaintext, signer, nil
+}
+
+// DecodeClearsigned reads a possibly clearsigned document, and returns its
+// cleartext along with the Entity that signed it. The signature is checked
+// against the keyring, and documents that are not signed are then
+// rejected. If the keyring is nil, nothing is checked, the armor is merely
+// stripped, and the returned signer is nil.
+func DecodeClearsigned(reader io.Reader, keyring *openpgp.EntityList) ([]byte, *openpgp.Entity, error) {
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !IsClearsigned(data) {
+		if keyring != nil {
+			return nil, nil, fmt.Errorf("Document is not clearsigned")
+		}
+		return data, nil, nil
+	}
+	return verifyClearsigned(bytes.TrimLeft(data, " \t\r\n"), keyring)
+}
+
+// UnmarshalSigned is Unmarshal for clearsigned documents: the signature is
+// checked as done by DecodeClearsigned, and the signer is returned along
+// with the decoded data.
+func UnmarshalSigned(data interface{}, reader io.Reader, keyring *openpgp.EntityList) (*openpgp.Entity, error) {
+	cleartext, signer, err := DecodeClearsigned(reader, keyring)
+	if err != nil {
+		return nil, err
+	}
+	if err := Unmarshal(data, bytes.NewReader(cleartext)); err != nil {
+		return nil, err
+	}
+	return signer, nil
+}
+
+// ParseSignedDsc is ParseDsc for a .dsc that has to be signed by a key of
+// the keyring. The signer is returned along with the DSC.
+func ParseSignedDsc(reader *bufio.Reader, path string, keyring *openpgp.EntityList) (*DSC, *openpgp.Entity, error) {
+	ret := DSC{Filename: path}
+	signer, err := UnmarshalSigned(&ret, reader, keyring)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &ret, signer, nil
+}
+
+// ParseSignedChanges is ParseChanges for a .changes that has to be signed
+// by a key of the keyring. The signer is returned along with the Changes.
+func ParseSignedChanges(reader *bufio.Reader, path string, keyring *openpgp.EntityList) (*Changes, *openpgp.Entity, error) {
+	ret := Changes{Filename: path}
+	signer, err := UnmarshalSigned(&ret, reader, keyring)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &ret, signer, nil
+}
+
+// }}}
+
+// vim: foldmethod=marker
@@ -0,0 +1,86 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control_test
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+
+	"github.com/ebikt/go-debian/control"
+	"github.com/ebikt/go-debian/testutil"
+)
+
+/*
+ *
+ */
+
+func TestClearsigned(t *testing.T) {
+	key, err := testutil.NewKey("Jane Doe", "jane@example.com")
+	isok(t, err)
+	other, err := testutil.NewKey("John Doe", "john@example.com")
+	isok(t, err)
+
+	changes := []byte(`Format: 1.8
+Source: hello
+Version: 2.10-3
+Distribution: unstable
+Files:
+ d41d8cd98f00b204e9800998ecf8427e 0 devel optional hello_2.10-3.dsc
+`)
+	signed, err := testutil.ClearSign(key, changes)
+	isok(t, err)
+	assert(t, control.IsClearsigned(signed))
+	assert(t, !control.IsClearsigned(changes))
+
+	cleartext, signer, err := control.DecodeClearsigned(bytes.NewReader(signed), testutil.Keyring(key))
+	isok(t, err)
+	assert(t, bytes.Equal(cleartext, changes))
+	assert(t, signer.PrimaryKey.KeyId == key.PrimaryKey.KeyId)
+
+	parsed, signer, err := control.ParseSignedChanges(bufio.NewReader(bytes.NewReader(signed)), "hello.changes", testutil.Keyring(key))
+	isok(t, err)
+	assert(t, parsed.Source == "hello")
+	assert(t, len(parsed.Files) == 1)
+	assert(t, signer.PrimaryKey.KeyId == key.PrimaryKey.KeyId)
+
+	/* Signed by an unknown key, or not signed at all */
+	_, _, err = control.ParseSignedChanges(bufio.NewReader(bytes.NewReader(signed)), "hello.changes", testutil.Keyring(other))
+	notok(t, err)
+	_, _, err = control.ParseSignedChanges(bufio.NewReader(bytes.NewReader(changes)), "hello.changes", testutil.Keyring(key))
+	notok(t, err)
+
+	/* Tampered with */
+	tampered := bytes.Replace(signed, []byte("2.10-3"), []byte("2.10-4"), 1)
+	_, _, err = control.DecodeClearsigned(bytes.NewReader(tampered), testutil.Keyring(key))
+	notok(t, err)
+
+	/* Without a keyring, the armor is only stripped */
+	cleartext, signer, err = control.DecodeClearsigned(bytes.NewReader(tampered), nil)
+	isok(t, err)
+	assert(t, signer == nil)
+	assert(t, bytes.Contains(cleartext, []byte("2.10-4")) && !control.IsClearsigned(cleartext))
+	cleartext, _, err = control.DecodeClearsigned(bytes.NewReader(changes), nil)
+	isok(t, err)
+	assert(t, bytes.Equal(cleartext, changes))
+}
+
+// vim: foldmethod=marker
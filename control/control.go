@@ -23,8 +23,7 @@ package control // import "github.com/ebikt/go-debian/control"
 import (
 	"bufio"
 	"fmt"
-	"os"
-	"path/filepath"
+	"strings"
 
 	"github.com/ebikt/go-debian/dependency"
 )
@@ -41,10 +40,36 @@ import (
 // package in general. The subsequent sets each describe a binary package that
 // the source tree builds.
 type Control struct {
-	Filename string
+	Filename string `json:"filename"`
 
-	Source   SourceParagraph
-	Binaries []BinaryParagraph
+	Source   SourceParagraph   `json:"source"`
+	Binaries []BinaryParagraph `json:"binaries"`
+}
+
+// SourceControl is the debian/control file of a source package.
+type SourceControl = Control
+
+// Binary returns the Binary paragraph of the named package, or nil if
+// the source package doesn't build it.
+func (c *Control) Binary(pkg string) *BinaryParagraph {
+	for i := range c.Binaries {
+		if c.Binaries[i].Package == pkg {
+			return &c.Binaries[i]
+		}
+	}
+	return nil
+}
+
+// BinariesFor returns the Binary paragraphs of the packages built on the
+// given architecture, the Architecture: all ones included.
+func (c *Control) BinariesFor(arch dependency.Arch) []BinaryParagraph {
+	ret := []BinaryParagraph{}
+	for _, binary := range c.Binaries {
+		if binary.BuildsOn(arch) {
+			ret = append(ret, binary)
+		}
+	}
+	return ret
 }
 
 // Encapsulation for a debian/control Source entry. This contains information
@@ -59,10 +84,19 @@ type SourceParagraph struct {
 	Section     string
 	Description string
 
+	StandardsVersion string `control:"Standards-Version"`
+	Homepage         string
+	VcsBrowser       string `control:"Vcs-Browser"`
+	VcsGit           string `control:"Vcs-Git"`
+
 	BuildDepends        dependency.Dependency `control:"Build-Depends"`
+	BuildDependsArch    dependency.Dependency `control:"Build-Depends-Arch"`
 	BuildDependsIndep   dependency.Dependency `control:"Build-Depends-Indep"`
 	BuildConflicts      dependency.Dependency `control:"Build-Conflicts"`
+	BuildConflictsArch  dependency.Dependency `control:"Build-Conflicts-Arch"`
 	BuildConflictsIndep dependency.Dependency `control:"Build-Conflicts-Indep"`
+
+	RulesRequiresRoot RulesRequiresRoot `control:"Rules-Requires-Root"`
 }
 
 // Return a list of all entities that are responsible for the package's
@@ -72,6 +106,23 @@ func (s *SourceParagraph) Maintainers() []string {
 	return append([]string{s.Maintainer}, s.Uploaders...)
 }
 
+// People returns the Maintainer, followed by the Uploaders, parsed.
+func (s *SourceParagraph) People() ([]Person, error) {
+	return parsePeople(s.Maintainer, s.Uploaders)
+}
+
+// Vcs returns the version control system the packaging is kept in, such as
+// "Git", and the location of the repository, from the Vcs-* field other
+// than Vcs-Browser. Both are empty if there's no such field.
+func (s *SourceParagraph) Vcs() (string, string) {
+	for _, key := range s.Order {
+		if len(key) > 4 && strings.EqualFold(key[:4], "Vcs-") && !strings.EqualFold(key, "Vcs-Browser") {
+			return key[4:], s.Get(key)
+		}
+	}
+	return "", ""
+}
+
 // Encapsulation for a debian/control Binary control entry. This contains
 // information that will be eventually put lovingly into the .deb file
 // after it's built on a given Arch.
@@ -83,6 +134,12 @@ type BinaryParagraph struct {
 	Section       string
 	Essential     bool
 	Description   string
+	MultiArch     string `control:"Multi-Arch"`
+	Homepage      string
+
+	// Restriction formula of the build profiles the package is built
+	// with, such as "<!nocheck>".
+	BuildProfiles string `control:"Build-Profiles"`
 
 	Depends    dependency.Dependency
 	Recommends dependency.Dependency
@@ -93,10 +150,31 @@ type BinaryParagraph struct {
 	Breaks    dependency.Dependency
 	Conflicts dependency.Dependency
 	Replaces  dependency.Dependency
+	Provides  dependency.Dependency
 
 	BuiltUsing dependency.Dependency `control:"Built-Using"`
 }
 
+// Synopsis returns the first line of the Description.
+func (b *BinaryParagraph) Synopsis() string {
+	synopsis, _ := SplitDescription(b.Description)
+	return synopsis
+}
+
+// ExtendedDescription returns the Description past its first line,
+// without its trailing newline.
+func (b *BinaryParagraph) ExtendedDescription() string {
+	_, extended := SplitDescription(b.Description)
+	return strings.TrimSuffix(extended, "\n")
+}
+
+// BuildsOn tells whether the binary package is built on the given
+// architecture, which Architecture: all packages are.
+func (b *BinaryParagraph) BuildsOn(arch dependency.Arch) bool {
+	_, err := binaryArchitecture(b, arch)
+	return err == nil
+}
+
 func (para *Paragraph) getDependencyField(field string) (*dependency.Dependency, error) {
 	if val, ok := para.Get2(field); ok {
 		return dependency.Parse(val)
@@ -113,26 +191,26 @@ func (para *Paragraph) getOptionalDependencyField(field string) dependency.Depen
 	return *dep
 }
 
-// Given a path on the filesystem, Parse the file off the disk and return
-// a pointer to a brand new Control struct, unless error is set to a value
-// other than nil.
-func ParseControlFile(path string) (ret *Control, err error) {
-	path, err = filepath.Abs(path)
-	if err != nil {
-		return nil, err
-	}
-
-	f, err := os.Open(path)
+func (para *Paragraph) getOptionalConflictsField(field string) dependency.Dependency {
+	val := para.Get(field)
+	dep, err := dependency.ParseConflicts(val)
 	if err != nil {
-		return nil, err
+		return dependency.Dependency{}
 	}
-	defer f.Close()
+	return *dep
+}
 
-	ret, err = ParseControl(bufio.NewReader(f), path)
-	if err != nil {
-		return nil, err
+// Check that the Build-Conflicts family of fields doesn't use alternatives,
+// which are not permitted by Debian Policy.
+func (s *SourceParagraph) ValidateBuildConflicts() error {
+	for _, dep := range []dependency.Dependency{
+		s.BuildConflicts, s.BuildConflictsArch, s.BuildConflictsIndep,
+	} {
+		if err := dep.ValidateConflicts(); err != nil {
+			return err
+		}
 	}
-	return ret, nil
+	return nil
 }
 
 // Given a bufio.Reader, consume the Reader, and return a Control object
@@ -26,6 +26,7 @@ import (
 	"testing"
 
 	"github.com/ebikt/go-debian/control"
+	"github.com/ebikt/go-debian/dependency"
 )
 
 /*
@@ -126,6 +127,66 @@ Description: XDG compliant autostarting app for Fluxbox
 
 	arches := c.Binaries[1].Architectures
 	assert(t, len(arches) == 3)
+
+	people, err := c.Source.People()
+	isok(t, err)
+	assert(t, len(people) == 3)
+	assert(t, people[0].Name == "Paul Tagliamonte")
+	assert(t, people[0].Email == "paultag@ubuntu.com")
+	assert(t, people[2].String() == "Foo Bar <fnord@baz.fnord>")
+}
+
+func TestSourceControl(t *testing.T) {
+	reader := bufio.NewReader(strings.NewReader(`Source: hello
+Maintainer: Jane Doe <jane@example.com>
+Build-Depends: debhelper-compat (= 13)
+Build-Depends-Arch: libfoo-dev
+Standards-Version: 4.6.2
+Homepage: https://example.com/hello
+Vcs-Browser: https://salsa.debian.org/debian/hello
+Vcs-Git: https://salsa.debian.org/debian/hello.git
+
+Package: hello
+Architecture: linux-any
+Multi-Arch: foreign
+Depends: ${misc:Depends}, libfoo1
+Provides: greeter
+Description: friendly greeter
+ Says hello.
+
+Package: hello-doc
+Architecture: all
+Build-Profiles: <!nodoc>
+Description: documentation of hello
+`))
+	var c *control.SourceControl
+	c, err := control.ParseControl(reader, "")
+	isok(t, err)
+
+	assert(t, c.Source.StandardsVersion == "4.6.2")
+	assert(t, c.Source.BuildDependsArch.Relations[0].Possibilities[0].Name == "libfoo-dev")
+	assert(t, c.Source.VcsBrowser == "https://salsa.debian.org/debian/hello")
+	vcs, url := c.Source.Vcs()
+	assert(t, vcs == "Git")
+	assert(t, url == c.Source.VcsGit)
+
+	hello := c.Binary("hello")
+	assert(t, hello != nil)
+	assert(t, hello.MultiArch == "foreign")
+	assert(t, hello.Provides.Relations[0].Possibilities[0].Name == "greeter")
+	assert(t, hello.Synopsis() == "friendly greeter")
+	assert(t, hello.ExtendedDescription() == "Says hello.")
+	assert(t, c.Binary("hello-doc").BuildProfiles == "<!nodoc>")
+	assert(t, c.Binary("missing") == nil)
+
+	amd64, err := dependency.ParseArch("amd64")
+	isok(t, err)
+	assert(t, len(c.BinariesFor(*amd64)) == 2)
+	hurd, err := dependency.ParseArch("hurd-i386")
+	isok(t, err)
+	binaries := c.BinariesFor(*hurd)
+	assert(t, len(binaries) == 1)
+	assert(t, binaries[0].Package == "hello-doc")
 }
 
 // vim: foldmethod=marker
@@ -0,0 +1,227 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control // import "github.com/ebikt/go-debian/control"
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ebikt/go-debian/dependency"
+	"github.com/ebikt/go-debian/version"
+)
+
+// Binary to Source cross-referencing {{{
+
+// SourceVersion returns the version of the source package this binary
+// Package was built from. It's the version given in the Source field as
+// "src (version)", as done for binNMUs, and otherwise the version of the
+// binary package itself, stripped of a binNMU suffix if it has one.
+func (index *BinaryIndex) SourceVersion() (version.Version, error) {
+	i := strings.Index(index.Source, "(")
+	if i == -1 {
+		return index.Version.SourceVersion(), nil
+	}
+	value := strings.TrimSpace(index.Source[i+1:])
+	if !strings.HasSuffix(value, ")") {
+		return version.Version{}, fmt.Errorf("Malformed Source field: '%s'", index.Source)
+	}
+	return version.Parse(strings.TrimSpace(strings.TrimSuffix(value, ")")))
+}
+
+// SourceRef is a version of a source package, as listed by the Built-Using
+// field of a binary package.
+type SourceRef struct {
+	Source  string
+	Version version.Version
+}
+
+func (ref SourceRef) String() string {
+	return fmt.Sprintf("%s (= %s)", ref.Source, ref.Version)
+}
+
+// ParseBuiltUsing returns the source packages listed by a Built-Using
+// field. Unlike other relations, every one of them must name a single
+// source package at an exact version, as in "gcc-12 (= 12.2.0-14)".
+func ParseBuiltUsing(builtUsing dependency.Dependency) ([]SourceRef, error) {
+	ret := []SourceRef{}
+	for _, relation := range builtUsing.Relations {
+		if len(relation.Possibilities) != 1 {
+			return nil, fmt.Errorf("Alternatives in Built-Using: '%s'", relation)
+		}
+		possibility := relation.Possibilities[0]
+		if possibility.Version == nil || possibility.Version.Operator != "=" {
+			return nil, fmt.Errorf("Built-Using without an exact version: '%s'", relation)
+		}
+		ver, err := version.Parse(possibility.Version.Number)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, SourceRef{Source: possibility.Name, Version: ver})
+	}
+	return ret, nil
+}
+
+// BuiltUsingSources returns the source packages listed by the Built-Using
+// field of the binary package, see ParseBuiltUsing.
+func (index *BinaryIndex) BuiltUsingSources() ([]SourceRef, error) {
+	return ParseBuiltUsing(index.GetBuiltUsing())
+}
+
+// BinaryNames returns the names of the binary packages listed in the
+// Binary field.
+func (index *SourceIndex) BinaryNames() []string {
+	ret := []string{}
+	for _, binary := range index.Binaries {
+		for _, name := range strings.Split(binary, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				ret = append(ret, name)
+			}
+		}
+	}
+	return ret
+}
+
+// SourceMap indexes the entries of a Sources index, to go from binary
+// packages to the source packages building them, and back.
+type SourceMap struct {
+	sources  []SourceIndex
+	byName   map[string][]int
+	byBinary map[string][]int
+}
+
+// NewSourceMap indexes the given Sources entries.
+func NewSourceMap(sources []SourceIndex) *SourceMap {
+	ret := SourceMap{
+		sources:  sources,
+		byName:   map[string][]int{},
+		byBinary: map[string][]int{},
+	}
+	for i, source := range sources {
+		ret.byName[source.Package] = append(ret.byName[source.Package], i)
+		for _, binary := range source.BinaryNames() {
+			ret.byBinary[binary] = append(ret.byBinary[binary], i)
+		}
+	}
+	return &ret
+}
+
+// Lookup returns the source package of the given name and version, or nil
+// if it's not in the index.
+func (m *SourceMap) Lookup(name string, ver version.Version) *SourceIndex {
+	for _, i := range m.byName[name] {
+		if version.Compare(m.sources[i].Version, ver) == 0 {
+			return &m.sources[i]
+		}
+	}
+	return nil
+}
+
+// SourceOf returns the source package the binary package was built from,
+// using its Source field. An error is returned if it's not in the index.
+func (m *SourceMap) SourceOf(binary *BinaryIndex) (*SourceIndex, error) {
+	name := binary.SourcePackage()
+	ver, err := binary.SourceVersion()
+	if err != nil {
+		return nil, err
+	}
+	if source := m.Lookup(name, ver); source != nil {
+		return source, nil
+	}
+	return nil, fmt.Errorf("Source %s (%s) of %s is not in the index", name, ver, binary.Package)
+}
+
+// BuiltUsing returns the source packages listed by the Built-Using field
+// of the binary package. An error is returned if one is not in the index,
+// as the archive has to keep them around for the binary package.
+func (m *SourceMap) BuiltUsing(binary *BinaryIndex) ([]SourceIndex, error) {
+	refs, err := binary.BuiltUsingSources()
+	if err != nil {
+		return nil, err
+	}
+	ret := []SourceIndex{}
+	for _, ref := range refs {
+		source := m.Lookup(ref.Source, ref.Version)
+		if source == nil {
+			return nil, fmt.Errorf("Source %s of %s is not in the index", ref, binary.Package)
+		}
+		ret = append(ret, *source)
+	}
+	return ret, nil
+}
+
+// Binaries returns the names of all binary packages built from the source
+// package of the given name and version.
+func (m *SourceMap) Binaries(name string, ver version.Version) []string {
+	if source := m.Lookup(name, ver); source != nil {
+		return source.BinaryNames()
+	}
+	return []string{}
+}
+
+// Building returns all the versions of the source packages listing the
+// given binary package in their Binary field.
+func (m *SourceMap) Building(binary string) []SourceIndex {
+	ret := []SourceIndex{}
+	for _, i := range m.byBinary[binary] {
+		ret = append(ret, m.sources[i])
+	}
+	return ret
+}
+
+// BuiltFrom filters a Packages index down to the binary packages built
+// from the given version of a source package.
+func BuiltFrom(binaries []BinaryIndex, name string, ver version.Version) []BinaryIndex {
+	ret := []BinaryIndex{}
+	for i := range binaries {
+		if binaries[i].SourcePackage() != name {
+			continue
+		}
+		sourceVersion, err := binaries[i].SourceVersion()
+		if err == nil && version.Compare(sourceVersion, ver) == 0 {
+			ret = append(ret, binaries[i])
+		}
+	}
+	return ret
+}
+
+// UsingSource filters a Packages index down to the binary packages whose
+// Built-Using field lists the given version of a source package, which
+// are the ones to rebuild before it can be removed.
+func UsingSource(binaries []BinaryIndex, name string, ver version.Version) []BinaryIndex {
+	ret := []BinaryIndex{}
+	for i := range binaries {
+		refs, err := binaries[i].BuiltUsingSources()
+		if err != nil {
+			continue
+		}
+		for _, ref := range refs {
+			if ref.Source == name && version.Compare(ref.Version, ver) == 0 {
+				ret = append(ret, binaries[i])
+				break
+			}
+		}
+	}
+	return ret
+}
+
+// }}}
+
+// vim: foldmethod=marker
@@ -0,0 +1,163 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control_test
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+
+	"github.com/ebikt/go-debian/control"
+	"github.com/ebikt/go-debian/version"
+)
+
+/*
+ *
+ */
+
+func TestSourceMap(t *testing.T) {
+	sources, err := control.ParseSourceIndex(bufio.NewReader(strings.NewReader(`Package: hello
+Binary: hello,
+ hello-doc
+Version: 2.10-3
+
+Package: hello
+Binary: hello
+Version: 2.10-2
+
+Package: gcc-defaults
+Binary: cpp, gcc, g++
+Version: 1.203
+`)))
+	isok(t, err)
+
+	binaries, err := control.ParseBinaryIndex(bufio.NewReader(strings.NewReader(`Package: hello
+Version: 2.10-3
+
+Package: hello-doc
+Source: hello
+Version: 2.10-3
+
+Package: hello
+Version: 2.10-3+b1
+Source: hello (2.10-3)
+
+Package: gcc
+Source: gcc-defaults (1.203)
+Version: 4:12.2.0-3
+
+Package: orphan
+Version: 1.0
+`)))
+	isok(t, err)
+
+	sourceMap := control.NewSourceMap(sources)
+	for _, binary := range binaries[:4] {
+		source, err := sourceMap.SourceOf(&binary)
+		isok(t, err)
+		assert(t, source.Package == binary.SourcePackage())
+	}
+	_, err = sourceMap.SourceOf(&binaries[4])
+	notok(t, err)
+
+	gccVersion, err := binaries[3].SourceVersion()
+	isok(t, err)
+	assert(t, gccVersion.String() == "1.203")
+
+	ver, err := version.Parse("2.10-3")
+	isok(t, err)
+	assert(t, strings.Join(sourceMap.Binaries("hello", ver), " ") == "hello hello-doc")
+	assert(t, len(sourceMap.Binaries("gcc-defaults", ver)) == 0)
+	assert(t, strings.Join(sourceMap.Binaries("gcc-defaults", gccVersion), " ") == "cpp gcc g++")
+	assert(t, len(sourceMap.Building("hello")) == 2)
+	assert(t, sourceMap.Building("g++")[0].Package == "gcc-defaults")
+
+	built := control.BuiltFrom(binaries, "hello", ver)
+	assert(t, len(built) == 3)
+	assert(t, built[2].Version.String() == "2.10-3+b1")
+
+	/* Without a version in the Source field, the binNMU suffix is dropped */
+	binaries[2].Source = "hello"
+	helloVersion, err := binaries[2].SourceVersion()
+	isok(t, err)
+	assert(t, helloVersion.String() == "2.10-3")
+
+	binaries[0].Source = "hello (2.10"
+	_, err = binaries[0].SourceVersion()
+	notok(t, err)
+}
+
+func TestBuiltUsing(t *testing.T) {
+	sources, err := control.ParseSourceIndex(bufio.NewReader(strings.NewReader(`Package: gcc-12
+Binary: gcc-12
+Version: 12.2.0-14
+
+Package: rustc
+Binary: rustc
+Version: 1.63.0+dfsg1-2
+`)))
+	isok(t, err)
+
+	binaries, err := control.ParseBinaryIndex(bufio.NewReader(strings.NewReader(`Package: ripgrep
+Version: 13.0.0-4+b2
+Built-Using: gcc-12 (= 12.2.0-14), rustc (= 1.63.0+dfsg1-2)
+
+Package: fd-find
+Version: 8.6.0-3
+Built-Using: rustc (= 1.63.0+dfsg1-1)
+
+Package: broken
+Version: 1.0
+Built-Using: rustc (>= 1.63)
+
+Package: hello
+Version: 2.10-3
+`)))
+	isok(t, err)
+
+	refs, err := binaries[0].BuiltUsingSources()
+	isok(t, err)
+	assert(t, len(refs) == 2)
+	assert(t, refs[1].Source == "rustc")
+	assert(t, refs[1].Version.String() == "1.63.0+dfsg1-2")
+	assert(t, refs[0].String() == "gcc-12 (= 12.2.0-14)")
+
+	_, err = binaries[2].BuiltUsingSources()
+	notok(t, err)
+	refs, err = binaries[3].BuiltUsingSources()
+	isok(t, err)
+	assert(t, len(refs) == 0)
+
+	sourceMap := control.NewSourceMap(sources)
+	used, err := sourceMap.BuiltUsing(&binaries[0])
+	isok(t, err)
+	assert(t, len(used) == 2)
+	_, err = sourceMap.BuiltUsing(&binaries[1])
+	notok(t, err)
+
+	ver, err := version.Parse("1.63.0+dfsg1-2")
+	isok(t, err)
+	using := control.UsingSource(binaries, "rustc", ver)
+	assert(t, len(using) == 1)
+	assert(t, using[0].Package == "ripgrep")
+}
+
+// vim: foldmethod=marker
@@ -21,6 +21,7 @@
 package control // import "github.com/ebikt/go-debian/control"
 
 import (
+	"encoding"
 	"fmt"
 	"io"
 	"reflect"
@@ -62,7 +63,9 @@ type Unmarshallable interface {
 // If you're unpacking into a struct, the struct will be walked according to
 // the rules above. If you wish to override how this writes to the nested
 // struct, objects that implement the Unmarshallable interface will be
-// Unmarshaled via that method call only.
+// Unmarshaled via that method call only. Failing that, objects implementing
+// encoding.TextUnmarshaler, such as version.Version, are Unmarshaled with
+// UnmarshalText, as are the fields of other types implementing it.
 //
 // Structs that contain Paragraph as an Anonymous member will have that
 // member populated with the parsed RFC822 block, to allow access to the
@@ -97,6 +100,26 @@ func NewDecoder(reader io.Reader, keyring *openpgp.EntityList) (*Decoder, error)
 
 // }}}
 
+// SetFields {{{
+
+// Only decode the given fields, see ParagraphReader.SetFields. The other
+// fields of the target are left alone, and aren't required.
+func (d *Decoder) SetFields(fields ...string) {
+	d.paragraphReader.SetFields(fields...)
+}
+
+// }}}
+
+// SetLimits {{{
+
+// Enforce the given Limits on what's decoded from now on, see
+// ParagraphReader.SetLimits.
+func (d *Decoder) SetLimits(limits Limits) {
+	d.paragraphReader.SetLimits(limits)
+}
+
+// }}}
+
 // Decode {{{
 
 func (d *Decoder) Decode(into interface{}) error {
@@ -184,7 +207,7 @@ func decodeStruct(p Paragraph, into reflect.Value) error {
 			}
 			continue
 		} else {
-			if fieldType.Tag.Get("required") == "true" {
+			if fieldType.Tag.Get("required") == "true" && p.Projects(paragraphKey) {
 				return fmt.Errorf(
 					"Required field '%s' is missing!",
 					fieldType.Name,
@@ -202,6 +225,14 @@ func decodeStruct(p Paragraph, into reflect.Value) error {
 // set a struct field value {{{
 
 func decodeStructValue(field reflect.Value, fieldType reflect.StructField, value string) error {
+	/* Types other than structs, such as named strings, may parse
+	 * themselves too */
+	if field.Kind() != reflect.Struct && field.CanAddr() {
+		if unmarshal, ok := field.Addr().Interface().(encoding.TextUnmarshaler); ok {
+			return unmarshal.UnmarshalText([]byte(value))
+		}
+	}
+
 	switch field.Type().Kind() {
 	case reflect.String:
 		field.SetString(value)
@@ -242,6 +273,9 @@ func decodeStructValueStruct(incoming reflect.Value, incomingField reflect.Struc
 	if unmarshal, ok := elem.Interface().(Unmarshallable); ok {
 		return unmarshal.UnmarshalControl(data)
 	}
+	if unmarshal, ok := elem.Interface().(encoding.TextUnmarshaler); ok {
+		return unmarshal.UnmarshalText([]byte(data))
+	}
 
 	return fmt.Errorf(
 		"Type '%s' does not implement control.Unmarshallable",
@@ -1,6 +1,7 @@
 package control_test
 
 import (
+	"fmt"
 	"strings"
 	"testing"
 
@@ -93,6 +94,46 @@ Arches: amd64 sparc any
 	assert(t, foo.Arches[2].CPU == "any")
 }
 
+// level is a field type parsing itself only through encoding.TextUnmarshaler
+type level int
+
+func (l *level) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "low":
+		*l = 1
+	case "high":
+		*l = 2
+	default:
+		return fmt.Errorf("Unknown level %s", text)
+	}
+	return nil
+}
+
+func (l level) MarshalText() ([]byte, error) {
+	return []byte([]string{"", "low", "high"}[l]), nil
+}
+
+func TestTextUnmarshal(t *testing.T) {
+	foo := struct {
+		Value    string
+		Level    level
+		Relation dependency.VersionRelation
+	}{}
+	isok(t, control.Unmarshal(&foo, strings.NewReader(`Value: foo
+Level: high
+Relation: >= 1.0
+`)))
+	assert(t, foo.Level == 2)
+	assert(t, foo.Relation.Operator == ">=")
+
+	out := strings.Builder{}
+	isok(t, control.Marshal(&out, foo))
+	assert(t, out.String() == "Value: foo\nLevel: high\nRelation: >= 1.0\n")
+
+	notok(t, control.Unmarshal(&foo, strings.NewReader("Level: medium\n")))
+	notok(t, control.Unmarshal(&foo, strings.NewReader("Relation: ~ 1.0\n")))
+}
+
 func TestNestedUnmarshal(t *testing.T) {
 	foo := TestStruct{}
 	isok(t, control.Unmarshal(&foo, strings.NewReader(`Value: foo
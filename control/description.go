@@ -0,0 +1,270 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control // import "github.com/ebikt/go-debian/control"
+
+import (
+	"bufio"
+	"crypto/md5"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Long descriptions {{{
+
+// rawDescription renders a Description value as found in the control
+// file, after the "Description: ", with its continuation lines indented
+// and empty lines written as ".".
+func rawDescription(description string) string {
+	lines := strings.Split(strings.TrimSuffix(description, "\n"), "\n")
+	for i := 1; i < len(lines); i++ {
+		if lines[i] == "" {
+			lines[i] = "."
+		}
+		lines[i] = " " + lines[i]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// DescriptionMD5 computes the Description-md5 of a full Description, the
+// key to the long descriptions in Translation indexes. It's the MD5 sum of
+// the field value as written in the control file, plus a newline, like
+// dak and apt-ftparchive do.
+func DescriptionMD5(description string) string {
+	return fmt.Sprintf("%x", md5.Sum([]byte(rawDescription(description)+"\n")))
+}
+
+// SplitDescription splits a Description into its synopsis, the first
+// line, and its extended description.
+func SplitDescription(description string) (string, string) {
+	parts := strings.SplitN(description, "\n", 2)
+	if len(parts) == 1 {
+		return parts[0], ""
+	}
+	return parts[0], parts[1]
+}
+
+// StripDescription removes the extended description of the package,
+// leaving the synopsis in the Description field and setting the
+// Description-md5 field, as done in the Packages indexes of archives that
+// ship the long descriptions in Translation-en. The full Description is
+// returned. Packages already stripped are left alone.
+func (index *BinaryIndex) StripDescription() string {
+	synopsis, extended := SplitDescription(index.Description)
+	if extended == "" && index.DescriptionMD5 != "" {
+		return index.Description
+	}
+	full := index.Description
+	index.DescriptionMD5 = DescriptionMD5(full)
+	index.Description = synopsis
+	if index.Paragraph.values != nil {
+		index.Paragraph.Set("Description", synopsis)
+		index.Paragraph.Set("Description-md5", index.DescriptionMD5)
+	}
+	return full
+}
+
+// DescriptionKey returns the Description-md5 of the package, computed from
+// its full Description when the index doesn't have the field.
+func (index *BinaryIndex) DescriptionKey() string {
+	if index.DescriptionMD5 != "" {
+		return index.DescriptionMD5
+	}
+	return DescriptionMD5(index.Description)
+}
+
+// AttachDescription puts back the full Description of a stripped package,
+// found by its Description-md5 in the given Translation entries. It
+// returns false if it's not there, leaving the package alone.
+func (index *BinaryIndex) AttachDescription(translations []TranslationIndex) bool {
+	for _, translation := range translations {
+		if translation.DescriptionMD5 != index.DescriptionMD5 {
+			continue
+		}
+		description := translation.Description("en")
+		if description == "" {
+			continue
+		}
+		index.Description = description
+		if index.Paragraph.values != nil {
+			index.Paragraph.Set("Description", description)
+		}
+		return true
+	}
+	return false
+}
+
+// }}}
+
+// Translation index {{{
+
+// TranslationIndex is an entry of a Translation-<lang> index, which holds
+// the long descriptions of the packages, in the Description-<lang> field,
+// keyed by their Description-md5.
+type TranslationIndex struct {
+	Paragraph
+
+	Package        string
+	DescriptionMD5 string `control:"Description-md5"`
+}
+
+// Description returns the description in the given language, such as
+// "en" or "pt_BR", or "" if there's none.
+func (t *TranslationIndex) Description(lang string) string {
+	return t.Paragraph.Get("Description-" + lang)
+}
+
+// NewTranslationIndex creates the Translation-en entry of a package, given
+// its full Description.
+func NewTranslationIndex(pkg, description string) TranslationIndex {
+	ret := TranslationIndex{
+		Paragraph:      NewParagraph(),
+		Package:        pkg,
+		DescriptionMD5: DescriptionMD5(description),
+	}
+	ret.Paragraph.Set("Package", pkg)
+	ret.Paragraph.Set("Description-md5", ret.DescriptionMD5)
+	ret.Paragraph.Set("Description-en", description)
+	return ret
+}
+
+// Languages returns the languages the entry has a description in, such as
+// "en" or "pt_BR", in the order of the fields.
+func (t *TranslationIndex) Languages() []string {
+	ret := []string{}
+	for _, key := range t.Paragraph.Order {
+		if lang := strings.TrimPrefix(key, "Description-"); lang != key && lang != "md5" {
+			ret = append(ret, lang)
+		}
+	}
+	return ret
+}
+
+// Check checks that the Description-md5 of the entry is the one of its
+// English description, if it has one.
+func (t *TranslationIndex) Check() error {
+	english := t.Description("en")
+	if english == "" {
+		return nil
+	}
+	if sum := DescriptionMD5(english); sum != t.DescriptionMD5 {
+		return fmt.Errorf("Description-md5 of %s is %s, but its English description's is %s",
+			t.Package, t.DescriptionMD5, sum)
+	}
+	return nil
+}
+
+// Given a reader, parse out a list of TranslationIndex structs.
+func ParseTranslationIndex(reader *bufio.Reader) (ret []TranslationIndex, err error) {
+	ret = []TranslationIndex{}
+	err = Unmarshal(&ret, reader)
+	return ret, err
+}
+
+// }}}
+
+// Translations {{{
+
+// Translations gathers the long descriptions of Translation indexes, in
+// as many languages as there are indexes, to show the descriptions of the
+// packages of a Packages index in the language of the user. Descriptions
+// are keyed by Description-md5 only: packages with the same English
+// description share their translations too.
+type Translations struct {
+	descriptions map[string]map[string]string
+}
+
+// NewTranslations creates an empty set of Translations.
+func NewTranslations() *Translations {
+	return &Translations{descriptions: map[string]map[string]string{}}
+}
+
+// Add adds the descriptions of the Translation entries to the set; they
+// replace the ones in the same language already there.
+func (t *Translations) Add(entries ...TranslationIndex) {
+	for _, entry := range entries {
+		descriptions, ok := t.descriptions[entry.DescriptionMD5]
+		if !ok {
+			descriptions = map[string]string{}
+			t.descriptions[entry.DescriptionMD5] = descriptions
+		}
+		for _, lang := range entry.Languages() {
+			descriptions[lang] = entry.Description(lang)
+		}
+	}
+}
+
+// Read adds the entries of a Translation index to the set, one at a time.
+func (t *Translations) Read(reader io.Reader) error {
+	paragraphs, err := NewParagraphReader(reader, nil)
+	if err != nil {
+		return err
+	}
+	for {
+		para, err := paragraphs.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		entry := TranslationIndex{}
+		if err := UnpackFromParagraph(*para, &entry); err != nil {
+			return err
+		}
+		t.Add(entry)
+	}
+}
+
+// Description returns the description with the given Description-md5 in
+// the language, or "" if there's none.
+func (t *Translations) Description(md5sum, lang string) string {
+	return t.descriptions[md5sum][lang]
+}
+
+// Languages returns the languages there's a description with the given
+// Description-md5 in, sorted.
+func (t *Translations) Languages(md5sum string) []string {
+	ret := []string{}
+	for lang := range t.descriptions[md5sum] {
+		ret = append(ret, lang)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// Localize returns the description of the package in the first of the
+// languages it's translated to, else in English, and the language used.
+// The Description of the package itself, with "" as the language, is the
+// last resort, which may only be the synopsis if it was stripped.
+func (t *Translations) Localize(index BinaryIndex, langs ...string) (string, string) {
+	key := index.DescriptionKey()
+	for _, lang := range append(append([]string{}, langs...), "en") {
+		if description := t.Description(key, lang); description != "" {
+			return description, lang
+		}
+	}
+	return index.Description, ""
+}
+
+// }}}
+
+// vim: foldmethod=marker
@@ -0,0 +1,166 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control_test
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/ebikt/go-debian/control"
+)
+
+/*
+ *
+ */
+
+func TestDescriptionMD5(t *testing.T) {
+	binaries, err := control.ParseBinaryIndex(bufio.NewReader(strings.NewReader(`Package: sed
+Version: 4.9-1
+Description: GNU stream editor for filtering/transforming text
+ sed reads the specified files or the standard input if no
+ files are specified, makes editing changes according to a
+ list of commands, and writes the results to the standard
+ output.
+Homepage: https://www.gnu.org/software/sed/
+
+Package: hostname
+Version: 3.23+nmu1
+Description: utility to set/show the host name or domain name
+ This package provides commands which can be used to display the system's
+ DNS name, and to display or set its hostname or NIS domain name.
+`)))
+	isok(t, err)
+	assert(t, control.DescriptionMD5(binaries[0].Description) == "2ed71305ee7a49ce4438c58140980d2f")
+	assert(t, control.DescriptionMD5(binaries[1].Description) == "a5a22acc3c69a7f40f07f1a8dfc93af1")
+
+	synopsis, extended := control.SplitDescription(binaries[1].Description)
+	assert(t, synopsis == "utility to set/show the host name or domain name")
+	assert(t, strings.HasPrefix(extended, "This package provides"))
+}
+
+func TestStripAttachDescription(t *testing.T) {
+	binaries, err := control.ParseBinaryIndex(bufio.NewReader(strings.NewReader(`Package: hello
+Version: 2.10-3
+Description: example package based on GNU hello
+ The GNU hello program produces a familiar, friendly greeting.
+ .
+ It allows non-programmers to use a classic computer science tool.
+Section: devel
+`)))
+	isok(t, err)
+	hello := binaries[0]
+	full := hello.Description
+
+	assert(t, hello.StripDescription() == full)
+	assert(t, hello.Description == "example package based on GNU hello")
+	assert(t, hello.DescriptionMD5 == control.DescriptionMD5(full))
+	/* Stripping twice is harmless */
+	assert(t, hello.StripDescription() == "example package based on GNU hello")
+	assert(t, hello.DescriptionMD5 == control.DescriptionMD5(full))
+
+	writer := bytes.Buffer{}
+	isok(t, control.Marshal(&writer, hello))
+	assert(t, strings.Contains(writer.String(), "Description: example package based on GNU hello\n"+
+		"Section: devel\nDescription-md5: "+hello.DescriptionMD5+"\n"))
+
+	writer = bytes.Buffer{}
+	isok(t, control.Marshal(&writer, []control.TranslationIndex{control.NewTranslationIndex("hello", full)}))
+	translations, err := control.ParseTranslationIndex(bufio.NewReader(&writer))
+	isok(t, err)
+	assert(t, len(translations) == 1)
+	assert(t, translations[0].Package == "hello")
+	assert(t, translations[0].Description("en") == full)
+	assert(t, translations[0].Description("de") == "")
+
+	assert(t, hello.AttachDescription(translations))
+	assert(t, hello.Description == full)
+	assert(t, hello.Paragraph.Get("Description") == full)
+
+	other := control.BinaryIndex{Package: "other", DescriptionMD5: "0123"}
+	assert(t, !other.AttachDescription(translations))
+}
+
+const helloTranslations = `Package: hello
+Description-md5: 05e3025aa7d961bdc34fd8d92eaa5895
+Description-de: Beispielpaket basierend auf GNU hello
+ Das GNU-Programm hello gibt einen freundlichen Gruß aus.
+
+Package: sed
+Description-md5: 2ed71305ee7a49ce4438c58140980d2f
+Description-de: GNU-Stream-Editor zum Filtern/Transformieren von Text
+ sed liest die angegebenen Dateien.
+`
+
+func TestTranslations(t *testing.T) {
+	binaries, err := control.ParseBinaryIndex(bufio.NewReader(strings.NewReader(`Package: hello
+Version: 2.10-3
+Description: example package based on GNU hello
+Description-md5: 05e3025aa7d961bdc34fd8d92eaa5895
+
+Package: sed
+Version: 4.9-1
+Description: GNU stream editor for filtering/transforming text
+ sed reads the specified files or the standard input if no
+ files are specified, makes editing changes according to a
+ list of commands, and writes the results to the standard
+ output.
+
+Package: hostname
+Version: 3.23+nmu1
+Description: utility to set/show the host name or domain name
+`)))
+	isok(t, err)
+	hello, sed, hostname := binaries[0], binaries[1], binaries[2]
+	assert(t, sed.DescriptionKey() == "2ed71305ee7a49ce4438c58140980d2f")
+
+	translations := control.NewTranslations()
+	isok(t, translations.Read(strings.NewReader(helloTranslations)))
+	translations.Add(control.NewTranslationIndex("hello", "example package based on GNU hello\nThe GNU hello program produces a familiar, friendly greeting.\n"))
+
+	description, lang := translations.Localize(sed, "fr", "de")
+	assert(t, lang == "de")
+	assert(t, strings.HasPrefix(description, "GNU-Stream-Editor"))
+	description, lang = translations.Localize(sed, "fr")
+	assert(t, lang == "")
+	assert(t, description == sed.Description)
+
+	assert(t, strings.Join(translations.Languages(hello.DescriptionKey()), " ") == "de en")
+	description, lang = translations.Localize(hello, "fr")
+	assert(t, lang == "en")
+	assert(t, strings.HasPrefix(description, "example package based on GNU hello\nThe GNU"))
+	description, lang = translations.Localize(hello, "de")
+	assert(t, lang == "de")
+	description, lang = translations.Localize(hostname, "de")
+	assert(t, lang == "" && description == hostname.Description)
+
+	entries, err := control.ParseTranslationIndex(bufio.NewReader(strings.NewReader(helloTranslations)))
+	isok(t, err)
+	assert(t, strings.Join(entries[0].Languages(), " ") == "de")
+	isok(t, entries[0].Check())
+	english := control.NewTranslationIndex("sed", sed.Description)
+	isok(t, english.Check())
+	english.DescriptionMD5 = "0123"
+	notok(t, english.Check())
+}
+
+// vim: foldmethod=marker
@@ -2,5 +2,15 @@
 
 Parse the Debian control file format.
 
+Building with the `pureparser` tag leaves out the helpers that touch the
+filesystem (such as ParseDscFile, or DSC.Move), so that this module, along
+with changelog, copyright, dependency, version and watch, may be used from
+restricted targets such as GOOS=js or GOOS=wasip1:
+
+	GOOS=js GOARCH=wasm go build -tags pureparser ./changelog ./control ./copyright ./dependency ./version ./watch
+
+Only these parser modules support the tag. The others, such as archive,
+deb or source, work on files and don't build with it.
+
 */
 package control // import "github.com/ebikt/go-debian/control"
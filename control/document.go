@@ -0,0 +1,319 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control // import "github.com/ebikt/go-debian/control"
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Document {{{
+
+// A Document is a control file, such as debian/control, kept the way it
+// was written: comments, blank lines, the order and the spelling of the
+// fields, and the layout of their values are all left alone, and writing
+// the Document out again gives back the very same bytes. Only the fields
+// which are Set are rendered again, so that scripted edits make for
+// minimal diffs.
+//
+// Clearsigned documents can't be edited, since their signature wouldn't
+// match anymore.
+type Document struct {
+	Paragraphs []*DocumentParagraph
+
+	// Blank lines and comments after the last paragraph.
+	trailer string
+}
+
+// A DocumentParagraph is a paragraph of a Document.
+type DocumentParagraph struct {
+	// Blank lines and comments before the first field.
+	leading string
+
+	items []documentItem
+
+	// The parsed values, kept in sync with the items.
+	paragraph Paragraph
+}
+
+// A documentItem is a field, along with the comments between its lines,
+// or comment lines between fields, for which the key is empty.
+type documentItem struct {
+	key string
+	raw string
+}
+
+// ParseDocument reads a Document.
+func ParseDocument(reader io.Reader) (*Document, error) {
+	doc := Document{Paragraphs: []*DocumentParagraph{}}
+	buffered := bufio.NewReader(reader)
+
+	pending := ""
+	var current *DocumentParagraph
+	end := func() error {
+		if current == nil {
+			return nil
+		}
+		if err := current.parse(); err != nil {
+			return err
+		}
+		doc.Paragraphs = append(doc.Paragraphs, current)
+		current = nil
+		return nil
+	}
+
+	for number := 1; ; number++ {
+		line, err := buffered.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if line == "" {
+			break
+		}
+		switch {
+		case number == 1 && strings.HasPrefix(line, "-----BEGIN PGP "):
+			return nil, fmt.Errorf("Signed documents can't be edited")
+		case strings.TrimSpace(line) == "":
+			if current != nil && pending != "" {
+				current.items = append(current.items, documentItem{raw: pending})
+				pending = ""
+			}
+			if err := end(); err != nil {
+				return nil, err
+			}
+			pending += line
+		case line[0] == '#':
+			pending += line
+		case line[0] == ' ' || line[0] == '\t':
+			if current == nil || len(current.items) == 0 {
+				return nil, fmt.Errorf("line %d: Continuation line without a key", number)
+			}
+			last := &current.items[len(current.items)-1]
+			last.raw += pending + line
+			pending = ""
+		default:
+			i := strings.Index(line, ":")
+			if i <= 0 {
+				return nil, fmt.Errorf("line %d: Expected a field, got '%s'", number, strings.TrimSpace(line))
+			}
+			if current == nil {
+				current = &DocumentParagraph{leading: pending}
+			} else if pending != "" {
+				current.items = append(current.items, documentItem{raw: pending})
+			}
+			pending = ""
+			current.items = append(current.items, documentItem{key: line[:i], raw: line})
+		}
+		if err == io.EOF {
+			break
+		}
+	}
+
+	if current != nil && pending != "" {
+		current.items = append(current.items, documentItem{raw: pending})
+		pending = ""
+	}
+	if err := end(); err != nil {
+		return nil, err
+	}
+	doc.trailer = pending
+	return &doc, nil
+}
+
+// parse reads the values of the fields, as a ParagraphReader does.
+func (p *DocumentParagraph) parse() error {
+	data := ""
+	for _, item := range p.items {
+		data += item.raw
+	}
+	reader, err := NewParagraphReader(strings.NewReader(data), nil)
+	if err != nil {
+		return err
+	}
+	paragraph, err := reader.Next()
+	if err != nil {
+		return err
+	}
+	p.paragraph = *paragraph
+	return nil
+}
+
+// Bytes returns the Document as it is to be written out.
+func (d *Document) Bytes() []byte {
+	out := bytes.Buffer{}
+	for _, para := range d.Paragraphs {
+		out.WriteString(para.leading)
+		for _, item := range para.items {
+			out.WriteString(item.raw)
+		}
+	}
+	out.WriteString(d.trailer)
+	return out.Bytes()
+}
+
+func (d *Document) String() string {
+	return string(d.Bytes())
+}
+
+// WriteTo writes the Document out.
+func (d *Document) WriteTo(out io.Writer) (int64, error) {
+	n, err := out.Write(d.Bytes())
+	return int64(n), err
+}
+
+// Append adds a paragraph at the end of the Document, after a blank line,
+// and returns it.
+func (d *Document) Append(paragraph Paragraph) *DocumentParagraph {
+	ret := &DocumentParagraph{paragraph: NewParagraph()}
+	if len(d.Paragraphs) > 0 {
+		last := d.Paragraphs[len(d.Paragraphs)-1]
+		last.terminate()
+		if d.trailer == "" {
+			ret.leading = "\n"
+		}
+	}
+	ret.leading = d.trailer + ret.leading
+	d.trailer = ""
+	for _, key := range paragraph.Order {
+		ret.Set(key, paragraph.Get(key))
+	}
+	d.Paragraphs = append(d.Paragraphs, ret)
+	return ret
+}
+
+// Remove removes the i-th paragraph, along with the blank lines and
+// comments before it. The comments at the top of the Document stay there
+// when the first paragraph is removed.
+func (d *Document) Remove(i int) {
+	if i == 0 && len(d.Paragraphs) > 1 {
+		next := d.Paragraphs[1]
+		next.leading = d.Paragraphs[0].leading + strings.TrimLeft(next.leading, " \t\r\n")
+	}
+	d.Paragraphs = append(d.Paragraphs[:i], d.Paragraphs[i+1:]...)
+}
+
+// Paragraph returns the values of the fields of the paragraph. Changing it
+// doesn't change the paragraph, Set does.
+func (p *DocumentParagraph) Paragraph() Paragraph {
+	return p.paragraph.Update(NewParagraph())
+}
+
+// Keys returns the fields of the paragraph, in order.
+func (p *DocumentParagraph) Keys() []string {
+	return append([]string{}, p.paragraph.Order...)
+}
+
+func (p *DocumentParagraph) Get(key string) string {
+	return p.paragraph.Get(key)
+}
+
+func (p *DocumentParagraph) Has(key string) bool {
+	return p.paragraph.Has(key)
+}
+
+func (p *DocumentParagraph) find(key string) int {
+	for i, item := range p.items {
+		if item.key != "" && strings.EqualFold(item.key, key) {
+			return i
+		}
+	}
+	return -1
+}
+
+// terminate ends the last line of the paragraph with a newline, if it's
+// missing at the end of the file.
+func (p *DocumentParagraph) terminate() {
+	if len(p.items) == 0 {
+		return
+	}
+	last := &p.items[len(p.items)-1]
+	if !strings.HasSuffix(last.raw, "\n") {
+		last.raw += "\n"
+	}
+}
+
+// Set sets the value of a field, rendered the way Marshal does. A field
+// already there is replaced where it is, keeping the spelling of its key,
+// and losing the comments between its lines; otherwise the field is added
+// after the last one. Setting a field to the value it has leaves it alone.
+func (p *DocumentParagraph) Set(key, value string) {
+	if i := p.find(key); i >= 0 {
+		if p.paragraph.Get(key) == value {
+			return
+		}
+		item := &p.items[i]
+		item.raw = formatField(item.key, value)
+		p.paragraph.Set(item.key, value)
+		return
+	}
+	p.Insert(key, value, "")
+}
+
+// Insert adds a field right after the field `after`, or after the last
+// field if `after` is empty or not in the paragraph. If the field is
+// already there, it is Set instead, and stays where it is.
+func (p *DocumentParagraph) Insert(key, value, after string) {
+	if p.find(key) >= 0 {
+		p.Set(key, value)
+		return
+	}
+	at := -1
+	if after != "" {
+		at = p.find(after)
+	}
+	if at < 0 {
+		for i, item := range p.items {
+			if item.key != "" {
+				at = i
+			}
+		}
+	}
+	p.terminate()
+	item := documentItem{key: key, raw: formatField(key, value)}
+	p.items = append(p.items[:at+1], append([]documentItem{item}, p.items[at+1:]...)...)
+
+	/* Keep the parsed order in line with the items */
+	p.paragraph.Set(key, value)
+	order := []string{}
+	for _, item := range p.items {
+		if item.key != "" {
+			order = append(order, item.key)
+		}
+	}
+	p.paragraph.Order = order
+}
+
+// Delete removes a field, if it's there.
+func (p *DocumentParagraph) Delete(key string) {
+	i := p.find(key)
+	if i < 0 {
+		return
+	}
+	p.items = append(p.items[:i], p.items[i+1:]...)
+	p.paragraph.Delete(key)
+}
+
+// }}}
+
+// vim: foldmethod=marker
@@ -0,0 +1,135 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ebikt/go-debian/control"
+)
+
+/*
+ *
+ */
+
+const debianControl = `# Generated by hand
+Source: hello
+Section: devel
+Priority: optional
+Maintainer: Santiago Vila <sanvila@debian.org>
+Build-Depends: debhelper-compat (= 13),
+# Not packaged yet
+#              libfoo-dev,
+               gettext
+Standards-Version: 4.6.0
+
+# The program
+Package: hello
+Architecture: any
+Depends: ${shlibs:Depends}, ${misc:Depends}
+Description: example package based on GNU hello
+ The GNU hello program produces a familiar, friendly greeting.
+ .
+ It allows non-programmers to use a classic computer science tool.
+`
+
+func TestDocumentRoundTrip(t *testing.T) {
+	doc, err := control.ParseDocument(strings.NewReader(debianControl))
+	isok(t, err)
+	assert(t, len(doc.Paragraphs) == 2)
+	assert(t, doc.String() == debianControl)
+
+	source := doc.Paragraphs[0]
+	assert(t, source.Get("Source") == "hello")
+	assert(t, strings.Contains(source.Get("Build-Depends"), "gettext"))
+	assert(t, !strings.Contains(source.Get("Build-Depends"), "libfoo"))
+	assert(t, strings.Join(source.Keys(), " ") ==
+		"Source Section Priority Maintainer Build-Depends Standards-Version")
+
+	/* Setting a field to its value leaves its layout alone */
+	source.Set("build-depends", source.Get("Build-Depends"))
+	assert(t, doc.String() == debianControl)
+
+	/* Without a trailing newline, and with trailing blank lines */
+	for _, data := range []string{"A: b\n\n\n", "A: b", "\n# header\n\nA: b\n # indented\n"} {
+		doc, err := control.ParseDocument(strings.NewReader(data))
+		isok(t, err)
+		assert(t, len(doc.Paragraphs) == 1)
+		assert(t, doc.String() == data)
+	}
+}
+
+func TestDocumentEdit(t *testing.T) {
+	doc, err := control.ParseDocument(strings.NewReader(debianControl))
+	isok(t, err)
+
+	source := doc.Paragraphs[0]
+	source.Set("Standards-Version", "4.7.0")
+	source.Insert("Rules-Requires-Root", "no", "Build-Depends")
+	source.Set("Homepage", "https://www.gnu.org/software/hello/")
+	binary := doc.Paragraphs[1]
+	binary.Delete("Depends")
+	binary.Set("Multi-Arch", "foreign")
+
+	expected := strings.Replace(debianControl, "Standards-Version: 4.6.0\n",
+		"Rules-Requires-Root: no\nStandards-Version: 4.7.0\nHomepage: https://www.gnu.org/software/hello/\n", 1)
+	expected = strings.Replace(expected, "Depends: ${shlibs:Depends}, ${misc:Depends}\n", "", 1)
+	expected += "Multi-Arch: foreign\n"
+	assert(t, doc.String() == expected)
+	assert(t, source.Paragraph().Get("Standards-Version") == "4.7.0")
+	assert(t, source.Keys()[5] == "Rules-Requires-Root")
+	assert(t, !binary.Has("Depends"))
+
+	/* A replaced field loses the comments between its lines */
+	source.Set("Build-Depends", "debhelper-compat (= 13), gettext")
+	assert(t, !strings.Contains(doc.String(), "libfoo"))
+	assert(t, strings.Contains(doc.String(), "Build-Depends: debhelper-compat (= 13), gettext\n"))
+
+	doc.Remove(0)
+	assert(t, strings.HasPrefix(doc.String(), "# Generated by hand\n# The program\nPackage: hello\n"))
+
+	para := control.NewParagraph()
+	para.Set("Package", "hello-doc")
+	para.Set("Description", "documentation of hello\nLong description.")
+	doc.Append(para)
+	assert(t, strings.HasSuffix(doc.String(),
+		"Multi-Arch: foreign\n\nPackage: hello-doc\nDescription: documentation of hello\n Long description.\n"))
+
+	reread, err := control.ParseDocument(strings.NewReader(doc.String()))
+	isok(t, err)
+	assert(t, len(reread.Paragraphs) == 2)
+	assert(t, reread.Paragraphs[1].Get("Package") == "hello-doc")
+}
+
+func TestDocumentErrors(t *testing.T) {
+	for _, data := range []string{
+		" continuation\n",
+		"A: b\nnot a field\n",
+		"A: b\nA: c\n",
+		"-----BEGIN PGP SIGNED MESSAGE-----\n",
+	} {
+		_, err := control.ParseDocument(strings.NewReader(data))
+		notok(t, err)
+	}
+}
+
+// vim: foldmethod=marker
@@ -23,13 +23,10 @@ package control // import "github.com/ebikt/go-debian/control"
 import (
 	"bufio"
 	"fmt"
-	"os"
 	"path"
-	"path/filepath"
 	"strings"
 
 	"github.com/ebikt/go-debian/dependency"
-	"github.com/ebikt/go-debian/internal"
 	"github.com/ebikt/go-debian/version"
 
 	"pault.ag/go/topsort"
@@ -62,14 +59,15 @@ type DSC struct {
 	BuildDependsArch  dependency.Dependency `control:"Build-Depends-Arch"`
 	BuildDependsIndep dependency.Dependency `control:"Build-Depends-Indep"`
 
+	BuildConflicts      dependency.Dependency `control:"Build-Conflicts"`
+	BuildConflictsArch  dependency.Dependency `control:"Build-Conflicts-Arch"`
+	BuildConflictsIndep dependency.Dependency `control:"Build-Conflicts-Indep"`
+
 	ChecksumsSha1   []SHA1FileHash   `control:"Checksums-Sha1" delim:"\n" strip:"\n\r\t "`
 	ChecksumsSha256 []SHA256FileHash `control:"Checksums-Sha256" delim:"\n" strip:"\n\r\t "`
 	Files           []MD5FileHash    `control:"Files" delim:"\n" strip:"\n\r\t "`
 
-	/*
-		TODO:
-			Package-List
-	*/
+	PackageList []PackageListEntry `control:"Package-List" delim:"\n" strip:"\n\r\t "`
 }
 
 // Given a bunch of DSC objects, sort the packages topologically by
@@ -122,28 +120,6 @@ func OrderDSCForBuild(dscs []DSC, arch dependency.Arch) ([]DSC, error) {
 	return ret, nil
 }
 
-// Given a path on the filesystem, Parse the file off the disk and return
-// a pointer to a brand new DSC struct, unless error is set to a value
-// other than nil.
-func ParseDscFile(path string) (ret *DSC, err error) {
-	path, err = filepath.Abs(path)
-	if err != nil {
-		return nil, err
-	}
-
-	f, err := os.Open(path)
-	if err != nil {
-		return nil, err
-	}
-	defer f.Close()
-
-	ret, err = ParseDsc(bufio.NewReader(f), path)
-	if err != nil {
-		return nil, err
-	}
-	return ret, nil
-}
-
 // Given a bufio.Reader, consume the Reader, and return a DSC object
 // for use.
 func ParseDsc(reader *bufio.Reader, path string) (*DSC, error) {
@@ -173,13 +149,18 @@ func (d *DSC) Maintainers() []string {
 	return append([]string{d.Maintainer}, d.Uploaders...)
 }
 
+// People returns the Maintainer, followed by the Uploaders, parsed.
+func (d *DSC) People() ([]Person, error) {
+	return parsePeople(d.Maintainer, d.Uploaders)
+}
+
 // Return a list of MD5FileHash entries from the `dsc.Files`
 // entry, with the exception that each `Filename` will be joined to the root
 // directory of the DSC file.
 func (d *DSC) AbsFiles() []MD5FileHash {
 	ret := []MD5FileHash{}
 
-	baseDir := filepath.Dir(d.Filename)
+	baseDir := path.Dir(d.Filename)
 	for _, hash := range d.Files {
 		hash.Filename = path.Join(baseDir, hash.Filename)
 		ret = append(ret, hash)
@@ -188,69 +169,20 @@ func (d *DSC) AbsFiles() []MD5FileHash {
 	return ret
 }
 
-// Copy the .dsc file and all referenced files to the directory
-// listed by the dest argument. This function will error out if the dest
-// argument is not a directory, or if there is an IO operation in transfer.
-//
-// This function will always move .dsc last, making it suitable to
-// be used to move something into an incoming directory with an inotify
-// hook. This will also mutate DSC.Filename to match the new location.
-func (d *DSC) Copy(dest string) error {
-	if file, err := os.Stat(dest); err == nil && !file.IsDir() {
-		return fmt.Errorf("Attempting to move .dsc to a non-directory")
-	}
-
-	for _, file := range d.AbsFiles() {
-		dirname := filepath.Base(file.Filename)
-		err := internal.Copy(file.Filename, dest+"/"+dirname)
-		if err != nil {
-			return err
-		}
-	}
-
-	dirname := filepath.Base(d.Filename)
-	err := internal.Copy(d.Filename, dest+"/"+dirname)
-	d.Filename = dest + "/" + dirname
-	return err
-}
-
-// Move the .dsc file and all referenced files to the directory
-// listed by the dest argument. This function will error out if the dest
-// argument is not a directory, or if there is an IO operation in transfer.
-//
-// This function will always move .dsc last, making it suitable to
-// be used to move something into an incoming directory with an inotify
-// hook. This will also mutate DSC.Filename to match the new location.
-func (d *DSC) Move(dest string) error {
-	if file, err := os.Stat(dest); err == nil && !file.IsDir() {
-		return fmt.Errorf("Attempting to move .dsc to a non-directory")
+// Return all the entries of the Files, Checksums-Sha1 and Checksums-Sha256
+// fields, as FileHashes.
+func (d *DSC) Hashes() FileHashes {
+	ret := FileHashes{}
+	for _, hash := range d.Files {
+		ret = append(ret, hash.FileHash)
 	}
-
-	for _, file := range d.AbsFiles() {
-		dirname := filepath.Base(file.Filename)
-		err := os.Rename(file.Filename, dest+"/"+dirname)
-		if err != nil {
-			return err
-		}
+	for _, hash := range d.ChecksumsSha1 {
+		ret = append(ret, hash.FileHash)
 	}
-
-	dirname := filepath.Base(d.Filename)
-	err := os.Rename(d.Filename, dest+"/"+dirname)
-	d.Filename = dest + "/" + dirname
-	return err
-}
-
-// Remove the .dsc file and any associated files. This function will
-// always remove the .dsc last, in the event there are filesystem i/o errors
-// on removing associated files.
-func (d *DSC) Remove() error {
-	for _, file := range d.AbsFiles() {
-		err := os.Remove(file.Filename)
-		if err != nil {
-			return err
-		}
+	for _, hash := range d.ChecksumsSha256 {
+		ret = append(ret, hash.FileHash)
 	}
-	return os.Remove(d.Filename)
+	return ret
 }
 
 // Return the name of the Debian source. This is assumed to be the first file
@@ -0,0 +1,120 @@
+//go:build !pureparser
+// +build !pureparser
+
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control // import "github.com/ebikt/go-debian/control"
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Given a path on the filesystem, Parse the file off the disk and return
+// a pointer to a brand new DSC struct, unless error is set to a value
+// other than nil.
+func ParseDscFile(path string) (ret *DSC, err error) {
+	path, err = filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	ret, err = ParseDsc(bufio.NewReader(f), path)
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// Validate checks the size and digests of every file referenced by the
+// .dsc, as found in the directory `dir`, or next to the .dsc if `dir` is
+// empty. See FileHashes.Verify.
+func (d *DSC) Validate(dir string) error {
+	if dir == "" {
+		dir = filepath.Dir(d.Filename)
+	}
+	if len(d.Files) == 0 {
+		return fmt.Errorf("%s lists no Files", d.Filename)
+	}
+	return d.Hashes().Verify(dir)
+}
+
+// Copy the .dsc file and all referenced files to the directory
+// listed by the dest argument. This function will error out if the dest
+// argument is not a directory, or if there is an IO operation in transfer.
+//
+// The files are copied to temporary names first, and only renamed in place
+// once all of them are there, the .dsc last, making it suitable to be
+// used to move something into an incoming directory with an inotify hook.
+// Nothing shows up in dest if the copy fails. This will also mutate
+// DSC.Filename to match the new location.
+func (d *DSC) Copy(dest string) error {
+	return d.relocate(dest, false)
+}
+
+// Move the .dsc file and all referenced files to the directory
+// listed by the dest argument. This function will error out if the dest
+// argument is not a directory, or if there is an IO operation in transfer.
+//
+// This function will always move .dsc last, making it suitable to
+// be used to move something into an incoming directory with an inotify
+// hook. Files already moved are moved back if one of them can't be, and
+// moves across filesystems are done as in Copy. This will also mutate
+// DSC.Filename to match the new location.
+func (d *DSC) Move(dest string) error {
+	return d.relocate(dest, true)
+}
+
+func (d *DSC) relocate(dest string, move bool) error {
+	files := []string{}
+	for _, file := range d.AbsFiles() {
+		files = append(files, file.Filename)
+	}
+	files = append(files, d.Filename)
+	if err := relocate(files, dest, move); err != nil {
+		return err
+	}
+	d.Filename = filepath.Join(dest, filepath.Base(d.Filename))
+	return nil
+}
+
+// Remove the .dsc file and any associated files. This function will
+// always remove the .dsc last, in the event there are filesystem i/o errors
+// on removing associated files.
+func (d *DSC) Remove() error {
+	for _, file := range d.AbsFiles() {
+		err := os.Remove(file.Filename)
+		if err != nil {
+			return err
+		}
+	}
+	return os.Remove(d.Filename)
+}
+
+// vim: foldmethod=marker
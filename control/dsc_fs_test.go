@@ -0,0 +1,87 @@
+//go:build !pureparser
+// +build !pureparser
+
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control_test
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ebikt/go-debian/control"
+)
+
+/*
+ *
+ */
+
+func TestDSCValidate(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"hello_1.0.orig.tar.gz":     "upstream sources",
+		"hello_1.0-1.debian.tar.xz": "packaging",
+	}
+	dsc := "Format: 3.0 (quilt)\nSource: hello\nVersion: 1.0-1\n"
+	for _, field := range []string{"Checksums-Sha1", "Checksums-Sha256", "Files"} {
+		dsc += field + ":\n"
+		for _, name := range []string{"hello_1.0.orig.tar.gz", "hello_1.0-1.debian.tar.xz"} {
+			data := []byte(files[name])
+			isok(t, os.WriteFile(filepath.Join(dir, name), data, 0644))
+			sum := fmt.Sprintf("%x", md5.Sum(data))
+			switch field {
+			case "Checksums-Sha1":
+				sum = fmt.Sprintf("%x", sha1.Sum(data))
+			case "Checksums-Sha256":
+				sum = fmt.Sprintf("%x", sha256.Sum256(data))
+			}
+			dsc += fmt.Sprintf(" %s %d %s\n", sum, len(data), name)
+		}
+	}
+	path := filepath.Join(dir, "hello_1.0-1.dsc")
+	isok(t, os.WriteFile(path, []byte(dsc), 0644))
+
+	c, err := control.ParseDscFile(path)
+	isok(t, err)
+	assert(t, len(c.Hashes()) == 6)
+	isok(t, c.Validate(""))
+	isok(t, c.Validate(dir))
+	notok(t, c.Validate(t.TempDir()))
+
+	/* Same size, different contents */
+	isok(t, os.WriteFile(filepath.Join(dir, "hello_1.0-1.debian.tar.xz"), []byte("PACKAGING"), 0644))
+	err = c.Validate("")
+	notok(t, err)
+	assert(t, strings.Contains(err.Error(), "mismatch"))
+
+	isok(t, os.WriteFile(filepath.Join(dir, "hello_1.0-1.debian.tar.xz"), []byte("packaging!"), 0644))
+	err = c.Validate("")
+	notok(t, err)
+	assert(t, strings.Contains(err.Error(), "Size mismatch"))
+}
+
+// vim: foldmethod=marker
@@ -74,6 +74,12 @@ Files:
 	assert(t, c.StandardsVersion == "3.9.3")
 	assert(t, c.Homepage == "https://launchpad.net/fbautostart")
 
+	assert(t, len(c.PackageList) == 1)
+	assert(t, c.PackageList[0].Name == "fbautostart")
+	assert(t, c.PackageList[0].Type == "deb")
+	assert(t, c.PackageList[0].Section == "misc")
+	assert(t, c.PackageList[0].Architectures[0].CPU == "any")
+
 	debianSource, err := c.DebianSource()
 	assert(t, err == nil)
 	assert(t, debianSource == "fbautostart_2.718281828-1.debian.tar.xz")
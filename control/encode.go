@@ -21,6 +21,7 @@
 package control // import "github.com/ebikt/go-debian/control"
 
 import (
+	"encoding"
 	"fmt"
 	"io"
 	"reflect"
@@ -115,6 +116,13 @@ func convertToParagraph(data reflect.Value) (*Paragraph, error) {
 // convert a struct value {{{
 
 func marshalStructValue(field reflect.Value, fieldType reflect.StructField) (string, error) {
+	if field.Kind() != reflect.Struct && field.Kind() != reflect.Ptr && field.CanInterface() {
+		if marshal, ok := field.Interface().(encoding.TextMarshaler); ok {
+			text, err := marshal.MarshalText()
+			return string(text), err
+		}
+	}
+
 	switch field.Type().Kind() {
 	case reflect.String:
 		return field.String(), nil
@@ -147,6 +155,10 @@ func marshalStructValueStruct(field reflect.Value, fieldType reflect.StructField
 	if marshal, ok := field.Interface().(Marshallable); ok {
 		return marshal.MarshalControl()
 	}
+	if marshal, ok := field.Interface().(encoding.TextMarshaler); ok {
+		text, err := marshal.MarshalText()
+		return string(text), err
+	}
 
 	return "", fmt.Errorf(
 		"Type '%s' does not implement control.Marshallable",
@@ -207,9 +219,9 @@ func marshalStructValueSlice(field reflect.Value, fieldType reflect.StructField)
 // a string to join the tokens with (`delim:", "`).
 //
 // In order to Marshal a custom Struct, you are required to implement the
-// Marshallable interface. It's highly encouraged to put this interface on
-// the struct without a pointer receiver, so that pass-by-value works
-// when you call Marshal.
+// Marshallable interface, or else encoding.TextMarshaler. It's highly
+// encouraged to put this interface on the struct without a pointer
+// receiver, so that pass-by-value works when you call Marshal.
 func Marshal(writer io.Writer, data interface{}) error {
 	encoder, err := NewEncoder(writer)
 	if err != nil {
@@ -244,12 +256,18 @@ func Marshal(writer io.Writer, data interface{}) error {
 // a string to join the tokens with (`delim:", "`).
 //
 // In order to Marshal a custom Struct, you are required to implement the
-// Marshallable interface. It's highly encouraged to put this interface on
-// the struct without a pointer receiver, so that pass-by-value works
-// when you call Marshal.
+// Marshallable interface, or else encoding.TextMarshaler. It's highly
+// encouraged to put this interface on the struct without a pointer
+// receiver, so that pass-by-value works when you call Marshal.
+//
+// Paragraphs themselves can be encoded too, which, along with SetOrder and
+// SetWrap, allows rewriting files such as debian/control while keeping
+// the changes readable in a diff.
 type Encoder struct {
 	writer         io.Writer
 	alreadyWritten bool
+	order          []string
+	width          int
 }
 
 // NewEncoder {{{
@@ -262,6 +280,21 @@ func NewEncoder(writer io.Writer) (*Encoder, error) {
 	}, nil
 }
 
+// SetOrder makes the Encoder write the given fields first, in that order,
+// followed by the other ones in their original order. Without it, fields
+// are written in the order of the Paragraph, or of the Struct.
+func (e *Encoder) SetOrder(keys ...string) {
+	e.order = keys
+}
+
+// SetWrap makes the Encoder write relationship fields (Depends,
+// Build-Depends, ...) that don't fit in `width` characters with one
+// relation per line, and the other ones on a single line. A width of 0,
+// the default, writes them as they are.
+func (e *Encoder) SetWrap(width int) {
+	e.width = width
+}
+
 // }}}
 
 // Encode {{{
@@ -313,12 +346,79 @@ func (e *Encoder) encodeStruct(data reflect.Value) error {
 			return err
 		}
 	}
-	paragraph, err := convertToParagraph(data)
-	if err != nil {
-		return err
+	var paragraph *Paragraph
+	if data.Type() == reflect.TypeOf(Paragraph{}) {
+		para := data.Interface().(Paragraph)
+		paragraph = &para
+	} else {
+		var err error
+		if paragraph, err = convertToParagraph(data); err != nil {
+			return err
+		}
 	}
 	e.alreadyWritten = true
-	return paragraph.WriteTo(e.writer)
+	arranged := e.arrange(*paragraph)
+	return arranged.WriteTo(e.writer)
+}
+
+// }}}
+
+// Field order and wrapping {{{
+
+// Relationship fields, which SetWrap applies to.
+var relationFields = map[string]bool{
+	"breaks":                true,
+	"build-conflicts":       true,
+	"build-conflicts-arch":  true,
+	"build-conflicts-indep": true,
+	"build-depends":         true,
+	"build-depends-arch":    true,
+	"build-depends-indep":   true,
+	"built-using":           true,
+	"conflicts":             true,
+	"depends":               true,
+	"enhances":              true,
+	"pre-depends":           true,
+	"provides":              true,
+	"recommends":            true,
+	"replaces":              true,
+	"static-built-using":    true,
+	"suggests":              true,
+}
+
+// arrange returns a copy of the Paragraph with the order and wrapping of
+// the Encoder applied.
+func (e *Encoder) arrange(p Paragraph) Paragraph {
+	ret := NewParagraph()
+	for _, key := range e.order {
+		if value, ok := p.Get2(key); ok {
+			ret.Set(key, e.wrap(key, value))
+		}
+	}
+	for _, key := range p.Order {
+		if !ret.Has(key) {
+			ret.Set(key, e.wrap(key, p.Get(key)))
+		}
+	}
+	return ret
+}
+
+// wrap rewraps the value of a relationship field, see SetWrap.
+func (e *Encoder) wrap(key, value string) string {
+	if e.width <= 0 || !relationFields[strings.ToLower(key)] {
+		return value
+	}
+	relations := []string{}
+	for _, relation := range strings.Split(value, ",") {
+		if relation = strings.Join(strings.Fields(relation), " "); relation != "" {
+			relations = append(relations, relation)
+		}
+	}
+	line := strings.Join(relations, ", ")
+	if len(key)+2+len(line) <= e.width {
+		return line
+	}
+	return strings.Join(relations, ",\n")
 }
 
 // }}}
@@ -112,6 +112,29 @@ A Test`}
 `)
 }
 
+func TestLineListWriteTo(t *testing.T) {
+	reader, err := control.NewParagraphReader(strings.NewReader(`Package: hello
+Conffiles:
+ /etc/hello.conf 5d41402abc4b2a76b9719d911017c592
+ /etc/hello/other.conf 0c5b1b6a9a6e6a53b0c2a5e2e4f1b6b4
+Description: hello
+ world
+`), nil)
+	isok(t, err)
+	paragraph, err := reader.Next()
+	isok(t, err)
+
+	writer := bytes.Buffer{}
+	isok(t, paragraph.WriteTo(&writer))
+	assert(t, writer.String() == `Package: hello
+Conffiles:
+ /etc/hello.conf 5d41402abc4b2a76b9719d911017c592
+ /etc/hello/other.conf 0c5b1b6a9a6e6a53b0c2a5e2e4f1b6b4
+Description: hello
+ world
+`)
+}
+
 type boolStruct struct {
 	ExtraSourceOnly bool `control:"Extra-Source-Only"`
 }
@@ -136,4 +159,54 @@ func TestBoolMarshal(t *testing.T) {
 `)
 }
 
+func TestEncoderOrderAndWrap(t *testing.T) {
+	reader, err := control.NewParagraphReader(strings.NewReader(`Source: hello
+Maintainer: Santiago Vila <sanvila@debian.org>
+Standards-Version: 4.6.1
+Section: devel
+Build-Depends: debhelper-compat (= 13),
+ libgettextpo-dev
+Priority: optional
+
+Package: hello
+Architecture: any
+Depends: ${shlibs:Depends}, ${misc:Depends}, libc6 (>= 2.34), libgettextpo0 (>= 0.21), hello-data (= ${source:Version})
+Description: example package based on GNU hello
+ The GNU hello program produces a familiar, friendly greeting.
+ .
+ It allows non-programmers to use a classic computer science tool.
+`), nil)
+	isok(t, err)
+	paragraphs, err := reader.All()
+	isok(t, err)
+	paragraphs[0].Set("Standards-Version", "4.6.2")
+
+	writer := bytes.Buffer{}
+	encoder, err := control.NewEncoder(&writer)
+	isok(t, err)
+	encoder.SetOrder("Source", "Section", "Priority", "Maintainer")
+	encoder.SetWrap(79)
+	isok(t, encoder.Encode(paragraphs))
+
+	assert(t, writer.String() == `Source: hello
+Section: devel
+Priority: optional
+Maintainer: Santiago Vila <sanvila@debian.org>
+Standards-Version: 4.6.2
+Build-Depends: debhelper-compat (= 13), libgettextpo-dev
+
+Package: hello
+Architecture: any
+Depends: ${shlibs:Depends},
+ ${misc:Depends},
+ libc6 (>= 2.34),
+ libgettextpo0 (>= 0.21),
+ hello-data (= ${source:Version})
+Description: example package based on GNU hello
+ The GNU hello program produces a familiar, friendly greeting.
+ .
+ It allows non-programmers to use a classic computer science tool.
+`)
+}
+
 // vim: foldmethod=marker
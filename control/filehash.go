@@ -29,7 +29,7 @@ import (
 	"hash"
 	"io"
 	"log"
-	"path/filepath"
+	"path"
 	"strconv"
 	"strings"
 
@@ -58,6 +58,46 @@ func FileHashFromHasher(path string, hasher hashio.Hasher) FileHash {
 
 type FileHashes []FileHash
 
+// VerifyReader checks the size and the digest of the data read from the
+// reader, against all the hashes, which are about a single file. The
+// reader is read only once, whatever the number of algorithms.
+func (hashes FileHashes) VerifyReader(reader io.Reader) error {
+	if len(hashes) == 0 {
+		return fmt.Errorf("No hash to verify against")
+	}
+	return verifyReader(hashes[0].Filename, reader, hashes)
+}
+
+func verifyReader(name string, reader io.Reader, hashes []FileHash) error {
+	algorithms := []string{}
+	for _, hash := range hashes {
+		algorithms = append(algorithms, hash.Algorithm)
+	}
+
+	writer, hashers, err := hashio.NewHasherWriters(algorithms, io.Discard)
+	if err != nil {
+		return err
+	}
+	size, err := io.Copy(writer, reader)
+	if err != nil {
+		return err
+	}
+
+	for i, hash := range hashes {
+		if size != hash.Size {
+			return fmt.Errorf("Size mismatch for %s: got %d, want %d", name, size, hash.Size)
+		}
+		want, err := hex.DecodeString(hash.Hash)
+		if err != nil {
+			return err
+		}
+		if got := hashers[i].Sum(nil); !bytes.Equal(got, want) {
+			return fmt.Errorf("%s mismatch for %s: got %x, want %x", hash.Algorithm, name, got, want)
+		}
+	}
+	return nil
+}
+
 type verifier struct {
 	h      hash.Hash
 	want   []byte
@@ -113,8 +153,8 @@ func (c *FileHash) Verifier() (io.WriteCloser, error) {
 
 // ByHashPath returns the corresponding /by-hash/<algorithm>/<hash> path.
 // This function must only be used if the release supports AcquireByHash.
-func (c *FileHash) ByHashPath(path string) string {
-	return filepath.Dir(path) + "/by-hash/" + c.ByHash + "/" + c.Hash
+func (c *FileHash) ByHashPath(filename string) string {
+	return path.Dir(filename) + "/by-hash/" + c.ByHash + "/" + c.Hash
 }
 
 func (c *FileHash) marshalControl() (string, error) {
@@ -0,0 +1,62 @@
+//go:build !pureparser
+// +build !pureparser
+
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control // import "github.com/ebikt/go-debian/control"
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// Verify checks the size and the digest of each of the files listed,
+// relative to the directory `dir`. Each file is read only once, whatever
+// the number of algorithms it's listed with. An error is returned for the
+// first file which is missing, has the wrong size or the wrong digest.
+func (hashes FileHashes) Verify(dir string) error {
+	names := []string{}
+	byName := map[string][]FileHash{}
+	for _, hash := range hashes {
+		if _, ok := byName[hash.Filename]; !ok {
+			names = append(names, hash.Filename)
+		}
+		byName[hash.Filename] = append(byName[hash.Filename], hash)
+	}
+
+	for _, name := range names {
+		if err := verifyFile(filepath.Join(dir, name), byName[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func verifyFile(path string, hashes []FileHash) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return verifyReader(path, f, hashes)
+}
+
+// vim: foldmethod=marker
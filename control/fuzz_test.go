@@ -0,0 +1,98 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/ebikt/go-debian/control"
+)
+
+/*
+ *
+ */
+
+func FuzzDeb822(f *testing.F) {
+	/* Trimmed down from bookworm's Packages and Sources indices */
+	f.Add(`Package: hello
+Version: 2.10-3
+Installed-Size: 281
+Maintainer: Santiago Vila <sanvila@debian.org>
+Architecture: amd64
+Depends: libc6 (>= 2.34)
+Description: example package based on GNU hello
+ The GNU hello program produces a familiar, friendly greeting.
+ .
+ Seriously, though: this is an example of how to do this.
+MD5sum: 3dc4a8cbb8d2c81ab0ec8a1d1e8b5d30
+
+Package: hello-traditional
+Version: 2.10-6
+`)
+	f.Add(`Package: hello
+Binary: hello
+Format: 3.0 (quilt)
+Checksums-Sha256:
+ 31e066137a962676e89f69d1b65382de95a7ef7d914b8cb956f41ea72e0f516b 725946 hello_2.10.orig.tar.gz
+ 5e4bc0e1e8e6b4e35ddd2e2ebd9ab2de0bf1bba1a1ee3aa8c46e6aa2cdc5e7b3 12688 hello_2.10-3.debian.tar.xz
+# A comment
+Vcs-Git: https://salsa.debian.org/sanvila/hello.git
+`)
+	f.Add("Key: Value\r\n\r\nOther:\tValue\n\tcontinued\n")
+
+	f.Fuzz(func(t *testing.T, in string) {
+		reader, err := control.NewParagraphReader(strings.NewReader(in), nil)
+		if err != nil {
+			return
+		}
+		paragraphs, err := reader.All()
+		if err != nil {
+			return
+		}
+		for _, paragraph := range paragraphs {
+			buf := bytes.Buffer{}
+			if err := paragraph.WriteTo(&buf); err != nil {
+				t.Fatal(err)
+			}
+			if buf.Len() == 0 {
+				continue
+			}
+			reread, err := control.NewParagraphReader(&buf, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rtParagraph, err := reread.Next()
+			if err != nil {
+				t.Fatalf("Can't read back %q: %s", buf.String(), err)
+			}
+			for _, key := range paragraph.Order {
+				if paragraph.Get(key) != rtParagraph.Get(key) {
+					t.Fatalf("Field %q doesn't round trip: %q != %q",
+						key, paragraph.Get(key), rtParagraph.Get(key))
+				}
+			}
+		}
+	})
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,312 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control // import "github.com/ebikt/go-debian/control"
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ebikt/go-debian/changelog"
+	"github.com/ebikt/go-debian/version"
+)
+
+// .changes generation {{{
+
+// A ChangesFile is a file of an upload, as listed by its .changes file.
+type ChangesFile struct {
+	// Checksums of the file, of the md5, sha1 and sha256 algorithms, named
+	// after its base name.
+	Hashes FileHashes
+
+	// Section and priority of the file; "-" if empty.
+	Section  string
+	Priority string
+
+	// For a binary package, its name, architecture and synopsis.
+	Package      string
+	Architecture string
+	Description  string
+}
+
+// name returns the base name of the file.
+func (f ChangesFile) name() string {
+	if len(f.Hashes) == 0 {
+		return ""
+	}
+	return f.Hashes[0].Filename
+}
+
+// hash returns the checksum of the algorithm.
+func (f ChangesFile) hash(algorithm string) (FileHash, error) {
+	for _, hash := range f.Hashes {
+		if hash.Algorithm == algorithm {
+			return hash, nil
+		}
+	}
+	return FileHash{}, fmt.Errorf("No %s checksum for %s", algorithm, f.name())
+}
+
+// changesFiles returns the files the .dsc lists, with their checksums.
+func (d *DSC) changesFiles(section, priority string) []ChangesFile {
+	ret := []ChangesFile{}
+	index := map[string]int{}
+	for _, hash := range d.Hashes() {
+		i, found := index[hash.Filename]
+		if !found {
+			i = len(ret)
+			index[hash.Filename] = i
+			ret = append(ret, ChangesFile{Section: section, Priority: priority})
+		}
+		ret[i].Hashes = append(ret[i].Hashes, hash)
+	}
+	return ret
+}
+
+// GenerateChangesOptions describe an upload, from which GenerateChanges
+// generates its .changes file.
+type GenerateChangesOptions struct {
+	// Entries of debian/changelog, the newest first.
+	Changelog changelog.ChangelogEntries
+
+	// The Changes are made of the entries newer than this version, as
+	// with dpkg-genchanges -v; of the top entry only if nil.
+	Since *version.Version
+
+	// Maintainer of the source package, from debian/control.
+	Maintainer string
+
+	// Distribution of the upload, ChangedBy and Date; the ones of the top
+	// entry of the Changelog if empty.
+	Distribution string
+	ChangedBy    string
+	Date         time.Time
+
+	// The files of the upload: a source upload has a .dsc, along with
+	// the files it lists, binary uploads have .debs.
+	Files []ChangesFile
+}
+
+// Urgencies, from the lowest to the highest.
+var urgencyRanks = map[changelog.Urgency]int{
+	changelog.UrgencyLow:       0,
+	changelog.UrgencyMedium:    1,
+	changelog.UrgencyHigh:      2,
+	changelog.UrgencyEmergency: 3,
+	changelog.UrgencyCritical:  4,
+}
+
+// changelogChanges returns the Changes field, highest Urgency and bugs
+// closed of the changelog entries of the upload.
+func changelogChanges(opts GenerateChangesOptions) (string, changelog.Urgency, []string, error) {
+	entries := opts.Changelog[:1]
+	if opts.Since != nil {
+		entries = changelog.ChangelogEntries{}
+		for _, entry := range opts.Changelog {
+			if version.Compare(entry.Version, *opts.Since) <= 0 {
+				break
+			}
+			entries = append(entries, entry)
+		}
+		if len(entries) == 0 {
+			return "", "", nil, fmt.Errorf("No changelog entry newer than %s", opts.Since)
+		}
+	}
+
+	changes := []string{}
+	urgency := changelog.UrgencyLow
+	closes := map[int]bool{}
+	for _, entry := range entries {
+		rendered := entry.String()
+		changes = append(changes, rendered[:strings.LastIndex(rendered, "\n -- ")])
+		entryUrgency, err := entry.Urgency()
+		if err != nil {
+			return "", "", nil, err
+		}
+		if urgencyRanks[entryUrgency] > urgencyRanks[urgency] {
+			urgency = entryUrgency
+		}
+		for _, bug := range entry.Closes() {
+			number, _ := strconv.Atoi(bug)
+			closes[number] = true
+		}
+	}
+
+	bugs := []int{}
+	for bug := range closes {
+		bugs = append(bugs, bug)
+	}
+	sort.Ints(bugs)
+	ret := []string{}
+	for _, bug := range bugs {
+		ret = append(ret, strconv.Itoa(bug))
+	}
+	return strings.Join(changes, "\n"), urgency, ret, nil
+}
+
+// GenerateChanges generates the .changes file of an upload, like
+// dpkg-genchanges does: the files are listed with their checksums, the
+// Changes are taken from the changelog, and the Binary, Architecture and
+// Description fields are made of the packages uploaded. The Paragraph of
+// the Changes is set, ready to be written and signed.
+func GenerateChanges(opts GenerateChangesOptions) (*Changes, error) {
+	if len(opts.Changelog) == 0 {
+		return nil, fmt.Errorf("No changelog entry")
+	}
+	top := opts.Changelog[0]
+	if opts.Maintainer == "" {
+		return nil, fmt.Errorf("No Maintainer for %s", top.Source)
+	}
+	if len(opts.Files) == 0 {
+		return nil, fmt.Errorf("No file to upload")
+	}
+	changesText, urgency, closes, err := changelogChanges(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	distribution, changedBy, date := opts.Distribution, opts.ChangedBy, opts.Date
+	if distribution == "" {
+		distribution = top.Target
+	}
+	if changedBy == "" {
+		changedBy = top.ChangedBy
+	}
+	if date.IsZero() {
+		date = top.When
+	}
+
+	source := false
+	architectures := map[string]bool{}
+	binaries := map[string]string{}
+	files, sha1s, sha256s := []string{}, []string{}, []string{}
+	for _, file := range opts.Files {
+		name := file.name()
+		if strings.HasSuffix(name, ".dsc") {
+			source = true
+		}
+		if file.Package != "" {
+			architectures[file.Architecture] = true
+			binaries[file.Package] = file.Description
+		}
+
+		section, priority := file.Section, file.Priority
+		if section == "" {
+			section = "-"
+		}
+		if priority == "" {
+			priority = "-"
+		}
+		md5, err := file.hash("md5")
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, fmt.Sprintf("%s %d %s %s %s", md5.Hash, md5.Size, section, priority, name))
+		for _, field := range []struct {
+			algorithm string
+			lines     *[]string
+		}{{"sha1", &sha1s}, {"sha256", &sha256s}} {
+			hash, err := file.hash(field.algorithm)
+			if err != nil {
+				return nil, err
+			}
+			*field.lines = append(*field.lines, fmt.Sprintf("%s %d %s", hash.Hash, hash.Size, name))
+		}
+	}
+
+	archList := []string{}
+	for arch := range architectures {
+		archList = append(archList, arch)
+	}
+	sort.Strings(archList)
+	if source {
+		archList = append([]string{"source"}, archList...)
+	}
+	names, descriptions := []string{}, []string{}
+	for name := range binaries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		descriptions = append(descriptions, fmt.Sprintf("%s - %s", name, binaries[name]))
+	}
+
+	para := NewParagraph()
+	for _, field := range []struct{ key, value string }{
+		{"Format", "1.8"},
+		{"Date", date.Format(time.RFC1123Z)},
+		{"Source", top.Source},
+		{"Binary", strings.Join(names, " ")},
+		{"Architecture", strings.Join(archList, " ")},
+		{"Version", top.Version.String()},
+		{"Distribution", distribution},
+		{"Urgency", string(urgency)},
+		{"Maintainer", opts.Maintainer},
+		{"Changed-By", changedBy},
+		{"Description", strings.Join(descriptions, "\n")},
+		{"Closes", strings.Join(closes, " ")},
+		{"Changes", changesText},
+		{"Checksums-Sha1", strings.Join(sha1s, "\n") + "\n"},
+		{"Checksums-Sha256", strings.Join(sha256s, "\n") + "\n"},
+		{"Files", strings.Join(files, "\n") + "\n"},
+	} {
+		if field.value != "" {
+			para.Set(field.key, field.value)
+		}
+	}
+
+	ret := Changes{}
+	if err := UnpackFromParagraph(para, &ret); err != nil {
+		return nil, err
+	}
+	noEpoch := top.Version
+	noEpoch.Epoch = 0
+	ret.Filename = fmt.Sprintf("%s_%s_%s.changes", top.Source, noEpoch, changesArchitecture(archList))
+	return &ret, nil
+}
+
+// changesArchitecture returns the architecture a .changes file is named
+// after, as dpkg-genchanges does.
+func changesArchitecture(architectures []string) string {
+	binary := []string{}
+	for _, arch := range architectures {
+		if arch != "source" && arch != "all" {
+			binary = append(binary, arch)
+		}
+	}
+	switch {
+	case len(binary) > 1:
+		return "multi"
+	case len(binary) == 1:
+		return binary[0]
+	case len(architectures) == 1:
+		return architectures[0]
+	case len(architectures) == 0:
+		return "source"
+	}
+	return "all"
+}
+
+// }}}
+
+// vim: foldmethod=marker
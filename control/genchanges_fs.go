@@ -0,0 +1,65 @@
+//go:build !pureparser
+// +build !pureparser
+
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control // import "github.com/ebikt/go-debian/control"
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/ebikt/go-debian/hashio"
+)
+
+// NewChangesFile hashes the file at the path, such as a .dsc or a
+// .buildinfo, for it to be listed by a .changes file.
+func NewChangesFile(path, section, priority string) (*ChangesFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	hashing, err := hashio.NewHashingReader(f)
+	if err != nil {
+		return nil, err
+	}
+	if err := hashing.Drain(); err != nil {
+		return nil, err
+	}
+	ret := ChangesFile{Section: section, Priority: priority}
+	for _, hasher := range hashing.Hashers() {
+		ret.Hashes = append(ret.Hashes, FileHashFromHasher(filepath.Base(path), *hasher))
+	}
+	return &ret, nil
+}
+
+// ChangesFiles returns the .dsc and the files it lists, for a source
+// upload.
+func (d *DSC) ChangesFiles(section, priority string) ([]ChangesFile, error) {
+	dsc, err := NewChangesFile(d.Filename, section, priority)
+	if err != nil {
+		return nil, err
+	}
+	return append([]ChangesFile{*dsc}, d.changesFiles(section, priority)...), nil
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,134 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control_test
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ebikt/go-debian/changelog"
+	"github.com/ebikt/go-debian/control"
+	"github.com/ebikt/go-debian/version"
+)
+
+/*
+ *
+ */
+
+const genchangesChangelog = `hello (2.10-3) unstable; urgency=medium
+
+  * Fix the greeting (Closes: #1234).
+
+ -- Jane Doe <jane@example.com>  Mon, 02 Jan 2023 15:04:05 +0000
+
+hello (2.10-2) unstable; urgency=high
+
+  * Security fix. Closes: #999
+
+ -- John Doe <john@example.com>  Sun, 01 Jan 2023 15:04:05 +0000
+
+hello (2.10-1) unstable; urgency=low
+
+  * New upstream release.
+
+ -- John Doe <john@example.com>  Sat, 31 Dec 2022 15:04:05 +0000
+`
+
+func changesFile(name, md5, sha1, sha256 string, size int64) control.ChangesFile {
+	ret := control.ChangesFile{}
+	for _, hash := range []struct{ algorithm, hash string }{{"md5", md5}, {"sha1", sha1}, {"sha256", sha256}} {
+		ret.Hashes = append(ret.Hashes, control.FileHash{Algorithm: hash.algorithm, Hash: hash.hash, Size: size, Filename: name})
+	}
+	return ret
+}
+
+func TestGenerateChanges(t *testing.T) {
+	entries, err := changelog.Parse(strings.NewReader(genchangesChangelog))
+	isok(t, err)
+
+	dsc := changesFile("hello_2.10-3.dsc", "aa", "bb", "cc", 10)
+	dsc.Section, dsc.Priority = "devel", "optional"
+	deb := changesFile("hello_2.10-3_amd64.deb", "dd", "ee", "ff", 20)
+	deb.Section, deb.Priority = "devel", "optional"
+	deb.Package, deb.Architecture, deb.Description = "hello", "amd64", "example package"
+	doc := changesFile("hello-doc_2.10-3_all.deb", "11", "22", "33", 30)
+	doc.Package, doc.Architecture, doc.Description = "hello-doc", "all", "documentation"
+
+	since := version.Version{Version: "2.10", Revision: "1"}
+	changes, err := control.GenerateChanges(control.GenerateChangesOptions{
+		Changelog:  entries,
+		Since:      &since,
+		Maintainer: "Hello Maintainers <hello@example.com>",
+		Files:      []control.ChangesFile{dsc, deb, doc},
+	})
+	isok(t, err)
+	assert(t, changes.Filename == "hello_2.10-3_amd64.changes")
+	assert(t, changes.Urgency == "high")
+	assert(t, strings.Join(changes.Closes, " ") == "999 1234")
+	assert(t, changes.ChangedBy == "Jane Doe <jane@example.com>")
+	assert(t, len(changes.Binaries) == 2 && changes.Binaries[1] == "hello-doc")
+	assert(t, len(changes.Architectures) == 3 && changes.Architectures[0].String() == "source")
+	assert(t, len(changes.Files) == 3 && changes.Files[2].Component == "-")
+	assert(t, changes.ChecksumsSha256[1].Hash == "ff")
+	assert(t, strings.Contains(changes.Changes, "hello (2.10-2) unstable; urgency=high"))
+	assert(t, !strings.Contains(changes.Changes, "2.10-1"))
+
+	out := bytes.Buffer{}
+	isok(t, changes.Paragraph.WriteTo(&out))
+	assert(t, strings.Contains(out.String(), "Date: Mon, 02 Jan 2023 15:04:05 +0000\n"))
+	assert(t, strings.Contains(out.String(), "\nChanges:\n hello (2.10-3) unstable; urgency=medium\n .\n   * Fix the greeting (Closes: #1234).\n .\n hello (2.10-2)"))
+	assert(t, strings.Contains(out.String(), "\nFiles:\n aa 10 devel optional hello_2.10-3.dsc\n"))
+
+	/* The changes file reads back */
+	parsed, err := control.ParseChanges(bufio.NewReader(&out), "")
+	isok(t, err)
+	assert(t, parsed.Version.String() == "2.10-3" && len(parsed.ChecksumsSha1) == 3)
+
+	/* A source only upload of the top entry */
+	changes, err = control.GenerateChanges(control.GenerateChangesOptions{
+		Changelog:    entries,
+		Maintainer:   "Hello Maintainers <hello@example.com>",
+		Distribution: "experimental",
+		Date:         time.Date(2023, 2, 1, 0, 0, 0, 0, time.UTC),
+		Files:        []control.ChangesFile{dsc},
+	})
+	isok(t, err)
+	assert(t, changes.Filename == "hello_2.10-3_source.changes")
+	assert(t, changes.Distribution == "experimental" && changes.Urgency == "medium")
+	assert(t, !strings.Contains(changes.Changes, "2.10-2"))
+	assert(t, changes.Date == "Wed, 01 Feb 2023 00:00:00 +0000")
+
+	for _, opts := range []control.GenerateChangesOptions{
+		{Maintainer: "Hello Maintainers <hello@example.com>", Files: []control.ChangesFile{dsc}},
+		{Changelog: entries, Files: []control.ChangesFile{dsc}},
+		{Changelog: entries, Maintainer: "Hello Maintainers <hello@example.com>"},
+		{Changelog: entries, Maintainer: "Hello Maintainers <hello@example.com>", Since: &entries[0].Version, Files: []control.ChangesFile{dsc}},
+		{Changelog: entries, Maintainer: "Hello Maintainers <hello@example.com>", Files: []control.ChangesFile{{Hashes: dsc.Hashes[:1]}}},
+	} {
+		_, err := control.GenerateChanges(opts)
+		notok(t, err)
+	}
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,203 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control // import "github.com/ebikt/go-debian/control"
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/ebikt/go-debian/dependency"
+	"github.com/ebikt/go-debian/version"
+)
+
+// Binary control generation {{{
+
+// Fields of the Source paragraph of debian/control that are passed on to
+// the binary packages, unless the Binary paragraph overrides them.
+var sourceInheritedFields = []string{
+	"Maintainer", "Original-Maintainer", "Section", "Priority", "Homepage",
+	"Origin", "Bugs",
+}
+
+// The order of the fields in a DEBIAN/control file, as written by
+// dpkg-gencontrol. Other fields follow, in their debian/control order.
+var binaryControlOrder = []string{
+	"Package", "Package-Type", "Source", "Version", "Built-For-Profiles",
+	"Auto-Built-Package", "Architecture", "Subarchitecture",
+	"Installer-Menu-Item", "Build-Essential", "Essential", "Protected",
+	"Origin", "Bugs", "Maintainer", "Original-Maintainer", "Installed-Size",
+	"Kernel-Version", "Pre-Depends", "Depends", "Recommends", "Suggests",
+	"Enhances", "Conflicts", "Breaks", "Replaces", "Provides", "Built-Using",
+	"Static-Built-Using", "Section", "Priority", "Multi-Arch", "Homepage",
+	"Description",
+}
+
+// Fields of a Binary paragraph that are only about the build.
+var binaryBuildFields = map[string]bool{
+	"package":        true,
+	"architecture":   true,
+	"build-profiles": true,
+}
+
+// User defined fields are prefixed with X, followed by the letters of the
+// files they go to: S for the .dsc, B for the .deb and C for the .changes.
+var userDefinedField = regexp.MustCompile(`^X([SBC]+)-(.+)$`)
+
+// binaryField tells which name a field of debian/control has in the
+// DEBIAN/control file, if it goes there.
+func binaryField(key string) (string, bool) {
+	if match := userDefinedField.FindStringSubmatch(key); match != nil {
+		return match[2], strings.Contains(match[1], "B")
+	}
+	return key, !binaryBuildFields[strings.ToLower(key)]
+}
+
+// GenerateBinaryControl derives the DEBIAN/control paragraph of the binary
+// package pkg, built on arch, from debian/control, like dpkg-gencontrol
+// does. The version is the one of the changelog, and the installed size
+// is in KiB.
+//
+// The fields of the Source paragraph that apply to binary packages are
+// inherited, and the user defined ones for the .deb (XB-) are renamed.
+// The variables are then expanded, the ${Arch} one and the version ones
+// being defined unless the substvars already have them. A Source field is
+// added when the binary package is named or versioned differently than
+// its source package. The Installed-Size and Extra-Size variables replace
+// and add to the installed size, respectively.
+func (c *Control) GenerateBinaryControl(
+	pkg string,
+	ver version.Version,
+	arch dependency.Arch,
+	installedSize int,
+	substvars *Substvars,
+) (*Paragraph, error) {
+	binary := c.Binary(pkg)
+	if binary == nil {
+		return nil, fmt.Errorf("Package '%s' is not in debian/control", pkg)
+	}
+
+	packageArch, err := binaryArchitecture(binary, arch)
+	if err != nil {
+		return nil, err
+	}
+
+	if substvars == nil {
+		substvars = NewSubstvars()
+	}
+	if _, ok := substvars.Get("binary:Version"); !ok {
+		substvars.SetVersions(ver, ver)
+	}
+	if _, ok := substvars.Get("Arch"); !ok {
+		substvars.Set("Arch", arch.String())
+		substvars.optional["Arch"] = true
+	}
+
+	fields := NewParagraph()
+	fields.Set("Package", pkg)
+	for _, key := range sourceInheritedFields {
+		if value, ok := c.Source.Get2(key); ok {
+			fields.Set(key, value)
+		}
+	}
+	for _, key := range c.Source.Order {
+		if name, ok := binaryField(key); ok && userDefinedField.MatchString(key) {
+			fields.Set(name, c.Source.Get(key))
+		}
+	}
+	for _, key := range binary.Order {
+		if name, ok := binaryField(key); ok {
+			fields.Set(name, binary.Get(key))
+		}
+	}
+	fields.Set("Version", ver.String())
+	fields.Set("Architecture", packageArch)
+
+	expanded, err := substvars.ExpandParagraph(fields)
+	if err != nil {
+		return nil, err
+	}
+
+	sourceName := c.Source.Source
+	sourceVersion, _ := substvars.Get("source:Version")
+	if sourceVersion != "" && sourceVersion != expanded.Get("Version") {
+		expanded.Set("Source", fmt.Sprintf("%s (%s)", sourceName, sourceVersion))
+	} else if sourceName != pkg {
+		expanded.Set("Source", sourceName)
+	}
+
+	size, err := binaryInstalledSize(installedSize, substvars)
+	if err != nil {
+		return nil, err
+	}
+	expanded.Set("Installed-Size", strconv.Itoa(size))
+
+	ret := NewParagraph()
+	for _, key := range binaryControlOrder {
+		if value, ok := expanded.Get2(key); ok {
+			ret.Set(key, value)
+		}
+	}
+	for _, key := range expanded.Order {
+		if !ret.Has(key) {
+			ret.Set(key, expanded.Get(key))
+		}
+	}
+	return &ret, nil
+}
+
+// binaryArchitecture returns the Architecture of the binary package built
+// on arch: "all" for architecture independent packages, and arch for the
+// others, if it's one of theirs.
+func binaryArchitecture(binary *BinaryParagraph, arch dependency.Arch) (string, error) {
+	for _, candidate := range binary.Architectures {
+		if candidate.CPU == "all" {
+			return "all", nil
+		}
+		if arch.Matches(candidate) {
+			return arch.String(), nil
+		}
+	}
+	return "", fmt.Errorf("Package '%s' is not built on %s", binary.Package, arch)
+}
+
+func binaryInstalledSize(installedSize int, substvars *Substvars) (int, error) {
+	if value, ok := substvars.use("Installed-Size"); ok {
+		size, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			return 0, fmt.Errorf("Invalid Installed-Size: '%s'", value)
+		}
+		installedSize = size
+	}
+	if value, ok := substvars.use("Extra-Size"); ok {
+		size, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			return 0, fmt.Errorf("Invalid Extra-Size: '%s'", value)
+		}
+		installedSize += size
+	}
+	return installedSize, nil
+}
+
+// }}}
+
+// vim: foldmethod=marker
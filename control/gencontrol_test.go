@@ -0,0 +1,116 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control_test
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/ebikt/go-debian/control"
+	"github.com/ebikt/go-debian/dependency"
+	"github.com/ebikt/go-debian/version"
+)
+
+/*
+ *
+ */
+
+func TestGenerateBinaryControl(t *testing.T) {
+	c, err := control.ParseControl(bufio.NewReader(strings.NewReader(`Source: hello
+Section: devel
+Priority: optional
+Maintainer: Jane Doe <jane@example.com>
+Build-Depends: debhelper-compat (= 13)
+Homepage: https://www.gnu.org/software/hello/
+XS-Testsuite: autopkgtest
+XBS-Cnf-Visible-Pkgname: hello
+
+Package: hello
+Architecture: any
+Build-Profiles: <!nocheck>
+Depends: ${shlibs:Depends}, ${misc:Depends}
+Conflicts: hello-traditional
+XB-Tag: role::program
+Description: example package based on GNU hello
+ The GNU hello program produces a familiar, friendly greeting.
+
+Package: hello-doc
+Architecture: all
+Section: doc
+Depends: ${misc:Depends}
+Description: documentation for hello
+`)), "debian/control")
+	isok(t, err)
+
+	ver, err := version.Parse("2.10-3")
+	isok(t, err)
+	amd64, err := dependency.ParseArch("amd64")
+	isok(t, err)
+
+	vars, err := control.ParseSubstvars(strings.NewReader("shlibs:Depends=libc6 (>= 2.34)\nmisc:Depends=\nExtra-Size=4\n"))
+	isok(t, err)
+	para, err := c.GenerateBinaryControl("hello", ver, *amd64, 276, vars)
+	isok(t, err)
+
+	out := bytes.Buffer{}
+	isok(t, para.WriteTo(&out))
+	assert(t, out.String() == `Package: hello
+Version: 2.10-3
+Architecture: amd64
+Maintainer: Jane Doe <jane@example.com>
+Installed-Size: 280
+Depends: libc6 (>= 2.34)
+Conflicts: hello-traditional
+Section: devel
+Priority: optional
+Homepage: https://www.gnu.org/software/hello/
+Description: example package based on GNU hello
+ The GNU hello program produces a familiar, friendly greeting.
+Cnf-Visible-Pkgname: hello
+Tag: role::program
+`)
+	assert(t, len(vars.Unused()) == 0)
+
+	binNMU, err := version.Parse("2.10-3+b1")
+	isok(t, err)
+	vars = control.NewSubstvars()
+	vars.SetVersions(ver, binNMU)
+	para, err = c.GenerateBinaryControl("hello-doc", binNMU, *amd64, 12, vars)
+	isok(t, err)
+	assert(t, para.Get("Source") == "hello (2.10-3)")
+	assert(t, para.Get("Architecture") == "all")
+	assert(t, para.Get("Section") == "doc")
+	assert(t, !para.Has("Depends"))
+
+	_, err = c.GenerateBinaryControl("hello-dbg", ver, *amd64, 12, nil)
+	notok(t, err)
+
+	c.Binaries[0].Architectures = c.Binaries[0].Architectures[:0]
+	arm64, err := dependency.ParseArch("arm64")
+	isok(t, err)
+	c.Binaries[0].Architectures = append(c.Binaries[0].Architectures, *arm64)
+	_, err = c.GenerateBinaryControl("hello", ver, *amd64, 12, nil)
+	notok(t, err)
+}
+
+// vim: foldmethod=marker
@@ -35,6 +35,11 @@ import (
 // This can be used to examine Binary packages contained in the Archive,
 // to examine things like Built-Using, Depends, Tags or Binary packages
 // present on an Architecture.
+//
+// The relationship fields are only parsed when first read through their
+// Get method, and kept parsed for the next reads, which makes reading a
+// whole index cheap when only names and versions matter. This makes a
+// BinaryIndex unsafe to read from several goroutines at once.
 type BinaryIndex struct {
 	Paragraph
 
@@ -58,36 +63,96 @@ type BinaryIndex struct {
 	SHA256         string
 
 	DebugBuildIds []string `control:"Build-Ids" delim:" "`
+
+	relations relationCache `control:"-"`
+}
+
+// Relationship fields {{{
+
+// relationCache holds the relationship fields of an index entry once
+// parsed, so that reading a whole index only costs parsing the fields
+// actually looked at, once. Entries are checked against the raw value of
+// the field, which is parsed again if it was changed since.
+type relationCache map[string]cachedRelation
+
+type cachedRelation struct {
+	raw string
+	dep dependency.Dependency
 }
 
+// get returns the parsed relationship field of the paragraph, which is
+// an empty Dependency if it's missing or invalid. Conflicts-like fields
+// must not use alternatives.
+func (cache *relationCache) get(para *Paragraph, field string, conflicts bool) dependency.Dependency {
+	raw := para.Get(field)
+	if cached, ok := (*cache)[field]; ok && cached.raw == raw {
+		return cached.dep
+	}
+	var dep dependency.Dependency
+	if conflicts {
+		dep = para.getOptionalConflictsField(field)
+	} else {
+		dep = para.getOptionalDependencyField(field)
+	}
+	if *cache == nil {
+		*cache = relationCache{}
+	}
+	(*cache)[field] = cachedRelation{raw: raw, dep: dep}
+	return dep
+}
+
+// }}}
+
 // Parse the Depends Dependency relation on this package.
 func (index *BinaryIndex) GetDepends() dependency.Dependency {
-	return index.getOptionalDependencyField("Depends")
+	return index.relations.get(&index.Paragraph, "Depends", false)
+}
+
+// Parse the Recommends relation on this package.
+func (index *BinaryIndex) GetRecommends() dependency.Dependency {
+	return index.relations.get(&index.Paragraph, "Recommends", false)
 }
 
 // Parse the Depends Suggests relation on this package.
 func (index *BinaryIndex) GetSuggests() dependency.Dependency {
-	return index.getOptionalDependencyField("Suggests")
+	return index.relations.get(&index.Paragraph, "Suggests", false)
+}
+
+// Parse the Enhances relation on this package.
+func (index *BinaryIndex) GetEnhances() dependency.Dependency {
+	return index.relations.get(&index.Paragraph, "Enhances", false)
+}
+
+// Parse the Provides relation on this package.
+func (index *BinaryIndex) GetProvides() dependency.Dependency {
+	return index.relations.get(&index.Paragraph, "Provides", false)
 }
 
 // Parse the Depends Breaks relation on this package.
 func (index *BinaryIndex) GetBreaks() dependency.Dependency {
-	return index.getOptionalDependencyField("Breaks")
+	return index.relations.get(&index.Paragraph, "Breaks", false)
+}
+
+// Parse the Conflicts relation on this package. Relations using
+// alternatives, which are not permitted here, result in an empty
+// Dependency.
+func (index *BinaryIndex) GetConflicts() dependency.Dependency {
+	return index.relations.get(&index.Paragraph, "Conflicts", true)
 }
 
 // Parse the Depends Replaces relation on this package.
 func (index *BinaryIndex) GetReplaces() dependency.Dependency {
-	return index.getOptionalDependencyField("Replaces")
+	return index.relations.get(&index.Paragraph, "Replaces", false)
 }
 
 // Parse the Depends Pre-Depends relation on this package.
 func (index *BinaryIndex) GetPreDepends() dependency.Dependency {
-	return index.getOptionalDependencyField("Pre-Depends")
+	return index.relations.get(&index.Paragraph, "Pre-Depends", false)
 }
 
 // Parse the Built-Depends relation on this package.
 func (index *BinaryIndex) GetBuiltUsing() dependency.Dependency {
-	return index.getOptionalDependencyField("Built-Using")
+	return index.relations.get(&index.Paragraph, "Built-Using", false)
 }
 
 // SourcePackage returns the Debian source package name from which this binary
@@ -103,6 +168,14 @@ func (index *BinaryIndex) SourcePackage() string {
 	return strings.Split(index.Source, " ")[0]
 }
 
+// IsUdeb tells whether the entry is the one of a udeb, a micro binary
+// package of the Debian Installer, listed by the debian-installer indexes
+// of the archive: its Package-Type says so, or its Filename ends in .udeb.
+func (index *BinaryIndex) IsUdeb() bool {
+	return index.Paragraph.Get("Package-Type") == "udeb" ||
+		strings.HasSuffix(index.Filename, ".udeb")
+}
+
 // BestChecksums can be included in a struct instead of e.g. ChecksumsSha256.
 //
 // BestChecksums uses cryptographically secure checksums, so that application
@@ -166,21 +239,40 @@ type SourceIndex struct {
 	Directory        string
 	Priority         string
 	Section          string
+
+	PackageList []PackageListEntry `control:"Package-List" delim:"\n" strip:"\n\r\t "`
+
+	relations relationCache `control:"-"`
 }
 
 // Parse the Depends Build-Depends relation on this package.
 func (index *SourceIndex) GetBuildDepends() dependency.Dependency {
-	return index.getOptionalDependencyField("Build-Depends")
+	return index.relations.get(&index.Paragraph, "Build-Depends", false)
 }
 
 // Parse the Depends Build-Depends-Arch relation on this package.
 func (index *SourceIndex) GetBuildDependsArch() dependency.Dependency {
-	return index.getOptionalDependencyField("Build-Depends-Arch")
+	return index.relations.get(&index.Paragraph, "Build-Depends-Arch", false)
 }
 
 // Parse the Depends Build-Depends-Indep relation on this package.
 func (index *SourceIndex) GetBuildDependsIndep() dependency.Dependency {
-	return index.getOptionalDependencyField("Build-Depends-Indep")
+	return index.relations.get(&index.Paragraph, "Build-Depends-Indep", false)
+}
+
+// Parse the Build-Conflicts relation on this package.
+func (index *SourceIndex) GetBuildConflicts() dependency.Dependency {
+	return index.relations.get(&index.Paragraph, "Build-Conflicts", true)
+}
+
+// Parse the Build-Conflicts-Arch relation on this package.
+func (index *SourceIndex) GetBuildConflictsArch() dependency.Dependency {
+	return index.relations.get(&index.Paragraph, "Build-Conflicts-Arch", true)
+}
+
+// Parse the Build-Conflicts-Indep relation on this package.
+func (index *SourceIndex) GetBuildConflictsIndep() dependency.Dependency {
+	return index.relations.get(&index.Paragraph, "Build-Conflicts-Indep", true)
 }
 
 // Given a reader, parse out a list of BinaryIndex structs.
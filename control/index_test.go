@@ -191,4 +191,30 @@ SHA256: fa53e4f50349c5c9b564b8dc1da86c503b0baf56ab95a4ef6e204b6f77bfe70c
 	assert(t, ddmsDepends.GetAllPossibilities()[0].Version.Number == "22.2+git20130830~92d25d6-1")
 }
 
+func TestBinaryIndexWeakRelations(t *testing.T) {
+	indexes, err := control.ParseBinaryIndex(bufio.NewReader(strings.NewReader(`Package: hello
+Version: 2.10-3
+Recommends: hello-doc
+Suggests: hello-el | emacs
+Enhances: bash
+Provides: hello-world (= 1.0)
+Conflicts: hello-traditional
+`)))
+	isok(t, err)
+	hello := indexes[0]
+
+	assert(t, hello.GetRecommends().Relations[0].Possibilities[0].Name == "hello-doc")
+	assert(t, len(hello.GetSuggests().Relations[0].Possibilities) == 2)
+	assert(t, hello.GetEnhances().Relations[0].Possibilities[0].Name == "bash")
+	assert(t, hello.GetProvides().Relations[0].Possibilities[0].Version.Number == "1.0")
+	assert(t, len(hello.GetDepends().Relations) == 0)
+	assert(t, len(hello.GetConflicts().Relations) == 1)
+
+	/* Changing a field parses it again */
+	hello.Set("Recommends", "hello-doc, hello-extras")
+	assert(t, len(hello.GetRecommends().Relations) == 2)
+	hello.Set("Conflicts", "a | b")
+	assert(t, len(hello.GetConflicts().Relations) == 0)
+}
+
 // vim: foldmethod=marker
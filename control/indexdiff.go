@@ -0,0 +1,279 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control // import "github.com/ebikt/go-debian/control"
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/ebikt/go-debian/version"
+)
+
+// Index diffs {{{
+
+// The kinds of IndexChanges.
+const (
+	IndexAdded      = "added"
+	IndexRemoved    = "removed"
+	IndexUpgraded   = "upgraded"
+	IndexDowngraded = "downgraded"
+)
+
+// An IndexChange is a difference between two versions of a Packages or
+// Sources index, such as a package upgraded from one day to the next.
+type IndexChange struct {
+	// One of IndexAdded, IndexRemoved, IndexUpgraded and IndexDowngraded.
+	Kind string
+
+	Package string
+
+	// Architecture of the binary package, empty for source packages.
+	Architecture string
+
+	// The version of the package in the old index, empty if added, and
+	// the one in the new index, empty if removed.
+	Old version.Version
+	New version.Version
+}
+
+func (c IndexChange) String() string {
+	name := c.Package
+	if c.Architecture != "" {
+		name += ":" + c.Architecture
+	}
+	switch c.Kind {
+	case IndexAdded:
+		return fmt.Sprintf("%s %s %s", c.Kind, name, c.New)
+	case IndexRemoved:
+		return fmt.Sprintf("%s %s %s", c.Kind, name, c.Old)
+	}
+	return fmt.Sprintf("%s %s %s -> %s", c.Kind, name, c.Old, c.New)
+}
+
+// An IndexChangeFunc is called for each change found by DiffBinaryIndexes
+// or DiffSourceIndexes. Returning an error stops the diff, which returns it.
+type IndexChangeFunc func(change IndexChange) error
+
+type indexDiffEntry struct {
+	arch    string
+	version version.Version
+}
+
+// indexDiffStream reads the entries of an index, a package at a time.
+type indexDiffStream struct {
+	reader  *indexReader
+	binary  bool
+	name    string
+	pending *Paragraph
+	done    bool
+}
+
+func newIndexDiffStream(reader io.Reader, binary bool) (*indexDiffStream, error) {
+	indexReader, err := newIndexReader(reader)
+	if err != nil {
+		return nil, err
+	}
+	indexReader.SetFields("Package", "Version", "Architecture")
+	return &indexDiffStream{reader: indexReader, binary: binary}, nil
+}
+
+// next reads the entries of the next package, returning its name, or ""
+// past the last one. Packages have to come sorted by name.
+func (s *indexDiffStream) next() (string, []indexDiffEntry, error) {
+	entries := []indexDiffEntry{}
+	name := ""
+	for !s.done {
+		para := s.pending
+		s.pending = nil
+		if para == nil {
+			var err error
+			if para, err = s.reader.paragraphs.Next(); err == io.EOF {
+				s.done = true
+				break
+			} else if err != nil {
+				return "", nil, err
+			}
+		}
+		current := para.Get("Package")
+		if current == "" {
+			return "", nil, fmt.Errorf("Index entry without a Package")
+		}
+		if name == "" {
+			if current < s.name {
+				return "", nil, fmt.Errorf("Index isn't sorted by package name: %s comes after %s", current, s.name)
+			}
+			name = current
+		} else if current != name {
+			s.pending = para
+			break
+		}
+		ver, err := version.Parse(para.Get("Version"))
+		if err != nil {
+			return "", nil, fmt.Errorf("Invalid Version of %s: %s", current, err)
+		}
+		entry := indexDiffEntry{version: ver}
+		if s.binary {
+			entry.arch = para.Get("Architecture")
+		}
+		entries = append(entries, entry)
+	}
+	if name != "" {
+		s.name = name
+	}
+	return name, entries, nil
+}
+
+// diffIndexPackage reports the changes between the entries of a package
+// in the old and new indexes. Per architecture, the versions gone and the
+// versions new are removals and additions, but for the highest of each,
+// which make an upgrade or a downgrade.
+func diffIndexPackage(name string, old, new []indexDiffEntry, fn IndexChangeFunc) error {
+	versions := func(entries []indexDiffEntry) map[string]map[string]version.Version {
+		ret := map[string]map[string]version.Version{}
+		for _, entry := range entries {
+			if ret[entry.arch] == nil {
+				ret[entry.arch] = map[string]version.Version{}
+			}
+			ret[entry.arch][entry.version.String()] = entry.version
+		}
+		return ret
+	}
+	oldVersions, newVersions := versions(old), versions(new)
+	archs := []string{}
+	for arch := range oldVersions {
+		archs = append(archs, arch)
+	}
+	for arch := range newVersions {
+		if _, ok := oldVersions[arch]; !ok {
+			archs = append(archs, arch)
+		}
+	}
+	sort.Strings(archs)
+
+	for _, arch := range archs {
+		gone, added := version.Slice{}, version.Slice{}
+		for key, ver := range oldVersions[arch] {
+			if _, ok := newVersions[arch][key]; !ok {
+				gone = append(gone, ver)
+			}
+		}
+		for key, ver := range newVersions[arch] {
+			if _, ok := oldVersions[arch][key]; !ok {
+				added = append(added, ver)
+			}
+		}
+		sort.Sort(gone)
+		sort.Sort(added)
+
+		changes := []IndexChange{}
+		if len(gone) > 0 && len(added) > 0 {
+			change := IndexChange{Kind: IndexUpgraded, Package: name, Architecture: arch,
+				Old: gone[len(gone)-1], New: added[len(added)-1]}
+			if version.Compare(change.New, change.Old) < 0 {
+				change.Kind = IndexDowngraded
+			}
+			changes = append(changes, change)
+			gone, added = gone[:len(gone)-1], added[:len(added)-1]
+		}
+		for _, ver := range gone {
+			changes = append(changes, IndexChange{Kind: IndexRemoved, Package: name, Architecture: arch, Old: ver})
+		}
+		for _, ver := range added {
+			changes = append(changes, IndexChange{Kind: IndexAdded, Package: name, Architecture: arch, New: ver})
+		}
+		for _, change := range changes {
+			if err := fn(change); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func diffIndexes(old, new io.Reader, binary bool, fn IndexChangeFunc) error {
+	oldStream, err := newIndexDiffStream(old, binary)
+	if err != nil {
+		return err
+	}
+	newStream, err := newIndexDiffStream(new, binary)
+	if err != nil {
+		return err
+	}
+
+	oldName, oldEntries, err := oldStream.next()
+	if err != nil {
+		return err
+	}
+	newName, newEntries, err := newStream.next()
+	if err != nil {
+		return err
+	}
+	for oldName != "" || newName != "" {
+		switch {
+		case newName == "" || (oldName != "" && oldName < newName):
+			if err := diffIndexPackage(oldName, oldEntries, nil, fn); err != nil {
+				return err
+			}
+			if oldName, oldEntries, err = oldStream.next(); err != nil {
+				return err
+			}
+		case oldName == "" || newName < oldName:
+			if err := diffIndexPackage(newName, nil, newEntries, fn); err != nil {
+				return err
+			}
+			if newName, newEntries, err = newStream.next(); err != nil {
+				return err
+			}
+		default:
+			if err := diffIndexPackage(oldName, oldEntries, newEntries, fn); err != nil {
+				return err
+			}
+			if oldName, oldEntries, err = oldStream.next(); err != nil {
+				return err
+			}
+			if newName, newEntries, err = newStream.next(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// DiffBinaryIndexes compares two versions of a Packages index, possibly
+// compressed, such as the ones of yesterday and today, and calls the
+// function for each package added, removed, upgraded or downgraded, per
+// architecture, in order of package names. The indexes are read as they
+// are compared, without holding them in memory: they have to be sorted
+// by package name, as dak and reprepro write them.
+func DiffBinaryIndexes(old, new io.Reader, fn IndexChangeFunc) error {
+	return diffIndexes(old, new, true, fn)
+}
+
+// DiffSourceIndexes compares two versions of a Sources index, see
+// DiffBinaryIndexes.
+func DiffSourceIndexes(old, new io.Reader, fn IndexChangeFunc) error {
+	return diffIndexes(old, new, false, fn)
+}
+
+// }}}
+
+// vim: foldmethod=marker
@@ -0,0 +1,145 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control_test
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/ebikt/go-debian/control"
+)
+
+/*
+ *
+ */
+
+func diffBinaryIndexes(t *testing.T, old, new string) string {
+	changes := []string{}
+	isok(t, control.DiffBinaryIndexes(strings.NewReader(old), strings.NewReader(new),
+		func(change control.IndexChange) error {
+			changes = append(changes, change.String())
+			return nil
+		}))
+	return strings.Join(changes, "\n")
+}
+
+func TestDiffBinaryIndexes(t *testing.T) {
+	old := `Package: gone
+Version: 1.0-1
+Architecture: all
+
+Package: hello
+Version: 2.10-2
+Architecture: amd64
+
+Package: hello
+Version: 2.10-2
+Architecture: arm64
+
+Package: same
+Version: 1
+Architecture: all
+
+Package: vim
+Version: 2:9.0-2
+Architecture: amd64
+`
+	new := `Package: hello
+Version: 2.10-3
+Architecture: amd64
+
+Package: hello
+Version: 2.10-2
+Architecture: arm64
+
+Package: hello
+Version: 2.10-2
+Architecture: i386
+
+Package: new
+Version: 0.1-1
+Architecture: all
+
+Package: same
+Version: 1
+Architecture: all
+
+Package: vim
+Version: 2:8.2-1
+Architecture: amd64
+`
+	diff := diffBinaryIndexes(t, old, new)
+	expected := `removed gone:all 1.0-1
+upgraded hello:amd64 2.10-2 -> 2.10-3
+added hello:i386 2.10-2
+added new:all 0.1-1
+downgraded vim:amd64 2:9.0-2 -> 2:8.2-1`
+	if diff != expected {
+		t.Fatalf("Unexpected diff:\n%s", diff)
+	}
+
+	assert(t, diffBinaryIndexes(t, old, old) == "")
+	assert(t, diffBinaryIndexes(t, "", new) == strings.Replace(
+		"added hello:amd64 2.10-3|added hello:arm64 2.10-2|added hello:i386 2.10-2|added new:all 0.1-1|added same:all 1|added vim:amd64 2:8.2-1",
+		"|", "\n", -1))
+}
+
+func TestDiffBinaryIndexesCompressed(t *testing.T) {
+	old := compressIndex(t, "gz", "Package: hello\nVersion: 2.10-2\nArchitecture: amd64\n")
+	new := compressIndex(t, "xz", "Package: hello\nVersion: 2.10-3\nArchitecture: amd64\n")
+	changes := []control.IndexChange{}
+	isok(t, control.DiffBinaryIndexes(bytes.NewReader(old), bytes.NewReader(new),
+		func(change control.IndexChange) error {
+			changes = append(changes, change)
+			return nil
+		}))
+	assert(t, len(changes) == 1)
+	assert(t, changes[0].Kind == control.IndexUpgraded)
+	assert(t, changes[0].Old.String() == "2.10-2")
+	assert(t, changes[0].New.String() == "2.10-3")
+}
+
+func TestDiffSourceIndexes(t *testing.T) {
+	old := "Package: hello\nVersion: 2.10-2\nArchitecture: any\n\nPackage: hello\nVersion: 2.9-1\nArchitecture: any\n"
+	new := "Package: hello\nVersion: 2.10-3\nArchitecture: any\n"
+	changes := []string{}
+	isok(t, control.DiffSourceIndexes(strings.NewReader(old), strings.NewReader(new),
+		func(change control.IndexChange) error {
+			changes = append(changes, change.String())
+			return nil
+		}))
+	assert(t, strings.Join(changes, "\n") == "upgraded hello 2.10-2 -> 2.10-3\nremoved hello 2.9-1")
+}
+
+func TestDiffIndexesErrors(t *testing.T) {
+	unsorted := "Package: vim\nVersion: 1\n\nPackage: hello\nVersion: 1\n"
+	notok(t, control.DiffSourceIndexes(strings.NewReader(""), strings.NewReader(unsorted),
+		func(control.IndexChange) error { return nil }))
+
+	stop := fmt.Errorf("stop")
+	err := control.DiffSourceIndexes(strings.NewReader(""), strings.NewReader("Package: hello\nVersion: 1\n"),
+		func(control.IndexChange) error { return stop })
+	assert(t, err == stop)
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,126 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control // import "github.com/ebikt/go-debian/control"
+
+import (
+	"io"
+
+	"github.com/ebikt/go-debian/hashio"
+)
+
+// Streaming index readers {{{
+
+// indexReader reads the paragraphs of an index one at a time, decompressing
+// it on the fly.
+type indexReader struct {
+	paragraphs *ParagraphReader
+	closer     io.Closer
+}
+
+func newIndexReader(reader io.Reader) (*indexReader, error) {
+	decompressed, err := hashio.NewDecompressingReader(reader)
+	if err != nil {
+		return nil, err
+	}
+	paragraphs, err := NewParagraphReader(decompressed, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &indexReader{paragraphs: paragraphs}, nil
+}
+
+func (r *indexReader) next(into interface{}) error {
+	para, err := r.paragraphs.Next()
+	if err != nil {
+		return err
+	}
+	return UnpackFromParagraph(*para, into)
+}
+
+// SetFields only decodes the given fields of the entries read from now on,
+// see ParagraphReader.SetFields.
+func (r *indexReader) SetFields(fields ...string) {
+	r.paragraphs.SetFields(fields...)
+}
+
+// Close closes the index file, when the reader was opened from one.
+func (r *indexReader) Close() error {
+	if r.closer == nil {
+		return nil
+	}
+	return r.closer.Close()
+}
+
+// BinaryIndexReader reads the entries of a Packages index one at a time,
+// so that the largest indexes can be scanned without holding them in
+// memory. Indexes compressed with gzip, xz, bzip2 or zstd are decompressed
+// on the fly.
+type BinaryIndexReader struct {
+	*indexReader
+}
+
+// NewBinaryIndexReader creates a BinaryIndexReader reading the (possibly
+// compressed) Packages index from the reader.
+func NewBinaryIndexReader(reader io.Reader) (*BinaryIndexReader, error) {
+	ret, err := newIndexReader(reader)
+	if err != nil {
+		return nil, err
+	}
+	return &BinaryIndexReader{ret}, nil
+}
+
+// Next returns the next entry of the index, or io.EOF at its end.
+func (r *BinaryIndexReader) Next() (*BinaryIndex, error) {
+	ret := BinaryIndex{}
+	if err := r.next(&ret); err != nil {
+		return nil, err
+	}
+	return &ret, nil
+}
+
+// SourceIndexReader reads the entries of a Sources index one at a time,
+// see BinaryIndexReader.
+type SourceIndexReader struct {
+	*indexReader
+}
+
+// NewSourceIndexReader creates a SourceIndexReader reading the (possibly
+// compressed) Sources index from the reader.
+func NewSourceIndexReader(reader io.Reader) (*SourceIndexReader, error) {
+	ret, err := newIndexReader(reader)
+	if err != nil {
+		return nil, err
+	}
+	return &SourceIndexReader{ret}, nil
+}
+
+// Next returns the next entry of the index, or io.EOF at its end.
+func (r *SourceIndexReader) Next() (*SourceIndex, error) {
+	ret := SourceIndex{}
+	if err := r.next(&ret); err != nil {
+		return nil, err
+	}
+	return &ret, nil
+}
+
+// }}}
+
+// vim: foldmethod=marker
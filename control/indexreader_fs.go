@@ -0,0 +1,64 @@
+//go:build !pureparser
+// +build !pureparser
+
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control // import "github.com/ebikt/go-debian/control"
+
+import (
+	"os"
+)
+
+// OpenBinaryIndex opens the (possibly compressed) Packages index at the
+// given path, to read it one entry at a time. The reader has to be closed.
+func OpenBinaryIndex(path string) (*BinaryIndexReader, error) {
+	reader, err := openIndex(path)
+	if err != nil {
+		return nil, err
+	}
+	return &BinaryIndexReader{reader}, nil
+}
+
+// OpenSourceIndex opens the (possibly compressed) Sources index at the
+// given path, to read it one entry at a time. The reader has to be closed.
+func OpenSourceIndex(path string) (*SourceIndexReader, error) {
+	reader, err := openIndex(path)
+	if err != nil {
+		return nil, err
+	}
+	return &SourceIndexReader{reader}, nil
+}
+
+func openIndex(path string) (*indexReader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := newIndexReader(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	reader.closer = f
+	return reader, nil
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,57 @@
+//go:build !pureparser
+// +build !pureparser
+
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control_test
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ebikt/go-debian/control"
+)
+
+/*
+ *
+ */
+
+func TestOpenSourceIndex(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "Sources.xz")
+	isok(t, os.WriteFile(path, compressIndex(t, "xz", "Package: hello\nBinary: hello, hello-doc\nVersion: 2.10-3\n"), 0644))
+
+	reader, err := control.OpenSourceIndex(path)
+	isok(t, err)
+	defer reader.Close()
+	entry, err := reader.Next()
+	isok(t, err)
+	assert(t, entry.Package == "hello" && len(entry.Binaries) == 2)
+	_, err = reader.Next()
+	assert(t, err == io.EOF)
+
+	_, err = control.OpenBinaryIndex(filepath.Join(dir, "Packages"))
+	notok(t, err)
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,104 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/ebikt/go-debian/control"
+	"github.com/ebikt/go-debian/hashio"
+)
+
+/*
+ *
+ */
+
+const streamedPackages = "Package: hello\nVersion: 2.10-3\n\nPackage: hello-doc\nVersion: 2.10-3\n"
+
+func compressIndex(t *testing.T, name, data string) []byte {
+	compressor, err := hashio.GetCompressor(name)
+	isok(t, err)
+	out := bytes.Buffer{}
+	writer, err := compressor(&out)
+	isok(t, err)
+	_, err = writer.Write([]byte(data))
+	isok(t, err)
+	isok(t, writer.Close())
+	return out.Bytes()
+}
+
+func readBinaryIndex(t *testing.T, reader *control.BinaryIndexReader) string {
+	names := []string{}
+	for {
+		entry, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		isok(t, err)
+		names = append(names, entry.Package+"="+entry.Version.String())
+	}
+	return strings.Join(names, " ")
+}
+
+func TestBinaryIndexReader(t *testing.T) {
+	bzipped, err := base64.StdEncoding.DecodeString("QlpoOTFBWSZTWUGx3BIAAA1bgAAQQAN4EEEALu2YACAAQFVTQB6jQaNAoNGjQZAaZJVnBmuhCGiFXLUm8Ryyo7UXtXyjZ6hJX1e+fi7kinChIINjuCQ=")
+	isok(t, err)
+
+	for name, data := range map[string][]byte{
+		"plain": []byte(streamedPackages),
+		"gz":    compressIndex(t, "gz", streamedPackages),
+		"xz":    compressIndex(t, "xz", streamedPackages),
+		"zst":   compressIndex(t, "zst", streamedPackages),
+		"bz2":   bzipped,
+	} {
+		reader, err := control.NewBinaryIndexReader(bytes.NewReader(data))
+		isok(t, err)
+		if got := readBinaryIndex(t, reader); got != "hello=2.10-3 hello-doc=2.10-3" {
+			t.Errorf("Unexpected %s index: %s", name, got)
+		}
+	}
+
+	reader, err := control.NewBinaryIndexReader(strings.NewReader(streamedPackages + "Description: skipped\n"))
+	isok(t, err)
+	reader.SetFields("Package")
+	entry, err := reader.Next()
+	isok(t, err)
+	assert(t, entry.Package == "hello" && entry.Version.String() == "")
+	entry, err = reader.Next()
+	isok(t, err)
+	assert(t, entry.Package == "hello-doc" && entry.Description == "")
+
+	reader, err = control.NewBinaryIndexReader(strings.NewReader(""))
+	isok(t, err)
+	_, err = reader.Next()
+	assert(t, err == io.EOF)
+
+	reader, err = control.NewBinaryIndexReader(strings.NewReader("Package: hello\nVersion: :\n"))
+	isok(t, err)
+	_, err = reader.Next()
+	notok(t, err)
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,186 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control // import "github.com/ebikt/go-debian/control"
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// JSON {{{
+
+// MarshalJSON encodes the Paragraph as a JSON object of its fields, in
+// their order, such as {"Package": "hello", "Version": "2.10-3"}.
+func (p Paragraph) MarshalJSON() ([]byte, error) {
+	out := bytes.Buffer{}
+	out.WriteString("{")
+	for i, key := range p.Order {
+		if i > 0 {
+			out.WriteString(",")
+		}
+		encodedKey, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		encodedValue, err := json.Marshal(p.Get(key))
+		if err != nil {
+			return nil, err
+		}
+		out.Write(encodedKey)
+		out.WriteString(":")
+		out.Write(encodedValue)
+	}
+	out.WriteString("}")
+	return out.Bytes(), nil
+}
+
+// UnmarshalJSON decodes a JSON object of strings into the Paragraph, its
+// fields in the order of the object. JSON null leaves the Paragraph
+// untouched.
+func (p *Paragraph) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if token != json.Delim('{') {
+		return fmt.Errorf("Expected a JSON object for a Paragraph")
+	}
+
+	ret := NewParagraph()
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		key := token.(string)
+		var value string
+		if err := decoder.Decode(&value); err != nil {
+			return fmt.Errorf("Field %s: %s", key, err)
+		}
+		if ret.Has(key) {
+			return fmt.Errorf("Duplicate field %s", key)
+		}
+		ret.Set(key, value)
+	}
+	if _, err := decoder.Token(); err != nil {
+		return err
+	}
+	*p = ret
+	return nil
+}
+
+// MarshalJSON encodes a pointer to a struct, such as a *BinaryIndex, as the
+// JSON object of the Paragraph ConvertToParagraph turns it into: the
+// fields are in their textual form, as in a control file, which is the
+// canonical one.
+//
+// The types of this package embedding a Paragraph are encoded that way by
+// json.Marshal, and decoded back by json.Unmarshal.
+func MarshalJSON(incoming interface{}) ([]byte, error) {
+	para, err := ConvertToParagraph(incoming)
+	if err != nil {
+		return nil, err
+	}
+	return para.MarshalJSON()
+}
+
+// UnmarshalJSON decodes a JSON object of fields, as written by MarshalJSON,
+// into a pointer to a struct, as UnpackFromParagraph does.
+func UnmarshalJSON(data []byte, incoming interface{}) error {
+	para := NewParagraph()
+	if err := para.UnmarshalJSON(data); err != nil {
+		return err
+	}
+	return UnpackFromParagraph(para, incoming)
+}
+
+func (index BinaryIndex) MarshalJSON() ([]byte, error) {
+	return MarshalJSON(&index)
+}
+
+func (index *BinaryIndex) UnmarshalJSON(data []byte) error {
+	return UnmarshalJSON(data, index)
+}
+
+func (index SourceIndex) MarshalJSON() ([]byte, error) {
+	return MarshalJSON(&index)
+}
+
+func (index *SourceIndex) UnmarshalJSON(data []byte) error {
+	return UnmarshalJSON(data, index)
+}
+
+func (t TranslationIndex) MarshalJSON() ([]byte, error) {
+	return MarshalJSON(&t)
+}
+
+func (t *TranslationIndex) UnmarshalJSON(data []byte) error {
+	return UnmarshalJSON(data, t)
+}
+
+func (s SourceParagraph) MarshalJSON() ([]byte, error) {
+	return MarshalJSON(&s)
+}
+
+func (s *SourceParagraph) UnmarshalJSON(data []byte) error {
+	return UnmarshalJSON(data, s)
+}
+
+func (b BinaryParagraph) MarshalJSON() ([]byte, error) {
+	return MarshalJSON(&b)
+}
+
+func (b *BinaryParagraph) UnmarshalJSON(data []byte) error {
+	return UnmarshalJSON(data, b)
+}
+
+func (d DSC) MarshalJSON() ([]byte, error) {
+	return MarshalJSON(&d)
+}
+
+func (d *DSC) UnmarshalJSON(data []byte) error {
+	return UnmarshalJSON(data, d)
+}
+
+func (c Changes) MarshalJSON() ([]byte, error) {
+	return MarshalJSON(&c)
+}
+
+func (c *Changes) UnmarshalJSON(data []byte) error {
+	return UnmarshalJSON(data, c)
+}
+
+func (b Buildinfo) MarshalJSON() ([]byte, error) {
+	return MarshalJSON(&b)
+}
+
+func (b *Buildinfo) UnmarshalJSON(data []byte) error {
+	return UnmarshalJSON(data, b)
+}
+
+// }}}
+
+// vim: foldmethod=marker
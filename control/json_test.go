@@ -0,0 +1,89 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/ebikt/go-debian/control"
+)
+
+/*
+ *
+ */
+
+func TestParagraphJSON(t *testing.T) {
+	para := control.NewParagraph()
+	para.Set("Package", "hello")
+	para.Set("Version", "2.10-3")
+	para.Set("Description", "example package\n\"quoted\" text")
+
+	data, err := json.Marshal(para)
+	isok(t, err)
+	assert(t, string(data) ==
+		`{"Package":"hello","Version":"2.10-3","Description":"example package\n\"quoted\" text"}`)
+
+	decoded := control.Paragraph{}
+	isok(t, json.Unmarshal(data, &decoded))
+	assert(t, strings.Join(decoded.Order, " ") == "Package Version Description")
+	assert(t, decoded.Get("description") == para.Get("Description"))
+
+	for _, data := range []string{`[]`, `{"Package": 1}`, `{"A": "b", "a": "c"}`, `{"A": "b"`} {
+		notok(t, json.Unmarshal([]byte(data), &decoded))
+	}
+}
+
+func TestIndexJSON(t *testing.T) {
+	binaries, err := control.ParseBinaryIndex(bufio.NewReader(strings.NewReader(`Package: hello
+Version: 2.10-3
+Architecture: amd64
+Depends: libc6 (= 2.36-9)
+X-Custom: kept
+`)))
+	isok(t, err)
+
+	data, err := json.Marshal(binaries)
+	isok(t, err)
+	assert(t, strings.Contains(string(data), `"Depends":"libc6 (= 2.36-9)"`))
+	assert(t, strings.Contains(string(data), `"X-Custom":"kept"`))
+
+	decoded := []control.BinaryIndex{}
+	isok(t, json.Unmarshal(data, &decoded))
+	assert(t, len(decoded) == 1)
+	assert(t, decoded[0].Package == "hello")
+	assert(t, decoded[0].Version.String() == "2.10-3")
+	assert(t, decoded[0].Architecture.String() == "amd64")
+	assert(t, decoded[0].GetDepends().String() == "libc6 (= 2.36-9)")
+	assert(t, decoded[0].Get("X-Custom") == "kept")
+
+	/* Entries built by hand are encoded from their fields */
+	source := control.SourceIndex{Package: "hello", Binaries: []string{"hello"}}
+	data, err = json.Marshal(source)
+	isok(t, err)
+	assert(t, strings.Contains(string(data), `"Package":"hello"`))
+
+	notok(t, json.Unmarshal([]byte(`{"Version": "not a version!"}`), &decoded[0]))
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,171 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control // import "github.com/ebikt/go-debian/control"
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ebikt/go-debian/dependency"
+)
+
+// {{{ Package-List entries
+
+// A PackageListEntry is a line of the Package-List field of a .dsc file or
+// of a Sources index, describing one of the binary packages the source
+// package builds:
+//
+//   Package-List:
+//    hello deb devel optional arch=any profile=!nocheck
+//    hello-udeb udeb debian-installer optional arch=linux-any essential=yes
+type PackageListEntry struct {
+	Name     string
+	Type     string
+	Section  string
+	Priority string
+
+	// Architectures the package is built on, from the "arch" key. The
+	// key is absent from old source packages, in which case this is
+	// empty.
+	Architectures []dependency.Arch
+
+	// Build profiles the package is built with, from the "profile" key,
+	// as a disjunction of conjunctions of profiles, like in
+	// Build-Depends restriction formulas.
+	Profiles []dependency.StageSet
+
+	Essential bool
+
+	// Any other key=value pairs.
+	Extra map[string]string
+}
+
+func (e *PackageListEntry) UnmarshalControl(data string) error {
+	vals := strings.Fields(data)
+	if len(vals) < 4 {
+		return fmt.Errorf("Malformed Package-List line: '%s'", data)
+	}
+	*e = PackageListEntry{Name: vals[0], Type: vals[1], Section: vals[2], Priority: vals[3]}
+	for _, option := range vals[4:] {
+		kv := strings.SplitN(option, "=", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("Malformed Package-List option '%s' of %s", option, e.Name)
+		}
+		switch kv[0] {
+		case "arch":
+			for _, name := range strings.Split(kv[1], ",") {
+				arch, err := dependency.ParseArch(name)
+				if err != nil {
+					return err
+				}
+				e.Architectures = append(e.Architectures, *arch)
+			}
+		case "profile":
+			for _, set := range strings.Split(kv[1], "+") {
+				stageSet := dependency.StageSet{}
+				for _, name := range strings.Split(set, ",") {
+					stage := dependency.Stage{Not: strings.HasPrefix(name, "!"), Name: strings.TrimPrefix(name, "!")}
+					if stage.Name == "" {
+						return fmt.Errorf("Empty profile in '%s' of %s", kv[1], e.Name)
+					}
+					stageSet.Stages = append(stageSet.Stages, stage)
+				}
+				e.Profiles = append(e.Profiles, stageSet)
+			}
+		case "essential":
+			e.Essential = kv[1] == "yes"
+		default:
+			if e.Extra == nil {
+				e.Extra = map[string]string{}
+			}
+			e.Extra[kv[0]] = kv[1]
+		}
+	}
+	return nil
+}
+
+func (e PackageListEntry) MarshalControl() (string, error) {
+	ret := []string{e.Name, e.Type, e.Section, e.Priority}
+	if len(e.Architectures) > 0 {
+		arches := []string{}
+		for _, arch := range e.Architectures {
+			arches = append(arches, arch.String())
+		}
+		ret = append(ret, "arch="+strings.Join(arches, ","))
+	}
+	if len(e.Profiles) > 0 {
+		sets := []string{}
+		for _, set := range e.Profiles {
+			stages := []string{}
+			for _, stage := range set.Stages {
+				stages = append(stages, stage.String())
+			}
+			sets = append(sets, strings.Join(stages, ","))
+		}
+		ret = append(ret, "profile="+strings.Join(sets, "+"))
+	}
+	if e.Essential {
+		ret = append(ret, "essential=yes")
+	}
+	keys := []string{}
+	for key := range e.Extra {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		ret = append(ret, key+"="+e.Extra[key])
+	}
+	return strings.Join(ret, " "), nil
+}
+
+// BuildsOn returns true if the package is built on the given architecture,
+// which is assumed when the entry has no architecture list.
+func (e PackageListEntry) BuildsOn(arch dependency.Arch) bool {
+	if len(e.Architectures) == 0 {
+		return true
+	}
+	for _, candidate := range e.Architectures {
+		if arch.Is(&candidate) {
+			return true
+		}
+	}
+	return false
+}
+
+// BuiltWith returns true if the package is built when building with the
+// given profiles enabled, which is always the case when the entry has no
+// profile restriction.
+func (e PackageListEntry) BuiltWith(profiles []string) bool {
+	if len(e.Profiles) == 0 {
+		return true
+	}
+	for _, set := range e.Profiles {
+		if set.Matches(profiles) {
+			return true
+		}
+	}
+	return false
+}
+
+// }}}
+
+// vim: foldmethod=marker
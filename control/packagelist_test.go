@@ -0,0 +1,70 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control_test
+
+import (
+	"testing"
+
+	"github.com/ebikt/go-debian/control"
+	"github.com/ebikt/go-debian/dependency"
+)
+
+/*
+ *
+ */
+
+func TestPackageListEntry(t *testing.T) {
+	entry := control.PackageListEntry{}
+	isok(t, entry.UnmarshalControl("hello-udeb udeb debian-installer optional arch=amd64,hurd-i386 profile=!nocheck,!noudeb+pkg.hello.udeb essential=yes foo=bar"))
+	assert(t, entry.Name == "hello-udeb")
+	assert(t, entry.Type == "udeb")
+	assert(t, entry.Section == "debian-installer")
+	assert(t, entry.Priority == "optional")
+	assert(t, len(entry.Architectures) == 2)
+	assert(t, entry.Architectures[1].OS == "hurd")
+	assert(t, len(entry.Profiles) == 2)
+	assert(t, entry.Profiles[0].Stages[1].Not)
+	assert(t, entry.Profiles[0].Stages[1].Name == "noudeb")
+	assert(t, entry.Profiles[1].Stages[0].Name == "pkg.hello.udeb")
+	assert(t, entry.Essential)
+	assert(t, entry.Extra["foo"] == "bar")
+
+	amd64, err := dependency.ParseArch("amd64")
+	isok(t, err)
+	kfreebsd, err := dependency.ParseArch("kfreebsd-amd64")
+	isok(t, err)
+	assert(t, entry.BuildsOn(*amd64))
+	assert(t, !entry.BuildsOn(*kfreebsd))
+
+	assert(t, entry.BuiltWith(nil))
+	assert(t, !entry.BuiltWith([]string{"noudeb"}))
+	assert(t, entry.BuiltWith([]string{"noudeb", "pkg.hello.udeb"}))
+
+	line, err := entry.MarshalControl()
+	isok(t, err)
+	assert(t, line == "hello-udeb udeb debian-installer optional arch=amd64,hurd-i386 profile=!nocheck,!noudeb+pkg.hello.udeb essential=yes foo=bar")
+
+	notok(t, entry.UnmarshalControl("hello deb devel"))
+	notok(t, entry.UnmarshalControl("hello deb devel optional arch"))
+	notok(t, entry.UnmarshalControl("hello deb devel optional profile=!"))
+}
+
+// vim: foldmethod=marker
@@ -25,12 +25,10 @@ import (
 	"bytes"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"strings"
 	"unicode"
 
 	"golang.org/x/crypto/openpgp"
-	"golang.org/x/crypto/openpgp/clearsign"
 )
 
 // A Paragraph is a block of RFC2822-like key value pairs. This struct contains
@@ -41,6 +39,10 @@ import (
 type Paragraph struct {
 	values map[string]string
 	Order  []string
+
+	// The fields it was read with, when read by a ParagraphReader which
+	// only keeps some of them; nil for all of them.
+	fields []string
 }
 
 // Paragraph Helpers {{{
@@ -69,6 +71,22 @@ func (p *Paragraph) Set(key, value string) {
 	p.values[k] = value
 }
 
+// Delete removes the key from the Paragraph, if it's there.
+func (p *Paragraph) Delete(key string) {
+	k := strings.ToLower(key)
+	if _, found := p.values[k]; !found {
+		return
+	}
+	delete(p.values, k)
+	order := []string{}
+	for _, el := range p.Order {
+		if strings.ToLower(el) != k {
+			order = append(order, el)
+		}
+	}
+	p.Order = order
+}
+
 func (p Paragraph) Get2(key string) (string, bool) {
 	if v, found := p.values[key]; found {
 		return v, true
@@ -82,6 +100,21 @@ func (p Paragraph) Get(key string) string {
 	return v
 }
 
+// Projects returns whether the key was read into the Paragraph, if it was
+// in the input: true unless the Paragraph was read by a ParagraphReader
+// keeping only some fields, which don't include it.
+func (p Paragraph) Projects(key string) bool {
+	if p.fields == nil {
+		return true
+	}
+	for _, field := range p.fields {
+		if strings.EqualFold(key, field) {
+			return true
+		}
+	}
+	return false
+}
+
 func (p Paragraph) Has(key string) bool {
 	_, b := p.Get2(key)
 	return b
@@ -89,13 +122,8 @@ func (p Paragraph) Has(key string) bool {
 
 func (p *Paragraph) WriteTo(out io.Writer) error {
 	for _, key := range p.Order {
-		value := p.Get(key)
-
-		value = strings.Replace(value, "\n", "\n ", -1)
-		value = strings.Replace(value, "\n \n", "\n .\n", -1)
-
 		if _, err := out.Write(
-			[]byte(fmt.Sprintf("%s: %s\n", key, value)),
+			[]byte(formatField(key, p.Get(key))),
 		); err != nil {
 			return err
 		}
@@ -103,6 +131,56 @@ func (p *Paragraph) WriteTo(out io.Writer) error {
 	return nil
 }
 
+// Fields whose value is a list of lines, which always start on the line
+// after the key. dpkg refuses a Conffiles field with a value on the line
+// of the key.
+var multilineFields = map[string]bool{
+	"changes":          true,
+	"checksums-md5":    true,
+	"checksums-sha1":   true,
+	"checksums-sha256": true,
+	"checksums-sha512": true,
+	"conffiles":        true,
+	"files":            true,
+	"md5sum":           true,
+	"package-list":     true,
+	"sha1":             true,
+	"sha256":           true,
+	"sha512":           true,
+}
+
+// formatField renders a single field, the inverse of what Next does
+// to read it. Values read from a multi-line field end in a newline, and
+// have their first line on the line of the key, unless it's empty or the
+// field is a list of lines.
+func formatField(key, value string) string {
+	lines := strings.Split(value, "\n")
+	first, rest := lines[0], lines[1:]
+	if strings.HasSuffix(value, "\n") {
+		lines = lines[:len(lines)-1]
+		first, rest = lines[0], lines[1:]
+		if len(lines) == 1 || first == "" || multilineFields[strings.ToLower(key)] {
+			first, rest = "", lines
+		}
+	} else if len(lines) > 1 && multilineFields[strings.ToLower(key)] {
+		/* As marshalled from a slice of lines */
+		first, rest = "", lines
+	}
+
+	ret := key + ":"
+	if first != "" {
+		ret += " " + first
+	}
+	ret += "\n"
+	for _, line := range rest {
+		if line == "" {
+			line = "."
+		}
+		ret += " " + line + "\n"
+	}
+	return ret
+}
+
 func (p *Paragraph) Update(other Paragraph) Paragraph {
 	ret := Paragraph{
 		Order:  []string{},
@@ -143,6 +221,11 @@ func (p *Paragraph) Update(other Paragraph) Paragraph {
 type ParagraphReader struct {
 	reader *bufio.Reader
 	signer *openpgp.Entity
+
+	// The fields to keep, all of them if nil.
+	fields []string
+
+	limits Limits
 }
 
 // {{{ NewParagraphReader
@@ -185,6 +268,73 @@ func (p *ParagraphReader) Signer() *openpgp.Entity {
 
 // }}}
 
+// SetFields {{{
+
+// Only keep the given fields (compared case insensitively) of the
+// Paragraphs read from now on, skipping the others without storing
+// them. Reading only the few fields a program uses out of a large index,
+// such as the Package, Version, Filename and SHA256 of a Packages file,
+// takes a fraction of the memory. With no fields, all of them are kept
+// again.
+//
+// Fields which aren't kept aren't checked for duplicates.
+func (p *ParagraphReader) SetFields(fields ...string) {
+	if len(fields) == 0 {
+		p.fields = nil
+		return
+	}
+	p.fields = append([]string{}, fields...)
+}
+
+func (p *ParagraphReader) wants(key []byte) bool {
+	if p.fields == nil {
+		return true
+	}
+	for _, field := range p.fields {
+		if bytes.EqualFold(key, []byte(field)) {
+			return true
+		}
+	}
+	return false
+}
+
+// }}}
+
+// Limits {{{
+
+// Limits bound how much a ParagraphReader reads into memory, which matters
+// when it reads untrusted input. Reading takes time linear in the size of
+// the input, and a limit of zero is no limit at all.
+type Limits struct {
+	// The longest line read, in bytes, including its newline.
+	MaxLineSize int
+
+	// The longest value of a single field, including its continuation
+	// lines, in bytes.
+	MaxFieldSize int
+
+	// The most fields in a single Paragraph, including ones which aren't
+	// kept because of SetFields.
+	MaxFields int
+}
+
+// DefaultLimits are generous enough for any file in the Debian archive,
+// such as the Files of a large source package, and small enough for
+// reading untrusted uploads.
+var DefaultLimits = Limits{
+	MaxLineSize:  64 * 1024,
+	MaxFieldSize: 16 * 1024 * 1024,
+	MaxFields:    1024,
+}
+
+// Enforce the given Limits on the Paragraphs read from now on. Anything
+// exceeding them is an error.
+func (p *ParagraphReader) SetLimits(limits Limits) {
+	p.limits = limits
+}
+
+// }}}
+
 // All {{{
 
 func (p *ParagraphReader) All() ([]Paragraph, error) {
@@ -210,20 +360,37 @@ func (p *ParagraphReader) Next() (*Paragraph, error) {
 	paragraph := Paragraph{
 		Order:  []string{},
 		values: map[string]string{},
+		fields: p.fields,
 	}
 	var lastKey string
 	lowerSeen := make(map[string]bool)
+	/* Whether a key line was read, even if it wasn't kept, and whether
+	 * the lines of the current field are being skipped */
+	seen, skipping := false, false
+	fields := 0
+
+	/* The value of the current field is built up here, and stored once
+	 * it's complete: appending each continuation line to a string would
+	 * copy the value so far every time. */
+	var value strings.Builder
+	flush := func() {
+		if lastKey != "" {
+			paragraph.values[lastKey] = value.String()
+			lastKey = ""
+			value.Reset()
+		}
+	}
 
 	for {
-		line, err := p.reader.ReadString('\n')
-		if err == io.EOF && line != "" {
+		raw, err := p.readLine()
+		if err == io.EOF && len(raw) != 0 {
 			err = nil
-			line = line + "\n"
 			/* We'll clean up the last of the buffer. */
 		}
 		if err == io.EOF {
 			/* Let's return the parsed paragraph if we have it */
-			if len(paragraph.Order) > 0 {
+			if seen {
+				flush()
 				return &paragraph, nil
 			}
 			/* Else, let's go ahead and drop the EOF out raw */
@@ -232,13 +399,19 @@ func (p *ParagraphReader) Next() (*Paragraph, error) {
 			return nil, err
 		}
 
-		if line == "\n" || line == "\r\n" {
+		if len(bytes.TrimSpace(raw)) == 0 {
+			/* Paragraphs are separated by one or more blank lines, which
+			 * may contain whitespace (see deb822(5)). */
+			if !seen {
+				continue
+			}
 			/* Lines are ended by a blank line; so we're able to go ahead
 			 * and return this guy as-is. All set. Done. Finished. */
+			flush()
 			return &paragraph, nil
 		}
 
-		if strings.HasPrefix(line, "#") {
+		if raw[0] == '#' {
 			continue // skip comments
 		}
 
@@ -251,7 +424,11 @@ func (p *ParagraphReader) Next() (*Paragraph, error) {
 		 * Key line is a Key/Value mapping.
 		 */
 
-		if strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") {
+		if raw[0] == ' ' || raw[0] == '\t' {
+			if skipping {
+				continue
+			}
+
 			/* This is a continuation line; so we're going to go ahead and
 			 * clean it up, and throw it into the list. We're going to remove
 			 * the first character (which we now know is whitespace), and if
@@ -262,34 +439,50 @@ func (p *ParagraphReader) Next() (*Paragraph, error) {
 
 			/* TrimFunc(line[1:], unicode.IsSpace) is identical to calling
 			 * TrimSpace. */
-			line = strings.TrimRightFunc(line[1:], unicode.IsSpace)
+			line := strings.TrimRightFunc(string(raw[1:]), unicode.IsSpace)
 
 			if line == "." {
 				line = ""
 			}
 
-			if paragraph.values[lastKey] == "" {
-				paragraph.values[lastKey] = line + "\n"
-			} else {
-				if !strings.HasSuffix(paragraph.values[lastKey], "\n") {
-					paragraph.values[lastKey] = paragraph.values[lastKey] + "\n"
-				}
-				paragraph.values[lastKey] = paragraph.values[lastKey] + line + "\n"
+			if !seen {
+				return nil, fmt.Errorf("Continuation line without a key: '%s'", line)
+			}
+
+			if value.Len() != 0 && !strings.HasSuffix(value.String(), "\n") {
+				value.WriteString("\n")
+			}
+			value.WriteString(line)
+			value.WriteString("\n")
+			if err := p.checkFieldSize(paragraph, &value); err != nil {
+				return nil, err
 			}
 			continue
 		}
 
 		/* So, if we're here, we've got a key line. Let's go ahead and split
 		 * this on the first key, and set that guy */
-		els := strings.SplitN(line, ":", 2)
-		if len(els) != 2 {
-			return nil, fmt.Errorf("Bad line: '%s' has no ':'", line)
+		colon := bytes.IndexByte(raw, ':')
+		if colon < 0 {
+			return nil, fmt.Errorf("Bad line: '%s' has no ':'", raw)
+		}
+		seen = true
+		flush()
+
+		fields++
+		if limit := p.limits.MaxFields; limit > 0 && fields > limit {
+			return nil, fmt.Errorf("More than the limit of %d fields in a paragraph", limit)
+		}
+
+		/* Fields which aren't projected are dropped before anything is
+		 * allocated for them, along with their continuation lines. */
+		if skipping = !p.wants(bytes.TrimSpace(raw[:colon])); skipping {
+			continue
 		}
 
 		/* We'll go ahead and take off any leading spaces */
-		key := strings.TrimSpace(els[0])
+		key := strings.TrimSpace(string(raw[:colon]))
 		lastKey = strings.ToLower(key)
-		value := strings.TrimSpace(els[1])
 
 		if lowerSeen[lastKey] {
 			return nil, fmt.Errorf("Duplicate key '%s'", key)
@@ -298,10 +491,44 @@ func (p *ParagraphReader) Next() (*Paragraph, error) {
 		}
 
 		paragraph.Order = append(paragraph.Order, key)
-		paragraph.values[lastKey] = value
+		value.WriteString(strings.TrimSpace(string(raw[colon+1:])))
+		if err := p.checkFieldSize(paragraph, &value); err != nil {
+			return nil, err
+		}
 	}
 }
 
+func (p *ParagraphReader) checkFieldSize(paragraph Paragraph, value *strings.Builder) error {
+	limit := p.limits.MaxFieldSize
+	if limit <= 0 || value.Len() <= limit {
+		return nil
+	}
+	return fmt.Errorf("Field '%s' is longer than the limit of %d bytes",
+		paragraph.Order[len(paragraph.Order)-1], limit)
+}
+
+// readLine returns the next line, which is only valid until the next call.
+func (p *ParagraphReader) readLine() ([]byte, error) {
+	line, err := p.reader.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		/* Longer than the buffer, which is rare enough to be copied */
+		ret := append([]byte{}, line...)
+		for err == bufio.ErrBufferFull && !p.lineTooLong(ret) {
+			line, err = p.reader.ReadSlice('\n')
+			ret = append(ret, line...)
+		}
+		line = ret
+	}
+	if p.lineTooLong(line) {
+		return nil, fmt.Errorf("Line is longer than the limit of %d bytes", p.limits.MaxLineSize)
+	}
+	return line, err
+}
+
+func (p *ParagraphReader) lineTooLong(line []byte) bool {
+	return p.limits.MaxLineSize > 0 && len(line) > p.limits.MaxLineSize
+}
+
 // }}}
 
 // decodeClearsig {{{
@@ -318,43 +545,18 @@ func (p *ParagraphReader) decodeClearsig(keyring *openpgp.EntityList) error {
 	// clearsigned documents into memory. Which fucking sucks. But here
 	// we are. It's likely worth a bug or two on this.
 
-	signedData, err := ioutil.ReadAll(p.reader)
+	signedData, err := io.ReadAll(p.reader)
 	if err != nil {
 		return err
 	}
 
-	block, _ := clearsign.Decode(signedData)
-	/* We're only interested in the first block. This may change in the
-	 * future, in which case, we should likely set reader back to
-	 * the remainder, and return that out to put through another
-	 * ParagraphReader, since it may have a different signer. */
-
-	if block == nil {
-		return fmt.Errorf("Invalid clearsigned input")
-	}
-
-	if keyring == nil {
-		/* As a special case, if the keyring is nil, we can go ahead
-		 * and assume this data isn't intended to be checked against the
-		 * keyring. So, we'll just pass on through. */
-		p.reader = bufio.NewReader(bytes.NewBuffer(block.Bytes))
-		return nil
-	}
-
-	/* Now, we have to go ahead and check that the signature is valid and
-	 * relates to an entity we have in our keyring */
-	signer, err := openpgp.CheckDetachedSignature(
-		keyring,
-		bytes.NewReader(block.Bytes),
-		block.ArmoredSignature.Body,
-	)
-
+	cleartext, signer, err := verifyClearsigned(signedData, keyring)
 	if err != nil {
 		return err
 	}
 
 	p.signer = signer
-	p.reader = bufio.NewReader(bytes.NewBuffer(block.Bytes))
+	p.reader = bufio.NewReader(bytes.NewBuffer(cleartext))
 
 	return nil
 }
@@ -154,6 +154,27 @@ func TestParagraphSet(t *testing.T) {
 	assert(t, para.Get("yanKee") == "candle")
 	assert(t, para.Order[1] == "british")
 	assert(t, para.Get("brItIsh") == "redcoat")
+	// Deleting a key removes it from both.
+	para.Delete("Yankee")
+	assert(t, len(para.Order) == 1)
+	assert(t, para.Order[0] == "british")
+	assert(t, !para.Has("yankee"))
+	para.Delete("yankee")
+	assert(t, len(para.Order) == 1)
+}
+
+func TestBlankLineSeparators(t *testing.T) {
+	// Reader {{{
+	reader, err := control.NewParagraphReader(strings.NewReader(
+		"\n\nPara: one\n\n\n\nPara: two\n  \t\n# A comment\nPara: three\n\n"), nil)
+	// }}}
+	isok(t, err)
+
+	blocks, err := reader.All()
+	isok(t, err)
+	assert(t, len(blocks) == 3)
+	assert(t, blocks[1].Get("Para") == "two")
+	assert(t, blocks[2].Get("Para") == "three")
 }
 
 func TestWhitespacePrefixedLines(t *testing.T) {
@@ -293,4 +314,83 @@ func TestLineWrapping(t *testing.T) {
 `)
 }
 
+func TestParagraphReaderFields(t *testing.T) {
+	reader, err := control.NewParagraphReader(strings.NewReader(`Package: hello
+Version: 2.10-3
+Description: greeting
+ A long description,
+ which is skipped.
+SHA256: abcd
+Depends: libc6
+Depends: duplicates are only checked in kept fields
+
+# Entries lacking the kept fields are still paragraphs
+Section: devel
+
+Package: hello-doc
+`), nil)
+	isok(t, err)
+	reader.SetFields("package", "Version", "SHA256")
+
+	paragraphs, err := reader.All()
+	isok(t, err)
+	assert(t, len(paragraphs) == 3)
+	assert(t, len(paragraphs[0].Order) == 3)
+	assert(t, paragraphs[0].Get("SHA256") == "abcd")
+	assert(t, !paragraphs[0].Has("Description"))
+	assert(t, paragraphs[0].Projects("version"))
+	assert(t, !paragraphs[0].Projects("Description"))
+	assert(t, len(paragraphs[1].Order) == 0)
+	assert(t, paragraphs[2].Get("Package") == "hello-doc")
+
+	/* Required fields which aren't projected aren't checked */
+	decoder, err := control.NewDecoder(strings.NewReader("Value: foo\nValue-Two: bar\n\nValue: baz\n"), nil)
+	isok(t, err)
+	decoder.SetFields("Value-Two")
+	entry := TestStruct{}
+	isok(t, decoder.Decode(&entry))
+	assert(t, entry.ValueTwo == "bar" && entry.Value == "")
+	decoder.SetFields("Value-Two", "Value")
+	isok(t, decoder.Decode(&entry))
+	assert(t, entry.Value == "baz")
+}
+
+func TestParagraphReaderLimits(t *testing.T) {
+	read := func(in string, limits control.Limits) error {
+		reader, err := control.NewParagraphReader(strings.NewReader(in), nil)
+		isok(t, err)
+		reader.SetLimits(limits)
+		_, err = reader.All()
+		return err
+	}
+
+	in := "Package: foo\nDescription: short\n long\n .\n more\n\nPackage: bar\n"
+	isok(t, read(in, control.Limits{}))
+	isok(t, read(in, control.DefaultLimits))
+	isok(t, read(in, control.Limits{MaxLineSize: 19, MaxFieldSize: 17, MaxFields: 2}))
+	notok(t, read(in, control.Limits{MaxLineSize: 18}))
+	notok(t, read(in, control.Limits{MaxFieldSize: 16}))
+	notok(t, read(in, control.Limits{MaxFields: 1}))
+
+	long := "Package: " + strings.Repeat("x", 10000) + "\n"
+	isok(t, read(long, control.Limits{MaxLineSize: 10010}))
+	notok(t, read(long, control.Limits{MaxLineSize: 10000}))
+	notok(t, read(long, control.Limits{MaxFieldSize: 9999}))
+
+	decoder, err := control.NewDecoder(strings.NewReader(in), nil)
+	isok(t, err)
+	decoder.SetLimits(control.Limits{MaxFields: 1})
+	notok(t, decoder.Decode(&TestStruct{}))
+}
+
+func TestManyContinuationLines(t *testing.T) {
+	/* Each continuation line used to copy the whole value so far */
+	in := "Files:\n" + strings.Repeat(" d41d8cd98f00b204e9800998ecf8427e 0 empty\n", 200000)
+	reader, err := control.NewParagraphReader(strings.NewReader(in), nil)
+	isok(t, err)
+	paragraph, err := reader.Next()
+	isok(t, err)
+	assert(t, len(paragraph.Get("Files")) == len(in)-len("Files:\n")-200000)
+}
+
 // vim: foldmethod=marker
@@ -0,0 +1,189 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control // import "github.com/ebikt/go-debian/control"
+
+import (
+	"fmt"
+	"mime"
+	"strings"
+)
+
+// People {{{
+
+// Person is someone responsible for a package, as named in the
+// Maintainer, Uploaders or Changed-By fields, and in the trailer lines of
+// changelog entries: "Full Name <email>".
+type Person struct {
+	Name  string
+	Email string
+}
+
+// String renders the Person back as "Full Name <email>". Names holding
+// characters that would be mistaken for separators, such as "Doe, Jane",
+// are quoted.
+func (p Person) String() string {
+	if p.Name == "" {
+		return "<" + p.Email + ">"
+	}
+	name := p.Name
+	if strings.ContainsAny(name, `,"<>`) {
+		name = `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(name) + `"`
+	}
+	return name + " <" + p.Email + ">"
+}
+
+func (p Person) MarshalControl() (string, error) {
+	return p.String(), nil
+}
+
+func (p *Person) UnmarshalControl(data string) error {
+	person, err := ParsePerson(data)
+	if err != nil {
+		return err
+	}
+	*p = *person
+	return nil
+}
+
+// validateEmail checks that the address looks like one: a local part and
+// a domain, around a single "@", without blanks.
+func validateEmail(email string) error {
+	at := strings.Index(email, "@")
+	switch {
+	case email == "":
+		return fmt.Errorf("Empty email address")
+	case strings.ContainsAny(email, " \t\n<>,\""):
+		return fmt.Errorf("Invalid character in email address '%s'", email)
+	case at <= 0 || at == len(email)-1 || strings.Count(email, "@") != 1:
+		return fmt.Errorf("Invalid email address '%s'", email)
+	}
+	return nil
+}
+
+// unquoteName strips the quotes around a name, and decodes the RFC 2047
+// encoded words it may hold, such as "=?UTF-8?Q?Ren=C3=A9?=".
+func unquoteName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if len(name) >= 2 && strings.HasPrefix(name, `"`) && strings.HasSuffix(name, `"`) {
+		name = name[1 : len(name)-1]
+		name = strings.NewReplacer(`\\`, `\`, `\"`, `"`).Replace(name)
+	}
+	if strings.Contains(name, "=?") {
+		decoded, err := new(mime.WordDecoder).DecodeHeader(name)
+		if err != nil {
+			return "", fmt.Errorf("Invalid encoded name '%s': %s", name, err)
+		}
+		name = decoded
+	}
+	if strings.ContainsAny(name, "<>") {
+		return "", fmt.Errorf("Invalid character in name '%s'", name)
+	}
+	return name, nil
+}
+
+// ParsePerson parses a "Full Name <email>" value. The name may be quoted,
+// or RFC 2047 encoded, and may be empty, but the email address must be
+// there.
+func ParsePerson(value string) (*Person, error) {
+	value = strings.TrimSpace(value)
+	start := strings.LastIndex(value, "<")
+	if start == -1 || !strings.HasSuffix(value, ">") {
+		return nil, fmt.Errorf("Missing email address in '%s'", value)
+	}
+	name, err := unquoteName(value[:start])
+	if err != nil {
+		return nil, err
+	}
+	email := strings.TrimSpace(value[start+1 : len(value)-1])
+	if err := validateEmail(email); err != nil {
+		return nil, err
+	}
+	return &Person{Name: name, Email: email}, nil
+}
+
+// ParsePeople parses a comma separated list of people, such as the
+// Uploaders field. Commas within quoted names or email addresses don't
+// separate people.
+func ParsePeople(value string) ([]Person, error) {
+	ret := []Person{}
+	inAddress, inQuotes, escaped := false, false, false
+	start := 0
+	for i, c := range value + "," {
+		switch {
+		case escaped:
+			escaped = false
+		case inQuotes && c == '\\':
+			escaped = true
+		case c == '"' && !inAddress:
+			inQuotes = !inQuotes
+		case inQuotes:
+		case c == '<':
+			inAddress = true
+		case c == '>':
+			inAddress = false
+		case c == ',' && !inAddress:
+			if entry := strings.TrimSpace(value[start:i]); entry != "" {
+				person, err := ParsePerson(entry)
+				if err != nil {
+					return nil, err
+				}
+				ret = append(ret, *person)
+			}
+			start = i + 1
+		}
+	}
+	if inQuotes {
+		return nil, fmt.Errorf("Unterminated quoted name in '%s'", value)
+	}
+	return ret, nil
+}
+
+// FormatPeople renders people back as a comma separated list, such as the
+// Uploaders field.
+func FormatPeople(people []Person) string {
+	ret := []string{}
+	for _, person := range people {
+		ret = append(ret, person.String())
+	}
+	return strings.Join(ret, ", ")
+}
+
+// parsePeople parses a Maintainer field, followed by Uploaders fields,
+// which may each hold several people.
+func parsePeople(maintainer string, uploaders []string) ([]Person, error) {
+	person, err := ParsePerson(maintainer)
+	if err != nil {
+		return nil, err
+	}
+	ret := []Person{*person}
+	for _, uploader := range uploaders {
+		people, err := ParsePeople(uploader)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, people...)
+	}
+	return ret, nil
+}
+
+// }}}
+
+// vim: foldmethod=marker
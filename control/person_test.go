@@ -0,0 +1,97 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control_test
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+
+	"github.com/ebikt/go-debian/control"
+)
+
+/*
+ *
+ */
+
+func TestParsePerson(t *testing.T) {
+	person, err := control.ParsePerson("  Jane Doe <jane@example.com> ")
+	isok(t, err)
+	assert(t, person.Name == "Jane Doe")
+	assert(t, person.Email == "jane@example.com")
+	assert(t, person.String() == "Jane Doe <jane@example.com>")
+
+	person, err = control.ParsePerson(`"Doe, Jane" <jane@example.com>`)
+	isok(t, err)
+	assert(t, person.Name == "Doe, Jane")
+	assert(t, person.String() == `"Doe, Jane" <jane@example.com>`)
+
+	person, err = control.ParsePerson("=?UTF-8?Q?Ren=C3=A9_Doe?= <rene@example.com>")
+	isok(t, err)
+	assert(t, person.Name == "René Doe")
+
+	for _, invalid := range []string{
+		"Jane Doe",
+		"Jane Doe <>",
+		"Jane Doe <jane>",
+		"Jane Doe <jane@>",
+		"Jane Doe <jane doe@example.com>",
+		"Jane <Doe> <jane@example.com>",
+		"=?bogus?Q?Jane?= <jane@example.com>",
+	} {
+		_, err := control.ParsePerson(invalid)
+		notok(t, err)
+	}
+}
+
+func TestParsePeople(t *testing.T) {
+	people, err := control.ParsePeople(`Jane Doe <jane@example.com>, "Roe, Richard" <rr@example.com>,` +
+		"\n <anon@example.com>,")
+	isok(t, err)
+	assert(t, len(people) == 3)
+	assert(t, people[1].Name == "Roe, Richard")
+	assert(t, people[2].Name == "")
+	assert(t, control.FormatPeople(people) ==
+		`Jane Doe <jane@example.com>, "Roe, Richard" <rr@example.com>, <anon@example.com>`)
+
+	_, err = control.ParsePeople("Jane Doe")
+	notok(t, err)
+	_, err = control.ParsePeople(`"Jane Doe <jane@example.com>`)
+	notok(t, err)
+}
+
+func TestChangedByPerson(t *testing.T) {
+	changes, err := control.ParseChanges(bufio.NewReader(strings.NewReader(`Source: hello
+Maintainer: Jane Doe <jane@example.com>
+Changed-By: Richard Roe <rr@example.com>
+`)), "")
+	isok(t, err)
+	person, err := changes.ChangedByPerson()
+	isok(t, err)
+	assert(t, person.Name == "Richard Roe")
+
+	changes.ChangedBy = ""
+	person, err = changes.ChangedByPerson()
+	isok(t, err)
+	assert(t, person.Email == "jane@example.com")
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,63 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control_test
+
+import (
+	"go/build"
+	"strings"
+	"testing"
+)
+
+/*
+ *
+ */
+
+// The parsing-only modules must not touch the filesystem when built with
+// the pureparser tag, so they stay usable from GOOS=js and friends; nor may
+// the modules of this repository they import, such as internal. These are
+// the only modules supporting the tag, see the package documentation.
+func TestPureParserImports(t *testing.T) {
+	ctx := build.Default
+	ctx.BuildTags = append(ctx.BuildTags, "pureparser")
+
+	seen := map[string]bool{}
+	var check func(pkg *build.Package)
+	check = func(pkg *build.Package) {
+		for _, imp := range pkg.Imports {
+			switch {
+			case imp == "os", imp == "os/exec", imp == "path/filepath", imp == "io/ioutil", imp == "syscall":
+				t.Errorf("%s imports %s with the pureparser tag", pkg.Dir, imp)
+			case strings.HasPrefix(imp, "github.com/ebikt/go-debian/") && !seen[imp]:
+				seen[imp] = true
+				dep, err := ctx.Import(imp, pkg.Dir, 0)
+				isok(t, err)
+				check(dep)
+			}
+		}
+	}
+	for _, dir := range []string{"../control", "../dependency", "../version", "../changelog", "../copyright", "../watch"} {
+		pkg, err := ctx.ImportDir(dir, 0)
+		isok(t, err)
+		check(pkg)
+	}
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,115 @@
+//go:build !pureparser
+// +build !pureparser
+
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control // import "github.com/ebikt/go-debian/control"
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/ebikt/go-debian/internal"
+)
+
+// relocate copies or moves the files into the directory `dest`, all or
+// nothing. Moves are renames, undone if one of them fails. Copies, and
+// moves across filesystems, go to temporary files in `dest` first, which
+// are only renamed in place once all of them are there, in order. Callers
+// put the .changes or .dsc last, so it only shows up in `dest` after the
+// files it references.
+func relocate(files []string, dest string, move bool) error {
+	if file, err := os.Stat(dest); err != nil {
+		return err
+	} else if !file.IsDir() {
+		return fmt.Errorf("Attempting to move %s to a non-directory", filepath.Base(files[len(files)-1]))
+	}
+
+	if move {
+		err := renameAll(files, dest)
+		if err == nil || !errors.Is(err, syscall.EXDEV) {
+			return err
+		}
+	}
+
+	temps := []string{}
+	cleanup := func() {
+		for _, temp := range temps {
+			os.Remove(temp)
+		}
+	}
+	for _, file := range files {
+		temp, err := os.CreateTemp(dest, "."+filepath.Base(file)+".")
+		if err != nil {
+			cleanup()
+			return err
+		}
+		temp.Close()
+		temps = append(temps, temp.Name())
+		if err := internal.Copy(file, temp.Name()); err != nil {
+			cleanup()
+			return err
+		}
+		/* CreateTemp makes the file private, keep the mode of the original */
+		info, err := os.Stat(file)
+		if err == nil {
+			err = os.Chmod(temp.Name(), info.Mode().Perm())
+		}
+		if err != nil {
+			cleanup()
+			return err
+		}
+	}
+	for i, file := range files {
+		if err := os.Rename(temps[i], filepath.Join(dest, filepath.Base(file))); err != nil {
+			cleanup()
+			return err
+		}
+	}
+
+	if move {
+		for _, file := range files {
+			if err := os.Remove(file); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// renameAll renames the files into `dest`, renaming them back if one of
+// them can't be.
+func renameAll(files []string, dest string) error {
+	for i, file := range files {
+		if err := os.Rename(file, filepath.Join(dest, filepath.Base(file))); err != nil {
+			for j := i - 1; j >= 0; j-- {
+				os.Rename(filepath.Join(dest, filepath.Base(files[j])), files[j])
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// vim: foldmethod=marker
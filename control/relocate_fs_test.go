@@ -0,0 +1,97 @@
+//go:build !pureparser
+// +build !pureparser
+
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control_test
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ebikt/go-debian/control"
+)
+
+/*
+ *
+ */
+
+// writeUpload writes a .changes listing a couple of files in `dir`.
+func writeUpload(t *testing.T, dir string) string {
+	files := map[string]string{
+		"hello_1.0-1.dsc":       "Source: hello\n",
+		"hello_1.0-1_amd64.deb": "!<arch>\n",
+	}
+	changes := "Format: 1.8\nSource: hello\nArchitecture: source amd64\nVersion: 1.0-1\nChecksums-Sha256:\n"
+	list := "Files:\n"
+	for _, name := range []string{"hello_1.0-1.dsc", "hello_1.0-1_amd64.deb"} {
+		data := []byte(files[name])
+		isok(t, os.WriteFile(filepath.Join(dir, name), data, 0644))
+		changes += fmt.Sprintf(" %x %d %s\n", sha256.Sum256(data), len(data), name)
+		list += fmt.Sprintf(" %x %d devel optional %s\n", md5.Sum(data), len(data), name)
+	}
+	path := filepath.Join(dir, "hello_1.0-1_amd64.changes")
+	isok(t, os.WriteFile(path, []byte(changes+list), 0644))
+	return path
+}
+
+func TestChangesRelocate(t *testing.T) {
+	src, copied, moved := t.TempDir(), t.TempDir(), t.TempDir()
+	changes, err := control.ParseChangesFile(writeUpload(t, src))
+	isok(t, err)
+	isok(t, changes.Validate(""))
+
+	isok(t, changes.Copy(copied))
+	assert(t, changes.Filename == filepath.Join(copied, "hello_1.0-1_amd64.changes"))
+	entries, err := os.ReadDir(copied)
+	isok(t, err)
+	assert(t, len(entries) == 3)
+	isok(t, changes.Validate(""))
+	info, err := os.Stat(changes.Filename)
+	isok(t, err)
+	assert(t, info.Mode().Perm() == 0644)
+
+	isok(t, changes.Move(moved))
+	assert(t, changes.Filename == filepath.Join(moved, "hello_1.0-1_amd64.changes"))
+	entries, err = os.ReadDir(copied)
+	isok(t, err)
+	assert(t, len(entries) == 0)
+	isok(t, changes.Validate(""))
+
+	/* Nothing shows up when a file is missing */
+	isok(t, os.Remove(filepath.Join(moved, "hello_1.0-1_amd64.deb")))
+	notok(t, changes.Copy(copied))
+	notok(t, changes.Move(copied))
+	entries, err = os.ReadDir(copied)
+	isok(t, err)
+	assert(t, len(entries) == 0)
+	_, err = os.Stat(filepath.Join(moved, "hello_1.0-1.dsc"))
+	isok(t, err)
+	assert(t, changes.Filename == filepath.Join(moved, "hello_1.0-1_amd64.changes"))
+
+	notok(t, changes.Copy(changes.Filename))
+}
+
+// vim: foldmethod=marker
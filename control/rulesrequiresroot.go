@@ -0,0 +1,138 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control // import "github.com/ebikt/go-debian/control"
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Rules-Requires-Root {{{
+
+// The special values of the Rules-Requires-Root field.
+const (
+	RulesRequiresRootNo            = "no"
+	RulesRequiresRootBinaryTargets = "binary-targets"
+)
+
+// Implementation-specific keywords, such as "dpkg/target-subcommand".
+var rulesRequiresRootKeyword = regexp.MustCompile(`^[a-z0-9][a-z0-9.+-]*/[a-z0-9][a-z0-9.+/-]*$`)
+
+// RulesRequiresRoot is the Rules-Requires-Root field of a source package,
+// telling whether debian/rules needs (fake)root to build its binary
+// targets. It's either "no", "binary-targets", or a space separated list
+// of keywords naming the cases where root is needed, in which case the
+// build driver has to provide a DEB_GAIN_ROOT_CMD.
+//
+// The zero value is an absent field, which recent dpkg versions (from
+// 1.22.13 on) treat as "no", and older ones as "binary-targets".
+type RulesRequiresRoot struct {
+	Keywords []string
+}
+
+// ParseRulesRequiresRoot parses and validates the value of a
+// Rules-Requires-Root field.
+func ParseRulesRequiresRoot(value string) (RulesRequiresRoot, error) {
+	ret := RulesRequiresRoot{}
+	return ret, ret.UnmarshalControl(value)
+}
+
+func (r *RulesRequiresRoot) UnmarshalControl(data string) error {
+	keywords := strings.Fields(data)
+	if len(keywords) == 0 {
+		return fmt.Errorf("Empty Rules-Requires-Root field")
+	}
+	for _, keyword := range keywords {
+		switch keyword {
+		case RulesRequiresRootNo, RulesRequiresRootBinaryTargets:
+			if len(keywords) != 1 {
+				return fmt.Errorf("Rules-Requires-Root '%s' can't be combined with other keywords", keyword)
+			}
+		default:
+			if !rulesRequiresRootKeyword.MatchString(keyword) {
+				return fmt.Errorf("Invalid Rules-Requires-Root keyword '%s'", keyword)
+			}
+		}
+	}
+	r.Keywords = keywords
+	return nil
+}
+
+func (r RulesRequiresRoot) MarshalControl() (string, error) {
+	return r.String(), nil
+}
+
+func (r RulesRequiresRoot) String() string {
+	return strings.Join(r.Keywords, " ")
+}
+
+// IsSet returns true unless the field is absent.
+func (r RulesRequiresRoot) IsSet() bool {
+	return len(r.Keywords) > 0
+}
+
+// Has returns true if the field lists the given keyword.
+func (r RulesRequiresRoot) Has(keyword string) bool {
+	for _, it := range r.Keywords {
+		if it == keyword {
+			return true
+		}
+	}
+	return false
+}
+
+// NeedsFakeroot returns true if the binary targets of debian/rules have to
+// be run as (fake)root, which is only the case for "binary-targets", or
+// when the field is absent and `legacy` is set to follow dpkg before
+// 1.22.13.
+func (r RulesRequiresRoot) NeedsFakeroot(legacy bool) bool {
+	if !r.IsSet() {
+		return legacy
+	}
+	return r.Has(RulesRequiresRootBinaryTargets)
+}
+
+// NeedsGainRootCommand returns true if debian/rules uses keywords, and so
+// needs the build driver to provide a command to gain root in
+// DEB_GAIN_ROOT_CMD.
+func (r RulesRequiresRoot) NeedsGainRootCommand() bool {
+	return r.IsSet() && !r.Has(RulesRequiresRootNo) && !r.Has(RulesRequiresRootBinaryTargets)
+}
+
+// Environment returns the variables a build driver exports to debian/rules,
+// as "KEY=value": DEB_RULES_REQUIRES_ROOT, and DEB_GAIN_ROOT_CMD (set to
+// `gainRoot`, such as "fakeroot --") when keywords are used.
+func (r RulesRequiresRoot) Environment(gainRoot string) []string {
+	value := r.String()
+	if !r.IsSet() {
+		value = RulesRequiresRootNo
+	}
+	ret := []string{"DEB_RULES_REQUIRES_ROOT=" + value}
+	if r.NeedsGainRootCommand() {
+		ret = append(ret, "DEB_GAIN_ROOT_CMD="+gainRoot)
+	}
+	return ret
+}
+
+// }}}
+
+// vim: foldmethod=marker
@@ -0,0 +1,77 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control_test
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+
+	"github.com/ebikt/go-debian/control"
+)
+
+/*
+ *
+ */
+
+func TestRulesRequiresRoot(t *testing.T) {
+	c, err := control.ParseControl(bufio.NewReader(strings.NewReader(`Source: hello
+Maintainer: Jane Doe <jane@example.com>
+Rules-Requires-Root: dpkg/target-subcommand  debhelper/upstream-make-install
+
+Package: hello
+Architecture: any
+`)), "")
+	isok(t, err)
+	rrr := c.Source.RulesRequiresRoot
+	assert(t, rrr.IsSet())
+	assert(t, len(rrr.Keywords) == 2)
+	assert(t, rrr.Has("dpkg/target-subcommand"))
+	assert(t, !rrr.NeedsFakeroot(true))
+	assert(t, rrr.NeedsGainRootCommand())
+	env := rrr.Environment("fakeroot --")
+	assert(t, len(env) == 2)
+	assert(t, env[0] == "DEB_RULES_REQUIRES_ROOT=dpkg/target-subcommand debhelper/upstream-make-install")
+	assert(t, env[1] == "DEB_GAIN_ROOT_CMD=fakeroot --")
+
+	rrr, err = control.ParseRulesRequiresRoot("binary-targets")
+	isok(t, err)
+	assert(t, rrr.NeedsFakeroot(false))
+	assert(t, !rrr.NeedsGainRootCommand())
+
+	rrr, err = control.ParseRulesRequiresRoot("no")
+	isok(t, err)
+	assert(t, !rrr.NeedsFakeroot(true))
+	assert(t, len(rrr.Environment("fakeroot")) == 1)
+
+	unset := control.RulesRequiresRoot{}
+	assert(t, !unset.IsSet())
+	assert(t, unset.NeedsFakeroot(true))
+	assert(t, !unset.NeedsFakeroot(false))
+	assert(t, unset.Environment("")[0] == "DEB_RULES_REQUIRES_ROOT=no")
+
+	for _, in := range []string{"", "yes", "no binary-targets", "no dpkg/foo", "dpkg", "Dpkg/Foo"} {
+		_, err := control.ParseRulesRequiresRoot(in)
+		notok(t, err)
+	}
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,231 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control // import "github.com/ebikt/go-debian/control"
+
+import (
+	"context"
+	"io"
+	"runtime"
+	"sync"
+)
+
+// Concurrent index scanning {{{
+
+// ScanOptions configure how an index is scanned by the Scan methods of
+// BinaryIndexReader and SourceIndexReader.
+type ScanOptions struct {
+	// How many goroutines decode entries and call the callback; one for
+	// each CPU if zero.
+	Workers int
+
+	// Call the callback with one entry at a time, in the order of the
+	// index. Otherwise, it's called from all the workers at once, in no
+	// particular order, and has to be safe for that.
+	Ordered bool
+}
+
+func (o ScanOptions) workers() int {
+	if o.Workers > 0 {
+		return o.Workers
+	}
+	return runtime.NumCPU()
+}
+
+type scanJob struct {
+	seq       int
+	paragraph *Paragraph
+}
+
+type scanResult struct {
+	seq   int
+	entry interface{}
+	err   error
+}
+
+/* Paragraphs are read off the index on one goroutine, and decoded into
+ * the entries newEntry returns by the workers. The first error, whether
+ * reading, decoding, from the callback or the context being done, stops
+ * the scan. */
+func (r *indexReader) scan(ctx context.Context, opts ScanOptions, newEntry func() interface{}, fn func(interface{}) error) error {
+	workers := opts.workers()
+
+	var once sync.Once
+	var scanErr error
+	done := make(chan struct{})
+	fail := func(err error) {
+		once.Do(func() {
+			scanErr = err
+			close(done)
+		})
+	}
+
+	finished := make(chan struct{})
+	defer close(finished)
+	go func() {
+		select {
+		case <-ctx.Done():
+			fail(ctx.Err())
+		case <-finished:
+		}
+	}()
+
+	/* In order, the entries decoded ahead of the one the callback waits
+	 * for are held back, so only so many are let through at once. */
+	window := make(chan struct{}, 4*workers)
+
+	jobs := make(chan scanJob, workers)
+	read := make(chan struct{})
+	go func() {
+		defer close(read)
+		defer close(jobs)
+		for seq := 0; ; seq++ {
+			paragraph, err := r.paragraphs.Next()
+			if err == io.EOF {
+				return
+			} else if err != nil {
+				fail(err)
+				return
+			}
+			if opts.Ordered {
+				select {
+				case window <- struct{}{}:
+				case <-done:
+					return
+				}
+			}
+			select {
+			case jobs <- scanJob{seq: seq, paragraph: paragraph}:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	results := make(chan scanResult, workers)
+	wg := sync.WaitGroup{}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				entry := newEntry()
+				err := UnpackFromParagraph(*job.paragraph, entry)
+				if opts.Ordered {
+					select {
+					case results <- scanResult{seq: job.seq, entry: entry, err: err}:
+					case <-done:
+						return
+					}
+					continue
+				}
+				if err == nil {
+					err = fn(entry)
+				}
+				if err != nil {
+					fail(err)
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	if opts.Ordered {
+		pending := map[int]scanResult{}
+		next := 0
+		failed := false
+		for result := range results {
+			pending[result.seq] = result
+			for !failed {
+				result, ok := pending[next]
+				if !ok {
+					break
+				}
+				delete(pending, next)
+				next++
+				<-window
+				select {
+				case <-done:
+					/* Cancelled, or failed elsewhere */
+					failed = true
+					continue
+				default:
+				}
+				err := result.err
+				if err == nil {
+					err = fn(result.entry)
+				}
+				if err != nil {
+					fail(err)
+					failed = true
+				}
+			}
+		}
+	} else {
+		for range results {
+		}
+	}
+	/* Don't leave anything reading off the index once we're done */
+	<-read
+
+	select {
+	case <-done:
+		return scanErr
+	default:
+		return nil
+	}
+}
+
+// Scan decodes the remaining entries of the index on several goroutines,
+// and calls fn with each of them, as configured by opts. Paragraphs are
+// read off the index on a goroutine of their own, which leaves the CPU
+// bound decoding of entries, and whatever fn does, to the workers. The
+// first error, whether reading the index, decoding an entry, returned by
+// fn or from the context once it's done, stops the scan and is returned.
+func (r *BinaryIndexReader) Scan(ctx context.Context, opts ScanOptions, fn func(*BinaryIndex) error) error {
+	return r.scan(ctx, opts, func() interface{} {
+		return &BinaryIndex{}
+	}, func(entry interface{}) error {
+		return fn(entry.(*BinaryIndex))
+	})
+}
+
+// Scan decodes the remaining entries of the index on several goroutines,
+// and calls fn with each of them, see BinaryIndexReader.Scan.
+func (r *SourceIndexReader) Scan(ctx context.Context, opts ScanOptions, fn func(*SourceIndex) error) error {
+	return r.scan(ctx, opts, func() interface{} {
+		return &SourceIndex{}
+	}, func(entry interface{}) error {
+		return fn(entry.(*SourceIndex))
+	})
+}
+
+// }}}
+
+// vim: foldmethod=marker
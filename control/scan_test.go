@@ -0,0 +1,149 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ebikt/go-debian/control"
+)
+
+/*
+ *
+ */
+
+func scannedPackages(count int) string {
+	out := strings.Builder{}
+	for i := 0; i < count; i++ {
+		fmt.Fprintf(&out, "Package: pkg%d\nVersion: 1.%d-1\nDepends: libc6 (>= 2.%d)\n\n", i, i, i)
+	}
+	return out.String()
+}
+
+func TestBinaryIndexScanOrdered(t *testing.T) {
+	reader, err := control.NewBinaryIndexReader(strings.NewReader(scannedPackages(500)))
+	isok(t, err)
+
+	seen := []string{}
+	isok(t, reader.Scan(context.Background(), control.ScanOptions{Workers: 4, Ordered: true}, func(entry *control.BinaryIndex) error {
+		seen = append(seen, entry.Package)
+		return nil
+	}))
+	assert(t, len(seen) == 500)
+	for i, name := range seen {
+		assert(t, name == fmt.Sprintf("pkg%d", i))
+	}
+}
+
+func TestBinaryIndexScanUnordered(t *testing.T) {
+	reader, err := control.NewBinaryIndexReader(strings.NewReader(scannedPackages(500)))
+	isok(t, err)
+	reader.SetFields("Package", "Depends")
+
+	lock := sync.Mutex{}
+	seen := map[string]bool{}
+	isok(t, reader.Scan(context.Background(), control.ScanOptions{}, func(entry *control.BinaryIndex) error {
+		lock.Lock()
+		defer lock.Unlock()
+		seen[entry.Package] = entry.Version.Version == "" && len(entry.GetDepends().Relations) == 1
+		return nil
+	}))
+	assert(t, len(seen) == 500)
+	for i := 0; i < 500; i++ {
+		assert(t, seen[fmt.Sprintf("pkg%d", i)])
+	}
+}
+
+func TestSourceIndexScan(t *testing.T) {
+	reader, err := control.NewSourceIndexReader(strings.NewReader(
+		"Package: hello\nVersion: 2.10-3\n\nPackage: bash\nVersion: 5.2-2\n"))
+	isok(t, err)
+
+	seen := []string{}
+	isok(t, reader.Scan(context.Background(), control.ScanOptions{Workers: 2, Ordered: true}, func(entry *control.SourceIndex) error {
+		seen = append(seen, entry.Package+"="+entry.Version.String())
+		return nil
+	}))
+	assert(t, strings.Join(seen, " ") == "hello=2.10-3 bash=5.2-2")
+}
+
+func TestIndexScanErrors(t *testing.T) {
+	stop := errors.New("stop")
+	for _, ordered := range []bool{true, false} {
+		reader, err := control.NewBinaryIndexReader(strings.NewReader(scannedPackages(500)))
+		isok(t, err)
+		lock := sync.Mutex{}
+		calls := 0
+		err = reader.Scan(context.Background(), control.ScanOptions{Workers: 4, Ordered: ordered}, func(entry *control.BinaryIndex) error {
+			lock.Lock()
+			defer lock.Unlock()
+			calls++
+			if entry.Package == "pkg10" {
+				return stop
+			}
+			return nil
+		})
+		assert(t, err == stop)
+		assert(t, calls < 500)
+		if ordered {
+			assert(t, calls == 11)
+		}
+
+		reader, err = control.NewBinaryIndexReader(strings.NewReader(
+			scannedPackages(20) + "Package: broken\nArchitecture: linux--amd64\n\n" + scannedPackages(20)))
+		isok(t, err)
+		calls = 0
+		err = reader.Scan(context.Background(), control.ScanOptions{Workers: 4, Ordered: ordered}, func(entry *control.BinaryIndex) error {
+			lock.Lock()
+			defer lock.Unlock()
+			calls++
+			return nil
+		})
+		notok(t, err)
+		if ordered {
+			assert(t, calls == 20)
+		}
+	}
+}
+
+func TestIndexScanCancel(t *testing.T) {
+	reader, err := control.NewBinaryIndexReader(strings.NewReader(scannedPackages(500)))
+	isok(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	calls := 0
+	err = reader.Scan(ctx, control.ScanOptions{Workers: 4, Ordered: true}, func(entry *control.BinaryIndex) error {
+		calls++
+		if calls == 10 {
+			cancel()
+		}
+		return nil
+	})
+	assert(t, err == context.Canceled)
+	assert(t, calls < 500)
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,340 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control // import "github.com/ebikt/go-debian/control"
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/ebikt/go-debian/version"
+)
+
+// Search index {{{
+
+// Weights of the fields of an entry matching a search term: a term found
+// in the name of the package counts the most, and one only found in its
+// long description the least.
+const (
+	searchWeightName        = 10
+	searchWeightProvides    = 5
+	searchWeightSynopsis    = 3
+	searchWeightTags        = 2
+	searchWeightDescription = 1
+	searchWeightHomepage    = 1
+
+	/* Bonus of a package named as the whole query */
+	searchWeightExactName = 20
+)
+
+// A SearchResult is an entry of a SearchIndex matching a query.
+type SearchResult struct {
+	// Name of the index the entry is from, as given to Refresh.
+	Index string
+	Entry BinaryIndex
+
+	// The higher, the better the entry matches the query.
+	Score int
+}
+
+type searchKey struct {
+	index, name, arch string
+}
+
+// SearchIndex is an in-memory inverted index of the entries of Packages
+// indexes, searched by the words of their name, description, debtags,
+// virtual packages provided and homepage, as `apt search` does, without
+// any database.
+//
+// Each index is refreshed as a whole, such as after downloading a newer
+// version of it; only the entries whose version changed are indexed
+// again. A SearchIndex isn't safe for concurrent use.
+type SearchIndex struct {
+	entries  map[searchKey]BinaryIndex
+	postings map[string]map[searchKey]int
+	indexes  map[string]map[searchKey]bool
+}
+
+// NewSearchIndex creates an empty SearchIndex.
+func NewSearchIndex() *SearchIndex {
+	return &SearchIndex{
+		entries:  map[searchKey]BinaryIndex{},
+		postings: map[string]map[searchKey]int{},
+		indexes:  map[string]map[searchKey]bool{},
+	}
+}
+
+// Len returns the number of entries indexed.
+func (s *SearchIndex) Len() int {
+	return len(s.entries)
+}
+
+// searchWords splits text into lower case words, such as
+// "python3-requests" or "gnu.org".
+func searchWords(text string) []string {
+	ret := []string{}
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune("+-.:", r)
+	})
+	for _, word := range words {
+		if word = strings.Trim(word, "-.:"); word != "" {
+			ret = append(ret, word)
+		}
+	}
+	return ret
+}
+
+// searchParts returns the parts of a compound word, such as "python3" and
+// "requests" for "python3-requests", and nothing for other words.
+func searchParts(word string) []string {
+	parts := strings.FieldsFunc(word, func(r rune) bool { return strings.ContainsRune("+-.:", r) })
+	if len(parts) < 2 {
+		return nil
+	}
+	return parts
+}
+
+// searchTerms returns the terms text is indexed by: its words, and the
+// parts of the compound ones.
+func searchTerms(text string) []string {
+	ret := []string{}
+	for _, word := range searchWords(text) {
+		ret = append(ret, word)
+		ret = append(ret, searchParts(word)...)
+	}
+	return ret
+}
+
+// entryTerms returns the weight of each term of an entry: the sum of the
+// weights of the fields it's found in.
+func entryTerms(entry *BinaryIndex) map[string]int {
+	synopsis, description := entry.Description, ""
+	if i := strings.Index(synopsis, "\n"); i >= 0 {
+		synopsis, description = synopsis[:i], synopsis[i+1:]
+	}
+	provides, dep := []string{}, entry.GetProvides()
+	for _, possi := range dep.GetAllPossibilities() {
+		provides = append(provides, possi.Name)
+	}
+	tags := entry.Paragraph.Get("Tag")
+	if parsed, err := entry.DebTags(); err == nil {
+		tags = ""
+		for _, tag := range parsed {
+			tags += tag.String() + " "
+		}
+	}
+	homepage := entry.Homepage
+	if i := strings.Index(homepage, "://"); i >= 0 {
+		homepage = homepage[i+3:]
+	}
+
+	ret := map[string]int{}
+	for _, field := range []struct {
+		text   string
+		weight int
+	}{
+		{entry.Package, searchWeightName},
+		{strings.Join(provides, " "), searchWeightProvides},
+		{synopsis, searchWeightSynopsis},
+		{tags, searchWeightTags},
+		{description, searchWeightDescription},
+		{homepage, searchWeightHomepage},
+	} {
+		seen := map[string]bool{}
+		for _, term := range searchTerms(field.text) {
+			if !seen[term] {
+				seen[term] = true
+				ret[term] += field.weight
+			}
+		}
+	}
+	return ret
+}
+
+func (s *SearchIndex) add(key searchKey, entry BinaryIndex) {
+	s.entries[key] = entry
+	for term, weight := range entryTerms(&entry) {
+		if s.postings[term] == nil {
+			s.postings[term] = map[searchKey]int{}
+		}
+		s.postings[term][key] = weight
+	}
+}
+
+func (s *SearchIndex) remove(key searchKey) {
+	entry, ok := s.entries[key]
+	if !ok {
+		return
+	}
+	for term := range entryTerms(&entry) {
+		delete(s.postings[term], key)
+		if len(s.postings[term]) == 0 {
+			delete(s.postings, term)
+		}
+	}
+	delete(s.entries, key)
+}
+
+// Refresh sets the entries of the named index, such as
+// "main/binary-amd64/Packages", which replace the ones it had. Entries
+// are told apart by their package name and architecture, the last one
+// winning when an index has several versions of a package. The changes
+// from the entries the index had are returned, sorted by package name and
+// architecture; the entries whose version didn't change aren't indexed
+// again.
+func (s *SearchIndex) Refresh(index string, entries []BinaryIndex) []IndexChange {
+	current := map[searchKey]BinaryIndex{}
+	for _, entry := range entries {
+		current[searchKey{index, entry.Package, entry.Architecture.String()}] = entry
+	}
+	previous := s.indexes[index]
+
+	changes := []IndexChange{}
+	for key := range previous {
+		if _, ok := current[key]; !ok {
+			changes = append(changes, IndexChange{
+				Kind: IndexRemoved, Package: key.name, Architecture: key.arch, Old: s.entries[key].Version,
+			})
+			s.remove(key)
+		}
+	}
+	for key, entry := range current {
+		if !previous[key] {
+			changes = append(changes, IndexChange{
+				Kind: IndexAdded, Package: key.name, Architecture: key.arch, New: entry.Version,
+			})
+			s.add(key, entry)
+			continue
+		}
+		old := s.entries[key].Version
+		cmp := version.Compare(old, entry.Version)
+		if cmp == 0 {
+			continue
+		}
+		kind := IndexUpgraded
+		if cmp > 0 {
+			kind = IndexDowngraded
+		}
+		changes = append(changes, IndexChange{
+			Kind: kind, Package: key.name, Architecture: key.arch, Old: old, New: entry.Version,
+		})
+		s.remove(key)
+		s.add(key, entry)
+	}
+
+	keys := map[searchKey]bool{}
+	for key := range current {
+		keys[key] = true
+	}
+	if len(keys) == 0 {
+		delete(s.indexes, index)
+	} else {
+		s.indexes[index] = keys
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Package != changes[j].Package {
+			return changes[i].Package < changes[j].Package
+		}
+		return changes[i].Architecture < changes[j].Architecture
+	})
+	return changes
+}
+
+// Remove drops the entries of the named index.
+func (s *SearchIndex) Remove(index string) {
+	s.Refresh(index, nil)
+}
+
+// match returns the weight of the entries matching a word of a query:
+// the ones having the word, or else all of its parts.
+func (s *SearchIndex) match(word string) map[searchKey]int {
+	ret := map[searchKey]int{}
+	if parts := searchParts(word); parts != nil {
+		for key, weight := range s.postings[parts[0]] {
+			ret[key] = weight
+		}
+		for _, part := range parts[1:] {
+			for key := range ret {
+				if weight, ok := s.postings[part][key]; ok {
+					ret[key] += weight
+				} else {
+					delete(ret, key)
+				}
+			}
+		}
+	}
+	for key, weight := range s.postings[word] {
+		if weight > ret[key] {
+			ret[key] = weight
+		}
+	}
+	return ret
+}
+
+// Search returns the entries matching every word of the query, best
+// matches first: the score of an entry adds up the weights of the fields
+// each word is found in, its name weighing the most, and a package named
+// as the whole query comes first. Words match whole words of the fields,
+// regardless of case; compound words, such as "gnu.org", also match the
+// entries having all of their parts.
+func (s *SearchIndex) Search(query string) []SearchResult {
+	words := searchWords(query)
+	if len(words) == 0 {
+		return []SearchResult{}
+	}
+	scores := s.match(words[0])
+	for _, word := range words[1:] {
+		matches := s.match(word)
+		for key := range scores {
+			if weight, ok := matches[key]; ok {
+				scores[key] += weight
+			} else {
+				delete(scores, key)
+			}
+		}
+	}
+
+	name := strings.ToLower(strings.TrimSpace(query))
+	ret := []SearchResult{}
+	for key, score := range scores {
+		if key.name == name {
+			score += searchWeightExactName
+		}
+		ret = append(ret, SearchResult{Index: key.index, Entry: s.entries[key], Score: score})
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		a, b := ret[i], ret[j]
+		switch {
+		case a.Score != b.Score:
+			return a.Score > b.Score
+		case a.Entry.Package != b.Entry.Package:
+			return a.Entry.Package < b.Entry.Package
+		case a.Entry.Architecture.String() != b.Entry.Architecture.String():
+			return a.Entry.Architecture.String() < b.Entry.Architecture.String()
+		}
+		return a.Index < b.Index
+	})
+	return ret
+}
+
+// }}}
+
+// vim: foldmethod=marker
@@ -0,0 +1,125 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control_test
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+
+	"github.com/ebikt/go-debian/control"
+)
+
+/*
+ *
+ */
+
+func searchEntries(t *testing.T, data string) []control.BinaryIndex {
+	entries, err := control.ParseBinaryIndex(bufio.NewReader(strings.NewReader(data)))
+	isok(t, err)
+	return entries
+}
+
+func searchNames(results []control.SearchResult) string {
+	names := []string{}
+	for _, result := range results {
+		names = append(names, result.Entry.Package)
+	}
+	return strings.Join(names, " ")
+}
+
+const searchPackages = `Package: hello
+Version: 2.10-3
+Architecture: amd64
+Homepage: https://www.gnu.org/software/hello/
+Tag: interface::commandline, role::program
+Description: example package based on GNU hello
+ The GNU hello program produces a familiar, friendly greeting.
+
+Package: python3-requests
+Version: 2.28.1-1
+Architecture: all
+Description: elegant and simple HTTP library for Python3, built for human beings
+ Requests allow you to send HTTP/1.1 requests.
+
+Package: exim4-daemon-light
+Version: 4.96-15
+Architecture: amd64
+Provides: mail-transport-agent
+Description: lightweight Exim MTA (v4) daemon
+ This package contains the exim4 daemon, to say hello to the world.
+`
+
+func TestSearchIndex(t *testing.T) {
+	index := control.NewSearchIndex()
+	changes := index.Refresh("main", searchEntries(t, searchPackages))
+	assert(t, len(changes) == 3)
+	assert(t, changes[0].String() == "added exim4-daemon-light:amd64 4.96-15")
+	assert(t, index.Len() == 3)
+
+	assert(t, searchNames(index.Search("hello")) == "hello exim4-daemon-light")
+	assert(t, searchNames(index.Search("HTTP library")) == "python3-requests")
+	assert(t, searchNames(index.Search("requests")) == "python3-requests")
+	assert(t, searchNames(index.Search("mail-transport-agent")) == "exim4-daemon-light")
+	assert(t, searchNames(index.Search("role::program")) == "hello")
+	assert(t, searchNames(index.Search("gnu.org")) == "hello")
+	assert(t, searchNames(index.Search("hello http")) == "")
+	assert(t, len(index.Search("  ")) == 0)
+
+	results := index.Search("hello")
+	assert(t, results[0].Index == "main")
+	assert(t, results[0].Score > results[1].Score)
+}
+
+func TestSearchIndexRefresh(t *testing.T) {
+	index := control.NewSearchIndex()
+	index.Refresh("main", searchEntries(t, searchPackages))
+	index.Refresh("contrib", searchEntries(t, `Package: hello-contrib
+Version: 1.0
+Architecture: all
+Description: another hello
+`))
+	assert(t, searchNames(index.Search("hello")) == "hello hello-contrib exim4-daemon-light")
+
+	changes := index.Refresh("main", searchEntries(t, `Package: hello
+Version: 2.12-1
+Architecture: amd64
+Description: example package based on GNU hello, now greeting everyone
+
+Package: python3-requests
+Version: 2.28.1-1
+Architecture: all
+Description: elegant and simple HTTP library for Python3, built for human beings
+`))
+	assert(t, len(changes) == 2)
+	assert(t, changes[0].String() == "removed exim4-daemon-light:amd64 4.96-15")
+	assert(t, changes[1].String() == "upgraded hello:amd64 2.10-3 -> 2.12-1")
+	assert(t, index.Len() == 3)
+	assert(t, searchNames(index.Search("greeting")) == "hello")
+	assert(t, searchNames(index.Search("exim4")) == "")
+	assert(t, searchNames(index.Search("friendly")) == "")
+
+	index.Remove("contrib")
+	assert(t, searchNames(index.Search("hello")) == "hello")
+	assert(t, index.Len() == 2)
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,96 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control // import "github.com/ebikt/go-debian/control"
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/clearsign"
+	"golang.org/x/crypto/openpgp/packet"
+)
+
+// Signing {{{
+
+// signingKey checks that the Entity can sign, which it can't when only its
+// public key is known, or its private key is still encrypted.
+func signingKey(signer *openpgp.Entity) (*packet.PrivateKey, error) {
+	if signer == nil || signer.PrivateKey == nil {
+		return nil, fmt.Errorf("Signing key has no private key")
+	}
+	if signer.PrivateKey.Encrypted {
+		return nil, fmt.Errorf("Signing key is encrypted, decrypt it first")
+	}
+	return signer.PrivateKey, nil
+}
+
+// ClearSign writes the data read from the reader, clearsigned by the
+// signer, the way gpg --clearsign (and thus debsign) does, for .dsc,
+// .changes and InRelease files. If config is nil, the data is hashed with
+// SHA-256.
+func ClearSign(out io.Writer, reader io.Reader, signer *openpgp.Entity, config *packet.Config) error {
+	key, err := signingKey(signer)
+	if err != nil {
+		return err
+	}
+	writer, err := clearsign.Encode(out, key, config)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(writer, reader); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	/* gpg ends the armor with a newline, unlike the clearsign module. */
+	_, err = out.Write([]byte("\n"))
+	return err
+}
+
+// DetachSign writes an ASCII armored detached signature of the data read
+// from the reader, made by the signer, as found in Release.gpg files.
+func DetachSign(out io.Writer, reader io.Reader, signer *openpgp.Entity, config *packet.Config) error {
+	if _, err := signingKey(signer); err != nil {
+		return err
+	}
+	if err := openpgp.ArmoredDetachSign(out, signer, reader, config); err != nil {
+		return err
+	}
+	_, err := out.Write([]byte("\n"))
+	return err
+}
+
+// MarshalSigned is Marshal for clearsigned documents, such as a .dsc or a
+// .changes file ready for upload.
+func MarshalSigned(out io.Writer, data interface{}, signer *openpgp.Entity) error {
+	cleartext := bytes.Buffer{}
+	if err := Marshal(&cleartext, data); err != nil {
+		return err
+	}
+	return ClearSign(out, &cleartext, signer, nil)
+}
+
+// }}}
+
+// vim: foldmethod=marker
@@ -0,0 +1,81 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control_test
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/openpgp"
+
+	"github.com/ebikt/go-debian/control"
+	"github.com/ebikt/go-debian/testutil"
+)
+
+/*
+ *
+ */
+
+func TestSign(t *testing.T) {
+	key, err := testutil.NewKey("Jane Doe", "jane@example.com")
+	isok(t, err)
+
+	dsc, err := control.ParseDsc(bufio.NewReader(strings.NewReader(`Format: 3.0 (quilt)
+Source: hello
+Binary: hello
+Version: 2.10-3
+Files:
+ d41d8cd98f00b204e9800998ecf8427e 0 hello_2.10.orig.tar.gz
+-dash: line
+`)), "hello_2.10-3.dsc")
+	isok(t, err)
+
+	out := bytes.Buffer{}
+	isok(t, control.MarshalSigned(&out, dsc, key))
+	signed := out.String()
+	assert(t, strings.HasPrefix(signed, "-----BEGIN PGP SIGNED MESSAGE-----\nHash: SHA256\n\nFormat: 3.0 (quilt)\n"))
+	assert(t, strings.HasSuffix(signed, "-----END PGP SIGNATURE-----\n"))
+
+	parsed, signer, err := control.ParseSignedDsc(bufio.NewReader(&out), "hello_2.10-3.dsc", testutil.Keyring(key))
+	isok(t, err)
+	assert(t, signer.PrimaryKey.KeyId == key.PrimaryKey.KeyId)
+	assert(t, parsed.Source == "hello" && len(parsed.Files) == 1)
+
+	release := []byte("Origin: Debian\nSuite: stable\n")
+	out.Reset()
+	isok(t, control.DetachSign(&out, bytes.NewReader(release), key, nil))
+	assert(t, strings.HasPrefix(out.String(), "-----BEGIN PGP SIGNATURE-----\n"))
+	assert(t, strings.HasSuffix(out.String(), "-----END PGP SIGNATURE-----\n"))
+	_, err = openpgp.CheckArmoredDetachedSignature(testutil.Keyring(key), bytes.NewReader(release), &out)
+	isok(t, err)
+
+	/* A public key can't sign */
+	armored, err := testutil.ArmoredPublicKey(key)
+	isok(t, err)
+	public, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(armored))
+	isok(t, err)
+	notok(t, control.ClearSign(&out, bytes.NewReader(release), public[0], nil))
+	notok(t, control.DetachSign(&out, bytes.NewReader(release), nil, nil))
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,275 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control // import "github.com/ebikt/go-debian/control"
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ebikt/go-debian/dependency"
+	"github.com/ebikt/go-debian/version"
+)
+
+// Substvars {{{
+
+// Substvars holds substitution variables, as found in debian/substvars
+// files, and expands their ${name} occurrences in control paragraphs the
+// way dpkg-gencontrol does.
+type Substvars struct {
+	// If set, expanding an undefined variable is an error, rather than
+	// only listed by Undefined.
+	Strict bool
+
+	values    map[string]string
+	optional  map[string]bool
+	used      map[string]bool
+	undefined map[string]bool
+}
+
+// maxSubstitutions bounds the expansion of a value, to catch variables
+// referencing themselves, as dpkg does.
+const maxSubstitutions = 50
+
+var (
+	substvarName = regexp.MustCompile(`^[A-Za-z0-9][-:0-9A-Za-z]*$`)
+	substvarRef  = regexp.MustCompile(`\$\{([-:0-9A-Za-z]*)\}`)
+)
+
+// NewSubstvars creates a set of variables holding the ones dpkg always
+// defines: ${Newline}, ${Space} and ${Tab}.
+func NewSubstvars() *Substvars {
+	ret := Substvars{
+		values:    map[string]string{},
+		optional:  map[string]bool{},
+		used:      map[string]bool{},
+		undefined: map[string]bool{},
+	}
+	for name, value := range map[string]string{"Newline": "\n", "Space": " ", "Tab": "\t"} {
+		ret.values[name] = value
+		ret.optional[name] = true
+	}
+	return &ret
+}
+
+// ParseSubstvars reads a debian/substvars file into a new set of
+// variables, see Substvars.Parse.
+func ParseSubstvars(reader io.Reader) (*Substvars, error) {
+	ret := NewSubstvars()
+	return ret, ret.Parse(reader)
+}
+
+// Parse reads the "name=value" lines of a substvars file, adding them to
+// the variables. Variables set with "name?=value" are not reported by
+// Unused. Empty lines and lines starting with "#" are skipped.
+func (s *Substvars) Parse(reader io.Reader) error {
+	scanner := bufio.NewScanner(reader)
+	lineno := 0
+	for scanner.Scan() {
+		lineno++
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.Index(line, "=")
+		if i == -1 {
+			return fmt.Errorf("Bad line %d in substvars file: '%s'", lineno, line)
+		}
+		name, value := line[:i], line[i+1:]
+		optional := strings.HasSuffix(name, "?")
+		name = strings.TrimSuffix(name, "?")
+		if !substvarName.MatchString(name) {
+			return fmt.Errorf("Bad substitution variable name on line %d: '%s'", lineno, name)
+		}
+		s.Set(name, value)
+		s.optional[name] = optional
+	}
+	return scanner.Err()
+}
+
+// Set defines a variable.
+func (s *Substvars) Set(name, value string) {
+	s.values[name] = value
+	delete(s.optional, name)
+}
+
+// Get returns the value of a variable, and whether it's defined.
+func (s *Substvars) Get(name string) (string, bool) {
+	value, ok := s.values[name]
+	return value, ok
+}
+
+// use returns the value of a variable, which counts as used.
+func (s *Substvars) use(name string) (string, bool) {
+	value, ok := s.values[name]
+	if ok {
+		s.used[name] = true
+	}
+	return value, ok
+}
+
+// SetVersions defines the version variables dpkg-gencontrol provides:
+// ${source:Version}, ${source:Upstream-Version} and ${binary:Version}.
+// The binary version is the one of the changelog, which is the source
+// version unless it's a binNMU.
+func (s *Substvars) SetVersions(source, binary version.Version) {
+	upstream := source
+	upstream.Revision = ""
+	s.Set("source:Version", source.String())
+	s.Set("source:Upstream-Version", upstream.String())
+	s.Set("binary:Version", binary.String())
+	for _, name := range []string{"source:Version", "source:Upstream-Version", "binary:Version"} {
+		s.optional[name] = true
+	}
+}
+
+// Expand substitutes the variables in the value, including the ones found
+// in the values substituted. ${} stands for a literal "$". Undefined
+// variables are replaced by nothing, and listed by Undefined, unless the
+// Substvars are Strict. Variables referencing themselves, directly or not,
+// are an error.
+func (s *Substvars) Expand(value string) (string, error) {
+	count := 0
+	return s.expand(value, []string{}, &count)
+}
+
+func (s *Substvars) expand(value string, expanding []string, count *int) (string, error) {
+	ret := ""
+	for {
+		loc := substvarRef.FindStringSubmatchIndex(value)
+		if loc == nil {
+			return ret + value, nil
+		}
+		name := value[loc[2]:loc[3]]
+		ret += value[:loc[0]]
+		value = value[loc[1]:]
+		if name == "" {
+			ret += "$"
+			continue
+		}
+
+		for i, parent := range expanding {
+			if parent == name {
+				cycle := append(append([]string{}, expanding[i:]...), name)
+				return "", fmt.Errorf("Substitution variable ${%s} is recursive: %s",
+					name, strings.Join(cycle, " -> "))
+			}
+		}
+		*count++
+		if *count > maxSubstitutions {
+			return "", fmt.Errorf("Too many substitutions, expanding ${%s}", name)
+		}
+		replacement, ok := s.values[name]
+		if !ok {
+			if s.Strict {
+				return "", fmt.Errorf("Undefined substitution variable ${%s}", name)
+			}
+			s.undefined[name] = true
+			continue
+		}
+		s.used[name] = true
+		/* The replacement is expanded too, for the variables it uses */
+		replacement, err := s.expand(replacement, append(expanding, name), count)
+		if err != nil {
+			return "", err
+		}
+		ret += replacement
+	}
+}
+
+// ExpandDependency substitutes the variables of a relationship field, such
+// as the ${shlibs:Depends} possibilities of a Depends, and parses the
+// result again. Relations left empty by variables expanding to nothing are
+// dropped.
+func (s *Substvars) ExpandDependency(dep dependency.Dependency) (*dependency.Dependency, error) {
+	value, err := s.Expand(dep.String())
+	if err != nil {
+		return nil, err
+	}
+	return dependency.Parse(cleanRelations(value))
+}
+
+// ExpandParagraph returns a copy of the paragraph with the variables
+// expanded in the values of all of its fields, which are dropped if they
+// end up empty, like dpkg-gencontrol does. The empty relations left by
+// variables expanding to nothing are removed from relationship fields.
+func (s *Substvars) ExpandParagraph(para Paragraph) (*Paragraph, error) {
+	ret := NewParagraph()
+	for _, key := range para.Order {
+		value, err := s.Expand(para.Get(key))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", key, err)
+		}
+		if relationFields[strings.ToLower(key)] {
+			value = cleanRelations(value)
+		}
+		if strings.TrimSpace(value) == "" {
+			continue
+		}
+		ret.Set(key, value)
+	}
+	return &ret, nil
+}
+
+func cleanRelations(value string) string {
+	relations := []string{}
+	for _, relation := range strings.Split(value, ",") {
+		if relation = strings.TrimSpace(relation); relation != "" {
+			relations = append(relations, relation)
+		}
+	}
+	return strings.Join(relations, ", ")
+}
+
+func sortedNames(set map[string]bool) []string {
+	ret := []string{}
+	for name := range set {
+		ret = append(ret, name)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// Undefined returns the names of the variables that were expanded without
+// being defined, which dpkg-gencontrol warns about.
+func (s *Substvars) Undefined() []string {
+	return sortedNames(s.undefined)
+}
+
+// Unused returns the names of the variables that were defined but never
+// expanded, except for the optional ones, which dpkg-gencontrol warns
+// about too.
+func (s *Substvars) Unused() []string {
+	unused := map[string]bool{}
+	for name := range s.values {
+		if !s.used[name] && !s.optional[name] {
+			unused[name] = true
+		}
+	}
+	return sortedNames(unused)
+}
+
+// }}}
+
+// vim: foldmethod=marker
@@ -0,0 +1,65 @@
+//go:build !pureparser
+// +build !pureparser
+
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control // import "github.com/ebikt/go-debian/control"
+
+import (
+	"os"
+)
+
+// ParseSubstvarsFile reads a debian/substvars file off the disk.
+func ParseSubstvarsFile(path string) (*Substvars, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseSubstvars(f)
+}
+
+// ParseFile reads a debian/substvars file off the disk, adding its
+// variables to the ones already defined.
+func (s *Substvars) ParseFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return s.Parse(f)
+}
+
+// ParseSubstvarsFiles reads several substvars files, such as
+// debian/substvars and debian/hello.substvars, into a single set of
+// variables, the later files overriding the earlier ones. Files that
+// don't exist are skipped, since debhelper only writes the ones it needs.
+func ParseSubstvarsFiles(paths ...string) (*Substvars, error) {
+	ret := NewSubstvars()
+	for _, path := range paths {
+		if err := ret.ParseFile(path); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	return ret, nil
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,55 @@
+//go:build !pureparser
+// +build !pureparser
+
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ebikt/go-debian/control"
+)
+
+/*
+ *
+ */
+
+func TestParseSubstvarsFiles(t *testing.T) {
+	dir := t.TempDir()
+	isok(t, os.WriteFile(filepath.Join(dir, "substvars"), []byte("misc:Depends=foo\nshared=1\n"), 0644))
+	isok(t, os.WriteFile(filepath.Join(dir, "hello.substvars"), []byte("misc:Depends=bar\n"), 0644))
+
+	vars, err := control.ParseSubstvarsFiles(
+		filepath.Join(dir, "substvars"),
+		filepath.Join(dir, "missing.substvars"),
+		filepath.Join(dir, "hello.substvars"),
+	)
+	isok(t, err)
+	value, _ := vars.Get("misc:Depends")
+	assert(t, value == "bar")
+	value, _ = vars.Get("shared")
+	assert(t, value == "1")
+}
+
+// vim: foldmethod=marker
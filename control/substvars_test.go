@@ -0,0 +1,121 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ebikt/go-debian/control"
+	"github.com/ebikt/go-debian/dependency"
+	"github.com/ebikt/go-debian/version"
+)
+
+/*
+ *
+ */
+
+func TestSubstvars(t *testing.T) {
+	vars, err := control.ParseSubstvars(strings.NewReader(`# generated by dh_shlibdeps
+shlibs:Depends=libc6 (>= 2.34)
+misc:Depends=
+misc:Pre-Depends?=dpkg (>= 1.15.6)
+python3:Depends=${python3:Versions}, python3:any
+python3:Versions=python3 (>= 3.11)
+unused=foo
+`))
+	isok(t, err)
+
+	ver, err := version.Parse("1:2.10-3")
+	isok(t, err)
+	vars.SetVersions(ver, ver)
+
+	para := control.NewParagraph()
+	para.Set("Package", "hello")
+	para.Set("Depends", "${shlibs:Depends}, ${misc:Depends}, ${python3:Depends}")
+	para.Set("Pre-Depends", "${misc:Pre-Depends}")
+	para.Set("Recommends", "${misc:Recommends}")
+	para.Set("Breaks", "hello-doc (<< ${source:Upstream-Version}), libhello (<< ${binary:Version})")
+	para.Set("Description", "cost${Space}${}5${Newline} greeting")
+
+	expanded, err := vars.ExpandParagraph(para)
+	isok(t, err)
+	assert(t, expanded.Get("Depends") == "libc6 (>= 2.34), python3 (>= 3.11), python3:any")
+	assert(t, expanded.Get("Pre-Depends") == "dpkg (>= 1.15.6)")
+	assert(t, !expanded.Has("Recommends"))
+	assert(t, expanded.Get("Breaks") == "hello-doc (<< 1:2.10), libhello (<< 1:2.10-3)")
+	assert(t, expanded.Get("Description") == "cost $5\n greeting")
+
+	assert(t, strings.Join(vars.Undefined(), " ") == "misc:Recommends")
+	assert(t, strings.Join(vars.Unused(), " ") == "unused")
+
+	vars.Set("loop", "a ${loop}")
+	_, err = vars.Expand("${loop}")
+	notok(t, err)
+
+	_, err = control.ParseSubstvars(strings.NewReader("no equal sign\n"))
+	notok(t, err)
+	_, err = control.ParseSubstvars(strings.NewReader("bad name=1\n"))
+	notok(t, err)
+}
+
+func TestSubstvarsCycles(t *testing.T) {
+	vars := control.NewSubstvars()
+	vars.Set("a", "${b}")
+	vars.Set("b", "x ${c}")
+	vars.Set("c", "${a}")
+	_, err := vars.Expand("${a}")
+	notok(t, err)
+	assert(t, strings.Contains(err.Error(), "a -> b -> c -> a"))
+
+	/* The same variable twice is not a cycle */
+	vars.Set("c", "${d}, ${d}")
+	vars.Set("d", "y")
+	value, err := vars.Expand("${a}")
+	isok(t, err)
+	assert(t, value == "x y, y")
+
+	vars.Strict = true
+	_, err = vars.Expand("${a} ${undefined}")
+	notok(t, err)
+	assert(t, len(vars.Undefined()) == 0)
+}
+
+func TestSubstvarsExpandDependency(t *testing.T) {
+	vars := control.NewSubstvars()
+	vars.Set("shlibs:Depends", "libc6 (>= 2.34), libhello1")
+	vars.Set("misc:Depends", "")
+	vars.Set("alt:Depends", "hello-alt")
+
+	dep, err := dependency.Parse("${shlibs:Depends}, ${misc:Depends}, hello-doc | ${alt:Depends}")
+	isok(t, err)
+	expanded, err := vars.ExpandDependency(*dep)
+	isok(t, err)
+	assert(t, len(expanded.Relations) == 3)
+	assert(t, len(expanded.GetSubstvars()) == 0)
+	assert(t, expanded.String() == "libc6 (>= 2.34), libhello1, hello-doc | hello-alt")
+
+	vars.Set("misc:Depends", "broken (")
+	_, err = vars.ExpandDependency(*dep)
+	notok(t, err)
+}
+
+// vim: foldmethod=marker
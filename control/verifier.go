@@ -0,0 +1,131 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control // import "github.com/ebikt/go-debian/control"
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/openpgp"
+)
+
+// Verifiers {{{
+
+// A Verifier checks OpenPGP signatures against the keys it trusts. The
+// KeyringVerifier does so in pure Go, while the GpgvVerifier hands the
+// signatures over to gpgv, trusting what the system trusts; code taking a
+// Verifier works with either.
+type Verifier interface {
+	// VerifyClearsigned checks a clearsigned message, returning its
+	// cleartext.
+	VerifyClearsigned(data []byte) ([]byte, error)
+
+	// VerifyDetached checks a detached signature, ASCII armored or not,
+	// of the signed data.
+	VerifyDetached(signed, signature []byte) error
+}
+
+// KeyringVerifier checks signatures against the keys of a keyring, in pure
+// Go.
+type KeyringVerifier struct {
+	Keyring openpgp.EntityList
+}
+
+// VerifyClearsigned implements Verifier.
+func (v KeyringVerifier) VerifyClearsigned(data []byte) ([]byte, error) {
+	cleartext, _, err := v.CheckClearsigned(data)
+	return cleartext, err
+}
+
+// VerifyDetached implements Verifier.
+func (v KeyringVerifier) VerifyDetached(signed, signature []byte) error {
+	_, err := v.CheckDetached(signed, signature)
+	return err
+}
+
+// CheckClearsigned is VerifyClearsigned, also returning the key which made
+// the signature.
+func (v KeyringVerifier) CheckClearsigned(data []byte) ([]byte, *openpgp.Entity, error) {
+	return verifyClearsigned(data, &v.Keyring)
+}
+
+// CheckDetached is VerifyDetached, also returning the key which made the
+// signature.
+func (v KeyringVerifier) CheckDetached(signed, signature []byte) (*openpgp.Entity, error) {
+	if bytes.HasPrefix(bytes.TrimLeft(signature, " \t\r\n"), []byte("-----BEGIN PGP ")) {
+		return openpgp.CheckArmoredDetachedSignature(v.Keyring, bytes.NewReader(signed), bytes.NewReader(signature))
+	}
+	return openpgp.CheckDetachedSignature(v.Keyring, bytes.NewReader(signed), bytes.NewReader(signature))
+}
+
+// A ContextVerifier is a Verifier whose checks can be cut short, such as
+// the GpgvVerifier, which kills gpgv once the context is done.
+type ContextVerifier interface {
+	Verifier
+
+	VerifyClearsignedContext(ctx context.Context, data []byte) ([]byte, error)
+	VerifyDetachedContext(ctx context.Context, signed, signature []byte) error
+}
+
+// DecodeVerified is DecodeClearsigned with the signature checked by the
+// Verifier. Documents that are not signed are rejected.
+func DecodeVerified(reader io.Reader, verifier Verifier) ([]byte, error) {
+	return DecodeVerifiedContext(context.Background(), reader, verifier)
+}
+
+// DecodeVerifiedContext is DecodeVerified, giving up once the context is
+// done: at any time with a ContextVerifier, otherwise before the signature
+// is checked.
+func DecodeVerifiedContext(ctx context.Context, reader io.Reader, verifier Verifier) ([]byte, error) {
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	if !IsClearsigned(data) {
+		return nil, fmt.Errorf("Document is not clearsigned")
+	}
+	data = bytes.TrimLeft(data, " \t\r\n")
+	if cv, ok := verifier.(ContextVerifier); ok {
+		return cv.VerifyClearsignedContext(ctx, data)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return verifier.VerifyClearsigned(data)
+}
+
+// VerifyDetachedContext checks a detached signature with the Verifier,
+// giving up once the context is done, see DecodeVerifiedContext.
+func VerifyDetachedContext(ctx context.Context, verifier Verifier, signed, signature []byte) error {
+	if cv, ok := verifier.(ContextVerifier); ok {
+		return cv.VerifyDetachedContext(ctx, signed, signature)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return verifier.VerifyDetached(signed, signature)
+}
+
+// }}}
+
+// vim: foldmethod=marker
@@ -0,0 +1,104 @@
+//go:build !pureparser
+// +build !pureparser
+
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control // import "github.com/ebikt/go-debian/control"
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// GpgvVerifier checks signatures by running gpgv, the way apt does, so
+// that they are checked by the same code and against the same keys as the
+// rest of the system.
+type GpgvVerifier struct {
+	// Command to run; "gpgv" if empty.
+	Command string
+
+	// Keyrings holding the trusted keys, such as
+	// /usr/share/keyrings/debian-archive-keyring.gpg. gpgv uses its
+	// default one, ~/.gnupg/trustedkeys.kbx, if empty.
+	Keyrings []string
+}
+
+func (v GpgvVerifier) run(ctx context.Context, stdin []byte, args ...string) ([]byte, error) {
+	command := v.Command
+	if command == "" {
+		command = "gpgv"
+	}
+	options := []string{}
+	for _, keyring := range v.Keyrings {
+		options = append(options, "--keyring", keyring)
+	}
+	cmd := exec.CommandContext(ctx, command, append(options, args...)...)
+	cmd.Stdin = bytes.NewReader(stdin)
+	stdout, stderr := bytes.Buffer{}, bytes.Buffer{}
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("%s: %s: %s", command, err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
+
+// VerifyClearsigned implements Verifier.
+func (v GpgvVerifier) VerifyClearsigned(data []byte) ([]byte, error) {
+	return v.VerifyClearsignedContext(context.Background(), data)
+}
+
+// VerifyClearsignedContext implements ContextVerifier.
+func (v GpgvVerifier) VerifyClearsignedContext(ctx context.Context, data []byte) ([]byte, error) {
+	return v.run(ctx, data, "--output", "-", "-")
+}
+
+// VerifyDetached implements Verifier. The signature goes through a
+// temporary file, gpgv reading the signed data from its standard input.
+func (v GpgvVerifier) VerifyDetached(signed, signature []byte) error {
+	return v.VerifyDetachedContext(context.Background(), signed, signature)
+}
+
+// VerifyDetachedContext implements ContextVerifier.
+func (v GpgvVerifier) VerifyDetachedContext(ctx context.Context, signed, signature []byte) error {
+	f, err := os.CreateTemp("", "go-debian-signature")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.Write(signature)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	_, err = v.run(ctx, signed, f.Name(), "-")
+	return err
+}
+
+// vim: foldmethod=marker
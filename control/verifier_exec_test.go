@@ -0,0 +1,66 @@
+//go:build !pureparser
+// +build !pureparser
+
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/crypto/openpgp"
+
+	"github.com/ebikt/go-debian/control"
+	"github.com/ebikt/go-debian/testutil"
+)
+
+/*
+ *
+ */
+
+func TestGpgvVerifier(t *testing.T) {
+	if _, err := exec.LookPath("gpgv"); err != nil {
+		t.Skip("gpgv is not installed")
+	}
+	key, err := testutil.NewKey("Jane Doe", "jane@example.com")
+	isok(t, err)
+	other, err := testutil.NewKey("John Doe", "john@example.com")
+	isok(t, err)
+
+	dir := t.TempDir()
+	keyring := func(name string, entity *openpgp.Entity) string {
+		path := filepath.Join(dir, name)
+		f, err := os.Create(path)
+		isok(t, err)
+		isok(t, entity.Serialize(f))
+		isok(t, f.Close())
+		return path
+	}
+	testVerifier(t, key,
+		control.GpgvVerifier{Keyrings: []string{keyring("trusted.gpg", key)}},
+		control.GpgvVerifier{Keyrings: []string{keyring("untrusted.gpg", other)}},
+	)
+}
+
+// vim: foldmethod=marker
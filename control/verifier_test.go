@@ -0,0 +1,96 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"golang.org/x/crypto/openpgp"
+
+	"github.com/ebikt/go-debian/control"
+	"github.com/ebikt/go-debian/testutil"
+)
+
+/*
+ *
+ */
+
+const verifiedRelease = `Origin: Debian
+Suite: stable
+Components: main
+`
+
+func testVerifier(t *testing.T, key *openpgp.Entity, trusted, untrusted control.Verifier) {
+	signed, err := testutil.ClearSign(key, []byte(verifiedRelease))
+	isok(t, err)
+	detached, err := testutil.DetachSign(key, []byte(verifiedRelease))
+	isok(t, err)
+
+	cleartext, err := control.DecodeVerified(bytes.NewReader(signed), trusted)
+	isok(t, err)
+	assert(t, string(cleartext) == verifiedRelease)
+	isok(t, trusted.VerifyDetached([]byte(verifiedRelease), detached))
+
+	notok(t, trusted.VerifyDetached([]byte(verifiedRelease+"Label: Debian\n"), detached))
+	_, err = control.DecodeVerified(bytes.NewReader([]byte(verifiedRelease)), trusted)
+	notok(t, err)
+	_, err = control.DecodeVerified(bytes.NewReader(signed), untrusted)
+	notok(t, err)
+	notok(t, untrusted.VerifyDetached([]byte(verifiedRelease), detached))
+
+	cleartext, err = control.DecodeVerifiedContext(context.Background(), bytes.NewReader(signed), trusted)
+	isok(t, err)
+	assert(t, string(cleartext) == verifiedRelease)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = control.DecodeVerifiedContext(ctx, bytes.NewReader(signed), trusted)
+	assert(t, err == context.Canceled)
+	err = control.VerifyDetachedContext(ctx, trusted, []byte(verifiedRelease), detached)
+	assert(t, err == context.Canceled)
+}
+
+func TestKeyringVerifier(t *testing.T) {
+	key, err := testutil.NewKey("Jane Doe", "jane@example.com")
+	isok(t, err)
+	other, err := testutil.NewKey("John Doe", "john@example.com")
+	isok(t, err)
+	testVerifier(t, key,
+		control.KeyringVerifier{Keyring: *testutil.Keyring(key)},
+		control.KeyringVerifier{Keyring: *testutil.Keyring(other)},
+	)
+
+	/* The Check variants tell who signed */
+	verifier := control.KeyringVerifier{Keyring: *testutil.Keyring(other, key)}
+	signed, err := testutil.ClearSign(key, []byte(verifiedRelease))
+	isok(t, err)
+	cleartext, signer, err := verifier.CheckClearsigned(signed)
+	isok(t, err)
+	assert(t, string(cleartext) == verifiedRelease && signer == key)
+	detached, err := testutil.DetachSign(key, []byte(verifiedRelease))
+	isok(t, err)
+	signer, err = verifier.CheckDetached([]byte(verifiedRelease), detached)
+	isok(t, err)
+	assert(t, signer == key)
+}
+
+// vim: foldmethod=marker
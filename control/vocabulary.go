@@ -0,0 +1,379 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control // import "github.com/ebikt/go-debian/control"
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Priority {{{
+
+// Priority is the Priority field of a package, telling how much a system
+// needs it, as defined in Debian Policy, section 2.5.
+type Priority string
+
+// The priorities of Debian Policy, from the most needed to the least.
+// PriorityExtra is deprecated in favor of PriorityOptional since Debian
+// Policy 4.0.1, but still parsed.
+const (
+	PriorityRequired  Priority = "required"
+	PriorityImportant Priority = "important"
+	PriorityStandard  Priority = "standard"
+	PriorityOptional  Priority = "optional"
+	PriorityExtra     Priority = "extra"
+)
+
+var priorities = []Priority{
+	PriorityRequired, PriorityImportant, PriorityStandard, PriorityOptional, PriorityExtra,
+}
+
+// ParsePriority parses and validates the value of a Priority field.
+func ParsePriority(value string) (Priority, error) {
+	var ret Priority
+	return ret, ret.UnmarshalText([]byte(value))
+}
+
+// Valid tells whether the Priority is one of Debian Policy.
+func (p Priority) Valid() bool {
+	return p.rank() >= 0
+}
+
+// Deprecated tells whether the Priority shouldn't be used anymore.
+func (p Priority) Deprecated() bool {
+	return p == PriorityExtra
+}
+
+func (p Priority) rank() int {
+	for i, priority := range priorities {
+		if priority == p {
+			return i
+		}
+	}
+	return -1
+}
+
+// Compare returns -1, 0 or 1 as the Priority is more needed than, as needed
+// as, or less needed than the other one. Invalid priorities are the least
+// needed.
+func (p Priority) Compare(other Priority) int {
+	rank := func(p Priority) int {
+		if p.Valid() {
+			return p.rank()
+		}
+		return len(priorities)
+	}
+	switch a, b := rank(p), rank(other); {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, for Priority fields
+// to be checked as they are decoded.
+func (p *Priority) UnmarshalText(text []byte) error {
+	priority := Priority(strings.TrimSpace(string(text)))
+	if !priority.Valid() {
+		return fmt.Errorf("Unknown Priority '%s'", text)
+	}
+	*p = priority
+	return nil
+}
+
+func (p Priority) MarshalText() ([]byte, error) {
+	return []byte(p), nil
+}
+
+// }}}
+
+// Sections {{{
+
+var sectionRegistry = struct {
+	sync.RWMutex
+	components map[string]bool
+	sections   map[string]bool
+}{
+	components: map[string]bool{},
+	sections:   map[string]bool{},
+}
+
+func init() {
+	RegisterComponent("main", "contrib", "non-free", "non-free-firmware")
+	RegisterSection(
+		"admin", "cli-mono", "comm", "database", "debian-installer",
+		"debug", "devel", "doc", "editors", "education", "electronics",
+		"embedded", "fonts", "games", "gnome", "gnu-r", "gnustep",
+		"golang", "graphics", "hamradio", "haskell", "httpd", "interpreters",
+		"introspection", "java", "javascript", "kde", "kernel", "libdevel",
+		"libs", "lisp", "localization", "mail", "math", "metapackages",
+		"misc", "net", "news", "ocaml", "oldlibs", "otherosfs", "perl",
+		"php", "python", "ruby", "rust", "science", "shells", "sound",
+		"tasks", "tex", "text", "utils", "vcs", "video", "web", "x11",
+		"xfce", "zope",
+	)
+}
+
+// RegisterComponent adds archive components to the known ones, which are
+// the ones of the Debian archive to start with, for archives with other
+// components, such as "restricted" and "universe".
+func RegisterComponent(names ...string) {
+	sectionRegistry.Lock()
+	defer sectionRegistry.Unlock()
+	for _, name := range names {
+		sectionRegistry.components[name] = true
+	}
+}
+
+// RegisterSection adds sections to the known ones, which are the ones of
+// the Debian archive to start with.
+func RegisterSection(names ...string) {
+	sectionRegistry.Lock()
+	defer sectionRegistry.Unlock()
+	for _, name := range names {
+		sectionRegistry.sections[name] = true
+	}
+}
+
+func sortedRegistry(set map[string]bool) []string {
+	sectionRegistry.RLock()
+	defer sectionRegistry.RUnlock()
+	ret := []string{}
+	for name := range set {
+		ret = append(ret, name)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// KnownComponents returns the registered archive components, sorted.
+func KnownComponents() []string {
+	return sortedRegistry(sectionRegistry.components)
+}
+
+// KnownSections returns the registered sections, sorted.
+func KnownSections() []string {
+	return sortedRegistry(sectionRegistry.sections)
+}
+
+// Section is the Section field of a package, such as "contrib/games": a
+// section of the archive, prefixed with the component it's in unless
+// that's main.
+type Section struct {
+	Component string
+	Name      string
+}
+
+// ParseSection parses the value of a Section field, and checks that its
+// component and section are known ones.
+func ParseSection(value string) (Section, error) {
+	ret := Section{}
+	return ret, ret.UnmarshalControl(value)
+}
+
+// Valid tells whether the component, if any, and the section are known.
+func (s Section) Valid() bool {
+	sectionRegistry.RLock()
+	defer sectionRegistry.RUnlock()
+	if s.Component != "" && !sectionRegistry.components[s.Component] {
+		return false
+	}
+	return sectionRegistry.sections[s.Name]
+}
+
+// ComponentName returns the component of the section, main if there's
+// none.
+func (s Section) ComponentName() string {
+	if s.Component == "" {
+		return "main"
+	}
+	return s.Component
+}
+
+func (s Section) String() string {
+	if s.Component == "" {
+		return s.Name
+	}
+	return s.Component + "/" + s.Name
+}
+
+func (s *Section) UnmarshalControl(data string) error {
+	value := strings.TrimSpace(data)
+	section := Section{Name: value}
+	if i := strings.LastIndex(value, "/"); i >= 0 {
+		section = Section{Component: value[:i], Name: value[i+1:]}
+	}
+	if !section.Valid() {
+		return fmt.Errorf("Unknown Section '%s'", data)
+	}
+	*s = section
+	return nil
+}
+
+func (s Section) MarshalControl() (string, error) {
+	return s.String(), nil
+}
+
+// }}}
+
+// Tags {{{
+
+var (
+	tagFacet = regexp.MustCompile(`^[a-z0-9][a-z0-9+.-]*$`)
+	tagValue = regexp.MustCompile(`^[a-z0-9][a-z0-9+.:-]*$`)
+)
+
+// Tag is a debtags tag of a package, a value of a facet, such as
+// "role::program" or "implemented-in::c++".
+type Tag struct {
+	Facet string
+	Value string
+}
+
+func (t Tag) String() string {
+	return t.Facet + "::" + t.Value
+}
+
+// ParseTag parses and validates a tag.
+func ParseTag(value string) (Tag, error) {
+	i := strings.Index(value, "::")
+	if i < 0 {
+		return Tag{}, fmt.Errorf("Tag '%s' has no facet", value)
+	}
+	tag := Tag{Facet: value[:i], Value: value[i+2:]}
+	if !tagFacet.MatchString(tag.Facet) {
+		return Tag{}, fmt.Errorf("Invalid facet in tag '%s'", value)
+	}
+	if !tagValue.MatchString(tag.Value) {
+		return Tag{}, fmt.Errorf("Invalid value in tag '%s'", value)
+	}
+	return tag, nil
+}
+
+// Tags are the debtags of a package, as listed by the Tag field of the
+// Packages indexes, where values of the same facet may be grouped:
+//
+//   Tag: implemented-in::c, role::program, use::{editing,viewing}
+type Tags []Tag
+
+// ParseTags parses and validates the value of a Tag field, expanding the
+// grouped values.
+func ParseTags(value string) (Tags, error) {
+	ret := Tags{}
+	return ret, ret.UnmarshalText([]byte(value))
+}
+
+// Has tells whether the tag is one of the Tags.
+func (t Tags) Has(tag Tag) bool {
+	for _, candidate := range t {
+		if candidate == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// Facet returns the values of the Tags of the facet.
+func (t Tags) Facet(facet string) []string {
+	ret := []string{}
+	for _, tag := range t {
+		if tag.Facet == facet {
+			ret = append(ret, tag.Value)
+		}
+	}
+	return ret
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, for Tag fields to be
+// parsed as they are decoded.
+func (t *Tags) UnmarshalText(text []byte) error {
+	ret := Tags{}
+	for _, group := range splitTagGroups(string(text)) {
+		tags := []string{group}
+		if open := strings.Index(group, "{"); open >= 0 {
+			if !strings.HasSuffix(group, "}") {
+				return fmt.Errorf("Unterminated tag group '%s'", group)
+			}
+			tags = []string{}
+			for _, value := range strings.Split(group[open+1:len(group)-1], ",") {
+				tags = append(tags, group[:open]+strings.TrimSpace(value))
+			}
+		}
+		for _, value := range tags {
+			tag, err := ParseTag(value)
+			if err != nil {
+				return err
+			}
+			ret = append(ret, tag)
+		}
+	}
+	*t = ret
+	return nil
+}
+
+// splitTagGroups splits a Tag field on the commas outside of braces.
+func splitTagGroups(data string) []string {
+	ret := []string{}
+	depth, start := 0, 0
+	add := func(group string) {
+		if group = strings.TrimSpace(group); group != "" {
+			ret = append(ret, group)
+		}
+	}
+	for i, c := range data {
+		switch c {
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case ',':
+			if depth == 0 {
+				add(data[start:i])
+				start = i + 1
+			}
+		}
+	}
+	add(data[start:])
+	return ret
+}
+
+// MarshalText writes the tags one by one, without grouping them.
+func (t Tags) MarshalText() ([]byte, error) {
+	values := []string{}
+	for _, tag := range t {
+		values = append(values, tag.String())
+	}
+	return []byte(strings.Join(values, ", ")), nil
+}
+
+// DebTags parses the Tag field of the entry.
+func (index *BinaryIndex) DebTags() (Tags, error) {
+	return ParseTags(index.Paragraph.Get("Tag"))
+}
+
+// }}}
+
+// vim: foldmethod=marker
@@ -0,0 +1,128 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/ebikt/go-debian/control"
+)
+
+/*
+ *
+ */
+
+func TestPriority(t *testing.T) {
+	priority, err := control.ParsePriority("optional")
+	isok(t, err)
+	assert(t, priority == control.PriorityOptional)
+	assert(t, !priority.Deprecated())
+	assert(t, control.PriorityExtra.Deprecated())
+	assert(t, control.PriorityRequired.Compare(control.PriorityStandard) < 0)
+	assert(t, control.PriorityOptional.Compare(control.PriorityOptional) == 0)
+	assert(t, control.Priority("low").Compare(control.PriorityExtra) > 0)
+	_, err = control.ParsePriority("low")
+	notok(t, err)
+
+	para := struct {
+		control.Paragraph
+		Package  string
+		Priority control.Priority
+	}{}
+	isok(t, control.Unmarshal(&para, strings.NewReader("Package: hello\nPriority: important\n")))
+	assert(t, para.Priority == control.PriorityImportant)
+	notok(t, control.Unmarshal(&para, strings.NewReader("Package: hello\nPriority: urgent\n")))
+
+	tagged := struct {
+		control.Paragraph
+		Package string
+		Section control.Section
+		Tag     control.Tags
+	}{}
+	isok(t, control.Unmarshal(&tagged, strings.NewReader("Package: hello\nSection: non-free/doc\nTag: role::{program,documentation}\n")))
+	assert(t, tagged.Section.Component == "non-free")
+	assert(t, len(tagged.Tag) == 2)
+	out := bytes.Buffer{}
+	isok(t, control.Marshal(&out, tagged))
+	assert(t, out.String() == "Package: hello\nSection: non-free/doc\nTag: role::program, role::documentation\n")
+}
+
+func TestSection(t *testing.T) {
+	section, err := control.ParseSection("contrib/games")
+	isok(t, err)
+	assert(t, section.Component == "contrib")
+	assert(t, section.Name == "games")
+	assert(t, section.String() == "contrib/games")
+
+	section, err = control.ParseSection("utils")
+	isok(t, err)
+	assert(t, section.ComponentName() == "main")
+	assert(t, section.String() == "utils")
+
+	_, err = control.ParseSection("utilities")
+	notok(t, err)
+	_, err = control.ParseSection("universe/utils")
+	notok(t, err)
+	_, err = control.ParseSection("contrib/widgets")
+	notok(t, err)
+
+	control.RegisterComponent("universe")
+	control.RegisterSection("widgets")
+	_, err = control.ParseSection("universe/widgets")
+	isok(t, err)
+	assert(t, len(control.KnownComponents()) == 5)
+	sections := control.KnownSections()
+	assert(t, sections[0] == "admin")
+}
+
+func TestTags(t *testing.T) {
+	tags, err := control.ParseTags("implemented-in::c++, role::program,\n use::{editing, viewing}, works-with::text")
+	isok(t, err)
+	assert(t, len(tags) == 5)
+	assert(t, tags[0] == control.Tag{Facet: "implemented-in", Value: "c++"})
+	assert(t, tags.Has(control.Tag{Facet: "use", Value: "viewing"}))
+	assert(t, !tags.Has(control.Tag{Facet: "use", Value: "playing"}))
+	uses := tags.Facet("use")
+	assert(t, len(uses) == 2 && uses[0] == "editing" && uses[1] == "viewing")
+	value, err := tags.MarshalText()
+	isok(t, err)
+	assert(t, string(value) == "implemented-in::c++, role::program, use::editing, use::viewing, works-with::text")
+
+	for _, invalid := range []string{"role", "Role::program", "role::", "use::{editing", "::program"} {
+		_, err := control.ParseTags(invalid)
+		notok(t, err)
+	}
+
+	tags, err = control.ParseTags("")
+	isok(t, err)
+	assert(t, len(tags) == 0)
+
+	index := control.BinaryIndex{Paragraph: control.NewParagraph()}
+	index.Paragraph.Set("Tag", "role::program, suite::gnu")
+	tags, err = index.DebTags()
+	isok(t, err)
+	assert(t, len(tags) == 2)
+	assert(t, tags[1].String() == "suite::gnu")
+}
+
+// vim: foldmethod=marker
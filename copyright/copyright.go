@@ -0,0 +1,341 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package copyright // import "github.com/ebikt/go-debian/copyright"
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/ebikt/go-debian/control"
+)
+
+// Format {{{
+
+// FormatURI is the URI the Format field of the Header has to point to.
+const FormatURI = "https://www.debian.org/doc/packaging-manuals/copyright-format/1.0/"
+
+// ValidFormat tells whether the Format field is the DEP-5 version 1.0 URI,
+// over http or https, with or without its trailing slash.
+func ValidFormat(format string) bool {
+	format = strings.TrimSuffix(strings.TrimSpace(format), "/")
+	format = strings.TrimPrefix(format, "https://")
+	format = strings.TrimPrefix(format, "http://")
+	return format == "www.debian.org/doc/packaging-manuals/copyright-format/1.0"
+}
+
+// }}}
+
+// Paragraphs {{{
+
+// Header is the first paragraph of the file, about the whole source
+// package.
+type Header struct {
+	control.Paragraph
+
+	Format          string
+	UpstreamName    string `control:"Upstream-Name"`
+	UpstreamContact string `control:"Upstream-Contact"`
+	Source          string
+	Disclaimer      string
+	Comment         string
+	License         string
+	Copyright       string
+	FilesExcluded   string `control:"Files-Excluded"`
+}
+
+// Contacts returns the Upstream-Contact entries, one per line.
+func (h Header) Contacts() []string {
+	return lines(h.UpstreamContact)
+}
+
+// ExcludedPatterns returns the patterns of the Files-Excluded field, the
+// files removed from the upstream tarball when repacking it.
+func (h Header) ExcludedPatterns() []string {
+	return strings.Fields(h.FilesExcluded)
+}
+
+// Files is a paragraph giving the copyright and license of the files
+// matching its patterns.
+type Files struct {
+	control.Paragraph
+
+	Files     string
+	Copyright string
+	License   string
+	Comment   string
+}
+
+// Patterns returns the whitespace separated patterns of the Files field.
+func (f Files) Patterns() []string {
+	return strings.Fields(f.Files)
+}
+
+// Holders returns the Copyright entries, one per line.
+func (f Files) Holders() []string {
+	return lines(f.Copyright)
+}
+
+// Match tells whether any of the patterns of the paragraph matches the
+// path, relative to the root of the source package.
+func (f Files) Match(path string) bool {
+	path = cleanPath(path)
+	for _, pattern := range f.Patterns() {
+		if matchPattern(pattern, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// License is a stand-alone License paragraph, giving the text of a
+// license referenced by name elsewhere.
+type License struct {
+	control.Paragraph
+
+	License string
+	Comment string
+}
+
+// Name returns the short name of the license, from the first line of the
+// License field.
+func (l License) Name() string {
+	name, _ := SplitLicense(l.License)
+	return name
+}
+
+// Text returns the full text of the license, from the continuation lines
+// of the License field.
+func (l License) Text() string {
+	_, text := SplitLicense(l.License)
+	return text
+}
+
+// SplitLicense splits the value of a License field into the short name of
+// the license, on its first line, and the license text that may follow.
+func SplitLicense(value string) (string, string) {
+	parts := strings.SplitN(value, "\n", 2)
+	if len(parts) == 1 {
+		return strings.TrimSpace(parts[0]), ""
+	}
+	return strings.TrimSpace(parts[0]), strings.TrimRight(parts[1], "\n")
+}
+
+// LicenseNames returns the names of the licenses combined in the short
+// name of a License field, such as "GPL-2+ or Artistic-1.0".
+func LicenseNames(name string) []string {
+	ret := []string{}
+	for _, or := range licenseOr.Split(name, -1) {
+		for _, and := range licenseAnd.Split(or, -1) {
+			and = strings.TrimSuffix(strings.TrimSpace(and), ",")
+			if and != "" {
+				ret = append(ret, and)
+			}
+		}
+	}
+	return ret
+}
+
+var (
+	licenseOr  = regexp.MustCompile(`\s+or\s+`)
+	licenseAnd = regexp.MustCompile(`,?\s+and\s+`)
+)
+
+func lines(value string) []string {
+	ret := []string{}
+	for _, line := range strings.Split(value, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			ret = append(ret, line)
+		}
+	}
+	return ret
+}
+
+// }}}
+
+// Copyright {{{
+
+// Copyright is a machine-readable debian/copyright file.
+type Copyright struct {
+	Header   Header    `json:"header"`
+	Files    []Files   `json:"files"`
+	Licenses []License `json:"licenses"`
+}
+
+// Parse reads a machine-readable debian/copyright file. Only the format
+// of the paragraphs is checked here, see Validate for the rest.
+func Parse(reader io.Reader) (*Copyright, error) {
+	paragraphs, err := control.NewParagraphReader(reader, nil)
+	if err != nil {
+		return nil, err
+	}
+	all, err := paragraphs.All()
+	if err != nil {
+		return nil, err
+	}
+	if len(all) == 0 {
+		return nil, fmt.Errorf("No header paragraph in copyright file")
+	}
+
+	ret := Copyright{Files: []Files{}, Licenses: []License{}}
+	if !all[0].Has("Format") {
+		return nil, fmt.Errorf("Header paragraph has no Format field")
+	}
+	if err := control.UnpackFromParagraph(all[0], &ret.Header); err != nil {
+		return nil, err
+	}
+	for i, para := range all[1:] {
+		switch {
+		case para.Has("Files"):
+			files := Files{}
+			if err := control.UnpackFromParagraph(para, &files); err != nil {
+				return nil, err
+			}
+			ret.Files = append(ret.Files, files)
+		case para.Has("License"):
+			license := License{}
+			if err := control.UnpackFromParagraph(para, &license); err != nil {
+				return nil, err
+			}
+			ret.Licenses = append(ret.Licenses, license)
+		default:
+			return nil, fmt.Errorf("Paragraph %d is neither a Files nor a License paragraph", i+2)
+		}
+	}
+	return &ret, nil
+}
+
+// LicenseForPath returns the Files paragraph applying to the path, relative
+// to the root of the source package: the last one with a matching pattern,
+// as later paragraphs override earlier ones. nil is returned when no
+// paragraph matches.
+func (c Copyright) LicenseForPath(path string) *Files {
+	for i := len(c.Files) - 1; i >= 0; i-- {
+		if c.Files[i].Match(path) {
+			return &c.Files[i]
+		}
+	}
+	return nil
+}
+
+// LicenseText returns the text of the license with the given short name,
+// from a stand-alone License paragraph, or else from a Files paragraph, or
+// the Header, carrying the text along with the name.
+func (c Copyright) LicenseText(name string) (string, bool) {
+	for _, license := range c.Licenses {
+		if license.Name() == name {
+			return license.Text(), true
+		}
+	}
+	for _, value := range c.licenseFields() {
+		if short, text := SplitLicense(value); short == name && text != "" {
+			return text, true
+		}
+	}
+	return "", false
+}
+
+func (c Copyright) licenseFields() []string {
+	ret := []string{c.Header.License}
+	for _, files := range c.Files {
+		ret = append(ret, files.License)
+	}
+	return ret
+}
+
+// Validate checks the file against the DEP-5 specification: the Format
+// field must be the one of version 1.0, the Files paragraphs must have
+// Copyright and License fields, the first one being about all files, and
+// each license referenced by name must have its text somewhere.
+func (c Copyright) Validate() error {
+	if !ValidFormat(c.Header.Format) {
+		return fmt.Errorf("Unknown copyright Format: '%s'", c.Header.Format)
+	}
+	for i, files := range c.Files {
+		if len(files.Patterns()) == 0 {
+			return fmt.Errorf("Files paragraph %d has no pattern", i+1)
+		}
+		if strings.TrimSpace(files.Copyright) == "" {
+			return fmt.Errorf("Files paragraph '%s' has no Copyright field", files.Files)
+		}
+		if strings.TrimSpace(files.License) == "" {
+			return fmt.Errorf("Files paragraph '%s' has no License field", files.Files)
+		}
+	}
+	if len(c.Files) > 0 && strings.Join(c.Files[0].Patterns(), " ") != "*" {
+		return fmt.Errorf("First Files paragraph is '%s', and not '*'", c.Files[0].Files)
+	}
+	for _, value := range c.licenseFields() {
+		short, _ := SplitLicense(value)
+		for _, name := range LicenseNames(short) {
+			if _, ok := c.LicenseText(name); !ok {
+				return fmt.Errorf("License '%s' has no text", name)
+			}
+		}
+	}
+	return nil
+}
+
+// }}}
+
+// Patterns {{{
+
+func cleanPath(path string) string {
+	for strings.HasPrefix(path, "./") {
+		path = path[2:]
+	}
+	return strings.TrimPrefix(path, "/")
+}
+
+// matchPattern matches a path against a Files pattern, where "*" matches
+// any string, including "/", "?" matches a single character, and "\"
+// escapes "*", "?" and "\".
+func matchPattern(pattern, path string) bool {
+	expr := "^"
+	pattern = cleanPath(pattern)
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '*':
+			expr += ".*"
+		case '?':
+			expr += "."
+		case '\\':
+			if i+1 < len(pattern) {
+				i++
+				expr += regexp.QuoteMeta(pattern[i : i+1])
+			} else {
+				expr += `\\`
+			}
+		default:
+			expr += regexp.QuoteMeta(pattern[i : i+1])
+		}
+	}
+	re, err := regexp.Compile(expr + "$")
+	if err != nil {
+		return false
+	}
+	return re.MatchString(path)
+}
+
+// }}}
+
+// vim: foldmethod=marker
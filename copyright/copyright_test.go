@@ -0,0 +1,180 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package copyright_test
+
+import (
+	"encoding/json"
+	"log"
+	"runtime/debug"
+	"strings"
+	"testing"
+
+	"github.com/ebikt/go-debian/copyright"
+)
+
+/*
+ *
+ */
+
+func isok(t *testing.T, err error) {
+	if err != nil {
+		log.Printf("Error! Error is not nil! %s\n", err)
+		debug.PrintStack()
+		t.FailNow()
+	}
+}
+
+func notok(t *testing.T, err error) {
+	if err == nil {
+		log.Printf("Error! Error is nil!\n")
+		debug.PrintStack()
+		t.FailNow()
+	}
+}
+
+func assert(t *testing.T, expr bool) {
+	if !expr {
+		log.Printf("Assertion failed!")
+		debug.PrintStack()
+		t.FailNow()
+	}
+}
+
+/*
+ *
+ */
+
+var copyrightFile = `Format: https://www.debian.org/doc/packaging-manuals/copyright-format/1.0/
+Upstream-Name: hello
+Upstream-Contact: Jane Doe <jane@example.com>
+ bug-hello@gnu.org
+Source: https://ftp.gnu.org/gnu/hello/
+Files-Excluded: doc/*.pdf
+ m4/libtool.m4
+
+Files: *
+Copyright: 1992-2022 Free Software Foundation, Inc.
+License: GPL-3+
+
+Files: lib/*
+ src/\*weird?.c
+Copyright: 2001 Jane Doe
+ 2003 John Doe
+License: LGPL-2.1+ or BSD-3-clause
+Comment: Gnulib
+
+Files: debian/*
+Copyright: 2015 Debian Developer <dd@debian.org>
+License: GPL-3+
+
+License: GPL-3+
+ This program is free software: you can redistribute it and/or modify
+ it under the terms of the GNU General Public License.
+ .
+ On Debian systems, see /usr/share/common-licenses/GPL-3.
+
+License: LGPL-2.1+
+ See /usr/share/common-licenses/LGPL-2.1.
+
+License: BSD-3-clause
+ Redistribution and use in source and binary forms are permitted.
+`
+
+func TestParse(t *testing.T) {
+	c, err := copyright.Parse(strings.NewReader(copyrightFile))
+	isok(t, err)
+	isok(t, c.Validate())
+
+	assert(t, c.Header.UpstreamName == "hello")
+	assert(t, len(c.Header.Contacts()) == 2)
+	assert(t, strings.Join(c.Header.ExcludedPatterns(), " ") == "doc/*.pdf m4/libtool.m4")
+	assert(t, len(c.Files) == 3)
+	assert(t, len(c.Licenses) == 3)
+	assert(t, c.Files[1].Holders()[1] == "2003 John Doe")
+	assert(t, c.Licenses[0].Name() == "GPL-3+")
+	assert(t, strings.HasSuffix(c.Licenses[0].Text(), "License.\n\nOn Debian systems, see /usr/share/common-licenses/GPL-3."))
+
+	text, ok := c.LicenseText("BSD-3-clause")
+	assert(t, ok)
+	assert(t, strings.HasPrefix(text, "Redistribution"))
+	assert(t, strings.Join(copyright.LicenseNames("GPL-2+ or Artistic, and MIT"), "|") == "GPL-2+|Artistic|MIT")
+}
+
+func TestJSON(t *testing.T) {
+	c, err := copyright.Parse(strings.NewReader(copyrightFile))
+	isok(t, err)
+	data, err := json.Marshal(c)
+	isok(t, err)
+	assert(t, strings.HasPrefix(string(data), `{"header":{"Format":`))
+
+	decoded := copyright.Copyright{}
+	isok(t, json.Unmarshal(data, &decoded))
+	assert(t, decoded.Header.UpstreamName == "hello")
+	assert(t, len(decoded.Files) == 3)
+	assert(t, decoded.Files[1].Holders()[1] == "2003 John Doe")
+	assert(t, decoded.Licenses[0].Text() == c.Licenses[0].Text())
+	isok(t, decoded.Validate())
+}
+
+func TestLicenseForPath(t *testing.T) {
+	c, err := copyright.Parse(strings.NewReader(copyrightFile))
+	isok(t, err)
+
+	for path, license := range map[string]string{
+		"src/foo.c":         "GPL-3+",
+		"./lib/sub/dir.c":   "LGPL-2.1+ or BSD-3-clause",
+		"src/*weird1.c":     "LGPL-2.1+ or BSD-3-clause",
+		"src/xweird1.c":     "GPL-3+",
+		"debian/rules":      "GPL-3+",
+		"liberty/strings.c": "GPL-3+",
+	} {
+		files := c.LicenseForPath(path)
+		assert(t, files != nil)
+		assert(t, files.License == license)
+	}
+	assert(t, c.LicenseForPath("debian/control").Copyright == "2015 Debian Developer <dd@debian.org>")
+
+	c.Files = c.Files[1:]
+	assert(t, c.LicenseForPath("src/foo.c") == nil)
+	notok(t, c.Validate())
+}
+
+func TestValidate(t *testing.T) {
+	assert(t, copyright.ValidFormat("http://www.debian.org/doc/packaging-manuals/copyright-format/1.0"))
+	assert(t, !copyright.ValidFormat("http://dep.debian.net/deps/dep5"))
+
+	for _, broken := range []string{
+		strings.Replace(copyrightFile, "copyright-format/1.0/", "copyright-format/2.0/", 1),
+		strings.Replace(copyrightFile, "License: BSD-3-clause\n Redistribution", "License: BSD-2-clause\n Redistribution", 1),
+		strings.Replace(copyrightFile, "Copyright: 2015 Debian Developer <dd@debian.org>\n", "", 1),
+	} {
+		c, err := copyright.Parse(strings.NewReader(broken))
+		isok(t, err)
+		notok(t, c.Validate())
+	}
+
+	_, err := copyright.Parse(strings.NewReader("Upstream-Name: hello\n"))
+	notok(t, err)
+	_, err = copyright.Parse(strings.NewReader(copyrightFile + "\nComment: stray\n"))
+	notok(t, err)
+}
+
+// vim: foldmethod=marker
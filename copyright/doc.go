@@ -0,0 +1,15 @@
+/*
+
+This module parses machine-readable debian/copyright files, as specified by
+DEP-5 (https://www.debian.org/doc/packaging-manuals/copyright-format/1.0/),
+and finds out which license applies to a given file of the source package.
+
+	c, err := copyright.ParseFile("debian/copyright")
+	if err != nil {
+		return err
+	}
+	files := c.LicenseForPath("src/foo.c")
+	fmt.Println(files.License)
+
+*/
+package copyright // import "github.com/ebikt/go-debian/copyright"
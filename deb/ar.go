@@ -21,13 +21,31 @@
 package deb // import "github.com/ebikt/go-debian/deb"
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"strconv"
 	"strings"
+
+	"github.com/ebikt/go-debian/hashio"
 )
 
+// Errors {{{
+
+var (
+	// ErrTruncated is the error of an `ar(1)` archive ending before the
+	// end of a header or member, such as a partial download.
+	ErrTruncated = errors.New("Truncated ar archive")
+
+	// ErrBadMagic is the error of an `ar(1)` archive whose global header,
+	// or the header of a member, doesn't end with the expected magic.
+	ErrBadMagic = errors.New("Bad ar magic")
+)
+
+// }}}
+
 // ArEntry {{{
 
 // Container type to access the different parts of a Debian `ar(1)` Archive.
@@ -52,9 +70,17 @@ type ArEntry struct {
 
 // This struct encapsulates a Debian .deb flavored `ar(1)` archive.
 type Ar struct {
-	in         io.Reader
-	lastReader *io.Reader
-	offset     bool
+	// If set, told about each member returned by Next: started when
+	// returned, advanced as its Data is read, and done by the next call.
+	Progress hashio.Progress
+
+	in     io.Reader
+	last   *arMemberReader
+	offset bool
+	item   string
+
+	// The GNU extended name table, the "//" member.
+	names []byte
 }
 
 // LoadAr {{{
@@ -73,74 +99,264 @@ func LoadAr(in io.Reader) (*Ar, error) {
 // Next {{{
 
 // Function to jump to the next file in the Debian `ar(1)` archive, and
-// return the next member.
+// return the next member. The long names of archives created by GNU and
+// BSD `ar(1)` are resolved, and their symbol tables skipped.
+//
+// An archive cut short makes Next, or reading the Data of the member, fail
+// with an error wrapping ErrTruncated; a corrupted header makes it fail
+// with an error wrapping ErrBadMagic, or a syntax error.
 func (d *Ar) Next() (*ArEntry, error) {
-	if d.lastReader != nil {
-		/* Before we do much more, let's empty out the reader, since we
-		 * can't be sure of our position in the reader until the LimitReader
-		 * is empty */
-		if _, err := io.Copy(ioutil.Discard, *d.lastReader); err != nil {
+	if d.item != "" {
+		err := d.skip()
+		if err == io.EOF {
+			err = nil
+		}
+		d.Progress.Done(d.item, err)
+		d.item = ""
+	}
+	for {
+		entry, err := d.next()
+		if err != nil {
 			return nil, err
 		}
-		if d.offset {
-			/* .ar archives align on 2 byte boundaries, so if we're odd, go
-			 * ahead and read another byte. If we get an io.EOF, it's fine
-			 * to return it. */
-			_, err := d.in.Read(make([]byte, 1))
-			if err != nil {
+		switch entry.Name {
+		case "/", "/SYM64/", "__.SYMDEF", "__.SYMDEF SORTED":
+			continue
+		case "//":
+			if d.names, err = ioutil.ReadAll(entry.Data); err != nil {
 				return nil, err
 			}
+			continue
 		}
+		if err := d.resolveName(entry); err != nil {
+			return nil, err
+		}
+		if d.Progress != nil {
+			d.item = entry.Name
+			d.Progress.Start(entry.Name, entry.Size)
+			entry.Data = hashio.NewProgressReader(entry.Data, d.Progress, entry.Name)
+		}
+		return entry, nil
 	}
+}
 
-	line := make([]byte, 60)
-	pos := 0
-
-	var err error
-	for pos < 60 {
-		var count int
-		count, err = d.in.Read(line[pos:])
-		if (err == nil) || (err == io.EOF){
-			pos = pos + count
+// resolveName sets the Name of the entry from its raw ar name: the System
+// V trailing slash is dropped, a GNU "/offset" is looked up in the
+// extended name table, and a BSD "#1/length" name is read from the start
+// of the member data.
+func (d *Ar) resolveName(entry *ArEntry) error {
+	switch {
+	case strings.HasPrefix(entry.Name, "#1/"):
+		length, err := toDecimal([]byte(entry.Name[3:]))
+		if err != nil || length < 0 || length > entry.Size {
+			return fmt.Errorf("Malformed BSD long name '%s'", entry.Name)
+		}
+		name := make([]byte, length)
+		if _, err := io.ReadFull(entry.Data, name); err != nil {
+			return err
+		}
+		entry.Name = strings.TrimRight(string(name), "\x00")
+		entry.Size -= length
+	case len(entry.Name) > 1 && entry.Name[0] == '/':
+		offset, err := toDecimal([]byte(entry.Name[1:]))
+		if err != nil || offset < 0 || offset >= int64(len(d.names)) {
+			return fmt.Errorf("Malformed GNU long name '%s'", entry.Name)
+		}
+		name := d.names[offset:]
+		if end := bytes.IndexByte(name, '\n'); end >= 0 {
+			name = name[:end]
 		}
-		if (err == io.EOF) { break }
-		if (err != nil) { return nil, err }
+		entry.Name = strings.TrimSuffix(string(name), "/")
+	default:
+		// Found a valid deb packages with trailing slash in ar names.
+		// According to wikipedia, that is System V extension. -- Ebik.
+		entry.Name = strings.TrimSuffix(entry.Name, "/")
 	}
-	if err == io.EOF {
-		if pos == 0 || (pos == 1 && line[0] == '\n') {
-			return nil, err
+	return nil
+}
+
+// skip reads what is left of the last member, and its padding.
+func (d *Ar) skip() error {
+	if d.last == nil {
+		return nil
+	}
+	/* Before we do much more, let's empty out the reader, since we
+	 * can't be sure of our position in the reader until the member
+	 * is read */
+	var rest io.Reader = d.last
+	if d.item != "" {
+		rest = hashio.NewProgressReader(rest, d.Progress, d.item)
+	}
+	if _, err := io.Copy(ioutil.Discard, rest); err != nil {
+		return err
+	}
+	d.last = nil
+	if d.offset {
+		/* .ar archives align on 2 byte boundaries, so if we're odd, go
+		 * ahead and read another byte. If we get an io.EOF, it's fine
+		 * to return it: some writers leave out the padding of the
+		 * last member. */
+		if _, err := io.ReadFull(d.in, make([]byte, 1)); err != nil {
+			return err
 		}
 	}
-	if pos < 60 {
-		return nil, fmt.Errorf("Caught a short read at the end")
+	return nil
+}
+
+// next reads the header of the next member, its name left as found.
+func (d *Ar) next() (*ArEntry, error) {
+	if err := d.skip(); err != nil {
+		return nil, err
+	}
+
+	line := make([]byte, 60)
+	pos, err := io.ReadFull(d.in, line)
+	switch err {
+	case nil:
+	case io.EOF:
+		return nil, err
+	case io.ErrUnexpectedEOF:
+		if pos == 1 && line[0] == '\n' {
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("Caught a short read of %d header bytes: %w", pos, ErrTruncated)
+	default:
+		return nil, err
 	}
 	entry, err := parseArEntry(line)
 	if err != nil {
 		return nil, err
 	}
 
-	entry.Data = io.LimitReader(d.in, entry.Size)
-	d.lastReader = &entry.Data
+	d.last = &arMemberReader{in: d.in, name: entry.Name, left: entry.Size}
 	d.offset = (entry.Size % 2) == 1
+	entry.Data = d.last
 
 	return entry, nil
 }
 
 // }}}
 
+// arMemberReader {{{
+
+// arMemberReader reads the data of a member, up to its size, failing
+// with ErrTruncated if the archive ends before.
+type arMemberReader struct {
+	in   io.Reader
+	name string
+	left int64
+}
+
+func (r *arMemberReader) Read(p []byte) (int, error) {
+	if r.left <= 0 {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > r.left {
+		p = p[:r.left]
+	}
+	n, err := r.in.Read(p)
+	r.left -= int64(n)
+	if err == io.EOF && r.left > 0 {
+		return n, fmt.Errorf("Member %s lacks %d bytes: %w", r.name, r.left, ErrTruncated)
+	}
+	return n, err
+}
+
+// }}}
+
 // toDecimal {{{
 
-// Take a byte array, and return an int64
+// Take a byte array, and return an int64. Members as large as the header
+// allows, up to 10 decimal digits, fit even on 32 bit platforms.
 func toDecimal(input []byte) (int64, error) {
 	stream := strings.TrimSpace(string(input))
-	out, err := strconv.Atoi(stream)
-	return int64(out), err
+	return strconv.ParseInt(stream, 10, 64)
 }
 
 // }}}
 
 // }}}
 
+// ArWriter {{{
+
+// ArWriter writes a Debian .deb flavored `ar(1)` archive, as read by Ar.
+//
+// The members of a .deb have to be written in order, such as:
+//
+//   w := deb.NewArWriter(out)
+//   w.WriteEntry("debian-binary", 4, strings.NewReader("2.0\n"))
+//   w.WriteEntry("control.tar.xz", controlSize, control)
+//   w.WriteEntry("data.tar.xz", dataSize, data)
+//   w.Close()
+type ArWriter struct {
+	// Modification timestamp of the members written by WriteEntry.
+	Timestamp int64
+
+	out     io.Writer
+	started bool
+}
+
+// Create an ArWriter writing the archive to the given io.Writer.
+func NewArWriter(out io.Writer) *ArWriter {
+	return &ArWriter{out: out}
+}
+
+func (w *ArWriter) start() error {
+	if w.started {
+		return nil
+	}
+	w.started = true
+	_, err := io.WriteString(w.out, "!<arch>\n")
+	return err
+}
+
+// WriteEntry writes a member of the archive, owned by root and with mode
+// 0644, reading exactly `size` bytes of its content from `r`.
+func (w *ArWriter) WriteEntry(name string, size int64, r io.Reader) error {
+	return w.WriteArEntry(&ArEntry{
+		Name:      name,
+		Timestamp: w.Timestamp,
+		FileMode:  "100644",
+		Size:      size,
+		Data:      r,
+	})
+}
+
+// WriteArEntry writes a member of the archive with the metadata of the
+// given ArEntry, reading exactly `entry.Size` bytes of its content from
+// `entry.Data`.
+func (w *ArWriter) WriteArEntry(entry *ArEntry) error {
+	header, err := formatArEntry(entry)
+	if err != nil {
+		return err
+	}
+	if err := w.start(); err != nil {
+		return err
+	}
+	if _, err := w.out.Write(header); err != nil {
+		return err
+	}
+	if n, err := io.CopyN(w.out, entry.Data, entry.Size); err == io.EOF {
+		return fmt.Errorf("Member %s is %d bytes long, not %d", entry.Name, n, entry.Size)
+	} else if err != nil {
+		return err
+	}
+	if entry.Size%2 == 1 {
+		if _, err := io.WriteString(w.out, "\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close finishes the archive, writing its header if no member was written.
+// The underlying io.Writer is not closed.
+func (w *ArWriter) Close() error {
+	return w.start()
+}
+
+// }}}
+
 // AR Format Hackery {{{
 
 // parseArEntry {{{
@@ -164,17 +380,13 @@ func parseArEntry(line []byte) (*ArEntry, error) {
 		return nil, fmt.Errorf("Malformed file entry line length")
 	}
 
-	if line[58] != 0x60 && line[59] != 0x0A {
-		return nil, fmt.Errorf("Malformed file entry line endings")
+	if line[58] != 0x60 || line[59] != 0x0A {
+		return nil, fmt.Errorf("Malformed file entry line endings: %w", ErrBadMagic)
 	}
 
 	entry := ArEntry{
-		// Found a valid deb packages with trailing slash in ar names.
-		// According to wikipedia, that is System V extension. -- Ebik.
-		Name:     strings.TrimSuffix(
-				strings.TrimSpace(string(line[0:16])), "/",
-			  ),
-		FileMode: strings.TrimSpace(string(line[48:58])),
+		Name:     strings.TrimSpace(string(line[0:16])),
+		FileMode: strings.TrimSpace(string(line[40:48])),
 	}
 
 	for target, value := range map[*int64][]byte{
@@ -183,41 +395,64 @@ func parseArEntry(line []byte) (*ArEntry, error) {
 		&entry.GroupID:   line[34:40],
 		&entry.Size:      line[48:58],
 	} {
+		/* GNU ar leaves all but the size of its "//" member blank */
+		if target != &entry.Size && len(bytes.TrimSpace(value)) == 0 {
+			continue
+		}
 		intValue, err := toDecimal(value)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("Malformed file entry field '%s'", strings.TrimSpace(string(value)))
 		}
 		*target = intValue
 	}
 
+	if entry.Size < 0 {
+		return nil, fmt.Errorf("Malformed file entry size")
+	}
+
 	return &entry, nil
 }
 
 // }}}
 
+// formatArEntry {{{
+
+// Create the AR format line of an ArEntry, as read by parseArEntry.
+func formatArEntry(entry *ArEntry) ([]byte, error) {
+	if entry.Name == "" || len(entry.Name) > 16 || strings.ContainsAny(entry.Name, " /") {
+		return nil, fmt.Errorf("Invalid ar member name '%s'", entry.Name)
+	}
+	mode := entry.FileMode
+	if mode == "" {
+		mode = "100644"
+	}
+	line := fmt.Sprintf("%-16s%-12d%-6d%-6d%-8s%-10d`\n",
+		entry.Name, entry.Timestamp, entry.OwnerID, entry.GroupID, mode, entry.Size)
+	if len(line) != 60 || entry.Size < 0 {
+		return nil, fmt.Errorf("Can't fit the metadata of %s in an ar header", entry.Name)
+	}
+	return []byte(line), nil
+}
+
+// }}}
+
 // checkAr {{{
 
 // Given a brand spank'n new os.File entry, go ahead and make sure it looks
 // like an `ar(1)` archive, and not some random file.
 func checkAr(reader io.Reader) error {
 	header := make([]byte, 8)
-	pos := 0
-	for pos < 8 {
-		count, err := reader.Read(header[pos:])
-		if  err == io.EOF {
-			if count+pos == 0  {
-				return fmt.Errorf("File is empty.")
-			} else {
-				return fmt.Errorf("Header too short for 'ar' file.")
-			}
-		}
-		if  err != nil {
-			return err
-		}
-		pos = pos + count
+	count, err := io.ReadFull(reader, header)
+	switch {
+	case err == io.EOF && count == 0:
+		return fmt.Errorf("File is empty.")
+	case err == io.ErrUnexpectedEOF:
+		return fmt.Errorf("Header too short for 'ar' file: %w", ErrTruncated)
+	case err != nil:
+		return err
 	}
 	if string(header) != "!<arch>\n" {
-		return fmt.Errorf("Header doesn't look as 'ar' file.")
+		return fmt.Errorf("Header doesn't look as 'ar' file: %w", ErrBadMagic)
 	}
 	return nil
 }
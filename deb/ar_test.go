@@ -0,0 +1,285 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package deb_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/ebikt/go-debian/deb"
+)
+
+/*
+ *
+ */
+
+func arMember(name string, data string) string {
+	member := fmt.Sprintf("%-16s%-12d%-6d%-6d%-8s%-10d`\n%s",
+		name, 1690000000, 0, 0, "100644", len(data), data)
+	if len(data)%2 == 1 {
+		member += "\n"
+	}
+	return member
+}
+
+func FuzzArReader(f *testing.F) {
+	/* The layout of a real .deb, as produced by dpkg-deb */
+	f.Add([]byte("!<arch>\n" +
+		arMember("debian-binary", "2.0\n") +
+		arMember("control.tar.xz", "\xfd7zXZ\x00") +
+		arMember("data.tar.xz", "\xfd7zXZ\x00\x00")))
+	/* System V style names, as found in some .debs */
+	f.Add([]byte("!<arch>\n" + arMember("debian-binary/", "2.0\n") + arMember("_gpgorigin/", "x")))
+	f.Add([]byte("!<arch>\n"))
+	/* GNU and BSD long names */
+	f.Add([]byte("!<arch>\n" + arMember("//", "long-member-name.txt/\n") + arMember("/0", "x")))
+	f.Add([]byte("!<arch>\n" + arMember("#1/20", "long-member-name.txtx")))
+
+	f.Fuzz(func(t *testing.T, in []byte) {
+		ar, err := deb.LoadAr(bytes.NewReader(in))
+		if err != nil {
+			return
+		}
+		for i := 0; ; i++ {
+			if i > len(in)/60 {
+				t.Fatalf("Read more members than could fit in %d bytes", len(in))
+			}
+			entry, err := ar.Next()
+			if err != nil {
+				return
+			}
+			n, err := io.Copy(ioutil.Discard, entry.Data)
+			if err != nil {
+				return
+			}
+			if n > entry.Size {
+				t.Fatalf("Member %q is larger than its declared size", entry.Name)
+			}
+		}
+	})
+}
+
+func readAr(t *testing.T, archive string) map[string]string {
+	ar, err := deb.LoadAr(strings.NewReader(archive))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ret := map[string]string{}
+	for {
+		entry, err := ar.Next()
+		if err == io.EOF {
+			return ret
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		data, err := ioutil.ReadAll(entry.Data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if int64(len(data)) != entry.Size {
+			t.Fatalf("Member %s is %d bytes long, not %d", entry.Name, len(data), entry.Size)
+		}
+		ret[entry.Name] = string(data)
+	}
+}
+
+func TestArLongNames(t *testing.T) {
+	/* As written by GNU ar */
+	names := "a-very-long-member-name.txt/\nanother-long-member-name/\n"
+	members := readAr(t, "!<arch>\n"+
+		arMember("/", "\x00\x00\x00\x00")+
+		arMember("//", names)+
+		arMember("/0", "first")+
+		arMember("short.txt/", "second")+
+		arMember("/29", "third"))
+	if len(members) != 3 || members["a-very-long-member-name.txt"] != "first" ||
+		members["short.txt"] != "second" || members["another-long-member-name"] != "third" {
+		t.Fatalf("Unexpected GNU members %q", members)
+	}
+
+	/* As written by BSD ar, the name leading the data */
+	members = readAr(t, "!<arch>\n"+
+		arMember("#1/27", "a-very-long-member-name.txtfirst")+
+		arMember("#1/20", "short\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00second"))
+	if len(members) != 2 || members["a-very-long-member-name.txt"] != "first" || members["short"] != "second" {
+		t.Fatalf("Unexpected BSD members %q", members)
+	}
+
+	for _, archive := range []string{
+		"!<arch>\n" + arMember("/0", "no table"),
+		"!<arch>\n" + arMember("//", "name/\n") + arMember("/42", "out of the table"),
+		"!<arch>\n" + arMember("#1/40", "longer than the member"),
+	} {
+		ar, err := deb.LoadAr(strings.NewReader(archive))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ar.Next(); err == nil {
+			t.Fatalf("Malformed long name accepted in %q", archive)
+		}
+	}
+}
+
+func TestArErrors(t *testing.T) {
+	next := func(archive string) (*deb.ArEntry, error) {
+		ar, err := deb.LoadAr(strings.NewReader(archive))
+		if err != nil {
+			return nil, err
+		}
+		return ar.Next()
+	}
+
+	if _, err := next("!<arch"); !errors.Is(err, deb.ErrTruncated) {
+		t.Fatalf("Short global header gave %v", err)
+	}
+	if _, err := next("!<arck>\n"); !errors.Is(err, deb.ErrBadMagic) {
+		t.Fatalf("Bad global header gave %v", err)
+	}
+	if _, err := next("!<arch>\n" + arMember("debian-binary", "2.0\n")[:30]); !errors.Is(err, deb.ErrTruncated) {
+		t.Fatalf("Short member header gave %v", err)
+	}
+	member := arMember("debian-binary", "2.0\n")
+	if _, err := next("!<arch>\n" + member[:58] + "\n\n" + member[60:]); !errors.Is(err, deb.ErrBadMagic) {
+		t.Fatalf("Bad member magic gave %v", err)
+	}
+
+	/* A member larger than 8 GiB, cut short */
+	entry, err := next("!<arch>\n" + fmt.Sprintf("%-16s%-12d%-6d%-6d%-8s%-10d`\n", "data.tar", 0, 0, 0, "100644", int64(9000000000)) + "data")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.Size != 9000000000 {
+		t.Fatalf("Unexpected size %d", entry.Size)
+	}
+	if _, err := ioutil.ReadAll(entry.Data); !errors.Is(err, deb.ErrTruncated) {
+		t.Fatalf("Truncated member gave %v", err)
+	}
+
+	/* Blank numeric fields, and the padding of the last member left out */
+	members := readAr(t, "!<arch>\n"+fmt.Sprintf("%-16s%-12s%-6s%-6s%-8s%-10d`\n", "debian-binary", "", "", "", "", 3)+"2.0")
+	if members["debian-binary"] != "2.0" {
+		t.Fatalf("Unexpected members %q", members)
+	}
+	if _, err := next("!<arch>\n" + fmt.Sprintf("%-16s%-12s%-6s%-6s%-8s%-10s`\n", "debian-binary", "", "", "", "", "") + "2.0"); err == nil {
+		t.Fatalf("Blank member size accepted")
+	}
+}
+
+// recordedProgress records the calls to a hashio.Progress.
+type recordedProgress struct {
+	events []string
+}
+
+func (p *recordedProgress) Start(item string, size int64) {
+	p.events = append(p.events, fmt.Sprintf("start %s %d", item, size))
+}
+
+func (p *recordedProgress) Advance(item string, n int64) {
+	p.events = append(p.events, fmt.Sprintf("advance %s %d", item, n))
+}
+
+func (p *recordedProgress) Done(item string, err error) {
+	p.events = append(p.events, fmt.Sprintf("done %s %v", item, err))
+}
+
+func TestArProgress(t *testing.T) {
+	ar, err := deb.LoadAr(strings.NewReader("!<arch>\n" +
+		arMember("//", "a-very-long-member-name.txt/\n") +
+		arMember("/0", "first") +
+		arMember("second", "skipped")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	progress := recordedProgress{}
+	ar.Progress = &progress
+	for {
+		entry, err := ar.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		if entry.Name == "a-very-long-member-name.txt" {
+			if _, err := ioutil.ReadAll(entry.Data); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	events := strings.Join(progress.events, ", ")
+	if events != "start a-very-long-member-name.txt 5, advance a-very-long-member-name.txt 5, "+
+		"done a-very-long-member-name.txt <nil>, start second 7, advance second 7, done second <nil>" {
+		t.Fatalf("Unexpected progress %s", events)
+	}
+}
+
+func TestArWriter(t *testing.T) {
+	out := bytes.Buffer{}
+	w := deb.NewArWriter(&out)
+	w.Timestamp = 1690000000
+	for _, member := range []struct{ name, data string }{
+		{"debian-binary", "2.0\n"},
+		{"control.tar.xz", "\xfd7zXZ\x00"},
+		{"data.tar.xz", "\xfd7zXZ\x00\x00"},
+	} {
+		if err := w.WriteEntry(member.name, int64(len(member.data)), strings.NewReader(member.data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	expected := "!<arch>\n" +
+		arMember("debian-binary", "2.0\n") +
+		arMember("control.tar.xz", "\xfd7zXZ\x00") +
+		arMember("data.tar.xz", "\xfd7zXZ\x00\x00")
+	if out.String() != expected {
+		t.Fatalf("Unexpected archive %q", out.String())
+	}
+
+	ar, err := deb.LoadAr(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry, err := ar.Next()
+	if err != nil || entry.Name != "debian-binary" || entry.Size != 4 || entry.Timestamp != 1690000000 {
+		t.Fatalf("Unexpected first member %v (%v)", entry, err)
+	}
+
+	w = deb.NewArWriter(ioutil.Discard)
+	if err := w.WriteEntry("debian-binary", 5, strings.NewReader("2.0\n")); err == nil {
+		t.Fatal("Short member data was accepted")
+	}
+	if err := w.WriteEntry("a-very-long-member-name", 0, strings.NewReader("")); err == nil {
+		t.Fatal("Long member name was accepted")
+	}
+
+	out.Reset()
+	if err := deb.NewArWriter(&out).Close(); err != nil || out.String() != "!<arch>\n" {
+		t.Fatalf("Unexpected empty archive %q (%v)", out.String(), err)
+	}
+}
+
+// vim: foldmethod=marker
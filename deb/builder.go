@@ -0,0 +1,550 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package deb // import "github.com/ebikt/go-debian/deb"
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/md5"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ebikt/go-debian/control"
+	"github.com/ebikt/go-debian/hashio"
+)
+
+// Builder {{{
+
+// A BuildFile is a file, directory, symbolic link or hard link of a package
+// being built.
+type BuildFile struct {
+	// Path of the file, relative to the root, such as "usr/bin/hello".
+	Path string
+
+	// tar.TypeReg, tar.TypeDir, tar.TypeSymlink or tar.TypeLink.
+	Type byte
+
+	// Permission bits; 0644 for files, 0755 for directories if 0.
+	Mode int64
+
+	// Target of a link, relative to the root for a hard link.
+	Linkname string
+
+	// Owner and group of the file, root if empty.
+	Owner string
+	Group string
+
+	// Contents of a regular file.
+	Data []byte
+
+	// Timestamp of the file, only used when the Builder clamps them; the
+	// ModTime of the Builder if zero.
+	ModTime time.Time
+}
+
+// Maintainer scripts a .deb may hold, which are executable.
+var maintainerScripts = map[string]bool{
+	"preinst":  true,
+	"postinst": true,
+	"prerm":    true,
+	"postrm":   true,
+	"config":   true,
+}
+
+// Builder builds a .deb from its control file, maintainer scripts and
+// files, like dpkg-deb --build does, in pure Go.
+//
+// The files are owned by root unless they say otherwise, sorted by path,
+// their timestamps set to ModTime, and the write permission of the group
+// and others is dropped. The compressors are set up to produce the same
+// output everywhere, so that the same input always builds the same .deb,
+// byte for byte, whatever the machine: see UseSourceDateEpoch for
+// reproducible builds. The directories leading to the files are added, the
+// md5sums file is generated, and the Installed-Size field is computed
+// unless the control file has one.
+type Builder struct {
+	// The control file; Package, Version, Architecture, Maintainer and
+	// Description are required.
+	Control control.Paragraph
+
+	// Maintainer scripts, such as "postinst", by name.
+	Scripts map[string][]byte
+
+	// Snippets put in place of the #DEBHELPER# token of the Scripts, as
+	// MaintainerScript does; scripts are made up for those missing.
+	Snippets []Snippet
+
+	// Triggers added to the triggers file, after the lines of the
+	// "triggers" of the ControlFiles if any.
+	Triggers []Trigger
+
+	// Absolute paths of the conffiles, such as "/etc/hello.conf", which
+	// must be regular files of the package.
+	Conffiles []string
+
+	// Other files of the control member, such as "triggers" or "shlibs".
+	ControlFiles map[string][]byte
+
+	// Files of the package; see AddFile, AddSymlink and AddTree.
+	Files []BuildFile
+
+	// If set, the files of the package are read from this tar stream,
+	// instead of Files.
+	DataTar io.Reader
+
+	// Compression of the control and data members: "gz", "xz", "zst" or
+	// "none"; "xz" if empty, as with dpkg-deb.
+	Compression string
+
+	// Level of the compression, 0 for its default one.
+	CompressionLevel int
+
+	// Timestamp of all the files and members; the Unix epoch if zero.
+	ModTime time.Time
+
+	// If set, ModTime is only an upper bound: the files keep their own
+	// ModTime when it's older, like dpkg-deb does with SOURCE_DATE_EPOCH.
+	ClampModTime bool
+}
+
+// NewBuilder creates a Builder of a package with the given control file.
+func NewBuilder(control control.Paragraph) *Builder {
+	return &Builder{
+		Control:      control,
+		Scripts:      map[string][]byte{},
+		ControlFiles: map[string][]byte{},
+	}
+}
+
+// AddFile adds a regular file to the package.
+func (b *Builder) AddFile(path string, mode int64, data []byte) {
+	b.Files = append(b.Files, BuildFile{Path: path, Type: tar.TypeReg, Mode: mode, Data: data})
+}
+
+// AddDirectory adds an (empty) directory to the package.
+func (b *Builder) AddDirectory(path string, mode int64) {
+	b.Files = append(b.Files, BuildFile{Path: path, Type: tar.TypeDir, Mode: mode})
+}
+
+// AddSymlink adds a symbolic link to the package.
+func (b *Builder) AddSymlink(path, target string) {
+	b.Files = append(b.Files, BuildFile{Path: path, Type: tar.TypeSymlink, Mode: 0777, Linkname: target})
+}
+
+// AddTree adds the files found under the root directory to the package,
+// as "debian/hello" is by dh_builddeb. A DEBIAN directory at its top is
+// skipped, as the control member is made of the Control, Scripts,
+// Conffiles and ControlFiles of the Builder.
+func (b *Builder) AddTree(root string) error {
+	return filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		switch {
+		case rel == ".":
+			return nil
+		case rel == "DEBIAN" && info.IsDir():
+			return filepath.SkipDir
+		case info.IsDir():
+			b.AddDirectory(rel, int64(info.Mode().Perm()))
+		case info.Mode()&os.ModeSymlink != 0:
+			target, err := os.Readlink(p)
+			if err != nil {
+				return err
+			}
+			b.AddSymlink(rel, target)
+		case info.Mode().IsRegular():
+			data, err := ioutil.ReadFile(p)
+			if err != nil {
+				return err
+			}
+			mode := int64(info.Mode().Perm())
+			if info.Mode()&os.ModeSetuid != 0 {
+				mode |= 04000
+			}
+			if info.Mode()&os.ModeSetgid != 0 {
+				mode |= 02000
+			}
+			b.AddFile(rel, mode, data)
+		default:
+			return fmt.Errorf("Unsupported file type of %s: %s", p, info.Mode())
+		}
+		b.Files[len(b.Files)-1].ModTime = info.ModTime()
+		return nil
+	})
+}
+
+// files returns the normalized files of the package, along with the
+// directories leading to them, sorted by path.
+func (b *Builder) files() ([]BuildFile, error) {
+	files := b.Files
+	if b.DataTar != nil {
+		files = []BuildFile{}
+		reader := tar.NewReader(b.DataTar)
+		for {
+			header, err := reader.Next()
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				return nil, err
+			}
+			file := BuildFile{
+				Path:     header.Name,
+				Type:     header.Typeflag,
+				Mode:     header.Mode,
+				Linkname: header.Linkname,
+				ModTime:  header.ModTime,
+			}
+			if header.Typeflag == tar.TypeReg {
+				if file.Data, err = ioutil.ReadAll(reader); err != nil {
+					return nil, err
+				}
+			}
+			files = append(files, file)
+		}
+	}
+
+	byPath := map[string]BuildFile{}
+	for _, file := range files {
+		file.Path = strings.TrimPrefix(path.Clean("/"+file.Path), "/")
+		if file.Path == "" {
+			continue
+		}
+		switch file.Type {
+		case tar.TypeReg:
+			if file.Mode == 0 {
+				file.Mode = 0644
+			}
+			file.Mode &= 07755
+		case tar.TypeDir:
+			if file.Mode == 0 {
+				file.Mode = 0755
+			}
+			file.Mode &= 07755
+		case tar.TypeSymlink:
+			file.Mode = 0777
+		case tar.TypeLink:
+			file.Linkname = strings.TrimPrefix(path.Clean("/"+file.Linkname), "/")
+		default:
+			return nil, fmt.Errorf("Unsupported type of %s: %q", file.Path, file.Type)
+		}
+		if _, found := byPath[file.Path]; found && file.Type != tar.TypeDir {
+			return nil, fmt.Errorf("Duplicate file %s", file.Path)
+		}
+		byPath[file.Path] = file
+
+		for dir := path.Dir(file.Path); dir != "."; dir = path.Dir(dir) {
+			if _, found := byPath[dir]; !found {
+				byPath[dir] = BuildFile{Path: dir, Type: tar.TypeDir, Mode: 0755}
+			}
+		}
+	}
+
+	sorted := []BuildFile{}
+	for _, file := range byPath {
+		sorted = append(sorted, file)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Path < sorted[j].Path })
+
+	/* Hard links come right after their target when they sort before it,
+	 * as the target has to be extracted first. */
+	ret := []BuildFile{}
+	links := map[string][]BuildFile{}
+	for _, file := range sorted {
+		if file.Type == tar.TypeLink {
+			target := byPath[file.Linkname]
+			if target.Type != tar.TypeReg {
+				return nil, fmt.Errorf("Hard link %s to %s, which is not a file of the package", file.Path, file.Linkname)
+			}
+			file.Mode = target.Mode
+			if file.Linkname > file.Path {
+				links[file.Linkname] = append(links[file.Linkname], file)
+				continue
+			}
+		}
+		ret = append(ret, file)
+		ret = append(ret, links[file.Path]...)
+	}
+	return ret, nil
+}
+
+func (b *Builder) modTime() time.Time {
+	if b.ModTime.IsZero() {
+		return time.Unix(0, 0)
+	}
+	return b.ModTime
+}
+
+// fileModTime returns the timestamp of the file in the .deb.
+func (b *Builder) fileModTime(file BuildFile) time.Time {
+	if b.ClampModTime && !file.ModTime.IsZero() && file.ModTime.Before(b.modTime()) {
+		return file.ModTime
+	}
+	return b.modTime()
+}
+
+// compressor returns the member extension and the compressor to use.
+func (b *Builder) compressor() (string, hashio.Compressor, error) {
+	switch b.Compression {
+	case "none":
+		return "", nil, nil
+	case "":
+		compressor, err := hashio.GetCompressorLevel("xz", b.CompressionLevel)
+		return ".xz", compressor, err
+	}
+	compressor, err := reproducibleCompressor(b.Compression, b.CompressionLevel)
+	return "." + b.Compression, compressor, err
+}
+
+// tarball writes a tar archive of the files, compressed.
+func (b *Builder) tarball(files []BuildFile, compressor hashio.Compressor) ([]byte, error) {
+	out := bytes.Buffer{}
+	var writer io.WriteCloser = nopWriteCloser{&out}
+	if compressor != nil {
+		var err error
+		if writer, err = compressor(&out); err != nil {
+			return nil, err
+		}
+	}
+
+	archive := tar.NewWriter(writer)
+	for _, file := range append([]BuildFile{{Path: ".", Type: tar.TypeDir, Mode: 0755}}, files...) {
+		header := tar.Header{
+			Name:     "./" + file.Path,
+			Typeflag: file.Type,
+			Mode:     file.Mode,
+			ModTime:  b.fileModTime(file).Truncate(time.Second),
+			Uname:    "root",
+			Gname:    "root",
+			Format:   tar.FormatGNU,
+		}
+		if file.Owner != "" {
+			header.Uname = file.Owner
+		}
+		if file.Group != "" {
+			header.Gname = file.Group
+		}
+		switch file.Type {
+		case tar.TypeDir:
+			header.Name += "/"
+			if file.Path == "." {
+				header.Name = "./"
+			}
+		case tar.TypeReg:
+			header.Size = int64(len(file.Data))
+		case tar.TypeSymlink:
+			header.Linkname = file.Linkname
+		case tar.TypeLink:
+			header.Linkname = "./" + file.Linkname
+		}
+		if err := archive.WriteHeader(&header); err != nil {
+			return nil, err
+		}
+		if _, err := archive.Write(file.Data); err != nil {
+			return nil, err
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+// controlFile returns the control file to write, with the Installed-Size
+// filled in if missing, without changing the Control of the Builder.
+func (b *Builder) controlFile(files []BuildFile) ([]byte, error) {
+	para := control.NewParagraph()
+	for _, key := range b.Control.Order {
+		para.Set(key, b.Control.Get(key))
+	}
+	for _, key := range []string{"Package", "Version", "Architecture", "Maintainer", "Description"} {
+		if para.Get(key) == "" {
+			return nil, fmt.Errorf("Missing %s field in the control file", key)
+		}
+	}
+	if !para.Has("Installed-Size") {
+		size := int64(0)
+		for _, file := range files {
+			if file.Type == tar.TypeReg {
+				size += (int64(len(file.Data)) + 1023) / 1024
+			} else {
+				size++
+			}
+		}
+		para.Set("Installed-Size", fmt.Sprint(size))
+	}
+	out := bytes.Buffer{}
+	if err := para.WriteTo(&out); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// md5sums returns the md5sums file of the files, leaving out the conffiles
+// like dh_md5sums does.
+func md5sums(files []BuildFile, conffiles map[string]bool) []byte {
+	hashes := map[string]string{}
+	for _, file := range files {
+		if file.Type == tar.TypeReg {
+			hashes[file.Path] = fmt.Sprintf("%x", md5.Sum(file.Data))
+		}
+	}
+	sums := MD5Sums{}
+	for _, file := range files {
+		hash := hashes[file.Path]
+		switch file.Type {
+		case tar.TypeReg:
+		case tar.TypeLink:
+			hash = hashes[file.Linkname]
+		default:
+			continue
+		}
+		if !conffiles["/"+file.Path] {
+			sums = append(sums, MD5Sum{Path: file.Path, Hash: hash})
+		}
+	}
+	return sums.Bytes()
+}
+
+// Build writes the .deb.
+func (b *Builder) Build(out io.Writer) error {
+	ext, compressor, err := b.compressor()
+	if err != nil {
+		return err
+	}
+	files, err := b.files()
+	if err != nil {
+		return err
+	}
+	controlFile, err := b.controlFile(files)
+	if err != nil {
+		return err
+	}
+
+	regular := map[string]bool{}
+	for _, file := range files {
+		regular["/"+file.Path] = file.Type == tar.TypeReg
+	}
+	conffiles := map[string]bool{}
+	for _, conffile := range b.Conffiles {
+		if !regular[conffile] {
+			return fmt.Errorf("Conffile %s is not a file of the package", conffile)
+		}
+		conffiles[conffile] = true
+	}
+
+	controlFiles := []BuildFile{
+		{Path: "control", Type: tar.TypeReg, Mode: 0644, Data: controlFile},
+		{Path: "md5sums", Type: tar.TypeReg, Mode: 0644, Data: md5sums(files, conffiles)},
+	}
+	if len(b.Conffiles) > 0 {
+		data := strings.Join(b.Conffiles, "\n") + "\n"
+		controlFiles = append(controlFiles, BuildFile{Path: "conffiles", Type: tar.TypeReg, Mode: 0644, Data: []byte(data)})
+	}
+	scripts := map[string][]byte{}
+	for name, data := range b.Scripts {
+		scripts[name] = data
+	}
+	for _, snippet := range b.Snippets {
+		if _, found := scripts[snippet.Script]; !found {
+			scripts[snippet.Script] = nil
+		}
+	}
+	for name, data := range scripts {
+		if !maintainerScripts[name] {
+			return fmt.Errorf("Unknown maintainer script '%s'", name)
+		}
+		data = MaintainerScript(name, data, b.Snippets)
+		controlFiles = append(controlFiles, BuildFile{Path: name, Type: tar.TypeReg, Mode: 0755, Data: data})
+	}
+	others := map[string][]byte{}
+	for name, data := range b.ControlFiles {
+		others[name] = data
+	}
+	if len(b.Triggers) > 0 {
+		triggers, err := FormatTriggers(b.Triggers)
+		if err != nil {
+			return err
+		}
+		others["triggers"] = append(append([]byte{}, others["triggers"]...), triggers...)
+	}
+	for name, data := range others {
+		if maintainerScripts[name] || strings.Contains(name, "/") {
+			return fmt.Errorf("Invalid control file name '%s'", name)
+		}
+		switch name {
+		case "control", "md5sums", "conffiles":
+			return fmt.Errorf("The %s control file is generated", name)
+		}
+		controlFiles = append(controlFiles, BuildFile{Path: name, Type: tar.TypeReg, Mode: 0644, Data: data})
+	}
+	sort.Slice(controlFiles, func(i, j int) bool { return controlFiles[i].Path < controlFiles[j].Path })
+
+	controlTar, err := b.tarball(controlFiles, compressor)
+	if err != nil {
+		return err
+	}
+	dataTar, err := b.tarball(files, compressor)
+	if err != nil {
+		return err
+	}
+
+	w := NewArWriter(out)
+	w.Timestamp = b.modTime().Unix()
+	for _, member := range []struct {
+		name string
+		data []byte
+	}{
+		{"debian-binary", []byte("2.0\n")},
+		{"control.tar" + ext, controlTar},
+		{"data.tar" + ext, dataTar},
+	} {
+		if err := w.WriteEntry(member.name, int64(len(member.data)), bytes.NewReader(member.data)); err != nil {
+			return err
+		}
+	}
+	return w.Close()
+}
+
+// }}}
+
+// vim: foldmethod=marker
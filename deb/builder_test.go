@@ -0,0 +1,267 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package deb_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ebikt/go-debian/control"
+	"github.com/ebikt/go-debian/deb"
+)
+
+/*
+ *
+ */
+
+func helloBuilder(t *testing.T) *deb.Builder {
+	para, err := control.NewParagraphReader(strings.NewReader(`Package: hello
+Version: 2.10-3
+Architecture: amd64
+Maintainer: Test Maintainer <test@example.com>
+Description: example package
+`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	controlFile, err := para.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := deb.NewBuilder(*controlFile)
+	b.AddFile("usr/bin/hello", 0775, []byte("#!/bin/sh\necho hello\n"))
+	b.AddFile("/etc/hello.conf", 0, []byte("greeting=hello\n"))
+	b.AddSymlink("usr/bin/hi", "hello")
+	b.Files = append(b.Files, deb.BuildFile{Path: "usr/bin/hey", Type: tar.TypeLink, Linkname: "usr/bin/hello"})
+	b.Scripts["postinst"] = []byte("#!/bin/sh\nexit 0\n")
+	b.Conffiles = []string{"/etc/hello.conf"}
+	b.ControlFiles["triggers"] = []byte("activate-noawait ldconfig\n")
+	return b
+}
+
+func buildDeb(t *testing.T, b *deb.Builder) []byte {
+	out := bytes.Buffer{}
+	if err := b.Build(&out); err != nil {
+		t.Fatal(err)
+	}
+	return out.Bytes()
+}
+
+func TestBuilder(t *testing.T) {
+	b := helloBuilder(t)
+	data := buildDeb(t, b)
+	if !bytes.Equal(data, buildDeb(t, helloBuilder(t))) {
+		t.Fatal("Builds are not reproducible")
+	}
+
+	debFile, err := deb.Load(bytes.NewReader(data), "hello_2.10-3_amd64.deb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if debFile.Control.Package != "hello" || debFile.Control.InstalledSize != 7 || debFile.ControlExt != "tar.xz" {
+		t.Fatalf("Unexpected package %v", debFile)
+	}
+	if b.Control.Has("Installed-Size") {
+		t.Fatal("The Control of the Builder was changed")
+	}
+	if string(debFile.ControlFiles["conffiles"]) != "/etc/hello.conf\n" ||
+		string(debFile.ControlFiles["triggers"]) != "activate-noawait ldconfig\n" ||
+		len(debFile.ControlFiles["postinst"]) == 0 {
+		t.Fatalf("Unexpected control files %v", debFile.ControlFiles)
+	}
+	sums, err := debFile.MD5Sums()
+	if err != nil || len(sums) != 2 || sums[0].Path != "usr/bin/hello" || sums[1].Path != "usr/bin/hey" {
+		t.Fatalf("Unexpected md5sums %v (%v)", sums, err)
+	}
+
+	headers := []string{}
+	for {
+		header, err := debFile.Data.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		if header.Uname != "root" || header.Uid != 0 || !header.ModTime.Equal(time.Unix(0, 0)) {
+			t.Fatalf("Unexpected header %v", header)
+		}
+		headers = append(headers, fmt.Sprintf("%s %o", header.Name, header.Mode))
+	}
+	expected := "./ 755 ./etc/ 755 ./etc/hello.conf 644 ./usr/ 755 ./usr/bin/ 755 " +
+		"./usr/bin/hello 755 ./usr/bin/hey 755 ./usr/bin/hi 777"
+	if strings.Join(headers, " ") != expected {
+		t.Fatalf("Unexpected data.tar %s", strings.Join(headers, " "))
+	}
+
+	debFile, err = deb.Load(bytes.NewReader(data), "hello_2.10-3_amd64.deb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := debFile.VerifyMD5Sums()
+	if err != nil || !report.OK() {
+		t.Fatalf("Unexpected md5sums report %v (%v)", report, err)
+	}
+}
+
+func TestBuilderHardLinkOrder(t *testing.T) {
+	b := helloBuilder(t)
+	b.Files = append(b.Files, deb.BuildFile{Path: "usr/bin/aloha", Type: tar.TypeLink, Linkname: "usr/bin/hello"})
+	data := buildDeb(t, b)
+
+	debFile, err := deb.Load(bytes.NewReader(data), "hello_2.10-3_amd64.deb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sums, err := debFile.MD5Sums()
+	if err != nil || len(sums) != 3 {
+		t.Fatalf("Unexpected md5sums %v (%v)", sums, err)
+	}
+	for _, sum := range sums {
+		if sum.Hash != sums[0].Hash {
+			t.Fatalf("Unexpected md5sums %v", sums)
+		}
+	}
+	names := []string{}
+	for {
+		header, err := debFile.Data.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, header.Name)
+	}
+	expected := "./ ./etc/ ./etc/hello.conf ./usr/ ./usr/bin/ ./usr/bin/hello ./usr/bin/aloha ./usr/bin/hey ./usr/bin/hi"
+	if strings.Join(names, " ") != expected {
+		t.Fatalf("Unexpected data.tar %s", strings.Join(names, " "))
+	}
+
+	debFile, err = deb.Load(bytes.NewReader(data), "hello_2.10-3_amd64.deb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := debFile.DataFS(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBuilderDataTar(t *testing.T) {
+	b := helloBuilder(t)
+	b.Files = nil
+	b.Conffiles = nil
+	b.DataTar = bytes.NewReader(tarball(t, map[string]string{"./usr/share/doc/hello/README": "hello\n"}))
+	b.Compression = "zst"
+	b.ModTime = time.Unix(1700000000, 0)
+
+	debFile, err := deb.Load(bytes.NewReader(buildDeb(t, b)), "hello_2.10-3_amd64.deb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if debFile.DataExt != "tar.zst" {
+		t.Fatalf("Unexpected data member %s", debFile.DataExt)
+	}
+	names := []string{}
+	for {
+		header, err := debFile.Data.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		if header.ModTime.Unix() != 1700000000 {
+			t.Fatalf("Unexpected timestamp %s", header.ModTime)
+		}
+		names = append(names, header.Name)
+	}
+	if len(names) != 6 || names[5] != "./usr/share/doc/hello/README" {
+		t.Fatalf("Unexpected data.tar %v", names)
+	}
+}
+
+func TestBuilderTree(t *testing.T) {
+	root := t.TempDir()
+	for name, data := range map[string]string{
+		"DEBIAN/control":      "ignored",
+		"usr/bin/hello":       "#!/bin/sh\n",
+		"usr/share/hello/msg": "hello\n",
+	} {
+		if err := os.MkdirAll(filepath.Join(root, filepath.Dir(name)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(root, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink("hello", filepath.Join(root, "usr/bin/hi")); err != nil {
+		t.Fatal(err)
+	}
+
+	b := helloBuilder(t)
+	b.Files, b.Conffiles = nil, nil
+	b.Compression = "none"
+	if err := b.AddTree(root); err != nil {
+		t.Fatal(err)
+	}
+	debFile, err := deb.Load(bytes.NewReader(buildDeb(t, b)), "hello_2.10-3_amd64.deb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for {
+		header, err := debFile.Data.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, header.Name)
+	}
+	if strings.Join(names, " ") != "./ ./usr/ ./usr/bin/ ./usr/bin/hello ./usr/bin/hi ./usr/share/ ./usr/share/hello/ ./usr/share/hello/msg" {
+		t.Fatalf("Unexpected data.tar %v", names)
+	}
+}
+
+func TestBuilderErrors(t *testing.T) {
+	for name, breakIt := range map[string]func(b *deb.Builder){
+		"missing field":  func(b *deb.Builder) { b.Control.Delete("Maintainer") },
+		"unknown script": func(b *deb.Builder) { b.Scripts["postinstall"] = []byte("") },
+		"conffile":       func(b *deb.Builder) { b.Conffiles = append(b.Conffiles, "/etc/missing") },
+		"control file":   func(b *deb.Builder) { b.ControlFiles["md5sums"] = []byte("") },
+		"compression":    func(b *deb.Builder) { b.Compression = "lz4" },
+		"hard link":      func(b *deb.Builder) { b.Files[3].Linkname = "usr/bin/hi" },
+		"duplicate":      func(b *deb.Builder) { b.AddFile("usr/bin/hello", 0, nil) },
+	} {
+		b := helloBuilder(t)
+		breakIt(b)
+		if err := b.Build(io.Discard); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+}
+
+// vim: foldmethod=marker
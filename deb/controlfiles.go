@@ -0,0 +1,183 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package deb // import "github.com/ebikt/go-debian/deb"
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/ebikt/go-debian/dependency"
+)
+
+// Control member files {{{
+
+// controlLines returns the lines of a file of the control member that
+// aren't blank or comments, without their trailing whitespace.
+func (deb *Deb) controlLines(name string) []string {
+	ret := []string{}
+	scanner := bufio.NewScanner(bytes.NewReader(deb.ControlFiles[name]))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		ret = append(ret, line)
+	}
+	return ret
+}
+
+// A Conffile is a line of the conffiles file of a .deb, see
+// deb-conffiles(5).
+type Conffile struct {
+	// Absolute path of the conffile, such as "/etc/hello.conf".
+	Path string
+
+	// Flags preceding the path, such as "remove-on-upgrade".
+	Flags []string
+}
+
+// HasFlag tells whether the conffile has the given flag.
+func (c Conffile) HasFlag(flag string) bool {
+	for _, f := range c.Flags {
+		if f == flag {
+			return true
+		}
+	}
+	return false
+}
+
+// ConffileEntries returns the conffiles of the .deb, with their flags, as
+// listed by its conffiles file.
+func (deb *Deb) ConffileEntries() []Conffile {
+	ret := []Conffile{}
+	for _, line := range deb.controlLines("conffiles") {
+		fields := strings.Fields(line)
+		ret = append(ret, Conffile{Path: fields[len(fields)-1], Flags: fields[:len(fields)-1]})
+	}
+	return ret
+}
+
+// Conffiles returns the paths of the conffiles of the .deb, as listed by
+// its conffiles file, such as "/etc/hello.conf". Flags such as
+// remove-on-upgrade are left out, see ConffileEntries.
+func (deb *Deb) Conffiles() []string {
+	ret := []string{}
+	for _, conffile := range deb.ConffileEntries() {
+		ret = append(ret, conffile.Path)
+	}
+	return ret
+}
+
+// Names of the maintainer scripts dpkg runs.
+var MaintainerScriptNames = []string{"preinst", "postinst", "prerm", "postrm"}
+
+// MaintainerScripts returns the maintainer scripts the .deb has, by name,
+// such as "postinst".
+func (deb *Deb) MaintainerScripts() map[string]string {
+	ret := map[string]string{}
+	for _, name := range MaintainerScriptNames {
+		if data, found := deb.ControlFiles[name]; found {
+			ret[name] = string(data)
+		}
+	}
+	return ret
+}
+
+// }}}
+
+// Triggers {{{
+
+// A Trigger is a line of the triggers file of a .deb, such as
+// "activate-noawait ldconfig", see deb-triggers(5).
+type Trigger struct {
+	// Such as "interest", "interest-noawait", "activate" or
+	// "activate-noawait".
+	Directive string
+
+	// Name of the trigger, or path of the file for file triggers.
+	Name string
+}
+
+func (t Trigger) String() string {
+	return t.Directive + " " + t.Name
+}
+
+// Triggers returns the triggers the .deb is interested in or activates.
+func (deb *Deb) Triggers() ([]Trigger, error) {
+	ret := []Trigger{}
+	for _, line := range deb.controlLines("triggers") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("Invalid triggers line: '%s'", line)
+		}
+		ret = append(ret, Trigger{Directive: fields[0], Name: fields[1]})
+	}
+	return ret, nil
+}
+
+// }}}
+
+// Shlibs {{{
+
+// A Shlib is a line of the shlibs file of a .deb, which tells what a
+// package linked to the library should depend on, see deb-shlibs(5).
+type Shlib struct {
+	// Package type the line applies to, such as "udeb", or empty for all
+	// of them.
+	Type string
+
+	// Library name and soname version, such as "libz" and "1" for
+	// libz.so.1.
+	Library string
+	Version string
+
+	Depends dependency.Dependency
+}
+
+// Shlibs returns the lines of the shlibs file of the .deb.
+func (deb *Deb) Shlibs() ([]Shlib, error) {
+	ret := []Shlib{}
+	for _, line := range deb.controlLines("shlibs") {
+		shlib := Shlib{}
+		if fields := strings.Fields(line); len(fields) > 0 && strings.HasSuffix(fields[0], ":") {
+			shlib.Type = strings.TrimSuffix(fields[0], ":")
+			line = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), fields[0]))
+		}
+		fields := strings.SplitN(strings.TrimSpace(line), " ", 3)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("Invalid shlibs line: '%s'", line)
+		}
+		shlib.Library, shlib.Version = fields[0], fields[1]
+		depends, err := dependency.Parse(strings.TrimSpace(fields[2]))
+		if err != nil {
+			return nil, err
+		}
+		shlib.Depends = *depends
+		ret = append(ret, shlib)
+	}
+	return ret, nil
+}
+
+// }}}
+
+// vim: foldmethod=marker
@@ -0,0 +1,116 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package deb_test
+
+import (
+	"testing"
+
+	"github.com/ebikt/go-debian/deb"
+)
+
+/*
+ *
+ */
+
+func TestControlFiles(t *testing.T) {
+	debFile := deb.Deb{ControlFiles: map[string][]byte{
+		"conffiles": []byte("/etc/hello.conf\n\nremove-on-upgrade /etc/old.conf\n"),
+		"postinst":  []byte("#!/bin/sh\nset -e\n"),
+		"prerm":     []byte("#!/bin/sh\n"),
+		"triggers":  []byte("# Triggers added by dh_makeshlibs\nactivate-noawait ldconfig\n"),
+		"shlibs":    []byte("libhello 1 libhello1 (>= 2.10)\nudeb: libhello 1 libhello1-udeb\n"),
+		"symbols": []byte("libhello.so.1 libhello1 #MINVER#\n" +
+			"| libhello1-compat\n" +
+			"* Build-Depends-Package: libhello-dev\n" +
+			" hello@Base 2.9\n" +
+			" hello_world@Base 2.10 1\n"),
+	}}
+
+	conffiles := debFile.Conffiles()
+	if len(conffiles) != 2 || conffiles[0] != "/etc/hello.conf" || conffiles[1] != "/etc/old.conf" {
+		t.Fatalf("Unexpected conffiles %v", conffiles)
+	}
+	entries := debFile.ConffileEntries()
+	if len(entries) != 2 || entries[0].HasFlag("remove-on-upgrade") || !entries[1].HasFlag("remove-on-upgrade") ||
+		len(entries[1].Flags) != 1 {
+		t.Fatalf("Unexpected conffile entries %v", entries)
+	}
+
+	scripts := debFile.MaintainerScripts()
+	if len(scripts) != 2 || scripts["postinst"] != "#!/bin/sh\nset -e\n" {
+		t.Fatalf("Unexpected maintainer scripts %v", scripts)
+	}
+
+	triggers, err := debFile.Triggers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(triggers) != 1 || triggers[0].String() != "activate-noawait ldconfig" {
+		t.Fatalf("Unexpected triggers %v", triggers)
+	}
+
+	shlibs, err := debFile.Shlibs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(shlibs) != 2 || shlibs[0].Library != "libhello" || shlibs[0].Version != "1" ||
+		shlibs[0].Depends.String() != "libhello1 (>= 2.10)" || shlibs[1].Type != "udeb" ||
+		shlibs[1].Depends.String() != "libhello1-udeb" {
+		t.Fatalf("Unexpected shlibs %v", shlibs)
+	}
+
+	symbols, err := debFile.Symbols()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(symbols) != 1 || symbols[0].Library != "libhello.so.1" || len(symbols[0].Depends) != 2 ||
+		symbols[0].Fields["Build-Depends-Package"] != "libhello-dev" || len(symbols[0].Symbols) != 2 {
+		t.Fatalf("Unexpected symbols %v", symbols)
+	}
+	symbol := symbols[0].Symbols[1]
+	if symbol.Name != "hello_world@Base" || symbol.MinVersion.String() != "2.10" || symbol.Template != 1 {
+		t.Fatalf("Unexpected symbol %v", symbol)
+	}
+}
+
+func TestControlFilesInvalid(t *testing.T) {
+	for name, data := range map[string]string{
+		"triggers": "activate\n",
+		"shlibs":   "libhello 1\n",
+		"symbols":  " hello@Base 2.9\n",
+	} {
+		debFile := deb.Deb{ControlFiles: map[string][]byte{name: []byte(data)}}
+		_, err1 := debFile.Triggers()
+		_, err2 := debFile.Shlibs()
+		_, err3 := debFile.Symbols()
+		if err1 == nil && err2 == nil && err3 == nil {
+			t.Fatalf("Invalid %s accepted", name)
+		}
+	}
+	debFile := deb.Deb{ControlFiles: map[string][]byte{
+		"symbols": []byte("libhello.so.1 libhello1\n hello@Base 2.9 1\n"),
+	}}
+	if _, err := debFile.Symbols(); err == nil {
+		t.Fatal("Unknown dependency template accepted")
+	}
+}
+
+// vim: foldmethod=marker
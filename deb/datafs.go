@@ -0,0 +1,365 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package deb // import "github.com/ebikt/go-debian/deb"
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DataFS {{{
+
+// DataFS is a read-only fs.FS of the files of a data.tar, so that the
+// contents of a .deb can be looked at with fs.WalkDir, fs.Glob,
+// http.FileServer and friends, without unpacking it. Paths are relative
+// to the root of the package, such as "usr/bin/hello", the root itself
+// being ".".
+//
+// Symbolic links are followed by Open, Stat and ReadFile, as the system
+// would once the package is installed, but never outside of the package:
+// absolute targets are taken relative to its root, and ".." stops there.
+// ReadDir and Lstat describe symbolic links themselves, and ReadLink
+// returns their target. Hard links share the contents of their target.
+//
+// The whole data.tar is read into memory.
+type DataFS struct {
+	files map[string]*dataFile
+}
+
+type dataFile struct {
+	header   tar.Header
+	data     []byte
+	children []string
+}
+
+// NewDataFS reads the whole data.tar into a DataFS.
+func NewDataFS(data *tar.Reader) (*DataFS, error) {
+	ret := DataFS{files: map[string]*dataFile{}}
+	ret.files["."] = &dataFile{header: tar.Header{
+		Name:     "./",
+		Typeflag: tar.TypeDir,
+		Mode:     0755,
+	}}
+
+	for {
+		header, err := data.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		name := md5sumsPath(header.Name)
+		if name == "" {
+			ret.files["."].header = *header
+			continue
+		}
+		file := &dataFile{header: *header}
+		switch header.Typeflag {
+		case tar.TypeReg:
+			if file.data, err = io.ReadAll(data); err != nil {
+				return nil, err
+			}
+		case tar.TypeLink:
+			target, found := ret.files[md5sumsPath(header.Linkname)]
+			if !found || target.header.Typeflag != tar.TypeReg {
+				return nil, fmt.Errorf("Hard link '%s' to unknown file '%s'", header.Name, header.Linkname)
+			}
+			file.header.Typeflag = tar.TypeReg
+			file.header.Size = target.header.Size
+			file.header.Linkname = ""
+			file.data = target.data
+		}
+		if err := ret.add(name, file); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, file := range ret.files {
+		sort.Strings(file.children)
+	}
+	return &ret, nil
+}
+
+// DataFS reads the Data of the Deb into a DataFS, see NewDataFS. This
+// consumes the Data of the Deb.
+func (deb *Deb) DataFS() (*DataFS, error) {
+	return NewDataFS(deb.Data)
+}
+
+/* Add a file, along with any of its directories missing from the tarball,
+ * which dpkg would create. */
+func (f *DataFS) add(name string, file *dataFile) error {
+	if existing, found := f.files[name]; found {
+		if existing.header.Typeflag == tar.TypeDir && file.header.Typeflag == tar.TypeDir {
+			file.children = existing.children
+			f.files[name] = file
+			return nil
+		}
+		return fmt.Errorf("Duplicate file '%s' in the data archive", name)
+	}
+	f.files[name] = file
+
+	dir, base := path.Split(name)
+	dir = strings.TrimSuffix(dir, "/")
+	if dir == "" {
+		dir = "."
+	}
+	parent, found := f.files[dir]
+	if !found {
+		parent = &dataFile{header: tar.Header{
+			Name:     dir + "/",
+			Typeflag: tar.TypeDir,
+			Mode:     0755,
+		}}
+		if err := f.add(dir, parent); err != nil {
+			return err
+		}
+	} else if parent.header.Typeflag != tar.TypeDir {
+		return fmt.Errorf("'%s' is in '%s', which is not a directory", name, dir)
+	}
+	parent.children = append(parent.children, base)
+	return nil
+}
+
+/* How many symbolic links are followed before giving up, as Linux does */
+const maxSymlinks = 40
+
+/* Find the file at the given path, following symbolic links in its
+ * directories, and the file itself if follow is set. */
+func (f *DataFS) lookup(op, name string, follow bool) (string, *dataFile, error) {
+	if !fs.ValidPath(name) {
+		return "", nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	links := 0
+	current := "."
+	rest := strings.Split(name, "/")
+	if name == "." {
+		rest = nil
+	}
+	for len(rest) > 0 {
+		component := rest[0]
+		rest = rest[1:]
+		next := path.Join(current, component)
+		file, found := f.files[next]
+		if !found {
+			return "", nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+		}
+		if file.header.Typeflag != tar.TypeSymlink || (len(rest) == 0 && !follow) {
+			if len(rest) > 0 && file.header.Typeflag != tar.TypeDir {
+				return "", nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+			}
+			current = next
+			continue
+		}
+		if links++; links > maxSymlinks {
+			return "", nil, &fs.PathError{Op: op, Path: name, Err: fmt.Errorf("Too many levels of symbolic links")}
+		}
+		target := file.header.Linkname
+		if path.IsAbs(target) {
+			current = "."
+		}
+		/* path.Clean("/"+...) stops ".." at the root */
+		target = strings.TrimPrefix(path.Clean("/"+path.Join(current, target)), "/")
+		current = "."
+		if target != "" {
+			rest = append(strings.Split(target, "/"), rest...)
+		}
+	}
+	return current, f.files[current], nil
+}
+
+// Open opens the named file, following symbolic links.
+func (f *DataFS) Open(name string) (fs.File, error) {
+	resolved, file, err := f.lookup("open", name, true)
+	if err != nil {
+		return nil, err
+	}
+	info := dataFileInfo{name: path.Base(name), file: file}
+	if file.header.Typeflag == tar.TypeDir {
+		return &dataDir{fs: f, path: resolved, info: info}, nil
+	}
+	return &dataRegular{info: info, Reader: bytes.NewReader(file.data)}, nil
+}
+
+// Stat describes the named file, following symbolic links.
+func (f *DataFS) Stat(name string) (fs.FileInfo, error) {
+	_, file, err := f.lookup("stat", name, true)
+	if err != nil {
+		return nil, err
+	}
+	return dataFileInfo{name: path.Base(name), file: file}, nil
+}
+
+// Lstat describes the named file, without following it if it's a
+// symbolic link.
+func (f *DataFS) Lstat(name string) (fs.FileInfo, error) {
+	_, file, err := f.lookup("lstat", name, false)
+	if err != nil {
+		return nil, err
+	}
+	return dataFileInfo{name: path.Base(name), file: file}, nil
+}
+
+// ReadLink returns the target of the named symbolic link, as it is in the
+// archive.
+func (f *DataFS) ReadLink(name string) (string, error) {
+	_, file, err := f.lookup("readlink", name, false)
+	if err != nil {
+		return "", err
+	}
+	if file.header.Typeflag != tar.TypeSymlink {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+	}
+	return file.header.Linkname, nil
+}
+
+// ReadFile returns the contents of the named file, following symbolic
+// links.
+func (f *DataFS) ReadFile(name string) ([]byte, error) {
+	_, file, err := f.lookup("read", name, true)
+	if err != nil {
+		return nil, err
+	}
+	if file.header.Typeflag == tar.TypeDir {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fmt.Errorf("Is a directory")}
+	}
+	return append([]byte{}, file.data...), nil
+}
+
+// ReadDir lists the named directory, sorted by name, following symbolic
+// links to it, but not those in it.
+func (f *DataFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	resolved, file, err := f.lookup("readdir", name, true)
+	if err != nil {
+		return nil, err
+	}
+	if file.header.Typeflag != tar.TypeDir {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fmt.Errorf("Not a directory")}
+	}
+	return f.entries(resolved, file), nil
+}
+
+func (f *DataFS) entries(dir string, file *dataFile) []fs.DirEntry {
+	ret := make([]fs.DirEntry, 0, len(file.children))
+	for _, child := range file.children {
+		info := dataFileInfo{name: child, file: f.files[path.Join(dir, child)]}
+		ret = append(ret, fs.FileInfoToDirEntry(info))
+	}
+	return ret
+}
+
+// }}}
+
+// DataFS files {{{
+
+type dataFileInfo struct {
+	name string
+	file *dataFile
+}
+
+func (i dataFileInfo) Name() string {
+	return i.name
+}
+
+func (i dataFileInfo) Size() int64 {
+	return int64(len(i.file.data))
+}
+
+func (i dataFileInfo) Mode() fs.FileMode {
+	return i.file.header.FileInfo().Mode()
+}
+
+func (i dataFileInfo) ModTime() time.Time {
+	return i.file.header.ModTime
+}
+
+func (i dataFileInfo) IsDir() bool {
+	return i.file.header.Typeflag == tar.TypeDir
+}
+
+// Sys returns the *tar.Header of the file, with its owner, and the target
+// of a symbolic link.
+func (i dataFileInfo) Sys() interface{} {
+	return &i.file.header
+}
+
+type dataRegular struct {
+	info dataFileInfo
+	*bytes.Reader
+}
+
+func (r *dataRegular) Stat() (fs.FileInfo, error) {
+	return r.info, nil
+}
+
+func (r *dataRegular) Close() error {
+	return nil
+}
+
+type dataDir struct {
+	fs      *DataFS
+	path    string
+	info    dataFileInfo
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (d *dataDir) Stat() (fs.FileInfo, error) {
+	return d.info, nil
+}
+
+func (d *dataDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.path, Err: fmt.Errorf("Is a directory")}
+}
+
+func (d *dataDir) Close() error {
+	return nil
+}
+
+func (d *dataDir) ReadDir(count int) ([]fs.DirEntry, error) {
+	if d.entries == nil {
+		d.entries = d.fs.entries(d.path, d.info.file)
+	}
+	rest := d.entries[d.offset:]
+	if count <= 0 {
+		d.offset = len(d.entries)
+		return rest, nil
+	}
+	if len(rest) == 0 {
+		return nil, io.EOF
+	}
+	if count > len(rest) {
+		count = len(rest)
+	}
+	d.offset += count
+	return rest[:count], nil
+}
+
+// }}}
+
+// vim: foldmethod=marker
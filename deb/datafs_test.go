@@ -0,0 +1,135 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package deb_test
+
+import (
+	"archive/tar"
+	"errors"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/ebikt/go-debian/deb"
+)
+
+/*
+ *
+ */
+
+func TestDataFS(t *testing.T) {
+	modTime := time.Date(2023, 6, 10, 12, 0, 0, 0, time.UTC)
+	files, err := deb.NewDataFS(members(t,
+		tarMember{header: tar.Header{Name: "./", Typeflag: tar.TypeDir, Mode: 0755}},
+		tarMember{header: tar.Header{Name: "./usr/bin/", Typeflag: tar.TypeDir, Mode: 0755, ModTime: modTime}},
+		tarMember{header: tar.Header{Name: "./usr/bin/hello", Mode: 04755, ModTime: modTime}, data: "hello"},
+		tarMember{header: tar.Header{Name: "./usr/bin/hi", Typeflag: tar.TypeLink, Linkname: "./usr/bin/hello"}},
+		tarMember{header: tar.Header{Name: "./usr/bin/hey", Typeflag: tar.TypeSymlink, Linkname: "hello"}},
+		tarMember{header: tar.Header{Name: "./usr/share/doc/hello/copyright", Mode: 0644}, data: "MIT"},
+		tarMember{header: tar.Header{Name: "./usr/share/doc/hi", Typeflag: tar.TypeSymlink, Linkname: "/usr/share/doc/hello"}},
+		tarMember{header: tar.Header{Name: "./usr/lib/escape", Typeflag: tar.TypeSymlink, Linkname: "../../../../usr/bin/hello"}},
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := fstest.TestFS(files, "usr/bin/hello", "usr/bin/hi", "usr/share/doc/hello/copyright"); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, expected := range map[string]string{
+		"usr/bin/hello":              "hello",
+		"usr/bin/hi":                 "hello",
+		"usr/bin/hey":                "hello",
+		"usr/share/doc/hi/copyright": "MIT",
+		"usr/lib/escape":             "hello",
+	} {
+		if data, err := fs.ReadFile(files, name); err != nil || string(data) != expected {
+			t.Fatalf("Unexpected contents %q of %s (%v)", data, name, err)
+		}
+	}
+
+	info, err := fs.Stat(files, "usr/bin/hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode() != fs.ModeSetuid|0755 || !info.ModTime().Equal(modTime) || info.Size() != 5 {
+		t.Fatalf("Unexpected mode %s, time %s or size %d", info.Mode(), info.ModTime(), info.Size())
+	}
+	if header, ok := info.Sys().(*tar.Header); !ok || header.Name != "./usr/bin/hello" {
+		t.Fatalf("Unexpected Sys %v", info.Sys())
+	}
+
+	info, err = files.Lstat("usr/share/doc/hi")
+	if err != nil || info.Mode()&fs.ModeSymlink == 0 {
+		t.Fatalf("Unexpected Lstat %v (%v)", info, err)
+	}
+	if target, err := files.ReadLink("usr/share/doc/hi"); err != nil || target != "/usr/share/doc/hello" {
+		t.Fatalf("Unexpected link target %q (%v)", target, err)
+	}
+	if _, err := files.ReadLink("usr/bin/hello"); err == nil {
+		t.Fatal("Read a link which is a file")
+	}
+
+	entries, err := fs.ReadDir(files, "usr/share/doc")
+	if err != nil || len(entries) != 2 || entries[0].Name() != "hello" || entries[1].Type() != fs.ModeSymlink {
+		t.Fatalf("Unexpected entries %v (%v)", entries, err)
+	}
+	if _, err := fs.ReadDir(files, "usr/share/doc/hi"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := files.Open("usr/bin/nope"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if _, err := files.Open("/usr/bin/hello"); !errors.Is(err, fs.ErrInvalid) {
+		t.Fatalf("Unexpected error %v", err)
+	}
+}
+
+func TestDataFSBroken(t *testing.T) {
+	_, err := deb.NewDataFS(members(t,
+		tarMember{header: tar.Header{Name: "./usr/bin/hi", Typeflag: tar.TypeLink, Linkname: "./usr/bin/hello"}},
+	))
+	if err == nil {
+		t.Fatal("Hard link to nothing accepted")
+	}
+
+	_, err = deb.NewDataFS(members(t,
+		tarMember{header: tar.Header{Name: "./usr", Mode: 0644}, data: "file"},
+		tarMember{header: tar.Header{Name: "./usr/bin/hello", Mode: 0755}, data: "hello"},
+	))
+	if err == nil {
+		t.Fatal("File in a file accepted")
+	}
+
+	files, err := deb.NewDataFS(members(t,
+		tarMember{header: tar.Header{Name: "./loop", Typeflag: tar.TypeSymlink, Linkname: "loop"}},
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := files.Open("loop"); err == nil {
+		t.Fatal("Opened a symlink loop")
+	}
+}
+
+// vim: foldmethod=marker
@@ -23,14 +23,18 @@ package deb // import "github.com/ebikt/go-debian/deb"
 import (
 	"archive/tar"
 	"bufio"
+	"bytes"
+	"context"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"path"
 	"strings"
 
 	"github.com/ebikt/go-debian/control"
 	"github.com/ebikt/go-debian/dependency"
+	"github.com/ebikt/go-debian/hashio"
 	"github.com/ebikt/go-debian/version"
 )
 
@@ -53,6 +57,7 @@ type Control struct {
 	Recommends    dependency.Dependency
 	Suggests      dependency.Dependency
 	Breaks        dependency.Dependency
+	Conflicts     dependency.Dependency
 	Replaces      dependency.Dependency
 	BuiltUsing    dependency.Dependency `control:"Built-Using"`
 	Section       string
@@ -68,6 +73,16 @@ func (c Control) SourceName() string {
 	return c.Source
 }
 
+// IsUdeb tells whether the control file is the one of a udeb, as its
+// Package-Type or a debian-installer Section say. Udebs don't need much of
+// the control file of a .deb: no Maintainer nor long Description.
+func (c Control) IsUdeb() bool {
+	if c.Paragraph.Get("Package-Type") == "udeb" {
+		return true
+	}
+	return c.Section == "debian-installer" || strings.HasSuffix(c.Section, "/debian-installer")
+}
+
 // }}}
 
 // Deb {{{
@@ -77,11 +92,25 @@ func (c Control) SourceName() string {
 // regarding the Control file is read from the control section of the .deb,
 // and Unmarshaled into the `Control` member of the Struct.
 type Deb struct {
-	Control Control
-	Path    string
-	Data    *tar.Reader
-	ControlExt  string
-	DataExt	    string
+	Control    Control     `json:"control"`
+	Path       string      `json:"path"`
+	Data       *tar.Reader `json:"-"`
+	ControlExt string      `json:"control_ext"`
+	DataExt    string      `json:"data_ext"`
+
+	// The files of the control member, such as "control", "md5sums" or
+	// "postinst", by their cleaned path.
+	ControlFiles map[string][]byte `json:"-"`
+
+	// Set when the .deb was loaded with LoadHashed.
+	hashing *hashio.HashingReader
+}
+
+// IsUdeb tells whether the .deb is a udeb, a micro binary package of the
+// Debian Installer: it's named as one, such as "hello-udeb_2.10-3_amd64.udeb",
+// or its control file says so.
+func (d *Deb) IsUdeb() bool {
+	return path.Ext(d.Path) == ".udeb" || d.Control.IsUdeb()
 }
 
 // Load {{{
@@ -103,6 +132,70 @@ func Load(in io.Reader, pathname string) (*Deb, error) {
 	return deb, nil
 }
 
+// Like Load, but reading stops once the context is done, reads of the
+// Data included, so that unpacking the largest .deb files can be
+// cancelled, or given a deadline.
+func LoadContext(ctx context.Context, in io.Reader, pathname string) (*Deb, error) {
+	return Load(hashio.NewContextReader(ctx, in), pathname)
+}
+
+// }}}
+
+// LoadHashed {{{
+
+// Like Load, but the .deb file is hashed while it is read, so that its
+// checksums are at hand through Checksums without reading it a second time.
+// The algorithms are the hashio ones, md5, sha1 and sha256 if none is given.
+func LoadHashed(in io.Reader, pathname string, hashes ...string) (*Deb, error) {
+	hashing, err := hashio.NewHashingReader(in, hashes...)
+	if err != nil {
+		return nil, err
+	}
+	deb, err := Load(hashing, pathname)
+	if err != nil {
+		return nil, err
+	}
+	deb.hashing = hashing
+	return deb, nil
+}
+
+// Checksums returns the checksums of the whole .deb file, as listed by a
+// .changes file or a Packages entry, named after the base name of its path.
+// Whatever wasn't read of the .deb yet is read to compute them, so Data
+// can't be read any further afterwards.
+func (d *Deb) Checksums() (control.FileHashes, error) {
+	if d.hashing == nil {
+		return nil, fmt.Errorf("The .deb was not loaded with LoadHashed")
+	}
+	if err := d.hashing.Drain(); err != nil {
+		return nil, err
+	}
+	ret := control.FileHashes{}
+	for _, hasher := range d.hashing.Hashers() {
+		ret = append(ret, control.FileHashFromHasher(path.Base(d.Path), *hasher))
+	}
+	return ret, nil
+}
+
+// ChangesFile returns the .deb as listed by a .changes file, see
+// control.GenerateChanges. Like Checksums, the .deb must have been loaded
+// with LoadHashed, and is read to its end.
+func (d *Deb) ChangesFile() (*control.ChangesFile, error) {
+	hashes, err := d.Checksums()
+	if err != nil {
+		return nil, err
+	}
+	synopsis := strings.SplitN(d.Control.Description, "\n", 2)[0]
+	return &control.ChangesFile{
+		Hashes:       hashes,
+		Section:      d.Control.Section,
+		Priority:     d.Control.Priority,
+		Package:      d.Control.Package,
+		Architecture: d.Control.Architecture.String(),
+		Description:  strings.TrimSpace(synopsis),
+	}, nil
+}
+
 // }}}
 
 // LoadFile {{{
@@ -125,6 +218,22 @@ func LoadFile(path string) (*Deb, Closer, error) {
 
 }
 
+// Like LoadFile, but with the .deb hashed as by LoadHashed.
+func LoadFileHashed(path string, hashes ...string) (*Deb, Closer, error) {
+	fd, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	debFile, err := LoadHashed(fd, path, hashes...)
+	if err != nil {
+		fd.Close()
+		return nil, nil, err
+	}
+
+	return debFile, fd.Close, nil
+}
+
 // }}}
 
 // Debian .deb Loader Internals {{{
@@ -194,21 +303,34 @@ func loadDeb2Control(archive *Ar, deb *Deb) error {
 		if err != nil {
 			return err
 		}
-		if strings.HasPrefix(member.Name, "control.") {
+		if member.IsControl() {
 			archive, err := member.Tarfile()
 			if err != nil {
 				return err
 			}
 			deb.ControlExt = member.Name[8:len(member.Name)]
+			deb.ControlFiles = map[string][]byte{}
 			for {
 				member, err := archive.Next()
-				if err != nil {
+				if err == io.EOF {
+					break
+				} else if err != nil {
 					return err
 				}
-				if path.Clean(member.Name) == "control" {
-					return control.Unmarshal(&deb.Control, archive)
+				if member.Typeflag != tar.TypeReg {
+					continue
+				}
+				data, err := ioutil.ReadAll(archive)
+				if err != nil {
+					return err
 				}
+				deb.ControlFiles[path.Clean(member.Name)] = data
+			}
+			data, found := deb.ControlFiles["control"]
+			if !found {
+				return fmt.Errorf("Missing control file in the control member")
 			}
+			return control.Unmarshal(&deb.Control, bytes.NewReader(data))
 		}
 	}
 }
@@ -228,7 +350,7 @@ func loadDeb2Data(archive *Ar, deb *Deb) error {
 		if err != nil {
 			return err
 		}
-		if strings.HasPrefix(member.Name, "data.") {
+		if member.IsData() {
 			archive, err := member.Tarfile()
 			if err != nil {
 				return err
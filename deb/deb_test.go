@@ -0,0 +1,219 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package deb_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/ebikt/go-debian/deb"
+	"github.com/ebikt/go-debian/testutil"
+	"github.com/klauspost/compress/zstd"
+)
+
+/*
+ *
+ */
+
+func tarball(t *testing.T, files map[string]string) []byte {
+	out := bytes.Buffer{}
+	w := tar.NewWriter(&out)
+	for name, data := range files {
+		if err := w.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(data))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return out.Bytes()
+}
+
+func TestLoadCompression(t *testing.T) {
+	control := tarball(t, map[string]string{
+		"./control": "Package: hello\nVersion: 2.10-3\nArchitecture: amd64\n",
+	})
+	data := tarball(t, map[string]string{"./usr/bin/hello": "#!/bin/sh\n"})
+
+	encoder, err := zstd.NewWriter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compressed := encoder.EncodeAll(control, nil)
+
+	out := bytes.Buffer{}
+	w := deb.NewArWriter(&out)
+	for _, member := range []struct {
+		name string
+		data []byte
+	}{
+		{"debian-binary", []byte("2.0\n")},
+		{"control.tar.zst", compressed},
+		{"data.tar", data},
+	} {
+		if err := w.WriteEntry(member.name, int64(len(member.data)), bytes.NewReader(member.data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	debFile, err := deb.Load(&out, "hello_2.10-3_amd64.deb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if debFile.Control.Package != "hello" || debFile.ControlExt != "tar.zst" || debFile.DataExt != "tar" {
+		t.Fatalf("Unexpected package %v", debFile)
+	}
+	header, err := debFile.Data.Next()
+	if err != nil || header.Name != "./usr/bin/hello" {
+		t.Fatalf("Unexpected data member %v (%v)", header, err)
+	}
+	content, err := ioutil.ReadAll(debFile.Data)
+	if err != nil || !strings.HasPrefix(string(content), "#!") {
+		t.Fatalf("Unexpected data %q (%v)", content, err)
+	}
+
+	encoded, err := json.Marshal(debFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"control":{"Package":"hello","Version":"2.10-3","Architecture":"amd64","Installed-Size":"0"},` +
+		`"path":"hello_2.10-3_amd64.deb","control_ext":"tar.zst","data_ext":"tar"}`
+	if string(encoded) != expected {
+		t.Fatalf("Unexpected JSON %s", encoded)
+	}
+	decoded := deb.Deb{}
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Control.Version.String() != "2.10-3" || decoded.Control.Architecture.CPU != "amd64" {
+		t.Fatalf("Unexpected decoded control %v", decoded.Control)
+	}
+}
+
+func TestIsUdeb(t *testing.T) {
+	for _, test := range []struct {
+		pkg      testutil.Deb
+		path     string
+		expected bool
+	}{
+		{testutil.Deb{Package: "hello", Version: "1"}, "hello_1_all.deb", false},
+		{testutil.Deb{Package: "hello-udeb", Version: "1"}, "hello-udeb_1_all.udeb", true},
+		{testutil.Deb{Package: "hello-udeb", Version: "1",
+			Fields: map[string]string{"Package-Type": "udeb"}}, "hello", true},
+		{testutil.Deb{Package: "hello-udeb", Version: "1",
+			Fields: map[string]string{"Section": "non-free/debian-installer"}}, "hello", true},
+	} {
+		data, err := test.pkg.Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+		debFile, err := deb.Load(bytes.NewReader(data), test.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if debFile.IsUdeb() != test.expected {
+			t.Errorf("IsUdeb of %s is %t", test.path, !test.expected)
+		}
+	}
+}
+
+func TestLoadHashed(t *testing.T) {
+	control := tarball(t, map[string]string{
+		"./control": "Package: hello\nVersion: 2.10-3\nArchitecture: amd64\n",
+	})
+	data := tarball(t, map[string]string{"./usr/bin/hello": "#!/bin/sh\n"})
+
+	out := bytes.Buffer{}
+	w := deb.NewArWriter(&out)
+	for _, member := range []struct {
+		name string
+		data []byte
+	}{
+		{"debian-binary", []byte("2.0\n")},
+		{"control.tar", control},
+		{"data.tar", data},
+	} {
+		if err := w.WriteEntry(member.name, int64(len(member.data)), bytes.NewReader(member.data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	raw := out.Bytes()
+
+	debFile, err := deb.Load(bytes.NewReader(raw), "hello_2.10-3_amd64.deb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := debFile.Checksums(); err == nil {
+		t.Fatal("Checksums of a .deb loaded without hashing")
+	}
+
+	debFile, err = deb.LoadHashed(bytes.NewReader(raw), "pool/hello_2.10-3_amd64.deb", "md5", "sha256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	/* Read some of the data, Checksums reads the rest */
+	if _, err := debFile.Data.Next(); err != nil {
+		t.Fatal(err)
+	}
+	hashes, err := debFile.Checksums()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hashes) != 2 || hashes[0].Algorithm != "md5" || hashes[1].Algorithm != "sha256" {
+		t.Fatalf("Unexpected checksums %v", hashes)
+	}
+	if hashes[1].Hash != fmt.Sprintf("%x", sha256.Sum256(raw)) || hashes[1].Size != int64(len(raw)) {
+		t.Fatalf("Wrong checksum %v", hashes[1])
+	}
+	if hashes[1].Filename != "hello_2.10-3_amd64.deb" {
+		t.Fatalf("Wrong filename %s", hashes[1].Filename)
+	}
+	if err := hashes.VerifyReader(bytes.NewReader(raw)); err != nil {
+		t.Fatal(err)
+	}
+
+	debFile, err = deb.LoadHashed(bytes.NewReader(raw), "pool/hello_2.10-3_amd64.deb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := debFile.ChangesFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.Package != "hello" || file.Architecture != "amd64" || len(file.Hashes) != 3 {
+		t.Fatalf("Unexpected changes file %v", file)
+	}
+
+	if _, err := deb.LoadHashed(bytes.NewReader(raw), "hello.deb", "crc32"); err == nil {
+		t.Fatal("Unknown algorithm accepted")
+	}
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,266 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package deb // import "github.com/ebikt/go-debian/deb"
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/fs"
+	"sort"
+	"strings"
+)
+
+// Diff {{{
+
+// DiffOptions tell Diff how closely to compare two .deb files.
+type DiffOptions struct {
+	// Compare the contents of the regular files, by their sha256
+	// checksums, rather than only their sizes.
+	Contents bool
+}
+
+// A FieldChange is a field of the control file which differs between two
+// .deb files. Old is empty for a field which was added, New for one which
+// was removed.
+type FieldChange struct {
+	Field string
+	Old   string
+	New   string
+}
+
+func (c FieldChange) String() string {
+	return fmt.Sprintf("%s: %q -> %q", c.Field, c.Old, c.New)
+}
+
+// A FileChange is a file of the data.tar of both .deb files, which
+// differs between them.
+type FileChange struct {
+	// Path of the file, relative to the root, such as "usr/bin/hello".
+	Path string
+
+	OldSize int64
+	NewSize int64
+
+	OldMode fs.FileMode
+	NewMode fs.FileMode
+
+	// Owners, as "user/group".
+	OldOwner string
+	NewOwner string
+
+	// Targets of symbolic links.
+	OldTarget string
+	NewTarget string
+
+	// Whether the contents differ, when compared with DiffOptions.Contents.
+	Contents bool
+}
+
+// String describes what changed, such as
+// "usr/bin/hello: size 5 -> 6, mode -rwxr-xr-x -> -rw-r--r--".
+func (c FileChange) String() string {
+	changes := []string{}
+	if c.OldSize != c.NewSize {
+		changes = append(changes, fmt.Sprintf("size %d -> %d", c.OldSize, c.NewSize))
+	}
+	if c.OldMode != c.NewMode {
+		changes = append(changes, fmt.Sprintf("mode %s -> %s", c.OldMode, c.NewMode))
+	}
+	if c.OldOwner != c.NewOwner {
+		changes = append(changes, fmt.Sprintf("owner %s -> %s", c.OldOwner, c.NewOwner))
+	}
+	if c.OldTarget != c.NewTarget {
+		changes = append(changes, fmt.Sprintf("target %s -> %s", c.OldTarget, c.NewTarget))
+	}
+	if c.Contents {
+		changes = append(changes, "contents")
+	}
+	return c.Path + ": " + strings.Join(changes, ", ")
+}
+
+// DiffReport lists the differences between two .deb files, as debdiff
+// does. An empty report means they're the same, as far as they were
+// compared.
+type DiffReport struct {
+	// Fields of the control file which changed, in the order of the old
+	// one, followed by those only in the new one.
+	Control []FieldChange
+
+	// Paths of the files only in the new .deb, and only in the old one.
+	Added   []string
+	Removed []string
+
+	// Files of both which changed, sorted by path.
+	Changed []FileChange
+}
+
+// Empty returns whether no difference was found.
+func (r DiffReport) Empty() bool {
+	return len(r.Control) == 0 && len(r.Added) == 0 && len(r.Removed) == 0 &&
+		len(r.Changed) == 0
+}
+
+// String renders the report, a section for each kind of difference.
+func (r DiffReport) String() string {
+	out := strings.Builder{}
+	section := func(title string, lines []string) {
+		if len(lines) == 0 {
+			return
+		}
+		out.WriteString(title + ":\n")
+		for _, line := range lines {
+			out.WriteString("  " + line + "\n")
+		}
+	}
+	control := []string{}
+	for _, change := range r.Control {
+		control = append(control, change.String())
+	}
+	changed := []string{}
+	for _, change := range r.Changed {
+		changed = append(changed, change.String())
+	}
+	section("Control", control)
+	section("Added", r.Added)
+	section("Removed", r.Removed)
+	section("Changed", changed)
+	return out.String()
+}
+
+/* What's compared of a file of the data.tar */
+type diffEntry struct {
+	size   int64
+	mode   fs.FileMode
+	owner  string
+	target string
+	hash   string
+}
+
+func diffEntries(data *tar.Reader, contents bool) (map[string]diffEntry, error) {
+	ret := map[string]diffEntry{}
+	for {
+		header, err := data.Next()
+		if err == io.EOF {
+			return ret, nil
+		} else if err != nil {
+			return nil, err
+		}
+		name := md5sumsPath(header.Name)
+		if name == "" {
+			continue
+		}
+		entry := diffEntry{
+			size:  header.Size,
+			mode:  header.FileInfo().Mode(),
+			owner: header.Uname + "/" + header.Gname,
+		}
+		switch header.Typeflag {
+		case tar.TypeReg:
+			if contents {
+				hash := sha256.New()
+				if _, err := io.Copy(hash, data); err != nil {
+					return nil, err
+				}
+				entry.hash = fmt.Sprintf("%x", hash.Sum(nil))
+			}
+		case tar.TypeLink:
+			/* Compared as the file it's a link to */
+			if target, found := ret[md5sumsPath(header.Linkname)]; found {
+				entry.size, entry.hash = target.size, target.hash
+			}
+		case tar.TypeSymlink:
+			entry.target = header.Linkname
+		}
+		ret[name] = entry
+	}
+}
+
+// Diff compares two .deb files, old and new: their control files, field by
+// field, and the files of their data.tar, by path. This consumes the Data
+// of both.
+func Diff(old, new *Deb, opts DiffOptions) (*DiffReport, error) {
+	ret := DiffReport{}
+
+	for _, field := range old.Control.Order {
+		if value := new.Control.Get(field); !new.Control.Has(field) || value != old.Control.Get(field) {
+			ret.Control = append(ret.Control, FieldChange{
+				Field: field,
+				Old:   old.Control.Get(field),
+				New:   value,
+			})
+		}
+	}
+	for _, field := range new.Control.Order {
+		if !old.Control.Has(field) {
+			ret.Control = append(ret.Control, FieldChange{Field: field, New: new.Control.Get(field)})
+		}
+	}
+
+	oldFiles, err := diffEntries(old.Data, opts.Contents)
+	if err != nil {
+		return nil, err
+	}
+	newFiles, err := diffEntries(new.Data, opts.Contents)
+	if err != nil {
+		return nil, err
+	}
+
+	for name, before := range oldFiles {
+		after, found := newFiles[name]
+		if !found {
+			ret.Removed = append(ret.Removed, name)
+			continue
+		}
+		if before == after {
+			continue
+		}
+		ret.Changed = append(ret.Changed, FileChange{
+			Path:      name,
+			OldSize:   before.size,
+			NewSize:   after.size,
+			OldMode:   before.mode,
+			NewMode:   after.mode,
+			OldOwner:  before.owner,
+			NewOwner:  after.owner,
+			OldTarget: before.target,
+			NewTarget: after.target,
+			Contents:  before.hash != after.hash,
+		})
+	}
+	for name := range newFiles {
+		if _, found := oldFiles[name]; !found {
+			ret.Added = append(ret.Added, name)
+		}
+	}
+
+	sort.Strings(ret.Added)
+	sort.Strings(ret.Removed)
+	sort.Slice(ret.Changed, func(i, j int) bool {
+		return ret.Changed[i].Path < ret.Changed[j].Path
+	})
+	return &ret, nil
+}
+
+// }}}
+
+// vim: foldmethod=marker
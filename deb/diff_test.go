@@ -0,0 +1,112 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package deb_test
+
+import (
+	"archive/tar"
+	"strings"
+	"testing"
+
+	"github.com/ebikt/go-debian/control"
+	"github.com/ebikt/go-debian/deb"
+)
+
+/*
+ *
+ */
+
+func diffDeb(t *testing.T, controlFile string, files ...tarMember) *deb.Deb {
+	ret := deb.Deb{Data: members(t, files...)}
+	if err := control.Unmarshal(&ret.Control, strings.NewReader(controlFile)); err != nil {
+		t.Fatal(err)
+	}
+	return &ret
+}
+
+func TestDiff(t *testing.T) {
+	diff := func(opts deb.DiffOptions) *deb.DiffReport {
+		old := diffDeb(t, "Package: hello\nVersion: 1.0-1\nArchitecture: amd64\nRecommends: bash\n",
+			tarMember{header: tar.Header{Name: "./", Typeflag: tar.TypeDir, Mode: 0755}},
+			tarMember{header: tar.Header{Name: "./usr/bin/hello", Mode: 0755}, data: "#!/bin/sh\n"},
+			tarMember{header: tar.Header{Name: "./usr/bin/hi", Typeflag: tar.TypeSymlink, Linkname: "hello", Mode: 0777}},
+			tarMember{header: tar.Header{Name: "./etc/hello.conf", Mode: 0644}, data: "a=1\n"},
+			tarMember{header: tar.Header{Name: "./usr/share/doc/hello/README", Mode: 0644}, data: "Hi\n"},
+		)
+		new := diffDeb(t, "Package: hello\nVersion: 1.0-2\nArchitecture: amd64\nSuggests: zsh\n",
+			tarMember{header: tar.Header{Name: "./", Typeflag: tar.TypeDir, Mode: 0755}},
+			tarMember{header: tar.Header{Name: "./usr/bin/hello", Mode: 0644}, data: "#!/bin/bash\n"},
+			tarMember{header: tar.Header{Name: "./usr/bin/hi", Typeflag: tar.TypeSymlink, Linkname: "/usr/bin/hello", Mode: 0777}},
+			tarMember{header: tar.Header{Name: "./etc/hello.conf", Mode: 0644}, data: "a=2\n"},
+			tarMember{header: tar.Header{Name: "./usr/share/doc/hello/NEWS", Mode: 0644}, data: "New\n"},
+		)
+		report, err := deb.Diff(old, new, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return report
+	}
+
+	report := diff(deb.DiffOptions{})
+	if report.Empty() {
+		t.Fatal("Expected differences")
+	}
+	expected := "Control:\n" +
+		"  Version: \"1.0-1\" -> \"1.0-2\"\n" +
+		"  Recommends: \"bash\" -> \"\"\n" +
+		"  Suggests: \"\" -> \"zsh\"\n" +
+		"Added:\n" +
+		"  usr/share/doc/hello/NEWS\n" +
+		"Removed:\n" +
+		"  usr/share/doc/hello/README\n" +
+		"Changed:\n" +
+		"  usr/bin/hello: size 10 -> 12, mode -rwxr-xr-x -> -rw-r--r--\n" +
+		"  usr/bin/hi: target hello -> /usr/bin/hello\n"
+	if report.String() != expected {
+		t.Fatalf("Unexpected report:\n%s", report)
+	}
+
+	/* Same size, different contents */
+	report = diff(deb.DiffOptions{Contents: true})
+	if len(report.Changed) != 3 || report.Changed[0].Path != "etc/hello.conf" ||
+		!report.Changed[0].Contents || !report.Changed[1].Contents {
+		t.Fatalf("Unexpected changes: %v", report.Changed)
+	}
+}
+
+func TestDiffSame(t *testing.T) {
+	files := func() []tarMember {
+		return []tarMember{
+			{header: tar.Header{Name: "./usr/bin/hello", Mode: 0755, Uname: "root", Gname: "root"}, data: "#!/bin/sh\n"},
+			{header: tar.Header{Name: "./usr/bin/hi", Typeflag: tar.TypeLink, Linkname: "./usr/bin/hello", Mode: 0755}},
+		}
+	}
+	old := diffDeb(t, "Package: hello\nVersion: 1.0-1\nArchitecture: amd64\n", files()...)
+	new := diffDeb(t, "Package: hello\nVersion: 1.0-1\nArchitecture: amd64\n", files()...)
+	report, err := deb.Diff(old, new, deb.DiffOptions{Contents: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Empty() || report.String() != "" {
+		t.Fatalf("Unexpected report:\n%s", report)
+	}
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,384 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package deb // import "github.com/ebikt/go-debian/deb"
+
+import (
+	"archive/tar"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ebikt/go-debian/hashio"
+)
+
+// Extraction {{{
+
+// ExtractPolicy tells how the files of a .deb are written to the disk.
+// The zero value is the safe default: files are owned by whoever
+// extracts them, their permissions go through the umask, setuid and
+// setgid bits are dropped, and device nodes, fifos and absolute symlinks
+// are refused.
+type ExtractPolicy struct {
+	// Restore the owners of the files, which takes being root.
+	PreserveOwners bool
+
+	// Set the permissions of the files as they are in the archive, rather
+	// than letting the umask restrict them.
+	IgnoreUmask bool
+
+	// Keep the setuid, setgid and sticky bits.
+	PreserveSpecialBits bool
+
+	// Skip device nodes and fifos, rather than failing on them. They are
+	// never created.
+	SkipSpecialFiles bool
+
+	// Allow symlinks to absolute paths, such as "/etc/alternatives/foo",
+	// which point outside of the extraction directory when followed.
+	AllowAbsoluteSymlinks bool
+
+	// If set, told about each regular file written, by its name in the
+	// archive.
+	Progress hashio.Progress
+}
+
+// extractPath returns where the archive member goes under dir, refusing
+// the names escaping it, and the ones going through a symlink, which
+// could have been planted by an earlier member to write anywhere.
+func extractPath(dir, name string) (string, error) {
+	for _, component := range strings.Split(name, "/") {
+		if component == ".." {
+			return "", fmt.Errorf("Refusing to extract '%s', which is outside of the package", name)
+		}
+	}
+	clean := strings.TrimPrefix(path.Clean("/"+name), "/")
+	if clean == "" {
+		return dir, nil
+	}
+
+	current := dir
+	components := strings.Split(clean, "/")
+	for _, component := range components[:len(components)-1] {
+		current = filepath.Join(current, component)
+		info, err := os.Lstat(current)
+		if os.IsNotExist(err) {
+			break
+		} else if err != nil {
+			return "", err
+		}
+		if !info.IsDir() {
+			return "", fmt.Errorf("Refusing to extract '%s' through '%s', which is not a directory", name, current)
+		}
+	}
+	return filepath.Join(dir, filepath.FromSlash(clean)), nil
+}
+
+// resolveSymlink returns the components of the path the target points to,
+// relative to dir, from the directory of components `parts`, following
+// the symlinks already under dir. It tells whether the path gets out of
+// dir, or goes through an absolute symlink, which it doesn't follow.
+//
+// A ".." of the target may only follow components which are directories
+// already: a later member could make any other one a symlink, which the
+// ".." would then go up from.
+func resolveSymlink(dir string, parts []string, target string, hops int) ([]string, bool, bool, error) {
+	parts = append([]string{}, parts...)
+	base := len(parts)
+	for _, component := range strings.Split(target, "/") {
+		switch component {
+		case "", ".":
+			continue
+		case "..":
+			if len(parts) == 0 {
+				return nil, true, false, nil
+			}
+			if len(parts) > base {
+				current := filepath.Join(dir, filepath.Join(parts...))
+				if info, err := os.Lstat(current); err != nil || !info.IsDir() {
+					return nil, false, false, fmt.Errorf("Refusing to resolve '..' after '%s', which isn't a directory yet", path.Join(parts...))
+				}
+			}
+			parts = parts[:len(parts)-1]
+			if base > len(parts) {
+				base = len(parts)
+			}
+			continue
+		}
+		parts = append(parts, component)
+		current := filepath.Join(dir, filepath.Join(parts...))
+		info, err := os.Lstat(current)
+		if err != nil || info.Mode()&os.ModeSymlink == 0 {
+			continue
+		}
+		if hops >= maxSymlinks {
+			return nil, false, false, fmt.Errorf("Too many levels of symlinks resolving '%s'", current)
+		}
+		link, err := os.Readlink(current)
+		if err != nil {
+			return nil, false, false, err
+		}
+		if path.IsAbs(link) {
+			return nil, false, true, nil
+		}
+		var escapes, absolute bool
+		parts, escapes, absolute, err = resolveSymlink(dir, parts[:len(parts)-1], link, hops+1)
+		if escapes || absolute || err != nil {
+			return nil, escapes, absolute, err
+		}
+	}
+	return parts, false, false, nil
+}
+
+// checkSymlink refuses the targets of symlinks pointing outside of the
+// package, resolving them through the symlinks already extracted under
+// dir, so that a chain of symlinks doesn't get out either.
+func (p ExtractPolicy) checkSymlink(dir, name, target string) error {
+	if path.IsAbs(target) {
+		if p.AllowAbsoluteSymlinks {
+			return nil
+		}
+		return fmt.Errorf("Refusing symlink '%s' to the absolute path '%s'", name, target)
+	}
+	parent := []string{}
+	if clean := path.Dir(strings.TrimPrefix(path.Clean("/"+name), "/")); clean != "." {
+		parent = strings.Split(clean, "/")
+	}
+	_, escapes, absolute, err := resolveSymlink(dir, parent, target, 0)
+	if err != nil {
+		return err
+	}
+	if escapes {
+		return fmt.Errorf("Refusing symlink '%s' to '%s', which is outside of the package", name, target)
+	}
+	if absolute && !p.AllowAbsoluteSymlinks {
+		return fmt.Errorf("Refusing symlink '%s' to '%s', which goes through an absolute symlink", name, target)
+	}
+	return nil
+}
+
+// mode returns the permissions of a member.
+func (p ExtractPolicy) mode(header *tar.Header) os.FileMode {
+	mode := os.FileMode(header.Mode) & os.ModePerm
+	if p.PreserveSpecialBits {
+		if header.Mode&04000 != 0 {
+			mode |= os.ModeSetuid
+		}
+		if header.Mode&02000 != 0 {
+			mode |= os.ModeSetgid
+		}
+		if header.Mode&01000 != 0 {
+			mode |= os.ModeSticky
+		}
+	}
+	return mode
+}
+
+// finish sets the owner and, unless the umask is honored, the permissions
+// of a file or a directory just created.
+func (p ExtractPolicy) finish(dest string, header *tar.Header) error {
+	if p.PreserveOwners {
+		if err := os.Lchown(dest, header.Uid, header.Gid); err != nil {
+			return err
+		}
+	}
+	/* Chown clears the setuid bits, so they're set afterwards */
+	if p.IgnoreUmask || p.PreserveSpecialBits {
+		return os.Chmod(dest, p.mode(header))
+	}
+	return nil
+}
+
+// removeExisting removes what is in the way of a new member, so as not to
+// write through a symlink. Directories are kept.
+func removeExisting(dest string) error {
+	info, err := os.Lstat(dest)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return fmt.Errorf("Refusing to replace the directory '%s'", dest)
+	}
+	return os.Remove(dest)
+}
+
+// ExtractTar writes the files of the tar archive under dir, following the
+// policy, or the default one if nil. Nothing is written outside of dir:
+// names with ".." components, hard links to files outside of the archive,
+// and members going through symlinks are refused.
+func ExtractTar(data *tar.Reader, dir string, policy *ExtractPolicy) error {
+	return ExtractTarContext(context.Background(), data, dir, policy)
+}
+
+// ExtractTarContext is ExtractTar, stopping once the context is done,
+// even in the middle of writing a file.
+func ExtractTarContext(ctx context.Context, data *tar.Reader, dir string, policy *ExtractPolicy) error {
+	if policy == nil {
+		policy = &ExtractPolicy{}
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	type dirTime struct {
+		path    string
+		modTime time.Time
+	}
+	dirs := []dirTime{}
+	extracted := map[string]bool{}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		header, err := data.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		dest, err := extractPath(dir, header.Name)
+		if err != nil {
+			return err
+		}
+		name := strings.TrimPrefix(path.Clean("/"+header.Name), "/")
+		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			return err
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if name == "" {
+				continue
+			}
+			if info, err := os.Lstat(dest); err == nil && !info.IsDir() {
+				return fmt.Errorf("Refusing to replace '%s' with a directory", dest)
+			}
+			if err := os.MkdirAll(dest, policy.mode(header)); err != nil {
+				return err
+			}
+			if err := policy.finish(dest, header); err != nil {
+				return err
+			}
+			dirs = append(dirs, dirTime{dest, header.ModTime})
+			continue
+		case tar.TypeReg:
+			if err := removeExisting(dest); err != nil {
+				return err
+			}
+			out, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_EXCL, policy.mode(header))
+			if err != nil {
+				return err
+			}
+			if policy.Progress != nil {
+				policy.Progress.Start(header.Name, header.Size)
+			}
+			_, err = io.Copy(out, hashio.NewContextReader(ctx, hashio.NewProgressReader(data, policy.Progress, header.Name)))
+			if closeErr := out.Close(); err == nil {
+				err = closeErr
+			}
+			if policy.Progress != nil {
+				policy.Progress.Done(header.Name, err)
+			}
+			if err != nil {
+				return err
+			}
+			if err := policy.finish(dest, header); err != nil {
+				return err
+			}
+			if err := os.Chtimes(dest, header.ModTime, header.ModTime); err != nil {
+				return err
+			}
+		case tar.TypeLink:
+			target := strings.TrimPrefix(path.Clean("/"+header.Linkname), "/")
+			if !extracted[target] {
+				return fmt.Errorf("Refusing hard link '%s' to '%s', which is not a file of the package",
+					header.Name, header.Linkname)
+			}
+			source, err := extractPath(dir, target)
+			if err != nil {
+				return err
+			}
+			if err := removeExisting(dest); err != nil {
+				return err
+			}
+			if err := os.Link(source, dest); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			if err := policy.checkSymlink(dir, name, header.Linkname); err != nil {
+				return err
+			}
+			if err := removeExisting(dest); err != nil {
+				return err
+			}
+			if err := os.Symlink(header.Linkname, dest); err != nil {
+				return err
+			}
+			if policy.PreserveOwners {
+				if err := os.Lchown(dest, header.Uid, header.Gid); err != nil {
+					return err
+				}
+			}
+			continue
+		case tar.TypeChar, tar.TypeBlock, tar.TypeFifo:
+			if policy.SkipSpecialFiles {
+				continue
+			}
+			return fmt.Errorf("Refusing to extract the special file '%s'", header.Name)
+		default:
+			return fmt.Errorf("Unsupported type of '%s': %c", header.Name, header.Typeflag)
+		}
+
+		extracted[name] = true
+	}
+
+	/* The times of the directories change as their files are written */
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := os.Chtimes(dirs[i].path, dirs[i].modTime, dirs[i].modTime); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Extract writes the files of the .deb under dir, as dpkg --extract does,
+// following the policy, or the default one if nil; see ExtractTar. This
+// consumes the Data of the Deb.
+func (deb *Deb) Extract(dir string, policy *ExtractPolicy) error {
+	return ExtractTar(deb.Data, dir, policy)
+}
+
+// ExtractContext is Extract, stopping once the context is done, see
+// ExtractTarContext.
+func (deb *Deb) ExtractContext(ctx context.Context, dir string, policy *ExtractPolicy) error {
+	return ExtractTarContext(ctx, deb.Data, dir, policy)
+}
+
+// }}}
+
+// vim: foldmethod=marker
@@ -0,0 +1,189 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package deb_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ebikt/go-debian/deb"
+)
+
+/*
+ *
+ */
+
+// tarMember is a member of a tarball made by members.
+type tarMember struct {
+	header tar.Header
+	data   string
+}
+
+func members(t *testing.T, entries ...tarMember) *tar.Reader {
+	out := bytes.Buffer{}
+	w := tar.NewWriter(&out)
+	for _, entry := range entries {
+		header := entry.header
+		header.Size = int64(len(entry.data))
+		if header.Typeflag == 0 {
+			header.Typeflag = tar.TypeReg
+		}
+		if err := w.WriteHeader(&header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(entry.data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return tar.NewReader(&out)
+}
+
+func TestExtractTar(t *testing.T) {
+	dir := t.TempDir()
+	modTime := time.Date(2023, 6, 10, 12, 0, 0, 0, time.UTC)
+	progress := recordedProgress{}
+	err := deb.ExtractTar(members(t,
+		tarMember{header: tar.Header{Name: "./", Typeflag: tar.TypeDir, Mode: 0755}},
+		tarMember{header: tar.Header{Name: "./usr/bin/", Typeflag: tar.TypeDir, Mode: 0755, ModTime: modTime}},
+		tarMember{header: tar.Header{Name: "./usr/bin/hello", Mode: 04755, ModTime: modTime}, data: "hello"},
+		tarMember{header: tar.Header{Name: "./usr/bin/hi", Typeflag: tar.TypeLink, Linkname: "./usr/bin/hello"}},
+		tarMember{header: tar.Header{Name: "./usr/bin/hey", Typeflag: tar.TypeSymlink, Linkname: "hello"}},
+		tarMember{header: tar.Header{Name: "./usr/share/doc/hello/link", Typeflag: tar.TypeSymlink,
+			Linkname: "../../../bin/hello"}},
+		tarMember{header: tar.Header{Name: "./dev/null", Typeflag: tar.TypeChar, Mode: 0666}},
+	), dir, &deb.ExtractPolicy{IgnoreUmask: true, SkipSpecialFiles: true, Progress: &progress})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if events := strings.Join(progress.events, ", "); events !=
+		"start ./usr/bin/hello 5, advance ./usr/bin/hello 5, done ./usr/bin/hello <nil>" {
+		t.Fatalf("Unexpected progress %s", events)
+	}
+
+	info, err := os.Stat(filepath.Join(dir, "usr/bin/hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode() != 0755 || !info.ModTime().Equal(modTime) {
+		t.Fatalf("Unexpected mode %s or time %s", info.Mode(), info.ModTime())
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "usr/bin/hi")); err != nil || string(data) != "hello" {
+		t.Fatalf("Unexpected hard link %q (%v)", data, err)
+	}
+	if target, err := os.Readlink(filepath.Join(dir, "usr/bin/hey")); err != nil || target != "hello" {
+		t.Fatalf("Unexpected symlink %q (%v)", target, err)
+	}
+	if info, err := os.Stat(filepath.Join(dir, "usr/bin")); err != nil || !info.ModTime().Equal(modTime) {
+		t.Fatalf("Unexpected directory time (%v)", err)
+	}
+	if _, err := os.Lstat(filepath.Join(dir, "dev/null")); !os.IsNotExist(err) {
+		t.Fatal("Device node created")
+	}
+}
+
+func TestExtractTarCancelled(t *testing.T) {
+	dir := t.TempDir()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := deb.ExtractTarContext(ctx, members(t,
+		tarMember{header: tar.Header{Name: "./hello", Mode: 0644}, data: "hello"},
+	), dir, nil)
+	if err != context.Canceled {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if _, err := os.Lstat(filepath.Join(dir, "hello")); !os.IsNotExist(err) {
+		t.Fatal("File extracted after cancellation")
+	}
+}
+
+func TestExtractTarUnsafe(t *testing.T) {
+	for name, entries := range map[string][]tarMember{
+		"traversal": {
+			{header: tar.Header{Name: "./usr/../../etc/passwd"}, data: "root"},
+		},
+		"absolute symlink": {
+			{header: tar.Header{Name: "./etc/passwd", Typeflag: tar.TypeSymlink, Linkname: "/etc/passwd"}},
+		},
+		"escaping symlink": {
+			{header: tar.Header{Name: "./usr/link", Typeflag: tar.TypeSymlink, Linkname: "../../etc"}},
+		},
+		"through symlink": {
+			{header: tar.Header{Name: "./usr/link", Typeflag: tar.TypeSymlink, Linkname: "."}},
+			{header: tar.Header{Name: "./usr/link/passwd"}, data: "root"},
+		},
+		"chained symlinks": {
+			{header: tar.Header{Name: "./usr/l1", Typeflag: tar.TypeSymlink, Linkname: ".."}},
+			{header: tar.Header{Name: "./usr/l2", Typeflag: tar.TypeSymlink, Linkname: "l1/.."}},
+		},
+		"redirected symlink": {
+			{header: tar.Header{Name: "./a", Typeflag: tar.TypeSymlink, Linkname: "b/../etc"}},
+			{header: tar.Header{Name: "./b", Typeflag: tar.TypeSymlink, Linkname: "."}},
+		},
+		"symlink loop": {
+			{header: tar.Header{Name: "./usr/l1", Typeflag: tar.TypeSymlink, Linkname: "l2"}},
+			{header: tar.Header{Name: "./usr/l2", Typeflag: tar.TypeSymlink, Linkname: "l1"}},
+			{header: tar.Header{Name: "./usr/l3", Typeflag: tar.TypeSymlink, Linkname: "l1/x"}},
+		},
+		"hard link escape": {
+			{header: tar.Header{Name: "./usr/passwd", Typeflag: tar.TypeLink, Linkname: "/etc/passwd"}},
+		},
+		"device": {
+			{header: tar.Header{Name: "./dev/sda", Typeflag: tar.TypeBlock, Mode: 0660}},
+		},
+	} {
+		dir := t.TempDir()
+		if err := deb.ExtractTar(members(t, entries...), dir, nil); err == nil {
+			t.Fatalf("Unsafe %s extracted", name)
+		}
+	}
+
+	/* Absolute symlinks are allowed on request */
+	dir := t.TempDir()
+	err := deb.ExtractTar(members(t, tarMember{
+		header: tar.Header{Name: "./usr/bin/editor", Typeflag: tar.TypeSymlink, Linkname: "/etc/alternatives/editor"},
+	}), dir, &deb.ExtractPolicy{AllowAbsoluteSymlinks: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	/* Symlinks going through the ones already extracted are resolved */
+	dir = t.TempDir()
+	err = deb.ExtractTar(members(t,
+		tarMember{header: tar.Header{Name: "./usr/lib/libfoo.so.1", Typeflag: tar.TypeSymlink, Linkname: "libfoo.so.1.0"}},
+		tarMember{header: tar.Header{Name: "./usr/lib/current", Typeflag: tar.TypeSymlink, Linkname: "."}},
+		tarMember{header: tar.Header{Name: "./usr/lib/libfoo.so", Typeflag: tar.TypeSymlink, Linkname: "current/libfoo.so.1"}},
+		tarMember{header: tar.Header{Name: "./usr/lib/up", Typeflag: tar.TypeSymlink, Linkname: "current/../../share"}},
+	), dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,263 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package deb // import "github.com/ebikt/go-debian/deb"
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Snippets {{{
+
+// A Snippet is a fragment of a maintainer script, as debhelper adds in
+// place of the #DEBHELPER# token, see MaintainerScript.
+type Snippet struct {
+	// The maintainer script, such as "postinst".
+	Script string
+
+	// The tool the snippet mimics, such as "dh_installsystemd", named in
+	// the comment preceding it.
+	Tool string
+
+	// The shell code, ending with a newline.
+	Text string
+}
+
+// The token of the maintainer scripts which the snippets replace.
+const debhelperToken = "#DEBHELPER#"
+
+// The script a package gets when it has snippets but no script of its own.
+const defaultScript = "#!/bin/sh\nset -e\n\n" + debhelperToken + "\n\nexit 0\n"
+
+// MaintainerScript returns the maintainer script `name`, such as
+// "postinst", with the snippets of that script in place of its
+// #DEBHELPER# token, as dh_installdeb does: in order for the preinst and
+// postinst, and in reverse order for the prerm and postrm, so that
+// things are undone in the opposite order they're done in. A script is
+// made up if `script` is nil, and the token is dropped if no snippet is
+// for it.
+func MaintainerScript(name string, script []byte, snippets []Snippet) []byte {
+	if script == nil {
+		script = []byte(defaultScript)
+	}
+	text := []string{}
+	for _, snippet := range snippets {
+		if snippet.Script != name {
+			continue
+		}
+		text = append(text, fmt.Sprintf(
+			"# Automatically added by %s\n%s# End automatically added section\n",
+			snippet.Tool, snippet.Text,
+		))
+	}
+	if name == "prerm" || name == "postrm" {
+		for i, j := 0, len(text)-1; i < j; i, j = i+1, j-1 {
+			text[i], text[j] = text[j], text[i]
+		}
+	}
+	replacement := strings.TrimSuffix(strings.Join(text, ""), "\n")
+	return bytes.Replace(script, []byte(debhelperToken), []byte(replacement), -1)
+}
+
+/* Quote a word for the shell, as debhelper does */
+func shellQuote(word string) string {
+	return "'" + strings.Replace(word, "'", `'\''`, -1) + "'"
+}
+
+// SystemdOptions tell SystemdSnippets what to do with the units, like the
+// options of dh_installsystemd.
+type SystemdOptions struct {
+	// Don't enable the units, --no-enable.
+	NoEnable bool
+
+	// Don't start the units on installation nor restart them on upgrade,
+	// --no-start.
+	NoStart bool
+
+	// Don't stop the units before upgrades, only restarting them after,
+	// --restart-after-upgrade, which is the default of compat 10 onward;
+	// they're stopped in the prerm otherwise.
+	StopBeforeUpgrade bool
+}
+
+// SystemdSnippets returns the snippets dh_installsystemd adds for the
+// systemd units of a package, such as "hello.service": enabling them and
+// (re)starting them in the postinst, stopping them in the prerm, and
+// cleaning up after them in the postrm.
+func SystemdSnippets(units []string, opts SystemdOptions) []Snippet {
+	if len(units) == 0 {
+		return nil
+	}
+	quoted := []string{}
+	for _, unit := range units {
+		quoted = append(quoted, shellQuote(unit))
+	}
+	list := strings.Join(quoted, " ")
+	ret := []Snippet{}
+	add := func(script, text string) {
+		ret = append(ret, Snippet{Script: script, Tool: "dh_installsystemd", Text: text})
+	}
+
+	if !opts.NoEnable {
+		add("postinst", `if [ "$1" = "configure" ] || [ "$1" = "abort-upgrade" ] || [ "$1" = "abort-deconfigure" ] || [ "$1" = "abort-remove" ] ; then
+	for unit in `+list+`; do
+		# This will only remove masks created by d-s-h on package removal.
+		deb-systemd-helper unmask "$unit" >/dev/null || true
+
+		# was-enabled defaults to true, so new installations run enable.
+		if deb-systemd-helper --quiet was-enabled "$unit"; then
+			# Enables the unit on first installation, creates new
+			# symlinks on upgrades if the unit file has changed.
+			deb-systemd-helper enable "$unit" >/dev/null || true
+		else
+			# Update the statefile to add new symlinks (if any), which need to be
+			# cleaned up on purge. Also remove old symlinks.
+			deb-systemd-helper update-state "$unit" >/dev/null || true
+		fi
+	done
+fi
+`)
+	}
+	if !opts.NoStart {
+		add("postinst", `if [ "$1" = "configure" ] || [ "$1" = "abort-upgrade" ] || [ "$1" = "abort-deconfigure" ] || [ "$1" = "abort-remove" ] ; then
+	if [ -z "${DPKG_ROOT:-}" ] && [ -d /run/systemd/system ]; then
+		systemctl --system daemon-reload >/dev/null || true
+		if [ -n "$2" ]; then
+			_dh_action=restart
+		else
+			_dh_action=start
+		fi
+		deb-systemd-invoke $_dh_action `+list+` >/dev/null || true
+	fi
+fi
+`)
+		condition := `[ "$1" = remove ]`
+		if opts.StopBeforeUpgrade {
+			condition = `[ "$1" = remove ] || [ "$1" = upgrade ]`
+		}
+		add("prerm", `if [ -z "${DPKG_ROOT:-}" ] && { `+condition+`; } && [ -d /run/systemd/system ] ; then
+	deb-systemd-invoke stop `+list+` >/dev/null || true
+fi
+`)
+	}
+	add("postrm", `if [ "$1" = remove ] && [ -d /run/systemd/system ] ; then
+	systemctl --system daemon-reload >/dev/null || true
+fi
+`)
+	if !opts.NoEnable {
+		add("postrm", `if [ "$1" = "remove" ]; then
+	if [ -x "/usr/bin/deb-systemd-helper" ]; then
+		deb-systemd-helper mask `+list+` >/dev/null || true
+	fi
+fi
+
+if [ "$1" = "purge" ]; then
+	if [ -x "/usr/bin/deb-systemd-helper" ]; then
+		deb-systemd-helper purge `+list+` >/dev/null || true
+		deb-systemd-helper unmask `+list+` >/dev/null || true
+	fi
+fi
+`)
+	}
+	return ret
+}
+
+// A UcfFile is a configuration file managed with ucf: the file shipped by
+// the package, such as "/usr/share/hello/hello.conf", and the
+// configuration file made from it, such as "/etc/hello.conf".
+type UcfFile struct {
+	Source string
+	Dest   string
+}
+
+// UcfSnippets returns the snippets dh_ucf adds for the configuration files
+// of the package `pkg` managed with ucf: installing them in the postinst,
+// and removing them on purge in the postrm.
+func UcfSnippets(pkg string, files []UcfFile) []Snippet {
+	ret := []Snippet{}
+	for _, file := range files {
+		source, dest := shellQuote(file.Source), shellQuote(file.Dest)
+		ret = append(ret, Snippet{Script: "postinst", Tool: "dh_ucf", Text: `if [ "$1" = "configure" ]; then
+	ucf ` + source + ` ` + dest + `
+	ucfr ` + shellQuote(pkg) + ` ` + dest + `
+fi
+`})
+		ret = append(ret, Snippet{Script: "postrm", Tool: "dh_ucf", Text: `if [ "$1" = "purge" ]; then
+	for ext in .ucf-new .ucf-old .ucf-dist ""; do
+		rm -f ` + dest + `"$ext"
+	done
+
+	if [ -x "` + "`command -v ucf`" + `" ]; then
+		ucf --purge ` + dest + `
+	fi
+	if [ -x "` + "`command -v ucfr`" + `" ]; then
+		ucfr --purge ` + shellQuote(pkg) + ` ` + dest + `
+	fi
+fi
+`})
+	}
+	return ret
+}
+
+// }}}
+
+// Triggers file {{{
+
+// LdconfigTrigger is the trigger dh_makeshlibs adds to the packages
+// shipping public shared libraries, rather than running ldconfig in their
+// maintainer scripts.
+var LdconfigTrigger = Trigger{Directive: "activate-noawait", Name: "ldconfig"}
+
+// Directives of a triggers file, see deb-triggers(5).
+var triggerDirectives = map[string]bool{
+	"interest":         true,
+	"interest-await":   true,
+	"interest-noawait": true,
+	"activate":         true,
+	"activate-await":   true,
+	"activate-noawait": true,
+}
+
+// FormatTriggers returns the triggers file with the triggers, once each,
+// in order.
+func FormatTriggers(triggers []Trigger) ([]byte, error) {
+	out := bytes.Buffer{}
+	seen := map[Trigger]bool{}
+	for _, trigger := range triggers {
+		if !triggerDirectives[trigger.Directive] {
+			return nil, fmt.Errorf("Unknown trigger directive '%s'", trigger.Directive)
+		}
+		if trigger.Name == "" || strings.ContainsAny(trigger.Name, " \t\n") {
+			return nil, fmt.Errorf("Invalid trigger name '%s'", trigger.Name)
+		}
+		if seen[trigger] {
+			continue
+		}
+		seen[trigger] = true
+		out.WriteString(trigger.String() + "\n")
+	}
+	return out.Bytes(), nil
+}
+
+// }}}
+
+// vim: foldmethod=marker
@@ -0,0 +1,159 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package deb_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/ebikt/go-debian/deb"
+)
+
+/*
+ *
+ */
+
+func TestMaintainerScript(t *testing.T) {
+	snippets := []deb.Snippet{
+		{Script: "postinst", Tool: "dh_first", Text: "first\n"},
+		{Script: "postinst", Tool: "dh_second", Text: "second\n"},
+		{Script: "postrm", Tool: "dh_first", Text: "first\n"},
+		{Script: "postrm", Tool: "dh_second", Text: "second\n"},
+	}
+
+	postinst := deb.MaintainerScript("postinst", []byte("#!/bin/sh\nset -e\n#DEBHELPER#\necho done\n"), snippets)
+	expected := "#!/bin/sh\nset -e\n" +
+		"# Automatically added by dh_first\nfirst\n# End automatically added section\n" +
+		"# Automatically added by dh_second\nsecond\n# End automatically added section\n" +
+		"echo done\n"
+	if string(postinst) != expected {
+		t.Fatalf("Unexpected postinst:\n%s", postinst)
+	}
+
+	postrm := string(deb.MaintainerScript("postrm", nil, snippets))
+	if !strings.HasPrefix(postrm, "#!/bin/sh\nset -e\n") || !strings.HasSuffix(postrm, "exit 0\n") ||
+		strings.Index(postrm, "second") > strings.Index(postrm, "first") {
+		t.Fatalf("Unexpected postrm:\n%s", postrm)
+	}
+
+	prerm := string(deb.MaintainerScript("prerm", []byte("#!/bin/sh\n#DEBHELPER#\n"), snippets))
+	if prerm != "#!/bin/sh\n\n" {
+		t.Fatalf("Unexpected prerm:\n%s", prerm)
+	}
+}
+
+func TestSystemdSnippets(t *testing.T) {
+	scripts := map[string]string{}
+	for _, snippet := range deb.SystemdSnippets([]string{"hello.service", "hello.socket"}, deb.SystemdOptions{}) {
+		if snippet.Tool != "dh_installsystemd" {
+			t.Fatalf("Unexpected tool %s", snippet.Tool)
+		}
+		scripts[snippet.Script] += snippet.Text
+	}
+	for script, expected := range map[string]string{
+		"postinst": "deb-systemd-helper enable \"$unit\"",
+		"prerm":    "deb-systemd-invoke stop 'hello.service' 'hello.socket'",
+		"postrm":   "deb-systemd-helper purge 'hello.service' 'hello.socket'",
+	} {
+		if !strings.Contains(scripts[script], expected) {
+			t.Fatalf("Unexpected %s:\n%s", script, scripts[script])
+		}
+	}
+	if strings.Contains(scripts["prerm"], "upgrade") {
+		t.Fatal("Units are stopped before upgrades")
+	}
+
+	scripts = map[string]string{}
+	for _, snippet := range deb.SystemdSnippets([]string{"hello.service"}, deb.SystemdOptions{NoEnable: true, NoStart: true}) {
+		scripts[snippet.Script] += snippet.Text
+	}
+	if scripts["postinst"] != "" || scripts["prerm"] != "" || strings.Contains(scripts["postrm"], "purge") {
+		t.Fatalf("Unexpected scripts: %v", scripts)
+	}
+	if deb.SystemdSnippets(nil, deb.SystemdOptions{}) != nil {
+		t.Fatal("Snippets without units")
+	}
+}
+
+func TestUcfSnippets(t *testing.T) {
+	snippets := deb.UcfSnippets("hello", []deb.UcfFile{
+		{Source: "/usr/share/hello/hello.conf", Dest: "/etc/hello.conf"},
+	})
+	if len(snippets) != 2 || snippets[0].Script != "postinst" || snippets[1].Script != "postrm" {
+		t.Fatalf("Unexpected snippets: %v", snippets)
+	}
+	if !strings.Contains(snippets[0].Text, "ucf '/usr/share/hello/hello.conf' '/etc/hello.conf'\n\tucfr 'hello' '/etc/hello.conf'\n") {
+		t.Fatalf("Unexpected postinst:\n%s", snippets[0].Text)
+	}
+	if !strings.Contains(snippets[1].Text, "ucf --purge '/etc/hello.conf'") {
+		t.Fatalf("Unexpected postrm:\n%s", snippets[1].Text)
+	}
+}
+
+func TestFormatTriggers(t *testing.T) {
+	data, err := deb.FormatTriggers([]deb.Trigger{
+		deb.LdconfigTrigger,
+		{Directive: "interest-noawait", Name: "/usr/share/hello/plugins"},
+		deb.LdconfigTrigger,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "activate-noawait ldconfig\ninterest-noawait /usr/share/hello/plugins\n" {
+		t.Fatalf("Unexpected triggers file:\n%s", data)
+	}
+	for _, bad := range []deb.Trigger{{Directive: "activate-later", Name: "ldconfig"}, {Directive: "activate", Name: "two words"}} {
+		if _, err := deb.FormatTriggers([]deb.Trigger{bad}); err == nil {
+			t.Fatalf("Formatted %v", bad)
+		}
+	}
+}
+
+func TestBuilderSnippets(t *testing.T) {
+	b := helloBuilder(t)
+	b.Scripts["postinst"] = []byte("#!/bin/sh\nset -e\n#DEBHELPER#\n")
+	b.Snippets = deb.SystemdSnippets([]string{"hello.service"}, deb.SystemdOptions{})
+	b.Triggers = []deb.Trigger{{Directive: "interest-noawait", Name: "/usr/share/hello/plugins"}}
+	data := buildDeb(t, b)
+
+	debFile, err := deb.Load(bytes.NewReader(data), "hello_2.10-3_amd64.deb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	scripts := debFile.MaintainerScripts()
+	if len(scripts) != 3 || !strings.Contains(scripts["postinst"], "deb-systemd-helper enable") ||
+		!strings.HasPrefix(scripts["prerm"], "#!/bin/sh\nset -e\n") {
+		t.Fatalf("Unexpected scripts: %v", scripts)
+	}
+	if _, found := scripts["preinst"]; found {
+		t.Fatal("Unexpected preinst")
+	}
+	triggers, err := debFile.Triggers()
+	if err != nil || len(triggers) != 2 || triggers[1].Name != "/usr/share/hello/plugins" {
+		t.Fatalf("Unexpected triggers: %v (%v)", triggers, err)
+	}
+	if string(b.ControlFiles["triggers"]) != "activate-noawait ldconfig\n" {
+		t.Fatal("The ControlFiles of the Builder were changed")
+	}
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,217 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package deb // import "github.com/ebikt/go-debian/deb"
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"crypto/md5"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// MD5Sums {{{
+
+// An MD5Sum is a line of the DEBIAN/md5sums file of a .deb, which lists the
+// md5 checksum of each file the package ships.
+type MD5Sum struct {
+	// Path of the file, relative to the root, such as "usr/bin/hello".
+	Path string
+
+	// Hex encoded md5 checksum of its contents.
+	Hash string
+}
+
+// MD5Sums is the contents of a DEBIAN/md5sums file.
+type MD5Sums []MD5Sum
+
+// md5sumsPath turns the name of a data.tar entry into a path as listed in
+// an md5sums file.
+func md5sumsPath(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+name), "/")
+}
+
+// ComputeMD5Sums reads the whole data.tar, and returns the checksums of
+// its regular files, in the order of the archive, as dh_md5sums would.
+// Hard links are listed with the checksum of their target.
+func ComputeMD5Sums(data *tar.Reader) (MD5Sums, error) {
+	ret := MD5Sums{}
+	seen := map[string]string{}
+	for {
+		header, err := data.Next()
+		if err == io.EOF {
+			return ret, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		name := md5sumsPath(header.Name)
+		switch header.Typeflag {
+		case tar.TypeReg:
+			hash := md5.New()
+			if _, err := io.Copy(hash, data); err != nil {
+				return nil, err
+			}
+			seen[name] = fmt.Sprintf("%x", hash.Sum(nil))
+		case tar.TypeLink:
+			target, found := seen[md5sumsPath(header.Linkname)]
+			if !found {
+				return nil, fmt.Errorf("Hard link '%s' to unknown file '%s'", header.Name, header.Linkname)
+			}
+			seen[name] = target
+		default:
+			continue
+		}
+		ret = append(ret, MD5Sum{Path: name, Hash: seen[name]})
+	}
+}
+
+// ParseMD5Sums reads an md5sums file.
+func ParseMD5Sums(in io.Reader) (MD5Sums, error) {
+	ret := MD5Sums{}
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		els := strings.SplitN(line, " ", 2)
+		if len(els) != 2 || len(els[0]) != 32 {
+			return nil, fmt.Errorf("Malformed md5sums line: '%s'", line)
+		}
+		/* Two spaces, or a space and a '*' for binary mode */
+		name := strings.TrimPrefix(strings.TrimPrefix(els[1], " "), "*")
+		ret = append(ret, MD5Sum{Path: md5sumsPath(name), Hash: strings.ToLower(els[0])})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// Bytes formats the md5sums file, ready to be added to a control.tar.
+func (m MD5Sums) Bytes() []byte {
+	out := bytes.Buffer{}
+	for _, sum := range m {
+		fmt.Fprintf(&out, "%s  %s\n", sum.Hash, sum.Path)
+	}
+	return out.Bytes()
+}
+
+// MD5SumsReport lists the differences between an md5sums file and the
+// data.tar it's about, by path. An empty report means they agree.
+type MD5SumsReport struct {
+	// Files whose contents don't match their checksum.
+	Mismatched []string
+
+	// Files listed, but not in the data.tar.
+	Missing []string
+
+	// Regular files of the data.tar that aren't listed.
+	Unlisted []string
+}
+
+// OK returns whether the md5sums file and the data.tar agree.
+func (r MD5SumsReport) OK() bool {
+	return len(r.Mismatched) == 0 && len(r.Missing) == 0 && len(r.Unlisted) == 0
+}
+
+// Verify reads the whole data.tar, and checks its files against the
+// md5sums.
+func (m MD5Sums) Verify(data *tar.Reader) (*MD5SumsReport, error) {
+	actual, err := ComputeMD5Sums(data)
+	if err != nil {
+		return nil, err
+	}
+	listed := map[string]string{}
+	for _, sum := range m {
+		listed[sum.Path] = sum.Hash
+	}
+
+	ret := MD5SumsReport{}
+	for _, sum := range actual {
+		hash, found := listed[sum.Path]
+		if !found {
+			ret.Unlisted = append(ret.Unlisted, sum.Path)
+			continue
+		}
+		delete(listed, sum.Path)
+		if hash != sum.Hash {
+			ret.Mismatched = append(ret.Mismatched, sum.Path)
+		}
+	}
+	for _, sum := range m {
+		if _, found := listed[sum.Path]; found {
+			ret.Missing = append(ret.Missing, sum.Path)
+		}
+	}
+	return &ret, nil
+}
+
+// MD5Sums returns the md5sums file of the control member of the .deb, or
+// nil if it has none.
+func (deb *Deb) MD5Sums() (MD5Sums, error) {
+	data, found := deb.ControlFiles["md5sums"]
+	if !found {
+		return nil, nil
+	}
+	return ParseMD5Sums(bytes.NewReader(data))
+}
+
+// VerifyMD5Sums checks the files of the .deb against its md5sums file.
+// Its conffiles aren't reported as unlisted.
+// This consumes the Data of the Deb.
+func (deb *Deb) VerifyMD5Sums() (*MD5SumsReport, error) {
+	sums, err := deb.MD5Sums()
+	if err != nil {
+		return nil, err
+	}
+	if sums == nil {
+		return nil, fmt.Errorf("The .deb has no md5sums file")
+	}
+	report, err := sums.Verify(deb.Data)
+	if err != nil {
+		return nil, err
+	}
+	/* The conffiles are usually left out of the md5sums file */
+	conffiles := map[string]bool{}
+	for _, conffile := range deb.Conffiles() {
+		conffiles[md5sumsPath(conffile)] = true
+	}
+	unlisted := []string{}
+	for _, name := range report.Unlisted {
+		if !conffiles[name] {
+			unlisted = append(unlisted, name)
+		}
+	}
+	if len(unlisted) == 0 {
+		unlisted = nil
+	}
+	report.Unlisted = unlisted
+	return report, nil
+}
+
+// }}}
+
+// vim: foldmethod=marker
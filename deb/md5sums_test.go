@@ -0,0 +1,128 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package deb_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/ebikt/go-debian/deb"
+)
+
+/*
+ *
+ */
+
+func debWithMD5Sums(t *testing.T, md5sums string, files map[string]string) *deb.Deb {
+	control := tarball(t, map[string]string{
+		"./control": "Package: hello\nVersion: 2.10-3\nArchitecture: amd64\n",
+		"./md5sums": md5sums,
+	})
+	out := bytes.Buffer{}
+	w := deb.NewArWriter(&out)
+	for _, member := range []struct {
+		name string
+		data []byte
+	}{
+		{"debian-binary", []byte("2.0\n")},
+		{"control.tar", control},
+		{"data.tar", tarball(t, files)},
+	} {
+		if err := w.WriteEntry(member.name, int64(len(member.data)), bytes.NewReader(member.data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	debFile, err := deb.Load(&out, "hello_2.10-3_amd64.deb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return debFile
+}
+
+func TestComputeMD5Sums(t *testing.T) {
+	out := bytes.Buffer{}
+	w := tar.NewWriter(&out)
+	for _, header := range []tar.Header{
+		{Name: "./", Typeflag: tar.TypeDir},
+		{Name: "./usr/bin/hello", Typeflag: tar.TypeReg, Size: 5},
+		{Name: "./usr/bin/hi", Typeflag: tar.TypeLink, Linkname: "./usr/bin/hello"},
+		{Name: "./usr/bin/hey", Typeflag: tar.TypeSymlink, Linkname: "hello"},
+	} {
+		header := header
+		if err := w.WriteHeader(&header); err != nil {
+			t.Fatal(err)
+		}
+		if header.Size > 0 {
+			w.Write([]byte("hello"))
+		}
+	}
+	w.Close()
+
+	sums, err := deb.ComputeMD5Sums(tar.NewReader(&out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "5d41402abc4b2a76b9719d911017c592  usr/bin/hello\n" +
+		"5d41402abc4b2a76b9719d911017c592  usr/bin/hi\n"
+	if string(sums.Bytes()) != expected {
+		t.Fatalf("Unexpected md5sums %q", sums.Bytes())
+	}
+
+	parsed, err := deb.ParseMD5Sums(strings.NewReader(expected + "\n"))
+	if err != nil || len(parsed) != 2 || parsed[1] != sums[1] {
+		t.Fatalf("Unexpected parsed md5sums %v (%v)", parsed, err)
+	}
+	if _, err := deb.ParseMD5Sums(strings.NewReader("1234 usr/bin/hello\n")); err == nil {
+		t.Fatal("Malformed md5sums accepted")
+	}
+}
+
+func TestVerifyMD5Sums(t *testing.T) {
+	files := map[string]string{
+		"./usr/bin/hello":    "hello",
+		"./usr/share/doc/hi": "hi",
+	}
+	debFile := debWithMD5Sums(t, "5d41402abc4b2a76b9719d911017c592  usr/bin/hello\n"+
+		"49f68a5c8493ec2c0bf489821c21fc3b  usr/share/doc/hi\n", files)
+	report, err := debFile.VerifyMD5Sums()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.OK() {
+		t.Fatalf("Unexpected report %v", report)
+	}
+
+	debFile = debWithMD5Sums(t, "00000000000000000000000000000000  usr/bin/hello\n"+
+		"49f68a5c8493ec2c0bf489821c21fc3b  usr/share/doc/gone\n", files)
+	report, err = debFile.VerifyMD5Sums()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.OK() || len(report.Mismatched) != 1 || report.Mismatched[0] != "usr/bin/hello" ||
+		len(report.Missing) != 1 || report.Missing[0] != "usr/share/doc/gone" ||
+		len(report.Unlisted) != 1 || report.Unlisted[0] != "usr/share/doc/hi" {
+		t.Fatalf("Unexpected report %v", report)
+	}
+}
+
+// vim: foldmethod=marker
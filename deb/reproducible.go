@@ -0,0 +1,105 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package deb // import "github.com/ebikt/go-debian/deb"
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/ebikt/go-debian/hashio"
+)
+
+// Reproducible builds {{{
+
+// SourceDateEpoch returns the time SOURCE_DATE_EPOCH is set to, as read
+// with getenv (such as os.Getenv), or the zero time if it's not set. See
+// https://reproducible-builds.org/specs/source-date-epoch/.
+func SourceDateEpoch(getenv func(string) string) (time.Time, error) {
+	value := strings.TrimSpace(getenv("SOURCE_DATE_EPOCH"))
+	if value == "" {
+		return time.Time{}, nil
+	}
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || seconds < 0 {
+		return time.Time{}, fmt.Errorf("Invalid SOURCE_DATE_EPOCH: '%s'", value)
+	}
+	return time.Unix(seconds, 0).UTC(), nil
+}
+
+// UseSourceDateEpoch sets up the Builder the way dpkg-deb is for
+// reproducible builds, when SOURCE_DATE_EPOCH is set: the ModTime is set
+// to it, and clamped. The files being sorted and owned by root, and the
+// compression being the same everywhere, the .deb is then the same,
+// byte for byte, across runs and machines.
+func (b *Builder) UseSourceDateEpoch(getenv func(string) string) error {
+	epoch, err := SourceDateEpoch(getenv)
+	if err != nil || epoch.IsZero() {
+		return err
+	}
+	b.ModTime, b.ClampModTime = epoch, true
+	return nil
+}
+
+// reproducibleCompressor returns a compressor whose output only depends
+// on its input and level: gzip headers carry no name, timestamp or
+// operating system, and zstd compresses on a single goroutine.
+func reproducibleCompressor(name string, level int) (hashio.Compressor, error) {
+	switch name {
+	case "gz":
+		if level == 0 {
+			level = gzip.DefaultCompression
+		} else if level < gzip.BestSpeed || level > gzip.BestCompression {
+			return nil, fmt.Errorf("Invalid gzip level: %d", level)
+		}
+		return func(out io.Writer) (io.WriteCloser, error) {
+			writer, err := gzip.NewWriterLevel(out, level)
+			if err != nil {
+				return nil, err
+			}
+			writer.Header = gzip.Header{OS: 255}
+			return writer, nil
+		}, nil
+	case "zst":
+		if level == 0 {
+			level = 3
+		}
+		if level < 1 || level > 22 {
+			return nil, fmt.Errorf("Invalid zstd level: %d", level)
+		}
+		return func(out io.Writer) (io.WriteCloser, error) {
+			return zstd.NewWriter(out,
+				zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)),
+				zstd.WithEncoderConcurrency(1),
+			)
+		}, nil
+	}
+	return hashio.GetCompressorLevel(name, level)
+}
+
+// }}}
+
+// vim: foldmethod=marker
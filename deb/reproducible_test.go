@@ -0,0 +1,127 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package deb_test
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ebikt/go-debian/deb"
+)
+
+/*
+ *
+ */
+
+func env(values map[string]string) func(string) string {
+	return func(key string) string { return values[key] }
+}
+
+func TestSourceDateEpoch(t *testing.T) {
+	epoch, err := deb.SourceDateEpoch(env(nil))
+	if err != nil || !epoch.IsZero() {
+		t.Fatalf("Unexpected epoch %s (%v)", epoch, err)
+	}
+	epoch, err = deb.SourceDateEpoch(env(map[string]string{"SOURCE_DATE_EPOCH": "1700000000\n"}))
+	if err != nil || epoch.Unix() != 1700000000 {
+		t.Fatalf("Unexpected epoch %s (%v)", epoch, err)
+	}
+	for _, value := range []string{"yesterday", "-1", "1.5"} {
+		if _, err := deb.SourceDateEpoch(env(map[string]string{"SOURCE_DATE_EPOCH": value})); err == nil {
+			t.Errorf("SOURCE_DATE_EPOCH=%s accepted", value)
+		}
+	}
+}
+
+func treeBuilder(t *testing.T, when time.Time, compression string) *deb.Builder {
+	root := t.TempDir()
+	for _, name := range []string{"usr/share/doc/hello/README", "usr/bin/hello"} {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, when, when); err != nil {
+			t.Fatal(err)
+		}
+	}
+	b := helloBuilder(t)
+	b.Files, b.Conffiles = nil, nil
+	b.Compression = compression
+	if err := b.AddTree(root); err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestReproducibleBuilds(t *testing.T) {
+	for _, compression := range []string{"gz", "xz", "zst", "none"} {
+		first := buildDeb(t, treeBuilder(t, time.Unix(1000000000, 0), compression))
+		second := buildDeb(t, treeBuilder(t, time.Now(), compression))
+		if !bytes.Equal(first, second) {
+			t.Errorf("%s: builds differ", compression)
+		}
+	}
+}
+
+func TestClampModTime(t *testing.T) {
+	epoch := map[string]string{"SOURCE_DATE_EPOCH": "1500000000"}
+	old := time.Unix(1000000000, 0)
+
+	b := treeBuilder(t, old, "gz")
+	b.Files[len(b.Files)-1].ModTime = time.Unix(1600000000, 0)
+	if err := b.UseSourceDateEpoch(env(epoch)); err != nil {
+		t.Fatal(err)
+	}
+	if !b.ClampModTime || b.ModTime.Unix() != 1500000000 {
+		t.Fatalf("Unexpected builder settings %s %v", b.ModTime, b.ClampModTime)
+	}
+
+	debFile, err := deb.Load(bytes.NewReader(buildDeb(t, b)), "hello_2.10-3_amd64.deb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	times := map[string]int64{}
+	for {
+		header, err := debFile.Data.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		times[header.Name] = header.ModTime.Unix()
+	}
+	if times["./usr/bin/hello"] != old.Unix() || times["./usr/share/doc/hello/README"] != 1500000000 || times["./"] != 1500000000 {
+		t.Fatalf("Unexpected timestamps %v", times)
+	}
+
+	if err := b.UseSourceDateEpoch(env(map[string]string{"SOURCE_DATE_EPOCH": "soon"})); err == nil {
+		t.Fatal("Invalid SOURCE_DATE_EPOCH accepted")
+	}
+}
+
+// vim: foldmethod=marker
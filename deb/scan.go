@@ -0,0 +1,248 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package deb // import "github.com/ebikt/go-debian/deb"
+
+import (
+	"archive/tar"
+	"bytes"
+	"debug/elf"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Scanner {{{
+
+// A File of the data.tar of a .deb, as handed to the Analyzers.
+type File struct {
+	Header *tar.Header
+
+	// Path of the file on the system, such as "/usr/bin/hello".
+	Path string
+
+	// Contents of regular files, read once and shared by all Analyzers.
+	// It's nil for other entries, and for files larger than the MaxSize
+	// of the Scanner.
+	Data []byte
+}
+
+// A Finding is something an Analyzer noticed about a File.
+type Finding struct {
+	Analyzer string
+	Path     string
+	Message  string
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%s: %s: %s", f.Analyzer, f.Path, f.Message)
+}
+
+// An Analyzer looks at each file of a .deb in turn, and reports what it
+// notices about it.
+type Analyzer interface {
+	Name() string
+	Analyze(file File) ([]Finding, error)
+}
+
+// Scanner runs Analyzers over the files of a .deb, in a single streaming
+// pass over its data.tar, so that it's only decompressed once whatever
+// the number of Analyzers.
+type Scanner struct {
+	Analyzers []Analyzer
+
+	// Files larger than this are only analyzed by their header. Defaults
+	// to 64 MiB when 0.
+	MaxSize int64
+}
+
+// NewScanner creates a Scanner with the given Analyzers, or with all the
+// built-in ones if none are given.
+func NewScanner(analyzers ...Analyzer) *Scanner {
+	if len(analyzers) == 0 {
+		analyzers = []Analyzer{
+			ELFAnalyzer{},
+			InterpreterAnalyzer{},
+			SetuidAnalyzer{},
+			EmbeddedLibraryAnalyzer{},
+		}
+	}
+	return &Scanner{Analyzers: analyzers}
+}
+
+// Scan reads the whole data.tar, and returns the Findings of all the
+// Analyzers, in the order of the files.
+func (s *Scanner) Scan(data *tar.Reader) ([]Finding, error) {
+	maxSize := s.MaxSize
+	if maxSize == 0 {
+		maxSize = 64 << 20
+	}
+
+	ret := []Finding{}
+	for {
+		header, err := data.Next()
+		if err == io.EOF {
+			return ret, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		file := File{Header: header, Path: "/" + strings.TrimPrefix(path.Clean("/"+header.Name), "/")}
+		if header.Typeflag == tar.TypeReg && header.Size <= maxSize {
+			if file.Data, err = ioutil.ReadAll(data); err != nil {
+				return nil, err
+			}
+		}
+		for _, analyzer := range s.Analyzers {
+			findings, err := analyzer.Analyze(file)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s: %s", analyzer.Name(), file.Path, err)
+			}
+			ret = append(ret, findings...)
+		}
+	}
+}
+
+// Scan runs the Analyzers over the files of the .deb, see Scanner.Scan.
+// This consumes the Data of the Deb.
+func (deb *Deb) Scan(analyzers ...Analyzer) ([]Finding, error) {
+	return NewScanner(analyzers...).Scan(deb.Data)
+}
+
+// }}}
+
+// Analyzers {{{
+
+// ELFAnalyzer reports the class and machine of ELF files, along with their
+// SONAME and the libraries they need.
+type ELFAnalyzer struct{}
+
+func (ELFAnalyzer) Name() string { return "elf" }
+
+func (a ELFAnalyzer) Analyze(file File) ([]Finding, error) {
+	if !bytes.HasPrefix(file.Data, []byte(elf.ELFMAG)) {
+		return nil, nil
+	}
+	binary, err := elf.NewFile(bytes.NewReader(file.Data))
+	if err != nil {
+		/* Not all that looks like ELF is, leave it to lintian */
+		return nil, nil
+	}
+	defer binary.Close()
+
+	message := fmt.Sprintf("%s %s %s", binary.Class, binary.Machine, binary.Type)
+	if sonames, err := binary.DynString(elf.DT_SONAME); err == nil && len(sonames) > 0 {
+		message += ", SONAME " + strings.Join(sonames, " ")
+	}
+	if needed, err := binary.ImportedLibraries(); err == nil && len(needed) > 0 {
+		message += ", NEEDED " + strings.Join(needed, " ")
+	}
+	return []Finding{{Analyzer: a.Name(), Path: file.Path, Message: message}}, nil
+}
+
+// InterpreterAnalyzer reports the interpreter of scripts, from their "#!"
+// line.
+type InterpreterAnalyzer struct{}
+
+func (InterpreterAnalyzer) Name() string { return "interpreter" }
+
+func (a InterpreterAnalyzer) Analyze(file File) ([]Finding, error) {
+	if !bytes.HasPrefix(file.Data, []byte("#!")) {
+		return nil, nil
+	}
+	line := file.Data[2:]
+	if i := bytes.IndexByte(line, '\n'); i != -1 {
+		line = line[:i]
+	}
+	interpreter := strings.TrimSpace(string(line))
+	if interpreter == "" {
+		return nil, nil
+	}
+	return []Finding{{Analyzer: a.Name(), Path: file.Path, Message: interpreter}}, nil
+}
+
+// SetuidAnalyzer reports setuid and setgid files, along with the user or
+// group they run as.
+type SetuidAnalyzer struct{}
+
+func (SetuidAnalyzer) Name() string { return "setuid" }
+
+func (a SetuidAnalyzer) Analyze(file File) ([]Finding, error) {
+	ret := []Finding{}
+	if file.Header.Typeflag != tar.TypeReg {
+		return ret, nil
+	}
+	if file.Header.Mode&04000 != 0 {
+		ret = append(ret, Finding{a.Name(), file.Path, "setuid " + owner(file.Header.Uname, file.Header.Uid)})
+	}
+	if file.Header.Mode&02000 != 0 {
+		ret = append(ret, Finding{a.Name(), file.Path, "setgid " + owner(file.Header.Gname, file.Header.Gid)})
+	}
+	return ret, nil
+}
+
+func owner(name string, id int) string {
+	if name != "" {
+		return name
+	}
+	return fmt.Sprint(id)
+}
+
+// An embeddedLibrary is recognized by the version string it compiles in.
+type embeddedLibrary struct {
+	name    string
+	files   string
+	version *regexp.Regexp
+}
+
+var embeddedLibraries = []embeddedLibrary{
+	{"zlib", "libz.so", regexp.MustCompile(`(?:de|in)flate ([0-9][0-9.]*) Copyright`)},
+	{"libpng", "libpng", regexp.MustCompile(`libpng version ([0-9][0-9.]*)`)},
+	{"openssl", "libcrypto.so", regexp.MustCompile(`OpenSSL ([0-9]+\.[0-9]+\.[0-9]+[a-z]?) `)},
+}
+
+// EmbeddedLibraryAnalyzer reports files carrying their own copy of a
+// library, such as zlib, rather than using the one of the system.
+type EmbeddedLibraryAnalyzer struct{}
+
+func (EmbeddedLibraryAnalyzer) Name() string { return "embedded-library" }
+
+func (a EmbeddedLibraryAnalyzer) Analyze(file File) ([]Finding, error) {
+	ret := []Finding{}
+	if !bytes.HasPrefix(file.Data, []byte(elf.ELFMAG)) {
+		return ret, nil
+	}
+	for _, library := range embeddedLibraries {
+		if strings.HasPrefix(path.Base(file.Path), library.files) {
+			continue
+		}
+		if match := library.version.FindSubmatch(file.Data); match != nil {
+			ret = append(ret, Finding{a.Name(), file.Path, fmt.Sprintf("%s %s", library.name, match[1])})
+		}
+	}
+	return ret, nil
+}
+
+// }}}
+
+// vim: foldmethod=marker
@@ -0,0 +1,93 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package deb_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/ebikt/go-debian/deb"
+)
+
+/*
+ *
+ */
+
+func TestScan(t *testing.T) {
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	binary, err := os.ReadFile(exe)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(binary, []byte("\x7fELF")) {
+		t.Skip("The test binary is not an ELF file")
+	}
+
+	out := bytes.Buffer{}
+	w := tar.NewWriter(&out)
+	for _, file := range []struct {
+		header tar.Header
+		data   string
+	}{
+		{tar.Header{Name: "./usr/", Typeflag: tar.TypeDir, Mode: 0755}, ""},
+		{tar.Header{Name: "./usr/bin/hello", Mode: 0755}, string(binary) + "deflate 1.2.13 Copyright 1995-2022 Jean-loup Gailly"},
+		{tar.Header{Name: "./usr/bin/hello-py", Mode: 0755}, "#!/usr/bin/python3 -u\nprint('hello')\n"},
+		{tar.Header{Name: "./usr/bin/hello-su", Mode: 06755, Uname: "root", Gname: "shadow"}, "#!/bin/sh\n"},
+		{tar.Header{Name: "./usr/lib/libz.so.1", Mode: 0644}, "\x7fELF deflate 1.2.13 Copyright"},
+	} {
+		file.header.Size = int64(len(file.data))
+		if err := w.WriteHeader(&file.header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(file.data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	findings, err := deb.NewScanner().Scan(tar.NewReader(&out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := []string{}
+	for _, finding := range findings {
+		got = append(got, finding.String())
+	}
+	if len(got) != 6 ||
+		!strings.HasPrefix(got[0], "elf: /usr/bin/hello: ELFCLASS64 ") ||
+		got[1] != "embedded-library: /usr/bin/hello: zlib 1.2.13" ||
+		got[2] != "interpreter: /usr/bin/hello-py: /usr/bin/python3 -u" ||
+		got[3] != "interpreter: /usr/bin/hello-su: /bin/sh" ||
+		got[4] != "setuid: /usr/bin/hello-su: setuid root" ||
+		got[5] != "setuid: /usr/bin/hello-su: setgid shadow" {
+		t.Fatalf("Unexpected findings:\n%s", strings.Join(got, "\n"))
+	}
+}
+
+// vim: foldmethod=marker
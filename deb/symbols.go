@@ -0,0 +1,299 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package deb // import "github.com/ebikt/go-debian/deb"
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ebikt/go-debian/version"
+)
+
+// Symbols {{{
+
+// A Symbol is a symbol exported by a library, as listed in a symbols file.
+type Symbol struct {
+	// Such as "hello_world@Base", or "hello@HELLO_1.0" for a symbol with
+	// a version.
+	Name string
+
+	// Tags of the symbol, such as "c++", "optional" or "arch=amd64".
+	Tags []string
+
+	// First version of the package providing the symbol.
+	MinVersion version.Version
+
+	// Index of the dependency template a package using the symbol gets,
+	// in the Depends of its SymbolsLibrary.
+	Template int
+
+	// Version of the package which stopped providing the symbol, for the
+	// symbols marked "#MISSING:" by dpkg-gensymbols, or empty.
+	Missing version.Version
+}
+
+// SplitName returns the name of the symbol and its version, such as
+// "hello" and "Base" for "hello@Base".
+func (s Symbol) SplitName() (string, string) {
+	i := strings.LastIndex(s.Name, "@")
+	if i == -1 {
+		return s.Name, ""
+	}
+	return s.Name[:i], s.Name[i+1:]
+}
+
+// Tag returns the value of a tag of the symbol, such as "amd64" for the
+// tag "arch=amd64", and whether the symbol has it.
+func (s Symbol) Tag(name string) (string, bool) {
+	for _, tag := range s.Tags {
+		parts := strings.SplitN(tag, "=", 2)
+		if parts[0] != name {
+			continue
+		}
+		if len(parts) == 1 {
+			return "", true
+		}
+		return parts[1], true
+	}
+	return "", false
+}
+
+// IsMissing returns whether the symbol is marked "#MISSING:".
+func (s Symbol) IsMissing() bool {
+	return !s.Missing.Empty()
+}
+
+// String returns the line of the symbol in a symbols file.
+func (s Symbol) String() string {
+	line := " "
+	if s.IsMissing() {
+		line = "#MISSING: " + s.Missing.String() + "# "
+	}
+	if len(s.Tags) > 0 {
+		line += "(" + strings.Join(s.Tags, "|") + ")"
+	}
+	if strings.ContainsAny(s.Name, " \t\"") || strings.HasPrefix(s.Name, "(") {
+		line += "\"" + s.Name + "\""
+	} else {
+		line += s.Name
+	}
+	line += " " + s.MinVersion.String()
+	if s.Template != 0 {
+		line += " " + strconv.Itoa(s.Template)
+	}
+	return line
+}
+
+// A SymbolsLibrary is the part of a symbols file about a library, see
+// deb-symbols(5).
+type SymbolsLibrary struct {
+	// Soname of the library, such as "libz.so.1".
+	Library string
+
+	// Dependency templates, such as "zlib1g #MINVER#": the one of the
+	// library line, followed by the alternative ones.
+	Depends []string
+
+	// Meta information fields, such as "Build-Depends-Package".
+	Fields map[string]string
+
+	Symbols []Symbol
+}
+
+// Lookup returns the symbol with the name, such as "hello@Base".
+func (l SymbolsLibrary) Lookup(name string) *Symbol {
+	for i := range l.Symbols {
+		if l.Symbols[i].Name == name {
+			return &l.Symbols[i]
+		}
+	}
+	return nil
+}
+
+// parseSymbolLine parses the line of a symbol, without its leading
+// whitespace nor "#MISSING:" marker.
+func parseSymbolLine(line string) (*Symbol, error) {
+	symbol := Symbol{}
+	rest := line
+	if strings.HasPrefix(rest, "(") {
+		end := strings.Index(rest, ")")
+		if end == -1 {
+			return nil, fmt.Errorf("Unterminated tags in symbols line: '%s'", line)
+		}
+		symbol.Tags = strings.Split(rest[1:end], "|")
+		rest = rest[end+1:]
+	}
+	if strings.HasPrefix(rest, "\"") {
+		end := strings.Index(rest[1:], "\"")
+		if end == -1 {
+			return nil, fmt.Errorf("Unterminated symbol name in symbols line: '%s'", line)
+		}
+		symbol.Name = rest[1 : end+1]
+		rest = rest[end+2:]
+	} else if fields := strings.Fields(rest); len(fields) > 0 {
+		symbol.Name = fields[0]
+		rest = strings.TrimPrefix(rest, fields[0])
+	}
+
+	fields := strings.Fields(rest)
+	if symbol.Name == "" || len(fields) < 1 || len(fields) > 2 {
+		return nil, fmt.Errorf("Invalid symbols line: '%s'", line)
+	}
+	minVersion, err := version.Parse(fields[0])
+	if err != nil {
+		return nil, err
+	}
+	symbol.MinVersion = minVersion
+	if len(fields) == 2 {
+		if symbol.Template, err = strconv.Atoi(fields[1]); err != nil || symbol.Template < 0 {
+			return nil, fmt.Errorf("Invalid dependency template of symbol %s: '%s'", symbol.Name, fields[1])
+		}
+	}
+	return &symbol, nil
+}
+
+// ParseSymbols reads a symbols file, as found in the control member of a
+// .deb or as debian/libhello1.symbols in a source package. Comments are
+// dropped, but for the "#MISSING:" markers of dpkg-gensymbols, and
+// "#include" isn't supported.
+func ParseSymbols(reader io.Reader) ([]SymbolsLibrary, error) {
+	ret := []SymbolsLibrary{}
+	var current *SymbolsLibrary
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		var missing version.Version
+		if strings.HasPrefix(line, "#MISSING:") {
+			end := strings.Index(line[len("#MISSING:"):], "#")
+			if end == -1 {
+				return nil, fmt.Errorf("Invalid #MISSING line in symbols file: '%s'", line)
+			}
+			end += len("#MISSING:")
+			var err error
+			if missing, err = version.Parse(strings.TrimSpace(line[len("#MISSING:"):end])); err != nil {
+				return nil, err
+			}
+			line = " " + strings.TrimLeft(line[end+1:], " \t")
+		} else if strings.HasPrefix(line, "#include") {
+			return nil, fmt.Errorf("Unsupported #include in symbols file: '%s'", line)
+		} else if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+
+		switch line[0] {
+		case ' ', '\t':
+			if current == nil {
+				return nil, fmt.Errorf("Symbol before any library in symbols file: '%s'", line)
+			}
+			symbol, err := parseSymbolLine(strings.TrimSpace(line))
+			if err != nil {
+				return nil, err
+			}
+			if symbol.Template >= len(current.Depends) {
+				return nil, fmt.Errorf("Invalid dependency template of symbol %s: '%d'", symbol.Name, symbol.Template)
+			}
+			symbol.Missing = missing
+			current.Symbols = append(current.Symbols, *symbol)
+		case '|':
+			if current == nil {
+				return nil, fmt.Errorf("Alternative before any library in symbols file: '%s'", line)
+			}
+			current.Depends = append(current.Depends, strings.TrimSpace(line[1:]))
+		case '*':
+			if current == nil {
+				return nil, fmt.Errorf("Field before any library in symbols file: '%s'", line)
+			}
+			field := strings.SplitN(strings.TrimSpace(line[1:]), ":", 2)
+			if len(field) != 2 {
+				return nil, fmt.Errorf("Invalid symbols field: '%s'", line)
+			}
+			current.Fields[strings.TrimSpace(field[0])] = strings.TrimSpace(field[1])
+		default:
+			fields := strings.SplitN(line, " ", 2)
+			if len(fields) != 2 {
+				return nil, fmt.Errorf("Invalid symbols library line: '%s'", line)
+			}
+			ret = append(ret, SymbolsLibrary{
+				Library: fields[0],
+				Depends: []string{strings.TrimSpace(fields[1])},
+				Fields:  map[string]string{},
+			})
+			current = &ret[len(ret)-1]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// FormatSymbols returns the symbols file listing the libraries: the
+// library lines, the alternative dependency templates, the fields in
+// order of their names and the symbols, in order.
+func FormatSymbols(libraries []SymbolsLibrary) ([]byte, error) {
+	out := bytes.Buffer{}
+	for _, library := range libraries {
+		if library.Library == "" || strings.ContainsAny(library.Library, " \t\n") {
+			return nil, fmt.Errorf("Invalid library name '%s'", library.Library)
+		}
+		if len(library.Depends) == 0 {
+			return nil, fmt.Errorf("%s: No dependency template", library.Library)
+		}
+		out.WriteString(library.Library + " " + library.Depends[0] + "\n")
+		for _, depends := range library.Depends[1:] {
+			out.WriteString("| " + depends + "\n")
+		}
+		names := []string{}
+		for name := range library.Fields {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			out.WriteString("* " + name + ": " + library.Fields[name] + "\n")
+		}
+		for _, symbol := range library.Symbols {
+			if symbol.Template < 0 || symbol.Template >= len(library.Depends) {
+				return nil, fmt.Errorf("%s: Invalid dependency template of symbol %s: '%d'",
+					library.Library, symbol.Name, symbol.Template)
+			}
+			out.WriteString(symbol.String() + "\n")
+		}
+	}
+	return out.Bytes(), nil
+}
+
+// Symbols returns the libraries listed in the symbols file of the .deb.
+func (deb *Deb) Symbols() ([]SymbolsLibrary, error) {
+	return ParseSymbols(bytes.NewReader(deb.ControlFiles["symbols"]))
+}
+
+// }}}
+
+// vim: foldmethod=marker
@@ -0,0 +1,121 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package deb_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ebikt/go-debian/deb"
+)
+
+/*
+ *
+ */
+
+const symbolsFile = `libhello.so.1 libhello1 #MINVER#
+| libhello-extra1 #MINVER#
+* Build-Depends-Package: libhello-dev
+ hello@Base 2.9
+ hello_extra@HELLO_1.1 2.11~rc1 1
+ (c++|optional)"hello::world() const@Base" 2.10
+#MISSING: 2.12# hello_old@Base 2.9
+libhello-extra.so.1 libhello-extra1
+ extra@Base 0
+`
+
+func TestParseSymbols(t *testing.T) {
+	libraries, err := deb.ParseSymbols(strings.NewReader("# Generated\n" + symbolsFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(libraries) != 2 || len(libraries[0].Symbols) != 4 || len(libraries[1].Symbols) != 1 {
+		t.Fatalf("Unexpected libraries %v", libraries)
+	}
+	library := libraries[0]
+	if len(library.Depends) != 2 || library.Depends[1] != "libhello-extra1 #MINVER#" {
+		t.Fatalf("Unexpected dependency templates %v", library.Depends)
+	}
+
+	extra := library.Lookup("hello_extra@HELLO_1.1")
+	if extra == nil || extra.Template != 1 || extra.MinVersion.String() != "2.11~rc1" || extra.IsMissing() {
+		t.Fatalf("Unexpected symbol %v", extra)
+	}
+	if name, symver := extra.SplitName(); name != "hello_extra" || symver != "HELLO_1.1" {
+		t.Fatalf("Unexpected symbol name %s %s", name, symver)
+	}
+
+	cxx := library.Lookup("hello::world() const@Base")
+	if cxx == nil || len(cxx.Tags) != 2 || cxx.Tags[0] != "c++" {
+		t.Fatalf("Unexpected symbol %v", cxx)
+	}
+	if _, ok := cxx.Tag("optional"); !ok {
+		t.Fatal("Tag optional not found")
+	}
+	if _, ok := cxx.Tag("arch"); ok {
+		t.Fatal("Tag arch found")
+	}
+
+	old := library.Lookup("hello_old@Base")
+	if old == nil || !old.IsMissing() || old.Missing.String() != "2.12" {
+		t.Fatalf("Unexpected symbol %v", old)
+	}
+}
+
+func TestFormatSymbols(t *testing.T) {
+	libraries, err := deb.ParseSymbols(strings.NewReader(symbolsFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := deb.FormatSymbols(libraries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != symbolsFile {
+		t.Fatalf("Unexpected symbols file:\n%s", out)
+	}
+
+	libraries[1].Symbols[0].Template = 1
+	if _, err := deb.FormatSymbols(libraries); err == nil {
+		t.Fatal("Unknown dependency template accepted")
+	}
+	if _, err := deb.FormatSymbols([]deb.SymbolsLibrary{{Library: "libhello.so.1"}}); err == nil {
+		t.Fatal("Library without dependency template accepted")
+	}
+}
+
+func TestParseSymbolsInvalid(t *testing.T) {
+	for _, data := range []string{
+		"#include \"libhello1.symbols.common\"\n",
+		"libhello.so.1 libhello1\n#MISSING: 2.12 hello@Base 2.9\n",
+		"libhello.so.1 libhello1\n (c++|optional hello@Base 2.9\n",
+		"libhello.so.1 libhello1\n \"hello()@Base 2.9\n",
+		"libhello.so.1 libhello1\n hello@Base\n",
+		"libhello.so.1 libhello1\n hello@Base 2.9 first\n",
+		"| libhello-extra1\n",
+	} {
+		if _, err := deb.ParseSymbols(strings.NewReader(data)); err == nil {
+			t.Fatalf("Invalid symbols file accepted: %s", data)
+		}
+	}
+}
+
+// vim: foldmethod=marker
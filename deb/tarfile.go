@@ -25,6 +25,7 @@ import (
 	"io"
 	"path/filepath"
 	"strings"
+	"sync"
 
 	"archive/tar"
 
@@ -32,6 +33,7 @@ import (
 	"compress/gzip"
 
 	"github.com/kjk/lzma"
+	"github.com/klauspost/compress/zstd"
 	"github.com/xi2/xz"
 )
 
@@ -51,23 +53,53 @@ func lzmaNewReader(r io.Reader) (io.Reader, error) {
 	return lzma.NewReader(r), nil
 }
 
+func zstdNewReader(r io.Reader) (io.Reader, error) {
+	/* A single goroutine decodes synchronously, and doesn't leak anything
+	 * when the reader is dropped without being closed. */
+	return zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+}
+
 func bzipNewReader(r io.Reader) (io.Reader, error) {
 	return bzip2.NewReader(r), nil
 }
 
-// For the authoritative list of supported file formats, see
+// Decompressors of the members of a .deb, keyed on their extension. For
+// the authoritative list of supported file formats, see
 // https://manpages.debian.org/unstable/dpkg-dev/deb.5
-var knownCompressionAlgorithms = map[string]DecompressorFunc{
-	".gz":   gzipNewReader,
-	".bz2":  bzipNewReader,
-	".xz":   xzNewReader,
-	".lzma": lzmaNewReader,
+var (
+	decompressorsLock sync.RWMutex
+	decompressors     = map[string]DecompressorFunc{
+		".gz":   gzipNewReader,
+		".bz2":  bzipNewReader,
+		".xz":   xzNewReader,
+		".lzma": lzmaNewReader,
+		".zst":  zstdNewReader,
+	}
+)
+
+// RegisterDecompressor registers the decompressor of the members with the
+// extension `ext`, such as ".lz4", replacing the one already registered
+// if any. Tarfile uses it from then on.
+func RegisterDecompressor(ext string, fn DecompressorFunc) {
+	decompressorsLock.Lock()
+	defer decompressorsLock.Unlock()
+	decompressors[ext] = fn
 }
 
-// DecompressorFn returns a decompressing reader for the specified reader and its
-// corresponding file extension ext.
+// LookupDecompressor returns the decompressor registered for the
+// extension `ext`, such as ".zst".
+func LookupDecompressor(ext string) (DecompressorFunc, bool) {
+	decompressorsLock.RLock()
+	defer decompressorsLock.RUnlock()
+	fn, ok := decompressors[ext]
+	return fn, ok
+}
+
+// DecompressorFor returns the decompressor registered for the extension
+// `ext`, and one passing the data through when there's none, such as for
+// uncompressed members.
 func DecompressorFor(ext string) DecompressorFunc {
-	if fn, ok := knownCompressionAlgorithms[ext]; ok {
+	if fn, ok := LookupDecompressor(ext); ok {
 		return fn
 	}
 	return func(r io.Reader) (io.Reader, error) { return r, nil } // uncompressed file or unknown compression scheme
@@ -78,27 +110,59 @@ func DecompressorFor(ext string) DecompressorFunc {
 // IsTarfile {{{
 
 // Check to see if the given ArEntry is, in fact, a Tarfile. This method
-// will return `true` for `control.tar.*` and `data.tar.*` files.
+// will return `true` for `control.tar`, `data.tar` and their compressed
+// `control.tar.*` and `data.tar.*` variants.
 //
 // This will return `false` for the `debian-binary` file. If this method
 // returns `true`, the `.Tarfile()` method will be around to give you a
 // tar.Reader back.
 func (e *ArEntry) IsTarfile() bool {
 	ext := filepath.Ext(e.Name)
+	if ext == ".tar" {
+		return true
+	}
 	return filepath.Ext(strings.TrimSuffix(e.Name, ext)) == ".tar"
 }
 
+// isMember checks whether the ArEntry is the `<name>.tar` member of a
+// .deb, or one of its compressed variants.
+func (e *ArEntry) isMember(name string) bool {
+	return e.Name == name+".tar" || strings.HasPrefix(e.Name, name+".tar.")
+}
+
+// IsControl checks whether the ArEntry is the `control.tar` member of a
+// .deb, compressed or not, holding its control files.
+func (e *ArEntry) IsControl() bool {
+	return e.isMember("control")
+}
+
+// IsData checks whether the ArEntry is the `data.tar` member of a .deb,
+// compressed or not, holding the files it installs.
+func (e *ArEntry) IsData() bool {
+	return e.isMember("data")
+}
+
 // }}}
 
 // Tarfile {{{
 
 // `.Tarfile()` will return a `tar.Reader` created from the ArEntry member
-// to allow further inspection of the contents of the `.deb`.
+// to allow further inspection of the contents of the `.deb`. The member is
+// decompressed by the decompressor registered for its extension, see
+// RegisterDecompressor.
 func (e *ArEntry) Tarfile() (*tar.Reader, error) {
 	if !e.IsTarfile() {
 		return nil, fmt.Errorf("%s appears to not be a tarfile", e.Name)
 	}
-	reader, err := DecompressorFor(filepath.Ext(e.Name))(e.Data)
+	ext := filepath.Ext(e.Name)
+	if ext == ".tar" {
+		return tar.NewReader(e.Data), nil
+	}
+	decompressor, ok := LookupDecompressor(ext)
+	if !ok {
+		return nil, fmt.Errorf("%s is compressed with an unsupported format", e.Name)
+	}
+	reader, err := decompressor(e.Data)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,92 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package deb_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/ebikt/go-debian/deb"
+)
+
+/*
+ *
+ */
+
+func TestTarfileDecompressors(t *testing.T) {
+	data := tarball(t, map[string]string{"./usr/bin/hello": "#!/bin/sh\n"})
+
+	entry := deb.ArEntry{Name: "data.tar.rev", Data: bytes.NewReader(data)}
+	if !entry.IsTarfile() {
+		t.Fatalf("%s is a tarfile", entry.Name)
+	}
+	if _, err := entry.Tarfile(); err == nil {
+		t.Fatalf("Unsupported compression of %s not reported", entry.Name)
+	}
+
+	/* A "compression" that isn't */
+	deb.RegisterDecompressor(".rev", func(in io.Reader) (io.Reader, error) {
+		return in, nil
+	})
+	if _, ok := deb.LookupDecompressor(".rev"); !ok {
+		t.Fatalf("Decompressor of .rev not registered")
+	}
+	reader, err := entry.Tarfile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, err := reader.Next()
+	if err != nil || header.Name != "./usr/bin/hello" {
+		t.Fatalf("Unexpected member %v (%v)", header, err)
+	}
+
+	for _, ext := range []string{".gz", ".bz2", ".xz", ".lzma", ".zst"} {
+		if _, ok := deb.LookupDecompressor(ext); !ok {
+			t.Fatalf("No decompressor for %s", ext)
+		}
+	}
+	entry = deb.ArEntry{Name: "debian-binary", Data: bytes.NewReader([]byte("2.0\n"))}
+	if _, err := entry.Tarfile(); err == nil {
+		t.Fatalf("%s is not a tarfile", entry.Name)
+	}
+}
+
+func TestArEntryMembers(t *testing.T) {
+	for _, member := range []struct {
+		name          string
+		control, data bool
+	}{
+		{"debian-binary", false, false},
+		{"control.tar", true, false},
+		{"control.tar.zst", true, false},
+		{"data.tar.xz", false, true},
+		{"data.tarball", false, false},
+		{"_gpgorigin", false, false},
+	} {
+		entry := deb.ArEntry{Name: member.name}
+		if entry.IsControl() != member.control || entry.IsData() != member.data {
+			t.Fatalf("Wrong kind of member for %s", member.name)
+		}
+	}
+}
+
+// vim: foldmethod=marker
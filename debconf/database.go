@@ -0,0 +1,240 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package debconf // import "github.com/ebikt/go-debian/debconf"
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/ebikt/go-debian/control"
+)
+
+// Database {{{
+
+// A Record is an item of a debconf database in the format of its File
+// driver, such as a question of /var/cache/debconf/config.dat or a
+// template of /var/cache/debconf/templates.dat.
+type Record struct {
+	Name string
+
+	// The other fields, such as "template" and "value" for questions, or
+	// "type" and "description" for templates, with their names lowercased
+	// as debconf keeps them.
+	Fields map[string]string
+
+	// Packages owning the item.
+	Owners []string
+
+	// Flags set on a question, such as "seen".
+	Flags []string
+
+	// Variables substituted in the description of a question, such as
+	// "pkg" for ${pkg}.
+	Variables map[string]string
+}
+
+// Template returns the name of the template of a question.
+func (r Record) Template() string {
+	return r.Fields["template"]
+}
+
+// Value returns the answer to a question.
+func (r Record) Value() string {
+	return r.Fields["value"]
+}
+
+// HasFlag returns whether the flag, such as "seen", is set on the question.
+func (r Record) HasFlag(flag string) bool {
+	for _, el := range r.Flags {
+		if el == flag {
+			return true
+		}
+	}
+	return false
+}
+
+// unescape decodes a value of the database, where newlines are written
+// "\n" and backslashes "\\".
+func unescape(value string) string {
+	if !strings.Contains(value, "\\") {
+		return value
+	}
+	ret := strings.Builder{}
+	for i := 0; i < len(value); i++ {
+		if value[i] == '\\' && i+1 < len(value) {
+			i++
+			if value[i] == 'n' {
+				ret.WriteByte('\n')
+			} else {
+				ret.WriteByte(value[i])
+			}
+			continue
+		}
+		ret.WriteByte(value[i])
+	}
+	return ret.String()
+}
+
+// escape encodes a value of the database, the inverse of unescape.
+func escape(value string) string {
+	return strings.Replace(strings.Replace(value, "\\", "\\\\", -1), "\n", "\\n", -1)
+}
+
+// splitList splits the value of an Owners or Flags field.
+func splitList(value string) []string {
+	ret := []string{}
+	for _, el := range strings.Split(value, ",") {
+		if el = strings.TrimSpace(el); el != "" {
+			ret = append(ret, el)
+		}
+	}
+	return ret
+}
+
+// ParseDatabase reads a debconf database in the format of its File
+// driver, as debconf-copydb and debconf-show read, see debconf.conf(5).
+func ParseDatabase(reader io.Reader) ([]Record, error) {
+	paragraphs, err := control.NewParagraphReader(reader, nil)
+	if err != nil {
+		return nil, err
+	}
+	ret := []Record{}
+	for {
+		paragraph, err := paragraphs.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		record := Record{
+			Name:      paragraph.Get("Name"),
+			Fields:    map[string]string{},
+			Owners:    []string{},
+			Flags:     []string{},
+			Variables: map[string]string{},
+		}
+		if record.Name == "" {
+			return nil, fmt.Errorf("Item without a Name in debconf database")
+		}
+		for _, key := range paragraph.Order {
+			value := paragraph.Get(key)
+			switch strings.ToLower(key) {
+			case "name":
+			case "owners":
+				record.Owners = splitList(value)
+			case "flags":
+				record.Flags = splitList(value)
+			case "variables":
+				scanner := bufio.NewScanner(strings.NewReader(value))
+				for scanner.Scan() {
+					if strings.TrimSpace(scanner.Text()) == "" {
+						continue
+					}
+					parts := strings.SplitN(scanner.Text(), "=", 2)
+					if len(parts) != 2 {
+						return nil, fmt.Errorf("Invalid variable of %s: '%s'", record.Name, scanner.Text())
+					}
+					record.Variables[strings.TrimSpace(parts[0])] = unescape(strings.TrimSpace(parts[1]))
+				}
+			default:
+				record.Fields[strings.ToLower(key)] = unescape(value)
+			}
+		}
+		ret = append(ret, record)
+	}
+	return ret, nil
+}
+
+// ReadDatabase reads a debconf database file, such as
+// /var/cache/debconf/config.dat, see ParseDatabase.
+func ReadDatabase(path string) ([]Record, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseDatabase(f)
+}
+
+// WriteDatabase writes the records as debconf does: the Name first,
+// followed by the other fields, the Owners, the Flags and the Variables,
+// each sorted.
+func WriteDatabase(writer io.Writer, records []Record) error {
+	out := bufio.NewWriter(writer)
+	for _, record := range records {
+		if record.Name == "" || strings.ContainsAny(record.Name, "\n") {
+			return fmt.Errorf("Invalid name of debconf item: '%s'", record.Name)
+		}
+		fmt.Fprintf(out, "Name: %s\n", record.Name)
+
+		keys := []string{}
+		for key := range record.Fields {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			fmt.Fprintf(out, "%s: %s\n", strings.ToUpper(key[:1])+key[1:], escape(record.Fields[key]))
+		}
+
+		if len(record.Owners) > 0 {
+			owners := append([]string{}, record.Owners...)
+			sort.Strings(owners)
+			fmt.Fprintf(out, "Owners: %s\n", strings.Join(owners, ", "))
+		}
+		if len(record.Flags) > 0 {
+			flags := append([]string{}, record.Flags...)
+			sort.Strings(flags)
+			fmt.Fprintf(out, "Flags: %s\n", strings.Join(flags, ", "))
+		}
+		if len(record.Variables) > 0 {
+			names := []string{}
+			for name := range record.Variables {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			fmt.Fprintf(out, "Variables:\n")
+			for _, name := range names {
+				fmt.Fprintf(out, " %s = %s\n", name, escape(record.Variables[name]))
+			}
+		}
+		fmt.Fprintf(out, "\n")
+	}
+	return out.Flush()
+}
+
+// Lookup returns the record with the name, or nil.
+func Lookup(records []Record, name string) *Record {
+	for i := range records {
+		if records[i].Name == name {
+			return &records[i]
+		}
+	}
+	return nil
+}
+
+// }}}
+
+// vim: foldmethod=marker
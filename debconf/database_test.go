@@ -0,0 +1,100 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package debconf_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/ebikt/go-debian/debconf"
+)
+
+/*
+ *
+ */
+
+const configDat = `Name: hello/enable
+Template: hello/enable
+Value: true
+Owners: hello
+Flags: seen
+
+Name: hello/greeting
+Template: hello/greeting
+Value: hi\, there
+Owners: hello, hello-extra
+Variables:
+ extra = first line\nsecond line
+ pkg = hello
+
+`
+
+func TestParseDatabase(t *testing.T) {
+	records, err := debconf.ParseDatabase(strings.NewReader(configDat))
+	isok(t, err)
+	assert(t, len(records) == 2)
+
+	enable := debconf.Lookup(records, "hello/enable")
+	assert(t, enable != nil)
+	assert(t, enable.Template() == "hello/enable")
+	assert(t, enable.Value() == "true")
+	assert(t, enable.HasFlag("seen"))
+	assert(t, len(enable.Owners) == 1 && enable.Owners[0] == "hello")
+
+	greeting := debconf.Lookup(records, "hello/greeting")
+	assert(t, greeting != nil)
+	assert(t, greeting.Value() == "hi, there")
+	assert(t, !greeting.HasFlag("seen"))
+	assert(t, len(greeting.Owners) == 2)
+	assert(t, greeting.Variables["pkg"] == "hello")
+	assert(t, greeting.Variables["extra"] == "first line\nsecond line")
+
+	assert(t, debconf.Lookup(records, "hello/missing") == nil)
+}
+
+func TestParseDatabaseInvalid(t *testing.T) {
+	_, err := debconf.ParseDatabase(strings.NewReader("Template: hello/enable\nValue: true\n"))
+	notok(t, err)
+	_, err = debconf.ParseDatabase(strings.NewReader("Name: hello/greeting\nVariables:\n pkg\n"))
+	notok(t, err)
+}
+
+func TestWriteDatabase(t *testing.T) {
+	records, err := debconf.ParseDatabase(strings.NewReader(configDat))
+	isok(t, err)
+	out := bytes.Buffer{}
+	isok(t, debconf.WriteDatabase(&out, records))
+	assert(t, out.String() == strings.Replace(configDat, `Value: hi\, there`, "Value: hi, there", 1))
+
+	records[1].Fields["description"] = "Greeting:\nThe greeting \\o/"
+	out.Reset()
+	isok(t, debconf.WriteDatabase(&out, records[1:]))
+	assert(t, strings.Contains(out.String(), "\nDescription: Greeting:\\nThe greeting \\\\o/\n"))
+
+	again, err := debconf.ParseDatabase(&out)
+	isok(t, err)
+	assert(t, again[0].Fields["description"] == "Greeting:\nThe greeting \\o/")
+
+	notok(t, debconf.WriteDatabase(&out, []debconf.Record{{}}))
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,8 @@
+/*
+
+This module parses the files of debconf: the templates files of packages,
+which describe the questions they ask, and the databases debconf keeps
+them and their answers in, such as /var/cache/debconf/config.dat.
+
+*/
+package debconf // import "github.com/ebikt/go-debian/debconf"
@@ -0,0 +1,196 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package debconf // import "github.com/ebikt/go-debian/debconf"
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/ebikt/go-debian/control"
+)
+
+// Templates {{{
+
+// The types of debconf templates, see debconf-devel(7).
+var templateTypes = map[string]bool{
+	"boolean":     true,
+	"error":       true,
+	"multiselect": true,
+	"note":        true,
+	"password":    true,
+	"select":      true,
+	"string":      true,
+	"text":        true,
+	"title":       true,
+}
+
+// A Template is a question a package may ask, as described in its
+// templates file, see debconf-devel(7). The translations, such as
+// Description-de.UTF-8, are only in the Paragraph.
+type Template struct {
+	control.Paragraph
+
+	// Name of the question, such as "hello/greeting".
+	Template string `required:"true"`
+
+	// One of boolean, select, multiselect, string, password, note, text,
+	// error or title.
+	Type string `required:"true"`
+
+	Default string
+
+	// Choices of select and multiselect questions, separated by ", ",
+	// see SplitChoices.
+	Choices string
+
+	// The short description, the first line, followed by the extended
+	// one.
+	Description string
+}
+
+// SplitChoices returns the choices of a Choices field, which are
+// separated by ", " with the commas of the choices escaped as "\,".
+func SplitChoices(choices string) []string {
+	ret := []string{}
+	if strings.TrimSpace(choices) == "" {
+		return ret
+	}
+	current := strings.Builder{}
+	for i := 0; i < len(choices); i++ {
+		switch {
+		case choices[i] == '\\' && i+1 < len(choices) && choices[i+1] == ',':
+			current.WriteByte(',')
+			i++
+		case choices[i] == ',':
+			ret = append(ret, strings.TrimSpace(current.String()))
+			current.Reset()
+		default:
+			current.WriteByte(choices[i])
+		}
+	}
+	return append(ret, strings.TrimSpace(current.String()))
+}
+
+// JoinChoices returns the Choices field listing the choices, the inverse
+// of SplitChoices.
+func JoinChoices(choices []string) string {
+	escaped := []string{}
+	for _, choice := range choices {
+		escaped = append(escaped, strings.Replace(choice, ",", "\\,", -1))
+	}
+	return strings.Join(escaped, ", ")
+}
+
+// Translated returns the translation of a field of the template, such as
+// "Description", to the language, such as "pt_BR": the one of the
+// Description-pt_BR.UTF-8 or Description-pt_BR field, or else of the
+// language without its country, such as Description-pt.UTF-8. The field
+// itself is returned if there is no translation.
+func (t Template) Translated(field, language string) string {
+	candidates := []string{language}
+	if i := strings.IndexAny(language, "_@"); i > 0 {
+		candidates = append(candidates, language[:i])
+	}
+	for _, candidate := range candidates {
+		for _, key := range []string{field + "-" + candidate + ".UTF-8", field + "-" + candidate} {
+			if value, ok := t.Paragraph.Get2(key); ok {
+				return value
+			}
+		}
+	}
+	switch strings.ToLower(field) {
+	case "default":
+		return t.Default
+	case "choices":
+		return t.Choices
+	case "description":
+		return t.Description
+	}
+	return t.Paragraph.Get(field)
+}
+
+// Languages returns the languages the description of the template is
+// translated to, such as "de" or "pt_BR", sorted.
+func (t Template) Languages() []string {
+	ret := []string{}
+	for _, key := range t.Paragraph.Order {
+		if !strings.HasPrefix(strings.ToLower(key), "description-") {
+			continue
+		}
+		language := key[len("description-"):]
+		language = strings.TrimSuffix(strings.TrimSuffix(language, ".UTF-8"), ".utf-8")
+		ret = append(ret, language)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// check returns an error if the template lacks its name or has an
+// unknown type.
+func (t Template) check() error {
+	if t.Template == "" {
+		return fmt.Errorf("Template without a name")
+	}
+	if !templateTypes[t.Type] {
+		return fmt.Errorf("Unknown type '%s' of template %s", t.Type, t.Template)
+	}
+	return nil
+}
+
+// ParseTemplates reads a templates file, such as debian/hello.templates
+// or the templates file in the control member of a .deb.
+func ParseTemplates(reader io.Reader) ([]Template, error) {
+	ret := []Template{}
+	if err := control.Unmarshal(&ret, reader); err != nil {
+		return nil, err
+	}
+	for _, template := range ret {
+		if err := template.check(); err != nil {
+			return nil, err
+		}
+	}
+	return ret, nil
+}
+
+// WriteTemplates writes a templates file with the templates, separated
+// by empty lines.
+func WriteTemplates(writer io.Writer, templates []Template) error {
+	encoder, err := control.NewEncoder(writer)
+	if err != nil {
+		return err
+	}
+	encoder.SetOrder("Template", "Type", "Default", "Choices", "Description")
+	for _, template := range templates {
+		if err := template.check(); err != nil {
+			return err
+		}
+		if err := encoder.Encode(template); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// }}}
+
+// vim: foldmethod=marker
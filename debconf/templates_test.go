@@ -0,0 +1,133 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package debconf_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/ebikt/go-debian/debconf"
+)
+
+/*
+ *
+ */
+
+func isok(t *testing.T, err error) {
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+}
+
+func notok(t *testing.T, err error) {
+	if err == nil {
+		t.Fatalf("Expected error, didn't get one")
+	}
+}
+
+func assert(t *testing.T, expr bool) {
+	if !expr {
+		t.Fatalf("Assertion failed!")
+	}
+}
+
+/*
+ *
+ */
+
+const templatesFile = `Template: hello/greeting
+Type: select
+Choices: hello, hi\, there, good morning
+Choices-de.UTF-8: hallo, hi\, du, guten Morgen
+Default: hello
+Description: Greeting to use:
+ The greeting hello prints.
+ .
+ Pick the one you like.
+Description-de.UTF-8: Zu verwendender Gruß:
+ Der Gruß, den hello ausgibt.
+ .
+ Wählen Sie den, der Ihnen gefällt.
+Description-pt_BR.UTF-8: Saudação a usar:
+ A saudação que o hello exibe.
+
+Template: hello/enable
+Type: boolean
+Default: true
+Description: Enable hello?
+`
+
+func TestParseTemplates(t *testing.T) {
+	templates, err := debconf.ParseTemplates(strings.NewReader(templatesFile))
+	isok(t, err)
+	assert(t, len(templates) == 2)
+
+	greeting := templates[0]
+	assert(t, greeting.Template == "hello/greeting")
+	assert(t, greeting.Type == "select")
+	assert(t, greeting.Default == "hello")
+	choices := debconf.SplitChoices(greeting.Choices)
+	assert(t, len(choices) == 3 && choices[1] == "hi, there")
+	assert(t, debconf.JoinChoices(choices) == greeting.Choices)
+	assert(t, greeting.Description == "Greeting to use:\nThe greeting hello prints.\n\nPick the one you like.\n")
+
+	assert(t, strings.Join(greeting.Languages(), " ") == "de pt_BR")
+	assert(t, strings.HasPrefix(greeting.Translated("Description", "de_DE"), "Zu verwendender Gruß:\n"))
+	assert(t, strings.HasPrefix(greeting.Translated("Description", "pt_BR"), "Saudação a usar:\n"))
+	assert(t, debconf.SplitChoices(greeting.Translated("Choices", "de"))[1] == "hi, du")
+	assert(t, greeting.Translated("Description", "fr") == greeting.Description)
+	assert(t, greeting.Translated("Default", "de") == "hello")
+
+	assert(t, len(debconf.SplitChoices("")) == 0)
+}
+
+func TestParseTemplatesInvalid(t *testing.T) {
+	_, err := debconf.ParseTemplates(strings.NewReader("Template: hello/greeting\nType: question\n"))
+	notok(t, err)
+	_, err = debconf.ParseTemplates(strings.NewReader("Type: string\nDescription: Greeting\n"))
+	notok(t, err)
+}
+
+func TestWriteTemplates(t *testing.T) {
+	templates, err := debconf.ParseTemplates(strings.NewReader(templatesFile))
+	isok(t, err)
+	out := bytes.Buffer{}
+	isok(t, debconf.WriteTemplates(&out, templates))
+
+	again, err := debconf.ParseTemplates(&out)
+	isok(t, err)
+	assert(t, len(again) == 2)
+	assert(t, again[0].Description == templates[0].Description)
+	assert(t, again[0].Translated("Description", "de") == templates[0].Translated("Description", "de"))
+
+	out.Reset()
+	isok(t, debconf.WriteTemplates(&out, []debconf.Template{{
+		Template:    "hello/name",
+		Type:        "string",
+		Description: "Name to greet:",
+	}}))
+	assert(t, out.String() == "Template: hello/name\nType: string\nDescription: Name to greet:\n")
+
+	notok(t, debconf.WriteTemplates(&out, []debconf.Template{{Template: "hello/name"}}))
+}
+
+// vim: foldmethod=marker
@@ -73,8 +73,24 @@ func parseArchInto(ret *Arch, arch string) error {
 	 * kfreebsd-any (implicitly any-kfreebsd-any)
 	 * kfreebsd-amd64 (implicitly any-kfreebsd-any)
 	 * bsd-openbsd-i386 */
-	flavors := strings.SplitN(arch, "-", 3)
-	switch len(flavors) {
+	if arch == "" || arch[0] == '-' || arch[len(arch)-1] == '-' ||
+		strings.Contains(arch, "--") {
+		return errors.New("Empty component in an Arch")
+	}
+	count := strings.Count(arch, "-") + 1
+	if count > 3 {
+		return errors.New("Hurm, no idea what happened here")
+	}
+	/* Split by hand, as strings.Split allocates, and this is done for
+	 * every arch of every dependency parsed */
+	var flavors [3]string
+	for i := 0; i < count-1; i++ {
+		dash := strings.IndexByte(arch, '-')
+		flavors[i], arch = arch[:dash], arch[dash+1:]
+	}
+	flavors[count-1] = arch
+
+	switch count {
 	case 1:
 		flavor := flavors[0]
 		/* OK, we've got a single guy like `any` or `amd64` */
@@ -95,20 +111,23 @@ func parseArchInto(ret *Arch, arch string) error {
 		 * gnu-kfreebsd-amd64 */
 		ret.OS = flavors[0]
 		ret.CPU = flavors[1]
+		if ret.OS != "any" && ret.CPU != "any" {
+			ret.ABI = "gnu"
+		} else {
+			ret.ABI = "any"
+		}
 	case 3:
 		/* This is something like bsd-openbsd-amd64 */
 		ret.ABI = flavors[0]
 		ret.OS = flavors[1]
 		ret.CPU = flavors[2]
-	default:
-		return errors.New("Hurm, no idea what happened here")
 	}
 
 	return nil
 }
 
-/*
- */
+// Matches checks if the Arch `other` is matched by the set, see
+// Arch.Matches for how each of the listed architectures matches it.
 func (set *ArchSet) Matches(other *Arch) bool {
 	/* If [!amd64 sparc] matches gnu-linux-any */
 
@@ -119,7 +138,7 @@ func (set *ArchSet) Matches(other *Arch) bool {
 
 	not := set.Not
 	for _, el := range set.Architectures {
-		if el.Is(other) {
+		if other.Matches(el) {
 			/* For each arch; check if it matches. If it does, then
 			 * return true (unless we're negated) */
 			return !not
@@ -166,4 +185,71 @@ func (arch *Arch) Is(other *Arch) bool {
 	return false
 }
 
+// Matches returns true if the Arch is matched by `other`, following the
+// dpkg-architecture(1) wildcard semantics: "any" matches every
+// architecture but "all", "linux-any" every Linux one, "any-amd64" amd64
+// on every OS, and "all" only itself. Wildcards are matched against the
+// dpkg tuple of the Arch, see ArchToTuple, so that "any-arm" matches
+// armhf and armel, and "any-amd64" x32. A wildcard Arch is only matched
+// by itself. The "native" keyword has to be resolved first, see
+// ResolveNative.
+//
+//   amd64 matches: amd64, any, linux-any, any-amd64, gnu-linux-amd64
+//   armhf matches: armhf, any, linux-any, any-arm, gnu-linux-any
+//   all matches:   all
+func (arch Arch) Matches(other Arch) bool {
+	if arch.IsNative() || other.IsNative() {
+		return false
+	}
+	if arch == other {
+		return true
+	}
+	if arch.CPU == "all" || other.CPU == "all" || arch.IsWildcard() {
+		return false
+	}
+	tuple, err := ArchToTuple(arch.String())
+	if err != nil {
+		/* Not an architecture dpkg knows of: compare the parts */
+		return arch.Is(&other)
+	}
+	if !other.IsWildcard() {
+		otherTuple, err := ArchToTuple(other.String())
+		return err == nil && *otherTuple == *tuple
+	}
+	/* The wildcards have the libc, OS and CPU parts of the tuple, such
+	 * as gnu-linux-any, the ABI being any */
+	matches := func(wildcard, part string) bool {
+		return wildcard == "" || wildcard == "any" || wildcard == part
+	}
+	return matches(other.ABI, tuple.Libc) && matches(other.OS, tuple.OS) && matches(other.CPU, tuple.CPU)
+}
+
+// IsNative checks if this is the "native" keyword, which stands for the
+// architecture of the build machine in architecture qualifiers such as
+// "gcc:native".
+func (arch Arch) IsNative() bool {
+	return arch.CPU == "native" && (arch.OS == "linux" || arch.OS == "") &&
+		(arch.ABI == "gnu" || arch.ABI == "")
+}
+
+// ResolveNative returns the native Arch if this is the "native" keyword,
+// and the Arch itself otherwise.
+func (arch Arch) ResolveNative(native Arch) Arch {
+	if arch.IsNative() {
+		return native
+	}
+	return arch
+}
+
+// Check to see if this is one of the OfficialArchitectures. Wildcards are
+// never official.
+func (arch *Arch) IsOfficial() bool {
+	for _, official := range OfficialArchitectures {
+		if *arch == official {
+			return true
+		}
+	}
+	return false
+}
+
 // vim: foldmethod=marker
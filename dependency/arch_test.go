@@ -38,6 +38,20 @@ func TestArchBasics(t *testing.T) {
 	assert(t, arch.OS == "linux")
 }
 
+func TestArchTwoPartBasics(t *testing.T) {
+	arch, err := dependency.ParseArch("hurd-i386")
+	isok(t, err)
+	assert(t, arch.CPU == "i386")
+	assert(t, arch.ABI == "gnu")
+	assert(t, arch.OS == "hurd")
+	assert(t, !arch.IsWildcard())
+
+	arch, err = dependency.ParseArch("linux-any")
+	isok(t, err)
+	assert(t, arch.ABI == "any")
+	assert(t, arch.IsWildcard())
+}
+
 /*
  */
 func TestArchCompareBasics(t *testing.T) {
@@ -119,4 +133,49 @@ func TestArchSetCompare(t *testing.T) {
 	assert(t, barArch.Matches(iAmNot))
 }
 
+func TestArchMatches(t *testing.T) {
+	parse := func(in string) dependency.Arch {
+		arch, err := dependency.ParseArch(in)
+		isok(t, err)
+		return *arch
+	}
+	for arch, wildcards := range map[string]map[string]bool{
+		"amd64": {
+			"amd64": true, "any": true, "linux-any": true, "any-amd64": true,
+			"gnu-linux-amd64": true, "gnu-linux-any": true, "all": false,
+			"i386": false, "any-i386": false, "kfreebsd-any": false,
+			"musl-linux-any": false, "native": false,
+		},
+		"all": {"all": true, "any": false, "linux-any": false},
+		"hurd-i386": {
+			"any-i386": true, "hurd-any": true, "i386": false, "linux-any": false,
+		},
+		"musl-linux-arm64": {"linux-any": true, "any-arm64": true, "arm64": false},
+		"armhf": {
+			"any-arm": true, "linux-any": true, "gnu-linux-any": true, "armhf": true,
+			"armel": false, "any-armhf": false, "musl-linux-any": false, "arm": false,
+		},
+		"armel":      {"any-arm": true, "armhf": false},
+		"x32":        {"any-amd64": true, "linux-any": true, "amd64": false, "any-i386": false},
+		"any":        {"amd64": false, "any": true, "linux-any": false},
+		"linux-any":  {"amd64": false, "any": false},
+		"arm64ilp32": {"any-arm64": true, "arm64": false},
+	} {
+		for wildcard, match := range wildcards {
+			assert(t, parse(arch).Matches(parse(wildcard)) == match)
+		}
+	}
+
+	/* Wildcards as read from control files, as opposed to ParseArch */
+	linuxAny := dependency.Arch{}
+	isok(t, linuxAny.UnmarshalControl("linux-any"))
+	assert(t, parse("amd64").Matches(linuxAny))
+
+	native := parse("native")
+	assert(t, native.IsNative())
+	assert(t, !parse("amd64").IsNative())
+	assert(t, native.ResolveNative(parse("arm64")) == parse("arm64"))
+	assert(t, parse("i386").ResolveNative(parse("arm64")) == parse("i386"))
+}
+
 // vim: foldmethod=marker
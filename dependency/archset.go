@@ -0,0 +1,257 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package dependency // import "github.com/ebikt/go-debian/dependency"
+
+// ArchSet algebra {{{
+
+// An ArchSet without any Architectures matches every architecture, which
+// leaves no way to spell "no architecture at all" with the struct alone.
+// The operations below use a nil *ArchSet for that case, and accept nil
+// wherever they accept an ArchSet.
+//
+// Wildcards (such as `linux-any`) are kept as they are whenever the result
+// can be expressed with them. When it can't -- `[linux-any]` minus
+// `[amd64]` has no positive spelling -- the wildcard is expanded against
+// the OfficialArchitectures and PortsArchitectures lists.
+
+// Complement returns an ArchSet matching exactly the architectures that
+// this set does not match. The complement of an empty (match-all) set is
+// nil, and the complement of nil is an empty set.
+func (set *ArchSet) Complement() *ArchSet {
+	if set == nil {
+		return &ArchSet{Architectures: []Arch{}}
+	}
+	if len(set.Architectures) == 0 {
+		return nil
+	}
+	return &ArchSet{
+		Not:           !set.Not,
+		Architectures: append([]Arch{}, set.Architectures...),
+	}
+}
+
+// Intersection returns an ArchSet matching the architectures matched by
+// both sets, such as `[amd64 i386]` and `[!i386]` giving `[amd64]`. If no
+// architecture is matched by both, nil is returned.
+func (set *ArchSet) Intersection(other *ArchSet) *ArchSet {
+	if set == nil || other == nil {
+		return nil
+	}
+	if len(set.Architectures) == 0 {
+		return other.copy()
+	}
+	if len(other.Architectures) == 0 {
+		return set.copy()
+	}
+
+	ret := ArchSet{}
+	switch {
+	case set.Not && other.Not:
+		ret.Not = true
+		ret.Architectures = normalizeArchs(
+			append(append([]Arch{}, set.Architectures...), other.Architectures...),
+		)
+	case set.Not:
+		ret.Architectures = subtractArchs(other.Architectures, set.Architectures)
+	case other.Not:
+		ret.Architectures = subtractArchs(set.Architectures, other.Architectures)
+	default:
+		ret.Architectures = intersectArchs(set.Architectures, other.Architectures)
+	}
+
+	if !ret.Not && len(ret.Architectures) == 0 {
+		return nil
+	}
+	return &ret
+}
+
+// Union returns an ArchSet matching the architectures matched by either
+// set, such as `[amd64]` and `[i386]` giving `[amd64 i386]`. If either set
+// matches everything, the result is an empty (match-all) set.
+func (set *ArchSet) Union(other *ArchSet) *ArchSet {
+	return set.Complement().Intersection(other.Complement()).Complement()
+}
+
+// Expand returns the concrete architectures out of the given list that
+// are matched by this set, in the order of the list.
+func (set *ArchSet) Expand(arches []Arch) []Arch {
+	ret := []Arch{}
+	if set == nil {
+		return ret
+	}
+	for i := range arches {
+		if !arches[i].IsWildcard() && set.Matches(&arches[i]) {
+			ret = append(ret, arches[i])
+		}
+	}
+	return ret
+}
+
+// Official returns the OfficialArchitectures matched by this set.
+func (set *ArchSet) Official() []Arch {
+	return set.Expand(OfficialArchitectures)
+}
+
+// Contains checks that every architecture matched by the other set is
+// matched by this set as well. Architectures are checked against the
+// OfficialArchitectures, plus any concrete architecture named by either
+// of the sets.
+func (set *ArchSet) Contains(other *ArchSet) bool {
+	universe := archUniverse(OfficialArchitectures, set, other)
+	for _, arch := range other.Expand(universe) {
+		if set == nil || !set.Matches(&arch) {
+			return false
+		}
+	}
+	return true
+}
+
+func (set *ArchSet) copy() *ArchSet {
+	return &ArchSet{
+		Not:           set.Not,
+		Architectures: append([]Arch{}, set.Architectures...),
+	}
+}
+
+// }}}
+
+// Arch list helpers {{{
+
+// covers checks if the architecture `arch` is included in `by`, either by
+// being the same, or by being a concrete architecture matched by the
+// wildcard `by`.
+func covers(by, arch Arch) bool {
+	if by == arch {
+		return true
+	}
+	return by.IsWildcard() && !arch.IsWildcard() && arch.Matches(by)
+}
+
+// archUniverse returns the given architectures, followed by any concrete
+// architecture named in the given sets that isn't listed yet.
+func archUniverse(arches []Arch, sets ...*ArchSet) []Arch {
+	ret := append([]Arch{}, arches...)
+	for _, set := range sets {
+		if set == nil {
+			continue
+		}
+		for _, arch := range set.Architectures {
+			if !arch.IsWildcard() {
+				ret = appendArch(ret, arch)
+			}
+		}
+	}
+	return ret
+}
+
+func knownArchitectures() []Arch {
+	return append(append([]Arch{}, OfficialArchitectures...), PortsArchitectures...)
+}
+
+func appendArch(arches []Arch, arch Arch) []Arch {
+	for _, el := range arches {
+		if el == arch {
+			return arches
+		}
+	}
+	return append(arches, arch)
+}
+
+// normalizeArchs drops duplicates, as well as concrete architectures which
+// are already matched by a wildcard in the same list.
+func normalizeArchs(arches []Arch) []Arch {
+	ret := []Arch{}
+	for i, arch := range arches {
+		covered := false
+		for j, other := range arches {
+			if i != j && other != arch && covers(other, arch) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			ret = appendArch(ret, arch)
+		}
+	}
+	return ret
+}
+
+func intersectArchs(a, b []Arch) []Arch {
+	ret := []Arch{}
+	for _, x := range a {
+		for _, y := range b {
+			switch {
+			case covers(y, x):
+				ret = append(ret, x)
+			case covers(x, y):
+				ret = append(ret, y)
+			case x.IsWildcard() && y.IsWildcard():
+				/* Two distinct wildcards, such as linux-any and any-amd64;
+				 * there's no wildcard for the overlap, so spell it out. */
+				xs := ArchSet{Architectures: []Arch{x}}
+				ys := ArchSet{Architectures: []Arch{y}}
+				ret = append(ret, ys.Expand(xs.Expand(knownArchitectures()))...)
+			}
+		}
+	}
+	return normalizeArchs(ret)
+}
+
+// subtractArchs returns the architectures of `a` which are not in `b`.
+func subtractArchs(a, b []Arch) []Arch {
+	excluded := ArchSet{Architectures: b}
+	universe := archUniverse(knownArchitectures(), &ArchSet{Architectures: a}, &excluded)
+
+	ret := []Arch{}
+	for _, x := range a {
+		dropped, overlaps := false, false
+		for _, y := range b {
+			if covers(y, x) {
+				dropped = true
+				break
+			}
+			if x.IsWildcard() {
+				xs := ArchSet{Architectures: []Arch{x}}
+				ys := ArchSet{Architectures: []Arch{y}}
+				if len(ys.Expand(xs.Expand(universe))) > 0 {
+					overlaps = true
+				}
+			}
+		}
+		switch {
+		case dropped:
+		case overlaps:
+			xs := ArchSet{Architectures: []Arch{x}}
+			for _, arch := range xs.Expand(universe) {
+				if !excluded.Matches(&arch) {
+					ret = append(ret, arch)
+				}
+			}
+		default:
+			ret = append(ret, x)
+		}
+	}
+	return normalizeArchs(ret)
+}
+
+// }}}
+
+// vim: foldmethod=marker
@@ -0,0 +1,119 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package dependency_test
+
+import (
+	"testing"
+
+	"github.com/ebikt/go-debian/dependency"
+)
+
+/*
+ *
+ */
+
+func archSet(t *testing.T, in string) *dependency.ArchSet {
+	dep, err := dependency.Parse("foo " + in)
+	isok(t, err)
+	return dep.Relations[0].Possibilities[0].Architectures
+}
+
+func TestArchSetUnion(t *testing.T) {
+	for _, test := range []struct {
+		A, B, Out string
+	}{
+		{"[amd64]", "[i386]", "[amd64 i386]"},
+		{"[amd64 i386]", "[i386 armhf]", "[amd64 i386 armhf]"},
+		{"[!amd64 !i386]", "[!i386 !armhf]", "[!i386]"},
+		{"[!amd64]", "[amd64]", ""},
+		{"[!amd64 !i386]", "[amd64]", "[!i386]"},
+		{"[amd64]", "", ""},
+	} {
+		out := archSet(t, test.A).Union(archSet(t, test.B))
+		assert(t, out != nil)
+		assert(t, out.String() == test.Out)
+	}
+
+	linuxAny := archSet(t, "[linux-any]")
+	out := archSet(t, "[amd64]").Union(linuxAny)
+	assert(t, len(out.Architectures) == 1)
+	assert(t, out.Architectures[0] == linuxAny.Architectures[0])
+}
+
+func TestArchSetIntersection(t *testing.T) {
+	for _, test := range []struct {
+		A, B, Out string
+	}{
+		{"[amd64 i386]", "[i386 armhf]", "[i386]"},
+		{"[amd64 i386]", "[!i386]", "[amd64]"},
+		{"[!amd64]", "[!i386]", "[!amd64 !i386]"},
+		{"[linux-any]", "[amd64 hurd-i386]", "[amd64]"},
+		{"[amd64]", "", "[amd64]"},
+		{"[!hurd-any]", "[!hurd-i386]", "[!hurd-any]"},
+		{"[hurd-any]", "[!hurd-amd64]", "[hurd-i386]"},
+	} {
+		out := archSet(t, test.A).Intersection(archSet(t, test.B))
+		assert(t, out != nil)
+		assert(t, out.String() == test.Out)
+	}
+
+	assert(t, archSet(t, "[amd64]").Intersection(archSet(t, "[i386]")) == nil)
+	assert(t, archSet(t, "[amd64]").Intersection(archSet(t, "[!amd64]")) == nil)
+	assert(t, archSet(t, "[amd64]").Intersection(nil) == nil)
+}
+
+func TestArchSetComplement(t *testing.T) {
+	assert(t, archSet(t, "[amd64 i386]").Complement().String() == "[!amd64 !i386]")
+	assert(t, archSet(t, "[!armel]").Complement().String() == "[armel]")
+	assert(t, archSet(t, "").Complement() == nil)
+
+	var none *dependency.ArchSet
+	all := none.Complement()
+	assert(t, all != nil)
+	assert(t, all.String() == "")
+
+	amd64, err := dependency.ParseArch("amd64")
+	isok(t, err)
+	assert(t, all.Matches(amd64))
+}
+
+func TestArchSetOfficial(t *testing.T) {
+	official := archSet(t, "[!i386 !hurd-i386]").Official()
+	assert(t, len(official) == len(dependency.OfficialArchitectures)-1)
+	for _, arch := range official {
+		assert(t, arch.IsOfficial())
+		assert(t, arch.CPU != "i386")
+	}
+
+	hurd, err := dependency.ParseArch("hurd-i386")
+	isok(t, err)
+	assert(t, !hurd.IsOfficial())
+
+	assert(t, archSet(t, "").Contains(archSet(t, "[amd64]")))
+	assert(t, archSet(t, "[linux-any]").Contains(archSet(t, "[amd64 i386]")))
+	assert(t, !archSet(t, "[amd64 i386]").Contains(archSet(t, "[linux-any]")))
+	assert(t, archSet(t, "[!armel]").Contains(archSet(t, "[amd64 armhf]")))
+	assert(t, !archSet(t, "[!armel]").Contains(archSet(t, "[armel]")))
+	assert(t, !archSet(t, "[amd64]").Contains(archSet(t, "[hurd-i386]")))
+	assert(t, archSet(t, "[amd64]").Contains(nil))
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,85 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package dependency // import "github.com/ebikt/go-debian/dependency"
+
+import (
+	"sync"
+)
+
+// Cache {{{
+
+// A Cache remembers the relationship fields it parsed, for the programs
+// parsing the same fields over and over, such as resolvers going through
+// the indexes of a whole archive, where many packages share their Depends.
+// It holds up to twice its size of fields, dropping the ones least recently
+// used past that. A Cache may be used from several goroutines at once.
+type Cache struct {
+	size int
+
+	mutex    sync.Mutex
+	current  map[string]cachedDependency
+	previous map[string]cachedDependency
+}
+
+type cachedDependency struct {
+	dependency *Dependency
+	err        error
+}
+
+// NewCache creates a Cache of the given size, which is at least 1.
+func NewCache(size int) *Cache {
+	if size < 1 {
+		size = 1
+	}
+	return &Cache{size: size, current: map[string]cachedDependency{}}
+}
+
+// Parse parses the field, like the Parse function of this package, or
+// returns the outcome of parsing it before. The Dependency is shared by all
+// the callers parsing the same field, and must not be modified.
+func (c *Cache) Parse(in string) (*Dependency, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if cached, ok := c.current[in]; ok {
+		return cached.dependency, cached.err
+	}
+	cached, ok := c.previous[in]
+	if !ok {
+		cached.dependency, cached.err = Parse(in)
+	}
+	if len(c.current) >= c.size {
+		/* What wasn't used since the last time is dropped */
+		c.previous, c.current = c.current, map[string]cachedDependency{}
+	}
+	c.current[in] = cached
+	return cached.dependency, cached.err
+}
+
+// Len returns the number of fields the Cache holds.
+func (c *Cache) Len() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return len(c.current) + len(c.previous)
+}
+
+// }}}
+
+// vim: foldmethod=marker
@@ -0,0 +1,104 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package dependency_test
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/ebikt/go-debian/dependency"
+)
+
+/*
+ *
+ */
+
+func TestCache(t *testing.T) {
+	cache := dependency.NewCache(2)
+	first, err := cache.Parse(benchmarkDepends)
+	isok(t, err)
+	second, err := cache.Parse(benchmarkDepends)
+	isok(t, err)
+	assert(t, first == second)
+	assert(t, first.String() == benchmarkDepends)
+
+	_, err = cache.Parse("foo (>= 1.0")
+	notok(t, err)
+	_, err = cache.Parse("foo (>= 1.0")
+	notok(t, err)
+
+	/* Past twice its size, the least recently used fields are dropped */
+	for i := 0; i < 10; i++ {
+		_, err := cache.Parse(fmt.Sprintf("libfoo%d", i))
+		isok(t, err)
+	}
+	assert(t, cache.Len() <= 4)
+	third, err := cache.Parse(benchmarkDepends)
+	isok(t, err)
+	assert(t, third != first)
+	assert(t, third.String() == first.String())
+}
+
+/*
+ * The benchmark parses fields the way a resolver going through the
+ * indexes of an archive does: a few thousand distinct Depends, shared by
+ * many packages, parsed again and again.
+ */
+
+func archiveDepends() []string {
+	random := rand.New(rand.NewSource(1))
+	libraries := []string{"libc6 (>= 2.34)", "libssl3 (>= 3.0.0)", "zlib1g (>= 1:1.2.0)",
+		"libglib2.0-0 (>= 2.75.3)", "libstdc++6 (>= 13.1)", "libgcc-s1 (>= 3.0)"}
+	ret := []string{}
+	for i := 0; i < 5000; i++ {
+		depends := libraries[random.Intn(len(libraries))]
+		for j := random.Intn(5); j > 0; j-- {
+			depends += fmt.Sprintf(", lib%s%d (>= %d.%d)", string(rune('a'+random.Intn(26))),
+				random.Intn(100), random.Intn(5), random.Intn(20))
+		}
+		if random.Intn(3) == 0 {
+			depends += ", debconf (>= 0.5) | debconf-2.0"
+		}
+		ret = append(ret, depends)
+	}
+	return ret
+}
+
+func benchmarkArchiveParse(b *testing.B, parse func(string) (*dependency.Dependency, error)) {
+	depends := archiveDepends()
+	random := rand.New(rand.NewSource(2))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		parse(depends[random.Intn(len(depends))])
+	}
+}
+
+func BenchmarkArchiveParse(b *testing.B) {
+	benchmarkArchiveParse(b, dependency.Parse)
+}
+
+func BenchmarkArchiveParseCache(b *testing.B) {
+	benchmarkArchiveParse(b, dependency.NewCache(8192).Parse)
+}
+
+// vim: foldmethod=marker
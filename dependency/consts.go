@@ -25,4 +25,35 @@ var (
 	All = Arch{ABI: "all", OS: "all", CPU: "all"}
 )
 
+// OfficialArchitectures are the release architectures of the Debian
+// archive, as listed on https://www.debian.org/ports/.
+var OfficialArchitectures = []Arch{
+	{ABI: "gnu", OS: "linux", CPU: "amd64"},
+	{ABI: "gnu", OS: "linux", CPU: "arm64"},
+	{ABI: "gnu", OS: "linux", CPU: "armel"},
+	{ABI: "gnu", OS: "linux", CPU: "armhf"},
+	{ABI: "gnu", OS: "linux", CPU: "i386"},
+	{ABI: "gnu", OS: "linux", CPU: "ppc64el"},
+	{ABI: "gnu", OS: "linux", CPU: "riscv64"},
+	{ABI: "gnu", OS: "linux", CPU: "s390x"},
+}
+
+// PortsArchitectures are the architectures built on debian-ports, which
+// are not part of the official archive but routinely show up in
+// architecture restriction lists.
+var PortsArchitectures = []Arch{
+	{ABI: "gnu", OS: "linux", CPU: "alpha"},
+	{ABI: "gnu", OS: "linux", CPU: "hppa"},
+	{ABI: "gnu", OS: "linux", CPU: "loong64"},
+	{ABI: "gnu", OS: "linux", CPU: "m68k"},
+	{ABI: "gnu", OS: "linux", CPU: "mips64el"},
+	{ABI: "gnu", OS: "linux", CPU: "powerpc"},
+	{ABI: "gnu", OS: "linux", CPU: "ppc64"},
+	{ABI: "gnu", OS: "linux", CPU: "sh4"},
+	{ABI: "gnu", OS: "linux", CPU: "sparc64"},
+	{ABI: "gnu", OS: "linux", CPU: "x32"},
+	{ABI: "gnu", OS: "hurd", CPU: "amd64"},
+	{ABI: "gnu", OS: "hurd", CPU: "i386"},
+}
+
 // vim: foldmethod=marker
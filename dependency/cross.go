@@ -0,0 +1,174 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package dependency // import "github.com/ebikt/go-debian/dependency"
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ebikt/go-debian/version"
+)
+
+// Build, host and target architectures {{{
+
+// CrossArchitectures are the architectures of a build, as
+// dpkg-architecture(1) names them: the one of the build machine, the one
+// the packages are built for, and, for cross compilers, the one the
+// compiler built generates code for. They're all the same for a native
+// build.
+type CrossArchitectures struct {
+	Build  Arch
+	Host   Arch
+	Target Arch
+}
+
+// NewCrossArchitectures parses the build and host architectures, such as
+// "amd64" and "arm64"; the target architecture is the host one.
+func NewCrossArchitectures(build, host string) (*CrossArchitectures, error) {
+	buildArch, err := ParseArch(build)
+	if err != nil {
+		return nil, err
+	}
+	hostArch, err := ParseArch(host)
+	if err != nil {
+		return nil, err
+	}
+	return &CrossArchitectures{Build: *buildArch, Host: *hostArch, Target: *hostArch}, nil
+}
+
+// IsCross tells whether the packages are built for another architecture
+// than the one of the build machine.
+func (a CrossArchitectures) IsCross() bool {
+	return a.Build != a.Host
+}
+
+// Environ returns the DEB_BUILD_*, DEB_HOST_* and DEB_TARGET_* variables of
+// dpkg-architecture, as "KEY=value" strings, such as
+// "DEB_HOST_GNU_TYPE=aarch64-linux-gnu".
+func (a CrossArchitectures) Environ() ([]string, error) {
+	ret := []string{}
+	for _, role := range []struct {
+		name string
+		arch Arch
+	}{{"BUILD", a.Build}, {"HOST", a.Host}, {"TARGET", a.Target}} {
+		arch := role.arch.String()
+		tuple, err := ArchToTuple(arch)
+		if err != nil {
+			return nil, err
+		}
+		triplet, err := ArchToTriplet(arch)
+		if err != nil {
+			return nil, err
+		}
+		multiarch, err := ArchToMultiarch(arch)
+		if err != nil {
+			return nil, err
+		}
+		bits, endian, err := ArchBits(arch)
+		if err != nil {
+			return nil, err
+		}
+		gnu := strings.SplitN(triplet, "-", 2)
+		for _, variable := range []struct{ key, value string }{
+			{"ARCH", arch},
+			{"ARCH_ABI", tuple.ABI},
+			{"ARCH_BITS", fmt.Sprint(bits)},
+			{"ARCH_CPU", tuple.CPU},
+			{"ARCH_ENDIAN", endian},
+			{"ARCH_LIBC", tuple.Libc},
+			{"ARCH_OS", tuple.OS},
+			{"GNU_CPU", gnu[0]},
+			{"GNU_SYSTEM", gnu[1]},
+			{"GNU_TYPE", triplet},
+			{"MULTIARCH", multiarch},
+		} {
+			ret = append(ret, "DEB_"+role.name+"_"+variable.key+"="+variable.value)
+		}
+	}
+	return ret, nil
+}
+
+// }}}
+
+// Multi-Arch {{{
+
+// The values of the Multi-Arch field.
+const (
+	MultiArchNo      = "no"
+	MultiArchSame    = "same"
+	MultiArchForeign = "foreign"
+	MultiArchAllowed = "allowed"
+)
+
+// crossArchSatisfied tells whether a package of the architecture, and with
+// the Multi-Arch value, can satisfy the architecture qualifier.
+func crossArchSatisfied(qualifier *Arch, arch Arch, multiArch string, arches CrossArchitectures) bool {
+	all := arch.CPU == "all"
+	switch {
+	case qualifier == nil:
+		if all || multiArch == MultiArchForeign {
+			return all || arch.Matches(arches.Build)
+		}
+		return arch.Matches(arches.Host)
+	case qualifier.CPU == "any" && qualifier.OS == "any":
+		return multiArch == MultiArchAllowed && (all || arch.Matches(arches.Build))
+	case qualifier.IsNative():
+		return arch.Matches(arches.Build)
+	}
+	return arch.Is(qualifier)
+}
+
+// SatisfiedByCross is like SatisfiedBy, for the Build-Depends of a cross
+// build: the architecture qualifier, ":native" and ":any" included, is
+// checked along with the Multi-Arch value of the package, such as
+// "foreign", following the rules of "apt-get build-dep -a":
+//
+//   foo          foo of the host architecture, or of the build one when
+//                it's Multi-Arch: foreign or Architecture: all
+//   foo:any      a Multi-Arch: allowed foo of the build architecture
+//   foo:native   foo of the build architecture
+//   foo:arm64    foo of that architecture
+//
+// The target architecture only matters to the build of cross compilers,
+// not to their Build-Depends.
+func (possi Possibility) SatisfiedByCross(name string, ver version.Version, arch Arch, multiArch string, arches CrossArchitectures) (bool, Explanation) {
+	byName := possi
+	byName.Arch = nil
+	ok, ret := byName.SatisfiedBy(name, ver, arch)
+	ret.Possibility = possi
+	if !ok && ret.Clause != ClauseVersion {
+		return false, ret
+	}
+	if !crossArchSatisfied(possi.Arch, arch, multiArch, arches) {
+		ma := multiArch
+		if ma == "" {
+			ma = MultiArchNo
+		}
+		ret.Clause = ClauseArch
+		ret.Reason = fmt.Sprintf("%s is built for %s, with Multi-Arch: %s", name, arch, ma)
+		return false, ret
+	}
+	return ok, ret
+}
+
+// }}}
+
+// vim: foldmethod=marker
@@ -0,0 +1,149 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package dependency_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ebikt/go-debian/dependency"
+)
+
+/*
+ *
+ */
+
+func TestCrossArchitecturesEnviron(t *testing.T) {
+	arches, err := dependency.NewCrossArchitectures("amd64", "arm64")
+	isok(t, err)
+	assert(t, arches.IsCross())
+	assert(t, arches.Target == arches.Host)
+	environ, err := arches.Environ()
+	isok(t, err)
+	env := strings.Join(environ, "\n") + "\n"
+	for _, variable := range []string{
+		"DEB_BUILD_ARCH=amd64", "DEB_BUILD_GNU_TYPE=x86_64-linux-gnu",
+		"DEB_BUILD_MULTIARCH=x86_64-linux-gnu", "DEB_HOST_ARCH=arm64",
+		"DEB_HOST_ARCH_CPU=arm64", "DEB_HOST_ARCH_BITS=64", "DEB_HOST_ARCH_ENDIAN=little",
+		"DEB_HOST_GNU_CPU=aarch64", "DEB_HOST_GNU_SYSTEM=linux-gnu",
+		"DEB_TARGET_GNU_TYPE=aarch64-linux-gnu", "DEB_TARGET_ARCH_OS=linux",
+		"DEB_TARGET_ARCH_ABI=base", "DEB_TARGET_ARCH_LIBC=gnu",
+	} {
+		if !strings.Contains(env, variable+"\n") {
+			t.Errorf("Missing %s in %s", variable, env)
+		}
+	}
+	assert(t, len(environ) == 33)
+
+	native, err := dependency.NewCrossArchitectures("amd64", "amd64")
+	isok(t, err)
+	assert(t, !native.IsCross())
+
+	_, err = dependency.NewCrossArchitectures("amd64", "-")
+	notok(t, err)
+	native.Host.CPU = "unknown"
+	_, err = native.Environ()
+	notok(t, err)
+}
+
+func TestSatisfiedByCross(t *testing.T) {
+	arches, err := dependency.NewCrossArchitectures("amd64", "arm64")
+	isok(t, err)
+	check := func(relation, arch, multiArch string) bool {
+		dep, err := dependency.Parse(relation)
+		isok(t, err)
+		c := candidate(t, "foo", "1.0-1", arch)
+		ok, explanation := dep.Relations[0].Possibilities[0].SatisfiedByCross(c.Name, c.Version, c.Arch, multiArch, *arches)
+		assert(t, ok == explanation.Satisfied())
+		return ok
+	}
+	for _, test := range []struct {
+		relation, arch, multiArch string
+		expected                  bool
+	}{
+		{"foo", "arm64", "", true},
+		{"foo", "amd64", "", false},
+		{"foo", "amd64", "foreign", true},
+		{"foo", "arm64", "foreign", false},
+		{"foo", "all", "", true},
+		{"foo", "arm64", "same", true},
+		{"foo:any", "amd64", "allowed", true},
+		{"foo:any", "amd64", "foreign", false},
+		{"foo:any", "arm64", "allowed", false},
+		{"foo:native", "amd64", "", true},
+		{"foo:native", "arm64", "", false},
+		{"foo:arm64", "arm64", "", true},
+		{"foo:arm64", "amd64", "foreign", false},
+		{"foo (>= 2)", "arm64", "", false},
+		{"bar", "arm64", "", false},
+	} {
+		if check(test.relation, test.arch, test.multiArch) != test.expected {
+			t.Errorf("%s satisfied by foo:%s (Multi-Arch: %s) is not %t",
+				test.relation, test.arch, test.multiArch, test.expected)
+		}
+	}
+
+	dep, err := dependency.Parse("foo")
+	isok(t, err)
+	c := candidate(t, "foo", "1.0-1", "amd64")
+	_, explanation := dep.Relations[0].Possibilities[0].SatisfiedByCross(c.Name, c.Version, c.Arch, "", *arches)
+	assert(t, explanation.Clause == dependency.ClauseArch)
+	assert(t, explanation.Reason == "foo is built for amd64, with Multi-Arch: no")
+}
+
+func TestUniverseCross(t *testing.T) {
+	build, err := dependency.ParseArch("amd64")
+	isok(t, err)
+	host, err := dependency.ParseArch("arm64")
+	isok(t, err)
+	gcc := candidate(t, "gcc", "12", "amd64")
+	gcc.MultiArch = dependency.MultiArchForeign
+	python := candidate(t, "python3", "3.11", "amd64")
+	python.MultiArch = dependency.MultiArchAllowed
+	universe := dependency.Universe{
+		Arch:  *host,
+		Build: build,
+		Candidates: []dependency.Candidate{
+			gcc, python,
+			candidate(t, "libfoo-dev", "1.0", "amd64"),
+			candidate(t, "libfoo-dev", "1.0", "arm64"),
+			candidate(t, "pkg-config", "1.8", "amd64"),
+		},
+	}
+	dep, err := dependency.Parse("gcc, python3:any, libfoo-dev, pkg-config:native, libamd64 [amd64]")
+	isok(t, err)
+	evaluation := dep.SatisfiedBy(universe)
+	assert(t, evaluation.Satisfied())
+	assert(t, evaluation.Relations[2].SatisfiedBy.Arch.CPU == "arm64")
+
+	dep, err = dependency.Parse("pkg-config, python3")
+	isok(t, err)
+	evaluation = dep.SatisfiedBy(universe)
+	unsatisfied := evaluation.Unsatisfied()
+	assert(t, len(unsatisfied) == 2)
+	assert(t, unsatisfied[0].Explanations[0].Clause == dependency.ClauseArch)
+
+	/* Natively, anything of the right name does */
+	universe.Build = nil
+	assert(t, dep.SatisfiedBy(universe).Satisfied())
+}
+
+// vim: foldmethod=marker
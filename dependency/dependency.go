@@ -21,6 +21,8 @@
 package dependency // import "github.com/ebikt/go-debian/dependency"
 
 import (
+	"fmt"
+
 	"github.com/ebikt/go-debian/version"
 )
 
@@ -75,28 +77,33 @@ func (dep *Dependency) GetSubstvars() []Possibility {
 	return possies
 }
 
+// Check that this Dependency may be used as one of the Conflicts family of
+// fields (Conflicts, Breaks, Build-Conflicts, Build-Conflicts-Arch and
+// Build-Conflicts-Indep). Debian Policy, section 7.1, does not permit
+// alternatives in those, so every Relation must have exactly one
+// Possibility. Architecture qualified names (`foo:i386`) are fine.
+func (dep *Dependency) ValidateConflicts() error {
+	for _, relation := range dep.Relations {
+		if len(relation.Possibilities) > 1 {
+			return fmt.Errorf(
+				"Alternatives are not permitted in conflicts: '%s'",
+				relation.String(),
+			)
+		}
+	}
+	return nil
+}
+
+// SatisfiedBy checks if the given version satisfies the VersionRelation,
+// see version.SatisfiesRelation. Relations with a malformed version or an
+// unknown operator are never satisfied.
 func (v VersionRelation) SatisfiedBy(ver version.Version) bool {
 	vVer, err := version.Parse(v.Number)
 	if err != nil {
 		return false
 	}
-
-	q := version.Compare(ver, vVer)
-	switch v.Operator {
-	case ">=":
-		return q >= 0
-	case "<=":
-		return q <= 0
-	case ">>":
-		return q > 0
-	case "<<":
-		return q < 0
-	case "=":
-		return q == 0
-	}
-
-	// XXX: WHAT THE SHIT
-	return false
+	ok, err := version.SatisfiesRelation(ver, v.Operator, vVer)
+	return ok && err == nil
 }
 
 // vim: foldmethod=marker
@@ -57,6 +57,24 @@ func TestArchSliceParse(t *testing.T) {
 	assert(t, els[1].Name == "baz")
 }
 
+func TestArchSliceTuples(t *testing.T) {
+	dep, err := dependency.Parse("foo [any-arm], bar [!any-amd64] | baz")
+	isok(t, err)
+
+	arch, err := dependency.ParseArch("armhf")
+	isok(t, err)
+	els := dep.GetPossibilities(*arch)
+	assert(t, len(els) == 2)
+	assert(t, els[0].Name == "foo")
+	assert(t, els[1].Name == "bar")
+
+	arch, err = dependency.ParseArch("x32")
+	isok(t, err)
+	els = dep.GetPossibilities(*arch)
+	assert(t, len(els) == 1)
+	assert(t, els[0].Name == "baz")
+}
+
 func TestSliceAllParse(t *testing.T) {
 	dep, err := dependency.Parse("foo, bar | baz")
 	isok(t, err)
@@ -0,0 +1,141 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package dependency // import "github.com/ebikt/go-debian/dependency"
+
+import (
+	"encoding/json"
+)
+
+// Text {{{
+
+// MarshalText implements encoding.TextMarshaler, rendering the Dependency
+// the way it's written in a control file, such as "foo (>= 1.0), bar | baz".
+func (dep Dependency) MarshalText() ([]byte, error) {
+	return []byte(dep.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, parsing a Dependency
+// as written in a control file.
+func (dep *Dependency) UnmarshalText(text []byte) error {
+	ret := Dependency{}
+	if err := ret.UnmarshalControl(string(text)); err != nil {
+		return err
+	}
+	*dep = ret
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler, rendering the Arch in its
+// canonical form, such as "amd64"; the empty Arch is the empty string.
+func (a Arch) MarshalText() ([]byte, error) {
+	if a == (Arch{}) {
+		return []byte{}, nil
+	}
+	return []byte(a.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, parsing an Arch such
+// as "amd64" or "linux-any"; the empty string is the empty Arch.
+func (a *Arch) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		*a = Arch{}
+		return nil
+	}
+	arch, err := ParseArch(string(text))
+	if err != nil {
+		return err
+	}
+	*a = *arch
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler, rendering the
+// VersionRelation without its parentheses, such as ">= 1.0".
+func (version VersionRelation) MarshalText() ([]byte, error) {
+	return []byte(version.Operator + " " + version.Number), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, parsing a
+// VersionRelation such as ">= 1.0" or "(>= 1.0)", see ParseVersionRelation.
+func (version *VersionRelation) UnmarshalText(text []byte) error {
+	ret, err := ParseVersionRelation(string(text))
+	if err != nil {
+		return err
+	}
+	*version = *ret
+	return nil
+}
+
+// }}}
+
+// JSON {{{
+
+// unmarshalJSONString decodes a JSON string, telling whether it was null.
+func unmarshalJSONString(data []byte) (string, bool, error) {
+	if string(data) == "null" {
+		return "", true, nil
+	}
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return "", false, err
+	}
+	return str, false, nil
+}
+
+// MarshalJSON encodes the Dependency as a JSON string, the way it's
+// written in a control file, such as "foo (>= 1.0), bar | baz".
+func (dep Dependency) MarshalJSON() ([]byte, error) {
+	return json.Marshal(dep.String())
+}
+
+// UnmarshalJSON parses a Dependency from a JSON string. JSON null leaves
+// the Dependency untouched.
+func (dep *Dependency) UnmarshalJSON(data []byte) error {
+	str, null, err := unmarshalJSONString(data)
+	if err != nil || null {
+		return err
+	}
+	return dep.UnmarshalText([]byte(str))
+}
+
+// MarshalJSON encodes the Arch as a JSON string, in its canonical form
+// such as "amd64"; the empty Arch is the empty string.
+func (a Arch) MarshalJSON() ([]byte, error) {
+	text, err := a.MarshalText()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(string(text))
+}
+
+// UnmarshalJSON parses an Arch from a JSON string. JSON null leaves the
+// Arch untouched.
+func (a *Arch) UnmarshalJSON(data []byte) error {
+	str, null, err := unmarshalJSONString(data)
+	if err != nil || null {
+		return err
+	}
+	return a.UnmarshalText([]byte(str))
+}
+
+// }}}
+
+// vim: foldmethod=marker
@@ -0,0 +1,105 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package dependency_test
+
+import (
+	"encoding"
+	"encoding/json"
+	"testing"
+
+	"github.com/ebikt/go-debian/dependency"
+)
+
+/*
+ *
+ */
+
+func TestDependencyJSON(t *testing.T) {
+	type document struct {
+		Depends dependency.Dependency `json:"depends"`
+		Arch    dependency.Arch       `json:"arch"`
+		Target  *dependency.Arch      `json:"target,omitempty"`
+	}
+	dep, err := dependency.Parse("foo (= 1.0) [amd64], bar | baz:any")
+	isok(t, err)
+	arch, err := dependency.ParseArch("linux-any")
+	isok(t, err)
+
+	data, err := json.Marshal(document{Depends: *dep, Arch: *arch})
+	isok(t, err)
+	assert(t, string(data) == `{"depends":"foo (= 1.0) [amd64], bar | baz:any","arch":"linux-any"}`)
+
+	decoded := document{}
+	isok(t, json.Unmarshal(data, &decoded))
+	assert(t, len(decoded.Depends.Relations) == 2)
+	assert(t, decoded.Depends.String() == dep.String())
+	assert(t, decoded.Arch == *arch)
+	assert(t, decoded.Target == nil)
+
+	data, err = json.Marshal(document{})
+	isok(t, err)
+	assert(t, string(data) == `{"depends":"","arch":""}`)
+	isok(t, json.Unmarshal(data, &decoded))
+	assert(t, len(decoded.Depends.Relations) == 0)
+	assert(t, decoded.Arch == dependency.Arch{})
+
+	notok(t, json.Unmarshal([]byte(`{"depends":"foo (>> "}`), &decoded))
+	notok(t, json.Unmarshal([]byte(`{"arch":"a-b-c-d"}`), &decoded))
+	notok(t, json.Unmarshal([]byte(`{"arch":1}`), &decoded))
+}
+
+func TestDependencyText(t *testing.T) {
+	var _ encoding.TextUnmarshaler = &dependency.VersionRelation{}
+
+	relation := dependency.VersionRelation{}
+	isok(t, relation.UnmarshalText([]byte(">= 1.0-1")))
+	assert(t, relation.Operator == ">=" && relation.Number == "1.0-1")
+	text, err := relation.MarshalText()
+	isok(t, err)
+	assert(t, string(text) == ">= 1.0-1")
+
+	parsed, err := dependency.ParseVersionRelation(" (<<2.0) ")
+	isok(t, err)
+	assert(t, parsed.Operator == "<<" && parsed.Number == "2.0")
+	assert(t, parsed.String() == "(<< 2.0)")
+	for _, in := range []string{"", ">=", "~ 1.0", "(= 1.0) extra", "(= 1.0"} {
+		_, err := dependency.ParseVersionRelation(in)
+		notok(t, err)
+	}
+
+	dep := dependency.Dependency{}
+	isok(t, dep.UnmarshalText([]byte("foo | bar:any (>= 1.0)")))
+	text, err = dep.MarshalText()
+	isok(t, err)
+	assert(t, string(text) == "foo | bar:any (>= 1.0)")
+	notok(t, dep.UnmarshalText([]byte("foo (")))
+	assert(t, len(dep.Relations) == 1)
+
+	arch := dependency.Arch{}
+	isok(t, arch.UnmarshalText([]byte("arm64")))
+	text, err = arch.MarshalText()
+	isok(t, err)
+	assert(t, string(text) == "arm64")
+	isok(t, arch.UnmarshalText(nil))
+	assert(t, arch == dependency.Arch{})
+}
+
+// vim: foldmethod=marker
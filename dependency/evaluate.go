@@ -0,0 +1,269 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package dependency // import "github.com/ebikt/go-debian/dependency"
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ebikt/go-debian/version"
+)
+
+// Evaluation {{{
+
+// Clauses of a Possibility an Explanation may blame.
+const (
+	ClauseName         = "name"
+	ClauseArch         = "architecture qualifier"
+	ClauseVersion      = "version"
+	ClauseRestrictions = "architecture restrictions"
+	ClauseProfiles     = "build profiles"
+	ClauseSubstvar     = "substitution variable"
+)
+
+// An Explanation tells whether a Possibility holds, and if not, which of
+// its clauses doesn't.
+type Explanation struct {
+	Possibility Possibility
+
+	// The clause that doesn't hold, empty if the Possibility does.
+	Clause string
+
+	// Why, such as "only foo 1.0-1 is available".
+	Reason string
+}
+
+// Satisfied tells whether the Possibility holds.
+func (e Explanation) Satisfied() bool {
+	return e.Clause == ""
+}
+
+func (e Explanation) String() string {
+	if e.Satisfied() {
+		return e.Possibility.String() + ": satisfied"
+	}
+	return fmt.Sprintf("%s: %s (%s)", e.Possibility.String(), e.Reason, e.Clause)
+}
+
+// SatisfiedBy tells whether the package `name`, of the given version and
+// architecture, satisfies the Possibility: it must have the same name, an
+// architecture matching the qualifier if any (":any" matching all of
+// them), and a version within the constraint if any. Architecture
+// restriction lists and build profiles tell whether the Possibility
+// applies, not which packages satisfy it, and are left to
+// Dependency.SatisfiedBy. A ":native" qualifier has to be resolved first,
+// see Arch.ResolveNative. Multi-Arch is not taken into account, but by
+// SatisfiedByCross.
+func (possi Possibility) SatisfiedBy(name string, ver version.Version, arch Arch) (bool, Explanation) {
+	ret := Explanation{Possibility: possi}
+	switch {
+	case possi.Substvar:
+		ret.Clause, ret.Reason = ClauseSubstvar, "the variable is not expanded"
+	case possi.Name != name:
+		ret.Clause, ret.Reason = ClauseName, fmt.Sprintf("%s is another package", name)
+	case possi.Arch != nil && possi.Arch.CPU != "any" && !arch.Matches(*possi.Arch):
+		ret.Clause, ret.Reason = ClauseArch, fmt.Sprintf("%s is built for %s", name, arch)
+	case possi.Version != nil && !possi.Version.SatisfiedBy(ver):
+		ret.Clause, ret.Reason = ClauseVersion, fmt.Sprintf("%s is at version %s", name, ver)
+	}
+	return ret.Satisfied(), ret
+}
+
+// A Candidate is a package relations may be satisfied by.
+type Candidate struct {
+	Name    string
+	Version version.Version
+	Arch    Arch
+
+	// The Multi-Arch field of the package, only looked at for cross
+	// builds.
+	MultiArch string
+}
+
+// A Universe is what relations are evaluated against: a set of packages,
+// the architecture they're evaluated on, which architecture restriction
+// lists and ":native" qualifiers are checked against, and the build
+// profiles enabled.
+//
+// For the Build-Depends of a cross build, Build is the architecture of the
+// build machine, and Arch the host one, which the packages are built for:
+// architecture restrictions are checked against the host architecture,
+// ":native" qualifiers against the build one, and candidates have to be
+// of the right architecture for their Multi-Arch value, see
+// Possibility.SatisfiedByCross.
+type Universe struct {
+	Arch       Arch
+	Build      *Arch
+	Profiles   []string
+	Candidates []Candidate
+}
+
+// satisfiedBy tells whether the candidate satisfies the Possibility, with
+// its ":native" qualifier resolved unless it's a cross build.
+func (u Universe) satisfiedBy(possi Possibility, candidate Candidate) (bool, Explanation) {
+	if u.Build != nil {
+		arches := CrossArchitectures{Build: *u.Build, Host: u.Arch, Target: u.Arch}
+		return possi.SatisfiedByCross(candidate.Name, candidate.Version, candidate.Arch, candidate.MultiArch, arches)
+	}
+	return possi.SatisfiedBy(candidate.Name, candidate.Version, candidate.Arch)
+}
+
+// RelationEvaluation tells whether a Relation is satisfied, and why.
+type RelationEvaluation struct {
+	Relation Relation
+
+	// The package satisfying the relation, nil if none does.
+	SatisfiedBy *Candidate
+
+	// Why each Possibility doesn't hold, when the Relation is not
+	// satisfied, or doesn't apply, when none of its Possibilities do.
+	Explanations []Explanation
+}
+
+// Satisfied tells whether the relation is satisfied, which it is when one
+// of its possibilities holds, or none of them applies.
+func (r RelationEvaluation) Satisfied() bool {
+	if r.SatisfiedBy != nil {
+		return true
+	}
+	for _, explanation := range r.Explanations {
+		if explanation.Clause != ClauseRestrictions && explanation.Clause != ClauseProfiles {
+			return false
+		}
+	}
+	return true
+}
+
+// Evaluation is the outcome of Dependency.SatisfiedBy.
+type Evaluation struct {
+	Relations []RelationEvaluation
+}
+
+// Satisfied tells whether all of the relations are satisfied.
+func (e Evaluation) Satisfied() bool {
+	return len(e.Unsatisfied()) == 0
+}
+
+// Unsatisfied returns the relations that are not satisfied.
+func (e Evaluation) Unsatisfied() []RelationEvaluation {
+	ret := []RelationEvaluation{}
+	for _, relation := range e.Relations {
+		if !relation.Satisfied() {
+			ret = append(ret, relation)
+		}
+	}
+	return ret
+}
+
+func (e Evaluation) String() string {
+	lines := []string{}
+	for _, relation := range e.Unsatisfied() {
+		for _, explanation := range relation.Explanations {
+			lines = append(lines, explanation.String())
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// explain returns the Explanation of a Possibility that no package of the
+// Universe satisfies, blaming the clause of the closest candidate: one of
+// the right name if any, and of the right architecture if any.
+func (u Universe) explain(possi Possibility) Explanation {
+	ret := Explanation{Possibility: possi, Clause: ClauseName, Reason: "no such package"}
+	if possi.Substvar {
+		_, ret = possi.SatisfiedBy("", version.Version{}, u.Arch)
+		return ret
+	}
+	versions := []string{}
+	seen := map[string]bool{}
+	for _, candidate := range u.Candidates {
+		_, explanation := u.satisfiedBy(possi, candidate)
+		switch explanation.Clause {
+		case ClauseArch:
+			if ret.Clause == ClauseName {
+				ret = explanation
+			}
+		case ClauseVersion:
+			if ver := candidate.Version.String(); !seen[ver] {
+				seen[ver] = true
+				versions = append(versions, ver)
+			}
+		}
+	}
+	if len(versions) > 0 {
+		ret.Clause = ClauseVersion
+		ret.Reason = fmt.Sprintf("only %s %s is available", possi.Name, strings.Join(versions, ", "))
+	}
+	return ret
+}
+
+// SatisfiedBy evaluates each relation of the Dependency against the
+// packages of the Universe. Possibilities whose architecture restrictions
+// or build profiles don't match the Universe don't apply, and relations
+// with no Possibility applying are satisfied, as dpkg-checkbuilddeps
+// has it.
+func (dep Dependency) SatisfiedBy(universe Universe) Evaluation {
+	ret := Evaluation{Relations: []RelationEvaluation{}}
+	for _, relation := range dep.Relations {
+		evaluation := RelationEvaluation{Relation: relation, Explanations: []Explanation{}}
+		for _, possi := range relation.Possibilities {
+			if possi.Architectures != nil && !possi.Architectures.Matches(&universe.Arch) {
+				evaluation.Explanations = append(evaluation.Explanations, Explanation{
+					Possibility: possi,
+					Clause:      ClauseRestrictions,
+					Reason:      fmt.Sprintf("doesn't apply to %s", universe.Arch),
+				})
+				continue
+			}
+			if !possi.MatchesProfiles(universe.Profiles) {
+				evaluation.Explanations = append(evaluation.Explanations, Explanation{
+					Possibility: possi,
+					Clause:      ClauseProfiles,
+					Reason:      "doesn't apply to the build profiles",
+				})
+				continue
+			}
+			if possi.Arch != nil && universe.Build == nil {
+				arch := possi.Arch.ResolveNative(universe.Arch)
+				possi.Arch = &arch
+			}
+			for i, candidate := range universe.Candidates {
+				if ok, _ := universe.satisfiedBy(possi, candidate); ok {
+					evaluation.SatisfiedBy = &universe.Candidates[i]
+					break
+				}
+			}
+			if evaluation.SatisfiedBy != nil {
+				break
+			}
+			evaluation.Explanations = append(evaluation.Explanations, universe.explain(possi))
+		}
+		if evaluation.SatisfiedBy != nil {
+			evaluation.Explanations = []Explanation{}
+		}
+		ret.Relations = append(ret.Relations, evaluation)
+	}
+	return ret
+}
+
+// }}}
+
+// vim: foldmethod=marker
@@ -0,0 +1,100 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package dependency_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ebikt/go-debian/dependency"
+	"github.com/ebikt/go-debian/version"
+)
+
+/*
+ *
+ */
+
+func candidate(t *testing.T, name, ver, arch string) dependency.Candidate {
+	parsedVersion, err := version.Parse(ver)
+	isok(t, err)
+	parsedArch, err := dependency.ParseArch(arch)
+	isok(t, err)
+	return dependency.Candidate{Name: name, Version: parsedVersion, Arch: *parsedArch}
+}
+
+func TestPossibilitySatisfiedBy(t *testing.T) {
+	dep, err := dependency.Parse("foo (>= 1.0), foo:i386, foo:any")
+	isok(t, err)
+	foo := candidate(t, "foo", "1.2-1", "amd64")
+
+	ok, explanation := dep.Relations[0].Possibilities[0].SatisfiedBy(foo.Name, foo.Version, foo.Arch)
+	assert(t, ok && explanation.Satisfied())
+	ok, explanation = dep.Relations[0].Possibilities[0].SatisfiedBy("bar", foo.Version, foo.Arch)
+	assert(t, !ok && explanation.Clause == dependency.ClauseName)
+	ok, explanation = dep.Relations[0].Possibilities[0].SatisfiedBy(foo.Name, version.Version{Version: "0.9"}, foo.Arch)
+	assert(t, !ok && explanation.Clause == dependency.ClauseVersion)
+	ok, explanation = dep.Relations[1].Possibilities[0].SatisfiedBy(foo.Name, foo.Version, foo.Arch)
+	assert(t, !ok && explanation.Clause == dependency.ClauseArch)
+	assert(t, explanation.String() == "foo:i386: foo is built for amd64 (architecture qualifier)")
+	ok, _ = dep.Relations[2].Possibilities[0].SatisfiedBy(foo.Name, foo.Version, foo.Arch)
+	assert(t, ok)
+}
+
+func TestDependencySatisfiedBy(t *testing.T) {
+	amd64, err := dependency.ParseArch("amd64")
+	isok(t, err)
+	universe := dependency.Universe{
+		Arch: *amd64,
+		Candidates: []dependency.Candidate{
+			candidate(t, "libc6", "2.36-9", "amd64"),
+			candidate(t, "libc6", "2.36-9", "i386"),
+			candidate(t, "gcc", "12.2.0-14", "amd64"),
+			candidate(t, "python3", "3.11.2-1", "amd64"),
+		},
+	}
+
+	dep, err := dependency.Parse("libc6 (>= 2.34), gcc:native, missing | python3, " +
+		"libfoo [arm64], pytest <!nocheck> | python3:any")
+	isok(t, err)
+	evaluation := dep.SatisfiedBy(universe)
+	assert(t, evaluation.Satisfied())
+	assert(t, evaluation.Relations[0].SatisfiedBy.Arch.String() == "amd64")
+	assert(t, evaluation.Relations[2].SatisfiedBy.Name == "python3")
+	assert(t, evaluation.Relations[3].SatisfiedBy == nil)
+	assert(t, evaluation.Relations[3].Explanations[0].Clause == dependency.ClauseRestrictions)
+
+	dep, err = dependency.Parse("libc6 (>= 2.40), libc6:arm64, missing, pytest <!nocheck>")
+	isok(t, err)
+	evaluation = dep.SatisfiedBy(universe)
+	assert(t, !evaluation.Satisfied())
+	unsatisfied := evaluation.Unsatisfied()
+	assert(t, len(unsatisfied) == 4)
+	assert(t, unsatisfied[0].Explanations[0].Clause == dependency.ClauseVersion)
+	assert(t, strings.Contains(unsatisfied[0].Explanations[0].Reason, "only libc6 2.36-9 is available"))
+	assert(t, unsatisfied[1].Explanations[0].Clause == dependency.ClauseArch)
+	assert(t, unsatisfied[2].Explanations[0].Clause == dependency.ClauseName)
+
+	universe.Profiles = []string{"nocheck"}
+	evaluation = dep.SatisfiedBy(universe)
+	assert(t, len(evaluation.Unsatisfied()) == 3)
+}
+
+// vim: foldmethod=marker
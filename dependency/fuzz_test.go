@@ -0,0 +1,64 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package dependency_test
+
+import (
+	"testing"
+
+	"github.com/ebikt/go-debian/dependency"
+)
+
+/*
+ *
+ */
+
+func FuzzParseDependency(f *testing.F) {
+	for _, seed := range []string{
+		/* Taken from bookworm's Packages and Sources indices */
+		"libc6 (>= 2.34), libssl3 (>= 3.0.0)",
+		"debconf (>= 0.5) | debconf-2.0",
+		"perl:any, libfile-fcntllock-perl",
+		"debhelper-compat (= 13), dh-python, python3-all:native <!nocheck>",
+		"gcc-12 [!hurd-i386 !kfreebsd-any], libc6-dev [linux-any] | libc6.1-dev [alpha ia64]",
+		"libgtk-3-dev <!stage1 !nogui>, libtool <cross>",
+		"${misc:Depends}, ${shlibs:Depends}",
+		"foo(>=1.0),bar:i386(<<2)[amd64]<!nocheck>",
+	} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, in string) {
+		dep, err := dependency.Parse(in)
+		if err != nil {
+			return
+		}
+		out := dep.String()
+		rtDep, err := dependency.Parse(out)
+		if err != nil {
+			t.Fatalf("Can't parse %q, written out from %q: %s", out, in, err)
+		}
+		if rtDep.String() != out {
+			t.Fatalf("%q doesn't round trip: %q != %q", in, rtDep.String(), out)
+		}
+	})
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,169 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package dependency // import "github.com/ebikt/go-debian/dependency"
+
+import (
+	"fmt"
+	"strings"
+)
+
+// GoTarget {{{
+
+// GoTarget is the set of Go build environment variables that produce
+// binaries for a Debian architecture. The optional variables (GOARM,
+// GO386, GOAMD64, GOPPC64, GOMIPS, GOMIPS64) are only set when they
+// matter for that architecture, to the baseline Debian builds for.
+type GoTarget struct {
+	GOOS     string
+	GOARCH   string
+	GOARM    string
+	GO386    string
+	GOAMD64  string
+	GOPPC64  string
+	GOMIPS   string
+	GOMIPS64 string
+}
+
+// Environ returns the GoTarget as a list of "KEY=value" strings, suitable
+// to be appended to the Env of an exec.Cmd running `go build`.
+func (t GoTarget) Environ() []string {
+	ret := []string{}
+	for _, el := range []struct{ key, value string }{
+		{"GOOS", t.GOOS},
+		{"GOARCH", t.GOARCH},
+		{"GOARM", t.GOARM},
+		{"GO386", t.GO386},
+		{"GOAMD64", t.GOAMD64},
+		{"GOPPC64", t.GOPPC64},
+		{"GOMIPS", t.GOMIPS},
+		{"GOMIPS64", t.GOMIPS64},
+	} {
+		if el.value != "" {
+			ret = append(ret, el.key+"="+el.value)
+		}
+	}
+	return ret
+}
+
+func (t GoTarget) String() string {
+	return strings.Join(t.Environ(), " ")
+}
+
+// goTargets maps the Debian CPU names to Go's. i386 is built for the i686
+// without SSE2, armel for ARMv5T, armhf for ARMv7 with VFPv3-D16, and amd64
+// for the original x86-64, so those get the matching baseline.
+var goTargets = []struct {
+	CPU    string
+	Target GoTarget
+}{
+	{"amd64", GoTarget{GOARCH: "amd64", GOAMD64: "v1"}},
+	{"i386", GoTarget{GOARCH: "386", GO386: "softfloat"}},
+	{"arm64", GoTarget{GOARCH: "arm64"}},
+	{"armhf", GoTarget{GOARCH: "arm", GOARM: "7"}},
+	{"armel", GoTarget{GOARCH: "arm", GOARM: "5"}},
+	{"ppc64el", GoTarget{GOARCH: "ppc64le", GOPPC64: "power8"}},
+	{"ppc64", GoTarget{GOARCH: "ppc64", GOPPC64: "power8"}},
+	{"riscv64", GoTarget{GOARCH: "riscv64"}},
+	{"s390x", GoTarget{GOARCH: "s390x"}},
+	{"loong64", GoTarget{GOARCH: "loong64"}},
+	{"mips64el", GoTarget{GOARCH: "mips64le", GOMIPS64: "hardfloat"}},
+	{"mipsel", GoTarget{GOARCH: "mipsle", GOMIPS: "hardfloat"}},
+	{"mips", GoTarget{GOARCH: "mips", GOMIPS: "hardfloat"}},
+}
+
+// goOSes maps the Debian OS names to Go's.
+var goOSes = map[string]string{
+	"linux":    "linux",
+	"kfreebsd": "freebsd",
+}
+
+// }}}
+
+// Arch to Go {{{
+
+// GoTarget returns the Go build environment that targets this Arch, such
+// as GOOS=linux GOARCH=arm GOARM=7 for armhf. Wildcards, `all`, and
+// architectures Go can't build for (such as x32 or the Hurd) are errors.
+func (arch Arch) GoTarget() (*GoTarget, error) {
+	if arch.IsWildcard() || arch.CPU == "all" {
+		return nil, fmt.Errorf("Can't build Go for the non-concrete Arch %s", arch)
+	}
+	goos, ok := goOSes[arch.OS]
+	if !ok || arch.ABI != "gnu" {
+		return nil, fmt.Errorf("Go can't target the Arch %s", arch)
+	}
+	for _, el := range goTargets {
+		if el.CPU == arch.CPU {
+			target := el.Target
+			target.GOOS = goos
+			return &target, nil
+		}
+	}
+	return nil, fmt.Errorf("Go can't target the Arch %s", arch)
+}
+
+// }}}
+
+// Go to Arch {{{
+
+// ArchForGo returns the Debian Arch binaries built with the given Go
+// environment run on. `goarm` is only looked at for GOARCH=arm, where
+// ARMv5 builds map to armel, and ARMv6 or newer to armhf; an empty value
+// is Go's default of 7. Any ",softfloat" or ",hardfloat" suffix is ignored.
+func ArchForGo(goos, goarch, goarm string) (*Arch, error) {
+	os := ""
+	for debian, golang := range goOSes {
+		if golang == goos {
+			os = debian
+		}
+	}
+	if os == "" {
+		return nil, fmt.Errorf("No Debian Arch for GOOS=%s", goos)
+	}
+
+	cpu := ""
+	if goarch == "arm" {
+		switch strings.SplitN(goarm, ",", 2)[0] {
+		case "5":
+			cpu = "armel"
+		case "", "6", "7":
+			cpu = "armhf"
+		default:
+			return nil, fmt.Errorf("No Debian Arch for GOARM=%s", goarm)
+		}
+	} else {
+		for _, el := range goTargets {
+			if el.Target.GOARCH == goarch {
+				cpu = el.CPU
+				break
+			}
+		}
+	}
+	if cpu == "" {
+		return nil, fmt.Errorf("No Debian Arch for GOARCH=%s", goarch)
+	}
+
+	return &Arch{ABI: "gnu", OS: os, CPU: cpu}, nil
+}
+
+// }}}
+
+// vim: foldmethod=marker
@@ -0,0 +1,81 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package dependency_test
+
+import (
+	"testing"
+
+	"github.com/ebikt/go-debian/dependency"
+)
+
+/*
+ *
+ */
+
+func TestArchGoTarget(t *testing.T) {
+	for arch, env := range map[string]string{
+		"amd64":          "GOOS=linux GOARCH=amd64 GOAMD64=v1",
+		"i386":           "GOOS=linux GOARCH=386 GO386=softfloat",
+		"armhf":          "GOOS=linux GOARCH=arm GOARM=7",
+		"armel":          "GOOS=linux GOARCH=arm GOARM=5",
+		"ppc64el":        "GOOS=linux GOARCH=ppc64le GOPPC64=power8",
+		"riscv64":        "GOOS=linux GOARCH=riscv64",
+		"kfreebsd-amd64": "GOOS=freebsd GOARCH=amd64 GOAMD64=v1",
+	} {
+		parsed, err := dependency.ParseArch(arch)
+		isok(t, err)
+		target, err := parsed.GoTarget()
+		isok(t, err)
+		assert(t, target.String() == env)
+	}
+
+	for _, arch := range []string{"any", "all", "linux-any", "x32", "hurd-i386"} {
+		parsed, err := dependency.ParseArch(arch)
+		isok(t, err)
+		_, err = parsed.GoTarget()
+		notok(t, err)
+	}
+}
+
+func TestArchForGo(t *testing.T) {
+	for _, el := range []struct{ goos, goarch, goarm, arch string }{
+		{"linux", "amd64", "", "amd64"},
+		{"linux", "arm", "", "armhf"},
+		{"linux", "arm", "6", "armhf"},
+		{"linux", "arm", "5,softfloat", "armel"},
+		{"linux", "ppc64le", "", "ppc64el"},
+		{"linux", "mips64le", "", "mips64el"},
+		{"freebsd", "386", "", "kfreebsd-i386"},
+	} {
+		arch, err := dependency.ArchForGo(el.goos, el.goarch, el.goarm)
+		isok(t, err)
+		assert(t, arch.String() == el.arch)
+	}
+
+	_, err := dependency.ArchForGo("darwin", "arm64", "")
+	notok(t, err)
+	_, err = dependency.ArchForGo("linux", "wasm", "")
+	notok(t, err)
+	_, err = dependency.ArchForGo("linux", "arm", "8")
+	notok(t, err)
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,96 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package dependency // import "github.com/ebikt/go-debian/dependency"
+
+import (
+	"strings"
+
+	"github.com/ebikt/go-debian/version"
+)
+
+// PossibilityKey is a compact, comparable form of a Possibility, made of
+// its name, architecture qualifier and version constraint. It's meant to be
+// used as a map key by resolvers and de-duplication passes, which would
+// otherwise have to call Possibility.String() in a loop.
+//
+// Two Possibilities which only differ in the way they are spelled, such
+// as `foo (>= 0:1.0)` and `foo (>=1.0)`, have the same key. Architecture
+// restriction lists and build profiles are not part of the key.
+type PossibilityKey struct {
+	Name     string
+	Arch     Arch
+	Operator string
+	Version  version.Version
+	Substvar bool
+}
+
+// Return the PossibilityKey of this Possibility.
+func (possi Possibility) Key() PossibilityKey {
+	key := PossibilityKey{
+		Name:     possi.Name,
+		Substvar: possi.Substvar,
+	}
+	if possi.Arch != nil {
+		key.Arch = *possi.Arch
+	}
+	if possi.Version != nil {
+		key.Operator = possi.Version.Operator
+		number := strings.TrimSpace(possi.Version.Number)
+		if ver, err := version.Parse(number); err == nil {
+			key.Version = ver
+		} else {
+			/* Keep whatever we've got, so that two different broken
+			 * versions don't wind up with the same key. */
+			key.Version = version.Version{Version: number}
+		}
+	}
+	return key
+}
+
+// Return the Possibility this key was made from, in its canonical form.
+func (key PossibilityKey) Possibility() Possibility {
+	possi := Possibility{
+		Name:          key.Name,
+		Architectures: &ArchSet{Architectures: []Arch{}},
+		StageSets:     []StageSet{},
+		Substvar:      key.Substvar,
+	}
+	if key.Arch != (Arch{}) {
+		arch := key.Arch
+		possi.Arch = &arch
+	}
+	if key.Operator != "" {
+		possi.Version = &VersionRelation{
+			Operator: key.Operator,
+			Number:   key.Version.String(),
+		}
+	}
+	return possi
+}
+
+func (key PossibilityKey) String() string {
+	if key.Substvar {
+		return "${" + key.Name + "}"
+	}
+	return key.Possibility().String()
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,61 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package dependency_test
+
+import (
+	"testing"
+
+	"github.com/ebikt/go-debian/dependency"
+)
+
+/*
+ *
+ */
+
+func TestPossibilityKey(t *testing.T) {
+	dep, err := dependency.Parse("foo (>= 0:1.0), foo (>=1.0) [amd64], foo:i386 (>= 1.0), foo (>> 1.0), foo, ${foo}")
+	isok(t, err)
+
+	keys := map[dependency.PossibilityKey]int{}
+	for _, possi := range dep.GetAllPossibilities() {
+		keys[possi.Key()]++
+	}
+	for _, possi := range dep.GetSubstvars() {
+		keys[possi.Key()]++
+	}
+
+	assert(t, len(keys) == 5)
+	assert(t, keys[dep.Relations[0].Possibilities[0].Key()] == 2)
+	assert(t, keys[dep.Relations[2].Possibilities[0].Key()] == 1)
+}
+
+func TestPossibilityKeyString(t *testing.T) {
+	dep, err := dependency.Parse("foo:armhf (>= 0:1.0-1) [amd64] <!nocheck>, ${misc:Depends}")
+	isok(t, err)
+
+	key := dep.Relations[0].Possibilities[0].Key()
+	assert(t, key.String() == "foo:armhf (>= 1.0-1)")
+	assert(t, key.Possibility().Key() == key)
+
+	assert(t, dep.Relations[1].Possibilities[0].Key().String() == "${misc:Depends}")
+}
+
+// vim: foldmethod=marker
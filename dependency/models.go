@@ -43,11 +43,17 @@ type VersionRelation struct {
 	Operator string
 }
 
+// Stage is a term of a build profile restriction list, such as "!nocheck",
+// which holds when the profile is enabled, or disabled if negated.
 type Stage struct {
 	Not  bool
 	Name string
 }
 
+// StageSet is a build profile restriction list, such as
+// `<!nocheck cross>`, which holds when all its Stages do. The StageSets of
+// a Possibility are its restriction formula, one per angle bracket group,
+// which holds when any of them does, see MatchesProfiles.
 type StageSet struct {
 	Stages []Stage
 }
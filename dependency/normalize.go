@@ -0,0 +1,223 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package dependency // import "github.com/ebikt/go-debian/dependency"
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/ebikt/go-debian/version"
+)
+
+// Normalization {{{
+
+// normalizePossibility returns a copy of the Possibility in its canonical
+// form: no surrounding whitespace, versions spelled canonically, and empty
+// restrictions dropped.
+func normalizePossibility(possi Possibility) Possibility {
+	ret := Possibility{Name: strings.TrimSpace(possi.Name), Substvar: possi.Substvar}
+	if possi.Arch != nil {
+		arch := *possi.Arch
+		ret.Arch = &arch
+	}
+	if possi.Version != nil {
+		relation := VersionRelation{
+			Operator: strings.TrimSpace(possi.Version.Operator),
+			Number:   strings.TrimSpace(possi.Version.Number),
+		}
+		if ver, err := version.Parse(relation.Number); err == nil {
+			relation.Number = ver.String()
+		}
+		ret.Version = &relation
+	}
+	if possi.Architectures != nil && len(possi.Architectures.Architectures) > 0 {
+		ret.Architectures = possi.Architectures.copy()
+	}
+	for _, stageSet := range possi.StageSets {
+		if len(stageSet.Stages) > 0 {
+			ret.StageSets = append(ret.StageSets, StageSet{
+				Stages: append([]Stage{}, stageSet.Stages...),
+			})
+		}
+	}
+	return ret
+}
+
+// relationSortKey orders relations the way wrap-and-sort does: by the name
+// of their first possibility, with the substitution variables last.
+func relationSortKey(relation Relation) string {
+	if len(relation.Possibilities) == 0 {
+		return ""
+	}
+	first := relation.Possibilities[0]
+	if first.Substvar {
+		return "~" + relation.String()
+	}
+	return relation.String()
+}
+
+// Normalize returns the Dependency in a canonical form: possibilities are
+// spelled canonically, duplicate possibilities of a relation and duplicate
+// relations are merged, and relations are sorted by name, with the
+// substitution variables last. The order of the alternatives of a relation
+// is kept, as it's meaningful.
+func (dep Dependency) Normalize() Dependency {
+	ret := Dependency{Relations: []Relation{}}
+	seenRelations := map[string]bool{}
+	for _, relation := range dep.Relations {
+		normalized := Relation{Possibilities: []Possibility{}}
+		seen := map[string]bool{}
+		for _, possi := range relation.Possibilities {
+			possi = normalizePossibility(possi)
+			if key := possi.String(); !seen[key] {
+				seen[key] = true
+				normalized.Possibilities = append(normalized.Possibilities, possi)
+			}
+		}
+		if len(normalized.Possibilities) == 0 {
+			continue
+		}
+		if key := normalized.String(); !seenRelations[key] {
+			seenRelations[key] = true
+			ret.Relations = append(ret.Relations, normalized)
+		}
+	}
+	sort.SliceStable(ret.Relations, func(i, j int) bool {
+		return relationSortKey(ret.Relations[i]) < relationSortKey(ret.Relations[j])
+	})
+	return ret
+}
+
+// }}}
+
+// Simplification {{{
+
+// restrictions returns what, besides its version constraint, tells what
+// a Possibility is about: its name, qualifier and restrictions.
+func (possi Possibility) restrictions() string {
+	unversioned := possi
+	unversioned.Version = nil
+	return unversioned.String()
+}
+
+// Implies tells whether any package satisfying the Possibility satisfies
+// the other one too, such as "foo (>= 1.2)" does "foo" and "foo (>= 1.0)".
+// Both must be about the same package, with the same architecture
+// qualifier and restrictions. Substitution variables only imply
+// themselves.
+func (possi Possibility) Implies(other Possibility) bool {
+	if possi.Substvar || other.Substvar {
+		return possi.Substvar && other.Substvar && possi.Name == other.Name
+	}
+	if possi.restrictions() != other.restrictions() {
+		return false
+	}
+	if other.Version == nil {
+		return true
+	}
+	if possi.Version == nil {
+		return false
+	}
+	ours, err := version.Parse(strings.TrimSpace(possi.Version.Number))
+	if err != nil {
+		return false
+	}
+	theirs, err := version.Parse(strings.TrimSpace(other.Version.Number))
+	if err != nil {
+		return false
+	}
+	cmp := version.Compare(ours, theirs)
+
+	switch op := possi.Version.Operator; other.Version.Operator {
+	case ">=":
+		return (op == "=" || op == ">=" || op == ">>") && cmp >= 0
+	case ">>":
+		return ((op == "=" || op == ">=") && cmp > 0) || (op == ">>" && cmp >= 0)
+	case "<=":
+		return (op == "=" || op == "<=" || op == "<<") && cmp <= 0
+	case "<<":
+		return ((op == "=" || op == "<=") && cmp < 0) || (op == "<<" && cmp <= 0)
+	case "=":
+		return op == "=" && cmp == 0
+	}
+	return false
+}
+
+// Implies tells whether satisfying the Relation satisfies the other one
+// too, which is when each of its possibilities implies one of the other.
+func (relation Relation) Implies(other Relation) bool {
+	for _, possi := range relation.Possibilities {
+		implied := false
+		for _, candidate := range other.Possibilities {
+			if possi.Implies(candidate) {
+				implied = true
+				break
+			}
+		}
+		if !implied {
+			return false
+		}
+	}
+	return len(relation.Possibilities) > 0
+}
+
+// Simplify returns the Normalized Dependency, without what's redundant:
+// the relations implied by stricter ones, so that "foo, foo (>= 1.2)"
+// becomes "foo (>= 1.2)", and the alternatives implying another one of
+// their relation, so that "foo (>= 1.2) | foo" becomes "foo".
+func (dep Dependency) Simplify() Dependency {
+	dep = dep.Normalize()
+	for i, relation := range dep.Relations {
+		possibilities := []Possibility{}
+		for j, possi := range relation.Possibilities {
+			redundant := false
+			for k, other := range relation.Possibilities {
+				if j != k && possi.Implies(other) && (!other.Implies(possi) || k < j) {
+					redundant = true
+					break
+				}
+			}
+			if !redundant {
+				possibilities = append(possibilities, possi)
+			}
+		}
+		dep.Relations[i].Possibilities = possibilities
+	}
+
+	ret := Dependency{Relations: []Relation{}}
+	for i, relation := range dep.Relations {
+		redundant := false
+		for j, other := range dep.Relations {
+			if i != j && other.Implies(relation) && (!relation.Implies(other) || j < i) {
+				redundant = true
+				break
+			}
+		}
+		if !redundant {
+			ret.Relations = append(ret.Relations, relation)
+		}
+	}
+	return ret
+}
+
+// }}}
+
+// vim: foldmethod=marker
@@ -0,0 +1,85 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package dependency_test
+
+import (
+	"testing"
+
+	"github.com/ebikt/go-debian/dependency"
+)
+
+/*
+ *
+ */
+
+func normalized(t *testing.T, in string) string {
+	dep, err := dependency.Parse(in)
+	isok(t, err)
+	return dep.Normalize().String()
+}
+
+func simplified(t *testing.T, in string) string {
+	dep, err := dependency.Parse(in)
+	isok(t, err)
+	return dep.Simplify().String()
+}
+
+func TestNormalize(t *testing.T) {
+	assert(t, normalized(t, "${misc:Depends},  zlib1g (>=1:1.2.0) ,libc6 (>=2.34)") ==
+		"libc6 (>= 2.34), zlib1g (>= 1:1.2.0), ${misc:Depends}")
+	assert(t, normalized(t, "foo | bar | foo, baz, foo|bar") == "baz, foo | bar")
+	assert(t, normalized(t, "foo (>= 0:1.0), foo (>= 1.0)") == "foo (>= 1.0)")
+	assert(t, normalized(t, "b | a, a [amd64] <!nocheck>") == "a [amd64] <!nocheck>, b | a")
+}
+
+func TestSimplify(t *testing.T) {
+	assert(t, simplified(t, "foo, foo (>= 1.2)") == "foo (>= 1.2)")
+	assert(t, simplified(t, "foo (>= 1.0), bar, foo (>= 1.2)") == "bar, foo (>= 1.2)")
+	assert(t, simplified(t, "foo (>> 1.0), foo (= 1.2)") == "foo (= 1.2)")
+	assert(t, simplified(t, "foo (<< 2.0), foo (<= 1.5)") == "foo (<= 1.5)")
+	assert(t, simplified(t, "foo (>= 1.2) | foo, bar") == "bar, foo")
+	assert(t, simplified(t, "foo | bar, foo") == "foo")
+
+	/* Different packages or restrictions don't imply each other */
+	assert(t, simplified(t, "foo, foo:any (>= 1.0)") == "foo, foo:any (>= 1.0)")
+	assert(t, simplified(t, "foo (>= 1.0), foo (<< 2.0)") == "foo (<< 2.0), foo (>= 1.0)")
+	assert(t, simplified(t, "foo [amd64], foo (>= 1.0)") == "foo (>= 1.0), foo [amd64]")
+	assert(t, simplified(t, "${a}, ${a} | foo") == "${a}")
+}
+
+func TestImplies(t *testing.T) {
+	dep, err := dependency.Parse("foo (= 1.2), foo (>> 1.2), foo (>= 1.2), foo (<< 1.2), foo (>= 1.0)")
+	isok(t, err)
+	eq := dep.Relations[0].Possibilities[0]
+	gt := dep.Relations[1].Possibilities[0]
+	ge := dep.Relations[2].Possibilities[0]
+	lt := dep.Relations[3].Possibilities[0]
+	older := dep.Relations[4].Possibilities[0]
+
+	assert(t, eq.Implies(ge))
+	assert(t, !eq.Implies(gt))
+	assert(t, gt.Implies(ge))
+	assert(t, !ge.Implies(gt))
+	assert(t, !lt.Implies(ge))
+	assert(t, ge.Implies(older))
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,162 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package dependency // import "github.com/ebikt/go-debian/dependency"
+
+import (
+	"fmt"
+
+	"github.com/ebikt/go-debian/version"
+)
+
+// ParseOptions {{{
+
+// ParseOptions control how forgiving ParseWithOptions is about syntax
+// that turns up in real-world control files.
+//
+// In Strict mode, anything dpkg would refuse is an error: empty relations
+// (as left behind by stray or trailing commas), empty alternatives, package
+// names that aren't valid Debian package names and malformed versions.
+//
+// Otherwise, all of those are recovered from and reported as warnings, as
+// are architecture qualifiers on substvars ("${foo:Depends}:any"), which
+// are dropped. This lets archive-scanning tools carry on past a single
+// bad stanza, and still tell someone about it.
+//
+// Substvars themselves are accepted in either mode, since they're an
+// ordinary part of debian/control.
+//
+// The limits, when not zero, bound the work done on untrusted input.
+// Parsing takes time linear in the size of the input whatever it holds,
+// and relations don't nest (brackets can't be opened inside one another,
+// and an unclosed one is an error as soon as the input runs out), so
+// bounding the length and the number of relations and alternatives bounds
+// the size of the resulting Dependency.
+type ParseOptions struct {
+	Strict bool
+
+	// The longest input accepted, in bytes.
+	MaxLength int
+
+	// The most Relations accepted, that is, comma separated entries.
+	MaxRelations int
+
+	// The most Possibilities accepted in a single Relation, that is, '|'
+	// separated alternatives.
+	MaxAlternatives int
+}
+
+// DefaultParseLimits are ParseOptions with limits generous enough for any
+// dependency field found in the Debian archive, and small enough for
+// parsing untrusted uploads.
+var DefaultParseLimits = ParseOptions{
+	MaxLength:       64 * 1024,
+	MaxRelations:    1024,
+	MaxAlternatives: 64,
+}
+
+// ParseWarning describes sloppy syntax that was recovered from while
+// parsing a Dependency in lenient mode.
+type ParseWarning struct {
+	// Byte offset into the parsed string where the problem starts.
+	Offset int
+
+	// The token found at Offset, as with ParseError.
+	Token string
+
+	Message string
+}
+
+// String returns the warning, along with where it was found.
+func (w ParseWarning) String() string {
+	return fmt.Sprintf("offset %d: %s", w.Offset, w.Message)
+}
+
+// Parse a string into a Dependency object, as Parse does, according to
+// the given ParseOptions. Any warnings raised in lenient mode are returned
+// along with the Dependency; in Strict mode there never are any.
+func ParseWithOptions(in string, opts ParseOptions) (*Dependency, []ParseWarning, error) {
+	ibuf := input{
+		Index:           0,
+		Data:            in,
+		strict:          opts.Strict,
+		lenient:         !opts.Strict,
+		maxRelations:    opts.MaxRelations,
+		maxAlternatives: opts.MaxAlternatives,
+	}
+	if opts.MaxLength > 0 && len(in) > opts.MaxLength {
+		return nil, nil, ibuf.errorf(opts.MaxLength,
+			"Dependency is longer than the limit of %d bytes", opts.MaxLength)
+	}
+	dep := &Dependency{Relations: []Relation{}}
+	err := parseDependency(&ibuf, dep)
+	if err != nil {
+		return nil, nil, withRelations(err, dep)
+	}
+	return dep, ibuf.warnings, nil
+}
+
+// }}}
+
+// Checks {{{
+
+/* Check a freshly parsed Possibility against what dpkg would accept. Plain
+ * Parse skips this entirely. */
+func checkPossibility(input *input, start int, possi *Possibility) error {
+	if !input.strict && !input.lenient {
+		return nil
+	}
+	if !validPackageName(possi.Name) {
+		if err := input.sloppy(start, "Invalid package name: '%s'", possi.Name); err != nil {
+			return err
+		}
+	}
+	if possi.Version != nil {
+		if _, err := version.Parse(possi.Version.Number); err != nil {
+			if err := input.sloppy(start, "Invalid version in relation on '%s': %s", possi.Name, err); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+/* Package names must consist only of lower case letters, digits and the
+ * characters '+', '-' and '.'. They must be at least two characters long
+ * and start with an alphanumeric character. */
+func validPackageName(name string) bool {
+	if len(name) < 2 {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		switch {
+		case c >= 'a' && c <= 'z', c >= '0' && c <= '9':
+		case i > 0 && (c == '+' || c == '-' || c == '.'):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// }}}
+
+// vim: foldmethod=marker
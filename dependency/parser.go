@@ -21,8 +21,8 @@
 package dependency // import "github.com/ebikt/go-debian/dependency"
 
 import (
-	"errors"
 	"fmt"
+	"strings"
 )
 
 // Parse a string into a Dependency object. The input should look something
@@ -31,12 +31,95 @@ func Parse(in string) (*Dependency, error) {
 	ibuf := input{Index: 0, Data: in}
 	dep := &Dependency{Relations: []Relation{}}
 	err := parseDependency(&ibuf, dep)
+	if err != nil {
+		return nil, withRelations(err, dep)
+	}
+	return dep, nil
+}
+
+// Parse a byte slice into a Dependency object, as Parse does. This saves
+// converting fields read as bytes, such as those of a Packages file, to
+// strings first: the one copy made is shared by all the strings of the
+// Dependency, which takes a handful of allocations no matter how many
+// Relations it has.
+func ParseBytes(in []byte) (*Dependency, error) {
+	return Parse(string(in))
+}
+
+// Parse a string into a Dependency object, enforcing the restrictions of
+// the Conflicts family of fields (Conflicts, Breaks, Build-Conflicts,
+// Build-Conflicts-Arch and Build-Conflicts-Indep), which don't allow
+// alternatives. The input should look something like "foo, bar:i386 (<< 2.0)".
+func ParseConflicts(in string) (*Dependency, error) {
+	dep, err := Parse(in)
 	if err != nil {
 		return nil, err
 	}
+	if err := dep.ValidateConflicts(); err != nil {
+		return nil, err
+	}
 	return dep, nil
 }
 
+// Parse a version restriction, such as ">= 1.0" or "(<< 2.0)", the way it
+// follows the name of a package in a relation.
+func ParseVersionRelation(in string) (*VersionRelation, error) {
+	data := strings.TrimSpace(in)
+	/* Offsets in errors should point into what we were given */
+	shift := strings.Index(in, data)
+	if !strings.HasPrefix(data, "(") {
+		data = "(" + data + ")"
+		shift--
+	}
+	ibuf := input{Index: 0, Data: data}
+	possi := Possibility{}
+	if err := parsePossibilityVersion(&ibuf, &possi); err != nil {
+		if perr, ok := err.(*ParseError); ok && perr.Offset+shift >= 0 {
+			perr.Offset += shift
+		}
+		return nil, err
+	}
+	possi.Version.Number = strings.TrimSpace(possi.Version.Number)
+	if possi.Version.Number == "" || ibuf.Index != len(data) {
+		return nil, fmt.Errorf("Malformed version relation: '%s'", in)
+	}
+	return possi.Version, nil
+}
+
+// ParseError {{{
+
+// ParseError is returned when a Dependency can't be parsed. Along with
+// what went wrong, it says where: the byte offset into the parsed string,
+// and the token found there, so that tools can point at the problem.
+type ParseError struct {
+	// Byte offset into the parsed string where the problem starts. This
+	// is the length of the string if it ended too early.
+	Offset int
+
+	// The token found at Offset, which is empty at the end of the string.
+	Token string
+
+	// All Relations successfully parsed before the one at fault.
+	Relations []Relation
+
+	Message string
+}
+
+// Error returns the message, along with where the problem was found.
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("offset %d: %s", e.Offset, e.Message)
+}
+
+/* Attach the Relations parsed so far to a ParseError. */
+func withRelations(err error, dep *Dependency) error {
+	if perr, ok := err.(*ParseError); ok {
+		perr.Relations = dep.Relations
+	}
+	return err
+}
+
+// }}}
+
 // input Model {{{
 
 /*
@@ -44,6 +127,19 @@ func Parse(in string) (*Dependency, error) {
 type input struct {
 	Data  string
 	Index int
+
+	// Set by ParseWithOptions; Parse leaves both unset, which keeps its
+	// historical behaviour of quietly accepting sloppy syntax.
+	strict   bool
+	lenient  bool
+	warnings []ParseWarning
+
+	/* Limits from ParseOptions, unlimited when zero */
+	maxRelations    int
+	maxAlternatives int
+
+	/* Where the Relations are built up, see parseDependency */
+	scratch *scratch
 }
 
 /*
@@ -63,6 +159,80 @@ func (i *input) Next() byte {
 	return chr
 }
 
+/* Consume everything up to, but not including, the next of the given
+ * bytes, and return it as a string. Slicing the input, rather than building
+ * up a string a byte at a time, keeps parsing linear in the size of the
+ * input, and leaves any UTF-8 in it alone. A NUL byte always stops, since
+ * Peek can't tell it from the end of the input. */
+func (i *input) NextUntil(stop string) string {
+	start := i.Index
+	if start >= len(i.Data) {
+		return ""
+	}
+	for i.Index < len(i.Data) && i.Data[i.Index] != 0 &&
+		strings.IndexByte(stop, i.Data[i.Index]) < 0 {
+		i.Index++
+	}
+	return i.Data[start:i.Index]
+}
+
+/* Build a ParseError for the token at the given offset. */
+func (i *input) errorf(offset int, format string, args ...interface{}) error {
+	return &ParseError{
+		Offset:  offset,
+		Token:   i.tokenAt(offset),
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+/* Turn an error from elsewhere (such as ParseArch) into a ParseError. */
+func (i *input) wrap(offset int, err error) error {
+	return i.errorf(offset, "%s", err)
+}
+
+/* The token starting at offset: either a single piece of punctuation, or
+ * everything up to the next piece of punctuation or whitespace. */
+func (i *input) tokenAt(offset int) string {
+	if offset < 0 || offset >= len(i.Data) {
+		return ""
+	}
+	end := offset
+	for end < len(i.Data) {
+		switch i.Data[end] {
+		case ',', '|', '(', ')', '[', ']', '<', '>':
+			if end == offset {
+				end++
+			}
+			return i.Data[offset:end]
+		case ' ', '\t', '\r', '\n':
+			if end == offset {
+				return ""
+			}
+			return i.Data[offset:end]
+		}
+		end++
+	}
+	return i.Data[offset:end]
+}
+
+/* Report syntax that dpkg would refuse, but which is common enough in the
+ * wild to recover from. Strict parsing fails on it, lenient parsing records
+ * a warning, and plain Parse lets it pass without a word. */
+func (i *input) sloppy(offset int, format string, args ...interface{}) error {
+	message := fmt.Sprintf(format, args...)
+	if i.strict {
+		return i.errorf(offset, "%s", message)
+	}
+	if i.lenient {
+		i.warnings = append(i.warnings, ParseWarning{
+			Offset:  offset,
+			Token:   i.tokenAt(offset),
+			Message: message,
+		})
+	}
+	return nil
+}
+
 // }}}
 
 // Parse Helpers {{{
@@ -84,24 +254,47 @@ func eatWhitespace(input *input) {
 
 // Dependency Parser {{{
 
-/* */
+/* Parse the input into ret. The Relations are built up in scratch buffers,
+ * and only copied into ret at the end (even if there's an error, so that
+ * it can be reported along with what was parsed), to keep allocations to a
+ * handful per Dependency rather than a few per Possibility. */
 func parseDependency(input *input, ret *Dependency) error {
+	input.scratch = getScratch()
+	err := parseRelations(input)
+	input.scratch.build(ret)
+	putScratch(input.scratch)
+	input.scratch = nil
+	return err
+}
+
+/* */
+func parseRelations(input *input) error {
 	eatWhitespace(input)
+	if input.Peek() == 0 {
+		return nil
+	}
 
+	scratch := input.scratch
 	for {
-		peek := input.Peek()
-		switch peek {
-		case 0: /* EOF, yay */
-			return nil
-		case ',': /* Next relation set */
-			input.Next()
-			eatWhitespace(input)
-			continue
-		}
-		err := parseRelation(input, ret)
+		start := input.Index
+		count := len(scratch.ends)
+		err := parseRelation(input)
 		if err != nil {
 			return err
 		}
+		if len(scratch.ends) == count {
+			if err := input.sloppy(start, "Empty relation"); err != nil {
+				return err
+			}
+		} else if input.maxRelations > 0 && len(scratch.ends) > input.maxRelations {
+			scratch.ends = scratch.ends[:input.maxRelations]
+			return input.errorf(start, "More than the limit of %d relations", input.maxRelations)
+		}
+		if input.Next() == 0 { /* EOF, yay */
+			return nil
+		}
+		/* Otherwise it was a ',' -- next relation set */
+		eatWhitespace(input)
 	}
 }
 
@@ -110,26 +303,40 @@ func parseDependency(input *input, ret *Dependency) error {
 // Relation Parser {{{
 
 /* */
-func parseRelation(input *input, dependency *Dependency) error {
+func parseRelation(input *input) error {
 	eatWhitespace(input) /* Clean out leading whitespace */
 
-	ret := &Relation{Possibilities: []Possibility{}}
+	switch input.Peek() {
+	case 0, ',': /* Nothing at all here; our parent will complain */
+		return nil
+	}
 
+	scratch := input.scratch
+	first := len(scratch.possibilities)
 	for {
-		peek := input.Peek()
-		switch peek {
-		case 0, ',': /* EOF, or done with this relation! yay */
-			dependency.Relations = append(dependency.Relations, *ret)
-			return nil
-		case '|': /* Next Possi */
+		start := input.Index
+		count := len(scratch.possibilities)
+		err := parsePossibility(input)
+		if err != nil {
+			return err
+		}
+		if len(scratch.possibilities) == count {
+			if err := input.sloppy(start, "Empty possibility"); err != nil {
+				return err
+			}
+		} else if input.maxAlternatives > 0 && len(scratch.possibilities)-first > input.maxAlternatives {
+			return input.errorf(start, "More than the limit of %d alternatives", input.maxAlternatives)
+		}
+		if input.Peek() == '|' { /* Next Possi */
 			input.Next()
 			eatWhitespace(input)
 			continue
 		}
-		err := parsePossibility(input, ret)
-		if err != nil {
-			return err
+		/* EOF, or done with this relation! yay */
+		if len(scratch.possibilities) > first {
+			scratch.ends = append(scratch.ends, len(scratch.possibilities))
 		}
+		return nil
 	}
 }
 
@@ -138,13 +345,14 @@ func parseRelation(input *input, dependency *Dependency) error {
 // Possibility Parser {{{
 
 /* */
-func parsePossibility(input *input, relation *Relation) error {
+func parsePossibility(input *input) error {
 	eatWhitespace(input) /* Clean out leading whitespace */
+	start := input.Index
 
 	peek := input.Peek()
 	if peek == '$' {
 		/* OK, nice. So, we've got a substvar. Let's eat it. */
-		return parseSubstvar(input, relation)
+		return parseSubstvar(input)
 	}
 
 	/* Otherwise, let's punt and build it up ourselves. */
@@ -152,7 +360,7 @@ func parsePossibility(input *input, relation *Relation) error {
 	ret := &Possibility{
 		Name:          "",
 		Version:       nil,
-		Architectures: &ArchSet{Architectures: []Arch{}},
+		Architectures: input.newArchSet(),
 		StageSets:     []StageSet{},
 		Substvar:      false,
 	}
@@ -166,7 +374,7 @@ func parsePossibility(input *input, relation *Relation) error {
 				return err
 			}
 			continue
-		case ' ':
+		case ' ', '(', '[', '<':
 			err := parsePossibilityControllers(input, ret)
 			if err != nil {
 				return err
@@ -174,20 +382,31 @@ func parsePossibility(input *input, relation *Relation) error {
 			continue
 		case ',', '|', 0: /* I'm out! */
 			if ret.Name == "" {
+				if ret.Version != nil || ret.Arch != nil ||
+					len(ret.Architectures.Architectures) != 0 ||
+					len(ret.StageSets) != 0 {
+					return input.errorf(start, "Missing package name before its restrictions")
+				}
 				return nil // e.g. trailing comma in Build-Depends
 			}
-			relation.Possibilities = append(relation.Possibilities, *ret)
+			if err := checkPossibility(input, start, ret); err != nil {
+				return err
+			}
+			input.scratch.possibilities = append(input.scratch.possibilities, *ret)
 			return nil
 		}
 		/* Not a control, let's append */
-		ret.Name += string(input.Next())
+		ret.Name += input.NextUntil(":,|([< ")
 	}
 }
 
-func parseSubstvar(input *input, relation *Relation) error {
+func parseSubstvar(input *input) error {
 	eatWhitespace(input)
+	start := input.Index
 	input.Next() /* Assert ch == '$' */
-	input.Next() /* Assert ch == '{' */
+	if input.Next() != '{' {
+		return input.errorf(start, "Substvar is missing its opening '{'")
+	}
 
 	ret := &Possibility{
 		Name:     "",
@@ -199,32 +418,68 @@ func parseSubstvar(input *input, relation *Relation) error {
 		peek := input.Peek()
 		switch peek {
 		case 0:
-			return errors.New("Oh no. Reached EOF before substvar finished")
+			return input.errorf(start, "Oh no. Reached EOF before substvar finished")
+		case ' ', '\t', '\r', '\n', '$', '{':
+			return input.errorf(input.Index, "Invalid character in a substvar: %q", peek)
 		case '}':
 			input.Next()
-			relation.Possibilities = append(relation.Possibilities, *ret)
+			if ret.Name == "" {
+				return input.errorf(start, "Empty substvar")
+			}
+			eatWhitespace(input)
+			if input.Peek() == ':' && input.lenient {
+				if err := skipSubstvarQualifier(input); err != nil {
+					return err
+				}
+			}
+			switch input.Peek() {
+			case ',', '|', 0:
+			default:
+				return input.errorf(input.Index, "Trailing garbage after a Substvar: %c", input.Peek())
+			}
+			input.scratch.possibilities = append(input.scratch.possibilities, *ret)
 			return nil
 		}
-		ret.Name += string(input.Next())
+		ret.Name += input.NextUntil(" \t\r\n${}")
+	}
+}
+
+/* Drop an architecture qualifier, as in "${foo:Depends}:any". It has no
+ * meaning until the substvar is expanded, so there is nothing to keep. */
+func skipSubstvarQualifier(input *input) error {
+	start := input.Index
+	input.Next() /* mandated to be a : */
+	for {
+		switch input.Peek() {
+		case ',', '|', 0, ' ', '\t', '\r', '\n':
+			qualifier := input.Data[start:input.Index]
+			eatWhitespace(input)
+			return input.sloppy(start, "Architecture qualifier on a substvar: %q", qualifier)
+		}
+		input.Next()
 	}
 }
 
 /* */
 func parseMultiarch(input *input, possi *Possibility) error {
+	start := input.Index
 	input.Next() /* mandated to be a : */
 	name := ""
 	for {
 		peek := input.Peek()
 		switch peek {
 		case ',', '|', 0, ' ', '(', '[', '<':
-			arch, err := ParseArch(name)
-			if err != nil {
-				return err
+			if name == "" {
+				return input.errorf(start, "Empty architecture qualifier after ':'")
+			}
+			arch := input.newArch()
+			if err := parseArchInto(arch, name); err != nil {
+				return input.wrap(start+1, err)
 			}
 			possi.Arch = arch
 			return nil
 		default:
-			name += string(input.Next())
+			name += input.NextUntil(",| ([<")
 		}
 	}
 	return nil
@@ -240,7 +495,7 @@ func parsePossibilityControllers(input *input, possi *Possibility) error {
 			return nil
 		case '(':
 			if possi.Version != nil {
-				return errors.New(
+				return input.errorf(input.Index,
 					"Only one Version relation per Possibility, please!",
 				)
 			}
@@ -251,7 +506,7 @@ func parsePossibilityControllers(input *input, possi *Possibility) error {
 			continue
 		case '[':
 			if len(possi.Architectures.Architectures) != 0 {
-				return errors.New(
+				return input.errorf(input.Index,
 					"Only one Arch relation per Possibility, please!",
 				)
 			}
@@ -267,7 +522,7 @@ func parsePossibilityControllers(input *input, possi *Possibility) error {
 			}
 			continue
 		}
-		return fmt.Errorf("Trailing garbage in a Possibility: %c", peek)
+		return input.errorf(input.Index, "Trailing garbage in a Possibility: %c", peek)
 	}
 	return nil
 }
@@ -277,14 +532,14 @@ func parsePossibilityVersion(input *input, possi *Possibility) error {
 	eatWhitespace(input)
 	input.Next() /* mandated to be ( */
 	// assert ch == '('
-	version := VersionRelation{}
+	version := input.newVersion()
 
-	err := parsePossibilityOperator(input, &version)
+	err := parsePossibilityOperator(input, version)
 	if err != nil {
 		return err
 	}
 
-	err = parsePossibilityNumber(input, &version)
+	err = parsePossibilityNumber(input, version)
 	if err != nil {
 		return err
 	}
@@ -292,13 +547,14 @@ func parsePossibilityVersion(input *input, possi *Possibility) error {
 	input.Next() /* OK, let's tidy up */
 	// assert ch == ')'
 
-	possi.Version = &version
+	possi.Version = version
 	return nil
 }
 
 /* */
 func parsePossibilityOperator(input *input, version *VersionRelation) error {
 	eatWhitespace(input)
+	start := input.Index
 	leader := input.Next() /* may be 0 */
 
 	if leader == '=' {
@@ -311,10 +567,11 @@ func parsePossibilityOperator(input *input, version *VersionRelation) error {
 	 * >=, <=, <<, >> */
 	secondary := input.Next()
 	if leader == 0 || secondary == 0 {
-		return errors.New("Oh no. Reached EOF before Operator finished")
+		return input.errorf(start, "Oh no. Reached EOF before Operator finished")
 	}
 
-	operator := string([]rune{rune(leader), rune(secondary)})
+	/* A slice of the input, rather than a new string */
+	operator := input.Data[start:input.Index]
 
 	switch operator {
 	case ">=", "<=", "<<", ">>":
@@ -322,7 +579,7 @@ func parsePossibilityOperator(input *input, version *VersionRelation) error {
 		return nil
 	}
 
-	return fmt.Errorf(
+	return input.errorf(start,
 		"Unknown Operator in Possibility Version modifier: %s",
 		operator,
 	)
@@ -332,30 +589,36 @@ func parsePossibilityOperator(input *input, version *VersionRelation) error {
 /* */
 func parsePossibilityNumber(input *input, version *VersionRelation) error {
 	eatWhitespace(input)
+	start := input.Index
 	for {
 		peek := input.Peek()
 		switch peek {
 		case 0:
-			return errors.New("Oh no. Reached EOF before Number finished")
+			return input.errorf(start, "Oh no. Reached EOF before Number finished")
 		case ')':
 			return nil
 		}
-		version.Number += string(input.Next())
+		version.Number += input.NextUntil(")")
 	}
 }
 
 /* */
 func parsePossibilityArchs(input *input, possi *Possibility) error {
 	eatWhitespace(input)
+	start := input.Index
 	input.Next() /* Assert ch == '[' */
 
 	for {
+		eatWhitespace(input)
 		peek := input.Peek()
 		switch peek {
 		case 0:
-			return errors.New("Oh no. Reached EOF before Arch list finished")
+			return input.errorf(start, "Oh no. Reached EOF before Arch list finished")
 		case ']':
 			input.Next()
+			if len(possi.Architectures.Architectures) == 0 {
+				return input.errorf(start, "Empty Arch list")
+			}
 			return nil
 		}
 
@@ -369,6 +632,7 @@ func parsePossibilityArchs(input *input, possi *Possibility) error {
 /* */
 func parsePossibilityArch(input *input, possi *Possibility) error {
 	eatWhitespace(input)
+	start := input.Index
 	arch := ""
 
 	// Exclamation marks may be prepended to each of the names. (It is not
@@ -381,20 +645,24 @@ func parsePossibilityArch(input *input, possi *Possibility) error {
 	if len(possi.Architectures.Architectures) == 0 {
 		possi.Architectures.Not = hasNot
 	} else if possi.Architectures.Not != hasNot {
-		return errors.New("Either the entire arch list needs negations, or none of it does -- no mix and match :/")
+		return input.errorf(start, "Either the entire arch list needs negations, or none of it does -- no mix and match :/")
 	}
 
+	nameStart := input.Index
 	for {
 		peek := input.Peek()
 		switch peek {
 		case 0:
-			return errors.New("Oh no. Reached EOF before Arch list finished")
+			return input.errorf(start, "Oh no. Reached EOF before Arch list finished")
 		case '!':
-			return errors.New("You can only negate whole blocks :(")
+			return input.errorf(input.Index, "You can only negate whole blocks :(")
 		case ']', ' ': /* Let our parent deal with both of these */
+			if arch == "" {
+				return input.errorf(start, "Empty Arch in an Arch list")
+			}
 			archObj, err := ParseArch(arch)
 			if err != nil {
-				return err
+				return input.wrap(nameStart, err)
 			}
 			possi.Architectures.Architectures = append(
 				possi.Architectures.Architectures,
@@ -402,23 +670,28 @@ func parsePossibilityArch(input *input, possi *Possibility) error {
 			)
 			return nil
 		}
-		arch += string(input.Next())
+		arch += input.NextUntil("! ]")
 	}
 }
 
 /* */
 func parsePossibilityStageSet(input *input, possi *Possibility) error {
 	eatWhitespace(input)
+	start := input.Index
 	input.Next() /* Assert ch == '<' */
 
 	stageSet := StageSet{}
 	for {
+		eatWhitespace(input)
 		peek := input.Peek()
 		switch peek {
 		case 0:
-			return errors.New("Oh no. Reached EOF before StageSet finished")
+			return input.errorf(start, "Oh no. Reached EOF before StageSet finished")
 		case '>':
 			input.Next()
+			if len(stageSet.Stages) == 0 {
+				return input.errorf(start, "Empty StageSet")
+			}
 			possi.StageSets = append(possi.StageSets, stageSet)
 			return nil
 		}
@@ -433,24 +706,32 @@ func parsePossibilityStageSet(input *input, possi *Possibility) error {
 /* */
 func parsePossibilityStage(input *input, stageSet *StageSet) error {
 	eatWhitespace(input)
+	start := input.Index
 
 	stage := Stage{}
 	for {
 		peek := input.Peek()
 		switch peek {
 		case 0:
-			return errors.New("Oh no. Reached EOF before Stage finished")
+			return input.errorf(start, "Oh no. Reached EOF before Stage finished")
 		case '!':
 			input.Next()
 			if stage.Not {
-				return errors.New("Double-negation (!!) of a single Stage is not permitted :(")
+				return input.errorf(start, "Double-negation (!!) of a single Stage is not permitted :(")
 			}
-			stage.Not = !stage.Not
+			if stage.Name != "" {
+				return input.errorf(input.Index-1, "You can only negate whole Stages :(")
+			}
+			stage.Not = true
+			continue
 		case '>', ' ': /* Let our parent deal with both of these */
+			if stage.Name == "" {
+				return input.errorf(start, "Empty Stage in a StageSet")
+			}
 			stageSet.Stages = append(stageSet.Stages, stage)
 			return nil
 		}
-		stage.Name += string(input.Next())
+		stage.Name += input.NextUntil("!> ")
 	}
 }
 
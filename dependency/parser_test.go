@@ -21,8 +21,10 @@
 package dependency_test
 
 import (
+	"fmt"
 	"log"
 	"runtime/debug"
+	"strings"
 	"testing"
 
 	"github.com/ebikt/go-debian/dependency"
@@ -303,22 +305,290 @@ func TestSingleSubstvar(t *testing.T) {
 	assert(t, !dep.Relations[2].Possibilities[0].Substvar)
 }
 
+func TestNoSpaceBeforeControllers(t *testing.T) {
+	dep, err := dependency.Parse("foo(>= 1.0), bar:i386(<< 2.0)[amd64]<!nocheck>")
+	isok(t, err)
+	assert(t, len(dep.Relations) == 2)
+
+	foo := dep.Relations[0].Possibilities[0]
+	assert(t, foo.Name == "foo")
+	assert(t, foo.Version.Number == "1.0")
+
+	bar := dep.Relations[1].Possibilities[0]
+	assert(t, bar.Name == "bar")
+	assert(t, bar.Arch.CPU == "i386")
+	assert(t, bar.Version.Operator == "<<")
+	assert(t, bar.Architectures.Architectures[0].CPU == "amd64")
+	assert(t, len(bar.StageSets) == 1)
+
+	_, err = dependency.Parse("foo:, bar")
+	notok(t, err)
+}
+
+func TestConflictsParse(t *testing.T) {
+	dep, err := dependency.ParseConflicts("foo, bar:i386 (<< 2.0), baz:amd64 [!armel]")
+	isok(t, err)
+	assert(t, len(dep.Relations) == 3)
+
+	bar := dep.Relations[1].Possibilities[0]
+	assert(t, bar.Name == "bar")
+	assert(t, bar.Arch.CPU == "i386")
+	assert(t, bar.Version.Number == "2.0")
+
+	baz := dep.Relations[2].Possibilities[0]
+	assert(t, baz.Arch.CPU == "amd64")
+	assert(t, baz.Architectures.Not)
+
+	_, err = dependency.ParseConflicts("foo | bar")
+	notok(t, err)
+
+	dep, err = dependency.Parse("foo, bar | baz")
+	isok(t, err)
+	notok(t, dep.ValidateConflicts())
+}
+
 func TestInsaneRoundTrip(t *testing.T) {
 	dep, err := dependency.Parse("foo:armhf <stage1 !cross> [amd64 i386] (>= 1.2:3.4~5.6-7.8~9.0) <!stage1 cross>")
 	isok(t, err)
 
-	assert(t, dep.String() == "foo:armhf [amd64 i386] (>= 1.2:3.4~5.6-7.8~9.0) <stage1 !cross> <!stage1 cross>")
+	assert(t, dep.String() == "foo:armhf (>= 1.2:3.4~5.6-7.8~9.0) [amd64 i386] <stage1 !cross> <!stage1 cross>")
 
 	rtDep, err := dependency.Parse(dep.String())
 	isok(t, err)
 	assert(t, dep.String() == rtDep.String())
 
 	dep.Relations[0].Possibilities[0].Architectures.Not = true
-	assert(t, dep.String() == "foo:armhf [!amd64 !i386] (>= 1.2:3.4~5.6-7.8~9.0) <stage1 !cross> <!stage1 cross>")
+	assert(t, dep.String() == "foo:armhf (>= 1.2:3.4~5.6-7.8~9.0) [!amd64 !i386] <stage1 !cross> <!stage1 cross>")
 
 	rtDep, err = dependency.Parse(dep.String())
 	isok(t, err)
 	assert(t, dep.String() == rtDep.String())
 }
 
+func TestParseStrict(t *testing.T) {
+	strict := dependency.ParseOptions{Strict: true}
+
+	dep, warnings, err := dependency.ParseWithOptions(
+		"libc6 (>= 2.34), foo:any | bar [amd64] <!nocheck>, ${misc:Depends}, baz ( >= 1.0 )", strict)
+	isok(t, err)
+	assert(t, len(warnings) == 0)
+	assert(t, len(dep.Relations) == 4)
+
+	for _, in := range []string{
+		"foo,",
+		"foo,, bar",
+		", foo",
+		"foo | | bar",
+		"foo |",
+		"| foo",
+		"Foo",
+		"f",
+		"-foo",
+		"foo (>= a:1.0)",
+		"${foo}:any",
+	} {
+		_, _, err := dependency.ParseWithOptions(in, strict)
+		notok(t, err)
+	}
+}
+
+func TestParseLenient(t *testing.T) {
+	lenient := dependency.ParseOptions{}
+
+	dep, warnings, err := dependency.ParseWithOptions("foo,, bar | | baz,", lenient)
+	isok(t, err)
+	assert(t, dep.String() == "foo, bar | baz")
+	assert(t, len(warnings) == 3)
+	assert(t, warnings[0].Offset == 4)
+	assert(t, warnings[0].Message == "Empty relation")
+	assert(t, warnings[1].Offset == 12)
+	assert(t, warnings[1].Message == "Empty possibility")
+	assert(t, warnings[2].Offset == 18)
+	assert(t, warnings[2].String() == "offset 18: Empty relation")
+
+	dep, warnings, err = dependency.ParseWithOptions("${shlibs:Depends}:any , Foo", lenient)
+	isok(t, err)
+	assert(t, dep.String() == "${shlibs:Depends}, Foo")
+	assert(t, len(warnings) == 2)
+	assert(t, warnings[0].Offset == 17)
+	assert(t, warnings[0].Message == `Architecture qualifier on a substvar: ":any"`)
+	assert(t, warnings[1].Message == "Invalid package name: 'Foo'")
+	assert(t, warnings[1].Token == "Foo")
+
+	/* Genuinely broken syntax is still an error */
+	_, _, err = dependency.ParseWithOptions("foo (>= 1.0", lenient)
+	notok(t, err)
+
+	/* Plain Parse stays quiet, and keeps refusing qualified substvars */
+	dep, err = dependency.Parse("foo,, bar | | baz,")
+	isok(t, err)
+	assert(t, dep.String() == "foo, bar | baz")
+	_, err = dependency.Parse("${foo}:any")
+	notok(t, err)
+}
+
+func TestParseErrorPosition(t *testing.T) {
+	for _, test := range []struct {
+		in     string
+		offset int
+		token  string
+		done   int
+	}{
+		{"foo, bar (>= 1.0", 13, "1.0", 1},
+		{"foo, bar [amd64", 10, "amd64", 1},
+		{"foo, bar | baz qux", 15, "qux", 1},
+		{"foo (>= 1.0), bar:, baz", 17, ":", 1},
+		{"foo [!amd64 i386]", 12, "i386", 0},
+		{"foo, bar [amd64] [i386]", 17, "[", 1},
+		{"foo <!nocheck !!cross>", 14, "!!cross", 0},
+		{"foo, bar, ${baz", 10, "${baz", 2},
+		{"foo | (>= 1.0)", 6, "(", 0},
+		{"foo (~= 1.0)", 5, "~=", 0},
+	} {
+		_, err := dependency.Parse(test.in)
+		notok(t, err)
+		perr, ok := err.(*dependency.ParseError)
+		assert(t, ok)
+		if perr.Offset != test.offset || perr.Token != test.token || len(perr.Relations) != test.done {
+			t.Errorf("%q: got offset %d, token %q and %d relations (%s)",
+				test.in, perr.Offset, perr.Token, len(perr.Relations), perr)
+		}
+	}
+
+	_, _, err := dependency.ParseWithOptions("foo, , bar", dependency.ParseOptions{Strict: true})
+	perr, ok := err.(*dependency.ParseError)
+	assert(t, ok)
+	assert(t, perr.Error() == "offset 5: Empty relation")
+	assert(t, len(perr.Relations) == 1)
+	assert(t, perr.Relations[0].Possibilities[0].Name == "foo")
+
+	_, err = dependency.ParseVersionRelation("  ~= 1.0")
+	perr, ok = err.(*dependency.ParseError)
+	assert(t, ok)
+	assert(t, perr.Offset == 2)
+	assert(t, perr.Token == "~=")
+}
+
+func TestParseLimits(t *testing.T) {
+	in := "foo | bar, baz, qux | quux | corge"
+
+	_, _, err := dependency.ParseWithOptions(in, dependency.DefaultParseLimits)
+	isok(t, err)
+	_, _, err = dependency.ParseWithOptions(in, dependency.ParseOptions{
+		MaxLength: len(in), MaxRelations: 3, MaxAlternatives: 3})
+	isok(t, err)
+
+	_, _, err = dependency.ParseWithOptions(in, dependency.ParseOptions{MaxLength: len(in) - 1})
+	notok(t, err)
+	_, _, err = dependency.ParseWithOptions(in, dependency.ParseOptions{MaxRelations: 2})
+	perr, ok := err.(*dependency.ParseError)
+	assert(t, ok)
+	assert(t, perr.Offset == 16)
+	assert(t, len(perr.Relations) == 2)
+	_, _, err = dependency.ParseWithOptions(in, dependency.ParseOptions{MaxAlternatives: 2})
+	perr, ok = err.(*dependency.ParseError)
+	assert(t, ok)
+	assert(t, perr.Offset == 29)
+	assert(t, perr.Token == "corge")
+}
+
+func TestParseAdversarial(t *testing.T) {
+	/* None of these should take more than a moment */
+	for _, in := range []string{
+		strings.Repeat("a", 1<<20),
+		"foo (>= " + strings.Repeat("1", 1<<20),
+		"foo [" + strings.Repeat("a", 1<<20),
+		"foo <" + strings.Repeat("a", 1<<20),
+		"${" + strings.Repeat("a", 1<<20),
+		strings.Repeat("foo [", 1<<18),
+		strings.Repeat("foo (", 1<<18),
+		strings.Repeat("foo <", 1<<18),
+		strings.Repeat("foo <a> ", 1<<18),
+		strings.Repeat("a, ", 1<<18),
+		strings.Repeat("a | ", 1<<18),
+	} {
+		dependency.Parse(in)
+		dependency.ParseWithOptions(in, dependency.ParseOptions{})
+	}
+}
+
+const benchmarkDepends = "libc6 (>= 2.34), libssl3 (>= 3.0.0), debconf (>= 0.5) | debconf-2.0, zlib1g (>= 1:1.1.4), perl:any"
+
+/* Set by race_test.go */
+var raceEnabled = false
+
+func TestParseAllocations(t *testing.T) {
+	if raceEnabled {
+		t.Skip("Allocations can't be counted with the race detector")
+	}
+	allocs := testing.AllocsPerRun(100, func() {
+		dependency.Parse(benchmarkDepends)
+	})
+	assert(t, allocs <= 4)
+
+	in := []byte(benchmarkDepends)
+	allocs = testing.AllocsPerRun(100, func() {
+		dependency.ParseBytes(in)
+	})
+	assert(t, allocs <= 5)
+
+	dep, err := dependency.ParseBytes(in)
+	isok(t, err)
+	assert(t, dep.String() == benchmarkDepends)
+}
+
+func TestParseConcurrently(t *testing.T) {
+	/* The buffers parsing reuses mustn't leak between Dependencies */
+	inputs := []string{
+		benchmarkDepends,
+		"foo:armhf (>= 1.0) [amd64 i386] <!nocheck>, bar | baz:native",
+		"${misc:Depends}, qux (<< 2:1.0~rc1)",
+	}
+	done := make(chan error)
+	for i := 0; i < 8; i++ {
+		go func(i int) {
+			for j := 0; j < 200; j++ {
+				in := inputs[(i+j)%len(inputs)]
+				dep, err := dependency.Parse(in)
+				if err == nil && dep.String() != in {
+					err = fmt.Errorf("%q parsed as %q", in, dep.String())
+				}
+				if err != nil {
+					done <- err
+					return
+				}
+			}
+			done <- nil
+		}(i)
+	}
+	for i := 0; i < 8; i++ {
+		isok(t, <-done)
+	}
+}
+
+func BenchmarkParse(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		dependency.Parse(benchmarkDepends)
+	}
+}
+
+func BenchmarkParseBytes(b *testing.B) {
+	in := []byte(benchmarkDepends)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		dependency.ParseBytes(in)
+	}
+}
+
+func BenchmarkParseBuildDepends(b *testing.B) {
+	in := "debhelper-compat (= 13), dh-python, python3-all:native <!nocheck>, " +
+		"gcc-12 [!hurd-i386 !kfreebsd-any], libc6-dev [linux-any] | libc6.1-dev [alpha ia64], " +
+		"libgtk-3-dev <!stage1 !nogui>, libtool <cross>"
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		dependency.Parse(in)
+	}
+}
+
 // vim: foldmethod=marker
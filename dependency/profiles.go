@@ -0,0 +1,92 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package dependency // import "github.com/ebikt/go-debian/dependency"
+
+// Build profiles {{{
+
+// Matches returns true if every Stage of the StageSet (a build profile
+// restriction list such as `<!nocheck cross>`) holds when building with
+// the given profiles enabled.
+func (stageSet StageSet) Matches(profiles []string) bool {
+	for _, stage := range stageSet.Stages {
+		enabled := false
+		for _, profile := range profiles {
+			if profile == stage.Name {
+				enabled = true
+				break
+			}
+		}
+		if enabled == stage.Not {
+			return false
+		}
+	}
+	return true
+}
+
+// MatchesProfiles returns true if the Possibility applies when building
+// with the given profiles enabled: it has no restriction formula, or one
+// of its restriction lists matches.
+//
+//   foo <!nocheck> <cross>
+//
+// applies unless building with the nocheck profile (and not cross).
+func (possi Possibility) MatchesProfiles(profiles []string) bool {
+	if len(possi.StageSets) == 0 {
+		return true
+	}
+	for _, stageSet := range possi.StageSets {
+		if stageSet.Matches(profiles) {
+			return true
+		}
+	}
+	return false
+}
+
+// Reduce returns the Dependency that applies when building on the given
+// Arch with the given profiles enabled, dropping the Possibilities whose
+// architecture or profile restrictions don't match (and the Relations left
+// empty), and the restrictions of the others, like dpkg-checkbuilddeps
+// does.
+func (dep Dependency) Reduce(arch Arch, profiles []string) Dependency {
+	ret := Dependency{Relations: []Relation{}}
+	for _, relation := range dep.Relations {
+		reduced := Relation{Possibilities: []Possibility{}}
+		for _, possi := range relation.Possibilities {
+			if possi.Architectures != nil && !possi.Architectures.Matches(&arch) {
+				continue
+			}
+			if !possi.MatchesProfiles(profiles) {
+				continue
+			}
+			possi.Architectures = &ArchSet{Architectures: []Arch{}}
+			possi.StageSets = nil
+			reduced.Possibilities = append(reduced.Possibilities, possi)
+		}
+		if len(reduced.Possibilities) > 0 {
+			ret.Relations = append(ret.Relations, reduced)
+		}
+	}
+	return ret
+}
+
+// }}}
+
+// vim: foldmethod=marker
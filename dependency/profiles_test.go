@@ -0,0 +1,76 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package dependency_test
+
+import (
+	"testing"
+
+	"github.com/ebikt/go-debian/dependency"
+)
+
+/*
+ *
+ */
+
+func TestMatchesProfiles(t *testing.T) {
+	dep, err := dependency.Parse("foo <!nocheck> <cross>, bar <stage1 !cross>, baz")
+	isok(t, err)
+	foo := dep.Relations[0].Possibilities[0]
+	bar := dep.Relations[1].Possibilities[0]
+	baz := dep.Relations[2].Possibilities[0]
+
+	assert(t, foo.MatchesProfiles(nil))
+	assert(t, !foo.MatchesProfiles([]string{"nocheck"}))
+	assert(t, foo.MatchesProfiles([]string{"nocheck", "cross"}))
+	assert(t, !bar.MatchesProfiles(nil))
+	assert(t, bar.MatchesProfiles([]string{"stage1"}))
+	assert(t, !bar.MatchesProfiles([]string{"stage1", "cross"}))
+	assert(t, baz.MatchesProfiles([]string{"nocheck"}))
+}
+
+func TestReduce(t *testing.T) {
+	dep, err := dependency.Parse("debhelper-compat (= 13), python3-pytest <!nocheck>, libfoo-dev [amd64] | libfoo-compat-dev, gcc-multilib [i386] <!nobiarch>")
+	isok(t, err)
+	amd64, err := dependency.ParseArch("amd64")
+	isok(t, err)
+
+	assert(t, dep.Reduce(*amd64, nil).String() == "debhelper-compat (= 13), python3-pytest, libfoo-dev | libfoo-compat-dev")
+	assert(t, dep.Reduce(*amd64, []string{"nocheck"}).String() == "debhelper-compat (= 13), libfoo-dev | libfoo-compat-dev")
+
+	i386, err := dependency.ParseArch("i386")
+	isok(t, err)
+	assert(t, dep.Reduce(*i386, []string{"nocheck"}).String() == "debhelper-compat (= 13), libfoo-compat-dev, gcc-multilib")
+
+	/* Hand built Possibilities have no ArchSet */
+	reduced := dependency.Dependency{Relations: []dependency.Relation{
+		{Possibilities: []dependency.Possibility{{Name: "foo"}}},
+	}}.Reduce(*i386, nil)
+	assert(t, reduced.String() == "foo")
+}
+
+func TestBadProfiles(t *testing.T) {
+	for _, in := range []string{"foo <!!nocheck>", "foo <no!check>", "foo <!>", "foo <>"} {
+		_, err := dependency.Parse(in)
+		notok(t, err)
+	}
+}
+
+// vim: foldmethod=marker
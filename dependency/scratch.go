@@ -0,0 +1,158 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package dependency // import "github.com/ebikt/go-debian/dependency"
+
+import (
+	"sync"
+)
+
+// scratch {{{
+
+/* Buffers to build the Relations of a Dependency in while parsing, which
+ * are reused from one parse to the next. Once parsing is done, build
+ * copies what was parsed into a few allocations of exactly the right size,
+ * no matter how many Relations and Possibilities there are. */
+type scratch struct {
+	possibilities []Possibility
+	/* Where the Possibilities of each Relation end */
+	ends []int
+
+	/* What the Possibilities point to */
+	archSets []ArchSet
+	versions []VersionRelation
+	arches   []Arch
+}
+
+/* Anything which grew larger than this parsing something unusual isn't
+ * worth keeping around. */
+const maxScratchSize = 1024
+
+var scratchPool = sync.Pool{
+	New: func() interface{} {
+		return &scratch{}
+	},
+}
+
+func getScratch() *scratch {
+	return scratchPool.Get().(*scratch)
+}
+
+func putScratch(s *scratch) {
+	if cap(s.possibilities) > maxScratchSize || cap(s.ends) > maxScratchSize {
+		return
+	}
+	/* Don't hold on to any of the parsed strings */
+	for i := range s.possibilities {
+		s.possibilities[i] = Possibility{}
+	}
+	for i := range s.archSets {
+		s.archSets[i] = ArchSet{}
+	}
+	for i := range s.versions {
+		s.versions[i] = VersionRelation{}
+	}
+	for i := range s.arches {
+		s.arches[i] = Arch{}
+	}
+	s.possibilities = s.possibilities[:0]
+	s.ends = s.ends[:0]
+	s.archSets = s.archSets[:0]
+	s.versions = s.versions[:0]
+	s.arches = s.arches[:0]
+	scratchPool.Put(s)
+}
+
+/* What a Possibility points to, once it's been built. */
+type possibilityExtras struct {
+	archSet ArchSet
+	version VersionRelation
+	arch    Arch
+}
+
+/* Copy the parsed Relations into ret. The pointers of the Possibilities
+ * still point into the scratch buffers (or into buffers they outgrew) at
+ * this point, and are pointed at the copies. */
+func (s *scratch) build(ret *Dependency) {
+	count := 0
+	if len(s.ends) > 0 {
+		count = s.ends[len(s.ends)-1]
+	}
+	possibilities := make([]Possibility, count)
+	extras := make([]possibilityExtras, count)
+	copy(possibilities, s.possibilities)
+	for i := range possibilities {
+		possi := &possibilities[i]
+		if possi.Architectures != nil {
+			extras[i].archSet = *possi.Architectures
+			possi.Architectures = &extras[i].archSet
+		}
+		if possi.Version != nil {
+			extras[i].version = *possi.Version
+			possi.Version = &extras[i].version
+		}
+		if possi.Arch != nil {
+			extras[i].arch = *possi.Arch
+			possi.Arch = &extras[i].arch
+		}
+	}
+
+	ret.Relations = make([]Relation, len(s.ends))
+	start := 0
+	for i, end := range s.ends {
+		ret.Relations[i].Possibilities = possibilities[start:end:end]
+		start = end
+	}
+}
+
+// }}}
+
+// input Allocators {{{
+
+/* Each of these hands out a pointer into the scratch buffers, if there are
+ * any, which build copies once parsing is done. */
+
+func (i *input) newArchSet() *ArchSet {
+	if i.scratch == nil {
+		return &ArchSet{Architectures: []Arch{}}
+	}
+	i.scratch.archSets = append(i.scratch.archSets, ArchSet{Architectures: []Arch{}})
+	return &i.scratch.archSets[len(i.scratch.archSets)-1]
+}
+
+func (i *input) newVersion() *VersionRelation {
+	if i.scratch == nil {
+		return &VersionRelation{}
+	}
+	i.scratch.versions = append(i.scratch.versions, VersionRelation{})
+	return &i.scratch.versions[len(i.scratch.versions)-1]
+}
+
+func (i *input) newArch() *Arch {
+	if i.scratch == nil {
+		return &Arch{ABI: "any", OS: "any", CPU: "any"}
+	}
+	i.scratch.arches = append(i.scratch.arches, Arch{ABI: "any", OS: "any", CPU: "any"})
+	return &i.scratch.arches[len(i.scratch.arches)-1]
+}
+
+// }}}
+
+// vim: foldmethod=marker
@@ -28,19 +28,26 @@ func (a Arch) MarshalControl() (string, error) {
 	return a.String(), nil
 }
 
+// String renders the Arch in its canonical (shortest) form, such as
+// "amd64" for gnu-linux-amd64, "kfreebsd-amd64" or "linux-any".
 func (a Arch) String() string {
 	/* ABI-OS-CPU -- gnu-linux-amd64 */
-	els := []string{}
-	if a.ABI != "any" && a.ABI != "all" && a.ABI != "gnu" && a.ABI != "" {
-		els = append(els, a.ABI)
+	abi := a.ABI
+	if abi == "" {
+		/* Unset by the two component forms, such as linux-any */
+		abi = "any"
 	}
-
-	if a.OS != "any" && a.OS != "all" && a.OS != "linux" {
-		els = append(els, a.OS)
+	switch {
+	case abi == a.OS && a.OS == a.CPU && (abi == "any" || abi == "all"):
+		return a.CPU
+	case abi == "gnu" && a.OS == "linux" && a.CPU != "any":
+		return a.CPU
+	case abi == "gnu" && a.OS != "any" && a.CPU != "any":
+		return a.OS + "-" + a.CPU
+	case abi == "any" && (a.OS == "any" || a.CPU == "any"):
+		return a.OS + "-" + a.CPU
 	}
-
-	els = append(els, a.CPU)
-	return strings.Join(els, "-")
+	return abi + "-" + a.OS + "-" + a.CPU
 }
 
 func (set ArchSet) String() string {
@@ -81,18 +88,21 @@ func (stageSet StageSet) String() string {
 }
 
 func (possi Possibility) String() string {
+	if possi.Substvar {
+		return "${" + possi.Name + "}"
+	}
 	str := possi.Name
 	if possi.Arch != nil {
 		str += ":" + possi.Arch.String()
 	}
+	if possi.Version != nil {
+		str += " " + possi.Version.String()
+	}
 	if possi.Architectures != nil {
 		if arch := possi.Architectures.String(); arch != "" {
 			str += " " + arch
 		}
 	}
-	if possi.Version != nil {
-		str += " " + possi.Version.String()
-	}
 	for _, stageSet := range possi.StageSets {
 		if stages := stageSet.String(); stages != "" {
 			str += " " + stages
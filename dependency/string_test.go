@@ -42,4 +42,53 @@ func TestArchString(t *testing.T) {
 	}
 }
 
+func TestSubstvarString(t *testing.T) {
+	dep, err := dependency.Parse("${misc:Depends}, foo | ${bar}")
+	isok(t, err)
+	assert(t, dep.String() == "${misc:Depends}, foo | ${bar}")
+
+	for _, in := range []string{"${}", "${ }", "$foo", "${foo} bar"} {
+		_, err := dependency.Parse(in)
+		notok(t, err)
+	}
+}
+
+func TestArchStringRoundTrip(t *testing.T) {
+	for in, out := range map[string]string{
+		"any":              "any",
+		"all":              "all",
+		"linux-any":        "linux-any",
+		"any-amd64":        "any-amd64",
+		"kfreebsd-amd64":   "kfreebsd-amd64",
+		"kfreebsd-any":     "kfreebsd-any",
+		"gnu-linux-any":    "gnu-linux-any",
+		"musl-linux-any":   "musl-linux-any",
+		"any-linux-amd64":  "any-linux-amd64",
+		"gnu-linux-arm64":  "arm64",
+		"gnu-hurd-i386":    "hurd-i386",
+		"bsd-windows-i386": "bsd-windows-i386",
+	} {
+		arch, err := dependency.ParseArch(in)
+		isok(t, err)
+		assert(t, arch.String() == out)
+
+		again, err := dependency.ParseArch(arch.String())
+		isok(t, err)
+		assert(t, *again == *arch)
+	}
+}
+
+func TestDependencyStringRoundTrip(t *testing.T) {
+	for _, in := range []string{
+		"libc6 (>= 2.34), debconf (>= 0.5) | debconf-2.0",
+		"python3:any (>= 3.11~), gcc-12:native",
+		"foo (<< 2) [!hurd-any !kfreebsd-any] <!nocheck> <stage1 cross>, bar [any-amd64]",
+		"${misc:Depends}, ${shlibs:Depends}",
+	} {
+		dep, err := dependency.Parse(in)
+		isok(t, err)
+		assert(t, dep.String() == in)
+	}
+}
+
 // vim: foldmethod=marker
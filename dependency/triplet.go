@@ -0,0 +1,390 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package dependency // import "github.com/ebikt/go-debian/dependency"
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// dpkg tables {{{
+
+// cpuTable is the cputable of dpkg: the Debian name of the CPUs, their
+// GNU name, the regular expression matching it in GNU triplets, their
+// bits and endianness.
+var cpuTable = []struct {
+	name   string
+	gnu    string
+	regex  string
+	bits   int
+	endian string
+}{
+	{"alpha", "alpha", `alpha.*`, 64, "little"},
+	{"amd64", "x86_64", `(amd64|x86_64)`, 64, "little"},
+	{"arc", "arc", `arc`, 32, "little"},
+	{"armeb", "armeb", `arm.*b`, 32, "big"},
+	{"arm", "arm", `arm.*`, 32, "little"},
+	{"arm64", "aarch64", `aarch64`, 64, "little"},
+	{"avr32", "avr32", `avr32`, 32, "big"},
+	{"hppa", "hppa", `hppa.*`, 32, "big"},
+	{"loong64", "loongarch64", `loongarch64`, 64, "little"},
+	{"i386", "i686", `(i[34567]86|pentium)`, 32, "little"},
+	{"ia64", "ia64", `ia64`, 64, "little"},
+	{"m32r", "m32r", `m32r`, 32, "big"},
+	{"m68k", "m68k", `m68k`, 32, "big"},
+	{"mips", "mips", `mips(eb)?`, 32, "big"},
+	{"mipsel", "mipsel", `mipsel`, 32, "little"},
+	{"mipsr6", "mipsisa32r6", `mipsisa32r6`, 32, "big"},
+	{"mipsr6el", "mipsisa32r6el", `mipsisa32r6el`, 32, "little"},
+	{"mips64", "mips64", `mips64`, 64, "big"},
+	{"mips64el", "mips64el", `mips64el`, 64, "little"},
+	{"mips64r6", "mipsisa64r6", `mipsisa64r6`, 64, "big"},
+	{"mips64r6el", "mipsisa64r6el", `mipsisa64r6el`, 64, "little"},
+	{"nios2", "nios2", `nios2`, 32, "little"},
+	{"or1k", "or1k", `or1k`, 32, "big"},
+	{"powerpc", "powerpc", `(powerpc|ppc)`, 32, "big"},
+	{"powerpcel", "powerpcle", `powerpcle`, 32, "little"},
+	{"ppc64", "powerpc64", `(powerpc|ppc)64`, 64, "big"},
+	{"ppc64el", "powerpc64le", `powerpc64le`, 64, "little"},
+	{"riscv64", "riscv64", `riscv64`, 64, "little"},
+	{"s390", "s390", `s390`, 32, "big"},
+	{"s390x", "s390x", `s390x`, 64, "big"},
+	{"sh3", "sh3", `sh3`, 32, "little"},
+	{"sh3eb", "sh3eb", `sh3eb`, 32, "big"},
+	{"sh4", "sh4", `sh4`, 32, "little"},
+	{"sh4eb", "sh4eb", `sh4eb`, 32, "big"},
+	{"sparc", "sparc", `sparc`, 32, "big"},
+	{"sparc64", "sparc64", `sparc64`, 64, "big"},
+	{"tilegx", "tilegx", `tilegx`, 64, "little"},
+}
+
+// osTable is the ostable of dpkg: the ABI, libc and OS parts of the
+// tuples, their GNU name, and the regular expression matching it in GNU
+// triplets.
+var osTable = []struct {
+	name  string
+	gnu   string
+	regex string
+}{
+	{"eabi-uclibc-linux", "linux-uclibceabi", `linux[^-]*-uclibceabi`},
+	{"base-uclibc-linux", "linux-uclibc", `linux[^-]*-uclibc`},
+	{"eabihf-musl-linux", "linux-musleabihf", `linux[^-]*-musleabihf`},
+	{"base-musl-linux", "linux-musl", `linux[^-]*-musl`},
+	{"eabihf-gnu-linux", "linux-gnueabihf", `linux[^-]*-gnueabihf`},
+	{"eabi-gnu-linux", "linux-gnueabi", `linux[^-]*-gnueabi`},
+	{"abin32-gnu-linux", "linux-gnuabin32", `linux[^-]*-gnuabin32`},
+	{"abi64-gnu-linux", "linux-gnuabi64", `linux[^-]*-gnuabi64`},
+	{"spe-gnu-linux", "linux-gnuspe", `linux[^-]*-gnuspe`},
+	{"x32-gnu-linux", "linux-gnux32", `linux[^-]*-gnux32`},
+	{"ilp32-gnu-linux", "linux-gnu_ilp32", `linux[^-]*-gnu_ilp32`},
+	{"base-gnu-linux", "linux-gnu", `linux[^-]*(-gnu.*)?`},
+	{"eabihf-gnu-kfreebsd", "kfreebsd-gnueabihf", `kfreebsd[^-]*-gnueabihf`},
+	{"base-gnu-kfreebsd", "kfreebsd-gnu", `kfreebsd[^-]*(-gnu.*)?`},
+	{"base-gnu-knetbsd", "knetbsd-gnu", `knetbsd[^-]*(-gnu.*)?`},
+	{"base-gnu-kopensolaris", "kopensolaris-gnu", `kopensolaris[^-]*(-gnu.*)?`},
+	{"base-gnu-hurd", "gnu", `gnu[^-]*`},
+	{"base-bsd-darwin", "darwin", `darwin[^-]*`},
+	{"base-bsd-dragonflybsd", "dragonflybsd", `dragonfly[^-]*`},
+	{"base-bsd-freebsd", "freebsd", `freebsd[^-]*`},
+	{"base-bsd-netbsd", "netbsd", `netbsd[^-]*`},
+	{"base-bsd-openbsd", "openbsd", `openbsd[^-]*`},
+	{"base-sysv-aix", "aix", `aix[^-]*`},
+	{"base-sysv-solaris", "solaris", `solaris[^-]*`},
+	{"eabi-uclibc-uclinux", "uclinux-uclibceabi", `uclinux[^-]*-uclibceabi`},
+	{"base-uclibc-uclinux", "uclinux-uclibc", `uclinux[^-]*(-uclibc.*)?`},
+	{"base-tos-mint", "mint", `mint[^-]*`},
+}
+
+// tupleTable is the tupletable of dpkg: the tuples and the architectures
+// they stand for, where "<cpu>" stands for any CPU of the cpuTable. The
+// first match wins.
+var tupleTable = []struct {
+	tuple string
+	arch  string
+}{
+	{"eabi-uclibc-linux-arm", "uclibc-linux-armel"},
+	{"base-uclibc-linux-<cpu>", "uclibc-linux-<cpu>"},
+	{"eabihf-musl-linux-arm", "musl-linux-armhf"},
+	{"base-musl-linux-<cpu>", "musl-linux-<cpu>"},
+	{"ilp32-gnu-linux-arm64", "arm64ilp32"},
+	{"eabihf-gnu-linux-arm", "armhf"},
+	{"eabi-gnu-linux-arm", "armel"},
+	{"abin32-gnu-linux-mips64r6el", "mipsn32r6el"},
+	{"abin32-gnu-linux-mips64r6", "mipsn32r6"},
+	{"abin32-gnu-linux-mips64el", "mipsn32el"},
+	{"abin32-gnu-linux-mips64", "mipsn32"},
+	{"abi64-gnu-linux-mips64r6el", "mips64r6el"},
+	{"abi64-gnu-linux-mips64r6", "mips64r6"},
+	{"abi64-gnu-linux-mips64el", "mips64el"},
+	{"abi64-gnu-linux-mips64", "mips64"},
+	{"spe-gnu-linux-powerpc", "powerpcspe"},
+	{"x32-gnu-linux-amd64", "x32"},
+	{"base-gnu-linux-<cpu>", "<cpu>"},
+	{"eabihf-gnu-kfreebsd-arm", "kfreebsd-armhf"},
+	{"base-gnu-kfreebsd-<cpu>", "kfreebsd-<cpu>"},
+	{"base-gnu-knetbsd-<cpu>", "knetbsd-<cpu>"},
+	{"base-gnu-kopensolaris-<cpu>", "kopensolaris-<cpu>"},
+	{"base-gnu-hurd-<cpu>", "hurd-<cpu>"},
+	{"base-bsd-dragonflybsd-<cpu>", "dragonflybsd-<cpu>"},
+	{"base-bsd-freebsd-<cpu>", "freebsd-<cpu>"},
+	{"base-bsd-openbsd-<cpu>", "openbsd-<cpu>"},
+	{"base-bsd-netbsd-<cpu>", "netbsd-<cpu>"},
+	{"base-bsd-darwin-<cpu>", "darwin-<cpu>"},
+	{"base-sysv-aix-<cpu>", "aix-<cpu>"},
+	{"base-sysv-solaris-<cpu>", "solaris-<cpu>"},
+	{"eabi-uclibc-uclinux-arm", "uclinux-armel"},
+	{"base-uclibc-uclinux-<cpu>", "uclinux-<cpu>"},
+	{"base-tos-mint-m68k", "mint-m68k"},
+}
+var (
+	tableRegexesOnce sync.Once
+	cpuRegexes       []*regexp.Regexp
+	osRegexes        []*regexp.Regexp
+)
+
+/* The regular expressions of the tables, anchored */
+func tableRegexes() ([]*regexp.Regexp, []*regexp.Regexp) {
+	tableRegexesOnce.Do(func() {
+		for _, cpu := range cpuTable {
+			cpuRegexes = append(cpuRegexes, regexp.MustCompile("^(?:"+cpu.regex+")$"))
+		}
+		for _, os := range osTable {
+			osRegexes = append(osRegexes, regexp.MustCompile("^(?:"+os.regex+")$"))
+		}
+	})
+	return cpuRegexes, osRegexes
+}
+
+func knownCPU(name string) bool {
+	for _, cpu := range cpuTable {
+		if cpu.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// }}}
+
+// Tuples {{{
+
+// ArchTuple is the dpkg architecture tuple of an architecture, such as
+// eabihf-gnu-linux-arm for armhf, see dpkg-architecture(1).
+type ArchTuple struct {
+	ABI  string
+	Libc string
+	OS   string
+	CPU  string
+}
+
+func (t ArchTuple) String() string {
+	return t.ABI + "-" + t.Libc + "-" + t.OS + "-" + t.CPU
+}
+
+/* The ABI, libc and OS parts, as named in the osTable */
+func (t ArchTuple) system() string {
+	return t.ABI + "-" + t.Libc + "-" + t.OS
+}
+
+func parseTuple(tuple string) (*ArchTuple, error) {
+	parts := strings.Split(tuple, "-")
+	if len(parts) != 4 {
+		return nil, fmt.Errorf("Malformed architecture tuple '%s'", tuple)
+	}
+	return &ArchTuple{ABI: parts[0], Libc: parts[1], OS: parts[2], CPU: parts[3]}, nil
+}
+
+// ArchToTuple returns the tuple of an architecture name, such as
+// base-gnu-linux-amd64 for amd64. Unknown architectures are errors.
+func ArchToTuple(arch string) (*ArchTuple, error) {
+	for _, row := range tupleTable {
+		i := strings.Index(row.arch, "<cpu>")
+		if i < 0 {
+			if row.arch == arch {
+				return parseTuple(row.tuple)
+			}
+			continue
+		}
+		prefix, suffix := row.arch[:i], row.arch[i+5:]
+		if len(arch) <= len(prefix)+len(suffix) ||
+			!strings.HasPrefix(arch, prefix) || !strings.HasSuffix(arch, suffix) {
+			continue
+		}
+		cpu := arch[len(prefix) : len(arch)-len(suffix)]
+		if knownCPU(cpu) {
+			return parseTuple(strings.Replace(row.tuple, "<cpu>", cpu, 1))
+		}
+	}
+	return nil, fmt.Errorf("Unknown architecture '%s'", arch)
+}
+
+// TupleToArch returns the architecture name of a tuple, such as armhf for
+// eabihf-gnu-linux-arm.
+func TupleToArch(tuple ArchTuple) (string, error) {
+	if knownCPU(tuple.CPU) {
+		for _, row := range tupleTable {
+			if strings.Replace(row.tuple, "<cpu>", tuple.CPU, 1) == tuple.String() {
+				return strings.Replace(row.arch, "<cpu>", tuple.CPU, 1), nil
+			}
+		}
+	}
+	return "", fmt.Errorf("Unknown architecture tuple '%s'", tuple)
+}
+
+// IsKnownArch checks if the architecture name is one dpkg knows of.
+func IsKnownArch(arch string) bool {
+	_, err := ArchToTuple(arch)
+	return err == nil
+}
+
+// KnownArchitectures returns the names of all the architectures dpkg
+// knows of, sorted, as dpkg-architecture --list-known does.
+func KnownArchitectures() []string {
+	seen := map[string]bool{}
+	for _, row := range tupleTable {
+		cpus := []string{""}
+		if strings.Contains(row.tuple, "<cpu>") {
+			cpus = cpus[:0]
+			for _, cpu := range cpuTable {
+				cpus = append(cpus, cpu.name)
+			}
+		}
+		for _, cpu := range cpus {
+			arch := strings.Replace(row.arch, "<cpu>", cpu, 1)
+			/* Only if this row is the one the arch maps to */
+			tuple, err := ArchToTuple(arch)
+			if err == nil && tuple.String() == strings.Replace(row.tuple, "<cpu>", cpu, 1) {
+				seen[arch] = true
+			}
+		}
+	}
+	ret := []string{}
+	for arch := range seen {
+		ret = append(ret, arch)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// }}}
+
+// GNU triplets {{{
+
+// ArchToTriplet returns the GNU triplet of an architecture name, such as
+// arm-linux-gnueabihf for armhf, as DEB_HOST_GNU_TYPE.
+func ArchToTriplet(arch string) (string, error) {
+	tuple, err := ArchToTuple(arch)
+	if err != nil {
+		return "", err
+	}
+	cpu, os := "", ""
+	for _, row := range cpuTable {
+		if row.name == tuple.CPU {
+			cpu = row.gnu
+		}
+	}
+	for _, row := range osTable {
+		if row.name == tuple.system() {
+			os = row.gnu
+		}
+	}
+	if cpu == "" || os == "" {
+		return "", fmt.Errorf("No GNU triplet for the architecture '%s'", arch)
+	}
+	return cpu + "-" + os, nil
+}
+
+// TripletToArch returns the architecture name of a GNU triplet, such as
+// amd64 for x86_64-linux-gnu; i586-linux-gnu is i386, as dpkg matches the
+// CPU and the system with regular expressions.
+func TripletToArch(triplet string) (string, error) {
+	parts := strings.SplitN(triplet, "-", 2)
+	if len(parts) != 2 {
+		return "", fmt.Errorf("Malformed GNU triplet '%s'", triplet)
+	}
+	cpus, oses := tableRegexes()
+	tuple := ArchTuple{}
+	for i, regex := range cpus {
+		if regex.MatchString(parts[0]) {
+			tuple.CPU = cpuTable[i].name
+			break
+		}
+	}
+	for i, regex := range oses {
+		if regex.MatchString(parts[1]) {
+			system, err := parseTuple(osTable[i].name + "-" + tuple.CPU)
+			if err != nil {
+				return "", err
+			}
+			tuple.ABI, tuple.Libc, tuple.OS = system.ABI, system.Libc, system.OS
+			break
+		}
+	}
+	if tuple.CPU == "" || tuple.OS == "" {
+		return "", fmt.Errorf("Unknown GNU triplet '%s'", triplet)
+	}
+	return TupleToArch(tuple)
+}
+
+// ArchToMultiarch returns the multiarch tuple of an architecture name,
+// the directory of its libraries under /usr/lib, such as
+// i386-linux-gnu for i386, as DEB_HOST_MULTIARCH. It's the GNU triplet,
+// but for the CPU of i386, which is i386 rather than i686.
+func ArchToMultiarch(arch string) (string, error) {
+	triplet, err := ArchToTriplet(arch)
+	if err != nil {
+		return "", err
+	}
+	if strings.HasPrefix(triplet, "i686-") {
+		triplet = "i386-" + triplet[5:]
+	}
+	return triplet, nil
+}
+
+// ArchBits returns the size of the pointers of an architecture, 32 or 64,
+// as DEB_HOST_ARCH_BITS, and its endianness, "little" or "big", as
+// DEB_HOST_ARCH_ENDIAN.
+func ArchBits(arch string) (int, string, error) {
+	tuple, err := ArchToTuple(arch)
+	if err != nil {
+		return 0, "", err
+	}
+	for _, row := range cpuTable {
+		if row.name != tuple.CPU {
+			continue
+		}
+		bits := row.bits
+		/* The ILP32 ABIs of 64 bits CPUs, such as x32, per the abitable */
+		switch tuple.ABI {
+		case "abin32", "ilp32", "x32":
+			bits = 32
+		}
+		return bits, row.endian, nil
+	}
+	return 0, "", fmt.Errorf("Unknown architecture '%s'", arch)
+}
+
+// }}}
+
+// vim: foldmethod=marker
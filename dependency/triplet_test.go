@@ -0,0 +1,117 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package dependency_test
+
+import (
+	"testing"
+
+	"github.com/ebikt/go-debian/dependency"
+)
+
+/*
+ *
+ */
+
+func TestArchTriplets(t *testing.T) {
+	for _, el := range []struct {
+		arch, tuple, triplet, multiarch string
+		bits                            int
+		endian                          string
+	}{
+		{"amd64", "base-gnu-linux-amd64", "x86_64-linux-gnu", "x86_64-linux-gnu", 64, "little"},
+		{"i386", "base-gnu-linux-i386", "i686-linux-gnu", "i386-linux-gnu", 32, "little"},
+		{"armhf", "eabihf-gnu-linux-arm", "arm-linux-gnueabihf", "arm-linux-gnueabihf", 32, "little"},
+		{"armel", "eabi-gnu-linux-arm", "arm-linux-gnueabi", "arm-linux-gnueabi", 32, "little"},
+		{"arm64", "base-gnu-linux-arm64", "aarch64-linux-gnu", "aarch64-linux-gnu", 64, "little"},
+		{"ppc64", "base-gnu-linux-ppc64", "powerpc64-linux-gnu", "powerpc64-linux-gnu", 64, "big"},
+		{"x32", "x32-gnu-linux-amd64", "x86_64-linux-gnux32", "x86_64-linux-gnux32", 32, "little"},
+		{"mips64el", "abi64-gnu-linux-mips64el", "mips64el-linux-gnuabi64", "mips64el-linux-gnuabi64", 64, "little"},
+		{"hurd-i386", "base-gnu-hurd-i386", "i686-gnu", "i386-gnu", 32, "little"},
+		{"kfreebsd-amd64", "base-gnu-kfreebsd-amd64", "x86_64-kfreebsd-gnu", "x86_64-kfreebsd-gnu", 64, "little"},
+		{"musl-linux-armhf", "eabihf-musl-linux-arm", "arm-linux-musleabihf", "arm-linux-musleabihf", 32, "little"},
+	} {
+		tuple, err := dependency.ArchToTuple(el.arch)
+		isok(t, err)
+		assert(t, tuple.String() == el.tuple)
+		arch, err := dependency.TupleToArch(*tuple)
+		isok(t, err)
+		assert(t, arch == el.arch)
+
+		triplet, err := dependency.ArchToTriplet(el.arch)
+		isok(t, err)
+		assert(t, triplet == el.triplet)
+		arch, err = dependency.TripletToArch(triplet)
+		isok(t, err)
+		assert(t, arch == el.arch)
+
+		multiarch, err := dependency.ArchToMultiarch(el.arch)
+		isok(t, err)
+		assert(t, multiarch == el.multiarch)
+
+		bits, endian, err := dependency.ArchBits(el.arch)
+		isok(t, err)
+		assert(t, bits == el.bits && endian == el.endian)
+	}
+
+	/* Triplets are matched like config.guess output */
+	for triplet, expected := range map[string]string{
+		"i586-linux-gnu":      "i386",
+		"amd64-linux-gnu":     "amd64",
+		"powerpc-linux-gnu":   "powerpc",
+		"ppc-linux-gnu":       "powerpc",
+		"x86_64-freebsd13.2":  "freebsd-amd64",
+		"arm-linux-gnueabihf": "armhf",
+	} {
+		arch, err := dependency.TripletToArch(triplet)
+		isok(t, err)
+		assert(t, arch == expected)
+	}
+}
+
+func TestUnknownArch(t *testing.T) {
+	for _, arch := range []string{"armhff", "linux-any", "any", "all", "", "amd64-linux"} {
+		assert(t, !dependency.IsKnownArch(arch))
+		_, err := dependency.ArchToTriplet(arch)
+		notok(t, err)
+	}
+	for _, triplet := range []string{"x86_64", "vax-linux-gnu", "x86_64-beos"} {
+		_, err := dependency.TripletToArch(triplet)
+		notok(t, err)
+	}
+	_, err := dependency.TupleToArch(dependency.ArchTuple{ABI: "base", Libc: "gnu", OS: "linux", CPU: "vax"})
+	notok(t, err)
+}
+
+func TestKnownArchitectures(t *testing.T) {
+	known := dependency.KnownArchitectures()
+	seen := map[string]bool{}
+	for _, arch := range known {
+		seen[arch] = true
+		assert(t, dependency.IsKnownArch(arch))
+	}
+	for _, arch := range []string{"amd64", "armhf", "x32", "hurd-amd64", "mipsn32r6el", "mint-m68k"} {
+		assert(t, seen[arch])
+	}
+	/* armhf only as eabihf-gnu-linux-arm, not as base-gnu-linux-armhf */
+	assert(t, !seen["armhf-armhf"] && !seen["linux-armhf"])
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,266 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package dpkg // import "github.com/ebikt/go-debian/dpkg"
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+)
+
+// Alternatives {{{
+
+// An AlternativeLink is the link of an alternative group, such as
+// /usr/bin/editor, and the name of the symlink of /etc/alternatives it
+// points to, such as "editor".
+type AlternativeLink struct {
+	Name string
+	Link string
+}
+
+// An Alternative is a choice of an AlternativeGroup.
+type Alternative struct {
+	Path     string
+	Priority int
+
+	// Paths of the slaves, keyed on their names. Slaves the alternative
+	// doesn't provide are left out.
+	Slaves map[string]string
+}
+
+// An AlternativeGroup is what update-alternatives knows of a group of
+// alternatives, such as "editor".
+type AlternativeGroup struct {
+	AlternativeLink
+
+	// Whether the alternative was chosen by the administrator, rather than
+	// by priority.
+	Manual bool
+
+	Slaves       []AlternativeLink
+	Alternatives []Alternative
+
+	// The alternative the symlink of /etc/alternatives points to, when
+	// read by ReadAlternatives.
+	Current string
+}
+
+// ParseAlternativeGroup parses an administrative file of
+// update-alternatives, as found in /var/lib/dpkg/alternatives/<name>.
+func ParseAlternativeGroup(name string, reader io.Reader) (*AlternativeGroup, error) {
+	scanner := bufio.NewScanner(reader)
+	next := func(what string) (string, error) {
+		if !scanner.Scan() {
+			if err := scanner.Err(); err != nil {
+				return "", err
+			}
+			return "", fmt.Errorf("%s: Missing %s", name, what)
+		}
+		return scanner.Text(), nil
+	}
+
+	ret := AlternativeGroup{AlternativeLink: AlternativeLink{Name: name}}
+	status, err := next("status")
+	if err != nil {
+		return nil, err
+	}
+	switch status {
+	case "auto":
+	case "manual":
+		ret.Manual = true
+	default:
+		return nil, fmt.Errorf("%s: Unknown status '%s'", name, status)
+	}
+	if ret.Link, err = next("link"); err != nil {
+		return nil, err
+	}
+
+	for {
+		slave, err := next("slave")
+		if err != nil {
+			return nil, err
+		}
+		if slave == "" {
+			break
+		}
+		link, err := next("slave link")
+		if err != nil {
+			return nil, err
+		}
+		ret.Slaves = append(ret.Slaves, AlternativeLink{Name: slave, Link: link})
+	}
+
+	for {
+		path, err := next("alternative")
+		if err != nil {
+			return nil, err
+		}
+		if path == "" {
+			break
+		}
+		alternative := Alternative{Path: path, Slaves: map[string]string{}}
+		priority, err := next("priority")
+		if err != nil {
+			return nil, err
+		}
+		if alternative.Priority, err = strconv.Atoi(priority); err != nil {
+			return nil, fmt.Errorf("%s: Malformed priority '%s' of %s", name, priority, path)
+		}
+		for _, slave := range ret.Slaves {
+			slavePath, err := next("slave path")
+			if err != nil {
+				return nil, err
+			}
+			if slavePath != "" {
+				alternative.Slaves[slave.Name] = slavePath
+			}
+		}
+		ret.Alternatives = append(ret.Alternatives, alternative)
+	}
+	return &ret, nil
+}
+
+// Lookup returns the alternative of the given path, or nil if it isn't
+// one of the group.
+func (g AlternativeGroup) Lookup(path string) *Alternative {
+	for i := range g.Alternatives {
+		if g.Alternatives[i].Path == path {
+			return &g.Alternatives[i]
+		}
+	}
+	return nil
+}
+
+// Best returns the alternative of the highest priority, the first one if
+// several have it, which is the one chosen in auto mode, or nil if the
+// group has none.
+func (g AlternativeGroup) Best() *Alternative {
+	var ret *Alternative
+	for i := range g.Alternatives {
+		if ret == nil || g.Alternatives[i].Priority > ret.Priority {
+			ret = &g.Alternatives[i]
+		}
+	}
+	return ret
+}
+
+// Selected returns the alternative in use: the Current one if known, and
+// the Best one otherwise.
+func (g AlternativeGroup) Selected() *Alternative {
+	if g.Current != "" {
+		if alternative := g.Lookup(g.Current); alternative != nil {
+			return alternative
+		}
+	}
+	return g.Best()
+}
+
+// AlternativeGroups are all the groups update-alternatives knows of.
+type AlternativeGroups []AlternativeGroup
+
+// ReadAlternatives reads the administrative files of update-alternatives,
+// in the alternatives directory of the given dpkg administrative
+// directory, and the symlinks of altdir, /etc/alternatives on the running
+// system, telling the Current alternatives; these are left empty if
+// altdir is empty.
+func ReadAlternatives(admindir, altdir string) (AlternativeGroups, error) {
+	dir := filepath.Join(admindir, "alternatives")
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return AlternativeGroups{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	ret := AlternativeGroups{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		f, err := os.Open(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		group, err := ParseAlternativeGroup(entry.Name(), f)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+		if altdir != "" {
+			current, err := os.Readlink(filepath.Join(altdir, entry.Name()))
+			if err != nil && !os.IsNotExist(err) {
+				return nil, err
+			}
+			group.Current = current
+		}
+		ret = append(ret, *group)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Name < ret[j].Name
+	})
+	return ret, nil
+}
+
+// Lookup returns the group of the given name, or nil if there's none.
+func (g AlternativeGroups) Lookup(name string) *AlternativeGroup {
+	for i := range g {
+		if g[i].Name == name {
+			return &g[i]
+		}
+	}
+	return nil
+}
+
+// Resolve returns what the link of a group or one of its slaves, such
+// as /usr/bin/editor, points to through /etc/alternatives: the path of
+// the Selected alternative, or of its slave. It returns false if the
+// link isn't one of a group, or if the alternative doesn't provide the
+// slave.
+func (g AlternativeGroups) Resolve(link string) (string, bool) {
+	for _, group := range g {
+		selected := group.Selected()
+		if group.Link == link {
+			if selected == nil {
+				return "", false
+			}
+			return selected.Path, true
+		}
+		for _, slave := range group.Slaves {
+			if slave.Link != link {
+				continue
+			}
+			if selected == nil {
+				return "", false
+			}
+			path, ok := selected.Slaves[slave.Name]
+			return path, ok
+		}
+	}
+	return "", false
+}
+
+// }}}
+
+// vim: foldmethod=marker
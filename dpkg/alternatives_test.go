@@ -0,0 +1,133 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package dpkg_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ebikt/go-debian/dpkg"
+)
+
+/*
+ *
+ */
+
+const editorAlternatives = `auto
+/usr/bin/editor
+editor.1.gz
+/usr/share/man/man1/editor.1.gz
+editor.fr.1.gz
+/usr/share/man/fr/man1/editor.1.gz
+
+/bin/nano
+40
+/usr/share/man/man1/nano.1.gz
+
+/usr/bin/vim.basic
+30
+/usr/share/man/man1/vim.1.gz
+/usr/share/man/fr/man1/vim.1.gz
+
+`
+
+func TestParseAlternativeGroup(t *testing.T) {
+	group, err := dpkg.ParseAlternativeGroup("editor", strings.NewReader(editorAlternatives))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if group.Name != "editor" || group.Link != "/usr/bin/editor" || group.Manual ||
+		len(group.Slaves) != 2 || len(group.Alternatives) != 2 {
+		t.Fatalf("Unexpected group: %v", group)
+	}
+	if best := group.Best(); best == nil || best.Path != "/bin/nano" || best.Priority != 40 {
+		t.Fatalf("Unexpected best alternative: %v", best)
+	}
+	if _, ok := group.Alternatives[0].Slaves["editor.fr.1.gz"]; ok {
+		t.Fatal("nano has no French manual page")
+	}
+	if group.Alternatives[1].Slaves["editor.fr.1.gz"] != "/usr/share/man/fr/man1/vim.1.gz" {
+		t.Fatalf("Unexpected slaves: %v", group.Alternatives[1].Slaves)
+	}
+
+	for _, bad := range []string{
+		"",
+		"broken\n/usr/bin/editor\n\n\n",
+		"auto\n/usr/bin/editor\n\n/bin/nano\nforty\n\n",
+		"auto\n/usr/bin/editor\n\n/bin/nano\n",
+	} {
+		if _, err := dpkg.ParseAlternativeGroup("editor", strings.NewReader(bad)); err == nil {
+			t.Fatalf("Parsed %q", bad)
+		}
+	}
+}
+
+func TestReadAlternatives(t *testing.T) {
+	admindir := t.TempDir()
+	altdir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(admindir, "alternatives"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(admindir, "alternatives", "editor"),
+		[]byte(strings.Replace(editorAlternatives, "auto", "manual", 1)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("/usr/bin/vim.basic", filepath.Join(altdir, "editor")); err != nil {
+		t.Fatal(err)
+	}
+
+	groups, err := dpkg.ReadAlternatives(admindir, altdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	editor := groups.Lookup("editor")
+	if editor == nil || !editor.Manual || editor.Current != "/usr/bin/vim.basic" {
+		t.Fatalf("Unexpected groups: %v", groups)
+	}
+
+	for link, expected := range map[string]string{
+		"/usr/bin/editor":                    "/usr/bin/vim.basic",
+		"/usr/share/man/fr/man1/editor.1.gz": "/usr/share/man/fr/man1/vim.1.gz",
+	} {
+		if actual, ok := groups.Resolve(link); !ok || actual != expected {
+			t.Fatalf("%s: expected %s, got %s", link, expected, actual)
+		}
+	}
+	if _, ok := groups.Resolve("/usr/bin/pager"); ok {
+		t.Fatal("Resolved /usr/bin/pager")
+	}
+
+	/* Without the symlinks, the best alternative is assumed */
+	groups, err = dpkg.ReadAlternatives(admindir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual, ok := groups.Resolve("/usr/bin/editor"); !ok || actual != "/bin/nano" {
+		t.Fatalf("Unexpected resolution: %s", actual)
+	}
+	if _, ok := groups.Resolve("/usr/share/man/fr/man1/editor.1.gz"); ok {
+		t.Fatal("nano has no French manual page")
+	}
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,241 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package dpkg // import "github.com/ebikt/go-debian/dpkg"
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ebikt/go-debian/control"
+)
+
+// Conffile {{{
+
+// A Conffile is one entry of the Conffiles field of the dpkg status
+// database, recording the md5sum a configuration file had when dpkg last
+// installed it.
+type Conffile struct {
+	Path string
+	MD5  string
+
+	// Set when the conffile is no longer shipped by the package.
+	Obsolete bool
+
+	// Set when the package asked for the conffile to be removed on the
+	// next upgrade.
+	RemoveOnUpgrade bool
+}
+
+// The md5sum dpkg records for a conffile it has not finished installing.
+const newConffileHash = "newconffile"
+
+// ParseConffiles parses the value of a Conffiles field of the dpkg status
+// database, which looks like:
+//
+//   /etc/foo.conf 5d41402abc4b2a76b9719d911017c592
+//   /etc/bar.conf 0c5b1b6a9a6e6a53b0c2a5e2e4f1b6b4 obsolete
+func ParseConffiles(value string) ([]Conffile, error) {
+	ret := []Conffile{}
+	for _, line := range strings.Split(value, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("Malformed Conffiles line: '%s'", line)
+		}
+		conffile := Conffile{Path: fields[0], MD5: fields[1]}
+		for _, flag := range fields[2:] {
+			switch flag {
+			case "obsolete":
+				conffile.Obsolete = true
+			case "remove-on-upgrade":
+				conffile.RemoveOnUpgrade = true
+			default:
+				return nil, fmt.Errorf("Unknown Conffiles flag: '%s'", flag)
+			}
+		}
+		ret = append(ret, conffile)
+	}
+	return ret, nil
+}
+
+// }}}
+
+// ConffileChecker {{{
+
+// ConffileState describes how a conffile on disk relates to the md5sum
+// recorded in the status database.
+type ConffileState int
+
+const (
+	// The conffile matches the md5sum dpkg recorded.
+	ConffileUnmodified ConffileState = iota
+
+	// The conffile was edited locally.
+	ConffileModified
+
+	// The conffile was deleted locally.
+	ConffileDeleted
+
+	// dpkg has no md5sum for this conffile yet, so it can't be checked.
+	ConffileUnknown
+)
+
+func (s ConffileState) String() string {
+	switch s {
+	case ConffileUnmodified:
+		return "unmodified"
+	case ConffileModified:
+		return "modified"
+	case ConffileDeleted:
+		return "deleted"
+	}
+	return "unknown"
+}
+
+// ConffileResult is the outcome of checking a single Conffile.
+type ConffileResult struct {
+	Package  string
+	Conffile Conffile
+	State    ConffileState
+
+	// md5sum of the file on disk, if it exists.
+	MD5 string
+}
+
+// Modified returns true if the conffile was changed or deleted locally.
+func (r ConffileResult) Modified() bool {
+	return r.State == ConffileModified || r.State == ConffileDeleted
+}
+
+// ConffileChecker hashes conffiles on disk and compares them against the
+// md5sums recorded in the dpkg status database, much like `dpkg --verify`
+// does for conffiles. Unlike the md5sums of regular files, a changed
+// conffile is a local modification rather than corruption.
+type ConffileChecker struct {
+	// Directory the conffile paths are relative to, "/" for the running
+	// system.
+	Root string
+}
+
+// Check the given conffiles of the package `pkg`.
+func (c ConffileChecker) Check(pkg string, conffiles []Conffile) ([]ConffileResult, error) {
+	ret := []ConffileResult{}
+	for _, conffile := range conffiles {
+		result := ConffileResult{Package: pkg, Conffile: conffile}
+		sum, err := md5File(filepath.Join(c.Root, conffile.Path))
+		switch {
+		case os.IsNotExist(err):
+			result.State = ConffileDeleted
+		case err != nil:
+			return nil, err
+		case conffile.MD5 == newConffileHash:
+			result.MD5 = sum
+			result.State = ConffileUnknown
+		case sum != conffile.MD5:
+			result.MD5 = sum
+			result.State = ConffileModified
+		default:
+			result.MD5 = sum
+			result.State = ConffileUnmodified
+		}
+		ret = append(ret, result)
+	}
+	return ret, nil
+}
+
+// CheckStatus checks the conffiles of every package of a dpkg status
+// database, as found in /var/lib/dpkg/status, skipping packages that are
+// not installed. Obsolete conffiles are skipped as well, since dpkg
+// doesn't care about them anymore.
+func (c ConffileChecker) CheckStatus(reader io.Reader) ([]ConffileResult, error) {
+	paragraphs, err := control.NewParagraphReader(reader, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := []ConffileResult{}
+	for {
+		paragraph, err := paragraphs.Next()
+		if err == io.EOF {
+			return ret, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		status := strings.Fields(paragraph.Get("Status"))
+		if len(status) == 3 && status[2] == "not-installed" {
+			continue
+		}
+
+		conffiles, err := ParseConffiles(paragraph.Get("Conffiles"))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", paragraph.Get("Package"), err)
+		}
+		current := []Conffile{}
+		for _, conffile := range conffiles {
+			if !conffile.Obsolete {
+				current = append(current, conffile)
+			}
+		}
+
+		results, err := c.Check(paragraph.Get("Package"), current)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, results...)
+	}
+}
+
+// CheckStatusFile checks the conffiles of every package of the dpkg status
+// database at the given path, relative to the Root.
+func (c ConffileChecker) CheckStatusFile(path string) ([]ConffileResult, error) {
+	f, err := os.Open(filepath.Join(c.Root, path))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return c.CheckStatus(f)
+}
+
+func md5File(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	hash := md5.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// }}}
+
+// vim: foldmethod=marker
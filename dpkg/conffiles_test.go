@@ -0,0 +1,138 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package dpkg_test
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"testing"
+
+	"github.com/ebikt/go-debian/dpkg"
+)
+
+/*
+ *
+ */
+
+func isok(t *testing.T, err error) {
+	if err != nil {
+		log.Printf("Error! Error is not nil! %s\n", err)
+		debug.PrintStack()
+		t.FailNow()
+	}
+}
+
+func notok(t *testing.T, err error) {
+	if err == nil {
+		log.Printf("Error! Error is nil!\n")
+		debug.PrintStack()
+		t.FailNow()
+	}
+}
+
+func assert(t *testing.T, expr bool) {
+	if !expr {
+		log.Printf("Assertion failed!")
+		debug.PrintStack()
+		t.FailNow()
+	}
+}
+
+func writeFile(t *testing.T, root, path, data string) {
+	path = filepath.Join(root, path)
+	isok(t, os.MkdirAll(filepath.Dir(path), 0755))
+	isok(t, ioutil.WriteFile(path, []byte(data), 0644))
+}
+
+/*
+ *
+ */
+
+func TestParseConffiles(t *testing.T) {
+	conffiles, err := dpkg.ParseConffiles(`
+ /etc/foo.conf 5d41402abc4b2a76b9719d911017c592
+ /etc/bar.conf 7d793037a0760186574b0282f2f435e7 obsolete
+ /etc/baz.conf newconffile remove-on-upgrade`)
+	isok(t, err)
+	assert(t, len(conffiles) == 3)
+	assert(t, conffiles[0].Path == "/etc/foo.conf")
+	assert(t, conffiles[0].MD5 == "5d41402abc4b2a76b9719d911017c592")
+	assert(t, conffiles[1].Obsolete)
+	assert(t, conffiles[2].RemoveOnUpgrade)
+
+	_, err = dpkg.ParseConffiles("/etc/foo.conf")
+	notok(t, err)
+	_, err = dpkg.ParseConffiles("/etc/foo.conf 5d41402abc4b2a76b9719d911017c592 bogus")
+	notok(t, err)
+}
+
+func TestConffileCheckStatus(t *testing.T) {
+	root, err := ioutil.TempDir("", "go-debian-dpkg")
+	isok(t, err)
+	defer os.RemoveAll(root)
+
+	/* md5("hello") and md5("world") */
+	writeFile(t, root, "etc/hello.conf", "hello")
+	writeFile(t, root, "etc/world.conf", "edited")
+	writeFile(t, root, "etc/old.conf", "whatever")
+
+	status := `Package: hello
+Status: install ok installed
+Version: 1.0
+Conffiles:
+ /etc/hello.conf 5d41402abc4b2a76b9719d911017c592
+ /etc/world.conf 7d793037a0760186574b0282f2f435e7
+ /etc/gone.conf 7d793037a0760186574b0282f2f435e7
+ /etc/old.conf 7d793037a0760186574b0282f2f435e7 obsolete
+
+Package: removed
+Status: deinstall ok not-installed
+Conffiles:
+ /etc/removed.conf 7d793037a0760186574b0282f2f435e7
+
+Package: nothing
+Status: install ok installed
+`
+	writeFile(t, root, "var/lib/dpkg/status", status)
+
+	checker := dpkg.ConffileChecker{Root: root}
+	results, err := checker.CheckStatusFile("var/lib/dpkg/status")
+	isok(t, err)
+	assert(t, len(results) == 3)
+
+	assert(t, results[0].Package == "hello")
+	assert(t, results[0].State == dpkg.ConffileUnmodified)
+	assert(t, !results[0].Modified())
+	assert(t, results[1].State == dpkg.ConffileModified)
+	assert(t, results[1].Modified())
+	assert(t, results[1].MD5 != results[1].Conffile.MD5)
+	assert(t, results[2].State == dpkg.ConffileDeleted)
+	assert(t, results[2].State.String() == "deleted")
+
+	_, err = checker.CheckStatus(strings.NewReader("Package: broken\nConffiles:\n /etc/foo\n"))
+	notok(t, err)
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,117 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package dpkg // import "github.com/ebikt/go-debian/dpkg"
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Diversions {{{
+
+// A Diversion is an entry of the diversions database of dpkg, telling it
+// to install the file From of any package other than Package as To.
+type Diversion struct {
+	From string
+	To   string
+
+	// The package the diversion is for, whose own file isn't diverted, or
+	// empty for a local diversion, which applies to all packages.
+	Package string
+}
+
+// Local returns whether the diversion was made by the administrator,
+// rather than by a package.
+func (d Diversion) Local() bool {
+	return d.Package == ""
+}
+
+// Diversions is the diversions database of dpkg.
+type Diversions []Diversion
+
+// ParseDiversions parses the diversions database of dpkg, as found in
+// /var/lib/dpkg/diversions: three lines per diversion, the diverted path,
+// where it's diverted to, and the package the diversion is for, ":" for
+// a local one.
+func ParseDiversions(reader io.Reader) (Diversions, error) {
+	lines := []string{}
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(lines)%3 != 0 {
+		return nil, fmt.Errorf("Truncated diversions database")
+	}
+
+	ret := Diversions{}
+	for i := 0; i < len(lines); i += 3 {
+		diversion := Diversion{From: lines[i], To: lines[i+1], Package: lines[i+2]}
+		if diversion.Package == ":" {
+			diversion.Package = ""
+		}
+		ret = append(ret, diversion)
+	}
+	return ret, nil
+}
+
+// ReadDiversions reads the diversions database in the given dpkg
+// administrative directory, which is empty if there's none.
+func ReadDiversions(admindir string) (Diversions, error) {
+	f, err := os.Open(filepath.Join(admindir, "diversions"))
+	if os.IsNotExist(err) {
+		return Diversions{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseDiversions(f)
+}
+
+// Lookup returns the diversion of the path, or nil if it isn't diverted.
+func (d Diversions) Lookup(path string) *Diversion {
+	for i := range d {
+		if d[i].From == path {
+			return &d[i]
+		}
+	}
+	return nil
+}
+
+// Divert returns where dpkg installs the file `path` of the package
+// `pkg`: where it's diverted to, unless the diversion is for that very
+// package, as dpkg-divert --truename does.
+func (d Diversions) Divert(path, pkg string) string {
+	diversion := d.Lookup(path)
+	if diversion == nil || (diversion.Package != "" && diversion.Package == pkg) {
+		return path
+	}
+	return diversion.To
+}
+
+// }}}
+
+// vim: foldmethod=marker
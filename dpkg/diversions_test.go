@@ -0,0 +1,92 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package dpkg_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ebikt/go-debian/dpkg"
+)
+
+/*
+ *
+ */
+
+const diversionsDatabase = `/usr/bin/firefox
+/usr/bin/firefox.real
+firefox-esr
+/etc/issue
+/etc/issue.distrib
+:
+`
+
+func TestParseDiversions(t *testing.T) {
+	diversions, err := dpkg.ParseDiversions(strings.NewReader(diversionsDatabase))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diversions) != 2 {
+		t.Fatalf("Unexpected diversions: %v", diversions)
+	}
+	if diversions[0].Local() || !diversions[1].Local() {
+		t.Fatalf("Unexpected diversions: %v", diversions)
+	}
+
+	for _, test := range []struct {
+		path, pkg, expected string
+	}{
+		{"/usr/bin/firefox", "firefox", "/usr/bin/firefox.real"},
+		{"/usr/bin/firefox", "firefox-esr", "/usr/bin/firefox"},
+		{"/etc/issue", "base-files", "/etc/issue.distrib"},
+		{"/etc/motd", "base-files", "/etc/motd"},
+	} {
+		if actual := diversions.Divert(test.path, test.pkg); actual != test.expected {
+			t.Fatalf("%s of %s: expected %s, got %s", test.path, test.pkg, test.expected, actual)
+		}
+	}
+	if diversions.Lookup("/etc/motd") != nil {
+		t.Fatal("Unexpected diversion of /etc/motd")
+	}
+
+	if _, err := dpkg.ParseDiversions(strings.NewReader("/etc/issue\n/etc/issue.distrib\n")); err == nil {
+		t.Fatal("Parsed a truncated database")
+	}
+}
+
+func TestReadDiversions(t *testing.T) {
+	admindir := t.TempDir()
+	diversions, err := dpkg.ReadDiversions(admindir)
+	if err != nil || len(diversions) != 0 {
+		t.Fatalf("Unexpected diversions: %v %v", diversions, err)
+	}
+	if err := os.WriteFile(filepath.Join(admindir, "diversions"), []byte(diversionsDatabase), 0644); err != nil {
+		t.Fatal(err)
+	}
+	diversions, err = dpkg.ReadDiversions(admindir)
+	if err != nil || len(diversions) != 2 {
+		t.Fatalf("Unexpected diversions: %v %v", diversions, err)
+	}
+}
+
+// vim: foldmethod=marker
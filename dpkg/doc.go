@@ -0,0 +1,7 @@
+/*
+
+This module provides an API to inspect the state dpkg keeps about the
+packages installed on a host, such as the status database.
+
+*/
+package dpkg // import "github.com/ebikt/go-debian/dpkg"
@@ -0,0 +1,550 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package dpkg // import "github.com/ebikt/go-debian/dpkg"
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/ebikt/go-debian/control"
+	"github.com/ebikt/go-debian/deb"
+	"github.com/ebikt/go-debian/dependency"
+)
+
+// Installer {{{
+
+// Installer installs .deb files into the root of a system image without
+// running dpkg, nor chrooting into it. This is experimental: the files are
+// unpacked and the packages are recorded as installed in the status
+// database, but running the maintainer scripts is left to the caller.
+type Installer struct {
+	// Root of the system image.
+	Root string
+}
+
+// Script is a maintainer script to run, inside the image, to finish the
+// installation of a package.
+type Script struct {
+	Package string
+
+	// "preinst" or "postinst".
+	Name string
+
+	// Arguments to run the script with, such as "configure".
+	Args []string
+
+	// Path of the script, inside the image.
+	Path string
+}
+
+func (s Script) String() string {
+	return strings.Join(append([]string{s.Path}, s.Args...), " ")
+}
+
+// An unpacked package.
+type unpacked struct {
+	control control.Paragraph
+
+	// Members of the control tarball, other than control itself.
+	info map[string][]byte
+
+	// Paths of what was unpacked, in order, as recorded in the .list file.
+	files []string
+}
+
+func (u unpacked) name() string {
+	return u.control.Get("Package")
+}
+
+// infoName is the name of the package in the info directory, qualified by
+// its architecture for Multi-Arch: same packages.
+func (u unpacked) infoName() string {
+	if u.control.Get("Multi-Arch") == "same" {
+		return u.name() + ":" + u.control.Get("Architecture")
+	}
+	return u.name()
+}
+
+func (i Installer) admindir() string {
+	return filepath.Join(i.Root, "var/lib/dpkg")
+}
+
+// Install unpacks the given .deb files into the Root, records them in its
+// status database, and returns the maintainer scripts to run, in order:
+// the preinst of every package (which dpkg would have run before
+// unpacking it), then their postinst, ordered so that the Pre-Depends and
+// (where there is no cycle) the Depends of a package come first.
+func (i Installer) Install(debs ...string) ([]Script, error) {
+	admindir := i.admindir()
+	for _, dir := range []string{admindir, filepath.Join(admindir, "info"), filepath.Join(admindir, "updates")} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, err
+		}
+	}
+
+	lock, err := LockAdmin(admindir)
+	if err != nil {
+		return nil, err
+	}
+	defer lock.Unlock()
+
+	if err := checkJournal(admindir); err != nil {
+		return nil, err
+	}
+
+	packages := []unpacked{}
+	for _, filename := range debs {
+		pkg, err := i.unpackFile(filename)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", filename, err)
+		}
+		packages = append(packages, *pkg)
+	}
+
+	order, err := installOrder(packages)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := i.record(packages); err != nil {
+		return nil, err
+	}
+
+	scripts := []Script{}
+	for _, script := range []struct {
+		name string
+		args []string
+	}{{"preinst", []string{"install"}}, {"postinst", []string{"configure"}}} {
+		for _, index := range order {
+			pkg := packages[index]
+			if _, ok := pkg.info[script.name]; !ok {
+				continue
+			}
+			scripts = append(scripts, Script{
+				Package: pkg.name(),
+				Name:    script.name,
+				Args:    script.args,
+				Path:    "/var/lib/dpkg/info/" + pkg.infoName() + "." + script.name,
+			})
+		}
+	}
+	return scripts, nil
+}
+
+func (i Installer) unpackFile(filename string) (*unpacked, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	archive, err := deb.LoadAr(f)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := unpacked{info: map[string][]byte{}, files: []string{}}
+	seenControl := false
+	for {
+		member, err := archive.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case member.Name == "debian-binary":
+			version, err := ioutil.ReadAll(member.Data)
+			if err != nil {
+				return nil, err
+			}
+			if !strings.HasPrefix(string(version), "2.") {
+				return nil, fmt.Errorf("Unknown binary version: '%s'", strings.TrimSpace(string(version)))
+			}
+		case strings.HasPrefix(member.Name, "control.tar"):
+			tarball, err := member.Tarfile()
+			if err != nil {
+				return nil, err
+			}
+			if err := ret.readControl(tarball); err != nil {
+				return nil, err
+			}
+			seenControl = true
+		case strings.HasPrefix(member.Name, "data.tar"):
+			if !seenControl {
+				return nil, fmt.Errorf("Missing or out of order .deb member 'control'")
+			}
+			tarball, err := member.Tarfile()
+			if err != nil {
+				return nil, err
+			}
+			if err := i.unpackData(tarball, &ret); err != nil {
+				return nil, err
+			}
+			return &ret, nil
+		}
+	}
+	return nil, fmt.Errorf("Missing or out of order .deb member 'data'")
+}
+
+func (u *unpacked) readControl(tarball *tar.Reader) error {
+	for {
+		header, err := tarball.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		data, err := ioutil.ReadAll(tarball)
+		if err != nil {
+			return err
+		}
+		u.info[path.Clean(header.Name)] = data
+	}
+
+	data, ok := u.info["control"]
+	if !ok {
+		return fmt.Errorf("Missing control file")
+	}
+	delete(u.info, "control")
+	reader, err := control.NewParagraphReader(bytes.NewReader(data), nil)
+	if err != nil {
+		return err
+	}
+	paragraph, err := reader.Next()
+	if err != nil {
+		return err
+	}
+	if paragraph.Get("Package") == "" {
+		return fmt.Errorf("Control file has no Package")
+	}
+	u.control = *paragraph
+	return nil
+}
+
+func (i Installer) unpackData(tarball *tar.Reader, pkg *unpacked) error {
+	for {
+		header, err := tarball.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		name := path.Clean("/" + header.Name)
+		target, err := resolvePath(i.Root, name)
+		if err != nil {
+			return err
+		}
+		if name == "/" {
+			pkg.files = append(pkg.files, "/.")
+			continue
+		}
+		pkg.files = append(pkg.files, name)
+
+		mode := os.FileMode(header.Mode).Perm()
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if info, err := os.Lstat(target); err == nil && info.Mode()&os.ModeSymlink != 0 {
+				/* Keep symlinks to directories, such as /lib -> usr/lib */
+				continue
+			}
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+			if err := os.Chmod(target, mode); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := replace(target); err != nil {
+				return err
+			}
+			f, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(f, tarball)
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				return err
+			}
+			if err := os.Chmod(target, mode); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			if err := replace(target); err != nil {
+				return err
+			}
+			if err := os.Symlink(header.Linkname, target); err != nil {
+				return err
+			}
+		case tar.TypeLink:
+			source, err := resolvePath(i.Root, path.Clean("/"+header.Linkname))
+			if err != nil {
+				return err
+			}
+			if err := replace(target); err != nil {
+				return err
+			}
+			if err := os.Link(source, target); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("Unsupported type of %s in data", name)
+		}
+
+		if os.Geteuid() == 0 {
+			if err := os.Lchown(target, header.Uid, header.Gid); err != nil {
+				return err
+			}
+		}
+		if header.Typeflag != tar.TypeSymlink {
+			if err := os.Chtimes(target, header.ModTime, header.ModTime); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// replace removes what is at the given path, unless it's a directory,
+// so a new file can take its place.
+func replace(target string) error {
+	info, err := os.Lstat(target)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return fmt.Errorf("%s is a directory", target)
+	}
+	return os.Remove(target)
+}
+
+// resolvePath maps an absolute path of the image to a path on the host,
+// under root, following the symbolic links on the way as they would be
+// followed once root is /. The last component is not followed. Nothing
+// outside of the root can be reached, whatever links point to.
+func resolvePath(root, name string) (string, error) {
+	split := func(name string) []string {
+		return strings.Split(strings.Trim(path.Clean("/"+name), "/"), "/")
+	}
+
+	parts := split(name)
+	current := "/"
+	links := 0
+	for len(parts) > 0 {
+		part := parts[0]
+		parts = parts[1:]
+		if part == "" {
+			continue
+		}
+		next := path.Join(current, part)
+		if len(parts) == 0 {
+			current = next
+			break
+		}
+
+		info, err := os.Lstat(filepath.Join(root, next))
+		if err != nil || info.Mode()&os.ModeSymlink == 0 {
+			current = next
+			continue
+		}
+		if links++; links > 40 {
+			return "", fmt.Errorf("Too many levels of symbolic links in %s", name)
+		}
+		link, err := os.Readlink(filepath.Join(root, next))
+		if err != nil {
+			return "", err
+		}
+		if !path.IsAbs(link) {
+			link = path.Join(current, link)
+		}
+		parts = append(split(link), parts...)
+		current = "/"
+	}
+	return filepath.Join(root, current), nil
+}
+
+// installOrder orders the packages so that what they Pre-Depend on comes
+// first, and what they Depend on too unless there's a cycle. A cycle
+// through Pre-Depends is an error.
+func installOrder(packages []unpacked) ([]int, error) {
+	provided := map[string][]int{}
+	for index, pkg := range packages {
+		provided[pkg.name()] = append(provided[pkg.name()], index)
+		if provides, err := dependency.Parse(pkg.control.Get("Provides")); err == nil {
+			for _, possi := range provides.GetAllPossibilities() {
+				provided[possi.Name] = append(provided[possi.Name], index)
+			}
+		}
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make([]int, len(packages))
+	order := []int{}
+
+	var visit func(index int) error
+	visit = func(index int) error {
+		state[index] = visiting
+		for _, field := range []string{"Pre-Depends", "Depends"} {
+			dep, err := dependency.Parse(packages[index].control.Get(field))
+			if err != nil {
+				return fmt.Errorf("%s: %s", packages[index].name(), err)
+			}
+			for _, possi := range dep.GetAllPossibilities() {
+				for _, other := range provided[possi.Name] {
+					switch state[other] {
+					case unvisited:
+						if err := visit(other); err != nil {
+							return err
+						}
+					case visiting:
+						if field == "Pre-Depends" && other != index {
+							return fmt.Errorf(
+								"Pre-Depends cycle between %s and %s",
+								packages[index].name(), packages[other].name(),
+							)
+						}
+					}
+				}
+			}
+		}
+		state[index] = done
+		order = append(order, index)
+		return nil
+	}
+
+	for index := range packages {
+		if state[index] == unvisited {
+			if err := visit(index); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return order, nil
+}
+
+// record writes the info files of the unpacked packages, and adds them to
+// the status database.
+func (i Installer) record(packages []unpacked) error {
+	admindir := i.admindir()
+	for _, pkg := range packages {
+		prefix := filepath.Join(admindir, "info", pkg.infoName()+".")
+		for name, data := range pkg.info {
+			mode := os.FileMode(0644)
+			switch name {
+			case "preinst", "postinst", "prerm", "postrm", "config":
+				mode = 0755
+			}
+			if err := ioutil.WriteFile(prefix+name, data, mode); err != nil {
+				return err
+			}
+		}
+		list := strings.Join(pkg.files, "\n") + "\n"
+		if err := ioutil.WriteFile(prefix+"list", []byte(list), 0644); err != nil {
+			return err
+		}
+	}
+
+	paragraphs, err := readStatus(admindir)
+	if os.IsNotExist(err) {
+		paragraphs = []control.Paragraph{}
+	} else if err != nil {
+		return err
+	}
+
+	for _, pkg := range packages {
+		paragraph, err := i.statusParagraph(pkg)
+		if err != nil {
+			return err
+		}
+		key := statusKey(paragraph)
+		replaced := false
+		for index := range paragraphs {
+			if statusKey(paragraphs[index]) == key {
+				paragraphs[index] = paragraph
+				replaced = true
+			}
+		}
+		if !replaced {
+			paragraphs = append(paragraphs, paragraph)
+		}
+	}
+	return writeStatus(admindir, paragraphs)
+}
+
+// statusParagraph is the entry of the status database for the package,
+// its control file with a Status and its Conffiles.
+func (i Installer) statusParagraph(pkg unpacked) (control.Paragraph, error) {
+	paragraph := control.NewParagraph()
+	paragraph.Set("Package", pkg.name())
+	paragraph.Set("Status", WantInstall+" ok installed")
+	for _, key := range pkg.control.Order {
+		if strings.ToLower(key) != "package" {
+			paragraph.Set(key, pkg.control.Get(key))
+		}
+	}
+
+	conffiles := []string{}
+	for _, line := range strings.Split(string(pkg.info["conffiles"]), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		/* Flags, such as remove-on-upgrade, come before the path */
+		name := fields[len(fields)-1]
+		target, err := resolvePath(i.Root, name)
+		if err != nil {
+			return paragraph, err
+		}
+		sum, err := md5File(target)
+		if err != nil {
+			return paragraph, err
+		}
+		conffiles = append(conffiles, strings.Join(append([]string{name, sum}, fields[:len(fields)-1]...), " "))
+	}
+	if len(conffiles) > 0 {
+		paragraph.Set("Conffiles", strings.Join(conffiles, "\n")+"\n")
+	}
+	return paragraph, nil
+}
+
+// }}}
+
+// vim: foldmethod=marker
@@ -0,0 +1,143 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package dpkg_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ebikt/go-debian/dpkg"
+	"github.com/ebikt/go-debian/manifest"
+)
+
+/*
+ *
+ */
+
+func buildDeb(t *testing.T, dir string, m manifest.Manifest) string {
+	m.BaseDir = dir
+	m.Version = "1.0-1"
+	m.Arch = "amd64"
+	m.Maintainer = "Jane Doe <jane@example.com>"
+	m.Description = m.Name + " package"
+	m.Mtime = time.Unix(1700000000, 0)
+	filename, err := m.BuildFile(dir)
+	isok(t, err)
+	return filename
+}
+
+func TestInstaller(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-debian-install")
+	isok(t, err)
+	defer os.RemoveAll(dir)
+	root := filepath.Join(dir, "root")
+
+	writeFile(t, dir, "hello", "#!/bin/sh\necho hello\n")
+	writeFile(t, dir, "hello.conf", "greeting = hello\n")
+	writeFile(t, dir, "libhello.so.1", "ELF")
+	writeFile(t, dir, "script", "#!/bin/sh\nset -e\n")
+
+	/* A merged /usr image, with an absolute symlink */
+	isok(t, os.MkdirAll(filepath.Join(root, "usr/lib"), 0755))
+	isok(t, os.Symlink("/usr/lib", filepath.Join(root, "lib")))
+	writeFile(t, root, "var/lib/dpkg/status", `Package: base-files
+Status: install ok installed
+Architecture: amd64
+Version: 13
+`)
+
+	hello := buildDeb(t, dir, manifest.Manifest{
+		Name:    "hello",
+		Depends: []string{"libhello1"},
+		Files: []manifest.File{
+			{Source: "hello", Destination: "/usr/bin/hello", Mode: 0755},
+			{Source: "hello.conf", Destination: "/etc/hello.conf", Type: manifest.TypeConfig},
+		},
+		Scripts: manifest.Scripts{PostInst: "script"},
+	})
+	libhello := buildDeb(t, dir, manifest.Manifest{
+		Name:      "libhello1",
+		MultiArch: "same",
+		Files: []manifest.File{
+			{Source: "libhello.so.1", Destination: "/lib/libhello.so.1"},
+		},
+		Scripts: manifest.Scripts{PreInst: "script", PostInst: "script"},
+	})
+
+	scripts, err := dpkg.Installer{Root: root}.Install(hello, libhello)
+	isok(t, err)
+	rendered := []string{}
+	for _, script := range scripts {
+		rendered = append(rendered, script.String())
+	}
+	assert(t, strings.Join(rendered, "\n") == `/var/lib/dpkg/info/libhello1:amd64.preinst install
+/var/lib/dpkg/info/libhello1:amd64.postinst configure
+/var/lib/dpkg/info/hello.postinst configure`)
+
+	data, err := ioutil.ReadFile(filepath.Join(root, "usr/lib/libhello.so.1"))
+	isok(t, err)
+	assert(t, string(data) == "ELF")
+	link, err := os.Readlink(filepath.Join(root, "lib"))
+	isok(t, err)
+	assert(t, link == "/usr/lib")
+
+	info, err := os.Stat(filepath.Join(root, "usr/bin/hello"))
+	isok(t, err)
+	assert(t, info.Mode().Perm() == 0755)
+
+	list, err := ioutil.ReadFile(filepath.Join(root, "var/lib/dpkg/info/hello.list"))
+	isok(t, err)
+	assert(t, strings.HasPrefix(string(list), "/.\n"))
+	assert(t, strings.Contains(string(list), "\n/usr/bin/hello\n"))
+	_, err = os.Stat(filepath.Join(root, "var/lib/dpkg/info/hello.md5sums"))
+	isok(t, err)
+
+	installed, err := dpkg.Installed(filepath.Join(root, "var/lib/dpkg"))
+	isok(t, err)
+	assert(t, strings.Join(installed, " ") == "base-files:amd64 hello:amd64 libhello1:amd64")
+
+	results, err := dpkg.ConffileChecker{Root: root}.CheckStatusFile("/var/lib/dpkg/status")
+	isok(t, err)
+	assert(t, len(results) == 1)
+	assert(t, results[0].Conffile.Path == "/etc/hello.conf")
+	assert(t, results[0].State == dpkg.ConffileUnmodified)
+
+	status, err := ioutil.ReadFile(filepath.Join(root, "var/lib/dpkg/status"))
+	isok(t, err)
+	assert(t, strings.Contains(string(status), "\nConffiles:\n /etc/hello.conf "))
+}
+
+func TestInstallerPreDependsCycle(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-debian-install")
+	isok(t, err)
+	defer os.RemoveAll(dir)
+
+	foo := buildDeb(t, dir, manifest.Manifest{Name: "foo", PreDepends: []string{"bar"}})
+	bar := buildDeb(t, dir, manifest.Manifest{Name: "bar", PreDepends: []string{"foo"}})
+	_, err = dpkg.Installer{Root: filepath.Join(dir, "root")}.Install(foo, bar)
+	notok(t, err)
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,74 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package dpkg // import "github.com/ebikt/go-debian/dpkg"
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Lock {{{
+
+// Lock is held on the dpkg database, the same way apt and dpkg take it, so
+// no other package manager runs while the database is being changed.
+type Lock struct {
+	files []*os.File
+}
+
+// LockAdmin takes the frontend lock and the database lock of the dpkg
+// administrative directory (usually /var/lib/dpkg), without waiting. An
+// error is returned if another process, such as apt or dpkg, holds either.
+func LockAdmin(admindir string) (*Lock, error) {
+	lock := Lock{}
+	for _, name := range []string{"lock-frontend", "lock"} {
+		path := filepath.Join(admindir, name)
+		f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0640)
+		if err != nil {
+			lock.Unlock()
+			return nil, err
+		}
+		if err := lockFile(f); err != nil {
+			f.Close()
+			lock.Unlock()
+			return nil, fmt.Errorf("Unable to lock %s, is another process using it? %s", path, err)
+		}
+		lock.files = append(lock.files, f)
+	}
+	return &lock, nil
+}
+
+// Unlock releases the Lock.
+func (l *Lock) Unlock() error {
+	var ret error
+	for i := len(l.files) - 1; i >= 0; i-- {
+		/* Closing the file drops the lock */
+		if err := l.files[i].Close(); err != nil && ret == nil {
+			ret = err
+		}
+	}
+	l.files = nil
+	return ret
+}
+
+// }}}
+
+// vim: foldmethod=marker
@@ -0,0 +1,188 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package dpkg // import "github.com/ebikt/go-debian/dpkg"
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ebikt/go-debian/control"
+	"github.com/ebikt/go-debian/internal"
+)
+
+// Selections {{{
+
+// The selection states of a package, the first word of its Status field.
+const (
+	WantUnknown   = "unknown"
+	WantInstall   = "install"
+	WantHold      = "hold"
+	WantDeinstall = "deinstall"
+	WantPurge     = "purge"
+)
+
+// readStatus reads all the paragraphs of the status database.
+func readStatus(admindir string) ([]control.Paragraph, error) {
+	f, err := os.Open(filepath.Join(admindir, "status"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	reader, err := control.NewParagraphReader(f, nil)
+	if err != nil {
+		return nil, err
+	}
+	return reader.All()
+}
+
+// writeStatus atomically replaces the status database.
+func writeStatus(admindir string, paragraphs []control.Paragraph) error {
+	return internal.WriteFileAtomic(filepath.Join(admindir, "status"), 0644, func(out io.Writer) error {
+		for _, paragraph := range paragraphs {
+			if err := paragraph.WriteTo(out); err != nil {
+				return err
+			}
+			if _, err := io.WriteString(out, "\n"); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// checkJournal refuses to touch a status database that has pending
+// updates, which dpkg only folds in on its next run.
+func checkJournal(admindir string) error {
+	entries, err := ioutil.ReadDir(filepath.Join(admindir, "updates"))
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if strings.Trim(entry.Name(), "0123456789") == "" {
+			return fmt.Errorf("dpkg was interrupted, run `dpkg --configure -a` first")
+		}
+	}
+	return nil
+}
+
+func statusKey(paragraph control.Paragraph) string {
+	return paragraph.Get("Package") + ":" + paragraph.Get("Architecture")
+}
+
+func matchesKey(paragraph control.Paragraph, key string) bool {
+	if strings.Contains(key, ":") {
+		return statusKey(paragraph) == key
+	}
+	return paragraph.Get("Package") == key
+}
+
+// Selections returns the selection state of every package of the status
+// database in the given dpkg administrative directory, keyed by
+// "package:architecture".
+func Selections(admindir string) (map[string]string, error) {
+	paragraphs, err := readStatus(admindir)
+	if err != nil {
+		return nil, err
+	}
+	ret := map[string]string{}
+	for _, paragraph := range paragraphs {
+		ret[statusKey(paragraph)] = strings.Fields(paragraph.Get("Status") + " " + WantUnknown)[0]
+	}
+	return ret, nil
+}
+
+// Installed returns the packages of the status database in the given dpkg
+// administrative directory that are (at least partially) installed, as
+// "package:architecture".
+func Installed(admindir string) ([]string, error) {
+	paragraphs, err := readStatus(admindir)
+	if err != nil {
+		return nil, err
+	}
+	ret := []string{}
+	for _, paragraph := range paragraphs {
+		status := strings.Fields(paragraph.Get("Status"))
+		if len(status) != 3 || status[2] == "not-installed" || status[2] == "config-files" {
+			continue
+		}
+		ret = append(ret, statusKey(paragraph))
+	}
+	return ret, nil
+}
+
+// SetSelections changes the selection state of packages in the status
+// database, as `dpkg --set-selections` does. Packages are given either as
+// "package", for every architecture it's known for, or as
+// "package:architecture". The dpkg database is locked while it's updated.
+func SetSelections(admindir string, selections map[string]string) error {
+	for key, want := range selections {
+		switch want {
+		case WantUnknown, WantInstall, WantHold, WantDeinstall, WantPurge:
+		default:
+			return fmt.Errorf("Unknown selection state '%s' for %s", want, key)
+		}
+	}
+
+	lock, err := LockAdmin(admindir)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	if err := checkJournal(admindir); err != nil {
+		return err
+	}
+
+	paragraphs, err := readStatus(admindir)
+	if err != nil {
+		return err
+	}
+	for key, want := range selections {
+		found := false
+		for i := range paragraphs {
+			if !matchesKey(paragraphs[i], key) {
+				continue
+			}
+			found = true
+			status := strings.Fields(paragraphs[i].Get("Status"))
+			if len(status) != 3 {
+				return fmt.Errorf("Malformed Status of %s: '%s'", key, paragraphs[i].Get("Status"))
+			}
+			status[0] = want
+			paragraphs[i].Set("Status", strings.Join(status, " "))
+		}
+		if !found {
+			return fmt.Errorf("Package %s is not in the status database", key)
+		}
+	}
+	return writeStatus(admindir, paragraphs)
+}
+
+// }}}
+
+// vim: foldmethod=marker
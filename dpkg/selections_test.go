@@ -0,0 +1,100 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package dpkg_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ebikt/go-debian/dpkg"
+)
+
+/*
+ *
+ */
+
+const selectionsStatus = `Package: hello
+Status: install ok installed
+Architecture: amd64
+Version: 2.10-3
+Description: example package based on GNU hello
+ The GNU hello program produces a familiar, friendly greeting.
+ .
+ It is very friendly.
+
+Package: libfoo1
+Status: install ok installed
+Architecture: amd64
+Version: 1.0
+
+Package: libfoo1
+Status: install ok installed
+Architecture: i386
+Version: 1.0
+
+Package: removed
+Status: deinstall ok config-files
+Architecture: amd64
+Version: 1.0
+`
+
+func TestSetSelections(t *testing.T) {
+	admindir, err := ioutil.TempDir("", "go-debian-dpkg")
+	isok(t, err)
+	defer os.RemoveAll(admindir)
+	writeFile(t, admindir, "status", selectionsStatus)
+
+	installed, err := dpkg.Installed(admindir)
+	isok(t, err)
+	assert(t, strings.Join(installed, " ") == "hello:amd64 libfoo1:amd64 libfoo1:i386")
+
+	isok(t, dpkg.SetSelections(admindir, map[string]string{
+		"hello":        dpkg.WantHold,
+		"libfoo1:i386": dpkg.WantDeinstall,
+	}))
+
+	selections, err := dpkg.Selections(admindir)
+	isok(t, err)
+	assert(t, selections["hello:amd64"] == dpkg.WantHold)
+	assert(t, selections["libfoo1:amd64"] == dpkg.WantInstall)
+	assert(t, selections["libfoo1:i386"] == dpkg.WantDeinstall)
+	assert(t, selections["removed:amd64"] == dpkg.WantDeinstall)
+
+	/* Everything else is kept as-is */
+	data, err := ioutil.ReadFile(filepath.Join(admindir, "status"))
+	isok(t, err)
+	expected := strings.Replace(selectionsStatus, "install ok installed\nArchitecture: amd64\nVersion: 2.10-3",
+		"hold ok installed\nArchitecture: amd64\nVersion: 2.10-3", 1)
+	expected = strings.Replace(expected, "install ok installed\nArchitecture: i386",
+		"deinstall ok installed\nArchitecture: i386", 1)
+	assert(t, string(data) == expected+"\n")
+
+	notok(t, dpkg.SetSelections(admindir, map[string]string{"missing": dpkg.WantHold}))
+	notok(t, dpkg.SetSelections(admindir, map[string]string{"hello": "keep"}))
+
+	writeFile(t, admindir, "updates/0001", "Package: hello\n")
+	notok(t, dpkg.SetSelections(admindir, map[string]string{"hello": dpkg.WantInstall}))
+}
+
+// vim: foldmethod=marker
@@ -1,7 +1,12 @@
 module github.com/ebikt/go-debian
 
+go 1.22
+
 require (
 	github.com/kjk/lzma v0.0.0-20161016003348-3fd93898850d
+	github.com/klauspost/compress v1.18.0
+	github.com/klauspost/pgzip v1.2.6
+	github.com/ulikunitz/xz v0.5.12
 	github.com/xi2/xz v0.0.0-20171230120015-48954b6210f8
 	golang.org/x/crypto v0.0.0-20190103213133-ff983b9c42bc
 	pault.ag/go/topsort v0.0.0-20160530003732-f98d2ad46e1a
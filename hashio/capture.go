@@ -0,0 +1,128 @@
+package hashio // import "github.com/ebikt/go-debian/hashio"
+
+import (
+	"fmt"
+	"io"
+)
+
+// DefaultHashes are the algorithms a .changes file or a Packages entry
+// lists the checksums of.
+var DefaultHashes = []string{"md5", "sha1", "sha256"}
+
+// MultiHasher computes several digests of the same data at once, along with
+// its size.
+type MultiHasher struct {
+	hashers []*Hasher
+	size    int64
+}
+
+// NewMultiHasher creates a MultiHasher for the named algorithms, or the
+// DefaultHashes if none is given.
+func NewMultiHasher(hashes ...string) (*MultiHasher, error) {
+	if len(hashes) == 0 {
+		hashes = DefaultHashes
+	}
+	ret := MultiHasher{}
+	for _, hash := range hashes {
+		hw, err := NewHasher(hash)
+		if err != nil {
+			return nil, err
+		}
+		ret.hashers = append(ret.hashers, hw)
+	}
+	return &ret, nil
+}
+
+func (mh *MultiHasher) Write(p []byte) (int, error) {
+	for _, hw := range mh.hashers {
+		hw.Write(p)
+	}
+	mh.size += int64(len(p))
+	return len(p), nil
+}
+
+// Size returns the number of bytes hashed so far.
+func (mh *MultiHasher) Size() int64 {
+	return mh.size
+}
+
+// Hashers returns the Hasher of every algorithm, in the order they were
+// asked for.
+func (mh *MultiHasher) Hashers() []*Hasher {
+	return mh.hashers
+}
+
+// Hasher returns the Hasher of the named algorithm, or nil if it isn't
+// computed.
+func (mh *MultiHasher) Hasher(name string) *Hasher {
+	for _, hw := range mh.hashers {
+		if hw.Name() == name {
+			return hw
+		}
+	}
+	return nil
+}
+
+// Sums returns the hex encoded digests, by algorithm name.
+func (mh *MultiHasher) Sums() map[string]string {
+	ret := map[string]string{}
+	for _, hw := range mh.hashers {
+		ret[hw.Name()] = fmt.Sprintf("%x", hw.Sum(nil))
+	}
+	return ret
+}
+
+// HashingReader hashes the data read through it.
+type HashingReader struct {
+	*MultiHasher
+	reader io.Reader
+}
+
+// NewHashingReader wraps the reader, hashing what is read with the named
+// algorithms, or the DefaultHashes if none is given.
+func NewHashingReader(reader io.Reader, hashes ...string) (*HashingReader, error) {
+	mh, err := NewMultiHasher(hashes...)
+	if err != nil {
+		return nil, err
+	}
+	return &HashingReader{MultiHasher: mh, reader: reader}, nil
+}
+
+func (hr *HashingReader) Read(p []byte) (int, error) {
+	n, err := hr.reader.Read(p)
+	if n > 0 {
+		hr.MultiHasher.Write(p[:n])
+	}
+	return n, err
+}
+
+// Drain reads and hashes what is left of the data, so that the digests
+// cover all of it.
+func (hr *HashingReader) Drain() error {
+	_, err := io.Copy(io.Discard, hr)
+	return err
+}
+
+// HashingWriter hashes the data written through it.
+type HashingWriter struct {
+	*MultiHasher
+	writer io.Writer
+}
+
+// NewHashingWriter wraps the writer, hashing what is written with the
+// named algorithms, or the DefaultHashes if none is given.
+func NewHashingWriter(writer io.Writer, hashes ...string) (*HashingWriter, error) {
+	mh, err := NewMultiHasher(hashes...)
+	if err != nil {
+		return nil, err
+	}
+	return &HashingWriter{MultiHasher: mh, writer: writer}, nil
+}
+
+func (hw *HashingWriter) Write(p []byte) (int, error) {
+	n, err := hw.writer.Write(p)
+	if n > 0 {
+		hw.MultiHasher.Write(p[:n])
+	}
+	return n, err
+}
@@ -5,6 +5,10 @@ import (
 	"io"
 
 	"compress/gzip"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/klauspost/pgzip"
+	"github.com/ulikunitz/xz"
 )
 
 type Compressor func(io.Writer) (io.WriteCloser, error)
@@ -13,8 +17,18 @@ func gzipCompressor(in io.Writer) (io.WriteCloser, error) {
 	return gzip.NewWriter(in), nil
 }
 
+func xzCompressor(in io.Writer) (io.WriteCloser, error) {
+	return xz.NewWriter(in)
+}
+
+func zstdCompressor(in io.Writer) (io.WriteCloser, error) {
+	return zstd.NewWriter(in)
+}
+
 var knownCompressors = map[string]Compressor{
-	"gz": gzipCompressor,
+	"gz":  gzipCompressor,
+	"xz":  xzCompressor,
+	"zst": zstdCompressor,
 }
 
 func GetCompressor(name string) (Compressor, error) {
@@ -23,3 +37,48 @@ func GetCompressor(name string) (Compressor, error) {
 	}
 	return nil, fmt.Errorf("No such compressor: '%s'", name)
 }
+
+// Dictionary sizes of the xz presets, from xz(1).
+var xzDictCaps = []int{
+	256 << 10, 1 << 20, 2 << 20, 4 << 20, 4 << 20,
+	8 << 20, 8 << 20, 16 << 20, 32 << 20, 64 << 20,
+}
+
+// GetCompressorLevel returns the compressor of the given name, set to
+// compress at the given level, as understood by gzip(1), xz(1) and
+// zstd(1). A level of 0 is the default level of the compressor. gzip
+// compresses blocks of the data in parallel, unlike with GetCompressor.
+func GetCompressorLevel(name string, level int) (Compressor, error) {
+	switch name {
+	case "gz":
+		if level == 0 {
+			level = gzip.DefaultCompression
+		} else if level < gzip.BestSpeed || level > gzip.BestCompression {
+			return nil, fmt.Errorf("Invalid gzip level: %d", level)
+		}
+		return func(in io.Writer) (io.WriteCloser, error) {
+			return pgzip.NewWriterLevel(in, level)
+		}, nil
+	case "xz":
+		if level == 0 {
+			level = 6
+		}
+		if level < 1 || level >= len(xzDictCaps) {
+			return nil, fmt.Errorf("Invalid xz level: %d", level)
+		}
+		return func(in io.Writer) (io.WriteCloser, error) {
+			return xz.WriterConfig{DictCap: xzDictCaps[level]}.NewWriter(in)
+		}, nil
+	case "zst":
+		if level == 0 {
+			level = 3
+		}
+		if level < 1 || level > 22 {
+			return nil, fmt.Errorf("Invalid zstd level: %d", level)
+		}
+		return func(in io.Writer) (io.WriteCloser, error) {
+			return zstd.NewWriter(in, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)))
+		}, nil
+	}
+	return GetCompressor(name)
+}
@@ -0,0 +1,36 @@
+package hashio // import "github.com/ebikt/go-debian/hashio"
+
+import (
+	"context"
+	"io"
+)
+
+// ContextReader reads from another reader until its context is done, after
+// which reads fail with the error of the context. This makes anything
+// reading a stream, such as decompressing an index or unpacking a large
+// archive, stop once cancelled or past its deadline.
+type ContextReader struct {
+	ctx    context.Context
+	reader io.Reader
+}
+
+// NewContextReader wraps the reader, to stop reading from it once the
+// context is done.
+func NewContextReader(ctx context.Context, reader io.Reader) *ContextReader {
+	return &ContextReader{ctx: ctx, reader: reader}
+}
+
+func (r *ContextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.reader.Read(p)
+}
+
+// Close closes the underlying reader, if it can be closed.
+func (r *ContextReader) Close() error {
+	if closer, ok := r.reader.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package hashio // import "github.com/ebikt/go-debian/hashio"
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+
+	"compress/bzip2"
+	"compress/gzip"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/xi2/xz"
+)
+
+type decompressor struct {
+	magic []byte
+	open  func(io.Reader) (io.Reader, error)
+}
+
+var knownDecompressors = []decompressor{
+	{[]byte{0x1f, 0x8b}, func(in io.Reader) (io.Reader, error) {
+		return gzip.NewReader(in)
+	}},
+	{[]byte{0xfd, '7', 'z', 'X', 'Z', 0x00}, func(in io.Reader) (io.Reader, error) {
+		return xz.NewReader(in, 0)
+	}},
+	{[]byte("BZh"), func(in io.Reader) (io.Reader, error) {
+		return bzip2.NewReader(in), nil
+	}},
+	{[]byte{0x28, 0xb5, 0x2f, 0xfd}, func(in io.Reader) (io.Reader, error) {
+		/* A single goroutine decodes synchronously, and doesn't leak
+		 * anything when the reader is dropped without being closed. */
+		return zstd.NewReader(in, zstd.WithDecoderConcurrency(1))
+	}},
+}
+
+// NewDecompressingReader returns a reader of the decompressed data of a
+// gzip, xz, bzip2 or zstd stream, as told by its magic number, and of the
+// data itself when it's none of those.
+func NewDecompressingReader(in io.Reader) (io.Reader, error) {
+	buffered := bufio.NewReader(in)
+	for _, candidate := range knownDecompressors {
+		magic, err := buffered.Peek(len(candidate.magic))
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if bytes.Equal(magic, candidate.magic) {
+			return candidate.open(buffered)
+		}
+	}
+	return buffered, nil
+}
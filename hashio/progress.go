@@ -0,0 +1,74 @@
+package hashio // import "github.com/ebikt/go-debian/hashio"
+
+import (
+	"io"
+)
+
+// Progress is told how long operations go along, such as reading the
+// members of an ar archive, extracting the files of a .deb, downloading
+// the indexes of a suite or writing them, so as to render progress bars or
+// emit metrics. Its methods are called from the goroutine doing the work,
+// one item at a time.
+type Progress interface {
+	// Start tells the work on an item began: an ar member, a file, a
+	// download or an index, of the given size in bytes, or -1 if unknown.
+	Start(item string, size int64)
+
+	// Advance tells n more bytes of the item were read or written.
+	Advance(item string, n int64)
+
+	// Done tells the work on the item ended, having failed if err is set.
+	Done(item string, err error)
+}
+
+// ProgressReader tells a Progress about the bytes read through it, as the
+// Advance of an item.
+type ProgressReader struct {
+	reader   io.Reader
+	progress Progress
+	item     string
+}
+
+// NewProgressReader wraps the reader, telling the progress, if not nil,
+// about the bytes of the item read.
+func NewProgressReader(reader io.Reader, progress Progress, item string) *ProgressReader {
+	return &ProgressReader{reader: reader, progress: progress, item: item}
+}
+
+func (r *ProgressReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	if n > 0 && r.progress != nil {
+		r.progress.Advance(r.item, int64(n))
+	}
+	return n, err
+}
+
+// Close closes the underlying reader, if it can be closed.
+func (r *ProgressReader) Close() error {
+	if closer, ok := r.reader.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// ProgressWriter tells a Progress about the bytes written through it, as
+// the Advance of an item.
+type ProgressWriter struct {
+	writer   io.Writer
+	progress Progress
+	item     string
+}
+
+// NewProgressWriter wraps the writer, telling the progress, if not nil,
+// about the bytes of the item written.
+func NewProgressWriter(writer io.Writer, progress Progress, item string) *ProgressWriter {
+	return &ProgressWriter{writer: writer, progress: progress, item: item}
+}
+
+func (w *ProgressWriter) Write(p []byte) (int, error) {
+	n, err := w.writer.Write(p)
+	if n > 0 && w.progress != nil {
+		w.progress.Advance(w.item, int64(n))
+	}
+	return n, err
+}
@@ -0,0 +1,36 @@
+package internal
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// WriteFileAtomic writes a file through `write`, into a temporary file next
+// to `dest`, which is then renamed over it, so readers never see a partial
+// file.
+func WriteFileAtomic(dest string, perm os.FileMode, write func(io.Writer) error) error {
+	out, err := ioutil.TempFile(filepath.Dir(dest), "."+filepath.Base(dest)+".")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(out.Name())
+
+	if err := write(out); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Chmod(perm); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Rename(out.Name(), dest)
+}
@@ -0,0 +1,22 @@
+package internal
+
+import (
+	"regexp"
+	"strings"
+)
+
+// GlobMatch matches the value against a shell wildcard pattern. Unlike
+// path.Match, '*' happily matches a '/', since components such as
+// "updates/main" are not paths. An empty pattern matches anything.
+func GlobMatch(pattern, value string) bool {
+	if pattern == "" {
+		return true
+	}
+	if !strings.ContainsAny(pattern, "*?") {
+		return pattern == value
+	}
+	expr := regexp.QuoteMeta(pattern)
+	expr = strings.Replace(expr, `\*`, ".*", -1)
+	expr = strings.Replace(expr, `\?`, ".", -1)
+	return regexp.MustCompile("^" + expr + "$").MatchString(value)
+}
@@ -0,0 +1,187 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package manifest // import "github.com/ebikt/go-debian/manifest"
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ebikt/go-debian/control"
+	"github.com/ebikt/go-debian/deb"
+)
+
+// Build {{{
+
+// Build the package described by the Manifest, and write the resulting
+// .deb out.
+func (m *Manifest) Build(out io.Writer) error {
+	builder, err := m.Builder()
+	if err != nil {
+		return err
+	}
+	return builder.Build(out)
+}
+
+// BuildFile builds the package into the given directory, under its
+// conventional Filename, and returns the path of the .deb.
+func (m *Manifest) BuildFile(dir string) (string, error) {
+	name, err := m.Filename()
+	if err != nil {
+		return "", err
+	}
+	target := filepath.Join(dir, name)
+	f, err := os.Create(target)
+	if err != nil {
+		return "", err
+	}
+	if err := m.Build(f); err != nil {
+		f.Close()
+		os.Remove(target)
+		return "", err
+	}
+	return target, f.Close()
+}
+
+// Builder returns the deb.Builder of the package described by the
+// Manifest, with its files and scripts read from the disk. Its members
+// are compressed with gzip, and its Installed-Size left to be computed.
+func (m *Manifest) Builder() (*deb.Builder, error) {
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+	paragraph, err := m.Control(0)
+	if err != nil {
+		return nil, err
+	}
+	ret := deb.NewBuilder(*paragraph)
+	ret.Compression = "gz"
+	ret.ModTime = m.Mtime
+	if ret.ModTime.IsZero() {
+		ret.ModTime = time.Now()
+	}
+
+	for _, file := range m.Files {
+		dest := strings.TrimPrefix(path.Clean(file.Destination), "/")
+		buildFile := deb.BuildFile{Path: dest, Mode: int64(file.Mode.Perm()), Owner: file.Owner, Group: file.Group}
+		switch file.Type {
+		case TypeDir:
+			buildFile.Type = tar.TypeDir
+		case TypeSymlink:
+			buildFile.Type = tar.TypeSymlink
+			buildFile.Linkname = file.Source
+		case TypeFile, TypeConfig:
+			source := m.source(file.Source)
+			info, err := os.Stat(source)
+			if err != nil {
+				return nil, err
+			}
+			if !info.Mode().IsRegular() {
+				return nil, fmt.Errorf("%s: Not a regular file", source)
+			}
+			if buildFile.Data, err = os.ReadFile(source); err != nil {
+				return nil, err
+			}
+			buildFile.Type = tar.TypeReg
+			if file.Mode == 0 {
+				buildFile.Mode = int64(info.Mode().Perm())
+			}
+			if file.Type == TypeConfig {
+				ret.Conffiles = append(ret.Conffiles, "/"+dest)
+			}
+		}
+		ret.Files = append(ret.Files, buildFile)
+	}
+	sort.Strings(ret.Conffiles)
+
+	for name, source := range map[string]string{
+		"preinst":  m.Scripts.PreInst,
+		"postinst": m.Scripts.PostInst,
+		"prerm":    m.Scripts.PreRm,
+		"postrm":   m.Scripts.PostRm,
+	} {
+		if source == "" {
+			continue
+		}
+		content, err := os.ReadFile(m.source(source))
+		if err != nil {
+			return nil, err
+		}
+		ret.Scripts[name] = content
+	}
+	return ret, nil
+}
+
+func (m *Manifest) source(name string) string {
+	if filepath.IsAbs(name) || m.BaseDir == "" {
+		return name
+	}
+	return filepath.Join(m.BaseDir, name)
+}
+
+// Control returns the DEBIAN/control paragraph of the package, given its
+// Installed-Size in KiB, left out if 0.
+func (m *Manifest) Control(installedSize int64) (*control.Paragraph, error) {
+	ret := control.NewParagraph()
+	set := func(key, value string) {
+		if value != "" {
+			ret.Set(key, value)
+		}
+	}
+
+	set("Package", m.Name)
+	if m.Source != m.Name {
+		set("Source", m.Source)
+	}
+	set("Version", m.Version)
+	set("Architecture", m.Arch)
+	set("Maintainer", m.Maintainer)
+	if installedSize != 0 {
+		set("Installed-Size", strconv.FormatInt(installedSize, 10))
+	}
+	if m.Essential {
+		set("Essential", "yes")
+	}
+	set("Multi-Arch", m.MultiArch)
+	for _, field := range m.relations() {
+		dep, err := field.parse()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", field.name, err)
+		}
+		set(field.name, dep.String())
+	}
+	set("Section", m.Section)
+	set("Priority", m.Priority)
+	set("Homepage", m.Homepage)
+	set("Description", strings.TrimRight(m.Description, "\n"))
+	return &ret, nil
+}
+
+// }}}
+
+// vim: foldmethod=marker
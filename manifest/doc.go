@@ -0,0 +1,20 @@
+/*
+
+This module builds `.deb` archives out of a declarative Manifest, listing
+the package metadata along with the files to ship, in the spirit of nfpm.
+
+	{
+		"name": "hello",
+		"version": "1.0-1",
+		"arch": "amd64",
+		"maintainer": "Jane Doe <jane@example.com>",
+		"description": "Say hello\nA program that greets the world.",
+		"depends": ["libc6 (>= 2.34)"],
+		"files": [
+			{"src": "build/hello", "dst": "/usr/bin/hello", "mode": 493},
+			{"src": "hello.conf", "dst": "/etc/hello.conf", "type": "config"}
+		]
+	}
+
+*/
+package manifest // import "github.com/ebikt/go-debian/manifest"
@@ -0,0 +1,251 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package manifest // import "github.com/ebikt/go-debian/manifest"
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/ebikt/go-debian/dependency"
+	"github.com/ebikt/go-debian/version"
+)
+
+// Manifest {{{
+
+// File types a manifest File may have.
+const (
+	// A regular file, copied from Source.
+	TypeFile = ""
+
+	// A regular file, copied from Source, and flagged as a conffile.
+	TypeConfig = "config"
+
+	// An (empty) directory.
+	TypeDir = "dir"
+
+	// A symbolic link, pointing to Source.
+	TypeSymlink = "symlink"
+)
+
+// A File to be shipped in the package.
+type File struct {
+	// Path of the file to copy, or target of the symlink.
+	Source string `json:"src"`
+
+	// Absolute path the file is installed to.
+	Destination string `json:"dst"`
+
+	// One of TypeFile, TypeConfig, TypeDir or TypeSymlink.
+	Type string `json:"type"`
+
+	// Permissions of the file. If unset, regular files keep the ones of
+	// Source, and directories get 0755.
+	Mode os.FileMode `json:"mode"`
+
+	// Owner and group of the file, root if unset.
+	Owner string `json:"owner"`
+	Group string `json:"group"`
+}
+
+// Scripts are paths to the maintainer scripts of the package.
+type Scripts struct {
+	PreInst  string `json:"preinst"`
+	PostInst string `json:"postinst"`
+	PreRm    string `json:"prerm"`
+	PostRm   string `json:"postrm"`
+}
+
+// Manifest describes a binary package to be built.
+type Manifest struct {
+	Name        string `json:"name"`
+	Source      string `json:"source"`
+	Version     string `json:"version"`
+	Arch        string `json:"arch"`
+	Maintainer  string `json:"maintainer"`
+	Description string `json:"description"`
+	Section     string `json:"section"`
+	Priority    string `json:"priority"`
+	Homepage    string `json:"homepage"`
+	MultiArch   string `json:"multi_arch"`
+	Essential   bool   `json:"essential"`
+
+	PreDepends []string `json:"pre_depends"`
+	Depends    []string `json:"depends"`
+	Recommends []string `json:"recommends"`
+	Suggests   []string `json:"suggests"`
+	Enhances   []string `json:"enhances"`
+	Breaks     []string `json:"breaks"`
+	Conflicts  []string `json:"conflicts"`
+	Replaces   []string `json:"replaces"`
+	Provides   []string `json:"provides"`
+
+	Files   []File  `json:"files"`
+	Scripts Scripts `json:"scripts"`
+
+	// Modification time of everything in the archive; the time of the
+	// build if unset.
+	Mtime time.Time `json:"mtime"`
+
+	// Relative paths (of Files and Scripts) are relative to this
+	// directory. Set by Load to the directory of the manifest file.
+	BaseDir string `json:"-"`
+}
+
+// Parse a JSON encoded Manifest.
+func Parse(in io.Reader) (*Manifest, error) {
+	ret := Manifest{}
+	decoder := json.NewDecoder(in)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&ret); err != nil {
+		return nil, err
+	}
+	return &ret, nil
+}
+
+// Load a JSON encoded Manifest from the given path.
+func Load(filename string) (*Manifest, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	ret, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", filename, err)
+	}
+	ret.BaseDir = filepath.Dir(filename)
+	return ret, nil
+}
+
+// }}}
+
+// Validation {{{
+
+// Package names, as defined by Debian Policy, section 5.6.1.
+var packageName = regexp.MustCompile(`^[a-z0-9][a-z0-9+.-]+$`)
+
+// Validate the Manifest, making sure the resulting package would be
+// reasonable according to Debian Policy.
+func (m *Manifest) Validate() error {
+	if !packageName.MatchString(m.Name) {
+		return fmt.Errorf("Invalid package name: '%s'", m.Name)
+	}
+	if _, err := version.Parse(m.Version); err != nil {
+		return err
+	}
+	arch, err := dependency.ParseArch(m.Arch)
+	if err != nil {
+		return err
+	}
+	if arch.CPU != "all" && arch.IsWildcard() {
+		return fmt.Errorf("Can't build a package for the wildcard Arch %s", m.Arch)
+	}
+	if m.Maintainer == "" {
+		return fmt.Errorf("A Maintainer is required")
+	}
+	if strings.TrimSpace(m.summary()) == "" {
+		return fmt.Errorf("A Description is required")
+	}
+
+	for _, field := range m.relations() {
+		if _, err := field.parse(); err != nil {
+			return fmt.Errorf("%s: %s", field.name, err)
+		}
+	}
+
+	seen := map[string]bool{}
+	for _, file := range m.Files {
+		if !path.IsAbs(file.Destination) {
+			return fmt.Errorf("Destination must be absolute: '%s'", file.Destination)
+		}
+		dest := path.Clean(file.Destination)
+		if seen[dest] {
+			return fmt.Errorf("Duplicate Destination: '%s'", dest)
+		}
+		seen[dest] = true
+
+		switch file.Type {
+		case TypeFile, TypeConfig, TypeSymlink:
+			if file.Source == "" {
+				return fmt.Errorf("%s: Source is required", dest)
+			}
+		case TypeDir:
+		default:
+			return fmt.Errorf("%s: Unknown file type '%s'", dest, file.Type)
+		}
+	}
+	return nil
+}
+
+func (m *Manifest) summary() string {
+	return strings.SplitN(m.Description, "\n", 2)[0]
+}
+
+type relationField struct {
+	name      string
+	values    []string
+	conflicts bool
+}
+
+func (f relationField) parse() (*dependency.Dependency, error) {
+	in := strings.Join(f.values, ", ")
+	if f.conflicts {
+		return dependency.ParseConflicts(in)
+	}
+	return dependency.Parse(in)
+}
+
+func (m *Manifest) relations() []relationField {
+	return []relationField{
+		{"Pre-Depends", m.PreDepends, false},
+		{"Depends", m.Depends, false},
+		{"Recommends", m.Recommends, false},
+		{"Suggests", m.Suggests, false},
+		{"Enhances", m.Enhances, false},
+		{"Breaks", m.Breaks, true},
+		{"Conflicts", m.Conflicts, true},
+		{"Replaces", m.Replaces, true},
+		{"Provides", m.Provides, true},
+	}
+}
+
+// Filename returns the conventional file name of the package, such as
+// "hello_1.0-1_amd64.deb".
+func (m *Manifest) Filename() (string, error) {
+	ver, err := version.Parse(m.Version)
+	if err != nil {
+		return "", err
+	}
+	ver.Epoch = 0
+	return fmt.Sprintf("%s_%s_%s.deb", m.Name, ver.String(), m.Arch), nil
+}
+
+// }}}
+
+// vim: foldmethod=marker
@@ -0,0 +1,176 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package manifest_test
+
+import (
+	"archive/tar"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ebikt/go-debian/deb"
+	"github.com/ebikt/go-debian/manifest"
+)
+
+/*
+ *
+ */
+
+func isok(t *testing.T, err error) {
+	if err != nil {
+		log.Printf("Error! Error is not nil! %s\n", err)
+		debug.PrintStack()
+		t.FailNow()
+	}
+}
+
+func notok(t *testing.T, err error) {
+	if err == nil {
+		log.Printf("Error! Error is nil!\n")
+		debug.PrintStack()
+		t.FailNow()
+	}
+}
+
+func assert(t *testing.T, expr bool) {
+	if !expr {
+		log.Printf("Assertion failed!")
+		debug.PrintStack()
+		t.FailNow()
+	}
+}
+
+/*
+ *
+ */
+
+const helloManifest = `{
+	"name": "hello",
+	"version": "1:1.0-1",
+	"arch": "amd64",
+	"maintainer": "Jane Doe <jane@example.com>",
+	"description": "Say hello\nA program that greets the world.\n\nIt is very friendly.",
+	"section": "misc",
+	"depends": ["libc6 (>= 2.34)", "adduser | passwd"],
+	"conflicts": ["hello-traditional"],
+	"files": [
+		{"src": "hello", "dst": "/usr/bin/hello", "mode": 493},
+		{"src": "hello.conf", "dst": "/etc/hello/hello.conf", "type": "config"},
+		{"src": "hello", "dst": "/usr/bin/hi", "type": "symlink"},
+		{"dst": "/var/lib/hello", "type": "dir", "mode": 448, "owner": "hello", "group": "hello"}
+	],
+	"scripts": {"postinst": "postinst"}
+}`
+
+func TestManifestBuild(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-debian-manifest")
+	isok(t, err)
+	defer os.RemoveAll(dir)
+
+	for name, data := range map[string]string{
+		"hello":         strings.Repeat("#", 2000),
+		"hello.conf":    "greeting = hello\n",
+		"postinst":      "#!/bin/sh\nset -e\n",
+		"manifest.json": helloManifest,
+	} {
+		isok(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0644))
+	}
+
+	m, err := manifest.Load(filepath.Join(dir, "manifest.json"))
+	isok(t, err)
+	m.Mtime = time.Unix(1700000000, 0)
+
+	name, err := m.Filename()
+	isok(t, err)
+	assert(t, name == "hello_1.0-1_amd64.deb")
+
+	debPath, err := m.BuildFile(dir)
+	isok(t, err)
+
+	debFile, closer, err := deb.LoadFile(debPath)
+	isok(t, err)
+	defer closer()
+
+	assert(t, debFile.Control.Package == "hello")
+	assert(t, debFile.Control.Version.Epoch == 1)
+	assert(t, debFile.Control.Architecture.CPU == "amd64")
+	/* 7 directories, the symlink, and 2 KiB + 1 KiB of files */
+	assert(t, debFile.Control.InstalledSize == 7+1+2+1)
+	assert(t, debFile.Control.Depends.String() == "libc6 (>= 2.34), adduser | passwd")
+	assert(t, debFile.Control.Conflicts.String() == "hello-traditional")
+	assert(t, debFile.Control.Description == "Say hello\nA program that greets the world.\n\nIt is very friendly.\n")
+
+	headers := map[string]tar.Header{}
+	for {
+		header, err := debFile.Data.Next()
+		if err == io.EOF {
+			break
+		}
+		isok(t, err)
+		headers[header.Name] = *header
+	}
+	assert(t, len(headers) == 11)
+	assert(t, headers["./usr/bin/hello"].Mode == 0755)
+	assert(t, headers["./usr/bin/hello"].Size == 2000)
+	assert(t, headers["./usr/bin/hi"].Linkname == "hello")
+	assert(t, headers["./var/lib/hello/"].Mode == 0700)
+	assert(t, headers["./var/lib/hello/"].Uname == "hello")
+	assert(t, headers["./etc/hello/"].Typeflag == tar.TypeDir)
+	assert(t, headers["./etc/hello/hello.conf"].Uname == "root")
+	assert(t, headers["./etc/hello/hello.conf"].ModTime.Equal(m.Mtime))
+}
+
+func TestManifestValidate(t *testing.T) {
+	valid := func() *manifest.Manifest {
+		m, err := manifest.Parse(strings.NewReader(helloManifest))
+		isok(t, err)
+		return m
+	}
+	isok(t, valid().Validate())
+
+	for _, mutate := range []func(*manifest.Manifest){
+		func(m *manifest.Manifest) { m.Name = "Hello" },
+		func(m *manifest.Manifest) { m.Version = "" },
+		func(m *manifest.Manifest) { m.Arch = "linux-any" },
+		func(m *manifest.Manifest) { m.Maintainer = "" },
+		func(m *manifest.Manifest) { m.Description = "\nNo summary" },
+		func(m *manifest.Manifest) { m.Depends = []string{"foo ("} },
+		func(m *manifest.Manifest) { m.Conflicts = []string{"foo | bar"} },
+		func(m *manifest.Manifest) { m.Files[0].Destination = "usr/bin/hello" },
+		func(m *manifest.Manifest) { m.Files[1].Destination = "/usr/bin/hello" },
+		func(m *manifest.Manifest) { m.Files[1].Type = "fifo" },
+	} {
+		m := valid()
+		mutate(m)
+		notok(t, m.Validate())
+	}
+
+	_, err := manifest.Parse(strings.NewReader(`{"name": "hello", "bogus": true}`))
+	notok(t, err)
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,443 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package patch // import "github.com/ebikt/go-debian/patch"
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Unified diffs {{{
+
+// A Hunk is a change of a unified diff: its Lines are prefixed with ' '
+// for context, '-' for removed and '+' for added lines, and end with
+// their newline, unless marked with "\ No newline at end of file".
+type Hunk struct {
+	OldStart int
+	OldLines int
+	NewStart int
+	NewLines int
+	Lines    []string
+}
+
+// A File is the unified diff of a file, from OldName to NewName, either of
+// which is "/dev/null" when the file is created or removed.
+type File struct {
+	OldName string
+	NewName string
+	Hunks   []Hunk
+}
+
+const devNull = "/dev/null"
+
+/* The name in a ---/+++ line, without the timestamp following it */
+func diffName(line string) string {
+	name := line[4:]
+	if i := strings.IndexByte(name, '\t'); i >= 0 {
+		name = name[:i]
+	}
+	return strings.TrimSpace(name)
+}
+
+/* The start and length of a range of a hunk header, such as "-12,7" */
+func hunkRange(field string) (int, int, error) {
+	start, count := field[1:], "1"
+	if i := strings.IndexByte(start, ','); i >= 0 {
+		start, count = start[:i], start[i+1:]
+	}
+	first, err := strconv.Atoi(start)
+	if err != nil {
+		return 0, 0, err
+	}
+	lines, err := strconv.Atoi(count)
+	if err != nil {
+		return 0, 0, err
+	}
+	return first, lines, nil
+}
+
+// Parse parses the unified diffs of a patch, skipping whatever text
+// precedes and separates them, such as its Header.
+func Parse(reader io.Reader) ([]File, error) {
+	in := bufio.NewReader(reader)
+	readLine := func() (string, error) {
+		line, err := in.ReadString('\n')
+		if err == io.EOF && line != "" {
+			err = nil
+		}
+		return line, err
+	}
+
+	ret := []File{}
+	var current *File
+	lineno := 0
+	for {
+		line, err := readLine()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		lineno++
+
+		switch {
+		case strings.HasPrefix(line, "--- "):
+			next, err := in.Peek(4)
+			if err != nil || string(next) != "+++ " {
+				current = nil
+				continue
+			}
+			newLine, err := readLine()
+			if err != nil {
+				return nil, err
+			}
+			lineno++
+			ret = append(ret, File{OldName: diffName(line), NewName: diffName(newLine)})
+			current = &ret[len(ret)-1]
+		case strings.HasPrefix(line, "@@ ") && current != nil:
+			fields := strings.Fields(line)
+			if len(fields) < 4 || fields[1][0] != '-' || fields[2][0] != '+' || fields[3] != "@@" {
+				return nil, fmt.Errorf("Malformed hunk header on line %d", lineno)
+			}
+			h := Hunk{}
+			if h.OldStart, h.OldLines, err = hunkRange(fields[1]); err != nil {
+				return nil, fmt.Errorf("Malformed hunk header on line %d: %s", lineno, err)
+			}
+			if h.NewStart, h.NewLines, err = hunkRange(fields[2]); err != nil {
+				return nil, fmt.Errorf("Malformed hunk header on line %d: %s", lineno, err)
+			}
+			old, new := 0, 0
+			for old < h.OldLines || new < h.NewLines {
+				line, err := readLine()
+				if err == io.EOF {
+					return nil, fmt.Errorf("Truncated hunk on line %d", lineno)
+				} else if err != nil {
+					return nil, err
+				}
+				lineno++
+				if line == "\n" {
+					/* Context lines which lost their space */
+					line = " \n"
+				}
+				switch line[0] {
+				case ' ':
+					old++
+					new++
+				case '-':
+					old++
+				case '+':
+					new++
+				case '\\':
+					h.markNoNewline()
+					continue
+				default:
+					return nil, fmt.Errorf("Malformed hunk line %d", lineno)
+				}
+				h.Lines = append(h.Lines, line)
+			}
+			if old != h.OldLines || new != h.NewLines {
+				return nil, fmt.Errorf("Hunk ending on line %d doesn't match its header", lineno)
+			}
+			if next, err := in.Peek(1); err == nil && next[0] == '\\' {
+				readLine()
+				lineno++
+				h.markNoNewline()
+			}
+			current.Hunks = append(current.Hunks, h)
+		}
+	}
+	return ret, nil
+}
+
+/* "\ No newline at end of file" is about the line before it */
+func (h *Hunk) markNoNewline() {
+	if len(h.Lines) > 0 {
+		h.Lines[len(h.Lines)-1] = strings.TrimSuffix(h.Lines[len(h.Lines)-1], "\n")
+	}
+}
+
+// Creates returns whether the diff creates the file.
+func (f File) Creates() bool {
+	return f.OldName == devNull
+}
+
+// Removes returns whether the diff removes the file.
+func (f File) Removes() bool {
+	return f.NewName == devNull
+}
+
+// Target returns the file the diff applies to, the NewName unless the
+// file is removed, once `strip` leading components are removed from it,
+// as patch -p does.
+func (f File) Target(strip int) (string, error) {
+	name := f.NewName
+	if f.Removes() {
+		name = f.OldName
+	}
+	components := strings.Split(name, "/")
+	if len(components) <= strip {
+		return "", fmt.Errorf("Can't strip %d components from '%s'", strip, name)
+	}
+	clean := path.Clean(strings.Join(components[strip:], "/"))
+	if path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", fmt.Errorf("Refusing to patch '%s', which is outside of the tree", name)
+	}
+	return clean, nil
+}
+
+// Reverse returns the diff undoing this one.
+func (f File) Reverse() File {
+	ret := File{OldName: f.NewName, NewName: f.OldName}
+	for _, h := range f.Hunks {
+		reversed := Hunk{
+			OldStart: h.NewStart,
+			OldLines: h.NewLines,
+			NewStart: h.OldStart,
+			NewLines: h.OldLines,
+		}
+		/* The added lines go before the removed ones they replace */
+		removed := []string{}
+		for _, line := range h.Lines {
+			switch line[0] {
+			case '-':
+				removed = append(removed, "+"+line[1:])
+			case '+':
+				reversed.Lines = append(reversed.Lines, "-"+line[1:])
+			default:
+				reversed.Lines = append(append(reversed.Lines, removed...), line)
+				removed = removed[:0]
+			}
+		}
+		reversed.Lines = append(reversed.Lines, removed...)
+		ret.Hunks = append(ret.Hunks, reversed)
+	}
+	return ret
+}
+
+/* Split contents in lines, keeping their newlines */
+func splitLines(contents string) []string {
+	ret := []string{}
+	for contents != "" {
+		i := strings.IndexByte(contents, '\n')
+		if i < 0 {
+			ret = append(ret, contents)
+			break
+		}
+		ret = append(ret, contents[:i+1])
+		contents = contents[i+1:]
+	}
+	return ret
+}
+
+/* The lines of either side of the hunk */
+func (h Hunk) side(old bool) []string {
+	ret := []string{}
+	for _, line := range h.Lines {
+		if line[0] == ' ' || (old && line[0] == '-') || (!old && line[0] == '+') {
+			ret = append(ret, line[1:])
+		}
+	}
+	return ret
+}
+
+func matchAt(lines, want []string, at int) bool {
+	if at < 0 || at+len(want) > len(lines) {
+		return false
+	}
+	for i := range want {
+		if lines[at+i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Apply applies the hunks to the contents of the file, the way patch does
+// with no fuzz: the context has to match, but may have moved.
+func (f File) Apply(contents string) (string, error) {
+	lines := splitLines(contents)
+	ret := []string{}
+	done := 0
+	offset := 0
+	for n, h := range f.Hunks {
+		old, new := h.side(true), h.side(false)
+		expected := h.OldStart - 1 + offset
+		if h.OldLines == 0 {
+			/* Nothing to match, the lines go after OldStart */
+			expected++
+		}
+		at := -1
+		for delta := 0; expected-delta >= done || expected+delta <= len(lines); delta++ {
+			if expected-delta >= done && matchAt(lines, old, expected-delta) {
+				at = expected - delta
+				break
+			}
+			if matchAt(lines, old, expected+delta) {
+				at = expected + delta
+				break
+			}
+		}
+		if at < 0 {
+			return "", fmt.Errorf("Hunk #%d doesn't apply", n+1)
+		}
+		offset = at - (h.OldStart - 1)
+		if h.OldLines == 0 {
+			offset--
+		}
+		ret = append(ret, lines[done:at]...)
+		ret = append(ret, new...)
+		done = at + len(old)
+	}
+	ret = append(ret, lines[done:]...)
+	return strings.Join(ret, ""), nil
+}
+
+// }}}
+
+// Apply {{{
+
+// ApplyOptions tell Apply how to apply a patch.
+type ApplyOptions struct {
+	// Number of leading components stripped from the names of the files,
+	// as patch -p does.
+	Strip int
+
+	// Apply the patch in reverse.
+	Reverse bool
+
+	// Where to save the files as they were before the patch, an empty one
+	// for those it creates, as quilt does in .pc/<patch>. Nothing is saved
+	// if empty.
+	BackupDir string
+
+	// Only check that the patch applies, without changing anything.
+	DryRun bool
+}
+
+/* A file being patched */
+type patchedFile struct {
+	dest     string
+	original []byte
+	contents string
+	mode     os.FileMode
+}
+
+// patchPath returns where the file to patch is under dir, refusing the
+// ones which are reached through a symlink.
+func patchPath(dir, name string) (string, error) {
+	current := dir
+	for _, component := range strings.Split(name, "/") {
+		current = filepath.Join(current, component)
+		info, err := os.Lstat(current)
+		if os.IsNotExist(err) {
+			break
+		} else if err != nil {
+			return "", err
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return "", fmt.Errorf("Refusing to patch '%s' through the symlink '%s'", name, current)
+		}
+	}
+	return filepath.Join(dir, filepath.FromSlash(name)), nil
+}
+
+// Apply applies the diffs to the files under dir, and returns the names
+// of the files it changed, in order. Nothing is changed unless all of the
+// diffs apply. Files which end up empty are removed, as patch -E does.
+func Apply(dir string, files []File, opts ApplyOptions) ([]string, error) {
+	names := []string{}
+	patched := map[string]*patchedFile{}
+	for _, file := range files {
+		if opts.Reverse {
+			file = file.Reverse()
+		}
+		name, err := file.Target(opts.Strip)
+		if err != nil {
+			return nil, err
+		}
+		current := patched[name]
+		if current == nil {
+			dest, err := patchPath(dir, name)
+			if err != nil {
+				return nil, err
+			}
+			current = &patchedFile{dest: dest, mode: 0644}
+			current.original, err = os.ReadFile(dest)
+			if err == nil {
+				if info, err := os.Stat(dest); err == nil {
+					current.mode = info.Mode().Perm()
+				}
+			} else if !os.IsNotExist(err) {
+				return nil, err
+			}
+			current.contents = string(current.original)
+			patched[name] = current
+			names = append(names, name)
+		}
+		contents, err := file.Apply(current.contents)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", name, err)
+		}
+		current.contents = contents
+		if file.Removes() && contents != "" {
+			return nil, fmt.Errorf("%s: Not empty once removed", name)
+		}
+	}
+	if opts.DryRun {
+		return names, nil
+	}
+
+	for _, name := range names {
+		file := patched[name]
+		if opts.BackupDir != "" {
+			backup := filepath.Join(opts.BackupDir, filepath.FromSlash(name))
+			if err := os.MkdirAll(filepath.Dir(backup), 0755); err != nil {
+				return nil, err
+			}
+			if err := os.WriteFile(backup, file.original, file.mode); err != nil {
+				return nil, err
+			}
+		}
+		if file.contents == "" {
+			if err := os.Remove(file.dest); err != nil && !os.IsNotExist(err) {
+				return nil, err
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(file.dest), 0755); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(file.dest, []byte(file.contents), file.mode); err != nil {
+			return nil, err
+		}
+	}
+	return names, nil
+}
+
+// }}}
+
+// vim: foldmethod=marker
@@ -0,0 +1,198 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package patch_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ebikt/go-debian/patch"
+)
+
+/*
+ *
+ */
+
+const greeting = `#include <stdio.h>
+
+int main() {
+	puts("Hullo");
+	return 0;
+}
+`
+
+const greetingPatch = `Description: Greet properly
+
+Index: hello/hello.c
+===================================================================
+--- hello.orig/hello.c	2024-05-01 12:00:00.000000000 +0200
++++ hello/hello.c	2024-05-01 12:00:00.000000000 +0200
+@@ -2,5 +2,5 @@
+ 
+ int main() {
+-	puts("Hullo");
++	puts("Hello");
+ 	return 0;
+ }
+--- /dev/null
++++ hello/NEWS
+@@ -0,0 +1,2 @@
++Greeting properly
++Without a newline
+\ No newline at end of file
+`
+
+func parse(t *testing.T, text string) []patch.File {
+	files, err := patch.Parse(strings.NewReader(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func TestParse(t *testing.T) {
+	files := parse(t, greetingPatch)
+	if len(files) != 2 {
+		t.Fatalf("Unexpected files: %v", files)
+	}
+	if files[0].OldName != "hello.orig/hello.c" || files[0].NewName != "hello/hello.c" ||
+		len(files[0].Hunks) != 1 || files[0].Hunks[0].OldStart != 2 || files[0].Hunks[0].NewLines != 5 {
+		t.Fatalf("Unexpected diff: %v", files[0])
+	}
+	if !files[1].Creates() || files[1].Removes() {
+		t.Fatalf("Unexpected diff: %v", files[1])
+	}
+	if name, err := files[1].Target(1); err != nil || name != "NEWS" {
+		t.Fatalf("Unexpected target: %s %v", name, err)
+	}
+	if _, err := files[1].Target(2); err == nil {
+		t.Fatal("Stripped too many components")
+	}
+
+	if _, err := patch.Parse(strings.NewReader("--- a/x\n+++ b/x\n@@ -1,2 +1,2 @@\n-a\n+b\n")); err == nil {
+		t.Fatal("Parsed a truncated hunk")
+	}
+	if _, err := (patch.File{OldName: "a/../../x", NewName: "b/../../x"}).Target(1); err == nil {
+		t.Fatal("Patched outside of the tree")
+	}
+}
+
+func TestFileApply(t *testing.T) {
+	files := parse(t, greetingPatch)
+
+	/* The context moved down two lines */
+	patched, err := files[0].Apply("/* Hello */\n\n" + greeting)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if patched != "/* Hello */\n\n"+strings.Replace(greeting, "Hullo", "Hello", 1) {
+		t.Fatalf("Unexpected result: %q", patched)
+	}
+
+	reverted, err := files[0].Reverse().Apply(patched)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reverted != "/* Hello */\n\n"+greeting {
+		t.Fatalf("Unexpected result: %q", reverted)
+	}
+
+	if _, err := files[0].Apply(strings.Replace(greeting, "return 0", "return 1", 1)); err == nil {
+		t.Fatal("Applied with a different context")
+	}
+
+	news, err := files[1].Apply("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if news != "Greeting properly\nWithout a newline" {
+		t.Fatalf("Unexpected result: %q", news)
+	}
+}
+
+func readFile(t *testing.T, name string) string {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestApply(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "hello.c"), []byte(greeting), 0600); err != nil {
+		t.Fatal(err)
+	}
+	files := parse(t, greetingPatch)
+
+	names, err := patch.Apply(dir, files, patch.ApplyOptions{Strip: 1, DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(names, " ") != "hello.c NEWS" || readFile(t, filepath.Join(dir, "hello.c")) != greeting {
+		t.Fatalf("Unexpected dry run: %v", names)
+	}
+
+	backup := filepath.Join(dir, ".pc", "greeting.patch")
+	if _, err := patch.Apply(dir, files, patch.ApplyOptions{Strip: 1, BackupDir: backup}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(readFile(t, filepath.Join(dir, "hello.c")), "Hello") {
+		t.Fatal("hello.c wasn't patched")
+	}
+	if info, err := os.Stat(filepath.Join(dir, "hello.c")); err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("Unexpected mode: %v %v", info, err)
+	}
+	if readFile(t, filepath.Join(backup, "hello.c")) != greeting || readFile(t, filepath.Join(backup, "NEWS")) != "" {
+		t.Fatal("Unexpected backup")
+	}
+
+	/* Nothing changes when a diff doesn't apply */
+	if _, err := patch.Apply(dir, files, patch.ApplyOptions{Strip: 1}); err == nil {
+		t.Fatal("Applied twice")
+	}
+
+	if _, err := patch.Apply(dir, files, patch.ApplyOptions{Strip: 1, Reverse: true}); err != nil {
+		t.Fatal(err)
+	}
+	if readFile(t, filepath.Join(dir, "hello.c")) != greeting {
+		t.Fatal("hello.c wasn't restored")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "NEWS")); !os.IsNotExist(err) {
+		t.Fatalf("NEWS wasn't removed: %v", err)
+	}
+}
+
+func TestApplySymlink(t *testing.T) {
+	dir := t.TempDir()
+	outside := t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(dir, "hello")); err != nil {
+		t.Fatal(err)
+	}
+	files := parse(t, "--- /dev/null\n+++ b/hello/evil\n@@ -0,0 +1 @@\n+evil\n")
+	if _, err := patch.Apply(dir, files, patch.ApplyOptions{Strip: 1}); err == nil {
+		t.Fatal("Patched through a symlink")
+	}
+}
+
+// vim: foldmethod=marker
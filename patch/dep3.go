@@ -0,0 +1,160 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package patch // import "github.com/ebikt/go-debian/patch"
+
+import (
+	"bufio"
+	"io"
+	"strings"
+
+	"github.com/ebikt/go-debian/control"
+)
+
+// DEP-3 {{{
+
+// Header is the metadata of a patch, as described by DEP-3
+// (https://dep-team.pages.debian.net/deps/dep3/), read from the text
+// preceding its diffs.
+type Header struct {
+	// All the fields, as found in the header.
+	control.Paragraph
+
+	// The Description, or the Subject of a patch made with git
+	// format-patch, followed by its long description and the free-form
+	// text of the header.
+	Description string
+
+	// The Author, or the From of a patch made with git format-patch.
+	Author string
+
+	Origin string
+
+	// The Bug and Bug-<Vendor> fields.
+	Bugs []string
+
+	// "no", "not-needed", or where the patch was sent to.
+	Forwarded string
+
+	AppliedUpstream string
+	LastUpdate      string
+}
+
+/* The name of the field starting the line, if it does */
+func headerField(line string) (string, string, bool) {
+	i := strings.Index(line, ":")
+	if i <= 0 {
+		return "", "", false
+	}
+	for _, c := range line[:i] {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-') {
+			return "", "", false
+		}
+	}
+	return line[:i], strings.TrimSpace(line[i+1:]), true
+}
+
+/* Whether the line starts the diffs, ending the header */
+func diffStart(line string) bool {
+	return line == "---" || strings.HasPrefix(line, "--- ") || strings.HasPrefix(line, "diff ") ||
+		strings.HasPrefix(line, "Index: ") || strings.HasPrefix(line, "===")
+}
+
+// ParseHeader parses the DEP-3 header of a patch. The fields are read up
+// to the first empty line, or where the diffs start, and the text which
+// isn't fields is added to the Description.
+func ParseHeader(reader io.Reader) (*Header, error) {
+	ret := Header{Paragraph: control.NewParagraph()}
+	freeForm := []string{}
+
+	scanner := bufio.NewScanner(reader)
+	field := ""
+	first := true
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if diffStart(line) {
+			break
+		}
+		if first && strings.HasPrefix(line, "From ") {
+			/* The mbox separator of git format-patch */
+			first = false
+			continue
+		}
+		first = false
+
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && field != "" {
+			value := strings.TrimSpace(line)
+			if value == "." {
+				value = ""
+			}
+			ret.Set(field, ret.Get(field)+"\n"+value)
+			continue
+		}
+		if name, value, ok := headerField(line); ok {
+			field = name
+			if ret.Has(name) {
+				/* Such as several Bug fields, kept apart */
+				value = ret.Get(name) + "\n" + value
+			}
+			ret.Set(name, value)
+			continue
+		}
+		field = ""
+		freeForm = append(freeForm, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	ret.Description = ret.Get("Description")
+	if ret.Description == "" {
+		ret.Description = ret.Get("Subject")
+		if strings.HasPrefix(ret.Description, "[PATCH") {
+			if i := strings.Index(ret.Description, "]"); i > 0 {
+				ret.Description = strings.TrimSpace(ret.Description[i+1:])
+			}
+		}
+	}
+	if text := strings.TrimSpace(strings.Join(freeForm, "\n")); text != "" {
+		if ret.Description != "" {
+			ret.Description += "\n"
+		}
+		ret.Description += text
+	}
+
+	ret.Author = ret.Get("Author")
+	if ret.Author == "" {
+		ret.Author = ret.Get("From")
+	}
+	ret.Origin = ret.Get("Origin")
+	ret.Forwarded = ret.Get("Forwarded")
+	ret.AppliedUpstream = ret.Get("Applied-Upstream")
+	ret.LastUpdate = ret.Get("Last-Update")
+	for _, name := range ret.Order {
+		if strings.EqualFold(name, "Bug") || strings.HasPrefix(strings.ToLower(name), "bug-") {
+			ret.Bugs = append(ret.Bugs, strings.Split(ret.Get(name), "\n")...)
+		}
+	}
+	return &ret, nil
+}
+
+// }}}
+
+// vim: foldmethod=marker
@@ -0,0 +1,95 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package patch_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ebikt/go-debian/patch"
+)
+
+/*
+ *
+ */
+
+func TestParseHeader(t *testing.T) {
+	header, err := patch.ParseHeader(strings.NewReader(`Description: Fix the build with GCC 14
+ The prototypes were missing.
+ .
+ Upstream has it fixed already.
+Author: Jane Doe <jane@example.org>
+Origin: upstream, https://example.org/hello/commit/1234
+Bug: https://example.org/hello/issues/42
+Bug-Debian: https://bugs.debian.org/1000000
+Forwarded: not-needed
+Applied-Upstream: 2.11
+Last-Update: 2024-05-01
+
+Some more notes.
+
+--- a/hello.c
++++ b/hello.c
+@@ -1 +1 @@
+-int main();
++int main(void);
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.Description != "Fix the build with GCC 14\nThe prototypes were missing.\n\nUpstream has it fixed already.\nSome more notes." {
+		t.Fatalf("Unexpected description: %q", header.Description)
+	}
+	if header.Author != "Jane Doe <jane@example.org>" || header.Forwarded != "not-needed" ||
+		header.AppliedUpstream != "2.11" || header.LastUpdate != "2024-05-01" ||
+		header.Origin != "upstream, https://example.org/hello/commit/1234" {
+		t.Fatalf("Unexpected header: %v", header)
+	}
+	if strings.Join(header.Bugs, " ") != "https://example.org/hello/issues/42 https://bugs.debian.org/1000000" {
+		t.Fatalf("Unexpected bugs: %v", header.Bugs)
+	}
+	if header.Get("Bug-Debian") != "https://bugs.debian.org/1000000" {
+		t.Fatal("Bug-Debian isn't in the paragraph")
+	}
+}
+
+func TestParseHeaderGit(t *testing.T) {
+	header, err := patch.ParseHeader(strings.NewReader(`From 0123456789abcdef0123456789abcdef01234567 Mon Sep 17 00:00:00 2001
+From: Jane Doe <jane@example.org>
+Date: Wed, 1 May 2024 12:00:00 +0200
+Subject: [PATCH 1/2] Don't use the network in the tests
+
+---
+ tests/run.sh | 2 +-
+ 1 file changed, 1 insertion(+), 1 deletion(-)
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.Description != "Don't use the network in the tests" {
+		t.Fatalf("Unexpected description: %q", header.Description)
+	}
+	if header.Author != "Jane Doe <jane@example.org>" || header.Forwarded != "" {
+		t.Fatalf("Unexpected header: %v", header)
+	}
+}
+
+// vim: foldmethod=marker
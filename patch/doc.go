@@ -0,0 +1,7 @@
+/*
+
+This module reads quilt patch series, the DEP-3 headers of the patches,
+and applies the unified diffs they carry.
+
+*/
+package patch // import "github.com/ebikt/go-debian/patch"
@@ -0,0 +1,96 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package patch // import "github.com/ebikt/go-debian/patch"
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Series {{{
+
+// A SeriesEntry is a patch of a quilt series, such as
+// debian/patches/series, with its options.
+type SeriesEntry struct {
+	// Name of the patch, relative to the patches directory.
+	Name string
+
+	// Number of leading components stripped from the names of the files,
+	// 1 unless set with -p.
+	Strip int
+
+	// Whether the patch is applied in reverse, set with -R.
+	Reverse bool
+}
+
+// ParseSeries parses a quilt series: a patch per line, optionally followed
+// by its options, -pN and -R, and comments starting with a "#". Like
+// dpkg-source, it refuses the patches out of the patches directory: the
+// absolute names, and the ones with a ".." component.
+func ParseSeries(reader io.Reader) ([]SeriesEntry, error) {
+	ret := []SeriesEntry{}
+	scanner := bufio.NewScanner(reader)
+	lineno := 0
+	for scanner.Scan() {
+		lineno++
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t') {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if path.IsAbs(fields[0]) {
+			return nil, fmt.Errorf("Absolute patch name '%s' on line %d", fields[0], lineno)
+		}
+		for _, part := range strings.Split(fields[0], "/") {
+			if part == ".." {
+				return nil, fmt.Errorf("Patch name '%s' out of the patches directory on line %d", fields[0], lineno)
+			}
+		}
+		entry := SeriesEntry{Name: fields[0], Strip: 1}
+		for _, option := range fields[1:] {
+			switch {
+			case option == "-R":
+				entry.Reverse = true
+			case strings.HasPrefix(option, "-p"):
+				strip, err := strconv.Atoi(option[2:])
+				if err != nil || strip < 0 {
+					return nil, fmt.Errorf("Malformed option '%s' on line %d", option, lineno)
+				}
+				entry.Strip = strip
+			default:
+				return nil, fmt.Errorf("Unsupported option '%s' on line %d", option, lineno)
+			}
+		}
+		ret = append(ret, entry)
+	}
+	return ret, scanner.Err()
+}
+
+// }}}
+
+// vim: foldmethod=marker
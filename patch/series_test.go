@@ -0,0 +1,68 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package patch_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ebikt/go-debian/patch"
+)
+
+/*
+ *
+ */
+
+func TestParseSeries(t *testing.T) {
+	series, err := patch.ParseSeries(strings.NewReader(`# Upstream fixes
+fix-build.patch
+debian/no-network.patch -p0   # From the old packaging
+revert-regression.patch -R -p2
+
+fix#1.patch
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []patch.SeriesEntry{
+		{Name: "fix-build.patch", Strip: 1},
+		{Name: "debian/no-network.patch", Strip: 0},
+		{Name: "revert-regression.patch", Strip: 2, Reverse: true},
+		{Name: "fix#1.patch", Strip: 1},
+	}
+	if len(series) != len(expected) {
+		t.Fatalf("Unexpected series: %v", series)
+	}
+	for i := range expected {
+		if series[i] != expected[i] {
+			t.Fatalf("Unexpected entry %d: %v", i, series[i])
+		}
+	}
+
+	for _, bad := range []string{"a.patch -pfoo\n", "a.patch --fuzz=3\n", "/etc/evil.patch\n",
+		"../../sub/evil.patch\n", "debian/../../evil.patch -p1\n", "..\n"} {
+		if _, err := patch.ParseSeries(strings.NewReader(bad)); err == nil {
+			t.Fatalf("Parsed %q", bad)
+		}
+	}
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,297 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package shlibdeps // import "github.com/ebikt/go-debian/shlibdeps"
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ebikt/go-debian/control"
+	"github.com/ebikt/go-debian/deb"
+	"github.com/ebikt/go-debian/dependency"
+	"github.com/ebikt/go-debian/version"
+)
+
+// Database {{{
+
+// Database holds what the packages providing shared libraries tell their
+// users to depend on: their shlibs and symbols files.
+type Database struct {
+	shlibs  map[string]dependency.Dependency
+	symbols map[string]deb.SymbolsLibrary
+}
+
+// NewDatabase creates an empty Database.
+func NewDatabase() *Database {
+	return &Database{
+		shlibs:  map[string]dependency.Dependency{},
+		symbols: map[string]deb.SymbolsLibrary{},
+	}
+}
+
+// splitSoname splits a soname into the library name and soname version
+// of shlibs files: libz.so.1 is libz 1, and libdb-5.3.so is libdb 5.3.
+func splitSoname(soname string) (string, string, bool) {
+	if i := strings.Index(soname, ".so."); i > 0 {
+		return soname[:i], soname[i+4:], true
+	}
+	if strings.HasSuffix(soname, ".so") {
+		name := strings.TrimSuffix(soname, ".so")
+		if i := strings.LastIndex(name, "-"); i > 0 {
+			return name[:i], name[i+1:], true
+		}
+	}
+	return "", "", false
+}
+
+// AddShlibs adds the lines of a shlibs file. Lines for other package
+// types than .deb, such as udeb, are skipped.
+func (d *Database) AddShlibs(shlibs []deb.Shlib) {
+	for _, shlib := range shlibs {
+		if shlib.Type != "" {
+			continue
+		}
+		d.shlibs[shlib.Library+" "+shlib.Version] = shlib.Depends
+	}
+}
+
+// AddSymbols adds the libraries of a symbols file, which take precedence
+// over shlibs files.
+func (d *Database) AddSymbols(libraries []deb.SymbolsLibrary) {
+	for _, library := range libraries {
+		d.symbols[library.Library] = library
+	}
+}
+
+// AddDeb adds the shlibs and symbols files of a .deb.
+func (d *Database) AddDeb(debFile *deb.Deb) error {
+	shlibs, err := debFile.Shlibs()
+	if err != nil {
+		return err
+	}
+	d.AddShlibs(shlibs)
+	libraries, err := debFile.Symbols()
+	if err != nil {
+		return err
+	}
+	d.AddSymbols(libraries)
+	return nil
+}
+
+// }}}
+
+// Resolve {{{
+
+// ResolveOptions tell Resolve how to work out the dependencies.
+type ResolveOptions struct {
+	// Packages to leave out of the dependencies, such as the one being
+	// built, as dpkg-shlibdeps -x does.
+	Exclude []string
+
+	// Leave out the libraries found in no shlibs nor symbols file, rather
+	// than failing.
+	IgnoreMissing bool
+}
+
+// fromSymbols returns the dependencies on a library the objects get from
+// its symbols file: a template per symbol they use, with #MINVER#
+// replaced by the highest minimum version of the symbols using it.
+func fromSymbols(library deb.SymbolsLibrary, objects []Object) ([]string, error) {
+	symbols := map[string]deb.Symbol{}
+	for _, symbol := range library.Symbols {
+		if symbol.IsMissing() {
+			continue
+		}
+		symbols[symbol.Name] = symbol
+	}
+	minVersions := map[int]*version.Version{}
+	for _, object := range objects {
+		for _, imported := range object.Symbols {
+			if imported.Library != "" && imported.Library != library.Library {
+				continue
+			}
+			symbol, found := symbols[imported.SymbolName()]
+			if !found {
+				continue
+			}
+			current := minVersions[symbol.Template]
+			if current == nil || version.Compare(symbol.MinVersion, *current) > 0 {
+				minVersion := symbol.MinVersion
+				minVersions[symbol.Template] = &minVersion
+			}
+		}
+	}
+	if len(minVersions) == 0 {
+		/* None of the known symbols is used: the lowest version will do */
+		for _, symbol := range library.Symbols {
+			if symbol.Template != 0 || symbol.IsMissing() {
+				continue
+			}
+			current := minVersions[0]
+			if current == nil || version.Compare(symbol.MinVersion, *current) < 0 {
+				minVersion := symbol.MinVersion
+				minVersions[0] = &minVersion
+			}
+		}
+	}
+
+	ret := []string{}
+	for template, minVersion := range minVersions {
+		if template >= len(library.Depends) {
+			return nil, fmt.Errorf("%s: Missing dependency template %d", library.Library, template)
+		}
+		constraint := ""
+		if minVersion != nil && !minVersion.Empty() && minVersion.String() != "0" {
+			constraint = "(>= " + minVersion.String() + ")"
+		}
+		ret = append(ret, strings.TrimSpace(strings.Replace(library.Depends[template], "#MINVER#", constraint, -1)))
+	}
+	if len(minVersions) == 0 {
+		ret = append(ret, strings.TrimSpace(strings.Replace(library.Depends[0], "#MINVER#", "", -1)))
+	}
+	return ret, nil
+}
+
+// Resolve works out the dependencies of the objects of a package on the
+// libraries they are linked to, from the symbols files, or from the
+// shlibs files for the libraries without one. Libraries which are objects
+// of the package are skipped. Dependencies on the same package are merged,
+// keeping the highest version.
+func (d *Database) Resolve(objects []Object, opts ResolveOptions) (*dependency.Dependency, error) {
+	provided := map[string]bool{}
+	for _, object := range objects {
+		if object.Soname != "" {
+			provided[object.Soname] = true
+		}
+	}
+	needed := map[string][]Object{}
+	for _, object := range objects {
+		for _, soname := range object.Needed {
+			needed[soname] = append(needed[soname], object)
+		}
+	}
+	sonames := []string{}
+	for soname := range needed {
+		sonames = append(sonames, soname)
+	}
+	sort.Strings(sonames)
+
+	relations := []string{}
+	missing := []string{}
+	for _, soname := range sonames {
+		if provided[soname] {
+			continue
+		}
+		if library, found := d.symbols[soname]; found {
+			deps, err := fromSymbols(library, needed[soname])
+			if err != nil {
+				return nil, err
+			}
+			relations = append(relations, deps...)
+			continue
+		}
+		name, soversion, ok := splitSoname(soname)
+		if dep, found := d.shlibs[name+" "+soversion]; ok && found {
+			relations = append(relations, dep.String())
+			continue
+		}
+		missing = append(missing, soname)
+	}
+	if len(missing) > 0 && !opts.IgnoreMissing {
+		return nil, fmt.Errorf("No dependency information found for %s", strings.Join(missing, ", "))
+	}
+
+	dep, err := dependency.Parse(strings.Join(relations, ", "))
+	if err != nil {
+		return nil, err
+	}
+	return mergeRelations(*dep, opts.Exclude), nil
+}
+
+// mergeRelations drops the relations on excluded packages and the
+// duplicates, keeps the highest of the ">=" versions of a package, and
+// sorts the relations, as dpkg-shlibdeps does.
+func mergeRelations(dep dependency.Dependency, exclude []string) *dependency.Dependency {
+	excluded := map[string]bool{}
+	for _, pkg := range exclude {
+		excluded[pkg] = true
+	}
+
+	ret := dependency.Dependency{Relations: []dependency.Relation{}}
+	simple := map[string]int{}
+	seen := map[string]bool{}
+	for _, relation := range dep.Relations {
+		if len(relation.Possibilities) == 1 {
+			possibility := relation.Possibilities[0]
+			if excluded[possibility.Name] {
+				continue
+			}
+			if possibility.Version == nil || possibility.Version.Operator == ">=" {
+				if i, found := simple[possibility.Name]; found {
+					current := &ret.Relations[i].Possibilities[0]
+					if possibility.Version != nil && (current.Version == nil ||
+						compareVersions(possibility.Version.Number, current.Version.Number) > 0) {
+						current.Version = possibility.Version
+					}
+					continue
+				}
+				simple[possibility.Name] = len(ret.Relations)
+			}
+		}
+		if seen[relation.String()] {
+			continue
+		}
+		seen[relation.String()] = true
+		ret.Relations = append(ret.Relations, relation)
+	}
+	sort.SliceStable(ret.Relations, func(i, j int) bool {
+		return ret.Relations[i].String() < ret.Relations[j].String()
+	})
+	return &ret
+}
+
+func compareVersions(a, b string) int {
+	first, err := version.Parse(a)
+	if err != nil {
+		return strings.Compare(a, b)
+	}
+	second, err := version.Parse(b)
+	if err != nil {
+		return strings.Compare(a, b)
+	}
+	return version.Compare(first, second)
+}
+
+// Substvars sets the shlibs:Depends variable to the dependencies the
+// objects of a package get, see Resolve.
+func (d *Database) Substvars(substvars *control.Substvars, objects []Object, opts ResolveOptions) error {
+	dep, err := d.Resolve(objects, opts)
+	if err != nil {
+		return err
+	}
+	substvars.Set("shlibs:Depends", dep.String())
+	return nil
+}
+
+// }}}
+
+// vim: foldmethod=marker
@@ -0,0 +1,117 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package shlibdeps_test
+
+import (
+	"testing"
+
+	"github.com/ebikt/go-debian/control"
+	"github.com/ebikt/go-debian/deb"
+	"github.com/ebikt/go-debian/shlibdeps"
+)
+
+/*
+ *
+ */
+
+func database(t *testing.T) *shlibdeps.Database {
+	db := shlibdeps.NewDatabase()
+	isok(t, db.AddDeb(&deb.Deb{ControlFiles: map[string][]byte{
+		"shlibs": []byte("libz 1 zlib1g (>= 1:1.2.3)\nudeb: libz 1 zlib1g-udeb\n"),
+	}}))
+	isok(t, db.AddDeb(&deb.Deb{ControlFiles: map[string][]byte{
+		"shlibs": []byte("libhello 1 libhello1\n"),
+		"symbols": []byte("libhello.so.1 libhello1 #MINVER#\n" +
+			"| libhello-extra1 #MINVER#\n" +
+			" hello@Base 2.9\n" +
+			" hello_world@Base 2.10\n" +
+			" hello_extra@Base 2.11 1\n"),
+	}}))
+	isok(t, db.AddDeb(&deb.Deb{ControlFiles: map[string][]byte{
+		"shlibs": []byte("libdb 5.3 libdb5.3\n"),
+	}}))
+	return db
+}
+
+func TestResolveSymbols(t *testing.T) {
+	db := database(t)
+
+	dep, err := db.Resolve([]shlibdeps.Object{{
+		Path:    "usr/bin/hello",
+		Needed:  []string{"libhello.so.1"},
+		Symbols: []shlibdeps.ImportedSymbol{{Name: "hello"}, {Name: "hello_world"}},
+	}}, shlibdeps.ResolveOptions{})
+	isok(t, err)
+	assert(t, dep.String() == "libhello1 (>= 2.10)")
+
+	dep, err = db.Resolve([]shlibdeps.Object{{
+		Path:    "usr/bin/hello",
+		Needed:  []string{"libhello.so.1"},
+		Symbols: []shlibdeps.ImportedSymbol{{Name: "hello"}, {Name: "hello_extra"}},
+	}}, shlibdeps.ResolveOptions{})
+	isok(t, err)
+	assert(t, dep.String() == "libhello-extra1 (>= 2.11), libhello1 (>= 2.9)")
+
+	/* No known symbol used: the lowest version */
+	dep, err = db.Resolve([]shlibdeps.Object{{
+		Path:   "usr/bin/hello",
+		Needed: []string{"libhello.so.1"},
+	}}, shlibdeps.ResolveOptions{})
+	isok(t, err)
+	assert(t, dep.String() == "libhello1 (>= 2.9)")
+}
+
+func TestResolveShlibs(t *testing.T) {
+	db := database(t)
+
+	dep, err := db.Resolve([]shlibdeps.Object{
+		{Path: "usr/bin/hello", Needed: []string{"libz.so.1", "libhello.so.1", "libdb-5.3.so"},
+			Symbols: []shlibdeps.ImportedSymbol{{Name: "hello_world"}}},
+		{Path: "usr/bin/hello-z", Needed: []string{"libz.so.1", "libhello-private.so.0"}},
+		{Path: "usr/lib/hello/libhello-private.so.0", Soname: "libhello-private.so.0"},
+	}, shlibdeps.ResolveOptions{Exclude: []string{"libdb5.3"}})
+	isok(t, err)
+	assert(t, dep.String() == "libhello1 (>= 2.10), zlib1g (>= 1:1.2.3)")
+
+	_, err = db.Resolve([]shlibdeps.Object{
+		{Path: "usr/bin/hello", Needed: []string{"libmissing.so.2"}},
+	}, shlibdeps.ResolveOptions{})
+	assert(t, err != nil)
+	assert(t, err.Error() == "No dependency information found for libmissing.so.2")
+
+	dep, err = db.Resolve([]shlibdeps.Object{
+		{Path: "usr/bin/hello", Needed: []string{"libmissing.so.2", "libz.so.1"}},
+	}, shlibdeps.ResolveOptions{IgnoreMissing: true})
+	isok(t, err)
+	assert(t, dep.String() == "zlib1g (>= 1:1.2.3)")
+}
+
+func TestSubstvars(t *testing.T) {
+	db := database(t)
+	substvars := control.NewSubstvars()
+	isok(t, db.Substvars(substvars, []shlibdeps.Object{
+		{Path: "usr/bin/hello", Needed: []string{"libz.so.1"}},
+	}, shlibdeps.ResolveOptions{}))
+	value, _ := substvars.Get("shlibs:Depends")
+	assert(t, value == "zlib1g (>= 1:1.2.3)")
+}
+
+// vim: foldmethod=marker
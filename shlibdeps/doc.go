@@ -0,0 +1,8 @@
+/*
+
+This module works out the dependencies of a package on the shared
+libraries its ELF objects are linked to, as dpkg-shlibdeps does, for the
+${shlibs:Depends} substitution variable.
+
+*/
+package shlibdeps // import "github.com/ebikt/go-debian/shlibdeps"
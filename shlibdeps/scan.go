@@ -0,0 +1,195 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package shlibdeps // import "github.com/ebikt/go-debian/shlibdeps"
+
+import (
+	"archive/tar"
+	"bytes"
+	"debug/elf"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/ebikt/go-debian/deb"
+)
+
+// Objects {{{
+
+// An ImportedSymbol is a symbol an ELF object needs from the libraries it
+// is linked to.
+type ImportedSymbol struct {
+	Name string
+
+	// Version of the symbol, such as "GLIBC_2.34", empty if unversioned.
+	Version string
+
+	// Soname of the library the version is from, if versioned.
+	Library string
+}
+
+// SymbolName returns the name of the symbol as found in symbols files,
+// such as "memcpy@GLIBC_2.14", or "compress@Base" if unversioned.
+func (s ImportedSymbol) SymbolName() string {
+	if s.Version == "" {
+		return s.Name + "@Base"
+	}
+	return s.Name + "@" + s.Version
+}
+
+// An Object is an ELF executable or shared library of a package.
+type Object struct {
+	// Path of the object, relative to the root, such as "usr/bin/hello".
+	Path string
+
+	// Soname of a library, such as "libhello.so.1".
+	Soname string
+
+	// Sonames of the libraries the object is linked to, DT_NEEDED.
+	Needed []string
+
+	Symbols []ImportedSymbol
+
+	// Symbols the object exports, as named in symbols files, such as
+	// "hello@Base".
+	Exported []string
+}
+
+// readObject returns the Object of the ELF file, or nil if the file isn't
+// an ELF object linked dynamically.
+func readObject(name string, contents []byte) (*Object, error) {
+	if !bytes.HasPrefix(contents, []byte(elf.ELFMAG)) {
+		return nil, nil
+	}
+	file, err := elf.NewFile(bytes.NewReader(contents))
+	if err != nil {
+		return nil, nil
+	}
+	defer file.Close()
+	if file.Type != elf.ET_EXEC && file.Type != elf.ET_DYN {
+		return nil, nil
+	}
+	if file.Section(".dynamic") == nil {
+		return nil, nil
+	}
+
+	ret := Object{Path: name}
+	if sonames, err := file.DynString(elf.DT_SONAME); err == nil && len(sonames) > 0 {
+		ret.Soname = sonames[0]
+	}
+	if ret.Needed, err = file.DynString(elf.DT_NEEDED); err != nil {
+		return nil, err
+	}
+	imported, err := file.ImportedSymbols()
+	if err != nil && err != elf.ErrNoSymbols {
+		return nil, err
+	}
+	for _, symbol := range imported {
+		ret.Symbols = append(ret.Symbols, ImportedSymbol{
+			Name:    symbol.Name,
+			Version: symbol.Version,
+			Library: symbol.Library,
+		})
+	}
+	dynamic, err := file.DynamicSymbols()
+	if err != nil && err != elf.ErrNoSymbols {
+		return nil, err
+	}
+	for _, symbol := range dynamic {
+		if exported(symbol) {
+			imported := ImportedSymbol{Name: symbol.Name, Version: symbol.Version}
+			ret.Exported = append(ret.Exported, imported.SymbolName())
+		}
+	}
+	sort.Strings(ret.Exported)
+	return &ret, nil
+}
+
+// exported returns whether a dynamic symbol is exported: defined, global
+// or weak, and visible.
+func exported(symbol elf.Symbol) bool {
+	if symbol.Section == elf.SHN_UNDEF || symbol.Name == "" {
+		return false
+	}
+	switch elf.ST_BIND(symbol.Info) {
+	case elf.STB_GLOBAL, elf.STB_WEAK, elf.STB_LOOS: /* STB_GNU_UNIQUE */
+	default:
+		return false
+	}
+	switch elf.ST_VISIBILITY(symbol.Other) {
+	case elf.STV_DEFAULT, elf.STV_PROTECTED:
+		return true
+	}
+	return false
+}
+
+// ScanFS returns the dynamically linked ELF objects of a tree, such as
+// the DataFS of a .deb or an os.DirFS, skipping /usr/lib/debug.
+func ScanFS(fsys fs.FS) ([]Object, error) {
+	ret := []Object{}
+	err := fs.WalkDir(fsys, ".", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() && strings.TrimPrefix(name, "./") == "usr/lib/debug" {
+			return fs.SkipDir
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		contents, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		object, err := readObject(name, contents)
+		if err != nil {
+			return err
+		}
+		if object != nil {
+			ret = append(ret, *object)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// ScanDir returns the dynamically linked ELF objects found under the
+// directory, such as debian/hello, see ScanFS.
+func ScanDir(dir string) ([]Object, error) {
+	return ScanFS(os.DirFS(dir))
+}
+
+// ScanTar returns the dynamically linked ELF objects of a tar archive,
+// such as the data.tar of a .deb, see ScanFS.
+func ScanTar(data *tar.Reader) ([]Object, error) {
+	fsys, err := deb.NewDataFS(data)
+	if err != nil {
+		return nil, err
+	}
+	return ScanFS(fsys)
+}
+
+// }}}
+
+// vim: foldmethod=marker
@@ -0,0 +1,108 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package shlibdeps_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/ebikt/go-debian/shlibdeps"
+)
+
+/*
+ *
+ */
+
+func isok(t *testing.T, err error) {
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+}
+
+func assert(t *testing.T, expr bool) {
+	if !expr {
+		t.Fatalf("Assertion failed!")
+	}
+}
+
+/*
+ *
+ */
+
+func compile(t *testing.T, dir string, args ...string) {
+	cmd := exec.Command("gcc", args...)
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("gcc %v: %v\n%s", args, err, out)
+	}
+}
+
+func TestScanDir(t *testing.T) {
+	if _, err := exec.LookPath("gcc"); err != nil {
+		t.Skip("gcc is not installed")
+	}
+	build := t.TempDir()
+	isok(t, os.WriteFile(filepath.Join(build, "hello.c"),
+		[]byte("int hello(void) { return 42; }\n"), 0644))
+	isok(t, os.WriteFile(filepath.Join(build, "main.c"),
+		[]byte("int hello(void);\nint main(void) { return hello(); }\n"), 0644))
+
+	root := t.TempDir()
+	isok(t, os.MkdirAll(filepath.Join(root, "usr/lib/debug"), 0755))
+	isok(t, os.MkdirAll(filepath.Join(root, "usr/bin"), 0755))
+	compile(t, build, "-shared", "-fPIC", "-Wl,-soname,libhello.so.1", "-o",
+		filepath.Join(root, "usr/lib/libhello.so.1"), "hello.c")
+	compile(t, build, "-o", filepath.Join(root, "usr/bin/hello"), "main.c",
+		filepath.Join(root, "usr/lib/libhello.so.1"))
+	compile(t, build, "-shared", "-fPIC", "-o",
+		filepath.Join(root, "usr/lib/debug/libhello.so.1.debug"), "hello.c")
+	isok(t, os.WriteFile(filepath.Join(root, "usr/bin/script"), []byte("#!/bin/sh\n"), 0755))
+
+	objects, err := shlibdeps.ScanDir(root)
+	isok(t, err)
+	assert(t, len(objects) == 2)
+	assert(t, objects[0].Path == "usr/bin/hello")
+	assert(t, objects[0].Soname == "")
+	assert(t, objects[1].Path == "usr/lib/libhello.so.1")
+	assert(t, objects[1].Soname == "libhello.so.1")
+	assert(t, len(objects[1].Exported) == 1 && objects[1].Exported[0] == "hello@Base")
+
+	needed := map[string]bool{}
+	for _, soname := range objects[0].Needed {
+		needed[soname] = true
+	}
+	assert(t, needed["libhello.so.1"])
+	imported := map[string]bool{}
+	for _, symbol := range objects[0].Symbols {
+		imported[symbol.SymbolName()] = true
+	}
+	assert(t, imported["hello@Base"])
+}
+
+func TestSymbolName(t *testing.T) {
+	assert(t, shlibdeps.ImportedSymbol{Name: "compress"}.SymbolName() == "compress@Base")
+	assert(t, shlibdeps.ImportedSymbol{Name: "memcpy", Version: "GLIBC_2.14",
+		Library: "libc.so.6"}.SymbolName() == "memcpy@GLIBC_2.14")
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,164 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package shlibdeps // import "github.com/ebikt/go-debian/shlibdeps"
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ebikt/go-debian/deb"
+	"github.com/ebikt/go-debian/version"
+)
+
+// Symbols files {{{
+
+// A SymbolsDiff tells how the symbols a library exports differ from the
+// ones listed in its symbols file, as dpkg-gensymbols reports.
+type SymbolsDiff struct {
+	Library string
+
+	// Symbols exported but not listed, or listed as missing.
+	New []string
+
+	// Symbols listed but not exported, but for the optional ones.
+	Lost []string
+}
+
+// Empty returns whether the symbols file is up to date.
+func (d SymbolsDiff) Empty() bool {
+	return len(d.New) == 0 && len(d.Lost) == 0
+}
+
+// String returns the diff of the symbols file, a "+" or "-" line per
+// new or lost symbol.
+func (d SymbolsDiff) String() string {
+	lines := []string{}
+	for _, name := range d.New {
+		lines = append(lines, "+ "+name)
+	}
+	for _, name := range d.Lost {
+		lines = append(lines, "- "+name)
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	return d.Library + "\n" + strings.Join(lines, "\n") + "\n"
+}
+
+// DiffSymbols compares the symbols a library exports, as scanned, with
+// the ones listed in its symbols file. Symbols tagged "regex" match the
+// exported symbols their name matches, such as "^hello_.*@Base$". Symbols
+// tagged "c++" or "arch=" are not supported: they can't be matched, as
+// the exported symbols are neither demangled nor of a known architecture,
+// so they are never lost, and the symbols they stand for are new.
+func DiffSymbols(library deb.SymbolsLibrary, object Object) SymbolsDiff {
+	ret := SymbolsDiff{Library: library.Library, New: []string{}, Lost: []string{}}
+	matched := map[int]bool{}
+	for _, name := range object.Exported {
+		i := lookupSymbol(library, name)
+		if i == -1 || library.Symbols[i].IsMissing() {
+			ret.New = append(ret.New, name)
+		}
+		if i != -1 {
+			matched[i] = true
+		}
+	}
+	for i, symbol := range library.Symbols {
+		if _, optional := symbol.Tag("optional"); optional || symbol.IsMissing() || !comparable(symbol) {
+			continue
+		}
+		if !matched[i] {
+			ret.Lost = append(ret.Lost, symbol.Name)
+		}
+	}
+	sort.Strings(ret.New)
+	sort.Strings(ret.Lost)
+	return ret
+}
+
+// comparable returns whether the symbol can be matched against the
+// exported symbols, see DiffSymbols.
+func comparable(symbol deb.Symbol) bool {
+	_, cxx := symbol.Tag("c++")
+	_, arch := symbol.Tag("arch")
+	return !cxx && !arch
+}
+
+// lookupSymbol returns the index of the symbol of the library the
+// exported symbol is, by name or else by regex, or -1.
+func lookupSymbol(library deb.SymbolsLibrary, name string) int {
+	for i, symbol := range library.Symbols {
+		if _, regex := symbol.Tag("regex"); !regex && symbol.Name == name && comparable(symbol) {
+			return i
+		}
+	}
+	for i, symbol := range library.Symbols {
+		if _, regex := symbol.Tag("regex"); !regex || !comparable(symbol) {
+			continue
+		}
+		if pattern, err := regexp.Compile(symbol.Name); err == nil && pattern.MatchString(name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// UpdateSymbols brings the symbols file of a library up to date with the
+// symbols it exports, as scanned from the given version of its package:
+// new symbols are added with that minimum version, symbols listed as
+// missing but exported again are no longer, and lost symbols are marked
+// missing since that version. The symbols are sorted by name, and the
+// changes returned.
+func UpdateSymbols(library *deb.SymbolsLibrary, object Object, current version.Version) SymbolsDiff {
+	diff := DiffSymbols(*library, object)
+	for _, name := range diff.New {
+		if i := lookupSymbol(*library, name); i != -1 {
+			library.Symbols[i].Missing = version.Version{}
+			continue
+		}
+		library.Symbols = append(library.Symbols, deb.Symbol{Name: name, MinVersion: current})
+	}
+	for _, name := range diff.Lost {
+		library.Lookup(name).Missing = current
+	}
+	sort.SliceStable(library.Symbols, func(i, j int) bool {
+		return library.Symbols[i].Name < library.Symbols[j].Name
+	})
+	return diff
+}
+
+// NewSymbolsLibrary returns the symbols file of a library, listing the
+// symbols it exports with the given minimum version, and "package
+// #MINVER#" as dependency template.
+func NewSymbolsLibrary(object Object, pkg string, current version.Version) deb.SymbolsLibrary {
+	ret := deb.SymbolsLibrary{
+		Library: object.Soname,
+		Depends: []string{pkg + " #MINVER#"},
+		Fields:  map[string]string{},
+	}
+	UpdateSymbols(&ret, object, current)
+	return ret
+}
+
+// }}}
+
+// vim: foldmethod=marker
@@ -0,0 +1,132 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package shlibdeps_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ebikt/go-debian/deb"
+	"github.com/ebikt/go-debian/shlibdeps"
+	"github.com/ebikt/go-debian/version"
+)
+
+/*
+ *
+ */
+
+func symbolsLibrary(t *testing.T) deb.SymbolsLibrary {
+	libraries, err := deb.ParseSymbols(strings.NewReader("libhello.so.1 libhello1 #MINVER#\n" +
+		" hello@Base 2.9\n" +
+		" (optional)hello_inline@Base 2.9\n" +
+		" hello_world@Base 2.10\n" +
+		"#MISSING: 2.11# hello_old@Base 2.9\n"))
+	isok(t, err)
+	assert(t, len(libraries) == 1)
+	return libraries[0]
+}
+
+func TestDiffSymbols(t *testing.T) {
+	library := symbolsLibrary(t)
+	object := shlibdeps.Object{
+		Path:     "usr/lib/libhello.so.1",
+		Soname:   "libhello.so.1",
+		Exported: []string{"hello@Base", "hello_new@Base", "hello_old@Base"},
+	}
+	diff := shlibdeps.DiffSymbols(library, object)
+	assert(t, !diff.Empty())
+	assert(t, strings.Join(diff.New, " ") == "hello_new@Base hello_old@Base")
+	assert(t, strings.Join(diff.Lost, " ") == "hello_world@Base")
+	assert(t, diff.String() == "libhello.so.1\n+ hello_new@Base\n+ hello_old@Base\n- hello_world@Base\n")
+
+	object.Exported = []string{"hello@Base", "hello_world@Base"}
+	diff = shlibdeps.DiffSymbols(library, object)
+	assert(t, diff.Empty())
+	assert(t, diff.String() == "")
+}
+
+func TestDiffTaggedSymbols(t *testing.T) {
+	libraries, err := deb.ParseSymbols(strings.NewReader("libhello.so.1 libhello1 #MINVER#\n" +
+		" (regex)^hello_private_.*@Base$ 2.9\n" +
+		" (c++)\"hello::world()@Base\" 2.9\n" +
+		" (arch=amd64)hello_amd64@Base 2.9\n" +
+		"#MISSING: 2.11# (regex)^hello_old_.*@Base$ 2.9\n"))
+	isok(t, err)
+	library := libraries[0]
+	object := shlibdeps.Object{
+		Soname:   "libhello.so.1",
+		Exported: []string{"hello_private_a@Base", "hello_private_b@Base", "_ZN5hello5worldEv@Base"},
+	}
+	diff := shlibdeps.DiffSymbols(library, object)
+	assert(t, strings.Join(diff.New, " ") == "_ZN5hello5worldEv@Base")
+	assert(t, strings.Join(diff.Lost, " ") == "")
+
+	object.Exported = []string{"hello_old_a@Base"}
+	diff = shlibdeps.DiffSymbols(library, object)
+	assert(t, strings.Join(diff.New, " ") == "hello_old_a@Base")
+	assert(t, strings.Join(diff.Lost, " ") == "^hello_private_.*@Base$")
+
+	current, err := version.Parse("2.12-1")
+	isok(t, err)
+	shlibdeps.UpdateSymbols(&library, object, current)
+	assert(t, len(library.Symbols) == 4)
+	for _, symbol := range library.Symbols {
+		switch symbol.Name {
+		case "^hello_old_.*@Base$":
+			assert(t, !symbol.IsMissing())
+		case "^hello_private_.*@Base$":
+			assert(t, symbol.Missing.String() == "2.12-1")
+		}
+	}
+}
+
+func TestUpdateSymbols(t *testing.T) {
+	library := symbolsLibrary(t)
+	current, err := version.Parse("2.12-1")
+	isok(t, err)
+	shlibdeps.UpdateSymbols(&library, shlibdeps.Object{
+		Soname:   "libhello.so.1",
+		Exported: []string{"hello@Base", "hello_new@Base", "hello_old@Base"},
+	}, current)
+
+	out, err := deb.FormatSymbols([]deb.SymbolsLibrary{library})
+	isok(t, err)
+	assert(t, string(out) == "libhello.so.1 libhello1 #MINVER#\n"+
+		" hello@Base 2.9\n"+
+		" (optional)hello_inline@Base 2.9\n"+
+		" hello_new@Base 2.12-1\n"+
+		" hello_old@Base 2.9\n"+
+		"#MISSING: 2.12-1# hello_world@Base 2.10\n")
+}
+
+func TestNewSymbolsLibrary(t *testing.T) {
+	current, err := version.Parse("1.0-1")
+	isok(t, err)
+	library := shlibdeps.NewSymbolsLibrary(shlibdeps.Object{
+		Soname:   "libhello.so.1",
+		Exported: []string{"hello@Base", "hello@HELLO_1"},
+	}, "libhello1", current)
+	out, err := deb.FormatSymbols([]deb.SymbolsLibrary{library})
+	isok(t, err)
+	assert(t, string(out) == "libhello.so.1 libhello1 #MINVER#\n hello@Base 1.0-1\n hello@HELLO_1 1.0-1\n")
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,8 @@
+/*
+
+This module unpacks Debian source packages, as dpkg-source -x does: the
+upstream tarballs, the debian tarball on top of them, and the quilt
+patches of the series applied to the result.
+
+*/
+package source // import "github.com/ebikt/go-debian/source"
@@ -0,0 +1,377 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package source // import "github.com/ebikt/go-debian/source"
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/ebikt/go-debian/control"
+	"github.com/ebikt/go-debian/deb"
+	"github.com/ebikt/go-debian/patch"
+)
+
+// Layout {{{
+
+// A Component is an orig-<component> tarball, unpacked in the directory of
+// the same name.
+type Component struct {
+	Name     string
+	Filename string
+}
+
+// Layout is how a source package is unpacked: its tarballs, in the order
+// they're unpacked, and the patches applied on top of them.
+type Layout struct {
+	Format string
+
+	// Path of the upstream tarball, or the only tarball of a
+	// "3.0 (native)" package.
+	Orig string
+
+	Components []Component
+
+	// Path of the debian tarball, of a "3.0 (quilt)" package.
+	Debian string
+
+	// Names of the patches of debian/patches/series, in the order they're
+	// applied.
+	Patches []string
+
+	series []patch.SeriesEntry
+}
+
+// componentName is what dpkg allows as the name of a component, which is
+// also the directory it's unpacked in.
+var componentName = regexp.MustCompile("^[A-Za-z0-9-]+$")
+
+// tarballs sorts the files of the .dsc into the tarballs of the Layout.
+func (l *Layout) tarballs(dsc *control.DSC) error {
+	for _, file := range dsc.AbsFiles() {
+		name := path.Base(file.Filename)
+		if !strings.HasPrefix(name, dsc.Source+"_") || strings.HasSuffix(name, ".asc") {
+			continue
+		}
+		name = name[len(dsc.Source)+1:]
+		switch {
+		case strings.Contains(name, ".orig.tar."):
+			l.Orig = file.Filename
+		case strings.Contains(name, ".orig-"):
+			component := name[strings.Index(name, ".orig-")+6:]
+			if i := strings.Index(component, ".tar."); i > 0 {
+				if !componentName.MatchString(component[:i]) {
+					return fmt.Errorf("Invalid component name '%s' in %s", component[:i], name)
+				}
+				l.Components = append(l.Components, Component{
+					Name:     component[:i],
+					Filename: file.Filename,
+				})
+			}
+		case strings.Contains(name, ".debian.tar."):
+			l.Debian = file.Filename
+		case strings.Contains(name, ".tar."):
+			l.Orig = file.Filename
+		}
+	}
+
+	switch l.Format {
+	case "3.0 (quilt)":
+		if l.Orig == "" || l.Debian == "" {
+			return fmt.Errorf("Missing the orig or the debian tarball of %s", dsc.Source)
+		}
+	case "3.0 (native)":
+		if l.Orig == "" || l.Debian != "" || len(l.Components) != 0 {
+			return fmt.Errorf("Expected a single tarball for %s", dsc.Source)
+		}
+	default:
+		return fmt.Errorf("Unsupported source format '%s'", l.Format)
+	}
+	return nil
+}
+
+// openTarball opens a tarball, decompressed according to its extension.
+func openTarball(filename string) (*tar.Reader, io.Closer, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	decompressed, err := deb.DecompressorFor(filepath.Ext(filename))(f)
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	return tar.NewReader(decompressed), f, nil
+}
+
+// readSeries reads the series from the debian tarball, without unpacking
+// it.
+func (l *Layout) readSeries() error {
+	data, closer, err := openTarball(l.Debian)
+	if err != nil {
+		return err
+	}
+	defer closer.Close()
+	for {
+		header, err := data.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if path.Clean(header.Name) == "debian/patches/series" {
+			return l.setSeries(data)
+		}
+	}
+}
+
+func (l *Layout) setSeries(reader io.Reader) error {
+	series, err := patch.ParseSeries(reader)
+	if err != nil {
+		return err
+	}
+	l.series = series
+	l.Patches = []string{}
+	for _, entry := range series {
+		l.Patches = append(l.Patches, entry.Name)
+	}
+	return nil
+}
+
+// }}}
+
+// Unpack {{{
+
+// UnpackOptions tell Unpack what to do.
+type UnpackOptions struct {
+	// Only work out the Layout, without writing anything.
+	DryRun bool
+
+	// Leave the patches of the series unapplied.
+	SkipPatches bool
+
+	// How the tarballs are extracted, the default ExtractPolicy if nil.
+	Policy *deb.ExtractPolicy
+}
+
+// Unpack unpacks the source package of the .dsc in dest, which must not
+// exist, as dpkg-source -x does: the upstream tarball, its components in
+// their directories, and the debian directory of the debian tarball
+// replacing the upstream one. The patches of debian/patches/series are
+// then applied, and recorded in .pc as quilt does. The top directory of
+// the tarballs is dropped. Only the "3.0 (quilt)" and "3.0 (native)"
+// formats are supported. The files of the .dsc are checked against their
+// sizes and digests before anything is read from them, see DSC.Validate.
+func Unpack(dsc *control.DSC, dest string, opts UnpackOptions) (*Layout, error) {
+	layout := Layout{Format: dsc.Format}
+	if err := layout.tarballs(dsc); err != nil {
+		return nil, err
+	}
+	if err := dsc.Validate(""); err != nil {
+		return nil, err
+	}
+	if opts.DryRun {
+		if layout.Debian != "" {
+			if err := layout.readSeries(); err != nil {
+				return nil, err
+			}
+		}
+		return &layout, nil
+	}
+
+	if _, err := os.Lstat(dest); err == nil {
+		return nil, fmt.Errorf("Refusing to unpack in '%s', which exists", dest)
+	}
+	if err := unpackTarball(layout.Orig, dest, opts.Policy); err != nil {
+		return nil, err
+	}
+	for _, component := range layout.Components {
+		dir := filepath.Join(dest, component.Name)
+		if err := os.RemoveAll(dir); err != nil {
+			return nil, err
+		}
+		if err := unpackTarball(component.Filename, dir, opts.Policy); err != nil {
+			return nil, err
+		}
+	}
+	if layout.Debian == "" {
+		return &layout, nil
+	}
+
+	if err := unpackDebian(layout.Debian, dest, opts.Policy); err != nil {
+		return nil, err
+	}
+	series, err := os.Open(filepath.Join(dest, "debian", "patches", "series"))
+	if os.IsNotExist(err) {
+		return &layout, nil
+	} else if err != nil {
+		return nil, err
+	}
+	err = layout.setSeries(series)
+	series.Close()
+	if err != nil {
+		return nil, err
+	}
+	if opts.SkipPatches {
+		return &layout, nil
+	}
+	if err := applySeries(dest, layout.series); err != nil {
+		return nil, err
+	}
+	return &layout, nil
+}
+
+// extractTemp extracts a tarball in a new temporary directory next to
+// dest.
+func extractTemp(filename, dest string, policy *deb.ExtractPolicy) (string, error) {
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return "", err
+	}
+	tmp, err := os.MkdirTemp(filepath.Dir(dest), ".unpack-")
+	if err != nil {
+		return "", err
+	}
+	data, closer, err := openTarball(filename)
+	if err != nil {
+		os.RemoveAll(tmp)
+		return "", err
+	}
+	defer closer.Close()
+	if err := deb.ExtractTar(data, tmp, policy); err != nil {
+		os.RemoveAll(tmp)
+		return "", fmt.Errorf("%s: %s", filepath.Base(filename), err)
+	}
+	return tmp, nil
+}
+
+// unpackTarball extracts a tarball as dest, dropping its top directory if
+// it has a single one.
+func unpackTarball(filename, dest string, policy *deb.ExtractPolicy) error {
+	tmp, err := extractTemp(filename, dest, policy)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	entries, err := os.ReadDir(tmp)
+	if err != nil {
+		return err
+	}
+	top := tmp
+	if len(entries) == 1 && entries[0].IsDir() {
+		top = filepath.Join(tmp, entries[0].Name())
+	}
+	if err := os.Chmod(top, 0755); err != nil {
+		return err
+	}
+	return os.Rename(top, dest)
+}
+
+// unpackDebian extracts the debian tarball, which has nothing but the
+// debian directory, replacing the one of dest.
+func unpackDebian(filename, dest string, policy *deb.ExtractPolicy) error {
+	tmp, err := extractTemp(filename, dest, policy)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	entries, err := os.ReadDir(tmp)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.Name() != "debian" || !entry.IsDir() {
+			return fmt.Errorf("%s: Unexpected '%s' outside of the debian directory",
+				filepath.Base(filename), entry.Name())
+		}
+	}
+	debian := filepath.Join(dest, "debian")
+	if err := os.RemoveAll(debian); err != nil {
+		return err
+	}
+	return os.Rename(filepath.Join(tmp, "debian"), debian)
+}
+
+// }}}
+
+// Patches {{{
+
+// applySeries applies the patches of debian/patches in order, keeping
+// what they change in .pc, as quilt does, so that they can be unapplied.
+func applySeries(dir string, series []patch.SeriesEntry) error {
+	if len(series) == 0 {
+		return nil
+	}
+	pc := filepath.Join(dir, ".pc")
+	if err := os.MkdirAll(pc, 0755); err != nil {
+		return err
+	}
+	for name, value := range map[string]string{
+		".version":       "2\n",
+		".quilt_patches": "debian/patches\n",
+		".quilt_series":  "series\n",
+	} {
+		if err := os.WriteFile(filepath.Join(pc, name), []byte(value), 0644); err != nil {
+			return err
+		}
+	}
+
+	applied := []string{}
+	for _, entry := range series {
+		if err := applyPatch(dir, entry); err != nil {
+			return fmt.Errorf("%s: %s", entry.Name, err)
+		}
+		applied = append(applied, entry.Name+"\n")
+		err := os.WriteFile(filepath.Join(pc, "applied-patches"), []byte(strings.Join(applied, "")), 0644)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyPatch applies a patch of debian/patches, saving the files it
+// changes under .pc/<patch>.
+func applyPatch(dir string, entry patch.SeriesEntry) error {
+	in, err := os.Open(filepath.Join(dir, "debian", "patches", filepath.FromSlash(entry.Name)))
+	if err != nil {
+		return err
+	}
+	files, err := patch.Parse(in)
+	in.Close()
+	if err != nil {
+		return err
+	}
+	_, err = patch.Apply(dir, files, patch.ApplyOptions{
+		Strip:     entry.Strip,
+		Reverse:   entry.Reverse,
+		BackupDir: filepath.Join(dir, ".pc", filepath.FromSlash(entry.Name)),
+	})
+	return err
+}
+
+// }}}
+
+// vim: foldmethod=marker
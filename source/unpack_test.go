@@ -0,0 +1,318 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package source_test
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/md5"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/ebikt/go-debian/control"
+	"github.com/ebikt/go-debian/source"
+)
+
+/*
+ *
+ */
+
+func writeTarball(t *testing.T, filename string, files map[string]string) {
+	f, err := os.Create(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	compressed := gzip.NewWriter(f)
+	w := tar.NewWriter(compressed)
+	names := []string{}
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := w.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(files[name]))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(files[name])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := compressed.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func makeDSC(t *testing.T, dir, format string, files ...string) *control.DSC {
+	dsc := control.DSC{
+		Filename: filepath.Join(dir, "hello_1.0-1.dsc"),
+		Format:   format,
+		Source:   "hello",
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(filepath.Join(dir, file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		size := int64(len(data))
+		dsc.Files = append(dsc.Files, control.MD5FileHash{FileHash: control.FileHash{
+			Algorithm: "md5", Hash: fmt.Sprintf("%x", md5.Sum(data)), Size: size, Filename: file,
+		}})
+		dsc.ChecksumsSha256 = append(dsc.ChecksumsSha256, control.SHA256FileHash{FileHash: control.FileHash{
+			Algorithm: "sha256", Hash: fmt.Sprintf("%x", sha256.Sum256(data)), Size: size, Filename: file,
+		}})
+	}
+	return &dsc
+}
+
+const fixPatch = `Description: Greet properly
+Author: Jane Doe <jane@example.org>
+
+--- a/hello.c
++++ b/hello.c
+@@ -1,3 +1,3 @@
+ #include <stdio.h>
+-int main() { puts("Hullo"); }
++int main() { puts("Hello"); }
+ /* EOF */
+`
+
+const addPatch = `--- /dev/null
++++ b/NEWS
+@@ -0,0 +1 @@
++Now greeting properly
+`
+
+func quiltSource(t *testing.T) (string, *control.DSC) {
+	dir := t.TempDir()
+	writeTarball(t, filepath.Join(dir, "hello_1.0.orig.tar.gz"), map[string]string{
+		"hello-1.0/hello.c":         "#include <stdio.h>\nint main() { puts(\"Hullo\"); }\n/* EOF */\n",
+		"hello-1.0/debian/rules":    "upstream packaging\n",
+		"hello-1.0/debian/upstream": "upstream packaging\n",
+	})
+	writeTarball(t, filepath.Join(dir, "hello_1.0.orig-docs.tar.gz"), map[string]string{
+		"docs/README": "Say hello\n",
+	})
+	writeTarball(t, filepath.Join(dir, "hello_1.0-1.debian.tar.gz"), map[string]string{
+		"debian/rules":             "#!/usr/bin/make -f\n",
+		"debian/patches/series":    "# Fixes\nfix.patch\nadd.patch -p1 # New files\n",
+		"debian/patches/fix.patch": fixPatch,
+		"debian/patches/add.patch": addPatch,
+	})
+	return dir, makeDSC(t, dir, "3.0 (quilt)",
+		"hello_1.0.orig.tar.gz", "hello_1.0.orig-docs.tar.gz", "hello_1.0-1.debian.tar.gz")
+}
+
+func readFile(t *testing.T, name string) string {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestUnpackQuilt(t *testing.T) {
+	dir, dsc := quiltSource(t)
+	dest := filepath.Join(dir, "hello-1.0")
+
+	layout, err := source.Unpack(dsc, dest, source.UnpackOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if layout.Orig != filepath.Join(dir, "hello_1.0.orig.tar.gz") ||
+		layout.Debian != filepath.Join(dir, "hello_1.0-1.debian.tar.gz") ||
+		len(layout.Components) != 1 || layout.Components[0].Name != "docs" {
+		t.Fatalf("Unexpected layout: %v", layout)
+	}
+	if strings.Join(layout.Patches, " ") != "fix.patch add.patch" {
+		t.Fatalf("Unexpected patches: %v", layout.Patches)
+	}
+
+	for name, expected := range map[string]string{
+		"hello.c":               "#include <stdio.h>\nint main() { puts(\"Hello\"); }\n/* EOF */\n",
+		"NEWS":                  "Now greeting properly\n",
+		"docs/README":           "Say hello\n",
+		"debian/rules":          "#!/usr/bin/make -f\n",
+		".pc/applied-patches":   "fix.patch\nadd.patch\n",
+		".pc/fix.patch/hello.c": "#include <stdio.h>\nint main() { puts(\"Hullo\"); }\n/* EOF */\n",
+		".pc/add.patch/NEWS":    "",
+	} {
+		if actual := readFile(t, filepath.Join(dest, name)); actual != expected {
+			t.Fatalf("Unexpected %s: %q", name, actual)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dest, "debian", "upstream")); !os.IsNotExist(err) {
+		t.Fatalf("The upstream debian directory wasn't replaced: %v", err)
+	}
+
+	if _, err := source.Unpack(dsc, dest, source.UnpackOptions{}); err == nil {
+		t.Fatal("Unpacked over an existing directory")
+	}
+}
+
+func TestUnpackDryRun(t *testing.T) {
+	dir, dsc := quiltSource(t)
+	dest := filepath.Join(dir, "hello-1.0")
+	layout, err := source.Unpack(dsc, dest, source.UnpackOptions{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(layout.Patches, " ") != "fix.patch add.patch" {
+		t.Fatalf("Unexpected patches: %v", layout.Patches)
+	}
+	if _, err := os.Stat(dest); !os.IsNotExist(err) {
+		t.Fatalf("Unpacked on a dry run: %v", err)
+	}
+}
+
+func TestUnpackTampered(t *testing.T) {
+	dir, dsc := quiltSource(t)
+	dest := filepath.Join(dir, "hello-1.0")
+	writeTarball(t, filepath.Join(dir, "hello_1.0-1.debian.tar.gz"), map[string]string{
+		"debian/rules": "#!/usr/bin/make -f\n",
+		"debian/evil":  "Not in the .dsc\n",
+	})
+	for _, opts := range []source.UnpackOptions{{}, {DryRun: true}} {
+		if _, err := source.Unpack(dsc, dest, opts); err == nil {
+			t.Fatal("Unpacked a tampered tarball")
+		}
+	}
+	if _, err := os.Lstat(dest); !os.IsNotExist(err) {
+		t.Fatalf("Unpacked a tampered tarball: %v", err)
+	}
+
+	/* A tarball of the listed size, but with another digest, is refused too */
+	_, dsc = quiltSource(t)
+	dsc.ChecksumsSha256[0].Hash = strings.Repeat("0", 64)
+	if _, err := source.Unpack(dsc, filepath.Join(t.TempDir(), "hello-1.0"), source.UnpackOptions{}); err == nil {
+		t.Fatal("Unpacked a tarball not matching its digest")
+	}
+}
+
+func TestUnpackSkipPatches(t *testing.T) {
+	dir, dsc := quiltSource(t)
+	dest := filepath.Join(dir, "hello-1.0")
+	if _, err := source.Unpack(dsc, dest, source.UnpackOptions{SkipPatches: true}); err != nil {
+		t.Fatal(err)
+	}
+	if actual := readFile(t, filepath.Join(dest, "hello.c")); !strings.Contains(actual, "Hullo") {
+		t.Fatalf("Patched: %q", actual)
+	}
+	if _, err := os.Stat(filepath.Join(dest, ".pc")); !os.IsNotExist(err) {
+		t.Fatalf("Unexpected .pc: %v", err)
+	}
+}
+
+func TestUnpackNative(t *testing.T) {
+	dir := t.TempDir()
+	writeTarball(t, filepath.Join(dir, "hello_1.0.tar.gz"), map[string]string{
+		"hello-1.0/hello.c":      "int main() {}\n",
+		"hello-1.0/debian/rules": "#!/usr/bin/make -f\n",
+	})
+	dest := filepath.Join(dir, "hello-1.0")
+	dsc := makeDSC(t, dir, "3.0 (native)", "hello_1.0.tar.gz")
+	if _, err := source.Unpack(dsc, dest, source.UnpackOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if readFile(t, filepath.Join(dest, "debian", "rules")) != "#!/usr/bin/make -f\n" {
+		t.Fatal("Unexpected debian/rules")
+	}
+
+	dsc.Format = "1.0"
+	if _, err := source.Unpack(dsc, filepath.Join(dir, "other"), source.UnpackOptions{}); err == nil {
+		t.Fatal("Unpacked an unsupported format")
+	}
+}
+
+func TestUnpackBadPatch(t *testing.T) {
+	dir := t.TempDir()
+	writeTarball(t, filepath.Join(dir, "hello_1.0.orig.tar.gz"), map[string]string{
+		"hello-1.0/hello.c": "int main() {}\n",
+	})
+	writeTarball(t, filepath.Join(dir, "hello_1.0-1.debian.tar.gz"), map[string]string{
+		"debian/patches/series":    "fix.patch\n",
+		"debian/patches/fix.patch": fixPatch,
+	})
+	dsc := makeDSC(t, dir, "3.0 (quilt)", "hello_1.0.orig.tar.gz", "hello_1.0-1.debian.tar.gz")
+	_, err := source.Unpack(dsc, filepath.Join(dir, "hello-1.0"), source.UnpackOptions{})
+	if err == nil || err.Error() != "fix.patch: hello.c: Hunk #1 doesn't apply" {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
+
+func TestUnpackBadComponent(t *testing.T) {
+	for _, name := range []string{"..", "a.b", "a_b"} {
+		dir := t.TempDir()
+		sub := filepath.Join(dir, "sub")
+		if err := os.Mkdir(sub, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "unrelated"), []byte("keep me\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		writeTarball(t, filepath.Join(sub, "hello_1.0.orig.tar.gz"), map[string]string{
+			"hello-1.0/hello.c": "int main() {}\n",
+		})
+		writeTarball(t, filepath.Join(sub, "hello_1.0.orig-"+name+".tar.gz"), map[string]string{
+			"evil/README": "Gone\n",
+		})
+		writeTarball(t, filepath.Join(sub, "hello_1.0-1.debian.tar.gz"), map[string]string{
+			"debian/rules": "#!/usr/bin/make -f\n",
+		})
+		dsc := makeDSC(t, sub, "3.0 (quilt)",
+			"hello_1.0.orig.tar.gz", "hello_1.0.orig-"+name+".tar.gz", "hello_1.0-1.debian.tar.gz")
+		_, err := source.Unpack(dsc, filepath.Join(sub, "hello-1.0"), source.UnpackOptions{})
+		if err == nil || !strings.Contains(err.Error(), "Invalid component name") {
+			t.Fatalf("Unexpected error for '%s': %v", name, err)
+		}
+		if readFile(t, filepath.Join(dir, "unrelated")) != "keep me\n" {
+			t.Fatalf("Unpacking '%s' removed unrelated files", name)
+		}
+	}
+}
+
+func TestUnpackEscapingPatch(t *testing.T) {
+	dir := t.TempDir()
+	sub := filepath.Join(dir, "sub")
+	writeTarball(t, filepath.Join(dir, "hello_1.0.orig.tar.gz"), map[string]string{
+		"hello-1.0/hello.txt": "Hello\n",
+	})
+	writeTarball(t, filepath.Join(dir, "hello_1.0-1.debian.tar.gz"), map[string]string{
+		"debian/patches/series": "../../sub/evil.patch\n",
+	})
+	dsc := makeDSC(t, dir, "3.0 (quilt)", "hello_1.0.orig.tar.gz", "hello_1.0-1.debian.tar.gz")
+	if _, err := source.Unpack(dsc, filepath.Join(dir, "hello-1.0"), source.UnpackOptions{}); err == nil {
+		t.Fatal("Unpacked a series out of debian/patches")
+	}
+	if _, err := os.Lstat(sub); !os.IsNotExist(err) {
+		t.Fatalf("Wrote out of the unpacked tree: %v", err)
+	}
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,275 @@
+//go:build !pureparser
+// +build !pureparser
+
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package testutil // import "github.com/ebikt/go-debian/testutil"
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/md5"
+	"crypto/sha256"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ebikt/go-debian/archive"
+	"github.com/ebikt/go-debian/control"
+	"github.com/ebikt/go-debian/deb"
+)
+
+// Packages {{{
+
+// Deb describes a binary package, from which a minimal but valid .deb is
+// built, rather than checking one in:
+//
+//   testutil.Deb{
+//       Package: "hello",
+//       Version: "2.10-3",
+//       Depends: "libc6 (>= 2.34)",
+//       Files:   map[string]string{"usr/bin/hello": "#!/bin/sh\n"},
+//   }
+type Deb struct {
+	Package string
+	Version string
+
+	// Architecture of the package; "all" if empty.
+	Architecture string
+
+	// Source package, when it's not named after the package.
+	Source string
+
+	Depends string
+
+	// Description of the package; a synopsis made of its name if empty.
+	Description string
+
+	// Other fields of the control file, such as "Multi-Arch".
+	Fields map[string]string
+
+	// Content of the files the package installs, by their path, such as
+	// "usr/bin/hello". Files under a bin/ directory are executable.
+	Files map[string]string
+}
+
+// arch returns the Architecture of the Deb.
+func (d Deb) arch() string {
+	if d.Architecture == "" {
+		return "all"
+	}
+	return d.Architecture
+}
+
+// Filename returns the name of the .deb, such as "hello_2.10-3_all.deb",
+// or of the .udeb if its Package-Type is "udeb".
+func (d Deb) Filename() string {
+	ver := d.Version
+	if i := strings.Index(ver, ":"); i >= 0 {
+		ver = ver[i+1:]
+	}
+	ext := ".deb"
+	if d.Fields["Package-Type"] == "udeb" {
+		ext = ".udeb"
+	}
+	return d.Package + "_" + ver + "_" + d.arch() + ext
+}
+
+// Control returns the control file of the package.
+func (d Deb) Control() control.Paragraph {
+	installed := 0
+	for _, data := range d.Files {
+		installed += (len(data) + 1023) / 1024
+	}
+	description := d.Description
+	if description == "" {
+		description = d.Package + " test package"
+	}
+
+	para := control.NewParagraph()
+	for _, field := range []struct{ key, value string }{
+		{"Package", d.Package},
+		{"Source", d.Source},
+		{"Version", d.Version},
+		{"Architecture", d.arch()},
+		{"Maintainer", "Test Maintainer <test@example.com>"},
+		{"Installed-Size", fmt.Sprint(installed)},
+		{"Depends", d.Depends},
+	} {
+		if field.value != "" {
+			para.Set(field.key, field.value)
+		}
+	}
+	keys := []string{}
+	for key := range d.Fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		para.Set(key, d.Fields[key])
+	}
+	para.Set("Description", description)
+	return para
+}
+
+// tarball creates a gzipped tarball of the files, with the directories
+// leading to them, as dpkg-deb does.
+func tarball(files map[string]string) ([]byte, error) {
+	names := []string{}
+	dirs := map[string]bool{}
+	for name := range files {
+		names = append(names, name)
+		parts := strings.Split(name, "/")
+		for i := 1; i < len(parts); i++ {
+			dirs[strings.Join(parts[:i], "/")] = true
+		}
+	}
+	for dir := range dirs {
+		names = append(names, dir+"/")
+	}
+	sort.Strings(names)
+
+	out := bytes.Buffer{}
+	compressed := gzip.NewWriter(&out)
+	w := tar.NewWriter(compressed)
+	for _, name := range names {
+		header := tar.Header{Name: "./" + name, Mode: 0644, ModTime: time.Unix(0, 0), Uname: "root", Gname: "root"}
+		data := files[name]
+		switch {
+		case strings.HasSuffix(name, "/"):
+			header.Typeflag, header.Mode = tar.TypeDir, 0755
+		case strings.Contains("/"+name, "/bin/"):
+			header.Mode = 0755
+		}
+		header.Size = int64(len(data))
+		if err := w.WriteHeader(&header); err != nil {
+			return nil, err
+		}
+		if _, err := w.Write([]byte(data)); err != nil {
+			return nil, err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	if err := compressed.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// Build builds the .deb, which deb.Load reads back.
+func (d Deb) Build() ([]byte, error) {
+	controlFile := bytes.Buffer{}
+	para := d.Control()
+	if err := para.WriteTo(&controlFile); err != nil {
+		return nil, err
+	}
+	controlTar, err := tarball(map[string]string{"control": controlFile.String()})
+	if err != nil {
+		return nil, err
+	}
+	dataTar, err := tarball(d.Files)
+	if err != nil {
+		return nil, err
+	}
+
+	out := bytes.Buffer{}
+	w := deb.NewArWriter(&out)
+	for _, member := range []struct {
+		name string
+		data []byte
+	}{
+		{"debian-binary", []byte("2.0\n")},
+		{"control.tar.gz", controlTar},
+		{"data.tar.gz", dataTar},
+	} {
+		if err := w.WriteEntry(member.name, int64(len(member.data)), bytes.NewReader(member.data)); err != nil {
+			return nil, err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// PoolPath returns where the .deb goes in the pool of the component, such
+// as "pool/main/h/hello/hello_2.10-3_all.deb".
+func (d Deb) PoolPath(component string) string {
+	source := d.Source
+	if i := strings.Index(source, " "); i >= 0 {
+		source = source[:i]
+	}
+	if source == "" {
+		source = d.Package
+	}
+	return archive.PoolDirectory(component, source) + "/" + d.Filename()
+}
+
+// PackagesIndex builds the .debs, and returns the Packages index listing
+// them, along with their content by their path in the pool of the
+// component, all in memory.
+func PackagesIndex(component string, debs ...Deb) ([]byte, map[string][]byte, error) {
+	index := bytes.Buffer{}
+	pool := map[string][]byte{}
+	for _, d := range debs {
+		data, err := d.Build()
+		if err != nil {
+			return nil, nil, err
+		}
+		filename := d.PoolPath(component)
+		pool[filename] = data
+
+		para := d.Control()
+		para.Set("Filename", filename)
+		para.Set("Size", fmt.Sprint(len(data)))
+		para.Set("MD5sum", fmt.Sprintf("%x", md5.Sum(data)))
+		para.Set("SHA256", fmt.Sprintf("%x", sha256.Sum256(data)))
+		if index.Len() > 0 {
+			index.WriteString("\n")
+		}
+		if err := para.WriteTo(&index); err != nil {
+			return nil, nil, err
+		}
+	}
+	return index.Bytes(), pool, nil
+}
+
+// AddDebs builds the .debs, adds them to the pool of the component, and
+// returns the Packages index listing them, to be put in the Indexes of a
+// Suite.
+func (r *Repository) AddDebs(component string, debs ...Deb) ([]byte, error) {
+	index, pool, err := PackagesIndex(component, debs...)
+	if err != nil {
+		return nil, err
+	}
+	for filename, data := range pool {
+		r.AddFile(filename, data)
+	}
+	return index, nil
+}
+
+// }}}
+
+// vim: foldmethod=marker
@@ -0,0 +1,82 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package testutil // import "github.com/ebikt/go-debian/testutil"
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+// Golden files {{{
+
+// UpdateGolden is the environment variable which, when set to a non
+// empty value, makes Golden write the golden files rather than compare
+// with them, as in "UPDATE_GOLDEN=1 go test ./...".
+const UpdateGolden = "UPDATE_GOLDEN"
+
+// WriteFiles writes the files, by their path relative to dir, such as the
+// ones of Suite.Files and PackagesIndex, creating the directories leading
+// to them.
+func WriteFiles(dir string, files map[string][]byte) error {
+	names := []string{}
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		dest := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(dest, files[name], 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Golden compares the output of a test with the golden file at `path`,
+// usually under testdata/, failing the test if they differ. When the
+// UpdateGolden environment variable is set, the golden file is written
+// instead, to be reviewed and checked in.
+func Golden(t testing.TB, path string, got []byte) {
+	t.Helper()
+	if os.Getenv(UpdateGolden) != "" {
+		if err := WriteFiles(filepath.Dir(path), map[string][]byte{filepath.Base(path): got}); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Can't read the golden file (%s=1 writes it): %s", UpdateGolden, err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("Output differs from %s (%s=1 updates it):\n%s", path, UpdateGolden, got)
+	}
+}
+
+// }}}
+
+// vim: foldmethod=marker
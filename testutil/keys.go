@@ -0,0 +1,106 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+// Package testutil helps testing code using archives, without network
+// access: it generates throwaway OpenPGP keys, signs Release files with
+// them, builds .debs and the indexes listing them, and serves repositories
+// over httptest. Rather than checking binary fixtures in, tests generate
+// them in memory, or write them out as golden files.
+package testutil // import "github.com/ebikt/go-debian/testutil"
+
+import (
+	"bytes"
+	"io/ioutil"
+
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+	"golang.org/x/crypto/openpgp/packet"
+
+	"github.com/ebikt/go-debian/control"
+)
+
+// Keys {{{
+
+// NewKey generates a throwaway OpenPGP key, with a user id made of the
+// name and the email. The key is small, to be quick to generate, and
+// only suitable for tests.
+func NewKey(name, email string) (*openpgp.Entity, error) {
+	entity, err := openpgp.NewEntity(name, "test key", email, &packet.Config{RSABits: 1024})
+	if err != nil {
+		return nil, err
+	}
+	/* Serializing the private key signs the identities and subkeys, which
+	 * the public key needs to be usable. */
+	if err := entity.SerializePrivate(ioutil.Discard, nil); err != nil {
+		return nil, err
+	}
+	return entity, nil
+}
+
+// Keyring returns a keyring of the given keys, such as the one apt would
+// be given with signed-by.
+func Keyring(entities ...*openpgp.Entity) *openpgp.EntityList {
+	ret := openpgp.EntityList(entities)
+	return &ret
+}
+
+// ArmoredPublicKey exports the public part of a key, ASCII armored, as
+// found in /etc/apt/keyrings/*.asc.
+func ArmoredPublicKey(entity *openpgp.Entity) ([]byte, error) {
+	out := bytes.Buffer{}
+	writer, err := armor.Encode(&out, openpgp.PublicKeyType, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := entity.Serialize(writer); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// }}}
+
+// Signing {{{
+
+// ClearSign signs the data inline, as an InRelease file.
+func ClearSign(entity *openpgp.Entity, data []byte) ([]byte, error) {
+	out := bytes.Buffer{}
+	if err := control.ClearSign(&out, bytes.NewReader(data), entity, nil); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// DetachSign signs the data with an ASCII armored detached signature, as a
+// Release.gpg file.
+func DetachSign(entity *openpgp.Entity, data []byte) ([]byte, error) {
+	out := bytes.Buffer{}
+	if err := control.DetachSign(&out, bytes.NewReader(data), entity, nil); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// }}}
+
+// vim: foldmethod=marker
@@ -0,0 +1,223 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package testutil // import "github.com/ebikt/go-debian/testutil"
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/openpgp"
+
+	"github.com/ebikt/go-debian/control"
+)
+
+// Repository {{{
+
+// A Suite of a test Repository, written out as dists/<Suite>/.
+type Suite struct {
+	Origin        string
+	Label         string
+	Suite         string
+	Codename      string
+	Architectures []string
+	Components    []string
+
+	// Date of the Release file, now if zero, and its Valid-Until, which
+	// is left out if zero.
+	Date       time.Time
+	ValidUntil time.Time
+
+	// If set, the Release file has "Acquire-By-Hash: yes", and the indexes
+	// are also written under by-hash/SHA256/.
+	AcquireByHash bool
+
+	// Index files, by their path relative to the suite directory, such as
+	// "main/binary-amd64/Packages".
+	Indexes map[string][]byte
+}
+
+// Release renders the Release file of the Suite, listing its Indexes.
+func (s Suite) Release() []byte {
+	date := s.Date
+	if date.IsZero() {
+		date = time.Now()
+	}
+
+	para := control.NewParagraph()
+	for _, field := range []struct{ key, value string }{
+		{"Origin", s.Origin},
+		{"Label", s.Label},
+		{"Suite", s.Suite},
+		{"Codename", s.Codename},
+		{"Date", date.UTC().Format(time.RFC1123)},
+	} {
+		if field.value != "" {
+			para.Set(field.key, field.value)
+		}
+	}
+	if !s.ValidUntil.IsZero() {
+		para.Set("Valid-Until", s.ValidUntil.UTC().Format(time.RFC1123))
+	}
+	if len(s.Architectures) > 0 {
+		para.Set("Architectures", strings.Join(s.Architectures, " "))
+	}
+	if len(s.Components) > 0 {
+		para.Set("Components", strings.Join(s.Components, " "))
+	}
+	if s.AcquireByHash {
+		para.Set("Acquire-By-Hash", "yes")
+	}
+
+	names := []string{}
+	for name := range s.Indexes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	md5sums, sha256sums := "", ""
+	for _, name := range names {
+		data := s.Indexes[name]
+		md5sums += fmt.Sprintf("%x %d %s\n", md5.Sum(data), len(data), name)
+		sha256sums += fmt.Sprintf("%x %d %s\n", sha256.Sum256(data), len(data), name)
+	}
+	if len(names) > 0 {
+		para.Set("MD5Sum", md5sums)
+		para.Set("SHA256", sha256sums)
+	}
+
+	out := bytes.Buffer{}
+	para.WriteTo(&out)
+	return out.Bytes()
+}
+
+// Repository is an archive served over HTTP by an httptest.Server, with
+// Release files signed by a throwaway key.
+type Repository struct {
+	// Key signing the InRelease and Release.gpg files; they are left out
+	// if nil.
+	Signer *openpgp.Entity
+
+	lock   sync.Mutex
+	files  map[string][]byte
+	server *httptest.Server
+}
+
+// NewRepository creates an empty Repository, which is served once Start
+// is called.
+func NewRepository(signer *openpgp.Entity) *Repository {
+	return &Repository{Signer: signer, files: map[string][]byte{}}
+}
+
+// AddFile adds a file to the Repository, such as a .deb in the pool, at
+// the given path relative to its root.
+func (r *Repository) AddFile(name string, data []byte) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.files[path.Clean("/"+name)] = data
+}
+
+// RemoveFile removes a file from the Repository.
+func (r *Repository) RemoveFile(name string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	delete(r.files, path.Clean("/"+name))
+}
+
+// Files returns the files of the Suite, by their path relative to the
+// root of the repository: its indexes, its Release file, and the InRelease
+// and Release.gpg files signed by the signer, if not nil.
+func (s Suite) Files(signer *openpgp.Entity) (map[string][]byte, error) {
+	dir := "dists/" + s.Suite
+	files := map[string][]byte{}
+	for name, data := range s.Indexes {
+		files[dir+"/"+name] = data
+		if s.AcquireByHash {
+			files[fmt.Sprintf("%s/%s/by-hash/SHA256/%x", dir, path.Dir(name), sha256.Sum256(data))] = data
+		}
+	}
+	release := s.Release()
+	files[dir+"/Release"] = release
+	if signer == nil {
+		return files, nil
+	}
+	inRelease, err := ClearSign(signer, release)
+	if err != nil {
+		return nil, err
+	}
+	files[dir+"/InRelease"] = inRelease
+	signature, err := DetachSign(signer, release)
+	if err != nil {
+		return nil, err
+	}
+	files[dir+"/Release.gpg"] = signature
+	return files, nil
+}
+
+// AddSuite writes the indexes of the Suite, its Release file, and the
+// InRelease and Release.gpg files when there's a Signer.
+func (r *Repository) AddSuite(suite Suite) error {
+	files, err := suite.Files(r.Signer)
+	if err != nil {
+		return err
+	}
+	for name, data := range files {
+		r.AddFile(name, data)
+	}
+	return nil
+}
+
+// ServeHTTP serves the files of the Repository, and 404 for anything
+// else.
+func (r *Repository) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.lock.Lock()
+	data, ok := r.files[path.Clean(req.URL.Path)]
+	r.lock.Unlock()
+	if !ok {
+		http.NotFound(w, req)
+		return
+	}
+	http.ServeContent(w, req, path.Base(req.URL.Path), time.Time{}, bytes.NewReader(data))
+}
+
+// Start serves the Repository, returning its URL.
+func (r *Repository) Start() string {
+	r.server = httptest.NewServer(r)
+	return r.server.URL
+}
+
+// Close stops serving the Repository.
+func (r *Repository) Close() {
+	if r.server != nil {
+		r.server.Close()
+	}
+}
+
+// }}}
+
+// vim: foldmethod=marker
@@ -0,0 +1,241 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package testutil_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/openpgp"
+
+	"github.com/ebikt/go-debian/archive"
+	"github.com/ebikt/go-debian/deb"
+	"github.com/ebikt/go-debian/testutil"
+)
+
+/*
+ *
+ */
+
+func isok(t *testing.T, err error) {
+	if err != nil {
+		log.Printf("Error! Error is not nil! %s\n", err)
+		t.FailNow()
+	}
+}
+
+func notok(t *testing.T, err error) {
+	if err == nil {
+		log.Printf("Error! Error is nil!\n")
+		t.FailNow()
+	}
+}
+
+func assert(t *testing.T, expr bool) {
+	if !expr {
+		log.Printf("Assertion failed!")
+		t.FailNow()
+	}
+}
+
+/*
+ *
+ */
+
+func TestRepository(t *testing.T) {
+	key, err := testutil.NewKey("Test Archive", "archive@example.org")
+	isok(t, err)
+	other, err := testutil.NewKey("Someone Else", "else@example.org")
+	isok(t, err)
+
+	repo := testutil.NewRepository(key)
+	isok(t, repo.AddSuite(testutil.Suite{
+		Origin:        "Example",
+		Suite:         "stable",
+		Codename:      "bookworm",
+		Architectures: []string{"amd64"},
+		Components:    []string{"main"},
+		Indexes: map[string][]byte{
+			"main/binary-amd64/Packages": []byte("Package: hello\nVersion: 2.10-3\n"),
+		},
+	}))
+	repo.AddFile("pool/main/h/hello/hello_2.10-3_amd64.deb", []byte("!<arch>\n"))
+	url := repo.Start()
+	defer repo.Close()
+
+	resp, err := http.Get(url + "/pool/main/h/hello/hello_2.10-3_amd64.deb")
+	isok(t, err)
+	data, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	isok(t, err)
+	assert(t, string(data) == "!<arch>\n")
+	resp, err = http.Get(url + "/dists/unstable/Release")
+	isok(t, err)
+	resp.Body.Close()
+	assert(t, resp.StatusCode == http.StatusNotFound)
+
+	checker := archive.MirrorChecker{Suite: "stable", Keyring: testutil.Keyring(key)}
+	statuses := checker.Check(context.Background(), []string{url})
+	isok(t, statuses[0].Err)
+	assert(t, statuses[0].Release.Codename == "bookworm")
+	checksums := statuses[0].Release.Checksums()
+	assert(t, len(checksums) == 1)
+	assert(t, checksums[0].Filename == "main/binary-amd64/Packages")
+
+	checker.Keyring = testutil.Keyring(other)
+	statuses = checker.Check(context.Background(), []string{url})
+	notok(t, statuses[0].Err)
+
+	/* The detached signature, checked with the exported public key */
+	armored, err := testutil.ArmoredPublicKey(key)
+	isok(t, err)
+	keyring, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(armored))
+	isok(t, err)
+	release := fetch(t, url+"/dists/stable/Release")
+	signature := fetch(t, url+"/dists/stable/Release.gpg")
+	signer, err := openpgp.CheckArmoredDetachedSignature(keyring, bytes.NewReader(release), bytes.NewReader(signature))
+	isok(t, err)
+	assert(t, signer.PrimaryKey.KeyId == key.PrimaryKey.KeyId)
+	assert(t, strings.Contains(string(release), "Origin: Example\n"))
+}
+
+func TestDebs(t *testing.T) {
+	hello := testutil.Deb{
+		Package:      "hello",
+		Version:      "1:2.10-3",
+		Architecture: "amd64",
+		Depends:      "libc6 (>= 2.34)",
+		Fields:       map[string]string{"Section": "devel"},
+		Files:        map[string]string{"usr/bin/hello": "#!/bin/sh\necho hello\n"},
+	}
+	assert(t, hello.Filename() == "hello_2.10-3_amd64.deb")
+	assert(t, hello.PoolPath("main") == "pool/main/h/hello/hello_2.10-3_amd64.deb")
+
+	data, err := hello.Build()
+	isok(t, err)
+	debFile, err := deb.Load(bytes.NewReader(data), hello.Filename())
+	isok(t, err)
+	assert(t, debFile.Control.Package == "hello")
+	assert(t, debFile.Control.Version.Epoch == 1)
+	assert(t, debFile.Control.Section == "devel")
+	header, err := debFile.Data.Next()
+	isok(t, err)
+	assert(t, header.Name == "./usr/")
+	for header.Typeflag == tar.TypeDir {
+		header, err = debFile.Data.Next()
+		isok(t, err)
+	}
+	assert(t, header.Name == "./usr/bin/hello")
+	assert(t, header.Mode == 0755)
+
+	key, err := testutil.NewKey("Test Archive", "archive@example.org")
+	isok(t, err)
+	repo := testutil.NewRepository(key)
+	packages, err := repo.AddDebs("main", hello, testutil.Deb{
+		Package: "libhello1",
+		Source:  "libhello",
+		Version: "1.0-1",
+	})
+	isok(t, err)
+	isok(t, repo.AddSuite(testutil.Suite{
+		Suite:         "stable",
+		Architectures: []string{"amd64"},
+		Components:    []string{"main"},
+		Indexes:       map[string][]byte{"main/binary-amd64/Packages": packages},
+	}))
+	url := repo.Start()
+	defer repo.Close()
+
+	client := archive.NewClient(archive.SourceEntry{URI: url, Suite: "stable"}, testutil.Keyring(key))
+	_, err = client.Update(context.Background())
+	isok(t, err)
+	index, err := client.Packages(context.Background(), "main", "amd64")
+	isok(t, err)
+	entries := []string{}
+	for {
+		entry, err := index.Next()
+		if err == io.EOF {
+			break
+		}
+		isok(t, err)
+		entries = append(entries, entry.Filename)
+		assert(t, bytes.Equal(fetch(t, url+"/"+entry.Filename)[:8], []byte("!<arch>\n")))
+	}
+	assert(t, len(entries) == 2)
+	assert(t, entries[1] == "pool/main/libh/libhello/libhello1_1.0-1_all.deb")
+}
+
+func TestFixtures(t *testing.T) {
+	packages, pool, err := testutil.PackagesIndex("main", testutil.Deb{
+		Package:      "hello",
+		Version:      "2.10-3",
+		Architecture: "amd64",
+		Files:        map[string]string{"usr/bin/hello": "#!/bin/sh\necho hello\n"},
+	})
+	isok(t, err)
+	testutil.Golden(t, "testdata/Packages", packages)
+	assert(t, len(pool["pool/main/h/hello/hello_2.10-3_amd64.deb"]) > 0)
+
+	key, err := testutil.NewKey("Test Archive", "archive@example.org")
+	isok(t, err)
+	files, err := testutil.Suite{
+		Suite:         "stable",
+		Architectures: []string{"amd64"},
+		Components:    []string{"main"},
+		Indexes:       map[string][]byte{"main/binary-amd64/Packages": packages},
+	}.Files(key)
+	isok(t, err)
+	assert(t, len(files) == 4)
+	for name, data := range pool {
+		files[name] = data
+	}
+
+	/* Served from the filesystem, rather than over HTTP */
+	dir := t.TempDir()
+	isok(t, testutil.WriteFiles(dir, files))
+	client := archive.NewClient(archive.SourceEntry{URI: "file://" + dir, Suite: "stable"}, testutil.Keyring(key))
+	_, err = client.Update(context.Background())
+	isok(t, err)
+	index, err := client.Packages(context.Background(), "main", "amd64")
+	isok(t, err)
+	entry, err := index.Next()
+	isok(t, err)
+	assert(t, entry.Filename == "pool/main/h/hello/hello_2.10-3_amd64.deb")
+}
+
+func fetch(t *testing.T, url string) []byte {
+	resp, err := http.Get(url)
+	isok(t, err)
+	defer resp.Body.Close()
+	assert(t, resp.StatusCode == http.StatusOK)
+	data, err := ioutil.ReadAll(resp.Body)
+	isok(t, err)
+	return data
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,147 @@
+/* {{{ Copyright © 2012 Michael Stapelberg and contributors
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ *     * Redistributions of source code must retain the above copyright
+ *       notice, this list of conditions and the following disclaimer.
+ *
+ *     * Redistributions in binary form must reproduce the above copyright
+ *       notice, this list of conditions and the following disclaimer in the
+ *       documentation and/or other materials provided with the distribution.
+ *
+ *     * Neither the name of Michael Stapelberg nor the
+ *       names of contributors may be used to endorse or promote products
+ *       derived from this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY Michael Stapelberg ''AS IS'' AND ANY
+ * EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+ * WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL Michael Stapelberg BE LIABLE FOR ANY
+ * DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+ * (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+ * LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ * ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+ * (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE. }}} */
+
+package version // import "github.com/ebikt/go-debian/version"
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Cache {{{
+
+// A Cache remembers the versions it parsed, for the programs parsing the
+// same version strings over and over, such as resolvers going through the
+// indexes of a whole archive. It holds up to twice its size of versions,
+// dropping the ones least recently used past that. A Cache may be used
+// from several goroutines at once.
+type Cache struct {
+	size int
+
+	mutex    sync.Mutex
+	current  map[string]cachedVersion
+	previous map[string]cachedVersion
+	hits     uint64
+	misses   uint64
+}
+
+type cachedVersion struct {
+	version Version
+	err     error
+}
+
+// NewCache creates a Cache of the given size, which is at least 1.
+func NewCache(size int) *Cache {
+	if size < 1 {
+		size = 1
+	}
+	return &Cache{size: size, current: map[string]cachedVersion{}}
+}
+
+// Parse parses the version, like the Parse function of this package, or
+// returns the outcome of parsing it before.
+func (c *Cache) Parse(input string) (Version, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if cached, ok := c.current[input]; ok {
+		c.hits++
+		return cached.version, cached.err
+	}
+	cached, ok := c.previous[input]
+	if ok {
+		c.hits++
+	} else {
+		c.misses++
+		cached.err = parseInto(&cached.version, input)
+	}
+	if len(c.current) >= c.size {
+		/* What wasn't used since the last time is dropped */
+		c.previous, c.current = c.current, map[string]cachedVersion{}
+	}
+	c.current[input] = cached
+	return cached.version, cached.err
+}
+
+// Compare parses the two versions through the Cache, and compares them
+// as the Compare function of this package does.
+func (c *Cache) Compare(a, b string) (int, error) {
+	first, err := c.Parse(a)
+	if err != nil {
+		return 0, err
+	}
+	second, err := c.Parse(b)
+	if err != nil {
+		return 0, err
+	}
+	return Compare(first, second), nil
+}
+
+// Len returns the number of versions the Cache holds.
+func (c *Cache) Len() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return len(c.current) + len(c.previous)
+}
+
+// Stats returns how many times Parse found the version in the Cache, and
+// how many times it had to parse it.
+func (c *Cache) Stats() (uint64, uint64) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.hits, c.misses
+}
+
+/* The Cache used by Parse, and to decode control files, if any */
+var defaultCache atomic.Value
+
+// EnableCache makes Parse, and the decoding of versions in control files,
+// go through a Cache of the given size shared by the whole program, and
+// returns it; a size of 0 goes back to parsing every version.
+func EnableCache(size int) *Cache {
+	if size <= 0 {
+		defaultCache.Store((*Cache)(nil))
+		return nil
+	}
+	cache := NewCache(size)
+	defaultCache.Store(cache)
+	return cache
+}
+
+// parse parses the version through the Cache EnableCache set up, if any.
+func parse(result *Version, input string) error {
+	if cache, _ := defaultCache.Load().(*Cache); cache != nil {
+		var err error
+		*result, err = cache.Parse(input)
+		return err
+	}
+	return parseInto(result, input)
+}
+
+// }}}
+
+// vim: foldmethod=marker
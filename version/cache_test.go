@@ -0,0 +1,168 @@
+/* {{{ Copyright © 2012 Michael Stapelberg and contributors
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ *     * Redistributions of source code must retain the above copyright
+ *       notice, this list of conditions and the following disclaimer.
+ *
+ *     * Redistributions in binary form must reproduce the above copyright
+ *       notice, this list of conditions and the following disclaimer in the
+ *       documentation and/or other materials provided with the distribution.
+ *
+ *     * Neither the name of Michael Stapelberg nor the
+ *       names of contributors may be used to endorse or promote products
+ *       derived from this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY Michael Stapelberg ''AS IS'' AND ANY
+ * EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+ * WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL Michael Stapelberg BE LIABLE FOR ANY
+ * DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+ * (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+ * LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ * ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+ * (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE. }}} */
+
+package version // import "github.com/ebikt/go-debian/version"
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+func TestCache(t *testing.T) {
+	cache := NewCache(2)
+	for _, input := range []string{"1:2.10-3", "2.10-3", "1:2.10-3"} {
+		got, err := cache.Parse(input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, _ := Parse(input)
+		if got != want {
+			t.Errorf("Parse(%q) = %v, want %v", input, got, want)
+		}
+	}
+	if hits, misses := cache.Stats(); hits != 1 || misses != 2 {
+		t.Errorf("Stats() = %d, %d, want 1, 2", hits, misses)
+	}
+
+	if _, err := cache.Parse("2.10 3"); err == nil {
+		t.Error("Parse(\"2.10 3\") succeeded")
+	}
+	if _, err := cache.Parse("2.10 3"); err == nil || err.Error() != "version string has embedded spaces" {
+		t.Errorf("Cached error %v", err)
+	}
+
+	/* Past twice its size, the least recently used versions are dropped */
+	for i := 0; i < 10; i++ {
+		cache.Parse(fmt.Sprintf("1.%d", i))
+	}
+	if cache.Len() > 4 {
+		t.Errorf("Len() = %d, want at most 4", cache.Len())
+	}
+
+	cmp, err := cache.Compare("1:1.0", "2.0")
+	if err != nil || cmp <= 0 {
+		t.Errorf("Compare(1:1.0, 2.0) = %d, %v", cmp, err)
+	}
+	if _, err := cache.Compare("1.0", "a:b"); err == nil {
+		t.Error("Compare of an invalid version succeeded")
+	}
+}
+
+func TestCacheConcurrent(t *testing.T) {
+	cache := NewCache(16)
+	wg := sync.WaitGroup{}
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				input := fmt.Sprintf("%d.%d-1", i, j%32)
+				got, err := cache.Parse(input)
+				if err != nil || got.String() != input {
+					t.Errorf("Parse(%q) = %v, %v", input, got, err)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestEnableCache(t *testing.T) {
+	cache := EnableCache(100)
+	defer EnableCache(0)
+
+	for i := 0; i < 3; i++ {
+		if _, err := Parse("2.10-3"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	decoded := Version{}
+	if err := decoded.UnmarshalControl("2.10-3"); err != nil || decoded.String() != "2.10-3" {
+		t.Fatalf("UnmarshalControl: %v, %v", decoded, err)
+	}
+	if hits, misses := cache.Stats(); hits != 3 || misses != 1 {
+		t.Errorf("Stats() = %d, %d, want 3, 1", hits, misses)
+	}
+
+	if EnableCache(0) != nil {
+		t.Error("EnableCache(0) returned a Cache")
+	}
+	Parse("2.10-4")
+	if cache.Len() != 1 {
+		t.Errorf("Parse went through a disabled Cache")
+	}
+}
+
+/*
+ * The benchmarks compare versions the way a resolver going through the
+ * indexes of an archive does: a few tens of thousands of version strings,
+ * compared again and again.
+ */
+
+func archiveVersions() []string {
+	random := rand.New(rand.NewSource(1))
+	ret := []string{}
+	for i := 0; i < 30000; i++ {
+		switch i % 4 {
+		case 0:
+			ret = append(ret, fmt.Sprintf("%d.%d.%d-%d", random.Intn(5), random.Intn(30), random.Intn(20), random.Intn(5)+1))
+		case 1:
+			ret = append(ret, fmt.Sprintf("1:%d.%d+dfsg-%d+deb12u%d", random.Intn(10), random.Intn(50), random.Intn(3)+1, random.Intn(4)))
+		case 2:
+			ret = append(ret, fmt.Sprintf("%d.%d~rc%d-%dubuntu%d", random.Intn(10), random.Intn(10), random.Intn(5), random.Intn(3)+1, random.Intn(3)))
+		default:
+			ret = append(ret, fmt.Sprintf("0.0~git2023%04d.%07x-%d", random.Intn(1232), random.Intn(1<<28), random.Intn(2)+1))
+		}
+	}
+	return ret
+}
+
+func benchmarkCompare(b *testing.B, parse func(string) (Version, error)) {
+	versions := archiveVersions()
+	random := rand.New(rand.NewSource(2))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		first, _ := parse(versions[random.Intn(len(versions))])
+		second, _ := parse(versions[random.Intn(len(versions))])
+		Compare(first, second)
+	}
+}
+
+func BenchmarkCompareParse(b *testing.B) {
+	benchmarkCompare(b, Parse)
+}
+
+func BenchmarkCompareCache(b *testing.B) {
+	benchmarkCompare(b, NewCache(65536).Parse)
+}
+
+// vim: foldmethod=marker
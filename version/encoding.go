@@ -0,0 +1,118 @@
+/* {{{ Copyright © 2012 Michael Stapelberg and contributors
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ *     * Redistributions of source code must retain the above copyright
+ *       notice, this list of conditions and the following disclaimer.
+ *
+ *     * Redistributions in binary form must reproduce the above copyright
+ *       notice, this list of conditions and the following disclaimer in the
+ *       documentation and/or other materials provided with the distribution.
+ *
+ *     * Neither the name of Michael Stapelberg nor the
+ *       names of contributors may be used to endorse or promote products
+ *       derived from this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY Michael Stapelberg ''AS IS'' AND ANY
+ * EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+ * WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL Michael Stapelberg BE LIABLE FOR ANY
+ * DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+ * (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+ * LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ * ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+ * (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE. }}} */
+
+
+package version // import "github.com/ebikt/go-debian/version"
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// Value implements database/sql/driver.Valuer, storing the Version as its
+// string form. An empty Version is stored as NULL.
+//
+// Note that the database will compare those strings bytewise, which is not
+// the dpkg ordering. Store ComparableString() in another column if you
+// need to sort by version in SQL.
+func (v Version) Value() (driver.Value, error) {
+	if v.Empty() {
+		return nil, nil
+	}
+	return v.String(), nil
+}
+
+// Scan implements database/sql.Scanner, parsing and validating the Version
+// read from the database. NULL results in an empty Version.
+func (v *Version) Scan(src interface{}) error {
+	switch data := src.(type) {
+	case nil:
+		*v = Version{}
+		return nil
+	case string:
+		return v.scanString(data)
+	case []byte:
+		return v.scanString(string(data))
+	}
+	return fmt.Errorf("cannot scan %T into a version", src)
+}
+
+func (v *Version) scanString(data string) error {
+	ret := Version{}
+	if err := parse(&ret, data); err != nil {
+		return err
+	}
+	*v = ret
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler, rendering the Version
+// as a string, such as "1:2.3-4"; an empty Version is an empty string.
+func (v Version) MarshalText() ([]byte, error) {
+	if v.Empty() {
+		return []byte{}, nil
+	}
+	return []byte(v.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, parsing and
+// validating a Version. The empty string results in an empty Version.
+func (v *Version) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		*v = Version{}
+		return nil
+	}
+	return v.scanString(string(text))
+}
+
+// MarshalJSON encodes the Version as a JSON string, such as "1:2.3-4", as
+// MarshalText does; an empty Version is an empty string.
+func (v Version) MarshalJSON() ([]byte, error) {
+	text, err := v.MarshalText()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(string(text))
+}
+
+// UnmarshalJSON parses and validates a Version from a JSON string, as
+// UnmarshalText does: the empty string results in an empty Version. JSON
+// null leaves the Version untouched.
+func (v *Version) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return err
+	}
+	return v.UnmarshalText([]byte(str))
+}
+
+// vim:ts=4:sw=4:noexpandtab foldmethod=marker
@@ -0,0 +1,154 @@
+/* {{{ Copyright © 2012 Michael Stapelberg and contributors
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ *     * Redistributions of source code must retain the above copyright
+ *       notice, this list of conditions and the following disclaimer.
+ *
+ *     * Redistributions in binary form must reproduce the above copyright
+ *       notice, this list of conditions and the following disclaimer in the
+ *       documentation and/or other materials provided with the distribution.
+ *
+ *     * Neither the name of Michael Stapelberg nor the
+ *       names of contributors may be used to endorse or promote products
+ *       derived from this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY Michael Stapelberg ''AS IS'' AND ANY
+ * EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+ * WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL Michael Stapelberg BE LIABLE FOR ANY
+ * DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+ * (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+ * LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ * ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+ * (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE. }}} */
+
+
+package version // import "github.com/ebikt/go-debian/version"
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding"
+	"encoding/json"
+	"testing"
+)
+
+var (
+	_ driver.Valuer            = Version{}
+	_ sql.Scanner              = &Version{}
+	_ encoding.TextMarshaler   = Version{}
+	_ encoding.TextUnmarshaler = &Version{}
+)
+
+func TestSQLValue(t *testing.T) {
+	value, err := v(1, "2.3", "4").Value()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value != "1:2.3-4" {
+		t.Errorf("Value() = %v, want 1:2.3-4", value)
+	}
+
+	value, err = Version{}.Value()
+	if err != nil || value != nil {
+		t.Errorf("Value() of an empty version = %v, %v, want nil", value, err)
+	}
+}
+
+func TestSQLScan(t *testing.T) {
+	for _, src := range []interface{}{"1:2.3-4", []byte("1:2.3-4")} {
+		var got Version
+		if err := got.Scan(src); err != nil {
+			t.Fatal(err)
+		}
+		if got != v(1, "2.3", "4") {
+			t.Errorf("Scan(%q) = %v", src, got)
+		}
+	}
+
+	got := v(1, "2.3", "4")
+	if err := got.Scan(nil); err != nil || !got.Empty() {
+		t.Errorf("Scan(nil) = %v, %v, want empty version", got, err)
+	}
+
+	for _, src := range []interface{}{"1:2 3", 42} {
+		got := v(1, "2.3", "4")
+		if err := got.Scan(src); err == nil {
+			t.Errorf("Scan(%v) unexpectedly succeeded", src)
+		}
+		if got != v(1, "2.3", "4") {
+			t.Errorf("failed Scan(%v) modified the version: %v", src, got)
+		}
+	}
+}
+
+func TestJSON(t *testing.T) {
+	type upload struct {
+		Version  Version
+		Previous *Version
+	}
+
+	data, err := json.Marshal(upload{Version: v(0, "1.0", "1")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"Version":"1.0-1","Previous":null}` {
+		t.Errorf("json.Marshal() = %s", data)
+	}
+
+	var got upload
+	if err := json.Unmarshal([]byte(`{"Version":"2:1.0~rc1","Previous":"1.0-1"}`), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Version != v(2, "1.0~rc1", "") || got.Previous == nil || *got.Previous != v(0, "1.0", "1") {
+		t.Errorf("json.Unmarshal() = %+v", got)
+	}
+
+	/* The empty Version makes the round trip */
+	data, err = json.Marshal(upload{})
+	if err != nil || string(data) != `{"Version":"","Previous":null}` {
+		t.Errorf("json.Marshal() of an empty version = %s, %v", data, err)
+	}
+	got = upload{Version: v(0, "1.0", "1")}
+	if err := json.Unmarshal(data, &got); err != nil || !got.Version.Empty() || got.Previous != nil {
+		t.Errorf("json.Unmarshal(%s) = %+v, %v", data, got, err)
+	}
+	text, err := Version{}.MarshalText()
+	if err != nil || string(data) != `{"Version":"`+string(text)+`","Previous":null}` {
+		t.Errorf("MarshalText() and MarshalJSON() disagree: %q, %s", text, data)
+	}
+
+	for _, in := range []string{`{"Version":"1.0 1"}`, `{"Version":1}`} {
+		if err := json.Unmarshal([]byte(in), &got); err == nil {
+			t.Errorf("json.Unmarshal(%s) unexpectedly succeeded", in)
+		}
+	}
+}
+
+func TestText(t *testing.T) {
+	text, err := v(1, "2.3", "4").MarshalText()
+	if err != nil || string(text) != "1:2.3-4" {
+		t.Errorf("MarshalText() = %q, %v", text, err)
+	}
+	text, err = Version{}.MarshalText()
+	if err != nil || len(text) != 0 {
+		t.Errorf("MarshalText() of an empty version = %q, %v", text, err)
+	}
+
+	got := v(1, "2.3", "4")
+	if err := got.UnmarshalText([]byte("2.10-3")); err != nil || got != v(0, "2.10", "3") {
+		t.Errorf("UnmarshalText() = %+v, %v", got, err)
+	}
+	if err := got.UnmarshalText(nil); err != nil || !got.Empty() {
+		t.Errorf("UnmarshalText() of nothing = %+v, %v", got, err)
+	}
+	if err := got.UnmarshalText([]byte("1.0 1")); err == nil {
+		t.Errorf("UnmarshalText() of an invalid version unexpectedly succeeded")
+	}
+}
+
+// vim:ts=4:sw=4:noexpandtab foldmethod=marker
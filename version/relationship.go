@@ -0,0 +1,265 @@
+/* {{{ Copyright © 2012 Michael Stapelberg and contributors
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ *     * Redistributions of source code must retain the above copyright
+ *       notice, this list of conditions and the following disclaimer.
+ *
+ *     * Redistributions in binary form must reproduce the above copyright
+ *       notice, this list of conditions and the following disclaimer in the
+ *       documentation and/or other materials provided with the distribution.
+ *
+ *     * Neither the name of Michael Stapelberg nor the
+ *       names of contributors may be used to endorse or promote products
+ *       derived from this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY Michael Stapelberg ''AS IS'' AND ANY
+ * EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+ * WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL Michael Stapelberg BE LIABLE FOR ANY
+ * DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+ * (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+ * LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ * ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+ * (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE. }}} */
+
+package version // import "github.com/ebikt/go-debian/version"
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// The suffixes below follow the Debian Developer's Reference (section 5.10
+// for binNMUs, 5.11 for NMUs) and the conventions of backports.debian.org
+// and the stable/security archives. They are appended to the Debian
+// revision, or to the upstream version of native packages.
+var (
+	binNMUSuffix       = regexp.MustCompile(`\+b([0-9]+)$`)
+	backportSuffix     = regexp.MustCompile(`~bpo[0-9]*([.+][0-9]+)?$`)
+	stableUpdateSuffix = regexp.MustCompile(`\+deb([0-9]+)u([0-9]+)$`)
+	nativeNMUSuffix    = regexp.MustCompile(`\+nmu[0-9]+$`)
+)
+
+// tail returns the part of the version which is owned by the Debian
+// packaging: the revision, or the version itself for native packages.
+func (v Version) tail() string {
+	if v.IsNative() {
+		return v.Version
+	}
+	return v.Revision
+}
+
+func (v Version) withTail(tail string) Version {
+	if v.IsNative() {
+		v.Version = tail
+	} else {
+		v.Revision = tail
+	}
+	return v
+}
+
+func (v Version) stripSuffix(suffix *regexp.Regexp) (Version, bool) {
+	tail := v.tail()
+	loc := suffix.FindStringIndex(tail)
+	if loc == nil || loc[0] == 0 {
+		return v, false
+	}
+	return v.withTail(tail[:loc[0]]), true
+}
+
+// BinNMU returns the version this binNMU was built from, and the number of
+// the binNMU. For 1.0-1+b2, this is 1.0-1 and 2. If this isn't a binNMU
+// version, the version itself and 0 are returned.
+func (v Version) BinNMU() (Version, int) {
+	match := binNMUSuffix.FindStringSubmatch(v.tail())
+	if match == nil {
+		return v, 0
+	}
+	n, err := strconv.Atoi(match[1])
+	if err != nil {
+		return v, 0
+	}
+	base, ok := v.stripSuffix(binNMUSuffix)
+	if !ok {
+		return v, 0
+	}
+	return base, n
+}
+
+// SourceVersion returns the version of the source package a binary package
+// of this version was built from, that is the version without its binNMU
+// suffix: 1.0-1 for 1.0-1+b2, and 1.0-1 itself for 1.0-1.
+func (v Version) SourceVersion() Version {
+	base, _ := v.BinNMU()
+	return base
+}
+
+// IsBinNMU checks if this version carries a binNMU suffix, such as 1.0-1+b1.
+func (v Version) IsBinNMU() bool {
+	_, n := v.BinNMU()
+	return n > 0
+}
+
+// IsBinNMUOf checks if this version is a binNMU of the other one, like
+// 1.0-1+b1 is of 1.0-1.
+func (v Version) IsBinNMUOf(other Version) bool {
+	base, n := v.BinNMU()
+	return n > 0 && Compare(base, other) == 0
+}
+
+// withoutRebuild strips a binNMU suffix and then a backports suffix, which
+// are always the last ones to be appended.
+func (v Version) withoutRebuild() Version {
+	v, _ = v.BinNMU()
+	v, _ = v.stripSuffix(backportSuffix)
+	return v
+}
+
+// IsBackport checks if this version carries a backports suffix, such as
+// 1.0-1~bpo12+1 (possibly binNMUed, as in 1.0-1~bpo12+1+b1).
+func (v Version) IsBackport() bool {
+	base, _ := v.BinNMU()
+	_, ok := base.stripSuffix(backportSuffix)
+	return ok
+}
+
+// IsBackportOf checks if this version is a backport of the other one, like
+// 1.0-1~bpo12+1 is of 1.0-1.
+func (v Version) IsBackportOf(other Version) bool {
+	base, _ := v.BinNMU()
+	base, ok := base.stripSuffix(backportSuffix)
+	return ok && Compare(base, other) == 0
+}
+
+// StableUpdate returns the Debian release number and the update number of
+// a stable or security update version. For 1.0-1+deb12u3, this is 12 and 3.
+// If this isn't a stable update, 0 and 0 are returned.
+func (v Version) StableUpdate() (int, int) {
+	match := stableUpdateSuffix.FindStringSubmatch(v.withoutRebuild().tail())
+	if match == nil {
+		return 0, 0
+	}
+	release, err := strconv.Atoi(match[1])
+	if err != nil {
+		return 0, 0
+	}
+	update, err := strconv.Atoi(match[2])
+	if err != nil {
+		return 0, 0
+	}
+	return release, update
+}
+
+// IsStableUpdate checks if this version carries a stable update suffix,
+// such as 1.0-1+deb12u1.
+func (v Version) IsStableUpdate() bool {
+	_, ok := v.withoutRebuild().stripSuffix(stableUpdateSuffix)
+	return ok
+}
+
+// IsStableUpdateOf checks if this version is a stable or security update
+// of the other one, like 1.0-1+deb12u1 is of 1.0-1.
+func (v Version) IsStableUpdateOf(other Version) bool {
+	base, ok := v.withoutRebuild().stripSuffix(stableUpdateSuffix)
+	return ok && Compare(base, other) == 0
+}
+
+// IsNMU checks if this is the version of a Non-Maintainer Upload, that is
+// a revision with a dot in it (1.0-1.1, or 1.0-0.1 for a new upstream
+// version) or a +nmuN suffix for native packages. binNMU and backports
+// suffixes are ignored, so 1.0-1.1~bpo12+1 is an NMU as well.
+func (v Version) IsNMU() bool {
+	v = v.withoutRebuild()
+	if stripped, ok := v.stripSuffix(stableUpdateSuffix); ok {
+		v = stripped
+	}
+	if v.IsNative() {
+		return nativeNMUSuffix.MatchString(v.Version)
+	}
+	for i := 0; i < len(v.Revision); i++ {
+		if v.Revision[i] == '.' {
+			return true
+		}
+	}
+	return nativeNMUSuffix.MatchString(v.Revision)
+}
+
+// An UpdateSuffix describes the suffix a distribution appends to the
+// versions of its stable, security or vendor updates, such as
+// "+deb{release}u{n}" for Debian (1.0-1+deb12u1, 1.0-1+deb12u2, ...) or
+// "+rpt{n}" for Raspberry Pi OS. In the Template, "{n}" stands for the
+// update number, and "{release}" for Release.
+type UpdateSuffix struct {
+	Template string
+	Release  string
+}
+
+// DebianStableUpdate returns the UpdateSuffix of stable and security
+// updates of the given Debian release, such as +deb12u1 for Debian 12.
+func DebianStableUpdate(release int) UpdateSuffix {
+	return UpdateSuffix{Template: "+deb{release}u{n}", Release: strconv.Itoa(release)}
+}
+
+func (s UpdateSuffix) expand(n string) string {
+	return strings.Replace(strings.Replace(s.Template, "{release}", s.Release, -1), "{n}", n, -1)
+}
+
+func (s UpdateSuffix) regexp() (*regexp.Regexp, error) {
+	parts := strings.Split(s.expand("\x00"), "\x00")
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("Update suffix template '%s' must contain {n} exactly once", s.Template)
+	}
+	return regexp.Compile(regexp.QuoteMeta(parts[0]) + "([0-9]+)" + regexp.QuoteMeta(parts[1]) + "$")
+}
+
+// Update returns the version this update is based on, and the number of
+// the update. For 1.0-1+deb12u3 and DebianStableUpdate(12), this is 1.0-1
+// and 3. If this isn't such an update, the version itself and 0 are
+// returned. A binNMU suffix is ignored.
+func (s UpdateSuffix) Update(v Version) (Version, int, error) {
+	suffix, err := s.regexp()
+	if err != nil {
+		return v, 0, err
+	}
+	v, _ = v.BinNMU()
+	match := suffix.FindStringSubmatch(v.tail())
+	if match == nil {
+		return v, 0, nil
+	}
+	n, err := strconv.Atoi(match[1])
+	if err != nil {
+		return v, 0, err
+	}
+	base, ok := v.stripSuffix(suffix)
+	if !ok {
+		return v, 0, nil
+	}
+	return base, n, nil
+}
+
+// Next returns the version of the next update of the given version: 1.0-1
+// becomes 1.0-1+deb12u1, and 1.0-1+deb12u1 becomes 1.0-1+deb12u2 for
+// DebianStableUpdate(12). binNMU suffixes are dropped, since an update is
+// a sourceful upload.
+func (s UpdateSuffix) Next(v Version) (Version, error) {
+	base, n, err := s.Update(v)
+	if err != nil {
+		return v, err
+	}
+	next := base.withTail(base.tail() + s.expand(strconv.Itoa(n+1)))
+	if _, err := Parse(next.String()); err != nil {
+		return v, err
+	}
+	if Compare(next, v) <= 0 {
+		return v, fmt.Errorf("Next update %s is not newer than %s", next, v)
+	}
+	return next, nil
+}
+
+// vim:ts=4:sw=4:noexpandtab foldmethod=marker
@@ -0,0 +1,182 @@
+/* {{{ Copyright © 2012 Michael Stapelberg and contributors
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ *     * Redistributions of source code must retain the above copyright
+ *       notice, this list of conditions and the following disclaimer.
+ *
+ *     * Redistributions in binary form must reproduce the above copyright
+ *       notice, this list of conditions and the following disclaimer in the
+ *       documentation and/or other materials provided with the distribution.
+ *
+ *     * Neither the name of Michael Stapelberg nor the
+ *       names of contributors may be used to endorse or promote products
+ *       derived from this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY Michael Stapelberg ''AS IS'' AND ANY
+ * EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+ * WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL Michael Stapelberg BE LIABLE FOR ANY
+ * DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+ * (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+ * LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ * ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+ * (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE. }}} */
+
+package version // import "github.com/ebikt/go-debian/version"
+
+import (
+	"testing"
+)
+
+func mustParse(t *testing.T, in string) Version {
+	ver, err := Parse(in)
+	if err != nil {
+		t.Fatalf("Parse(%q): %v", in, err)
+	}
+	return ver
+}
+
+func TestBinNMU(t *testing.T) {
+	for _, test := range []struct {
+		Version string
+		Base    string
+		N       int
+	}{
+		{"1.0-1+b1", "1.0-1", 1},
+		{"1:1.0-1+b12", "1:1.0-1", 12},
+		{"1.0+b2", "1.0", 2},
+		{"1.0-1~bpo12+1+b1", "1.0-1~bpo12+1", 1},
+		{"1.0-1", "1.0-1", 0},
+		{"1.0-1+bx", "1.0-1+bx", 0},
+		{"1.0-+b1", "1.0-+b1", 0},
+	} {
+		ver := mustParse(t, test.Version)
+		base, n := ver.BinNMU()
+		if base.String() != test.Base || n != test.N {
+			t.Errorf("%s.BinNMU() = %s, %d, want %s, %d", ver, base, n, test.Base, test.N)
+		}
+		if source := ver.SourceVersion(); source.String() != test.Base {
+			t.Errorf("%s.SourceVersion() = %s, want %s", ver, source, test.Base)
+		}
+		if ver.IsBinNMU() != (test.N > 0) {
+			t.Errorf("%s.IsBinNMU() = %v", ver, ver.IsBinNMU())
+		}
+	}
+
+	if !mustParse(t, "1.0-1+b1").IsBinNMUOf(mustParse(t, "1.0-1")) {
+		t.Errorf("1.0-1+b1 is not a binNMU of 1.0-1")
+	}
+	if mustParse(t, "1.0-1+b1").IsBinNMUOf(mustParse(t, "1.0-2")) {
+		t.Errorf("1.0-1+b1 is a binNMU of 1.0-2")
+	}
+	if mustParse(t, "1.0-1").IsBinNMUOf(mustParse(t, "1.0-1")) {
+		t.Errorf("1.0-1 is a binNMU of itself")
+	}
+}
+
+func TestBackport(t *testing.T) {
+	for _, test := range []struct {
+		Version string
+		Of      string
+		Is      bool
+	}{
+		{"1.0-1~bpo12+1", "1.0-1", true},
+		{"1.0-1~bpo70+2", "1.0-1", true},
+		{"1.0-1~bpo.1", "1.0-1", true},
+		{"1.0-1~bpo12+1+b1", "1.0-1", true},
+		{"1.0-1+deb12u1~bpo11+1", "1.0-1+deb12u1", true},
+		{"1.0~bpo12+1", "1.0", true},
+		{"1.0-1~bpo12+1", "1.0-2", false},
+		{"1.0-1", "1.0-1", false},
+	} {
+		ver := mustParse(t, test.Version)
+		of := mustParse(t, test.Of)
+		if ver.IsBackportOf(of) != test.Is {
+			t.Errorf("%s.IsBackportOf(%s) = %v", ver, of, !test.Is)
+		}
+		if test.Is && !ver.IsBackport() {
+			t.Errorf("%s.IsBackport() = false", ver)
+		}
+	}
+}
+
+func TestStableUpdate(t *testing.T) {
+	ver := mustParse(t, "2.4-1+deb12u3")
+	if release, update := ver.StableUpdate(); release != 12 || update != 3 {
+		t.Errorf("%s.StableUpdate() = %d, %d", ver, release, update)
+	}
+	if !ver.IsStableUpdate() || !ver.IsStableUpdateOf(mustParse(t, "2.4-1")) {
+		t.Errorf("%s is not a stable update of 2.4-1", ver)
+	}
+	if !mustParse(t, "2.4-1+deb12u3+b1").IsStableUpdateOf(mustParse(t, "2.4-1")) {
+		t.Errorf("binNMU of %s is not a stable update of 2.4-1", ver)
+	}
+	if mustParse(t, "2.4-1").IsStableUpdate() {
+		t.Errorf("2.4-1 is a stable update")
+	}
+}
+
+func TestNMU(t *testing.T) {
+	for in, nmu := range map[string]bool{
+		"1.0-1.1":          true,
+		"1.0-0.1":          true,
+		"1.0+nmu1":         true,
+		"1.0-1.1+b1":       true,
+		"1.0-1.1~bpo12+1":  true,
+		"1.0-1.1+deb12u1":  true,
+		"1.0-1":            false,
+		"1.0":              false,
+		"1.0-1+b1":         false,
+		"1.0-1~bpo12+1":    false,
+		"1.0-1+deb12u1":    false,
+		"1.0-1~bpo.1":      false,
+		"1.0-1+deb12u1+b1": false,
+	} {
+		if got := mustParse(t, in).IsNMU(); got != nmu {
+			t.Errorf("%s.IsNMU() = %v, want %v", in, got, nmu)
+		}
+	}
+}
+
+func TestUpdateSuffix(t *testing.T) {
+	for _, test := range []struct {
+		Suffix  UpdateSuffix
+		Version string
+		Next    string
+	}{
+		{DebianStableUpdate(12), "1.2-3", "1.2-3+deb12u1"},
+		{DebianStableUpdate(12), "1.2-3+deb12u1", "1.2-3+deb12u2"},
+		{DebianStableUpdate(12), "1.2-3+deb12u9+b1", "1.2-3+deb12u10"},
+		{DebianStableUpdate(12), "2:1.2", "2:1.2+deb12u1"},
+		{UpdateSuffix{Template: "+rpt{n}"}, "1.2-3", "1.2-3+rpt1"},
+		{UpdateSuffix{Template: "+rpt{n}"}, "1.2-3+rpt7", "1.2-3+rpt8"},
+	} {
+		next, err := test.Suffix.Next(mustParse(t, test.Version))
+		if err != nil || next.String() != test.Next {
+			t.Errorf("Next(%s) = %s (%v), want %s", test.Version, next, err, test.Next)
+		}
+	}
+
+	base, n, err := DebianStableUpdate(12).Update(mustParse(t, "1:1.0-1+deb12u3"))
+	if err != nil || base.String() != "1:1.0-1" || n != 3 {
+		t.Errorf("Update(1:1.0-1+deb12u3) = %s, %d (%v)", base, n, err)
+	}
+
+	for _, template := range []string{"+deb12", "+{n}u{n}"} {
+		if _, err := (UpdateSuffix{Template: template}).Next(mustParse(t, "1.0-1")); err == nil {
+			t.Errorf("Template %s was accepted", template)
+		}
+	}
+	if next, err := (UpdateSuffix{Template: "~{release}.{n}", Release: "bookworm"}).Next(mustParse(t, "1.0-1")); err == nil {
+		t.Errorf("Next(1.0-1) = %s, which is not newer", next)
+	}
+	if _, err := (UpdateSuffix{Template: "+a b{n}"}).Next(mustParse(t, "1.0-1")); err == nil {
+		t.Errorf("Suffix with a space was accepted")
+	}
+}
+
+// vim:ts=4:sw=4:noexpandtab foldmethod=marker
@@ -68,7 +68,7 @@ func (v *Version) IsNative() bool {
 }
 
 func (version *Version) UnmarshalControl(data string) error {
-	return parseInto(version, data)
+	return parse(version, data)
 }
 
 func (version Version) MarshalControl() (string, error) {
@@ -357,12 +357,33 @@ func Compare(a Version, b Version) int {
 	return verrevcmp(a.Revision, b.Revision)
 }
 
+// SatisfiesRelation checks if `ver` satisfies the version restriction of a
+// relation, such as `>= target` for "foo (>= 1.0)". The operators are the
+// ones of Debian Policy, section 7.1: "<<", "<=", "=", ">=" and ">>", along
+// with the obsolete "<" and ">", which dpkg reads as "<=" and ">=".
+func SatisfiesRelation(ver Version, op string, target Version) (bool, error) {
+	q := Compare(ver, target)
+	switch op {
+	case "<<":
+		return q < 0, nil
+	case "<=", "<":
+		return q <= 0, nil
+	case "=":
+		return q == 0, nil
+	case ">=", ">":
+		return q >= 0, nil
+	case ">>":
+		return q > 0, nil
+	}
+	return false, fmt.Errorf("Unknown version relation operator '%s'", op)
+}
+
 // Parse returns a Version struct filled with the epoch, version and revision
 // specified in input. It verifies the version string as a whole, just like
 // dpkg(1), and even returns roughly the same error messages.
 func Parse(input string) (Version, error) {
 	result := Version{}
-	return result, parseInto(&result, input)
+	return result, parse(&result, input)
 }
 
 func validateVerRev(v string, name string, chars string) error {
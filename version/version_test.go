@@ -347,4 +347,33 @@ func TestParseInvalidCharactersInRevision(t *testing.T) {
 	}
 }
 
+func TestSatisfiesRelation(t *testing.T) {
+	for _, test := range []struct {
+		Version  string
+		Op       string
+		Target   string
+		Expected bool
+	}{
+		{"1.0~rc1-1", ">=", "1.0-1", false},
+		{"1.0~rc1-1", "<<", "1.0", true},
+		{"1.0~rc1", "<<", "1.0", true},
+		{"1.0-1", ">>", "1.0", true},
+		{"1:0.9", ">>", "2.0", true},
+		{"1.0a", ">>", "1.0+", false},
+		{"1.0-1+b1", "=", "1.0-1+b1", true},
+		{"1.0-1+b1", "=", "1.0-1", false},
+		{"1.10", "<=", "1.9", false},
+		{"1.10", "<", "1.10", true},
+		{"1.10", ">", "1.10", true},
+	} {
+		ok, err := SatisfiesRelation(mustParse(t, test.Version), test.Op, mustParse(t, test.Target))
+		if err != nil || ok != test.Expected {
+			t.Errorf("%s %s %s: got %v (%v), want %v", test.Version, test.Op, test.Target, ok, err, test.Expected)
+		}
+	}
+	if _, err := SatisfiesRelation(mustParse(t, "1.0"), "!=", mustParse(t, "1.0")); err == nil {
+		t.Errorf("Unknown operator != was accepted")
+	}
+}
+
 // vim:ts=4:sw=4:noexpandtab foldmethod=marker
@@ -0,0 +1,21 @@
+/*
+
+This module parses debian/watch files, versions 3 and 4, as read by
+uscan(1), and finds the newest upstream version among the files an upstream
+site lists, without running uscan.
+
+	w, err := watch.ParseFile("debian/watch", "hello")
+	if err != nil {
+		return err
+	}
+	newest, err := w.Entries[0].Newest([]string{
+		"https://ftp.gnu.org/gnu/hello/hello-2.10.tar.gz",
+		"https://ftp.gnu.org/gnu/hello/hello-2.12.tar.gz",
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Println(newest.Version)
+
+*/
+package watch // import "github.com/ebikt/go-debian/watch"
@@ -0,0 +1,278 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package watch // import "github.com/ebikt/go-debian/watch"
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Mangling rules {{{
+
+// Rule is a mangling rule of a watch file option, written the perl way,
+// either as a substitution, "s/pattern/replacement/flags", or as a
+// transliteration, "tr/search/replace/flags" (or "y/.../.../").
+type Rule struct {
+	Op string
+
+	// Set for a substitution; the pattern is a Go regexp, so perl only
+	// constructs such as lookarounds are refused.
+	Regexp      *regexp.Regexp
+	Replacement string
+	Global      bool
+
+	// Set for a transliteration.
+	Search  []rune
+	Replace []rune
+	Delete  bool
+}
+
+// ParseRules parses the value of a mangling option, a list of rules
+// separated by ";".
+func ParseRules(value string) ([]Rule, error) {
+	ret := []Rule{}
+	value = strings.TrimSpace(value)
+	for value != "" {
+		rule, rest, err := parseRule(value)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, *rule)
+		rest = strings.TrimSpace(rest)
+		if rest != "" && rest[0] != ';' {
+			return nil, fmt.Errorf("Trailing garbage after mangling rule: '%s'", rest)
+		}
+		value = strings.TrimSpace(strings.TrimPrefix(rest, ";"))
+	}
+	return ret, nil
+}
+
+// splitDelimited reads the n parts of a rule following the operator, each
+// ended by the delimiter, which is the character right after the operator.
+func splitDelimited(in string, n int) ([]string, string, error) {
+	if in == "" {
+		return nil, "", fmt.Errorf("Mangling rule has no delimiter")
+	}
+	delim := in[0]
+	if delim == '\\' || delim == ' ' {
+		return nil, "", fmt.Errorf("Invalid mangling rule delimiter '%c'", delim)
+	}
+	parts := []string{}
+	current := strings.Builder{}
+	for i := 1; i < len(in); i++ {
+		switch {
+		case in[i] == '\\' && i+1 < len(in) && in[i+1] == delim:
+			current.WriteByte(delim)
+			i++
+		case in[i] == '\\' && i+1 < len(in):
+			current.WriteString(in[i : i+2])
+			i++
+		case in[i] == delim:
+			parts = append(parts, current.String())
+			current.Reset()
+			if len(parts) == n {
+				return parts, in[i+1:], nil
+			}
+		default:
+			current.WriteByte(in[i])
+		}
+	}
+	return nil, "", fmt.Errorf("Unterminated mangling rule")
+}
+
+func parseRule(in string) (*Rule, string, error) {
+	var op string
+	switch {
+	case strings.HasPrefix(in, "tr"):
+		op = "tr"
+	case strings.HasPrefix(in, "s"), strings.HasPrefix(in, "y"):
+		op = in[:1]
+	default:
+		return nil, "", fmt.Errorf("Unknown mangling rule: '%s'", in)
+	}
+	parts, rest, err := splitDelimited(in[len(op):], 2)
+	if err != nil {
+		return nil, "", err
+	}
+	end := strings.IndexAny(rest, "; \t")
+	if end < 0 {
+		end = len(rest)
+	}
+	flags, rest := rest[:end], rest[end:]
+
+	if op == "s" {
+		rule, err := newSubstitution(parts[0], parts[1], flags)
+		return rule, rest, err
+	}
+	rule, err := newTransliteration(parts[0], parts[1], flags)
+	return rule, rest, err
+}
+
+func newSubstitution(pattern, replacement, flags string) (*Rule, error) {
+	rule := Rule{Op: "s"}
+	prefix := ""
+	for _, flag := range flags {
+		switch flag {
+		case 'g':
+			rule.Global = true
+		case 'i', 'm', 's', 'x':
+			prefix += string(flag)
+		default:
+			return nil, fmt.Errorf("Unknown substitution flag '%c'", flag)
+		}
+	}
+	if prefix != "" {
+		pattern = "(?" + prefix + ")" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid mangling pattern: %s", err)
+	}
+	rule.Regexp = re
+	rule.Replacement = perlReplacement(replacement)
+	return &rule, nil
+}
+
+// perlReplacement turns the "$1", "${1}" and "\1" references of a perl
+// replacement into the "${1}" ones of regexp.Expand, and unescapes the
+// rest.
+func perlReplacement(in string) string {
+	out := strings.Builder{}
+	for i := 0; i < len(in); i++ {
+		switch c := in[i]; {
+		case (c == '\\' || c == '$') && i+1 < len(in) && in[i+1] >= '0' && in[i+1] <= '9':
+			j := i + 1
+			for j < len(in) && in[j] >= '0' && in[j] <= '9' {
+				j++
+			}
+			out.WriteString("${" + in[i+1:j] + "}")
+			i = j - 1
+		case c == '$' && i+1 < len(in) && in[i+1] == '{':
+			end := strings.IndexByte(in[i:], '}')
+			if end < 0 {
+				out.WriteString("$$")
+				continue
+			}
+			out.WriteString(in[i : i+end+1])
+			i += end
+		case c == '$':
+			out.WriteString("$$")
+		case c == '\\' && i+1 < len(in):
+			i++
+			if in[i] == '$' {
+				out.WriteString("$$")
+			} else {
+				out.WriteByte(in[i])
+			}
+		default:
+			out.WriteByte(c)
+		}
+	}
+	return out.String()
+}
+
+// expandRange expands the "a-z" ranges of a transliteration list.
+func expandRange(in string) []rune {
+	runes := []rune(strings.ReplaceAll(in, `\-`, "\x00"))
+	ret := []rune{}
+	for i := 0; i < len(runes); i++ {
+		if i+2 < len(runes) && runes[i+1] == '-' && runes[i] <= runes[i+2] {
+			for r := runes[i]; r <= runes[i+2]; r++ {
+				ret = append(ret, r)
+			}
+			i += 2
+			continue
+		}
+		if runes[i] == 0 {
+			ret = append(ret, '-')
+		} else {
+			ret = append(ret, runes[i])
+		}
+	}
+	return ret
+}
+
+func newTransliteration(search, replace, flags string) (*Rule, error) {
+	rule := Rule{Op: "tr", Search: expandRange(search), Replace: expandRange(replace)}
+	for _, flag := range flags {
+		switch flag {
+		case 'd':
+			rule.Delete = true
+		default:
+			return nil, fmt.Errorf("Unknown transliteration flag '%c'", flag)
+		}
+	}
+	if len(rule.Replace) == 0 && !rule.Delete {
+		rule.Replace = rule.Search
+	}
+	return &rule, nil
+}
+
+// Apply applies the rule to the string.
+func (r Rule) Apply(in string) string {
+	if r.Op != "tr" && r.Op != "y" {
+		return r.substitute(in)
+	}
+	out := strings.Builder{}
+	for _, c := range in {
+		index := -1
+		for i, s := range r.Search {
+			if s == c {
+				index = i
+				break
+			}
+		}
+		switch {
+		case index < 0:
+			out.WriteRune(c)
+		case index < len(r.Replace):
+			out.WriteRune(r.Replace[index])
+		case !r.Delete && len(r.Replace) > 0:
+			out.WriteRune(r.Replace[len(r.Replace)-1])
+		}
+	}
+	return out.String()
+}
+
+func (r Rule) substitute(in string) string {
+	if r.Global {
+		return r.Regexp.ReplaceAllString(in, r.Replacement)
+	}
+	match := r.Regexp.FindStringSubmatchIndex(in)
+	if match == nil {
+		return in
+	}
+	out := r.Regexp.ExpandString(nil, r.Replacement, in, match)
+	return in[:match[0]] + string(out) + in[match[1]:]
+}
+
+// Mangle applies the rules to the string, in order.
+func Mangle(rules []Rule, in string) string {
+	for _, rule := range rules {
+		in = rule.Apply(in)
+	}
+	return in
+}
+
+// }}}
+
+// vim: foldmethod=marker
@@ -0,0 +1,380 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package watch // import "github.com/ebikt/go-debian/watch"
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ebikt/go-debian/version"
+)
+
+// Options {{{
+
+// Options are the "opts=" of a watch line, by name. Options without a
+// value, such as "repack", are there with an empty one.
+type Options map[string]string
+
+// Starts an option name, where the value of the previous option ends; a
+// comma that doesn't, as in "s/,/./", belongs to the value.
+var optionStart = regexp.MustCompile(`^\s*[A-Za-z]+\s*(=|,|$)`)
+
+func parseOptions(in string) (Options, error) {
+	ret := Options{}
+	parts := []string{}
+	start := 0
+	for i := 0; i < len(in); i++ {
+		if in[i] == ',' && optionStart.MatchString(in[i+1:]) {
+			parts = append(parts, in[start:i])
+			start = i + 1
+		}
+	}
+	parts = append(parts, in[start:])
+	for _, part := range parts {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		kv := strings.SplitN(part, "=", 2)
+		name := strings.ToLower(strings.TrimSpace(kv[0]))
+		if !optionStart.MatchString(name) {
+			return nil, fmt.Errorf("Invalid option: '%s'", part)
+		}
+		if len(kv) == 2 {
+			ret[name] = strings.TrimSpace(kv[1])
+		} else {
+			ret[name] = ""
+		}
+	}
+	return ret, nil
+}
+
+// Has tells whether the option is set.
+func (o Options) Has(name string) bool {
+	_, ok := o[name]
+	return ok
+}
+
+// Rules parses the mangling rules of the option, none if it's not set.
+func (o Options) Rules(name string) ([]Rule, error) {
+	return ParseRules(o[name])
+}
+
+// mangling returns the rules of the option, or else the ones of the
+// "versionmangle" option, which applies to both the upstream and the Debian
+// versions.
+func (o Options) mangling(name string) ([]Rule, error) {
+	if o.Has(name) {
+		return o.Rules(name)
+	}
+	return o.Rules("versionmangle")
+}
+
+// }}}
+
+// Entry {{{
+
+// Entry is a line of a watch file, telling where to look for the upstream
+// files, and how to tell their versions.
+type Entry struct {
+	// Line of the watch file the entry starts on.
+	Line int
+
+	Options Options
+
+	// The page listing the upstream files, and the regular expression
+	// matching them, with the version in its groups.
+	URL     string
+	Pattern string
+
+	// What to do with the upstream version, such as "debian", "same" or
+	// "ignore", or a version to fetch; and the obsolete script to run.
+	VersionPolicy string
+	Script        string
+}
+
+// Candidate is an upstream file matching the Pattern of an Entry.
+type Candidate struct {
+	URL string
+
+	// The groups of the pattern joined by ".", and the version this gives
+	// once mangled by the "uversionmangle" option.
+	Upstream string
+	Version  version.Version
+}
+
+func (e Entry) regexp() (*regexp.Regexp, error) {
+	re, err := regexp.Compile("^(?:" + e.Pattern + ")$")
+	if err != nil {
+		return nil, fmt.Errorf("Invalid watch pattern '%s': %s", e.Pattern, err)
+	}
+	if re.NumSubexp() == 0 {
+		return nil, fmt.Errorf("Watch pattern '%s' has no group for the version", e.Pattern)
+	}
+	return re, nil
+}
+
+// match matches the candidate as a whole, or else its base name when the
+// pattern is about file names only.
+func match(re *regexp.Regexp, pattern, candidate string) []string {
+	if groups := re.FindStringSubmatch(candidate); groups != nil {
+		return groups
+	}
+	if strings.Contains(pattern, "/") {
+		return nil
+	}
+	return re.FindStringSubmatch(path.Base(candidate))
+}
+
+// Scan returns the candidates matching the Pattern, newest first, as
+// compared by dpkg. The candidates are file names or URLs, such as the
+// links of the page at URL; the ones not matching are left out.
+func (e Entry) Scan(candidates []string) ([]Candidate, error) {
+	re, err := e.regexp()
+	if err != nil {
+		return nil, err
+	}
+	rules, err := e.Options.mangling("uversionmangle")
+	if err != nil {
+		return nil, err
+	}
+	ret := []Candidate{}
+	for _, candidate := range candidates {
+		groups := match(re, e.Pattern, candidate)
+		if groups == nil {
+			continue
+		}
+		parts := []string{}
+		for _, group := range groups[1:] {
+			if group != "" {
+				parts = append(parts, group)
+			}
+		}
+		upstream := strings.Join(parts, ".")
+		mangled := Mangle(rules, upstream)
+		if mangled == "" {
+			continue
+		}
+		ret = append(ret, Candidate{
+			URL:      candidate,
+			Upstream: upstream,
+			Version:  version.Version{Version: mangled},
+		})
+	}
+	sort.SliceStable(ret, func(i, j int) bool {
+		return version.Compare(ret[i].Version, ret[j].Version) > 0
+	})
+	return ret, nil
+}
+
+// Newest returns the newest of the candidates matching the Pattern, or nil
+// if none does.
+func (e Entry) Newest(candidates []string) (*Candidate, error) {
+	all, err := e.Scan(candidates)
+	if err != nil || len(all) == 0 {
+		return nil, err
+	}
+	return &all[0], nil
+}
+
+// DebianUpstreamVersion returns the upstream part of the Debian version,
+// mangled by the "dversionmangle" option, for comparing with the upstream
+// versions.
+func (e Entry) DebianUpstreamVersion(current version.Version) (version.Version, error) {
+	rules, err := e.Options.mangling("dversionmangle")
+	if err != nil {
+		return version.Version{}, err
+	}
+	return version.Version{Version: Mangle(rules, current.Version)}, nil
+}
+
+// Check returns the newest of the candidates, and whether it's newer than
+// the upstream version of the current Debian version.
+func (e Entry) Check(current version.Version, candidates []string) (*Candidate, bool, error) {
+	newest, err := e.Newest(candidates)
+	if err != nil || newest == nil {
+		return nil, false, err
+	}
+	upstream, err := e.DebianUpstreamVersion(current)
+	if err != nil {
+		return nil, false, err
+	}
+	return newest, version.Compare(newest.Version, upstream) > 0, nil
+}
+
+// DownloadURL returns the URL of the candidate mangled by the
+// "downloadurlmangle" option.
+func (e Entry) DownloadURL(candidate Candidate) (string, error) {
+	rules, err := e.Options.Rules("downloadurlmangle")
+	if err != nil {
+		return "", err
+	}
+	return Mangle(rules, candidate.URL), nil
+}
+
+// }}}
+
+// Watch {{{
+
+// Watch is a debian/watch file.
+type Watch struct {
+	Version int
+	Entries []Entry
+}
+
+// The placeholders of version 4 watch files, and what uscan replaces them
+// with.
+var substitutions = []struct{ name, value string }{
+	{"@ANY_VERSION@", `[-_]?[Vv]?(\d[\-+\.:\~\da-zA-Z]*)`},
+	{"@ARCHIVE_EXT@", `(?i:\.(?:tar\.xz|tar\.bz2|tar\.gz|tar\.zstd?|zip|tgz|tbz|txz))`},
+	{"@SIGNATURE_EXT@", `(?i:\.(?:tar\.xz|tar\.bz2|tar\.gz|tar\.zstd?|zip|tgz|tbz|txz))(?i:\.(?:asc|pgp|gpg|sig|sign))`},
+	{"@DEB_EXT@", `[\+~](debian|dfsg|ds|deb)(\.)?(\d+)?$`},
+}
+
+// logicalLines joins the lines continued by a trailing "\", dropping the
+// comments and blank lines, along with the number of the line each one
+// starts on.
+func logicalLines(reader io.Reader) ([]string, []int, error) {
+	lines, numbers := []string{}, []int{}
+	scanner := bufio.NewScanner(reader)
+	current, start, lineno := "", 0, 0
+	for scanner.Scan() {
+		lineno++
+		line := scanner.Text()
+		if current == "" {
+			start = lineno
+			if trimmed := strings.TrimSpace(line); trimmed == "" || trimmed[0] == '#' {
+				continue
+			}
+		} else {
+			line = strings.TrimLeft(line, " \t")
+		}
+		if strings.HasSuffix(line, "\\") {
+			current += strings.TrimSuffix(line, "\\")
+			continue
+		}
+		lines = append(lines, strings.TrimSpace(current+line))
+		numbers = append(numbers, start)
+		current = ""
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+	if current != "" {
+		return nil, nil, fmt.Errorf("line %d: Continued line at the end of the file", start)
+	}
+	return lines, numbers, nil
+}
+
+// Parse reads a debian/watch file of the source package, whose name the
+// "@PACKAGE@" placeholder stands for.
+func Parse(reader io.Reader, source string) (*Watch, error) {
+	lines, numbers, err := logicalLines(reader)
+	if err != nil {
+		return nil, err
+	}
+	if len(lines) == 0 || !strings.HasPrefix(lines[0], "version=") {
+		return nil, fmt.Errorf("Watch file does not start with a version line")
+	}
+	ret := Watch{Entries: []Entry{}}
+	ret.Version, err = strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(lines[0], "version=")))
+	if err != nil || ret.Version < 3 || ret.Version > 4 {
+		return nil, fmt.Errorf("Unsupported watch file version: '%s'", lines[0])
+	}
+
+	for i, line := range lines[1:] {
+		if ret.Version >= 4 {
+			for _, sub := range substitutions {
+				line = strings.ReplaceAll(line, sub.name, sub.value)
+			}
+		}
+		line = strings.ReplaceAll(line, "@PACKAGE@", source)
+		entry, err := parseEntry(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", numbers[i+1], err)
+		}
+		entry.Line = numbers[i+1]
+		ret.Entries = append(ret.Entries, *entry)
+	}
+	return &ret, nil
+}
+
+func parseEntry(line string) (*Entry, error) {
+	entry := Entry{Options: Options{}}
+	if strings.HasPrefix(line, "opts=") || strings.HasPrefix(line, "options=") {
+		value := line[strings.IndexByte(line, '=')+1:]
+		var opts string
+		if strings.HasPrefix(value, `"`) {
+			end := strings.IndexByte(value[1:], '"')
+			if end < 0 {
+				return nil, fmt.Errorf("Unterminated opts")
+			}
+			opts, line = value[1:end+1], value[end+2:]
+		} else {
+			end := strings.IndexAny(value, " \t")
+			if end < 0 {
+				end = len(value)
+			}
+			opts, line = value[:end], value[end:]
+		}
+		options, err := parseOptions(opts)
+		if err != nil {
+			return nil, err
+		}
+		entry.Options = options
+	}
+
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("No URL")
+	}
+	entry.URL, fields = fields[0], fields[1:]
+	if slash := strings.LastIndex(entry.URL, "/"); slash >= 0 && strings.Contains(entry.URL[slash:], "(") {
+		entry.URL, entry.Pattern = entry.URL[:slash+1], entry.URL[slash+1:]
+	} else if len(fields) > 0 {
+		entry.Pattern, fields = fields[0], fields[1:]
+	} else {
+		return nil, fmt.Errorf("No pattern for '%s'", entry.URL)
+	}
+	if len(fields) > 0 {
+		entry.VersionPolicy, fields = fields[0], fields[1:]
+	}
+	entry.Script = strings.Join(fields, " ")
+
+	if _, err := entry.regexp(); err != nil {
+		return nil, err
+	}
+	for _, name := range []string{"uversionmangle", "dversionmangle", "versionmangle", "downloadurlmangle", "filenamemangle"} {
+		if _, err := entry.Options.Rules(name); err != nil {
+			return nil, fmt.Errorf("Invalid %s: %s", name, err)
+		}
+	}
+	return &entry, nil
+}
+
+// }}}
+
+// vim: foldmethod=marker
@@ -0,0 +1,169 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package watch_test
+
+import (
+	"log"
+	"runtime/debug"
+	"strings"
+	"testing"
+
+	"github.com/ebikt/go-debian/version"
+	"github.com/ebikt/go-debian/watch"
+)
+
+/*
+ *
+ */
+
+func isok(t *testing.T, err error) {
+	if err != nil {
+		log.Printf("Error! Error is not nil! %s\n", err)
+		debug.PrintStack()
+		t.FailNow()
+	}
+}
+
+func notok(t *testing.T, err error) {
+	if err == nil {
+		log.Printf("Error! Error is nil!\n")
+		debug.PrintStack()
+		t.FailNow()
+	}
+}
+
+func assert(t *testing.T, expr bool) {
+	if !expr {
+		log.Printf("Assertion failed!")
+		debug.PrintStack()
+		t.FailNow()
+	}
+}
+
+/*
+ *
+ */
+
+const helloWatch = `# Watch for new hello releases
+version=4
+opts="pgpsigurlmangle=s/$/.sig/,uversionmangle=s/-?rc/~rc/;tr/_/./, \
+  dversionmangle=s/\+dfsg\d*$//,repack" \
+  https://ftp.gnu.org/gnu/@PACKAGE@/ @PACKAGE@@ANY_VERSION@@ARCHIVE_EXT@
+
+https://example.org/releases/foo-(\d+)_(\d+)\.tar\.gz debian uupdate
+`
+
+func TestParse(t *testing.T) {
+	w, err := watch.Parse(strings.NewReader(helloWatch), "hello")
+	isok(t, err)
+	assert(t, w.Version == 4)
+	assert(t, len(w.Entries) == 2)
+
+	entry := w.Entries[0]
+	assert(t, entry.Line == 3)
+	assert(t, entry.URL == "https://ftp.gnu.org/gnu/hello/")
+	assert(t, strings.HasPrefix(entry.Pattern, "hello[-_]"))
+	assert(t, entry.Options["pgpsigurlmangle"] == "s/$/.sig/")
+	assert(t, entry.Options["uversionmangle"] == "s/-?rc/~rc/;tr/_/./")
+	assert(t, entry.Options.Has("repack"))
+
+	entry = w.Entries[1]
+	assert(t, entry.Line == 7)
+	assert(t, entry.URL == "https://example.org/releases/")
+	assert(t, entry.Pattern == `foo-(\d+)_(\d+)\.tar\.gz`)
+	assert(t, entry.VersionPolicy == "debian")
+	assert(t, entry.Script == "uupdate")
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, data := range []string{
+		"",
+		"https://example.org/ foo-(.*).tar.gz\n",
+		"version=2\nhttps://example.org/ foo-(.*).tar.gz\n",
+		"version=4\nhttps://example.org/\n",
+		"version=4\nhttps://example.org/ foo-.*.tar.gz\n",
+		"version=4\nopts=uversionmangle=s/a/b https://example.org/ foo-(.*).tar.gz\n",
+		"version=4\nopts=\"repack https://example.org/ foo-(.*).tar.gz\n",
+		"version=4\nhttps://example.org/ \\\n",
+	} {
+		_, err := watch.Parse(strings.NewReader(data), "foo")
+		notok(t, err)
+	}
+}
+
+func TestNewest(t *testing.T) {
+	w, err := watch.Parse(strings.NewReader(helloWatch), "hello")
+	isok(t, err)
+	entry := w.Entries[0]
+	candidates := []string{
+		"https://ftp.gnu.org/gnu/hello/hello-2.10.tar.gz",
+		"https://ftp.gnu.org/gnu/hello/hello-2.12rc1.tar.xz",
+		"https://ftp.gnu.org/gnu/hello/hello-2.9.tar.gz",
+		"hello-2.12.tar.gz",
+		"hello-2.12.tar.gz.sig",
+		"https://ftp.gnu.org/gnu/hello/README",
+	}
+	all, err := entry.Scan(candidates)
+	isok(t, err)
+	assert(t, len(all) == 4)
+	assert(t, all[0].Version.String() == "2.12")
+	assert(t, all[1].Version.String() == "2.12~rc1")
+	assert(t, all[1].Upstream == "2.12rc1")
+	assert(t, all[3].Version.String() == "2.9")
+
+	current, err := version.Parse("2.10+dfsg1-3")
+	isok(t, err)
+	newest, newer, err := entry.Check(current, candidates)
+	isok(t, err)
+	assert(t, newer)
+	assert(t, newest.URL == "hello-2.12.tar.gz")
+
+	current, err = version.Parse("2.12+dfsg-1")
+	isok(t, err)
+	_, newer, err = entry.Check(current, candidates)
+	isok(t, err)
+	assert(t, !newer)
+
+	newest, err = w.Entries[1].Newest([]string{"foo-1_2.tar.gz", "foo-1_10.tar.gz", "bar-2_0.tar.gz"})
+	isok(t, err)
+	assert(t, newest.Version.String() == "1.10")
+	newest, err = w.Entries[1].Newest([]string{"bar-2_0.tar.gz"})
+	isok(t, err)
+	assert(t, newest == nil)
+}
+
+func TestRules(t *testing.T) {
+	rules, err := watch.ParseRules(`s/^v//; s|_|.|g;tr/A-Z/a-z/; s/(\d+)b(\d+)/$1~beta\2/`)
+	isok(t, err)
+	assert(t, len(rules) == 4)
+	assert(t, watch.Mangle(rules, "v1_2_3B4") == "1.2.3~beta4")
+
+	rules, err = watch.ParseRules(`s/\//-/; s/x/\$/; y/ab//d`)
+	isok(t, err)
+	assert(t, watch.Mangle(rules, "1/2/x-abc") == "1-2/$-c")
+
+	for _, value := range []string{"q/a/b/", "s/a/b", "s/a/b/z", "s/(?=a)/b/", "tr/a/b/q", "s/a/b/ junk"} {
+		_, err := watch.ParseRules(value)
+		notok(t, err)
+	}
+}
+
+// vim: foldmethod=marker